@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Defaults of the rotation of the access log
+const (
+	defaultAccessLogMaxMB = 10
+	defaultAccessLogKeep  = 3
+)
+
+// accessEntry is a line of the access log of the HTTP API.
+type accessEntry struct {
+	Time   time.Time `json:"time"`
+	Method string    `json:"method"`
+	// Path is the path of the request, without the query, which may name
+	// the sites looked up
+	Path      string  `json:"path"`
+	Status    int     `json:"status"`
+	LatencyMS float64 `json:"latencyMs"`
+	Bytes     int     `json:"bytes"`
+	Remote    string  `json:"remote"`
+	// Client is the name of the client from the tokens file, if any
+	Client string `json:"client,omitempty"`
+	// Result is "ok", or the error type of a failed request
+	Result string `json:"result"`
+}
+
+// accessRecord collects what the handlers know about a request for its
+// access log entry.
+type accessRecord struct {
+	Client string
+	Result string
+}
+
+type accessRecordKey struct{}
+
+// accessRecordOf returns the record of a request, or nil if the request is
+// not logged.
+func accessRecordOf(r *http.Request) *accessRecord {
+	record, _ := r.Context().Value(accessRecordKey{}).(*accessRecord)
+	return record
+}
+
+// noteResult records the result of a request for the access log.
+func noteResult(r *http.Request, result string) {
+	if record := accessRecordOf(r); record != nil {
+		record.Result = result
+	}
+}
+
+// statusRecorder remembers the status and size of a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (w *statusRecorder) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusRecorder) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.bytes += n
+	return n, err
+}
+
+// logAccess writes an entry to out for each request, as a line of JSON. Each
+// request is also logged at verbosity -v, like the actions of other commands.
+func logAccess(next http.Handler, out io.Writer) http.Handler {
+	var mu sync.Mutex
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		record := &accessRecord{}
+		recorder := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r.WithContext(context.WithValue(r.Context(), accessRecordKey{}, record)))
+
+		remote, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			remote = r.RemoteAddr
+		}
+		entry := accessEntry{
+			Time:      start.UTC(),
+			Method:    r.Method,
+			Path:      r.URL.Path,
+			Status:    recorder.status,
+			LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+			Bytes:     recorder.bytes,
+			Remote:    remote,
+			Client:    record.Client,
+			Result:    record.Result,
+		}
+		if entry.Status == 0 {
+			entry.Status = http.StatusOK
+		}
+		if entry.Result == "" {
+			entry.Result = "ok"
+			if entry.Status >= http.StatusBadRequest {
+				entry.Result = "error"
+			}
+		}
+
+		logf(levelActions, "%s %s %d %.1fms %s", entry.Method, entry.Path, entry.Status, entry.LatencyMS, entry.Result)
+		if out == nil {
+			return
+		}
+		line, _ := json.Marshal(entry)
+		mu.Lock()
+		defer mu.Unlock()
+		if _, err := out.Write(append(line, '\n')); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not write the access log: %v\n", err)
+		}
+	})
+}
+
+// rotatingFile is a log file that is renamed to path.1 once it reaches
+// maxBytes, with the older files shifted to path.2 and so on; only keep old
+// files are kept.
+type rotatingFile struct {
+	path     string
+	maxBytes int64
+	keep     int
+	mu       sync.Mutex
+	f        *os.File
+	size     int64
+}
+
+// openRotatingFile opens the log at path for appending.
+func openRotatingFile(path string, maxBytes int64, keep int) (*rotatingFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), privateDirMode); err != nil {
+		return nil, err
+	}
+	rf := &rotatingFile{path: path, maxBytes: maxBytes, keep: keep}
+	if err := rf.open(); err != nil {
+		return nil, err
+	}
+	return rf, nil
+}
+
+func (rf *rotatingFile) open() error {
+	f, err := os.OpenFile(rf.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, privateFileMode)
+	if err != nil {
+		return fmt.Errorf("failed to open the log: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	rf.f, rf.size = f, info.Size()
+	return nil
+}
+
+// Write appends p to the log, rotating it first if p would make it larger
+// than maxBytes. A line is never split between two files.
+func (rf *rotatingFile) Write(p []byte) (int, error) {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	if rf.size > 0 && rf.size+int64(len(p)) > rf.maxBytes {
+		if err := rf.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := rf.f.Write(p)
+	rf.size += int64(n)
+	return n, err
+}
+
+// rotate shifts the old logs, dropping the oldest, and starts a new log.
+func (rf *rotatingFile) rotate() error {
+	rf.f.Close()
+	os.Remove(fmt.Sprintf("%s.%d", rf.path, rf.keep))
+	for i := rf.keep - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", rf.path, i), fmt.Sprintf("%s.%d", rf.path, i+1))
+	}
+	if rf.keep > 0 {
+		os.Rename(rf.path, rf.path+".1")
+	} else {
+		os.Remove(rf.path)
+	}
+	return rf.open()
+}
+
+// Close closes the log.
+func (rf *rotatingFile) Close() error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	return rf.f.Close()
+}
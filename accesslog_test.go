@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLogAccess(t *testing.T) {
+	clients := []serveClient{{Name: "phone", Token: "0123456789abcdef"}}
+	var out bytes.Buffer
+	handler := logAccess(requireToken(newAPIHandler(stubAliases{}), clients, newRateLimiter(60)), &out)
+
+	for _, auth := range []string{"Bearer 0123456789abcdef", ""} {
+		req := httptest.NewRequest("GET", "/aliases?site=secret.example", nil)
+		req.RemoteAddr = "192.0.2.7:51234"
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 entries, got:\n%s", out.String())
+	}
+	var ok, denied accessEntry
+	json.Unmarshal([]byte(lines[0]), &ok)
+	json.Unmarshal([]byte(lines[1]), &denied)
+	if ok.Method != "GET" || ok.Path != "/aliases" || ok.Status != http.StatusOK || ok.Client != "phone" || ok.Result != "ok" || ok.Remote != "192.0.2.7" || ok.Bytes == 0 {
+		t.Errorf("unexpected entry %+v", ok)
+	}
+	if denied.Status != http.StatusUnauthorized || denied.Client != "" || denied.Result != "auth" {
+		t.Errorf("unexpected entry for the request without a token %+v", denied)
+	}
+	if strings.Contains(out.String(), "secret.example") {
+		t.Error("the query was logged")
+	}
+}
+
+func TestLogAccessRecordsErrorType(t *testing.T) {
+	_, client := newFakeJMAP(t)
+	var out bytes.Buffer
+	handler := logAccess(newAPIHandler(&aliasService{client: client}), &out)
+	req := httptest.NewRequest("PUT", "/aliases/missing@fastmail.com/state", strings.NewReader(`{"state": "enabled"}`))
+	req.Header.Set("Content-Type", "application/json")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	var entry accessEntry
+	if err := json.Unmarshal(out.Bytes(), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry.Status != http.StatusNotFound || entry.Result != "notFound" {
+		t.Errorf("unexpected entry %+v", entry)
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	rf, err := openRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err := rf.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rf.Close()
+
+	// Each line fills the 10 byte log, so each write rotates; only 2 old
+	// logs are kept
+	want := map[string]string{"": "fourth\n", ".1": "third\n", ".2": "second\n"}
+	for suffix, content := range want {
+		data, err := os.ReadFile(path + suffix)
+		if err != nil || string(data) != content {
+			t.Errorf("%s = %q, %v; want %q", filepath.Base(path+suffix), data, err, content)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("expected no third old log, got %v", err)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// newAccountsCmd creates the command group for inspecting accessible accounts.
+func newAccountsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "accounts",
+		Short: "Inspect the accounts the API token can access",
+	}
+	cmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List accounts available to the API token, including delegated accounts",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, err := newClientFromCmd(cmd)
+			if err != nil {
+				return err
+			}
+			return handleAccountsList(client)
+		},
+	})
+	return cmd
+}
+
+// handleAccountsList prints the accounts from the session, marking the one
+// currently selected.
+func handleAccountsList(client *FastmailClient) error {
+	session, err := client.GetSession()
+	if err != nil {
+		return formatAPIError("failed to get session", err)
+	}
+
+	ids := make([]string, 0, len(session.Accounts))
+	for id := range session.Accounts {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return session.Accounts[ids[i]].Name < session.Accounts[ids[j]].Name
+	})
+
+	if porcelain != "" {
+		// id, name, personal|shared, read-only|read-write, masked email supported (yes|no), selected (*)
+		for _, id := range ids {
+			account := session.Accounts[id]
+			selected := ""
+			if id == client.AccountID {
+				selected = "*"
+			}
+			printPorcelain(id, account.Name, choose(account.IsPersonal, "personal", "shared"),
+				choose(account.IsReadOnly, "read-only", "read-write"), choose(account.SupportsMaskedEmail(), "yes", "no"), selected)
+		}
+		return nil
+	}
+
+	fmt.Printf("Accounts available to %s:\n", session.Username)
+	for _, id := range ids {
+		account := session.Accounts[id]
+		marker := " "
+		if id == client.AccountID {
+			marker = "*"
+		}
+
+		var notes []string
+		if account.IsPersonal {
+			notes = append(notes, "personal")
+		} else {
+			notes = append(notes, "shared")
+		}
+		if account.IsReadOnly {
+			notes = append(notes, "read-only")
+		}
+		if !account.SupportsMaskedEmail() {
+			notes = append(notes, "no masked email")
+		}
+
+		fmt.Printf("%s %s  %s (%s)\n", marker, id, sanitizeText(account.Name), strings.Join(notes, ", "))
+	}
+	return nil
+}
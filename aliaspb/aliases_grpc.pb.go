@@ -0,0 +1,301 @@
+// The gRPC interface of serve mode. It offers the same alias operations as
+// the line protocol and the HTTP API; see "masked_fastmail serve --help".
+//
+// Regenerate the Go code after changing this file, from the repository root:
+//
+//   protoc --go_out=. --go_opt=paths=source_relative \
+//     --go-grpc_out=. --go-grpc_opt=paths=source_relative aliaspb/aliases.proto
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: aliaspb/aliases.proto
+
+package aliaspb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	AliasService_GetAlias_FullMethodName    = "/maskedfastmail.v1.AliasService/GetAlias"
+	AliasService_CreateAlias_FullMethodName = "/maskedfastmail.v1.AliasService/CreateAlias"
+	AliasService_ListAliases_FullMethodName = "/maskedfastmail.v1.AliasService/ListAliases"
+	AliasService_UpdateState_FullMethodName = "/maskedfastmail.v1.AliasService/UpdateState"
+	AliasService_Refresh_FullMethodName     = "/maskedfastmail.v1.AliasService/Refresh"
+)
+
+// AliasServiceClient is the client API for AliasService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// AliasService gets, creates, lists and changes the masked email aliases of
+// the account serve runs for.
+type AliasServiceClient interface {
+	// GetAlias returns the alias for a site, created if there is none.
+	GetAlias(ctx context.Context, in *GetAliasRequest, opts ...grpc.CallOption) (*Alias, error)
+	// CreateAlias creates a new alias for a site, even if it already has one.
+	CreateAlias(ctx context.Context, in *CreateAliasRequest, opts ...grpc.CallOption) (*Alias, error)
+	// ListAliases streams the aliases of a site that aren't deleted, or every
+	// alias when no site is given.
+	ListAliases(ctx context.Context, in *ListAliasesRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Alias], error)
+	// UpdateState sets the state of each alias sent on the stream, and answers
+	// each request in order. A failed update is answered with an error and
+	// doesn't end the stream. Protected aliases are not deleted.
+	UpdateState(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[UpdateStateRequest, UpdateStateResponse], error)
+	// Refresh loads the session again and forgets the last readiness check.
+	Refresh(ctx context.Context, in *RefreshRequest, opts ...grpc.CallOption) (*RefreshResponse, error)
+}
+
+type aliasServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAliasServiceClient(cc grpc.ClientConnInterface) AliasServiceClient {
+	return &aliasServiceClient{cc}
+}
+
+func (c *aliasServiceClient) GetAlias(ctx context.Context, in *GetAliasRequest, opts ...grpc.CallOption) (*Alias, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Alias)
+	err := c.cc.Invoke(ctx, AliasService_GetAlias_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aliasServiceClient) CreateAlias(ctx context.Context, in *CreateAliasRequest, opts ...grpc.CallOption) (*Alias, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Alias)
+	err := c.cc.Invoke(ctx, AliasService_CreateAlias_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aliasServiceClient) ListAliases(ctx context.Context, in *ListAliasesRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Alias], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &AliasService_ServiceDesc.Streams[0], AliasService_ListAliases_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ListAliasesRequest, Alias]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type AliasService_ListAliasesClient = grpc.ServerStreamingClient[Alias]
+
+func (c *aliasServiceClient) UpdateState(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[UpdateStateRequest, UpdateStateResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &AliasService_ServiceDesc.Streams[1], AliasService_UpdateState_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[UpdateStateRequest, UpdateStateResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type AliasService_UpdateStateClient = grpc.BidiStreamingClient[UpdateStateRequest, UpdateStateResponse]
+
+func (c *aliasServiceClient) Refresh(ctx context.Context, in *RefreshRequest, opts ...grpc.CallOption) (*RefreshResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RefreshResponse)
+	err := c.cc.Invoke(ctx, AliasService_Refresh_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AliasServiceServer is the server API for AliasService service.
+// All implementations must embed UnimplementedAliasServiceServer
+// for forward compatibility.
+//
+// AliasService gets, creates, lists and changes the masked email aliases of
+// the account serve runs for.
+type AliasServiceServer interface {
+	// GetAlias returns the alias for a site, created if there is none.
+	GetAlias(context.Context, *GetAliasRequest) (*Alias, error)
+	// CreateAlias creates a new alias for a site, even if it already has one.
+	CreateAlias(context.Context, *CreateAliasRequest) (*Alias, error)
+	// ListAliases streams the aliases of a site that aren't deleted, or every
+	// alias when no site is given.
+	ListAliases(*ListAliasesRequest, grpc.ServerStreamingServer[Alias]) error
+	// UpdateState sets the state of each alias sent on the stream, and answers
+	// each request in order. A failed update is answered with an error and
+	// doesn't end the stream. Protected aliases are not deleted.
+	UpdateState(grpc.BidiStreamingServer[UpdateStateRequest, UpdateStateResponse]) error
+	// Refresh loads the session again and forgets the last readiness check.
+	Refresh(context.Context, *RefreshRequest) (*RefreshResponse, error)
+	mustEmbedUnimplementedAliasServiceServer()
+}
+
+// UnimplementedAliasServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedAliasServiceServer struct{}
+
+func (UnimplementedAliasServiceServer) GetAlias(context.Context, *GetAliasRequest) (*Alias, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetAlias not implemented")
+}
+func (UnimplementedAliasServiceServer) CreateAlias(context.Context, *CreateAliasRequest) (*Alias, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateAlias not implemented")
+}
+func (UnimplementedAliasServiceServer) ListAliases(*ListAliasesRequest, grpc.ServerStreamingServer[Alias]) error {
+	return status.Errorf(codes.Unimplemented, "method ListAliases not implemented")
+}
+func (UnimplementedAliasServiceServer) UpdateState(grpc.BidiStreamingServer[UpdateStateRequest, UpdateStateResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method UpdateState not implemented")
+}
+func (UnimplementedAliasServiceServer) Refresh(context.Context, *RefreshRequest) (*RefreshResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Refresh not implemented")
+}
+func (UnimplementedAliasServiceServer) mustEmbedUnimplementedAliasServiceServer() {}
+func (UnimplementedAliasServiceServer) testEmbeddedByValue()                      {}
+
+// UnsafeAliasServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AliasServiceServer will
+// result in compilation errors.
+type UnsafeAliasServiceServer interface {
+	mustEmbedUnimplementedAliasServiceServer()
+}
+
+func RegisterAliasServiceServer(s grpc.ServiceRegistrar, srv AliasServiceServer) {
+	// If the following call pancis, it indicates UnimplementedAliasServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&AliasService_ServiceDesc, srv)
+}
+
+func _AliasService_GetAlias_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetAliasRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AliasServiceServer).GetAlias(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AliasService_GetAlias_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AliasServiceServer).GetAlias(ctx, req.(*GetAliasRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AliasService_CreateAlias_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateAliasRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AliasServiceServer).CreateAlias(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AliasService_CreateAlias_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AliasServiceServer).CreateAlias(ctx, req.(*CreateAliasRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AliasService_ListAliases_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ListAliasesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(AliasServiceServer).ListAliases(m, &grpc.GenericServerStream[ListAliasesRequest, Alias]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type AliasService_ListAliasesServer = grpc.ServerStreamingServer[Alias]
+
+func _AliasService_UpdateState_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(AliasServiceServer).UpdateState(&grpc.GenericServerStream[UpdateStateRequest, UpdateStateResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type AliasService_UpdateStateServer = grpc.BidiStreamingServer[UpdateStateRequest, UpdateStateResponse]
+
+func _AliasService_Refresh_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RefreshRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AliasServiceServer).Refresh(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AliasService_Refresh_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AliasServiceServer).Refresh(ctx, req.(*RefreshRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// AliasService_ServiceDesc is the grpc.ServiceDesc for AliasService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var AliasService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "maskedfastmail.v1.AliasService",
+	HandlerType: (*AliasServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetAlias",
+			Handler:    _AliasService_GetAlias_Handler,
+		},
+		{
+			MethodName: "CreateAlias",
+			Handler:    _AliasService_CreateAlias_Handler,
+		},
+		{
+			MethodName: "Refresh",
+			Handler:    _AliasService_Refresh_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ListAliases",
+			Handler:       _AliasService_ListAliases_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "UpdateState",
+			Handler:       _AliasService_UpdateState_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "aliaspb/aliases.proto",
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// auditLogFile is the audit log in the data directory: one JSON entry per
+// line, only ever appended to. Unlike the other local data it is a file
+// whatever the storage, so that it can be copied and checked as it is.
+const auditLogFile = "audit.log"
+
+// Audit log actions
+const (
+	auditCreate         = "create"
+	auditUpdate         = "update"
+	auditDestroy        = "destroy"
+	auditCreateIdentity = "create-identity"
+)
+
+// auditEntry is a change made to the account by this tool. Each entry holds
+// the hash of the one before it, so that changing or removing an entry
+// breaks the chain from there on.
+type auditEntry struct {
+	Seq     int       `json:"seq"`
+	At      time.Time `json:"at"`
+	User    string    `json:"user,omitempty"`
+	Host    string    `json:"host,omitempty"`
+	Account string    `json:"account"`
+	Action  string    `json:"action"`
+	// Alias is the email of the alias or identity changed, if known
+	Alias string `json:"alias,omitempty"`
+	ID    string `json:"id,omitempty"`
+	// Changes are the properties set, such as state and description
+	Changes map[string]string `json:"changes,omitempty"`
+	Prev    string            `json:"prev"`
+	Hash    string            `json:"hash"`
+}
+
+// hash returns the hash of the entry, computed over all its fields but the
+// hash itself.
+func (e auditEntry) hash() string {
+	e.Hash = ""
+	// Maps are encoded with sorted keys, so the encoding is stable
+	data, _ := json.Marshal(e)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// updateChanges returns the properties an update sets.
+func updateChanges(update MaskedEmailUpdate) map[string]string {
+	changes := make(map[string]string, 2)
+	if update.State != nil {
+		changes["state"] = string(*update.State)
+	}
+	if update.Description != nil {
+		changes["description"] = *update.Description
+	}
+	return changes
+}
+
+// auditLogPath returns the location of the audit log.
+func auditLogPath() (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate data directory: %w", err)
+	}
+	return filepath.Join(dir, auditLogFile), nil
+}
+
+// recordAudit appends entries for changes the client made. The entries are
+// completed with the time, the local user and the account. A failure only
+// prints a warning, since the account was already changed.
+func (fc *FastmailClient) recordAudit(entries ...auditEntry) {
+	if len(entries) == 0 {
+		return
+	}
+	user, host := currentOwner()
+	now := time.Now().UTC()
+	for i := range entries {
+		entries[i].At, entries[i].User, entries[i].Host, entries[i].Account = now, user, host, fc.AccountID
+		if entries[i].Alias == "" {
+			entries[i].Alias = fc.aliasEmails[entries[i].ID]
+		}
+	}
+	path, err := auditLogPath()
+	if err == nil {
+		err = appendAudit(path, entries)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not write the audit log: %v\n", err)
+	}
+}
+
+// appendAudit chains the entries to the last entry of the log at path and
+// appends them. Like other local data, the log is not locked: runs writing
+// at the same moment may fork the chain, which verify then reports.
+func appendAudit(path string, entries []auditEntry) error {
+	if err := os.MkdirAll(filepath.Dir(path), privateDirMode); err != nil {
+		return err
+	}
+	existing, err := readAuditLog(path)
+	if err != nil {
+		return err
+	}
+	last := auditEntry{}
+	if len(existing) > 0 {
+		last = existing[len(existing)-1]
+	}
+
+	var buf bytes.Buffer
+	for _, entry := range entries {
+		entry.Seq, entry.Prev = last.Seq+1, last.Hash
+		entry.Hash = entry.hash()
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		buf.Write(append(line, '\n'))
+		last = entry
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, privateFileMode)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// readAuditLog reads the entries of the log at path. A missing log has no
+// entries.
+func readAuditLog(path string) ([]auditEntry, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []auditEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var entry auditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return entries, fmt.Errorf("%s line %d is not an audit entry: %w", path, line, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// verifyAudit checks the hash chain of the entries and returns an error
+// naming the first entry that was changed, removed or inserted.
+func verifyAudit(entries []auditEntry) error {
+	prev := auditEntry{}
+	for _, entry := range entries {
+		switch {
+		case entry.Hash != entry.hash():
+			return fmt.Errorf("entry %d was changed: its hash doesn't match its contents", entry.Seq)
+		case entry.Seq != prev.Seq+1 || entry.Prev != prev.Hash:
+			return fmt.Errorf("the chain is broken before entry %d: entries were removed, inserted or reordered", entry.Seq)
+		}
+		prev = entry
+	}
+	return nil
+}
+
+// newAuditLogCmd creates the command group for the audit log.
+func newAuditLogCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "audit-log",
+		Short: "Show and verify the log of changes made with this tool",
+		Long: `Every alias this tool creates, updates or destroys, and every sending identity
+it creates, is appended to an audit log in the data directory, with the time,
+the local user and host, and the account. Each entry includes the hash of the
+one before it, so that verify detects entries that were changed or removed
+afterwards. Removing entries from the end can only be detected by comparing
+with the head hash printed by verify, so keep it somewhere else.`,
+	}
+
+	showCmd := &cobra.Command{
+		Use:   "show",
+		Short: "Print the audit log",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			limit, _ := cmd.Flags().GetInt("limit")
+			path, err := auditLogPath()
+			if err != nil {
+				return err
+			}
+			entries, err := readAuditLog(path)
+			if err != nil {
+				return err
+			}
+			if limit > 0 && len(entries) > limit {
+				entries = entries[len(entries)-limit:]
+			}
+			printAuditLog(entries)
+			return nil
+		},
+	}
+	showCmd.Flags().Int("limit", 0, "only print the last n entries")
+	cmd.AddCommand(showCmd)
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "verify",
+		Short: "Check that no entry of the audit log was changed or removed",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := auditLogPath()
+			if err != nil {
+				return err
+			}
+			entries, err := readAuditLog(path)
+			if err != nil {
+				return err
+			}
+			if err := verifyAudit(entries); err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			head := ""
+			if len(entries) > 0 {
+				head = entries[len(entries)-1].Hash
+			}
+			if porcelain != "" {
+				// entries, head hash
+				printPorcelain(strconv.Itoa(len(entries)), head)
+				return nil
+			}
+			if len(entries) == 0 {
+				fmt.Println("The audit log is empty")
+				return nil
+			}
+			fmt.Printf("%s verified; the chain is intact\n", plural(len(entries), "entry", "entries"))
+			fmt.Printf("Head: %s\n", head)
+			return nil
+		},
+	})
+	return cmd
+}
+
+// formatChanges renders changes as name=value pairs in name order.
+func formatChanges(changes map[string]string) string {
+	names := make([]string, 0, len(changes))
+	for name := range changes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, name+"="+strconv.Quote(changes[name]))
+	}
+	return strings.Join(pairs, " ")
+}
+
+// printAuditLog prints the entries, oldest first.
+func printAuditLog(entries []auditEntry) {
+	if porcelain != "" {
+		// seq, at (RFC 3339), user, host, account, action, alias, id, changes
+		for _, entry := range entries {
+			printPorcelain(strconv.Itoa(entry.Seq), entry.At.Format(time.RFC3339), entry.User, entry.Host,
+				entry.Account, entry.Action, entry.Alias, entry.ID, formatChanges(entry.Changes))
+		}
+		return
+	}
+	if len(entries) == 0 {
+		fmt.Println("The audit log is empty")
+		return
+	}
+	for _, entry := range entries {
+		target := entry.Alias
+		if target == "" {
+			target = entry.ID
+		}
+		who := entry.User
+		if entry.Host != "" {
+			who += "@" + entry.Host
+		}
+		line := fmt.Sprintf("%5d  %s  %s  %s %s", entry.Seq, formatTime(entry.At), sanitizeText(who), entry.Action, target)
+		if len(entry.Changes) > 0 {
+			line += "  " + sanitizeText(formatChanges(entry.Changes))
+		}
+		fmt.Println(line)
+	}
+}
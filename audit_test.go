@@ -0,0 +1,126 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeTestAuditLog(t *testing.T) (string, []auditEntry) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), auditLogFile)
+	at := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	if err := appendAudit(path, []auditEntry{
+		{At: at, User: "alice", Account: "u1", Action: auditCreate, Alias: "shop.1@fastmail.com", ID: "a1", Changes: map[string]string{"state": "enabled"}},
+		{At: at, User: "alice", Account: "u1", Action: auditUpdate, Alias: "shop.1@fastmail.com", ID: "a1", Changes: map[string]string{"state": "disabled"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := appendAudit(path, []auditEntry{{At: at, User: "bob", Account: "u1", Action: auditDestroy, ID: "a1"}}); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := readAuditLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path, entries
+}
+
+func TestAppendAuditChainsEntries(t *testing.T) {
+	_, entries := writeTestAuditLog(t)
+	if len(entries) != 3 {
+		t.Fatalf("got %d entries, want 3", len(entries))
+	}
+	for i, entry := range entries {
+		if entry.Seq != i+1 {
+			t.Errorf("entry %d has seq %d", i, entry.Seq)
+		}
+	}
+	if entries[0].Prev != "" || entries[2].Prev != entries[1].Hash {
+		t.Errorf("entries are not chained: %+v", entries)
+	}
+	if err := verifyAudit(entries); err != nil {
+		t.Errorf("verifyAudit() = %v, want nil", err)
+	}
+}
+
+func TestClientRecordsAudit(t *testing.T) {
+	_, client := newFakeJMAP(t,
+		MaskedEmailInfo{ID: "a1", Email: "shop.1@fastmail.com", State: AliasEnabled},
+		MaskedEmailInfo{ID: "a2", Email: "news.2@fastmail.com", State: AliasEnabled},
+	)
+	if err := handleStateUpdate(client, "shop.1@fastmail.com", false, true, false, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := handleStateUpdate(client, "news.2@fastmail.com", false, false, true, false); err != nil {
+		t.Fatal(err)
+	}
+
+	path, err := auditLogPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := readAuditLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want one per change: %+v", len(entries), entries)
+	}
+	if entries[0].Alias != "shop.1@fastmail.com" || entries[0].Account != "u1" || entries[0].Changes["state"] != "disabled" {
+		t.Errorf("first entry = %+v", entries[0])
+	}
+	if entries[1].Alias != "news.2@fastmail.com" || entries[1].Changes["state"] != "deleted" || entries[1].Prev != entries[0].Hash {
+		t.Errorf("second entry = %+v; want it chained to the first", entries[1])
+	}
+	if err := verifyAudit(entries); err != nil {
+		t.Errorf("verifyAudit() = %v, want nil", err)
+	}
+}
+
+func TestVerifyAuditDetectsTampering(t *testing.T) {
+	_, entries := writeTestAuditLog(t)
+
+	changed := append([]auditEntry(nil), entries...)
+	changed[1].Changes = map[string]string{"state": "enabled"}
+	if err := verifyAudit(changed); err == nil || !strings.Contains(err.Error(), "entry 2 was changed") {
+		t.Errorf("a changed entry: got %v", err)
+	}
+
+	removed := []auditEntry{entries[0], entries[2]}
+	if err := verifyAudit(removed); err == nil || !strings.Contains(err.Error(), "before entry 3") {
+		t.Errorf("a removed entry: got %v", err)
+	}
+
+	// Rewriting an entry with a matching hash still breaks the next link
+	rehashed := append([]auditEntry(nil), entries...)
+	rehashed[1].User = "mallory"
+	rehashed[1].Hash = rehashed[1].hash()
+	if err := verifyAudit(rehashed); err == nil {
+		t.Errorf("a rehashed entry went unnoticed")
+	}
+}
+
+func TestReadAuditLogReportsBadLines(t *testing.T) {
+	path, _ := writeTestAuditLog(t)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("not json\n")
+	f.Close()
+
+	if _, err := readAuditLog(path); err == nil || !strings.Contains(err.Error(), "line 4") {
+		t.Errorf("readAuditLog() = %v, want an error naming line 4", err)
+	}
+}
+
+func TestPrintAuditLog(t *testing.T) {
+	_, entries := writeTestAuditLog(t)
+	out := captureStdout(t, func() { printAuditLog(entries) })
+	if !strings.Contains(out, `update shop.1@fastmail.com  state="disabled"`) || !strings.Contains(out, "destroy a1") {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// capabilityNames describes the JMAP capabilities a token can be granted
+var capabilityNames = map[string]string{
+	capabilityCore:                          "core",
+	capabilityMail:                          "mail",
+	capabilitySubmission:                    "sending mail",
+	maskedEmailNamespace:                    "masked email",
+	"urn:ietf:params:jmap:contacts":         "contacts",
+	"urn:ietf:params:jmap:calendars":        "calendars",
+	"urn:ietf:params:jmap:vacationresponse": "vacation response",
+}
+
+// tokenStatus is what auth status reports about the API token.
+type tokenStatus struct {
+	Source   string
+	Token    string
+	Username string
+	// AccountID and Account are the account selected for the token
+	AccountID string
+	Account   SessionAccount
+	// Capabilities are the capabilities granted for the account, sorted
+	Capabilities []string
+	// LastUsed is when this tool last fetched a session with the token,
+	// if known; Fastmail doesn't report when a token was last used
+	LastUsed *time.Time
+}
+
+// newAuthCmd creates the command group for inspecting the API token.
+func newAuthCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "auth",
+		Short: "Inspect the API token",
+	}
+	cmd.AddCommand(&cobra.Command{
+		Use:   "status",
+		Short: "Show what the API token grants and whether it is read-only",
+		Long: `Fetch a fresh session with the API token and report where the token was read
+from, the user and account it belongs to, the capabilities it grants, whether
+it is read-only, and when this tool last used it. A rejected token exits with
+status 3, so this also checks that a token works.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Read before the client refreshes the cached session
+			var cached cachedSession
+			_ = readJSONFile(sessionCacheFile, &cached)
+
+			client, err := newClientFromCmd(cmd)
+			if err != nil {
+				return err
+			}
+			var lastUsed *time.Time
+			if cached.Session != nil && cached.TokenHash == tokenFingerprint(client.Token) {
+				lastUsed = &cached.FetchedAt
+			}
+			status, err := fetchTokenStatus(client, lastUsed)
+			if err != nil {
+				return err
+			}
+			printTokenStatus(status)
+			return nil
+		},
+	})
+
+	rotateCmd := &cobra.Command{
+		Use:   "rotate",
+		Short: "Check a new API token and swap it into the token file",
+		Long: `Read a new API token from stdin, check that it works by fetching the session
+and reading the aliases, and only then replace the token in the file named by
+FASTMAIL_API_KEY_FILE, or by --file. The file is replaced atomically, so it
+never holds a partial token. With --archive, a fingerprint of the old token
+(never the token itself) is kept in the data directory, to tell later which
+token was retired when.
+
+When the token comes from FASTMAIL_API_KEY or a systemd credential and no
+--file is given, the new token is only checked, and you update it where it is
+set.
+
+A token of a different Fastmail user than the old one is only swapped in after
+confirmation; when the token is piped in, pass --yes to confirm.`,
+		Example: `  pbpaste | masked_fastmail auth rotate
+  masked_fastmail auth rotate --file /etc/masked_fastmail/api_key --archive < new_key`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if readOnlyForced() {
+				return fmt.Errorf("%w: refusing to replace the API token, since %s is set", ErrReadOnly, readOnlyEnv)
+			}
+			path, _ := cmd.Flags().GetString("file")
+			archive, _ := cmd.Flags().GetBool("archive")
+			if path == "" {
+				path = os.Getenv(apiKeyFileEnv)
+			}
+			newToken, err := readNewToken(os.Stdin)
+			if err != nil {
+				return err
+			}
+			return handleAuthRotate(cmd, newToken, path, archive)
+		},
+	}
+	rotateCmd.Flags().String("file", "", "token file to write the new token to (default $"+apiKeyFileEnv+")")
+	rotateCmd.Flags().Bool("archive", false, "keep a fingerprint of the old token in the data directory")
+	cmd.AddCommand(rotateCmd)
+	return cmd
+}
+
+// retiredTokensFile lists the fingerprints of tokens replaced by auth rotate
+const retiredTokensFile = "retired-tokens.json"
+
+// retiredToken identifies a token replaced by auth rotate without revealing
+// it.
+type retiredToken struct {
+	Fingerprint string    `json:"fingerprint"`
+	Hint        string    `json:"hint"`
+	Source      string    `json:"source"`
+	RetiredAt   time.Time `json:"retiredAt"`
+}
+
+// readNewToken reads a token from the first line of r. At a terminal the
+// user is asked to paste it; the token is never taken as an argument, where
+// it would end up in the shell history.
+func readNewToken(in *os.File) (string, error) {
+	if isTerminal(in) {
+		if noInput {
+			return "", errNoInput
+		}
+		fmt.Fprint(os.Stderr, "Paste the new API token and press Enter: ")
+	}
+	line, err := readAnswer(bufio.NewReader(in))
+	if err != nil && line == "" {
+		return "", fmt.Errorf("failed to read the new API token: %w", err)
+	}
+	token := strings.TrimSpace(line)
+	if token == "" {
+		return "", fmt.Errorf("no API token was given on stdin")
+	}
+	return token, nil
+}
+
+// handleAuthRotate checks the new token and swaps it into the token file.
+func handleAuthRotate(cmd *cobra.Command, newToken, path string, archive bool) error {
+	oldToken, _ := loadToken()
+	oldSource := credentialSource()
+	if oldToken == newToken {
+		return fmt.Errorf("the new token is the one already in use")
+	}
+
+	client, err := newClientWithToken(cmd, newToken)
+	if err != nil {
+		return fmt.Errorf("the new token doesn't work: %w", err)
+	}
+	session, err := verifyToken(client)
+	if err != nil {
+		return err
+	}
+	if oldToken != "" {
+		if err := confirmTokenOwner(tokenUsername(cmd, oldToken), session.Username); err != nil {
+			return err
+		}
+	}
+
+	if path == "" {
+		fmt.Printf("Update %s with the new token where it is set; nothing was written\n", oldSource)
+		return nil
+	}
+	data := []byte(newToken + "\n")
+	defer clear(data)
+	if err := writeFileAtomic(path, data, privateFileMode); err != nil {
+		return fmt.Errorf("failed to write the new token: %w", err)
+	}
+	fmt.Printf("Replaced the token in %s\n", path)
+
+	if archive && oldToken != "" {
+		if err := archiveToken(oldToken, oldSource, time.Now()); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not archive the old token: %v\n", err)
+		}
+	}
+	fmt.Fprintln(os.Stderr, "Revoke the old token in Fastmail under Settings > Privacy & Security > Manage API tokens")
+	return nil
+}
+
+// confirmTokenOwner confirms replacing the token of oldUser with one of
+// newUser; tokens of the same user, or an old token that no longer works,
+// need no confirmation. The new token is usually piped in, so there is
+// rarely a terminal to ask on: then the swap is refused unless --yes is
+// given, rather than silently switching users.
+func confirmTokenOwner(oldUser, newUser string) error {
+	if oldUser == "" || oldUser == newUser {
+		return nil
+	}
+	err := confirm(fmt.Sprintf("The old token belongs to %s, the new one to %s. Replace it anyway?", oldUser, newUser))
+	if errors.Is(err, ErrNotConfirmed) {
+		return fmt.Errorf("%w: the new token belongs to %s, not %s like the old one; pass --yes to replace it anyway", ErrNotConfirmed, newUser, oldUser)
+	}
+	return err
+}
+
+// verifyToken checks that the token of the client works by fetching the
+// session and reading the aliases.
+func verifyToken(client *FastmailClient) (*Session, error) {
+	session, err := client.GetSession()
+	if err != nil {
+		return nil, formatAPIError("the new token doesn't work", err)
+	}
+	aliases, err := client.FetchAllAliases()
+	if err != nil {
+		return nil, formatAPIError("the new token can't read the aliases", err)
+	}
+	fmt.Fprintf(os.Stderr, "The new token works: it belongs to %s and read %s\n",
+		sanitizeText(session.Username), plural(len(aliases), "alias", "aliases"))
+	if session.Accounts[client.AccountID].IsReadOnly {
+		fmt.Fprintln(os.Stderr, "Warning: the new token is read-only")
+	}
+	return session, nil
+}
+
+// tokenUsername returns the user a token belongs to, or "" if the token
+// doesn't work, as an old token may not.
+func tokenUsername(cmd *cobra.Command, token string) string {
+	client, err := newClientWithToken(cmd, token)
+	if err != nil {
+		return ""
+	}
+	session, err := client.GetSession()
+	if err != nil {
+		return ""
+	}
+	return session.Username
+}
+
+// archiveToken records the fingerprint of a retired token.
+func archiveToken(token, source string, now time.Time) error {
+	var retired []retiredToken
+	return updateJSONFile(retiredTokensFile, &retired, func() error {
+		retired = append(retired, retiredToken{
+			Fingerprint: tokenFingerprint(token),
+			Hint:        redactToken(token),
+			Source:      source,
+			RetiredAt:   now.UTC(),
+		})
+		return nil
+	})
+}
+
+// fetchTokenStatus fetches a fresh session and describes the token.
+func fetchTokenStatus(client *FastmailClient, lastUsed *time.Time) (tokenStatus, error) {
+	client.invalidateSession()
+	session, err := client.GetSession()
+	if err != nil {
+		return tokenStatus{}, formatAPIError("failed to get session", err)
+	}
+
+	status := tokenStatus{
+		Source:    credentialSource(),
+		Token:     redactToken(client.Token),
+		Username:  session.Username,
+		AccountID: client.AccountID,
+		Account:   session.Accounts[client.AccountID],
+		LastUsed:  lastUsed,
+	}
+	for capability := range status.Account.AccountCapabilities {
+		status.Capabilities = append(status.Capabilities, capability)
+	}
+	sort.Strings(status.Capabilities)
+	return status, nil
+}
+
+// printTokenStatus prints the token status.
+func printTokenStatus(status tokenStatus) {
+	access := choose(status.Account.IsReadOnly, "read-only", "read-write")
+	lastUsed := ""
+	if status.LastUsed != nil {
+		lastUsed = formatTime(*status.LastUsed)
+	}
+
+	if porcelain != "" {
+		// field, value; one capability row per capability
+		printPorcelain("source", status.Source)
+		printPorcelain("user", status.Username)
+		printPorcelain("account", status.AccountID)
+		printPorcelain("access", access)
+		for _, capability := range status.Capabilities {
+			printPorcelain("capability", capability)
+		}
+		lastUsedRFC3339 := ""
+		if status.LastUsed != nil {
+			lastUsedRFC3339 = status.LastUsed.UTC().Format(time.RFC3339)
+		}
+		printPorcelain("last-used", lastUsedRFC3339)
+		return
+	}
+
+	fmt.Printf("Token:        %s (from %s)\n", status.Token, status.Source)
+	fmt.Printf("User:         %s\n", sanitizeText(status.Username))
+	fmt.Printf("Account:      %s (%s), %s\n", sanitizeText(status.Account.Name), status.AccountID, choose(status.Account.IsPersonal, "personal", "shared"))
+	fmt.Printf("Access:       %s\n", access)
+	fmt.Println("Capabilities:")
+	for _, capability := range status.Capabilities {
+		name := capabilityNames[capability]
+		if name == "" {
+			name = "other"
+		}
+		fmt.Printf("  %-18s %s\n", name, capability)
+	}
+	if !status.Account.SupportsMaskedEmail() {
+		fmt.Println("Warning: the token does not grant masked email; create one with the Masked Email scope")
+	}
+	if lastUsed == "" {
+		lastUsed = "unknown"
+	}
+	fmt.Printf("Last used:    %s (by this tool on this machine)\n", lastUsed)
+}
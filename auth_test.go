@@ -0,0 +1,138 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFetchTokenStatus(t *testing.T) {
+	fake, client := newFakeJMAP(t)
+	lastUsed := time.Now().Add(-3 * time.Hour)
+
+	status, err := fetchTokenStatus(client, &lastUsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Username != "me@example.com" || status.AccountID != "u1" {
+		t.Errorf("unexpected status %+v", status)
+	}
+	if len(status.Capabilities) != 1 || status.Capabilities[0] != maskedEmailNamespace {
+		t.Errorf("capabilities = %v, want masked email", status.Capabilities)
+	}
+	if fake.requests != 0 {
+		t.Errorf("only the session should be fetched, got %d API requests", fake.requests)
+	}
+
+	out := captureStdout(t, func() { printTokenStatus(status) })
+	for _, want := range []string{"Access:       read-write", "masked email", "Last used:    3 hours ago"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output is missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestPrintTokenStatusWithoutMaskedEmail(t *testing.T) {
+	status := tokenStatus{
+		Source:  apiKeyEnv,
+		Account: SessionAccount{Name: "shared@example.com", IsReadOnly: true},
+	}
+	out := captureStdout(t, func() { printTokenStatus(status) })
+	for _, want := range []string{"read-only", "does not grant masked email", "Last used:    unknown"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output is missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestReadNewToken(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stdin")
+	if err := os.WriteFile(path, []byte("  new-token \nignored\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	in, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+
+	token, err := readNewToken(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "new-token" {
+		t.Errorf("got %q, want new-token", token)
+	}
+}
+
+func TestVerifyToken(t *testing.T) {
+	fake, client := newFakeJMAP(t, MaskedEmailInfo{ID: "a1", Email: "a@fastmail.com", State: AliasEnabled})
+	session, err := verifyToken(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.Username != "me@example.com" || fake.requests != 1 {
+		t.Errorf("got user %q after %d requests, want a session and one read", session.Username, fake.requests)
+	}
+}
+
+func TestArchiveToken(t *testing.T) {
+	t.Setenv(dataDirEnv, t.TempDir())
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	if err := archiveToken("fmu1-old-token-1234", apiKeyFileEnv, now); err != nil {
+		t.Fatal(err)
+	}
+
+	var retired []retiredToken
+	if err := readJSONFile(retiredTokensFile, &retired); err != nil {
+		t.Fatal(err)
+	}
+	if len(retired) != 1 || retired[0].Fingerprint != tokenFingerprint("fmu1-old-token-1234") || !retired[0].RetiredAt.Equal(now) {
+		t.Fatalf("unexpected archive %+v", retired)
+	}
+	data, _ := os.ReadFile(filepath.Join(os.Getenv(dataDirEnv), retiredTokensFile))
+	if strings.Contains(string(data), "old-token") {
+		t.Errorf("the archive must not contain the token:\n%s", data)
+	}
+}
+
+func TestAuthRotateRefusedWhenReadOnlyForced(t *testing.T) {
+	t.Setenv(readOnlyEnv, "1")
+	cmd := newAuthCmd()
+	cmd.SetArgs([]string{"rotate"})
+	cmd.SilenceErrors, cmd.SilenceUsage = true, true
+	if err := cmd.Execute(); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("expected auth rotate to be refused, got %v", err)
+	}
+}
+
+func TestConfirmTokenOwnerPiped(t *testing.T) {
+	// auth rotate reads the token from a pipe, so stdin is no terminal
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	writer.Close()
+	stdin := os.Stdin
+	os.Stdin = reader
+	defer func() { os.Stdin, assumeYes = stdin, false }()
+
+	err = confirmTokenOwner("me@example.com", "someone@example.com")
+	if !errors.Is(err, ErrNotConfirmed) || !strings.Contains(err.Error(), "someone@example.com") || !strings.Contains(err.Error(), "--yes") {
+		t.Fatalf("confirmTokenOwner() = %v; want a refusal naming the new user and --yes", err)
+	}
+	if err := confirmTokenOwner("me@example.com", "me@example.com"); err != nil {
+		t.Errorf("a token of the same user: %v", err)
+	}
+	if err := confirmTokenOwner("", "me@example.com"); err != nil {
+		t.Errorf("an old token that no longer works: %v", err)
+	}
+	assumeYes = true
+	if err := confirmTokenOwner("me@example.com", "someone@example.com"); err != nil {
+		t.Errorf("--yes should replace the token: %v", err)
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// SetError describes why the server rejected a single object in a
+// MaskedEmail/set request.
+type SetError struct {
+	Type        string `json:"type"`
+	Description string `json:"description,omitempty"`
+}
+
+func (e SetError) String() string {
+	if e.Description != "" {
+		return fmt.Sprintf("%s (%s)", e.Description, e.Type)
+	}
+	return e.Type
+}
+
+// BatchResult aggregates the outcome of a batch update that may have been
+// split across several requests.
+type BatchResult struct {
+	// Updated lists the IDs the server confirmed as updated
+	Updated []string
+	// Destroyed lists the IDs the server confirmed as destroyed
+	Destroyed []string
+	// Failed maps IDs to the reason the server rejected them
+	Failed map[string]SetError
+	// Requests is the number of HTTP requests used for the batch
+	Requests int
+}
+
+// ErrInterrupted is returned when a batch is stopped before all requests
+// were sent.
+var ErrInterrupted = errors.New("interrupted before all changes were applied")
+
+// UpdateAliases applies the given updates, transparently splitting them into
+// several MaskedEmail/set requests when they exceed the server's
+// maxObjectsInSet limit. Per-item failures are collected in the result rather
+// than aborting the batch; an error is only returned if a request fails as a
+// whole, in which case the result describes the chunks applied so far.
+//
+// Cancelling ctx stops the batch before the next request is sent; a request
+// already in flight is allowed to finish so its outcome is known. The batch
+// then returns ErrInterrupted.
+//
+// If afterChunk is not nil, it is called with the outcome of each request
+// as soon as it is known, so that callers can save their progress before the
+// next one is sent.
+func (fc *FastmailClient) UpdateAliases(ctx context.Context, updates map[string]MaskedEmailUpdate, afterChunk func(chunk *BatchResult)) (*BatchResult, error) {
+	result := &BatchResult{Failed: make(map[string]SetError)}
+	if len(updates) == 0 {
+		return result, nil
+	}
+
+	limits, err := fc.Limits()
+	if err != nil {
+		return result, err
+	}
+
+	chunks := chunkIDs(sortedUpdateIDs(updates), limits.MaxObjectsInSet)
+	logf(levelActions, "updating %s in %s", plural(len(updates), "alias", "aliases"), plural(len(chunks), "request", "requests"))
+	var progress *Progress
+	if len(chunks) > 1 {
+		fmt.Fprintf(os.Stderr, "Note: %d updates exceed the server limit of %d per request; sending %d requests\n",
+			len(updates), limits.MaxObjectsInSet, len(chunks))
+		progress = newProgress("Updating aliases", len(updates))
+		defer progress.Done()
+	}
+
+	for _, ids := range chunks {
+		if ctx.Err() != nil {
+			return result, ErrInterrupted
+		}
+
+		chunk := make(map[string]MaskedEmailUpdate, len(ids))
+		for _, id := range ids {
+			chunk[id] = updates[id]
+		}
+
+		response, err := fc.setMaskedEmail(nil, chunk)
+		if err != nil {
+			return result, err
+		}
+		result.Requests++
+
+		chunkResult := &BatchResult{Failed: make(map[string]SetError), Requests: 1}
+		err = mergeSetResponse(response, ids, chunkResult)
+		result.Updated = append(result.Updated, chunkResult.Updated...)
+		for id, setErr := range chunkResult.Failed {
+			result.Failed[id] = setErr
+		}
+		entries := make([]auditEntry, 0, len(chunkResult.Updated))
+		for _, id := range chunkResult.Updated {
+			entries = append(entries, auditEntry{Action: auditUpdate, ID: id, Changes: updateChanges(updates[id])})
+		}
+		fc.recordAudit(entries...)
+		if afterChunk != nil {
+			afterChunk(chunkResult)
+		}
+		if err != nil {
+			return result, err
+		}
+		progress.Add(len(ids))
+	}
+
+	return result, nil
+}
+
+// DestroyAliases permanently destroys the aliases, in as many
+// MaskedEmail/set requests as the server's maxObjectsInSet limit requires.
+// Per-item failures are collected in the result like in UpdateAliases.
+func (fc *FastmailClient) DestroyAliases(ids []string) (*BatchResult, error) {
+	result := &BatchResult{Failed: make(map[string]SetError)}
+	if len(ids) == 0 {
+		return result, nil
+	}
+	if err := fc.ensureWritable(); err != nil {
+		return result, err
+	}
+	limits, err := fc.Limits()
+	if err != nil {
+		return result, err
+	}
+
+	sorted := append([]string(nil), ids...)
+	sort.Strings(sorted)
+	chunks := chunkIDs(sorted, limits.MaxObjectsInSet)
+	logf(levelActions, "destroying %s in %s", plural(len(ids), "alias", "aliases"), plural(len(chunks), "request", "requests"))
+	for _, chunk := range chunks {
+		response, err := fc.invoke(maskedEmailCapabilities, methodCall{
+			name: methodSet,
+			arguments: struct {
+				Destroy   []string `json:"destroy"`
+				AccountID string   `json:"accountId"`
+			}{Destroy: chunk, AccountID: fc.AccountID},
+		})
+		if err != nil {
+			return result, err
+		}
+		result.Requests++
+
+		if len(response.MethodResponses) == 0 || len(response.MethodResponses[0]) < 2 {
+			return result, fmt.Errorf("failed to validate response structure: missing MaskedEmail/set response")
+		}
+		var setResponse struct {
+			Destroyed    []string            `json:"destroyed"`
+			NotDestroyed map[string]SetError `json:"notDestroyed"`
+		}
+		if err := json.Unmarshal(response.MethodResponses[0][1], &setResponse); err != nil {
+			return result, fmt.Errorf("failed to parse response: %w", err)
+		}
+		destroyed := make(map[string]bool, len(setResponse.Destroyed))
+		for _, id := range setResponse.Destroyed {
+			destroyed[id] = true
+		}
+		var entries []auditEntry
+		for _, id := range chunk {
+			switch setErr, ok := setResponse.NotDestroyed[id]; {
+			case destroyed[id]:
+				result.Destroyed = append(result.Destroyed, id)
+				entries = append(entries, auditEntry{Action: auditDestroy, ID: id})
+			case ok:
+				result.Failed[id] = setErr
+			default:
+				result.Failed[id] = SetError{Type: "unconfirmed", Description: "server did not confirm the destruction"}
+			}
+		}
+		fc.recordAudit(entries...)
+	}
+	return result, nil
+}
+
+// mergeSetResponse records the updated and rejected IDs of a single
+// MaskedEmail/set response in the aggregated result.
+func mergeSetResponse(response *JMAPResponse, ids []string, result *BatchResult) error {
+	if len(response.MethodResponses) == 0 || len(response.MethodResponses[0]) < 2 {
+		return fmt.Errorf("failed to validate response structure: missing MaskedEmail/set response")
+	}
+
+	var setResponse struct {
+		Updated    map[string]json.RawMessage `json:"updated"`
+		NotUpdated map[string]SetError        `json:"notUpdated"`
+	}
+	if err := json.Unmarshal(response.MethodResponses[0][1], &setResponse); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	for _, id := range ids {
+		if _, ok := setResponse.Updated[id]; ok {
+			result.Updated = append(result.Updated, id)
+			continue
+		}
+		if setErr, ok := setResponse.NotUpdated[id]; ok {
+			result.Failed[id] = setErr
+			continue
+		}
+		result.Failed[id] = SetError{Type: "unconfirmed", Description: "server did not confirm the update"}
+	}
+	return nil
+}
+
+// sortedUpdateIDs returns the IDs of an update map in a stable order.
+func sortedUpdateIDs(updates map[string]MaskedEmailUpdate) []string {
+	ids := make([]string, 0, len(updates))
+	for id := range updates {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// chunkIDs splits ids into consecutive chunks of at most size elements.
+func chunkIDs(ids []string, size int) [][]string {
+	if size <= 0 {
+		size = len(ids)
+	}
+
+	var chunks [][]string
+	for start := 0; start < len(ids); start += size {
+		end := start + size
+		if end > len(ids) {
+			end = len(ids)
+		}
+		chunks = append(chunks, ids[start:end])
+	}
+	return chunks
+}
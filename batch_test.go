@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestChunkIDs(t *testing.T) {
+	chunks := chunkIDs([]string{"a", "b", "c", "d", "e"}, 2)
+	if len(chunks) != 3 {
+		t.Fatalf("expected 3 chunks, got %d", len(chunks))
+	}
+	if len(chunks[2]) != 1 || chunks[2][0] != "e" {
+		t.Fatalf("expected last chunk to hold the remainder, got %v", chunks[2])
+	}
+
+	if chunks := chunkIDs([]string{"a"}, 0); len(chunks) != 1 {
+		t.Fatalf("expected a non-positive size to produce a single chunk, got %v", chunks)
+	}
+}
+
+func TestMergeSetResponse(t *testing.T) {
+	response := &JMAPResponse{
+		MethodResponses: [][]json.RawMessage{{
+			json.RawMessage(`"MaskedEmail/set"`),
+			json.RawMessage(`{"updated": {"1": null}, "notUpdated": {"2": {"type": "forbidden", "description": "not allowed"}}}`),
+			json.RawMessage(`null`),
+		}},
+	}
+	result := &BatchResult{Failed: make(map[string]SetError)}
+
+	if err := mergeSetResponse(response, []string{"1", "2", "3"}, result); err != nil {
+		t.Fatalf("mergeSetResponse returned error: %v", err)
+	}
+	if len(result.Updated) != 1 || result.Updated[0] != "1" {
+		t.Fatalf("expected alias 1 to be updated, got %v", result.Updated)
+	}
+	if result.Failed["2"].Type != "forbidden" {
+		t.Fatalf("expected alias 2 to fail with forbidden, got %+v", result.Failed["2"])
+	}
+	if result.Failed["3"].Type != "unconfirmed" {
+		t.Fatalf("expected unconfirmed alias 3 to be reported, got %+v", result.Failed["3"])
+	}
+}
+
+func TestUpdateAliasesReportsEachChunk(t *testing.T) {
+	fake, client := newFakeJMAP(t,
+		MaskedEmailInfo{ID: "1", Email: "a@fastmail.com", State: AliasEnabled},
+		MaskedEmailInfo{ID: "2", Email: "b@fastmail.com", State: AliasEnabled},
+		MaskedEmailInfo{ID: "3", Email: "c@fastmail.com", State: AliasEnabled},
+	)
+	fake.maxSet = 2
+
+	state := AliasDisabled
+	updates := map[string]MaskedEmailUpdate{"1": {State: &state}, "2": {State: &state}, "3": {State: &state}}
+	var chunks [][]string
+	result, err := client.UpdateAliases(context.Background(), updates, func(chunk *BatchResult) {
+		if fake.state(chunk.Updated[0]) != AliasDisabled {
+			t.Errorf("chunk %v reported before it was applied", chunk.Updated)
+		}
+		chunks = append(chunks, chunk.Updated)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) != 2 || len(chunks[0]) != 2 || len(chunks[1]) != 1 || chunks[1][0] != "3" {
+		t.Errorf("reported chunks %v, want [1 2] then [3]", chunks)
+	}
+	if len(result.Updated) != 3 || result.Requests != 2 {
+		t.Errorf("result = %+v, want all 3 updated in 2 requests", result)
+	}
+}
+
+func TestUpdateAliasesInterrupted(t *testing.T) {
+	// A cached session avoids any network access
+	client := &FastmailClient{session: &Session{}}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	state := AliasDisabled
+	result, err := client.UpdateAliases(ctx, map[string]MaskedEmailUpdate{"id1": {State: &state}}, nil)
+	if !errors.Is(err, ErrInterrupted) {
+		t.Fatalf("expected ErrInterrupted, got %v", err)
+	}
+	if result.Requests != 0 || len(result.Updated) != 0 {
+		t.Fatalf("expected no requests to be sent, got %+v", result)
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// defaultServeURL is the HTTP API the bookmarklet calls by default, as
+// started with serve --listen 127.0.0.1:8787 --browser
+const defaultServeURL = "http://127.0.0.1:8787"
+
+// bookmarkletScript gets the alias for the origin of the page from the HTTP
+// API, puts it in the field that had the focus when the bookmarklet was
+// clicked and copies it. Without a field, the alias is shown to be copied.
+// The placeholders are replaced with JSON strings.
+const bookmarkletScript = `(()=>{const f=document.activeElement;` +
+	`fetch(__API__+"/aliases/lookup",{method:"POST",headers:{"Content-Type":"application/json","Authorization":"Bearer "+__TOKEN__},body:JSON.stringify({site:location.origin})})` +
+	`.then(r=>r.json()).then(a=>{if(a.error){alert("masked_fastmail: "+a.error.message);return}` +
+	`if(f&&f!==document.body&&"value"in f){f.focus();f.value=a.email;` +
+	`f.dispatchEvent(new Event("input",{bubbles:true}));f.dispatchEvent(new Event("change",{bubbles:true}))}` +
+	`else{prompt("masked_fastmail",a.email)}` +
+	`if(navigator.clipboard){navigator.clipboard.writeText(a.email).catch(()=>{})}})` +
+	`.catch(e=>alert("masked_fastmail: "+e))})()`
+
+// newBookmarkletCmd creates the command that prints a bookmarklet for the
+// HTTP API of serve mode.
+func newBookmarkletCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "bookmarklet",
+		Short: "Print a bookmarklet that fills in the alias for the current site",
+		Long: `Print a bookmarklet, a bookmark running a script, for the HTTP API of serve
+mode. Clicked on a signup page, it gets the alias for the site from the API,
+creating it if there is none, puts it in the field that has the focus and copies
+it to the clipboard.
+
+The bookmarklet sends the token of a client from the tokens file of serve, so
+serve must run with --tokens-file, and with --browser so that pages can call the
+API. The script runs in the page, which could read the token: give the
+bookmarklet its own client with the lookup scope, which can only get aliases.`,
+		Example: `  echo "browser $(openssl rand -hex 24) lookup" >> ~/.config/masked_fastmail/tokens
+  masked_fastmail serve --listen 127.0.0.1:8787 --browser --tokens-file ~/.config/masked_fastmail/tokens &
+  masked_fastmail bookmarklet --tokens-file ~/.config/masked_fastmail/tokens --client browser`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			apiURL, _ := cmd.Flags().GetString("url")
+			tokensFile, _ := cmd.Flags().GetString("tokens-file")
+			name, _ := cmd.Flags().GetString("client")
+			if tokensFile == "" {
+				return &exitCodeError{code: exitInvalidInput, err: fmt.Errorf("--tokens-file is required: browsers may only call the API with a token")}
+			}
+			clients, err := readServeTokens(tokensFile)
+			if err != nil {
+				return err
+			}
+			client, err := findServeClient(clients, name)
+			if err != nil {
+				return err
+			}
+			if !client.LookupOnly {
+				fmt.Fprintf(os.Stderr, "Warning: %s can do more than get aliases; add the %s scope to its line in %s\n", client.Name, scopeLookup, tokensFile)
+			}
+			script, err := bookmarklet(apiURL, client.Token)
+			if err != nil {
+				return err
+			}
+			fmt.Println(script)
+			return nil
+		},
+	}
+	cmd.Flags().String("url", defaultServeURL, "address of the HTTP API of serve")
+	cmd.Flags().String("tokens-file", "", "tokens file of serve")
+	cmd.Flags().String("client", "", "client of the tokens file whose token the bookmarklet uses (default: the only client)")
+	return cmd
+}
+
+// findServeClient returns the client with the name, or the only client if
+// name is empty.
+func findServeClient(clients []serveClient, name string) (serveClient, error) {
+	if name == "" {
+		if len(clients) == 1 {
+			return clients[0], nil
+		}
+		return serveClient{}, &exitCodeError{code: exitInvalidInput, err: fmt.Errorf("the tokens file has %d clients; select one with --client", len(clients))}
+	}
+	for _, client := range clients {
+		if client.Name == name {
+			return client, nil
+		}
+	}
+	return serveClient{}, &exitCodeError{code: exitInvalidInput, err: fmt.Errorf("no client named %q in the tokens file", name)}
+}
+
+// bookmarklet returns the javascript: URL of the bookmarklet calling the API
+// at apiURL with the token.
+func bookmarklet(apiURL, token string) (string, error) {
+	parsed, err := url.Parse(apiURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return "", &exitCodeError{code: exitInvalidInput, err: fmt.Errorf("invalid API address %q: expected e.g. %s", apiURL, defaultServeURL)}
+	}
+	apiJSON, _ := json.Marshal(strings.TrimSuffix(apiURL, "/"))
+	tokenJSON, _ := json.Marshal(token)
+	script := strings.NewReplacer("__API__", string(apiJSON), "__TOKEN__", string(tokenJSON)).Replace(bookmarkletScript)
+	// Browsers decode javascript: URLs, so characters with a meaning in URLs
+	// are escaped
+	return "javascript:" + strings.NewReplacer("%", "%25", " ", "%20", `"`, "%22", "#", "%23").Replace(script), nil
+}
+
+// allowBrowsers lets scripts on any page call the API, as the bookmarklet
+// does from the page it is clicked on. Preflight requests are answered here,
+// since they carry no token; the requests themselves still need one.
+func allowBrowsers(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := w.Header()
+		header.Set("Access-Control-Allow-Origin", "*")
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			header.Set("Access-Control-Allow-Methods", "GET, POST, PUT")
+			header.Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+			header.Set("Access-Control-Max-Age", "600")
+			// Chrome asks before public pages may call a local address
+			if r.Header.Get("Access-Control-Request-Private-Network") == "true" {
+				header.Set("Access-Control-Allow-Private-Network", "true")
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestBookmarklet(t *testing.T) {
+	got, err := bookmarklet("http://127.0.0.1:8787/", "0123456789abcdef")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(got, "javascript:") || strings.ContainsAny(got, ` "#`) {
+		t.Fatalf("not a usable javascript: URL: %s", got)
+	}
+	script, err := url.PathUnescape(strings.TrimPrefix(got, "javascript:"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`fetch("http://127.0.0.1:8787"+"/aliases/lookup"`, `"Bearer "+"0123456789abcdef"`, "site:location.origin"} {
+		if !strings.Contains(script, want) {
+			t.Errorf("the script doesn't contain %s:\n%s", want, script)
+		}
+	}
+
+	for _, invalid := range []string{"127.0.0.1:8787", "ftp://host", "http://"} {
+		if _, err := bookmarklet(invalid, "0123456789abcdef"); exitCode(err) != exitInvalidInput {
+			t.Errorf("bookmarklet(%q) = %v; want invalid input", invalid, err)
+		}
+	}
+}
+
+func TestFindServeClient(t *testing.T) {
+	one := []serveClient{{Name: "browser", Token: "a"}}
+	two := append(one, serveClient{Name: "phone", Token: "b"})
+	if client, err := findServeClient(one, ""); err != nil || client.Name != "browser" {
+		t.Errorf("findServeClient() = %v, %v; want the only client", client, err)
+	}
+	if client, err := findServeClient(two, "phone"); err != nil || client.Token != "b" {
+		t.Errorf("findServeClient(phone) = %v, %v", client, err)
+	}
+	if _, err := findServeClient(two, ""); err == nil || !strings.Contains(err.Error(), "--client") {
+		t.Errorf("findServeClient() = %v; want an error asking for --client", err)
+	}
+	if _, err := findServeClient(two, "tablet"); err == nil {
+		t.Error("expected an error for an unknown client")
+	}
+}
+
+func TestAllowBrowsers(t *testing.T) {
+	clients := []serveClient{{Name: "browser", Token: "0123456789abcdef", LookupOnly: true}}
+	handler := allowBrowsers(requireToken(newAPIHandler(stubAliases{}), clients, newRateLimiter(60)))
+
+	preflight := httptest.NewRequest("OPTIONS", "/aliases/lookup", nil)
+	preflight.Header.Set("Origin", "https://shop.example")
+	preflight.Header.Set("Access-Control-Request-Method", "POST")
+	preflight.Header.Set("Access-Control-Request-Private-Network", "true")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, preflight)
+	if rec.Code != http.StatusNoContent || rec.Header().Get("Access-Control-Allow-Origin") != "*" ||
+		!strings.Contains(rec.Header().Get("Access-Control-Allow-Headers"), "Authorization") ||
+		rec.Header().Get("Access-Control-Allow-Private-Network") != "true" {
+		t.Errorf("unexpected preflight response %d %v", rec.Code, rec.Header())
+	}
+
+	// The request itself still needs the token
+	req := httptest.NewRequest("POST", "/aliases/lookup", strings.NewReader(`{"site": "https://shop.example"}`))
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized || rec.Header().Get("Access-Control-Allow-Origin") != "*" {
+		t.Errorf("request without a token: %d %v", rec.Code, rec.Header())
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// brands maps brand names to the domains of their sites, e.g. Amazon to
+// amazon.com, amazon.de and audible.com; it is set by the brands setting
+var brands map[string][]string
+
+// validateBrands checks the brands setting: every brand needs a name and at
+// least one domain, and every domain must be one.
+func validateBrands(brands map[string][]string) error {
+	for name, domains := range brands {
+		if strings.TrimSpace(name) == "" {
+			return fmt.Errorf("brands: a brand has no name")
+		}
+		if len(domains) == 0 {
+			return fmt.Errorf("brands: %s has no domains", name)
+		}
+		for _, domain := range domains {
+			if _, err := normalizeOrigin(domain); err != nil || looksLikeEmail(domain) {
+				return fmt.Errorf("brands: %q of %s is not a domain", domain, name)
+			}
+		}
+	}
+	return nil
+}
+
+// brandSites returns the registrable domains of a brand, looked up without
+// regard to case.
+func brandSites(name string) (map[string]bool, error) {
+	for brand, domains := range brands {
+		if !strings.EqualFold(brand, strings.TrimSpace(name)) {
+			continue
+		}
+		sites := make(map[string]bool, len(domains))
+		for _, domain := range domains {
+			sites[registrableDomain(domain)] = true
+		}
+		return sites, nil
+	}
+	if len(brands) == 0 {
+		return nil, fmt.Errorf("no brands are configured; add them to the brands setting of the config file")
+	}
+	return nil, fmt.Errorf("unknown brand %q (configured: %s)", name, strings.Join(brandNames(), ", "))
+}
+
+// brandNames returns the configured brand names, sorted.
+func brandNames() []string {
+	names := make([]string, 0, len(brands))
+	for name := range brands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// brandFilter returns a filter matching the aliases of any site of a brand,
+// including their subdomains.
+func brandFilter(name string) (aliasFilter, error) {
+	sites, err := brandSites(name)
+	if err != nil {
+		return aliasFilter{}, err
+	}
+	return aliasFilter{match: func(alias MaskedEmailInfo) bool {
+		return alias.ForDomain != "" && sites[registrableDomain(alias.ForDomain)]
+	}}, nil
+}
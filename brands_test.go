@@ -0,0 +1,53 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateBrands(t *testing.T) {
+	valid := map[string][]string{"Amazon": {"amazon.com", "https://amazon.de", "audible.com"}}
+	if err := validateBrands(valid); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	invalid := []map[string][]string{
+		{"Amazon": nil},
+		{" ": {"amazon.com"}},
+		{"Amazon": {"me@amazon.com"}},
+		{"Amazon": {""}},
+	}
+	for _, brands := range invalid {
+		if err := validateBrands(brands); err == nil {
+			t.Errorf("expected an error for %v", brands)
+		}
+	}
+}
+
+func TestBrandFilter(t *testing.T) {
+	brands = map[string][]string{"Amazon": {"amazon.com", "amazon.de", "audible.com"}}
+	defer func() { brands = nil }()
+
+	filter, err := brandFilter("amazon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]bool{
+		"https://amazon.com":       true,
+		"https://smile.amazon.com": true,
+		"https://www.amazon.de":    true,
+		"https://audible.com":      true,
+		"https://amazon.co.uk":     false,
+		"https://notamazon.com":    false,
+		"":                         false,
+	}
+	for domain, want := range tests {
+		if got := filter.matches(MaskedEmailInfo{ForDomain: domain}); got != want {
+			t.Errorf("%q: got %v, want %v", domain, got, want)
+		}
+	}
+
+	if _, err := brandFilter("Google"); err == nil || !strings.Contains(err.Error(), "Amazon") {
+		t.Errorf("an unknown brand should list the configured ones, got %v", err)
+	}
+}
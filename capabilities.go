@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// platform is what capability detection looks at, so that other platforms
+// can be detected in tests.
+type platform struct {
+	GOOS     string
+	GOARCH   string
+	getenv   func(string) string
+	lookPath func(string) (string, error)
+}
+
+// currentPlatform returns the platform the tool runs on.
+func currentPlatform() platform {
+	return platform{GOOS: runtime.GOOS, GOARCH: runtime.GOARCH, getenv: os.Getenv, lookPath: exec.LookPath}
+}
+
+// has reports whether the command is installed.
+func (p platform) has(command string) bool {
+	_, err := p.lookPath(command)
+	return err == nil
+}
+
+// termux reports whether the platform is Termux on Android, like isTermux.
+func (p platform) termux() bool {
+	return p.getenv("TERMUX_VERSION") != "" || strings.Contains(p.getenv("PREFIX"), "com.termux")
+}
+
+// capability is a platform-specific feature and whether it can be used.
+type capability struct {
+	Name      string `json:"name"`
+	Available bool   `json:"available"`
+	// Backend is what provides the feature, e.g. wl-copy
+	Backend string `json:"backend,omitempty"`
+	// Detail explains why the feature is missing and how to get it, or how
+	// it is used
+	Detail string `json:"detail,omitempty"`
+}
+
+// detectCapabilities returns the platform-specific features of the platform.
+func detectCapabilities(p platform) []capability {
+	return []capability{detectClipboard(p), detectNotifications(p), detectKeyring(p)}
+}
+
+// detectClipboard finds the clipboard backend copyToClipboard would use.
+// The clipboard package uses pbcopy on macOS, the Windows API on Windows,
+// and xclip, xsel or wl-copy elsewhere.
+func detectClipboard(p platform) capability {
+	c := capability{Name: "clipboard"}
+	switch {
+	case p.termux():
+		if p.has(termuxClipboardSet) {
+			c.Available, c.Backend = true, termuxClipboardSet
+		} else {
+			c.Detail = "install the Termux:API app and `pkg install termux-api`"
+		}
+	case p.GOOS == "windows":
+		c.Available, c.Backend = true, "Windows clipboard"
+	case p.GOOS == "darwin":
+		if p.has("pbcopy") {
+			c.Available, c.Backend = true, "pbcopy"
+		} else {
+			c.Detail = "pbcopy was not found"
+		}
+	case p.GOOS == "plan9" || p.GOOS == "js" || p.GOOS == "wasip1":
+		c.Detail = "not supported on " + p.GOOS
+	case p.getenv("WAYLAND_DISPLAY") != "" && p.has(wlCopy):
+		c.Available, c.Backend = true, wlCopy
+	case p.getenv("WAYLAND_DISPLAY") == "" && p.getenv("DISPLAY") == "":
+		c.Detail = "no graphical session: DISPLAY and WAYLAND_DISPLAY are unset, e.g. over SSH"
+	default:
+		for _, tool := range []string{"xclip", "xsel"} {
+			if p.has(tool) {
+				c.Available, c.Backend = true, tool
+				return c
+			}
+		}
+		c.Detail = "install wl-clipboard for Wayland, or xclip or xsel for X11"
+	}
+	return c
+}
+
+// detectNotifications finds the backend of the notifications showing new
+// aliases, which only Termux has.
+func detectNotifications(p platform) capability {
+	c := capability{Name: "notifications"}
+	switch {
+	case !p.termux():
+		c.Detail = "only shown in Termux on Android"
+	case p.has(termuxNotification):
+		c.Available, c.Backend = true, termuxNotification
+	default:
+		c.Detail = "install the Termux:API app and `pkg install termux-api`"
+	}
+	return c
+}
+
+// detectKeyring finds a keyring the API token can be read from with a
+// command. The tool doesn't store the token itself, but the command can set
+// FASTMAIL_API_KEY.
+func detectKeyring(p platform) capability {
+	c := capability{Name: "keyring"}
+	switch {
+	case p.GOOS == "windows":
+		c.Detail = "no command reads the Windows Credential Manager; use FASTMAIL_API_KEY_FILE"
+	case p.GOOS == "darwin" && p.has("security"):
+		c.Available, c.Backend = true, "macOS Keychain"
+		c.Detail = "FASTMAIL_API_KEY=$(security find-generic-password -s masked_fastmail -w)"
+	case p.GOOS != "darwin" && !p.termux() && p.has("secret-tool"):
+		c.Available, c.Backend = true, "Secret Service (secret-tool)"
+		c.Detail = "FASTMAIL_API_KEY=$(secret-tool lookup service masked_fastmail)"
+	case p.termux():
+		c.Detail = "use FASTMAIL_API_KEY_FILE with a file in the Termux home directory"
+	default:
+		c.Detail = "install secret-tool (libsecret-tools), or use FASTMAIL_API_KEY_FILE"
+	}
+	return c
+}
+
+// newDoctorCmd creates the command that reports the platform-specific
+// features available.
+func newDoctorCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "doctor",
+		Short: "Report the platform-specific features that can be used here",
+		Long: `Report the platform, where the API token is read from, and which platform-specific
+features can be used: copying to the clipboard, notifications and the keyring.
+Missing features are skipped with a note rather than failing commands; doctor
+tells how to get them.`,
+		Example: `  masked_fastmail doctor
+  masked_fastmail doctor --output ndjson | jq -r 'select(.available | not) | .name'`,
+		Args:        cobra.NoArgs,
+		Annotations: map[string]string{outputAnnotation: outputNDJSON},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			token, err := loadToken()
+			source := credentialSource()
+			if err != nil {
+				source += ": " + err.Error()
+			} else if token == "" {
+				source = fmt.Sprintf("not set; set %s or %s", apiKeyEnv, apiKeyFileEnv)
+			}
+			p := currentPlatform()
+			return printCapabilities(p, source, detectCapabilities(p))
+		},
+	}
+}
+
+// printCapabilities prints the platform, the token source and the
+// capabilities in the selected output format.
+func printCapabilities(p platform, tokenSource string, capabilities []capability) error {
+	if outputFormat == outputNDJSON {
+		for _, c := range capabilities {
+			if err := printNDJSON(c); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if porcelain != "" {
+		// name, available, backend, detail
+		for _, c := range capabilities {
+			printPorcelain(c.Name, choose(c.Available, "yes", "no"), c.Backend, c.Detail)
+		}
+		return nil
+	}
+
+	fmt.Printf("masked_fastmail %s on %s/%s\n", version, p.GOOS, p.GOARCH)
+	fmt.Printf("%-14s %s\n", "token", tokenSource)
+	for _, c := range capabilities {
+		status := choose(c.Available, "yes", "no")
+		if c.Backend != "" {
+			status += ", " + c.Backend
+		}
+		fmt.Printf("%-14s %s\n", c.Name, status)
+		if c.Detail != "" {
+			fmt.Printf("%-14s %s\n", "", c.Detail)
+		}
+	}
+	return nil
+}
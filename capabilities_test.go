@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// fakePlatform returns a platform with the environment and the installed
+// commands.
+func fakePlatform(goos string, env map[string]string, commands ...string) platform {
+	return platform{
+		GOOS:   goos,
+		GOARCH: "arm64",
+		getenv: func(key string) string { return env[key] },
+		lookPath: func(name string) (string, error) {
+			for _, command := range commands {
+				if command == name {
+					return "/usr/bin/" + name, nil
+				}
+			}
+			return "", errors.New("not found")
+		},
+	}
+}
+
+func TestDetectClipboard(t *testing.T) {
+	termux := map[string]string{"TERMUX_VERSION": "0.118.0"}
+	tests := map[string]struct {
+		platform platform
+		backend  string
+	}{
+		"termux":             {fakePlatform("android", termux, termuxClipboardSet), termuxClipboardSet},
+		"termux without API": {fakePlatform("android", termux), ""},
+		"windows":            {fakePlatform("windows", nil), "Windows clipboard"},
+		"macos":              {fakePlatform("darwin", nil, "pbcopy"), "pbcopy"},
+		"wayland":            {fakePlatform("linux", map[string]string{"WAYLAND_DISPLAY": "wayland-0"}, wlCopy, "xclip"), wlCopy},
+		"x11":                {fakePlatform("freebsd", map[string]string{"DISPLAY": ":0"}, "xsel"), "xsel"},
+		"x11 without tools":  {fakePlatform("linux", map[string]string{"DISPLAY": ":0"}), ""},
+		"ssh":                {fakePlatform("linux", nil, "xclip"), ""},
+		"wasm":               {fakePlatform("js", nil), ""},
+	}
+	for name, tt := range tests {
+		c := detectClipboard(tt.platform)
+		if c.Available != (tt.backend != "") || c.Backend != tt.backend {
+			t.Errorf("%s: got %+v, want the backend %q", name, c, tt.backend)
+		}
+		if !c.Available && c.Detail == "" {
+			t.Errorf("%s: no explanation of the missing clipboard", name)
+		}
+	}
+}
+
+func TestDetectNotificationsAndKeyring(t *testing.T) {
+	termux := fakePlatform("android", map[string]string{"PREFIX": "/data/data/com.termux/files/usr"}, termuxNotification, "secret-tool")
+	if c := detectNotifications(termux); !c.Available || c.Backend != termuxNotification {
+		t.Errorf("expected notifications in Termux, got %+v", c)
+	}
+	if c := detectKeyring(termux); c.Available {
+		t.Errorf("expected no keyring in Termux, got %+v", c)
+	}
+
+	linux := fakePlatform("linux", map[string]string{"DISPLAY": ":0"}, termuxNotification, "secret-tool")
+	if c := detectNotifications(linux); c.Available {
+		t.Errorf("expected no notifications outside Termux, got %+v", c)
+	}
+	if c := detectKeyring(linux); !c.Available || c.Detail == "" {
+		t.Errorf("expected the Secret Service with how to use it, got %+v", c)
+	}
+	if c := detectKeyring(fakePlatform("darwin", nil, "security")); c.Backend != "macOS Keychain" {
+		t.Errorf("expected the Keychain on macOS, got %+v", c)
+	}
+	if c := detectKeyring(fakePlatform("windows", nil)); c.Available {
+		t.Errorf("expected no usable keyring on Windows, got %+v", c)
+	}
+}
+
+func TestPrintCapabilities(t *testing.T) {
+	p := fakePlatform("linux", nil)
+	out := captureStdout(t, func() {
+		printCapabilities(p, apiKeyEnv, detectCapabilities(p))
+	})
+	for _, want := range []string{"on linux/arm64", "token          FASTMAIL_API_KEY", "clipboard      no\n", "DISPLAY and WAYLAND_DISPLAY are unset"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("the report doesn't contain %q:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"time"
+)
+
+const checkpointDir = "checkpoints"
+
+// bulkCheckpoint records the progress of a bulk state change so that an
+// interrupted run can be summarized and continued later.
+type bulkCheckpoint struct {
+	// State is the state the aliases are being changed to
+	State AliasState `json:"state"`
+	// Force deletes protected aliases too
+	Force bool `json:"force,omitempty"`
+	// Pending lists the aliases that have not been processed yet
+	Pending []string `json:"pending"`
+	// Done lists the aliases that were changed or already had the state
+	Done []string `json:"done,omitempty"`
+	// Failed maps aliases the server rejected to the reason
+	Failed    map[string]string `json:"failed,omitempty"`
+	UpdatedAt time.Time         `json:"updatedAt"`
+
+	// path is the file --resume takes. Checkpoints in the data directory
+	// are documents of the local data, named by name.
+	path string
+	name string
+}
+
+// newBulkCheckpoint starts tracking a bulk state change of the given aliases.
+func newBulkCheckpoint(state AliasState, emails []string) *bulkCheckpoint {
+	return &bulkCheckpoint{
+		State:   state,
+		Pending: emails,
+		Failed:  make(map[string]string),
+	}
+}
+
+// loadBulkCheckpoint reads a checkpoint saved by an interrupted bulk change.
+func loadBulkCheckpoint(path string) (*bulkCheckpoint, error) {
+	checkpoint := &bulkCheckpoint{name: checkpointName(path)}
+	var err error
+	if checkpoint.name != "" {
+		err = readJSONFile(checkpoint.name, checkpoint)
+		if err == nil && checkpoint.State == "" {
+			// A missing document leaves the checkpoint untouched
+			err = &os.PathError{Op: "open", Path: path, Err: os.ErrNotExist}
+		}
+	} else {
+		err = readJSONPath(path, checkpoint)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+	if _, ok := statePriority[checkpoint.State]; !ok {
+		return nil, fmt.Errorf("checkpoint %s has an invalid state %q", path, checkpoint.State)
+	}
+	if checkpoint.Failed == nil {
+		checkpoint.Failed = make(map[string]string)
+	}
+	checkpoint.path = path
+	return checkpoint, nil
+}
+
+// markDone moves an alias from pending to done.
+func (c *bulkCheckpoint) markDone(email string) {
+	c.removePending(email)
+	c.Done = append(c.Done, email)
+}
+
+// markFailed moves an alias from pending to failed.
+func (c *bulkCheckpoint) markFailed(email string, reason string) {
+	c.removePending(email)
+	c.Failed[email] = reason
+}
+
+// retryFailed moves the failed aliases back to pending, so that a resumed
+// run tries them again, and returns how many there were.
+func (c *bulkCheckpoint) retryFailed() int {
+	failed := sortedKeys(c.Failed)
+	c.Pending = append(c.Pending, failed...)
+	c.Failed = make(map[string]string)
+	return len(failed)
+}
+
+func (c *bulkCheckpoint) removePending(email string) {
+	for i, pending := range c.Pending {
+		if pending == email {
+			c.Pending = append(c.Pending[:i], c.Pending[i+1:]...)
+			return
+		}
+	}
+}
+
+// checkpointName returns the name in the local data of the checkpoint at
+// path, or "" if path is not in the checkpoints of the data directory.
+func checkpointName(path string) string {
+	dir, err := dataDir()
+	if err != nil {
+		return ""
+	}
+	dir, err = filepath.Abs(filepath.Join(dir, checkpointDir))
+	if err != nil {
+		return ""
+	}
+	path, err = filepath.Abs(path)
+	if err != nil || filepath.Dir(path) != dir {
+		return ""
+	}
+	return checkpointDir + "/" + filepath.Base(path)
+}
+
+// save writes the checkpoint, choosing a new one in the data directory the
+// first time it is saved; it is kept by the selected storage like the rest of
+// the local data. Resumed checkpoints are updated in place.
+func (c *bulkCheckpoint) save(now time.Time) error {
+	if c.path == "" {
+		dir, err := dataDir()
+		if err != nil {
+			return fmt.Errorf("failed to locate data directory: %w", err)
+		}
+		c.name = checkpointDir + "/" + fmt.Sprintf("bulk-%s.json", now.UTC().Format("20060102-150405"))
+		c.path = filepath.Join(dir, filepath.FromSlash(c.name))
+	}
+	c.UpdatedAt = now.UTC().Truncate(time.Second)
+	if c.name != "" {
+		return writeJSONFile(c.name, c)
+	}
+	return writeJSONPath(c.path, c)
+}
+
+// remove deletes the checkpoint, once the bulk change has completed.
+func (c *bulkCheckpoint) remove() error {
+	if c.name != "" {
+		return deleteJSONFile(c.name)
+	}
+	err := os.Remove(c.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// withInterrupt returns a context that is cancelled on the first Ctrl-C, so
+// that bulk operations can stop issuing requests and report what was done.
+// A second Ctrl-C terminates the process as usual. The returned function
+// must be called to stop watching for the signal.
+func withInterrupt() (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+
+	go func() {
+		select {
+		case <-signals:
+			signal.Stop(signals)
+			fmt.Fprintln(os.Stderr, "\nInterrupted: finishing the request in flight (press Ctrl-C again to quit immediately)")
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx, func() {
+		signal.Stop(signals)
+		cancel()
+	}
+}
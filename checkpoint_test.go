@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBulkCheckpoint(t *testing.T) {
+	t.Setenv(dataDirEnv, t.TempDir())
+
+	checkpoint := newBulkCheckpoint(AliasDisabled, []string{"a@fastmail.com", "b@fastmail.com", "c@fastmail.com"})
+	checkpoint.markDone("b@fastmail.com")
+	checkpoint.markFailed("a@fastmail.com", "notFound")
+
+	if !reflect.DeepEqual(checkpoint.Pending, []string{"c@fastmail.com"}) {
+		t.Fatalf("expected only c to be pending, got %v", checkpoint.Pending)
+	}
+
+	now := time.Date(2024, 6, 1, 12, 30, 0, 0, time.UTC)
+	if err := checkpoint.save(now); err != nil {
+		t.Fatalf("save returned error: %v", err)
+	}
+	if filepath.Base(checkpoint.path) != "bulk-20240601-123000.json" {
+		t.Fatalf("unexpected checkpoint path %q", checkpoint.path)
+	}
+
+	var loaded bulkCheckpoint
+	if err := readJSONPath(checkpoint.path, &loaded); err != nil {
+		t.Fatalf("readJSONPath returned error: %v", err)
+	}
+	if loaded.State != AliasDisabled || !reflect.DeepEqual(loaded.Pending, checkpoint.Pending) ||
+		!reflect.DeepEqual(loaded.Done, checkpoint.Done) || loaded.Failed["a@fastmail.com"] != "notFound" {
+		t.Fatalf("checkpoint did not round-trip, got %+v", loaded)
+	}
+}
+
+func TestLoadBulkCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "bulk.json")
+	if err := writeJSONPath(path, bulkCheckpoint{State: AliasEnabled, Pending: []string{"a@fastmail.com"}}); err != nil {
+		t.Fatalf("writeJSONPath returned error: %v", err)
+	}
+	checkpoint, err := loadBulkCheckpoint(path)
+	if err != nil {
+		t.Fatalf("loadBulkCheckpoint returned error: %v", err)
+	}
+	if checkpoint.path != path || checkpoint.Failed == nil {
+		t.Fatalf("expected the checkpoint to be ready to resume, got %+v", checkpoint)
+	}
+
+	invalid := filepath.Join(dir, "invalid.json")
+	if err := writeJSONPath(invalid, bulkCheckpoint{State: "archived"}); err != nil {
+		t.Fatalf("writeJSONPath returned error: %v", err)
+	}
+	if _, err := loadBulkCheckpoint(invalid); err == nil {
+		t.Fatalf("expected an error for an invalid state")
+	}
+	if _, err := loadBulkCheckpoint(filepath.Join(dir, "missing.json")); err == nil {
+		t.Fatalf("expected an error for a missing checkpoint")
+	}
+}
+
+func TestBulkCheckpointStorage(t *testing.T) {
+	for _, backend := range storageNames() {
+		t.Run(backend, func(t *testing.T) {
+			t.Setenv(dataDirEnv, t.TempDir())
+			previous := storageBackend
+			storageBackend = backend
+			t.Cleanup(func() { storageBackend = previous })
+
+			checkpoint := newBulkCheckpoint(AliasDeleted, []string{"a@fastmail.com"})
+			if err := checkpoint.save(time.Date(2024, 6, 1, 12, 30, 0, 0, time.UTC)); err != nil {
+				t.Fatal(err)
+			}
+			loaded, err := loadBulkCheckpoint(checkpoint.path)
+			if err != nil {
+				t.Fatalf("loadBulkCheckpoint(%q) returned error: %v", checkpoint.path, err)
+			}
+			if loaded.State != AliasDeleted || !reflect.DeepEqual(loaded.Pending, checkpoint.Pending) {
+				t.Fatalf("checkpoint did not round-trip, got %+v", loaded)
+			}
+
+			if err := loaded.remove(); err != nil {
+				t.Fatalf("remove returned error: %v", err)
+			}
+			if _, err := loadBulkCheckpoint(checkpoint.path); !errors.Is(err, os.ErrNotExist) {
+				t.Fatalf("expected the removed checkpoint to be missing, got %v", err)
+			}
+		})
+	}
+}
+
+func TestBulkStateUpdateSavesProgressBeforeEachRequest(t *testing.T) {
+	fake, client := newFakeJMAP(t,
+		MaskedEmailInfo{ID: "1", Email: "a@fastmail.com", State: AliasEnabled},
+		MaskedEmailInfo{ID: "2", Email: "b@fastmail.com", State: AliasEnabled},
+	)
+	fake.maxSet = 1
+	dir := filepath.Join(os.Getenv(dataDirEnv), checkpointDir)
+
+	// What a process killed while sending each request would leave behind
+	var saved []*bulkCheckpoint
+	withTransport(client, func(next http.RoundTripper) http.RoundTripper {
+		return roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			if paths, _ := filepath.Glob(filepath.Join(dir, "*.json")); len(paths) == 1 {
+				checkpoint, err := loadBulkCheckpoint(paths[0])
+				if err != nil {
+					t.Error(err)
+				}
+				saved = append(saved, checkpoint)
+			} else {
+				saved = append(saved, nil)
+			}
+			return next.RoundTrip(r)
+		})
+	})
+
+	checkpoint := newBulkCheckpoint(AliasDisabled, []string{"a@fastmail.com", "b@fastmail.com"})
+	if err := runBulkStateUpdate(context.Background(), client, checkpoint); err != nil {
+		t.Fatal(err)
+	}
+	if len(saved) < 2 {
+		t.Fatalf("got %d requests, want at least two set requests", len(saved))
+	}
+	first, second := saved[len(saved)-2], saved[len(saved)-1]
+	if first == nil || !reflect.DeepEqual(first.Pending, []string{"a@fastmail.com", "b@fastmail.com"}) {
+		t.Errorf("checkpoint before the first set request = %+v; want both aliases pending", first)
+	}
+	if second == nil || !reflect.DeepEqual(second.Pending, []string{"b@fastmail.com"}) || !reflect.DeepEqual(second.Done, []string{"a@fastmail.com"}) {
+		t.Errorf("checkpoint before the second set request = %+v; want the first alias done", second)
+	}
+	if paths, _ := filepath.Glob(filepath.Join(dir, "*.json")); len(paths) != 0 {
+		t.Errorf("checkpoints left after the run completed: %v", paths)
+	}
+}
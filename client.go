@@ -2,7 +2,7 @@ package main
 
 import (
 	"bytes"
-	"encoding/json"
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -12,296 +12,277 @@ import (
 	"time"
 )
 
-// JMAP API endpoints and methods
-const (
-	apiURL               = "https://api.fastmail.com/jmap/api"
-	maskedEmailNamespace = "https://www.fastmail.com/dev/maskedemail"
-	methodGet            = "MaskedEmail/get"
-	methodSet            = "MaskedEmail/set"
-)
+// operation classifies API requests by how long they are expected to take.
+type operation int
 
 const (
-	defaultHTTPTimeout = 30 * time.Second
-	jmapErrorSuffixLen = 6 // length of "/error" suffix
+	// opDefault is an ordinary API call on a few aliases
+	opDefault operation = iota
+	// opSession fetches the session object, which should answer quickly
+	opSession
+	// opFullFetch downloads the account's whole alias inventory
+	opFullFetch
 )
 
-// ErrAliasNotFound is returned when an alias cannot be found
-var ErrAliasNotFound = errors.New("alias not found")
-
-// APIError represents an error from the Fastmail API
-type APIError struct {
-	// StatusCode is the HTTP status code (0 if not applicable)
-	StatusCode int
-	// Type is the JMAP error type (empty for HTTP errors)
-	Type string
-	// Message is the error message
-	Message string
-	// ResponseBody is the raw response body for debugging
-	ResponseBody string
+// operationTimeouts are the default timeouts per operation, used unless
+// overridden with --timeout or the timeout config setting.
+var operationTimeouts = map[operation]time.Duration{
+	opDefault:   30 * time.Second,
+	opSession:   10 * time.Second,
+	opFullFetch: 2 * time.Minute,
 }
 
-func (e *APIError) Error() string {
-	if e.StatusCode > 0 {
-		return fmt.Sprintf("API error (HTTP %d): %s", e.StatusCode, e.Message)
-	}
-	if e.Type != "" {
-		return fmt.Sprintf("API error (%s): %s", e.Type, e.Message)
+// requestTimeout is the timeout selected with --timeout or in the config
+// file; zero keeps the per-operation defaults.
+var requestTimeout time.Duration
+
+// readOnlyEnv forces read-only mode for every command when set to a true
+// value, e.g. on a demo machine; --read-only=false doesn't override it
+const readOnlyEnv = "MASKED_FASTMAIL_READONLY"
+
+// readOnlyForced reports whether MASKED_FASTMAIL_READONLY asks for
+// read-only mode: 1, true, yes or on.
+func readOnlyForced() bool {
+	switch strings.ToLower(strings.TrimSpace(os.Getenv(readOnlyEnv))) {
+	case "1", "t", "true", "yes", "on":
+		return true
 	}
-	return fmt.Sprintf("API error: %s", e.Message)
+	return false
 }
 
+// ErrReadOnly is returned when a modification is attempted in read-only mode
+// or with a token that lacks write access
+var ErrReadOnly = errors.New("read-only access")
+
+// ErrMissingToken is returned when no API token is configured
+var ErrMissingToken = errors.New("FASTMAIL_API_KEY or FASTMAIL_API_KEY_FILE must be set")
+
 type FastmailClient struct {
 	AccountID string
 	Token     string
 	Debug     bool
-	client    *http.Client
+	ReadOnly  bool
+	// Timeout overrides the per-operation request timeouts when non-zero
+	Timeout time.Duration
+	// MaxResponseSize is the largest response body in bytes; zero uses the default
+	MaxResponseSize int64
+	// CompressRequests gzips large request bodies
+	CompressRequests bool
+	// Timing prints the duration and size of each request to stderr
+	Timing  bool
+	client  *http.Client
+	session *Session
+	// serverErrors counts consecutive 5xx responses; after repeated ones the
+	// status page is checked once and any incident is kept in incident
+	serverErrors  int
+	statusChecked bool
+	incident      string
+	// stats records the calls made, for the summary logged at levelAPI
+	stats *callStats
+	// CacheSession saves the session object in the data directory between runs
+	CacheSession bool
+	// aliasEmails are the emails of the aliases fetched, by ID, for the
+	// audit log of changes made by ID
+	aliasEmails map[string]string
 }
 
-// getMaskedEmail performs a MaskedEmail/get request with the given properties
-// Note: The API does not support server-side filtering, so we filter the results client-side.
-func (fc *FastmailClient) getMaskedEmail(properties []string) ([]MaskedEmailInfo, error) {
-	payload, err := fc.buildRequest(methodCall{
-		name: methodGet,
-		arguments: struct {
-			AccountID  string   `json:"accountId"`
-			Properties []string `json:"properties"`
-		}{
-			AccountID:  fc.AccountID,
-			Properties: properties,
-		},
-		clientID: nil,
-	})
-	if err != nil {
-		return nil, err
-	}
-
-	response, err := fc.sendRequest(payload)
-	if err != nil {
-		return nil, err
-	}
-
-	// Validate response structure before accessing
-	if err := fc.validateMethodResponse(response, 0, 2); err != nil {
-		return nil, err
-	}
-
-	var responseData struct {
-		List []MaskedEmailInfo `json:"list"`
-	}
-	if err := json.Unmarshal(response.MethodResponses[0][1], &responseData); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response data: %w", err)
-	}
-
-	return responseData.List, nil
+// ClientOptions configures a FastmailClient.
+type ClientOptions struct {
+	// Debug logs raw API requests and responses, see levelPayloads
+	Debug bool
+	// ReadOnly refuses all requests that would modify the account
+	ReadOnly bool
+	// Timeout overrides the per-operation request timeouts when non-zero
+	Timeout time.Duration
+	// MaxResponseSize is the largest response body in bytes; zero uses the default
+	MaxResponseSize int64
+	// CompressRequests gzips large request bodies
+	CompressRequests bool
+	// Timing prints the duration and size of each request to stderr
+	Timing bool
+	// TLSPins are the public key pins accepted for the Fastmail API; empty
+	// disables pinning
+	TLSPins []string
+	// CacheSession saves the session object in the data directory between runs
+	CacheSession bool
+	// Token is used instead of the configured token when set
+	Token string
+	// WrapTransport wraps the HTTP transport, e.g. to record the requests
+	WrapTransport func(http.RoundTripper) http.RoundTripper
 }
 
-// setMaskedEmail performs a MaskedEmail/set request with the given updates or creates
-func (fc *FastmailClient) setMaskedEmail(create map[string]MaskedEmailCreate, update map[string]MaskedEmailUpdate) (*MaskedEmailResponse, error) {
-	args := struct {
-		Create    map[string]MaskedEmailCreate `json:"create,omitempty"`
-		Update    map[string]MaskedEmailUpdate `json:"update,omitempty"`
-		AccountID string                       `json:"accountId"`
-	}{
-		AccountID: fc.AccountID,
-		Create:    create,
-		Update:    update,
+// NewFastmailClient creates a new client for interacting with the Fastmail API.
+// The API token is read with loadToken unless opts.Token is set. The account
+// is taken from FASTMAIL_ACCOUNT_ID; if that is not set, call SelectAccount or
+// SelectPrimaryAccount before making requests.
+func NewFastmailClient(opts ClientOptions) (*FastmailClient, error) {
+	accountID := os.Getenv("FASTMAIL_ACCOUNT_ID")
+	token := opts.Token
+	if token == "" {
+		var err error
+		if token, err = loadToken(); err != nil {
+			return nil, err
+		}
 	}
 
-	payload, err := fc.buildRequest(methodCall{
-		name:      methodSet,
-		arguments: args,
-		clientID:  nil,
-	})
-	if err != nil {
-		return nil, err
+	if token == "" {
+		return nil, ErrMissingToken
 	}
 
-	return fc.sendRequest(payload)
-}
-
-// FetchAllAliases retrieves all masked email aliases with the fields needed by the CLI.
-func (fc *FastmailClient) FetchAllAliases() ([]MaskedEmailInfo, error) {
-	return fc.getMaskedEmail([]string{"email", "forDomain", "state", "description", "id"})
-}
-
-type MaskedEmailRequest struct {
-	Using       []string            `json:"using"`
-	MethodCalls [][]json.RawMessage `json:"methodCalls"`
-}
-
-type MaskedEmailResponse struct {
-	MethodResponses [][]json.RawMessage `json:"methodResponses"`
-	MethodErrors    []interface{}       `json:"methodErrors,omitempty"`
-}
-
-// JMAPError represents a JMAP method error
-type JMAPError struct {
-	Type    string `json:"type"`
-	Message string `json:"message,omitempty"`
-}
-
-// AliasState represents the possible states of a masked email
-type AliasState string
-
-const (
-	AliasPending  AliasState = "pending"
-	AliasEnabled  AliasState = "enabled"
-	AliasDisabled AliasState = "disabled"
-	AliasDeleted  AliasState = "deleted"
-)
-
-type MaskedEmailInfo struct {
-	ID            string     `json:"id"`
-	Email         string     `json:"email"`
-	State         AliasState `json:"state"`
-	ForDomain     string     `json:"forDomain"`
-	Description   string     `json:"description"`
-	CreatedBy     string     `json:"createdBy"`
-	URL           string     `json:"url,omitempty"`
-	CreatedAt     time.Time  `json:"createdAt"`
-	LastMessageAt *time.Time `json:"lastMessageAt,omitempty"`
-}
-
-// MaskedEmailCreate defines the payload for creating a masked email
-type MaskedEmailCreate struct {
-	ForDomain   string `json:"forDomain"`
-	Description string `json:"description"`
-	URL         string `json:"url,omitempty"`
-	EmailPrefix string `json:"emailPrefix,omitempty"`
-}
-
-// MaskedEmailUpdate defines the payload for updating a masked email
-type MaskedEmailUpdate struct {
-	State       *AliasState `json:"state,omitempty"`
-	Description *string     `json:"description,omitempty"`
-}
-
-// methodCall represents a JMAP method call
-type methodCall struct {
-	arguments interface{}
-	clientID  interface{}
-	name      string
-}
-
-func (fc *FastmailClient) buildRequest(calls ...methodCall) (*MaskedEmailRequest, error) {
-	methodCalls := make([][]json.RawMessage, len(calls))
-
-	for i, call := range calls {
-		name, err := json.Marshal(call.name)
-		if err != nil {
-			return nil, fmt.Errorf("failed to marshal method name: %w", err)
-		}
-		args, err := json.Marshal(call.arguments)
-		if err != nil {
-			return nil, fmt.Errorf("failed to marshal method arguments: %w", err)
-		}
-		clientID, err := json.Marshal(call.clientID)
-		if err != nil {
-			return nil, fmt.Errorf("failed to marshal client ID: %w", err)
+	httpClient := &http.Client{}
+	if len(opts.TLSPins) > 0 {
+		httpClient.Transport = pinnedTransport(opts.TLSPins)
+	}
+	if opts.WrapTransport != nil {
+		transport := httpClient.Transport
+		if transport == nil {
+			transport = http.DefaultTransport
 		}
-
-		methodCalls[i] = []json.RawMessage{name, args, clientID}
+		httpClient.Transport = opts.WrapTransport(transport)
 	}
 
-	return &MaskedEmailRequest{
-		Using:       []string{"urn:ietf:params:jmap:core", "https://www.fastmail.com/dev/maskedemail"},
-		MethodCalls: methodCalls,
+	return &FastmailClient{
+		AccountID:        accountID,
+		Token:            token,
+		Debug:            opts.Debug,
+		ReadOnly:         opts.ReadOnly,
+		Timeout:          opts.Timeout,
+		MaxResponseSize:  opts.MaxResponseSize,
+		CompressRequests: opts.CompressRequests,
+		Timing:           opts.Timing,
+		// Requests are bounded by per-operation contexts, see timeoutFor
+		client:       httpClient,
+		CacheSession: opts.CacheSession,
 	}, nil
 }
 
-// NewFastmailClient creates a new client for interacting with the Fastmail API.
-// It requires FASTMAIL_ACCOUNT_ID and FASTMAIL_API_KEY environment variables to be set.
-func NewFastmailClient(debug bool) (*FastmailClient, error) {
-	accountID := os.Getenv("FASTMAIL_ACCOUNT_ID")
-	token := os.Getenv("FASTMAIL_API_KEY")
-
-	if accountID == "" {
-		return nil, errors.New("FASTMAIL_ACCOUNT_ID environment variable must be set")
-	}
-	if token == "" {
-		return nil, errors.New("FASTMAIL_API_KEY environment variable must be set")
+// timeoutFor returns how long a request for the operation may take.
+func (fc *FastmailClient) timeoutFor(op operation) time.Duration {
+	if fc.Timeout > 0 {
+		return fc.Timeout
 	}
-
-	return &FastmailClient{
-		AccountID: accountID,
-		Token:     token,
-		Debug:     debug,
-		client: &http.Client{
-			Timeout: defaultHTTPTimeout,
-		},
-	}, nil
+	return operationTimeouts[op]
 }
 
-func (fc *FastmailClient) sendRequest(payload *MaskedEmailRequest) (*MaskedEmailResponse, error) {
-	jsonPayload, err := json.Marshal(payload)
-	if err != nil {
-		return nil, err
+// maxResponseBytes returns the largest response body the client reads.
+func (fc *FastmailClient) maxResponseBytes() int64 {
+	if fc.MaxResponseSize > 0 {
+		return fc.MaxResponseSize
 	}
+	return defaultMaxResponseMB << 20
+}
 
-	if fc.Debug {
-		fmt.Fprintf(os.Stderr, "DEBUG: Request URL: %s\n", apiURL)
-		fmt.Fprintf(os.Stderr, "DEBUG: Request Headers:\n")
-		fmt.Fprintf(os.Stderr, "  Content-Type: application/json\n")
-		fmt.Fprintf(os.Stderr, "  Authorization: Bearer %s\n", redactToken(fc.Token))
-		fmt.Fprintf(os.Stderr, "DEBUG: Request Body:\n%s\n", string(jsonPayload))
-	}
+// doHTTP authenticates and sends an HTTP request, printing debug output when
+// enabled. The body of successful (2xx) responses is passed to decode as it
+// is received, failing with ErrResponseTooLarge if it exceeds the maximum
+// response size. The request, including decoding the response, must finish
+// within the timeout of the operation.
+func (fc *FastmailClient) doHTTP(req *http.Request, requestBody []byte, op operation, decode func(io.Reader) error) error {
+	timeout := fc.timeoutFor(op)
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+	defer cancel()
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", fc.Token))
+	// Setting Accept-Encoding disables Go's transparent decompression, so
+	// that the compressed size can be reported by --timing
+	req.Header.Set("Accept-Encoding", acceptEncoding)
 
-	req, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(jsonPayload))
-	if err != nil {
-		return nil, err
+	if fc.Debug {
+		fc.debugf("Request URL: %s", req.URL)
+		fc.debugf("Request Headers:")
+		if contentType := req.Header.Get("Content-Type"); contentType != "" {
+			fc.debugf("  Content-Type: %s", contentType)
+		}
+		fc.debugf("  Authorization: Bearer %s", redactToken(fc.Token))
+		if len(requestBody) > 0 {
+			fc.debugf("Request Body:\n%s", string(requestBody))
+		}
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", fc.Token))
-
+	start := time.Now()
+	defer func() { fc.stats.recordCall(time.Since(start)) }()
 	resp, err := fc.client.Do(req)
 	if err != nil {
-		return nil, err
+		return timeoutError(err, timeout)
 	}
 	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
+	limit := fc.maxResponseBytes()
+	if resp.ContentLength > limit {
+		return responseSizeError(limit)
 	}
-
+	wire := &countingReader{r: resp.Body}
+	decompressed, err := decompressBody(wire, resp.Header.Get("Content-Encoding"))
+	if err != nil {
+		return fmt.Errorf("failed to decompress response: %w", timeoutError(err, timeout))
+	}
+	// The limit applies to the decompressed size, which also guards
+	// against responses that expand enormously
+	decoded := &countingReader{r: decompressed}
+	var body io.Reader = newSizeLimitedReader(decoded, limit)
+
+	if fc.Timing {
+		defer func() {
+			fmt.Fprintf(os.Stderr, "timing: %s\n", requestTiming{
+				Label:    requestLabel(req, requestBody),
+				Duration: time.Since(start),
+				Sent:     max(req.ContentLength, 0),
+				Received: wire.n,
+				Decoded:  decoded.n,
+			})
+		}()
+	}
+
+	logf(levelAPI, "%s: %s in %s", requestLabel(req, requestBody), resp.Status, time.Since(start).Round(time.Millisecond))
 	if fc.Debug {
-		fmt.Fprintf(os.Stderr, "DEBUG: Response Status: %s (%d)\n", resp.Status, resp.StatusCode)
-		fmt.Fprintf(os.Stderr, "DEBUG: Response Headers:\n")
+		fc.debugf("Response Status: %s (%d)", resp.Status, resp.StatusCode)
+		fc.debugf("Response Headers:")
 		for key, values := range resp.Header {
 			for _, value := range values {
-				fmt.Fprintf(os.Stderr, "  %s: %s\n", key, value)
+				fc.debugf("  %s: %s", key, value)
 			}
 		}
-		fmt.Fprintf(os.Stderr, "DEBUG: Response Body:\n%s\n", string(body))
+		// Debug output needs the whole body, so it is not streamed
+		data, err := io.ReadAll(body)
+		if err != nil {
+			return timeoutError(err, timeout)
+		}
+		fc.debugf("Response Body:\n%s", string(data))
+		body = bytes.NewReader(data)
 	}
 
 	// Check HTTP status code before attempting to unmarshal JSON
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, &APIError{
+		data, err := io.ReadAll(body)
+		if err != nil {
+			return timeoutError(err, timeout)
+		}
+		apiErr := &APIError{
 			StatusCode:   resp.StatusCode,
-			Message:      fmt.Sprintf("%s\nResponse body: %s", resp.Status, string(body)),
-			ResponseBody: string(body),
+			Message:      fmt.Sprintf("%s\nResponse body: %s", resp.Status, string(data)),
+			ResponseBody: string(data),
 		}
+		switch {
+		case resp.StatusCode >= 500:
+			fc.recordServerError(apiErr)
+		case resp.StatusCode == http.StatusUnauthorized:
+			// The cached session belongs to a token that no longer works
+			fc.invalidateSession()
+		}
+		return apiErr
 	}
+	fc.serverErrors = 0
 
-	// Check for empty response body
-	if len(body) == 0 {
-		return nil, fmt.Errorf("failed to receive response: empty response body")
-	}
-
-	var result MaskedEmailResponse
-	err = json.Unmarshal(body, &result)
-	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal JSON response: %w\nResponse body: %s", err, string(body))
-	}
+	return timeoutError(decode(body), timeout)
+}
 
-	// Validate JMAP error responses
-	if err := fc.validateJMAPResponse(&result); err != nil {
-		return nil, err
+// timeoutError explains a request that ran out of time and how to allow
+// more. Other errors are returned unchanged.
+func timeoutError(err error, timeout time.Duration) error {
+	if err == nil || !errors.Is(err, context.DeadlineExceeded) {
+		return err
 	}
-
-	return &result, nil
+	return fmt.Errorf("request timed out after %s (use --timeout to allow longer): %w", timeout, err)
 }
 
 // redactToken returns a redacted version of the token showing only the last 4 characters.
@@ -314,248 +295,23 @@ func redactToken(token string) string {
 	return "[redacted token]..." + token[len(token)-4:]
 }
 
-// validateJMAPResponse checks for JMAP errors in the response
-func (fc *FastmailClient) validateJMAPResponse(response *MaskedEmailResponse) error {
-	// Check for top-level methodErrors
-	if len(response.MethodErrors) > 0 {
-		return &APIError{
-			Type:    "methodError",
-			Message: fmt.Sprintf("JMAP method errors in response: %v", response.MethodErrors),
+// ensureWritable returns ErrReadOnly if the client may not modify the
+// account, either because read-only mode was requested or because the
+// session reports the token only has read access.
+func (fc *FastmailClient) ensureWritable() error {
+	if fc.ReadOnly {
+		if readOnlyForced() {
+			return fmt.Errorf("%w: refusing to modify aliases, since %s is set", ErrReadOnly, readOnlyEnv)
 		}
+		return fmt.Errorf("%w: refusing to modify aliases in read-only mode", ErrReadOnly)
 	}
 
-	// Check if MethodResponses is empty
-	if len(response.MethodResponses) == 0 {
-		return fmt.Errorf("failed to process JMAP response: empty MethodResponses array")
-	}
-
-	// Check each method response for errors
-	for i, methodResponse := range response.MethodResponses {
-		if len(methodResponse) == 0 {
-			return fmt.Errorf("failed to process JMAP response: empty method response at index %d", i)
-		}
-
-		// Check if method name indicates an error (e.g., "MaskedEmail/get/error")
-		var methodName string
-		if err := json.Unmarshal(methodResponse[0], &methodName); err != nil {
-			return fmt.Errorf("failed to unmarshal method name at index %d: %w", i, err)
-		}
-
-		// JMAP error responses have method names ending with "/error"
-		if len(methodName) > jmapErrorSuffixLen && methodName[len(methodName)-jmapErrorSuffixLen:] == "/error" {
-			// Try to extract error details
-			if len(methodResponse) > 1 {
-				var jmapError JMAPError
-				if err := json.Unmarshal(methodResponse[1], &jmapError); err == nil {
-					return &APIError{
-						Type:    jmapError.Type,
-						Message: jmapError.Message,
-					}
-				}
-				// If we can't parse the error structure, return the raw JSON
-				return &APIError{
-					Type:         "unknown",
-					Message:      fmt.Sprintf("JMAP error in method '%s': %s", methodName, string(methodResponse[1])),
-					ResponseBody: string(methodResponse[1]),
-				}
-			}
-			return &APIError{
-				Type:    "unknown",
-				Message: fmt.Sprintf("JMAP error in method '%s'", methodName),
-			}
-		}
-
-		// Validate that the response has at least method name and response data
-		if len(methodResponse) < 2 {
-			return fmt.Errorf("failed to validate method response structure at index %d: expected at least 2 elements, got %d", i, len(methodResponse))
-		}
-	}
-
-	return nil
-}
-
-// validateMethodResponse validates that a specific method response in the JMAP response
-// has the expected structure before accessing it. Returns an error if the response
-// structure is invalid.
-func (fc *FastmailClient) validateMethodResponse(response *MaskedEmailResponse, index int, minElements int) error {
-	if len(response.MethodResponses) == 0 {
-		return fmt.Errorf("failed to validate response structure: MethodResponses is empty")
-	}
-	if index >= len(response.MethodResponses) {
-		return fmt.Errorf("failed to validate response structure: method response index %d out of range (have %d responses)", index, len(response.MethodResponses))
-	}
-	if len(response.MethodResponses[index]) < minElements {
-		return fmt.Errorf("failed to validate response structure: method response at index %d has %d elements, expected at least %d", index, len(response.MethodResponses[index]), minElements)
-	}
-	return nil
-}
-
-func (fc *FastmailClient) GetAliases(domain string) ([]MaskedEmailInfo, error) {
-	targetDomain, err := normalizeOrigin(domain)
-	if err != nil {
-		return nil, err
-	}
-
-	maskedEmails, err := fc.FetchAllAliases()
+	session, err := fc.GetSession()
 	if err != nil {
-		return nil, err
-	}
-
-	var filteredAliases []MaskedEmailInfo
-	for _, alias := range maskedEmails {
-		if alias.State == AliasDeleted {
-			continue
-		}
-
-		if aliasMatchesDomain(alias, targetDomain) {
-			filteredAliases = append(filteredAliases, alias)
-		}
-	}
-
-	return filteredAliases, nil
-}
-
-// parseCreatedAlias extracts the created alias from a JMAP response
-func (fc *FastmailClient) parseCreatedAlias(response *MaskedEmailResponse) (*MaskedEmailInfo, error) {
-	// Validate response structure before accessing
-	if err := fc.validateMethodResponse(response, 0, 2); err != nil {
-		return nil, err
-	}
-
-	var createdAlias struct {
-		Created struct {
-			MaskedEmail MaskedEmailInfo `json:"MaskedEmail"`
-		} `json:"created"`
-	}
-
-	err := json.Unmarshal(response.MethodResponses[0][1], &createdAlias)
-	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal created alias: %w", err)
-	}
-
-	return &createdAlias.Created.MaskedEmail, nil
-}
-
-// parseUpdatedAlias verifies that an alias update was successful
-func (fc *FastmailClient) parseUpdatedAlias(response *MaskedEmailResponse, aliasID string) error {
-	// Validate response structure before accessing
-	if err := fc.validateMethodResponse(response, 0, 2); err != nil {
 		return err
 	}
-
-	// Verify the update was successful
-	var updateResponse struct {
-		Updated map[string]interface{} `json:"updated"`
-	}
-	if err := json.Unmarshal(response.MethodResponses[0][1], &updateResponse); err != nil {
-		return fmt.Errorf("failed to parse response: %w", err)
-	}
-
-	if _, ok := updateResponse.Updated[aliasID]; !ok {
-		return fmt.Errorf("server did not confirm the alias update")
-	}
-
-	return nil
-}
-
-func (fc *FastmailClient) CreateAlias(domain string, description *string) (*MaskedEmailInfo, error) {
-	targetDomain, err := normalizeOrigin(domain)
-	if err != nil {
-		return nil, err
-	}
-
-	descValue := ""
-	if description != nil {
-		descValue = *description
-	}
-
-	create := map[string]MaskedEmailCreate{
-		"MaskedEmail": {
-			ForDomain:   targetDomain,
-			Description: descValue,
-		},
-	}
-
-	response, err := fc.setMaskedEmail(create, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	return fc.parseCreatedAlias(response)
-}
-
-// GetAliasByEmail retrieves a specific alias by its email address.
-// Returns ErrAliasNotFound if the alias doesn't exist.
-func (fc *FastmailClient) GetAliasByEmail(email string) (*MaskedEmailInfo, error) {
-	aliases, err := fc.FetchAllAliases()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get aliases: %w", err)
-	}
-
-	for _, alias := range aliases {
-		if alias.Email == email {
-			return &alias, nil
-		}
-	}
-
-	return nil, fmt.Errorf("%w: %s", ErrAliasNotFound, email)
-}
-
-// UpdateAliasStatus changes the state of an existing alias.
-// Returns an error if the alias is already in the requested state or if the update fails.
-func (fc *FastmailClient) UpdateAliasStatus(alias *MaskedEmailInfo, state AliasState) error {
-	// Print current state for user feedback
-	fmt.Printf("Setting '%s' for '%s' to '%s'\n", alias.Email, alias.ForDomain, state)
-
-	if state == alias.State {
-		return fmt.Errorf("alias '%s' is already '%s'", alias.Email, state)
+	if account, ok := session.Accounts[fc.AccountID]; ok && account.IsReadOnly {
+		return fmt.Errorf("%w: the API token cannot modify account %q", ErrReadOnly, account.Name)
 	}
-
-	desiredState := state
-	update := map[string]MaskedEmailUpdate{
-		alias.ID: {
-			State: &desiredState,
-		},
-	}
-
-	response, err := fc.setMaskedEmail(nil, update)
-	if err != nil {
-		return fmt.Errorf("update request failed: %w", err)
-	}
-
-	if err := fc.parseUpdatedAlias(response, alias.ID); err != nil {
-		return err
-	}
-
-	fmt.Println("Success")
 	return nil
 }
-
-// UpdateAliasDescription changes only the description field for an alias.
-func (fc *FastmailClient) UpdateAliasDescription(alias *MaskedEmailInfo, description string) error {
-	desc := description
-	update := map[string]MaskedEmailUpdate{
-		alias.ID: {
-			Description: &desc,
-		},
-	}
-
-	response, err := fc.setMaskedEmail(nil, update)
-	if err != nil {
-		return fmt.Errorf("failed to update alias description: %w", err)
-	}
-
-	return fc.parseUpdatedAlias(response, alias.ID)
-}
-
-func aliasMatchesDomain(alias MaskedEmailInfo, targetDomain string) bool {
-	if domainsEqual(alias.ForDomain, targetDomain) {
-		return true
-	}
-
-	if strings.TrimSpace(alias.ForDomain) == "" {
-		return domainsEqual(alias.Description, targetDomain)
-	}
-
-	return false
-}
@@ -1,39 +1,91 @@
 package main
 
-import "testing"
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
 
-func TestAliasMatchesDomain(t *testing.T) {
-	target := "https://example.com"
+func TestTimeoutFor(t *testing.T) {
+	client := &FastmailClient{}
+	if client.timeoutFor(opSession) >= client.timeoutFor(opFullFetch) {
+		t.Fatalf("expected session requests to time out sooner than full fetches")
+	}
 
-	if !aliasMatchesDomain(MaskedEmailInfo{
-		ForDomain: target,
-	}, target) {
-		t.Fatalf("expected direct forDomain match")
+	client.Timeout = 90 * time.Second
+	for _, op := range []operation{opDefault, opSession, opFullFetch} {
+		if got := client.timeoutFor(op); got != client.Timeout {
+			t.Fatalf("expected --timeout to override operation %d, got %s", op, got)
+		}
 	}
+}
 
-	if aliasMatchesDomain(MaskedEmailInfo{
-		ForDomain: "https://other.com",
-	}, target) {
-		t.Fatalf("did not expect different domain to match")
+func TestDoHTTPTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client := &FastmailClient{Token: "token", Timeout: 50 * time.Millisecond, client: server.Client()}
+	req, err := http.NewRequest("GET", server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
 	}
 
-	if !aliasMatchesDomain(MaskedEmailInfo{
-		ForDomain:   "",
-		Description: "https://example.com",
-	}, target) {
-		t.Fatalf("expected description fallback to match")
+	err = client.doHTTP(req, nil, opSession, func(io.Reader) error { return nil })
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected a deadline error, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "--timeout") {
+		t.Fatalf("expected the error to mention --timeout, got %q", err)
 	}
+	if !isOutcomeUnknown(err) {
+		t.Fatalf("timeouts should be treated as unknown outcomes")
+	}
+}
+
+func TestDoHTTPResponseTooLarge(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"methodResponses": []}`)
+	}))
+	defer server.Close()
 
-	if aliasMatchesDomain(MaskedEmailInfo{
-		ForDomain:   "",
-		Description: "https://other.com",
-	}, target) {
-		t.Fatalf("description fallback should not match different domains")
+	client := &FastmailClient{Token: "token", MaxResponseSize: 8, client: server.Client()}
+	req, err := http.NewRequest("GET", server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
 	}
 
-	if !aliasMatchesDomain(MaskedEmailInfo{
-		ForDomain: "https://Example.com/signup",
-	}, target) {
-		t.Fatalf("expected ForDomain to match (casing and trailing slash should be ignored)")
+	err = client.doHTTP(req, nil, opDefault, func(body io.Reader) error {
+		_, err := io.ReadAll(body)
+		return err
+	})
+	if !errors.Is(err, ErrResponseTooLarge) {
+		t.Fatalf("expected ErrResponseTooLarge, got %v", err)
+	}
+}
+
+func TestReadOnlyForced(t *testing.T) {
+	for value, want := range map[string]bool{"1": true, "true": true, "YES": true, "on": true, "": false, "0": false, "no": false} {
+		t.Setenv(readOnlyEnv, value)
+		if got := readOnlyForced(); got != want {
+			t.Errorf("%s=%q: readOnlyForced() = %v, want %v", readOnlyEnv, value, got, want)
+		}
+	}
+}
+
+func TestEnsureWritableNamesReadOnlyEnv(t *testing.T) {
+	t.Setenv(readOnlyEnv, "1")
+	client := &FastmailClient{AccountID: "u1", ReadOnly: true}
+	err := client.ensureWritable()
+	if !errors.Is(err, ErrReadOnly) || !strings.Contains(err.Error(), readOnlyEnv) {
+		t.Fatalf("expected a read-only error naming %s, got %v", readOnlyEnv, err)
 	}
 }
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/atotto/clipboard"
+)
+
+const (
+	termuxClipboardSet = "termux-clipboard-set"
+	termuxNotification = "termux-notification"
+	wlCopy             = "wl-copy"
+)
+
+// isTermux reports whether the tool runs inside Termux on Android.
+func isTermux() bool {
+	return os.Getenv("TERMUX_VERSION") != "" || strings.Contains(os.Getenv("PREFIX"), "com.termux")
+}
+
+// clipboardCommand returns the external command used to set the clipboard on
+// platforms where the generic clipboard package is unreliable, or nil to use
+// the clipboard package.
+func clipboardCommand() []string {
+	if isTermux() {
+		if _, err := exec.LookPath(termuxClipboardSet); err == nil {
+			return []string{termuxClipboardSet}
+		}
+	}
+	// The clipboard package only uses wl-copy if wl-paste is installed too,
+	// and otherwise falls back to X11 tools that fail in pure Wayland sessions
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		if _, err := exec.LookPath(wlCopy); err == nil {
+			return []string{wlCopy}
+		}
+	}
+	return nil
+}
+
+// copyToClipboard attempts to copy the given text to the system clipboard
+func copyToClipboard(text string) error {
+	if command := clipboardCommand(); command != nil {
+		cmd := exec.Command(command[0], command[1:]...)
+		cmd.Stdin = strings.NewReader(text)
+		// Output is not captured: wl-copy leaves a process serving the
+		// clipboard in the background, which would keep a pipe open
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("failed to copy to clipboard with %s: %w", command[0], err)
+		}
+		return nil
+	}
+
+	if err := clipboard.WriteAll(text); err != nil {
+		if isTermux() {
+			return fmt.Errorf("failed to copy to clipboard: install the Termux:API app and `pkg install termux-api`")
+		}
+		if os.Getenv("WAYLAND_DISPLAY") != "" {
+			return fmt.Errorf("failed to copy to clipboard: install wl-clipboard for Wayland support: %w", err)
+		}
+		return fmt.Errorf("failed to copy to clipboard: %w", err)
+	}
+	return nil
+}
+
+// notifyAlias shows the alias in an Android notification when running in
+// Termux, so it can be copied from the notification shade while filling in a
+// signup form in another app. Failures are ignored.
+func notifyAlias(alias *MaskedEmailInfo) {
+	if !detectNotifications(currentPlatform()).Available {
+		return
+	}
+
+	_ = exec.Command(termuxNotification,
+		"--id", "masked_fastmail",
+		"--title", "Masked email",
+		"--content", alias.Email,
+		"--button1", "Copy",
+		"--button1-action", fmt.Sprintf("%s %s", termuxClipboardSet, alias.Email),
+	).Run()
+}
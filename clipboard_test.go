@@ -0,0 +1,66 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIsTermux(t *testing.T) {
+	t.Setenv("TERMUX_VERSION", "")
+	t.Setenv("PREFIX", "/usr")
+	if isTermux() {
+		t.Fatalf("did not expect Termux to be detected")
+	}
+
+	t.Setenv("PREFIX", "/data/data/com.termux/files/usr")
+	if !isTermux() {
+		t.Fatalf("expected Termux to be detected from PREFIX")
+	}
+}
+
+// fakeClipboardTool installs a command on PATH that writes its input to a
+// file, and returns the path of that file.
+func fakeClipboardTool(t *testing.T, name string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	output := filepath.Join(dir, "clipboard")
+	script := "#!/bin/sh\ncat > " + output + "\n"
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return output
+}
+
+func TestCopyToClipboardTools(t *testing.T) {
+	tests := []struct {
+		name string
+		tool string
+		env  map[string]string
+	}{
+		{name: "termux", tool: termuxClipboardSet, env: map[string]string{"TERMUX_VERSION": "0.118.0", "WAYLAND_DISPLAY": ""}},
+		{name: "wayland", tool: wlCopy, env: map[string]string{"TERMUX_VERSION": "", "PREFIX": "", "WAYLAND_DISPLAY": "wayland-0"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			output := fakeClipboardTool(t, tt.tool)
+
+			if err := copyToClipboard("user.1234@fastmail.com"); err != nil {
+				t.Fatalf("copyToClipboard returned error: %v", err)
+			}
+			copied, err := os.ReadFile(output)
+			if err != nil {
+				t.Fatalf("expected %s to be used: %v", tt.tool, err)
+			}
+			if string(copied) != "user.1234@fastmail.com" {
+				t.Fatalf("expected the alias to be copied, got %q", copied)
+			}
+		})
+	}
+}
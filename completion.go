@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// completionShells lists the shells completion scripts can be generated for
+var completionShells = []string{"bash", "zsh", "fish", "powershell"}
+
+// newCompletionCmd creates the command that prints or installs shell
+// completion scripts.
+func newCompletionCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "completion",
+		Short: "Print or install shell completion scripts",
+		Example: `  # Install completions for your current shell:
+  masked_fastmail completion install
+
+  # Print the zsh completion script:
+  masked_fastmail completion zsh`,
+	}
+
+	for _, shell := range completionShells {
+		shell := shell
+		cmd.AddCommand(&cobra.Command{
+			Use:   shell,
+			Short: fmt.Sprintf("Print the %s completion script", shell),
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return generateCompletion(cmd.Root(), shell, os.Stdout)
+			},
+		})
+	}
+
+	installCmd := &cobra.Command{
+		Use:   "install",
+		Short: "Install the completion script for your shell and check that it loads",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			shell, _ := cmd.Flags().GetString("shell")
+			return handleCompletionInstall(cmd.Root(), shell)
+		},
+	}
+	installCmd.Flags().String("shell", "", "shell to install for: bash, zsh or fish (detected from $SHELL by default)")
+	cmd.AddCommand(installCmd)
+
+	return cmd
+}
+
+// generateCompletion writes the completion script for the shell.
+func generateCompletion(root *cobra.Command, shell string, w io.Writer) error {
+	switch shell {
+	case "bash":
+		return root.GenBashCompletionV2(w, true)
+	case "zsh":
+		return root.GenZshCompletion(w)
+	case "fish":
+		return root.GenFishCompletion(w, true)
+	case "powershell":
+		return root.GenPowerShellCompletionWithDesc(w)
+	}
+	return fmt.Errorf("unsupported shell %q (expected one of: %s)", shell, strings.Join(completionShells, ", "))
+}
+
+// detectShell returns the name of the user's login shell from $SHELL.
+func detectShell() (string, error) {
+	shell := filepath.Base(os.Getenv("SHELL"))
+	if shell == "." || shell == "/" || shell == "" {
+		return "", errors.New("could not detect your shell from $SHELL; pass --shell")
+	}
+	return shell, nil
+}
+
+// completionPath returns where the completion script for the shell is
+// installed for the current user.
+func completionPath(shell string, name string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	switch shell {
+	case "bash":
+		dataHome := os.Getenv("XDG_DATA_HOME")
+		if dataHome == "" {
+			dataHome = filepath.Join(home, ".local", "share")
+		}
+		return filepath.Join(dataHome, "bash-completion", "completions", name), nil
+	case "zsh":
+		zdotdir := os.Getenv("ZDOTDIR")
+		if zdotdir == "" {
+			zdotdir = home
+		}
+		return filepath.Join(zdotdir, ".zfunc", "_"+name), nil
+	case "fish":
+		configHome := os.Getenv("XDG_CONFIG_HOME")
+		if configHome == "" {
+			configHome = filepath.Join(home, ".config")
+		}
+		return filepath.Join(configHome, "fish", "completions", name+".fish"), nil
+	case "powershell":
+		return "", errors.New("automatic installation is not supported for powershell; add `masked_fastmail completion powershell | Out-String | Invoke-Expression` to your profile")
+	}
+	return "", fmt.Errorf("unsupported shell %q (expected one of: %s)", shell, strings.Join(completionShells, ", "))
+}
+
+// completionCheck returns a command that exits successfully if the installed
+// completion script loads in the shell.
+func completionCheck(shell string, path string, name string) *exec.Cmd {
+	switch shell {
+	case "bash":
+		return exec.Command("bash", "-c", `source "$1" && complete -p "$2" >/dev/null`, "bash", path, name)
+	case "zsh":
+		return exec.Command("zsh", "-c", `autoload -Uz compinit && compinit -u -D && source "$1" && (( $+functions[_$2] ))`, "zsh", path, name)
+	case "fish":
+		return exec.Command("fish", "-c", `source $argv[1]; and complete -c $argv[2] | string length -q`, path, name)
+	}
+	return nil
+}
+
+// handleCompletionInstall writes the completion script for the shell to the
+// user's completion directory and checks that the shell can load it.
+func handleCompletionInstall(root *cobra.Command, shell string) error {
+	if shell == "" {
+		detected, err := detectShell()
+		if err != nil {
+			return err
+		}
+		shell = detected
+	}
+
+	name := root.Name()
+	path, err := completionPath(shell, name)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := generateCompletion(root, shell, &buf); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create completion directory: %w", err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write completion script: %w", err)
+	}
+	fmt.Printf("Installed %s completions to %s\n", shell, path)
+
+	if _, err := exec.LookPath(shell); err != nil {
+		fmt.Printf("Could not find %s to check the completions load.\n", shell)
+	} else if output, err := completionCheck(shell, path, name).CombinedOutput(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: the completion script did not load in %s: %v\n", shell, err)
+		if len(output) > 0 {
+			fmt.Fprintf(os.Stderr, "%s\n", strings.TrimSpace(string(output)))
+		}
+	} else {
+		fmt.Println("Verified that the completions load.")
+	}
+
+	switch shell {
+	case "bash":
+		fmt.Println("Completions are loaded automatically by bash-completion 2 in new shells.")
+	case "zsh":
+		fmt.Printf("Make sure your ~/.zshrc contains, before compinit:\n  fpath=(%s $fpath)\n", filepath.Dir(path))
+	case "fish":
+		fmt.Println("Completions are loaded automatically in new shells.")
+	}
+	return nil
+}
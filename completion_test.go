@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func TestCompletionPath(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_DATA_HOME", "")
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("ZDOTDIR", "")
+
+	tests := []struct {
+		shell    string
+		expected string
+	}{
+		{"bash", filepath.Join(home, ".local", "share", "bash-completion", "completions", "masked_fastmail")},
+		{"zsh", filepath.Join(home, ".zfunc", "_masked_fastmail")},
+		{"fish", filepath.Join(home, ".config", "fish", "completions", "masked_fastmail.fish")},
+	}
+	for _, tt := range tests {
+		got, err := completionPath(tt.shell, "masked_fastmail")
+		if err != nil {
+			t.Fatalf("completionPath(%q) returned error: %v", tt.shell, err)
+		}
+		if got != tt.expected {
+			t.Errorf("completionPath(%q) = %q, want %q", tt.shell, got, tt.expected)
+		}
+	}
+
+	t.Setenv("XDG_CONFIG_HOME", "/xdg/config")
+	if got, _ := completionPath("fish", "masked_fastmail"); got != "/xdg/config/fish/completions/masked_fastmail.fish" {
+		t.Errorf("expected XDG_CONFIG_HOME to be respected, got %q", got)
+	}
+
+	for _, shell := range []string{"powershell", "tcsh"} {
+		if _, err := completionPath(shell, "masked_fastmail"); err == nil {
+			t.Errorf("expected an error installing for %s", shell)
+		}
+	}
+}
+
+func TestDetectShell(t *testing.T) {
+	t.Setenv("SHELL", "/usr/local/bin/fish")
+	if shell, err := detectShell(); err != nil || shell != "fish" {
+		t.Fatalf("detectShell() = %q, %v; want fish", shell, err)
+	}
+
+	t.Setenv("SHELL", "")
+	if _, err := detectShell(); err == nil {
+		t.Fatalf("expected an error when $SHELL is unset")
+	}
+}
+
+func TestGenerateCompletion(t *testing.T) {
+	root := &cobra.Command{Use: "masked_fastmail"}
+	for _, shell := range completionShells {
+		var buf bytes.Buffer
+		if err := generateCompletion(root, shell, &buf); err != nil {
+			t.Fatalf("generateCompletion(%q) returned error: %v", shell, err)
+		}
+		if !strings.Contains(buf.String(), "masked_fastmail") {
+			t.Errorf("expected %s script to reference the command", shell)
+		}
+	}
+
+	if err := generateCompletion(root, "tcsh", &bytes.Buffer{}); err == nil {
+		t.Fatalf("expected an error for an unsupported shell")
+	}
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	configFile    = "config.yaml"
+	configPathEnv = "MASKED_FASTMAIL_CONFIG"
+)
+
+// Config holds the user's preferences from the configuration file.
+type Config struct {
+	// DomainStrategy controls how precisely domains are matched to aliases
+	DomainStrategy domainStrategy `yaml:"domain_strategy"`
+	// IgnoreScheme treats http:// and https:// origins as equivalent
+	IgnoreScheme bool `yaml:"ignore_scheme"`
+	// SelectionStrategy decides which alias is used when several match a
+	// site
+	SelectionStrategy selectionStrategy `yaml:"selection_strategy"`
+	// StripWWW removes a leading www. from hosts, so that www.example.com
+	// and example.com share an alias
+	StripWWW bool `yaml:"strip_www"`
+	// Timeout limits every API request, overriding the per-operation defaults
+	Timeout time.Duration `yaml:"timeout"`
+	// MaxResponseMB is the largest API response in MiB the client reads
+	MaxResponseMB int64 `yaml:"max_response_mb"`
+	// CompressRequests gzips large request bodies, such as bulk updates
+	CompressRequests bool `yaml:"compress_requests"`
+	// StatusURL is the status page checked after repeated server errors;
+	// empty disables the check
+	StatusURL string `yaml:"status_url"`
+	// TLSPins are the public key pins ("sha256/<base64>") accepted for the
+	// Fastmail API; empty disables pinning
+	TLSPins []string `yaml:"tls_pins"`
+	// FuzzySearch also matches searches whose characters appear in order
+	// but not adjacent
+	FuzzySearch bool `yaml:"fuzzy_search"`
+	// Storage is the backend for the local data: json or sqlite
+	Storage string `yaml:"storage"`
+	// AbsoluteTimes shows timestamps as ISO 8601 instead of relative times
+	AbsoluteTimes bool `yaml:"absolute_times"`
+	// Glyphs marks alias states with symbols in listings and digests
+	Glyphs bool `yaml:"glyphs"`
+	// StateGlyphs replaces the symbols of some states when glyphs is on
+	StateGlyphs map[string]string `yaml:"state_glyphs"`
+	// Timezone is the IANA time zone timestamps are shown in and dates in
+	// filters are read in; "local" or empty uses the local time zone
+	Timezone string `yaml:"timezone"`
+	// Descriptions maps domains to the descriptions of their aliases,
+	// applied at creation and by tidy-descriptions
+	Descriptions map[string]string `yaml:"descriptions"`
+	// PurgeAfter is how long deleted aliases stay in the recycle bin before
+	// purge destroys them
+	PurgeAfter time.Duration `yaml:"purge_after"`
+	// MaxAliasesPerDomain caps the aliases of a registrable domain; zero
+	// disables the cap
+	MaxAliasesPerDomain int `yaml:"max_aliases_per_domain"`
+	// MaxAliasesAction is what happens at the cap: warn or refuse
+	MaxAliasesAction string `yaml:"max_aliases_action"`
+	// MaxCreationsPerDay and MaxCreationsPerWeek warn when more aliases are
+	// created with this tool in a day or the last 7 days; zero disables them
+	MaxCreationsPerDay  int `yaml:"max_creations_per_day"`
+	MaxCreationsPerWeek int `yaml:"max_creations_per_week"`
+	// ConfirmPhraseOver is the number of aliases above which bulk changes
+	// must be confirmed by typing a phrase; zero disables the phrase
+	ConfirmPhraseOver int `yaml:"confirm_phrase_over"`
+	// PromptTimeout is how long prompts wait for an answer; zero waits
+	// forever
+	PromptTimeout time.Duration `yaml:"prompt_timeout"`
+	// PromptTimeoutAction is the answer taken when a prompt times out:
+	// default or yes
+	PromptTimeoutAction string `yaml:"prompt_timeout_action"`
+	// Brands maps brand names to the domains of their sites, for --brand
+	Brands map[string][]string `yaml:"brands"`
+}
+
+// defaultConfig returns the configuration used when no file exists.
+func defaultConfig() Config {
+	return Config{
+		DomainStrategy:      strategyOrigin,
+		MaxResponseMB:       defaultMaxResponseMB,
+		StatusURL:           defaultStatusURL,
+		FuzzySearch:         true,
+		Storage:             storageJSON,
+		PurgeAfter:          defaultPurgeAfter,
+		MaxAliasesAction:    guardrailRefuse,
+		ConfirmPhraseOver:   defaultBulkPhraseOver,
+		PromptTimeoutAction: timeoutDefault,
+	}
+}
+
+// configPath returns the location of the configuration file.
+// MASKED_FASTMAIL_CONFIG overrides the platform default.
+func configPath() (string, error) {
+	if path := os.Getenv(configPathEnv); path != "" {
+		return path, nil
+	}
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, appDirName, configFile), nil
+}
+
+// loadConfig reads the configuration file, falling back to the defaults for
+// settings it omits. A missing file is not an error.
+func loadConfig() (Config, error) {
+	config := defaultConfig()
+
+	path, err := configPath()
+	if err != nil {
+		return config, fmt.Errorf("failed to locate config file: %w", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return config, nil
+	}
+	if err != nil {
+		return config, err
+	}
+
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if err := config.validate(); err != nil {
+		return config, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	return config, nil
+}
+
+// validate checks that all settings have supported values.
+func (c *Config) validate() error {
+	if c.DomainStrategy == "" {
+		c.DomainStrategy = strategyOrigin
+	}
+	if !c.DomainStrategy.valid() {
+		return fmt.Errorf("unknown domain_strategy %q (expected one of: %s, %s, %s)",
+			c.DomainStrategy, strategyOrigin, strategyHost, strategyRegistrable)
+	}
+	if c.SelectionStrategy == "" {
+		c.SelectionStrategy = selectByState
+	}
+	if _, err := parseSelectionStrategy(string(c.SelectionStrategy)); err != nil {
+		return fmt.Errorf("selection_strategy: %w", err)
+	}
+	if c.Timeout < 0 {
+		return fmt.Errorf("timeout must not be negative, got %s", c.Timeout)
+	}
+	if c.PurgeAfter < 0 {
+		return fmt.Errorf("purge_after must not be negative, got %s", c.PurgeAfter)
+	}
+	if c.MaxAliasesPerDomain < 0 {
+		return fmt.Errorf("max_aliases_per_domain must not be negative, got %d", c.MaxAliasesPerDomain)
+	}
+	if err := validateGuardrailAction("max_aliases_action", c.MaxAliasesAction); err != nil {
+		return err
+	}
+	if c.MaxCreationsPerDay < 0 || c.MaxCreationsPerWeek < 0 {
+		return fmt.Errorf("max_creations_per_day and max_creations_per_week must not be negative")
+	}
+	if c.ConfirmPhraseOver < 0 {
+		return fmt.Errorf("confirm_phrase_over must not be negative, got %d", c.ConfirmPhraseOver)
+	}
+	if c.PromptTimeout < 0 {
+		return fmt.Errorf("prompt_timeout must not be negative, got %s", c.PromptTimeout)
+	}
+	if err := validateTimeoutAction(c.PromptTimeoutAction); err != nil {
+		return err
+	}
+	if c.MaxResponseMB <= 0 {
+		return fmt.Errorf("max_response_mb must be positive, got %d", c.MaxResponseMB)
+	}
+	if _, err := loadTimezone(c.Timezone); err != nil {
+		return err
+	}
+	if err := validateDescriptions(c.Descriptions); err != nil {
+		return err
+	}
+	if err := validateBrands(c.Brands); err != nil {
+		return err
+	}
+	if err := validateStateGlyphs(c.StateGlyphs); err != nil {
+		return err
+	}
+	if err := validateStorage(c.Storage); err != nil {
+		return err
+	}
+	for _, pin := range c.TLSPins {
+		if err := validatePin(pin); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyConfig loads the configuration file and applies it to the package
+// settings it controls.
+func applyConfig() error {
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	activeDomainStrategy = config.DomainStrategy
+	activeSelection, _ = parseSelectionStrategy(string(config.SelectionStrategy))
+	ignoreScheme = config.IgnoreScheme
+	stripWWWPrefix = config.StripWWW
+	requestTimeout = config.Timeout
+	maxResponseMB = config.MaxResponseMB
+	compressRequests = config.CompressRequests
+	statusURL = config.StatusURL
+	tlsPins = config.TLSPins
+	fuzzySearch = config.FuzzySearch
+	storageBackend = config.Storage
+	purgeAfter = config.PurgeAfter
+	absoluteTimes = config.AbsoluteTimes
+	stateGlyphs = themeGlyphs(config.Glyphs, config.StateGlyphs)
+	displayLocation, _ = loadTimezone(config.Timezone)
+	domainDescriptions = config.Descriptions
+	maxAliasesPerDomain = config.MaxAliasesPerDomain
+	domainCapAction = config.MaxAliasesAction
+	maxCreationsPerDay = config.MaxCreationsPerDay
+	maxCreationsPerWeek = config.MaxCreationsPerWeek
+	brands = config.Brands
+	bulkPhraseOver = config.ConfirmPhraseOver
+	promptTimeout = config.PromptTimeout
+	promptTimeoutAction = config.PromptTimeoutAction
+	return nil
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	t.Setenv(configPathEnv, path)
+
+	config, err := loadConfig()
+	if err != nil {
+		t.Fatalf("loadConfig returned error for a missing file: %v", err)
+	}
+	if config.DomainStrategy != strategyOrigin {
+		t.Fatalf("expected the origin strategy by default, got %q", config.DomainStrategy)
+	}
+
+	if err := os.WriteFile(path, []byte("domain_strategy: registrable\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	config, err = loadConfig()
+	if err != nil {
+		t.Fatalf("loadConfig returned error: %v", err)
+	}
+	if config.DomainStrategy != strategyRegistrable {
+		t.Fatalf("expected the registrable strategy, got %q", config.DomainStrategy)
+	}
+
+	if err := os.WriteFile(path, []byte("timeout: 90s\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	config, err = loadConfig()
+	if err != nil {
+		t.Fatalf("loadConfig returned error: %v", err)
+	}
+	if config.Timeout != 90*time.Second {
+		t.Fatalf("expected a 90s timeout, got %s", config.Timeout)
+	}
+	if config.MaxResponseMB != defaultMaxResponseMB {
+		t.Fatalf("expected the default response size limit, got %d", config.MaxResponseMB)
+	}
+
+	if err := os.WriteFile(path, []byte("domain_strategy: path\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadConfig(); err == nil {
+		t.Fatalf("expected an error for an unknown strategy")
+	}
+}
+
+func TestLoadConfigStatusURL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	t.Setenv(configPathEnv, path)
+
+	config, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.StatusURL != defaultStatusURL {
+		t.Fatalf("expected the Fastmail status page by default, got %q", config.StatusURL)
+	}
+
+	if err := os.WriteFile(path, []byte("status_url: \"\"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	config, err = loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.StatusURL != "" {
+		t.Fatalf("expected an empty status_url to disable the check, got %q", config.StatusURL)
+	}
+}
+
+func TestLoadConfigFuzzySearch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	t.Setenv(configPathEnv, path)
+
+	config, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !config.FuzzySearch {
+		t.Fatalf("expected fuzzy search by default")
+	}
+
+	if err := os.WriteFile(path, []byte("fuzzy_search: false\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	config, err = loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.FuzzySearch {
+		t.Fatalf("expected fuzzy_search: false to turn fuzzy search off")
+	}
+}
+
+func TestLoadConfigTimezone(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	t.Setenv(configPathEnv, path)
+
+	if err := os.WriteFile(path, []byte("timezone: Europe/Oslo\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	config, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Timezone != "Europe/Oslo" {
+		t.Fatalf("expected the Europe/Oslo time zone, got %q", config.Timezone)
+	}
+
+	if err := os.WriteFile(path, []byte("timezone: Mars/Olympus\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadConfig(); err == nil {
+		t.Fatalf("expected an error for an unknown time zone")
+	}
+}
+
+func TestLoadConfigDescriptions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	t.Setenv(configPathEnv, path)
+
+	if err := os.WriteFile(path, []byte("descriptions:\n  github.com: GitHub (work org)\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	config, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Descriptions["github.com"] != "GitHub (work org)" {
+		t.Fatalf("expected the GitHub description, got %v", config.Descriptions)
+	}
+
+	if err := os.WriteFile(path, []byte("descriptions:\n  github.com: \"\"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadConfig(); err == nil {
+		t.Fatalf("expected an error for an empty description")
+	}
+}
+
+func TestLoadConfigMaxAliasesPerDomain(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	t.Setenv(configPathEnv, path)
+
+	if err := os.WriteFile(path, []byte("max_aliases_per_domain: 3\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	config, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.MaxAliasesPerDomain != 3 || config.MaxAliasesAction != guardrailRefuse {
+		t.Fatalf("expected a cap of 3 that refuses, got %d and %q", config.MaxAliasesPerDomain, config.MaxAliasesAction)
+	}
+
+	for _, invalid := range []string{"max_aliases_per_domain: -1\n", "max_aliases_action: block\n"} {
+		if err := os.WriteFile(path, []byte(invalid), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := loadConfig(); err == nil {
+			t.Errorf("expected an error for %q", invalid)
+		}
+	}
+}
+
+func TestLoadConfigPromptTimeout(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	t.Setenv(configPathEnv, path)
+
+	if err := os.WriteFile(path, []byte("prompt_timeout: 30s\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	config, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.PromptTimeout != 30*time.Second || config.PromptTimeoutAction != timeoutDefault {
+		t.Fatalf("expected a 30s timeout taking the default answer, got %s and %q", config.PromptTimeout, config.PromptTimeoutAction)
+	}
+
+	for _, invalid := range []string{"prompt_timeout: -1s\n", "prompt_timeout_action: cancel\n"} {
+		if err := os.WriteFile(path, []byte(invalid), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := loadConfig(); err == nil {
+			t.Errorf("expected an error for %q", invalid)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+// Modes of --count
+const (
+	// countTotal prints the number of matching aliases
+	countTotal = "total"
+	// countByState prints the number of matching aliases in each state
+	countByState = "state"
+)
+
+// countedStates are the states counted by --count=state, in display order
+var countedStates = []AliasState{AliasEnabled, AliasPending, AliasDisabled, AliasDeleted}
+
+// addCountFlag adds the --count flag to a command.
+func addCountFlag(cmd *cobra.Command) {
+	cmd.Flags().String("count", "", "only print the number of matching aliases; --count=state prints it for each state")
+	cmd.Flags().Lookup("count").NoOptDefVal = countTotal
+}
+
+// countModeFromCmd returns the --count mode of a command, or "" without
+// --count.
+func countModeFromCmd(cmd *cobra.Command) (string, error) {
+	mode, _ := cmd.Flags().GetString("count")
+	switch mode {
+	case "", countTotal, countByState:
+	default:
+		return "", fmt.Errorf("unknown --count %q (expected %s or %s)", mode, countTotal, countByState)
+	}
+	if mode != "" && outputFormat == outputNDJSON {
+		return "", fmt.Errorf("--count cannot be combined with --output %s", outputFormat)
+	}
+	return mode, nil
+}
+
+// printCount prints the number of aliases with the states, in total or for
+// each state. The total is a bare number, for shell conditionals such as
+// [ "$(masked_fastmail --list example.com --count)" -gt 0 ].
+func printCount(states []AliasState, mode string) {
+	if mode != countByState {
+		fmt.Println(len(states))
+		return
+	}
+	counts := make(map[AliasState]int)
+	for _, state := range states {
+		counts[state]++
+	}
+	// state, count
+	for _, state := range countedStates {
+		if porcelain != "" {
+			printPorcelain(string(state), strconv.Itoa(counts[state]))
+		} else {
+			fmt.Printf("%-9s %d\n", state+":", counts[state])
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import "testing"
+
+func TestPrintCount(t *testing.T) {
+	states := []AliasState{AliasEnabled, AliasEnabled, AliasDisabled}
+
+	if out := captureStdout(t, func() { printCount(states, countTotal) }); out != "3\n" {
+		t.Errorf("expected the bare total, got %q", out)
+	}
+
+	want := "enabled:  2\npending:  0\ndisabled: 1\ndeleted:  0\n"
+	if out := captureStdout(t, func() { printCount(states, countByState) }); out != want {
+		t.Errorf("unexpected breakdown by state:\n%s", out)
+	}
+
+	porcelain = porcelainV1
+	defer func() { porcelain = "" }()
+	want = "enabled\t2\npending\t0\ndisabled\t1\ndeleted\t0\n"
+	if out := captureStdout(t, func() { printCount(states, countByState) }); out != want {
+		t.Errorf("unexpected porcelain breakdown:\n%s", out)
+	}
+}
+
+func TestCountModeFromCmd(t *testing.T) {
+	cmd := newSearchCmd()
+	cmd.Flags().Set("count", "domain")
+	if _, err := countModeFromCmd(cmd); err == nil {
+		t.Fatalf("expected an error for an unknown --count mode")
+	}
+
+	cmd = newSearchCmd()
+	cmd.Flags().Parse([]string{"--count"})
+	if mode, err := countModeFromCmd(cmd); err != nil || mode != countTotal {
+		t.Fatalf("expected --count to count the total, got %q, %v", mode, err)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// Where the API token is read from, in order of precedence
+const (
+	apiKeyEnv     = "FASTMAIL_API_KEY"
+	apiKeyFileEnv = "FASTMAIL_API_KEY_FILE"
+	// credentialsDirEnv is set by systemd for services with LoadCredential=
+	// or SetCredential=; the token is read from the credential named
+	// systemdCredentialName in that directory
+	credentialsDirEnv     = "CREDENTIALS_DIRECTORY"
+	systemdCredentialName = "fastmail_api_key"
+)
+
+// tokenSource describes where the token of the last loadToken call came
+// from, for error messages
+var tokenSource = apiKeyEnv
+
+// loadToken returns the API token from FASTMAIL_API_KEY, the file named by
+// FASTMAIL_API_KEY_FILE, or the systemd credential fastmail_api_key, so that
+// services can keep the token out of their environment. An empty token means
+// none is configured.
+func loadToken() (string, error) {
+	if token := os.Getenv(apiKeyEnv); token != "" {
+		tokenSource = apiKeyEnv
+		return token, nil
+	}
+	if path := os.Getenv(apiKeyFileEnv); path != "" {
+		tokenSource = fmt.Sprintf("the file %s named by %s", path, apiKeyFileEnv)
+		return readTokenFile(path)
+	}
+	if dir := os.Getenv(credentialsDirEnv); dir != "" {
+		path := filepath.Join(dir, systemdCredentialName)
+		if _, err := os.Stat(path); err == nil {
+			tokenSource = fmt.Sprintf("the systemd credential %s", systemdCredentialName)
+			return readTokenFile(path)
+		}
+	}
+	tokenSource = apiKeyEnv
+	return "", nil
+}
+
+// readTokenFile reads a token from a file, ignoring surrounding whitespace
+// such as a trailing newline. The buffer is zeroed once the token is copied
+// out of it. A file others can read only prints a warning, since systemd
+// credentials and secret mounts manage their own permissions.
+func readTokenFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read the API token: %w", err)
+	}
+	defer clear(data)
+
+	if info, err := os.Stat(path); err == nil && runtime.GOOS != "windows" && info.Mode().Perm()&0o077 != 0 {
+		fmt.Fprintf(os.Stderr, "Warning: %s can be read by other users; restrict it with chmod 600\n", path)
+	}
+	token := bytes.TrimSpace(data)
+	if len(token) == 0 {
+		return "", fmt.Errorf("the API token file %s is empty", path)
+	}
+	return string(token), nil
+}
+
+// credentialSource names where the API token is read from.
+func credentialSource() string {
+	return tokenSource
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadToken(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "key")
+	if err := os.WriteFile(keyFile, []byte("from-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	credentials := filepath.Join(dir, "credentials")
+	if err := os.Mkdir(credentials, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(credentials, systemdCredentialName), []byte("from-systemd"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name                   string
+		env, file, credentials string
+		want                   string
+	}{
+		{"environment wins", "from-env", keyFile, credentials, "from-env"},
+		{"file", "", keyFile, credentials, "from-file"},
+		{"systemd credential", "", "", credentials, "from-systemd"},
+		{"credential directory without the credential", "", "", dir, ""},
+		{"nothing configured", "", "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(apiKeyEnv, tt.env)
+			t.Setenv(apiKeyFileEnv, tt.file)
+			t.Setenv(credentialsDirEnv, tt.credentials)
+			got, err := loadToken()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadTokenFileErrors(t *testing.T) {
+	dir := t.TempDir()
+	empty := filepath.Join(dir, "empty")
+	if err := os.WriteFile(empty, []byte(" \n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(apiKeyEnv, "")
+
+	for _, path := range []string{empty, filepath.Join(dir, "missing")} {
+		t.Setenv(apiKeyFileEnv, path)
+		if _, err := loadToken(); err == nil {
+			t.Errorf("%s: expected an error", path)
+		}
+		if !strings.Contains(credentialSource(), apiKeyFileEnv) {
+			t.Errorf("credentialSource() = %q, want it to name %s", credentialSource(), apiKeyFileEnv)
+		}
+	}
+}
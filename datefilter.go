@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// dateFilter limits aliases by when they were created and last received
+// mail. Unset bounds are zero. The filters are evaluated locally, since the
+// API can't query the MaskedEmail properties.
+type dateFilter struct {
+	createdAfter      time.Time
+	createdBefore     time.Time
+	lastMessageBefore time.Time
+}
+
+// addDateFilterFlags adds the --created-after, --created-before and
+// --last-message-before flags to a command.
+func addDateFilterFlags(cmd *cobra.Command) {
+	cmd.Flags().String("created-after", "", "only include aliases created on or after this date, e.g. 2024-06-01")
+	cmd.Flags().String("created-before", "", "only include aliases created before this date")
+	cmd.Flags().String("last-message-before", "", "only include aliases whose last message arrived before this date, or that never received mail")
+}
+
+// dateFilterFromCmd reads the date filter flags of a command. Dates are read
+// in the display time zone, see parseDate.
+func dateFilterFromCmd(cmd *cobra.Command) (dateFilter, error) {
+	var filter dateFilter
+	for _, flag := range []struct {
+		name   string
+		target *time.Time
+	}{
+		{"created-after", &filter.createdAfter},
+		{"created-before", &filter.createdBefore},
+		{"last-message-before", &filter.lastMessageBefore},
+	} {
+		value, _ := cmd.Flags().GetString(flag.name)
+		if value == "" {
+			continue
+		}
+		t, err := parseDate(value)
+		if err != nil {
+			return filter, fmt.Errorf("invalid --%s: %w", flag.name, err)
+		}
+		*flag.target = t
+	}
+	if !filter.createdAfter.IsZero() && !filter.createdBefore.IsZero() && !filter.createdBefore.After(filter.createdAfter) {
+		return filter, fmt.Errorf("--created-before must be later than --created-after")
+	}
+	return filter, nil
+}
+
+// active reports whether any bound is set.
+func (f dateFilter) active() bool {
+	return !f.createdAfter.IsZero() || !f.createdBefore.IsZero() || !f.lastMessageBefore.IsZero()
+}
+
+// matches reports whether the alias is within every bound. Aliases whose
+// creation time is unknown don't match creation bounds, and aliases that
+// never received mail match --last-message-before.
+func (f dateFilter) matches(alias MaskedEmailInfo) bool {
+	if !f.createdAfter.IsZero() || !f.createdBefore.IsZero() {
+		if alias.CreatedAt.IsZero() {
+			return false
+		}
+		if !f.createdAfter.IsZero() && alias.CreatedAt.Before(f.createdAfter) {
+			return false
+		}
+		if !f.createdBefore.IsZero() && !alias.CreatedAt.Before(f.createdBefore) {
+			return false
+		}
+	}
+	if !f.lastMessageBefore.IsZero() && alias.LastMessageAt != nil && !alias.LastMessageAt.Before(f.lastMessageBefore) {
+		return false
+	}
+	return true
+}
+
+// filter returns the aliases matching the date filter.
+func (f dateFilter) filter(aliases []MaskedEmailInfo) []MaskedEmailInfo {
+	if !f.active() {
+		return aliases
+	}
+	matched := make([]MaskedEmailInfo, 0, len(aliases))
+	for _, alias := range aliases {
+		if f.matches(alias) {
+			matched = append(matched, alias)
+		}
+	}
+	return matched
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDateFilter(t *testing.T) {
+	date := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	}
+	lastMessage := date(2023, 9, 1)
+	aliases := []MaskedEmailInfo{
+		{Email: "silent2023@fastmail.com", CreatedAt: date(2023, 3, 1)},
+		{Email: "busy2023@fastmail.com", CreatedAt: date(2023, 5, 1), LastMessageAt: &lastMessage},
+		{Email: "new@fastmail.com", CreatedAt: date(2024, 2, 1)},
+		{Email: "unknown@fastmail.com"},
+	}
+
+	filter := dateFilter{createdAfter: date(2023, 1, 1), createdBefore: date(2024, 1, 1), lastMessageBefore: date(2023, 1, 1)}
+	matched := filter.filter(aliases)
+	if len(matched) != 1 || matched[0].Email != "silent2023@fastmail.com" {
+		t.Fatalf("expected only the alias created in 2023 without mail, got %+v", matched)
+	}
+
+	filter = dateFilter{lastMessageBefore: date(2024, 1, 1)}
+	if got := len(filter.filter(aliases)); got != 4 {
+		t.Errorf("expected aliases without mail and with old mail to match, got %d", got)
+	}
+
+	if got := len(dateFilter{}.filter(aliases)); got != len(aliases) {
+		t.Errorf("expected an empty filter to match every alias, got %d", got)
+	}
+}
+
+func TestDateFilterFromCmd(t *testing.T) {
+	cmd := newExportCmd()
+	cmd.Flags().Set("created-after", "2024-06-01")
+	cmd.Flags().Set("created-before", "2024-01-01")
+	if _, err := dateFilterFromCmd(cmd); err == nil {
+		t.Fatalf("expected an error for an empty creation period")
+	}
+
+	cmd = newExportCmd()
+	cmd.Flags().Set("last-message-before", "yesterday")
+	if _, err := dateFilterFromCmd(cmd); err == nil {
+		t.Fatalf("expected an error for an invalid date")
+	}
+}
+
+func TestRegexStateUpdateWithDates(t *testing.T) {
+	old := time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC)
+	fake, client := newFakeJMAP(t,
+		MaskedEmailInfo{ID: "1", Email: "old@fastmail.com", State: AliasEnabled, CreatedAt: old},
+		MaskedEmailInfo{ID: "2", Email: "new@fastmail.com", State: AliasEnabled, CreatedAt: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)},
+	)
+
+	dates := dateFilter{createdBefore: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	if err := handleRegexStateUpdate(client, nil, nil, dates, false, true, false, false); err != nil {
+		t.Fatalf("handleRegexStateUpdate returned error: %v", err)
+	}
+	if fake.state("1") != AliasDisabled || fake.state("2") != AliasEnabled {
+		t.Fatalf("expected only the alias created before 2020 to be disabled, got %s and %s", fake.state("1"), fake.state("2"))
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/spf13/cobra"
+)
+
+// matchFields are the alias properties a --match expression can test, by
+// lowercase name
+var matchFields = map[string]func(MaskedEmailInfo) string{
+	"email":       func(a MaskedEmailInfo) string { return a.Email },
+	"fordomain":   func(a MaskedEmailInfo) string { return a.ForDomain },
+	"description": func(a MaskedEmailInfo) string { return a.Description },
+	"state":       func(a MaskedEmailInfo) string { return string(a.State) },
+	"createdby":   func(a MaskedEmailInfo) string { return a.CreatedBy },
+	"url":         func(a MaskedEmailInfo) string { return a.URL },
+}
+
+// matchFieldNames lists the fields of --match as they are written.
+const matchFieldNames = "email, forDomain, description, state, createdBy, url"
+
+// parseMatchFilter parses a --match expression: "<field>~<regexp>" matches
+// the field against a regular expression, and "<field>=<value>" compares it
+// with a value, ignoring case. Fields are the alias properties of the API.
+func parseMatchFilter(expr string) (aliasFilter, error) {
+	i := strings.IndexAny(expr, "~=")
+	if i < 0 {
+		return aliasFilter{}, fmt.Errorf("invalid --match %q: expected <field>~<regexp> or <field>=<value>", expr)
+	}
+	name, op, value := expr[:i], expr[i], expr[i+1:]
+	field, ok := matchFields[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return aliasFilter{}, fmt.Errorf("invalid --match %q: unknown field %q (expected one of: %s)", expr, name, matchFieldNames)
+	}
+	if op == '=' {
+		return aliasFilter{match: func(alias MaskedEmailInfo) bool { return strings.EqualFold(field(alias), value) }}, nil
+	}
+	re, err := regexp.Compile(value)
+	if err != nil {
+		return aliasFilter{}, fmt.Errorf("invalid --match %q: %w", expr, err)
+	}
+	return aliasFilter{match: func(alias MaskedEmailInfo) bool { return re.MatchString(field(alias)) }}, nil
+}
+
+// describeData is what a --set template can use.
+type describeData struct {
+	Email string
+	// Domain is the forDomain of the alias, such as https://www.amazon.de
+	Domain string
+	// Host is the host of Domain, such as www.amazon.de, and Site its
+	// registrable domain, such as amazon.de
+	Host        string
+	Site        string
+	Description string
+	State       string
+	CreatedBy   string
+}
+
+// planTemplatedDescriptions returns the aliases matching every filter, other
+// than deleted ones, whose description the template changes, by email.
+func planTemplatedDescriptions(aliases []MaskedEmailInfo, filters []aliasFilter, tmpl *template.Template) ([]descriptionFix, error) {
+	var fixes []descriptionFix
+	for _, alias := range filterAliases(aliases, filters) {
+		host := hostFromOrigin(alias.ForDomain)
+		data := describeData{
+			Email:       alias.Email,
+			Domain:      alias.ForDomain,
+			Host:        host,
+			Site:        registrableDomain(host),
+			Description: alias.Description,
+			State:       string(alias.State),
+			CreatedBy:   alias.CreatedBy,
+		}
+		var description strings.Builder
+		if err := tmpl.Execute(&description, data); err != nil {
+			return nil, &exitCodeError{code: exitInvalidInput, err: fmt.Errorf("invalid --set template for %s: %w", alias.Email, err)}
+		}
+		if text := strings.TrimSpace(description.String()); text != alias.Description {
+			fixes = append(fixes, descriptionFix{Alias: alias, Description: text})
+		}
+	}
+	sort.Slice(fixes, func(i, j int) bool { return fixes[i].Alias.Email < fixes[j].Alias.Email })
+	return fixes, nil
+}
+
+// newDescribeCmd creates the command that sets templated descriptions on the
+// aliases matching a pattern.
+func newDescribeCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "describe --match <expr> --set <template>",
+		Short: "Set templated descriptions on the aliases matching a pattern",
+		Long: `Set the description of every alias matching the --match expressions, other than
+deleted ones, to the --set template, and show the changes. With --apply, the
+descriptions are changed, in as few requests as the server allows.
+
+A --match expression is "<field>~<regexp>" for a regular expression or
+"<field>=<value>" for a value, ignoring case, where the field is one of
+` + matchFieldNames + `. Several --match must all match.
+
+The template is a Go template that can use {{.Email}}, {{.Domain}} (the
+forDomain, e.g. https://www.amazon.de), {{.Host}} (www.amazon.de), {{.Site}}
+(amazon.de), {{.Description}}, {{.State}} and {{.CreatedBy}}.`,
+		Example: `  masked_fastmail describe --match 'forDomain~amazon' --set 'Amazon ({{.Host}})'
+  masked_fastmail describe --match 'forDomain~amazon' --set 'Amazon ({{.Host}})' --apply
+  masked_fastmail describe --match 'description=' --match 'state=enabled' --set '{{.Site}}' --apply`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			exprs, _ := cmd.Flags().GetStringArray("match")
+			set, _ := cmd.Flags().GetString("set")
+			apply, _ := cmd.Flags().GetBool("apply")
+			filters := make([]aliasFilter, 0, len(exprs))
+			for _, expr := range exprs {
+				filter, err := parseMatchFilter(expr)
+				if err != nil {
+					return &exitCodeError{code: exitInvalidInput, err: err}
+				}
+				filters = append(filters, filter)
+			}
+			tmpl, err := template.New("description").Parse(set)
+			if err != nil {
+				return &exitCodeError{code: exitInvalidInput, err: fmt.Errorf("invalid --set template: %w", err)}
+			}
+
+			client, err := newClientFromCmd(cmd)
+			if err != nil {
+				return err
+			}
+			return handleDescribe(client, filters, tmpl, apply)
+		},
+	}
+	cmd.Flags().StringArray("match", nil, "only change aliases matching <field>~<regexp> or <field>=<value> (repeatable)")
+	cmd.Flags().String("set", "", "template of the new descriptions, e.g. 'Amazon ({{.Host}})'")
+	cmd.Flags().Bool("apply", false, "change the descriptions instead of only showing them")
+	cmd.MarkFlagRequired("match")
+	cmd.MarkFlagRequired("set")
+	return cmd
+}
+
+// handleDescribe shows, and with apply makes, the templated description
+// changes of the aliases matching the filters.
+func handleDescribe(client *FastmailClient, filters []aliasFilter, tmpl *template.Template, apply bool) error {
+	aliases, err := fetchAllAliasesWithProgress(client)
+	if err != nil {
+		return formatAPIError("failed to get aliases", err)
+	}
+	fixes, err := planTemplatedDescriptions(aliases, filters, tmpl)
+	if err != nil {
+		return err
+	}
+	if apply {
+		if err := confirmBulk("describe", len(fixes), ""); err != nil {
+			return err
+		}
+	}
+	return applyDescriptionFixes(client, fixes, apply, "No descriptions to change")
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func TestParseMatchFilter(t *testing.T) {
+	alias := MaskedEmailInfo{Email: "shop.1@fastmail.com", ForDomain: "https://www.amazon.de", State: AliasEnabled}
+	tests := map[string]bool{
+		"forDomain~amazon":      true,
+		"fordomain~^https://a":  false,
+		"state=ENABLED":         true,
+		"description=":          true,
+		"email~@fastmail\\.com": true,
+		"state=disabled":        false,
+	}
+	for expr, want := range tests {
+		filter, err := parseMatchFilter(expr)
+		if err != nil {
+			t.Errorf("parseMatchFilter(%q): %v", expr, err)
+			continue
+		}
+		if got := filter.matches(alias); got != want {
+			t.Errorf("%q matched %v, want %v", expr, got, want)
+		}
+	}
+
+	for expr, problem := range map[string]string{
+		"amazon":         "expected <field>~<regexp>",
+		"domain~amazon":  "unknown field",
+		"forDomain~a(mz": "error parsing regexp",
+	} {
+		if _, err := parseMatchFilter(expr); err == nil || !strings.Contains(err.Error(), problem) {
+			t.Errorf("parseMatchFilter(%q) = %v; want an error about %q", expr, err, problem)
+		}
+	}
+}
+
+func TestHandleDescribe(t *testing.T) {
+	fake, client := newFakeJMAP(t,
+		MaskedEmailInfo{ID: "a1", Email: "a.1@fastmail.com", ForDomain: "https://www.amazon.de", Description: "amzn", State: AliasEnabled},
+		MaskedEmailInfo{ID: "a2", Email: "a.2@fastmail.com", ForDomain: "https://smile.amazon.com", Description: "", State: AliasPending},
+		MaskedEmailInfo{ID: "a3", Email: "a.3@fastmail.com", ForDomain: "https://amazon.co.uk", Description: "Amazon (amazon.co.uk)", State: AliasEnabled},
+		MaskedEmailInfo{ID: "a4", Email: "a.4@fastmail.com", ForDomain: "https://amazon.fr", Description: "old", State: AliasDeleted},
+		MaskedEmailInfo{ID: "a5", Email: "e.5@fastmail.com", ForDomain: "https://example.com", Description: "Example", State: AliasEnabled},
+	)
+	// Two updates per request, so that the changes are split
+	fake.maxSet = 2
+	filter, _ := parseMatchFilter("forDomain~amazon")
+	tmpl := template.Must(template.New("description").Parse("Amazon ({{.Host}})"))
+
+	// The preview changes nothing
+	out := captureStdout(t, func() {
+		if err := handleDescribe(client, []aliasFilter{filter}, tmpl, false); err != nil {
+			t.Fatal(err)
+		}
+	})
+	want := `~ a.1@fastmail.com description: "amzn" -> "Amazon (www.amazon.de)"` + "\n" +
+		`~ a.2@fastmail.com description: "" -> "Amazon (smile.amazon.com)"` + "\n"
+	if out != want || fake.requests != 1 {
+		t.Fatalf("preview = %q after %d requests; want %q", out, fake.requests, want)
+	}
+
+	captureStdout(t, func() {
+		if err := handleDescribe(client, []aliasFilter{filter}, tmpl, true); err != nil {
+			t.Fatal(err)
+		}
+	})
+	for id, want := range map[string]string{"a1": "Amazon (www.amazon.de)", "a2": "Amazon (smile.amazon.com)", "a3": "Amazon (amazon.co.uk)", "a4": "old", "a5": "Example"} {
+		if got := fake.aliases[id].Description; got != want {
+			t.Errorf("%s description = %q, want %q", id, got, want)
+		}
+	}
+
+	bad := template.Must(template.New("description").Parse("{{.Hots}}"))
+	if err := handleDescribe(client, []aliasFilter{filter}, bad, false); exitCode(err) != exitInvalidInput {
+		t.Errorf("expected invalid input for an unknown template field, got %v", err)
+	}
+}
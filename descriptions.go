@@ -0,0 +1,261 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// domainDescriptions maps domains to their canonical descriptions; it is set
+// by the descriptions setting
+var domainDescriptions map[string]string
+
+// validateDescriptions checks the descriptions setting: every key must be a
+// domain and every description non-empty.
+func validateDescriptions(descriptions map[string]string) error {
+	for domain, description := range descriptions {
+		if _, err := normalizeOrigin(domain); err != nil || looksLikeEmail(domain) {
+			return fmt.Errorf("descriptions: %q is not a domain", domain)
+		}
+		if strings.TrimSpace(description) == "" {
+			return fmt.Errorf("descriptions: the description of %s is empty", domain)
+		}
+	}
+	return nil
+}
+
+// canonicalDescription returns the configured description of the domain
+// under the active domain strategy. If several configured domains match, the
+// longest one wins, so that shop.example.com can override example.com.
+func canonicalDescription(domain string) (string, bool) {
+	if strings.TrimSpace(domain) == "" {
+		return "", false
+	}
+	keys := make([]string, 0, len(domainDescriptions))
+	for key := range domainDescriptions {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if len(keys[i]) != len(keys[j]) {
+			return len(keys[i]) > len(keys[j])
+		}
+		return keys[i] < keys[j]
+	})
+	for _, key := range keys {
+		if domainsMatch(domain, key) {
+			return domainDescriptions[key], true
+		}
+	}
+	return "", false
+}
+
+// defaultDescription returns the description to create an alias for the
+// domain with: the one given, or else the canonical one, if any.
+func defaultDescription(domain string, description *string) *string {
+	if description != nil {
+		return description
+	}
+	if canonical, ok := canonicalDescription(domain); ok {
+		return &canonical
+	}
+	return nil
+}
+
+// descriptionFix is an alias whose description is to be replaced, such as
+// by the canonical one of its domain.
+type descriptionFix struct {
+	Alias       MaskedEmailInfo
+	Description string
+}
+
+// planDescriptionFixes returns the aliases, other than deleted ones, whose
+// description differs from the canonical one of their domain, by email.
+func planDescriptionFixes(aliases []MaskedEmailInfo) []descriptionFix {
+	var fixes []descriptionFix
+	for _, alias := range aliases {
+		if alias.State == AliasDeleted {
+			continue
+		}
+		canonical, ok := canonicalDescription(alias.ForDomain)
+		if ok && alias.Description != canonical {
+			fixes = append(fixes, descriptionFix{Alias: alias, Description: canonical})
+		}
+	}
+	sort.Slice(fixes, func(i, j int) bool { return fixes[i].Alias.Email < fixes[j].Alias.Email })
+	return fixes
+}
+
+// newTidyDescriptionsCmd creates the command that sets the canonical
+// descriptions of the config file on existing aliases.
+func newTidyDescriptionsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "tidy-descriptions",
+		Short: "Set the descriptions configured for their domains on existing aliases",
+		Long: `Compare the descriptions of the aliases with the canonical descriptions of their
+domains in the descriptions setting of the config file, and show the aliases
+that differ. With --apply, their descriptions are replaced by the canonical
+ones. New aliases get the canonical description when none is given.`,
+		Example: `  masked_fastmail tidy-descriptions
+  masked_fastmail tidy-descriptions --apply`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			apply, _ := cmd.Flags().GetBool("apply")
+			if len(domainDescriptions) == 0 {
+				return fmt.Errorf("no descriptions are configured; add them to the descriptions setting of the config file")
+			}
+			client, err := newClientFromCmd(cmd)
+			if err != nil {
+				return err
+			}
+			return handleTidyDescriptions(client, apply)
+		},
+	}
+	cmd.Flags().Bool("apply", false, "change the descriptions instead of only showing them")
+	return cmd
+}
+
+// handleTidyDescriptions shows, and with apply makes, the description
+// changes.
+func handleTidyDescriptions(client *FastmailClient, apply bool) error {
+	aliases, err := fetchAllAliasesWithProgress(client)
+	if err != nil {
+		return formatAPIError("failed to get aliases", err)
+	}
+	return applyDescriptionFixes(client, planDescriptionFixes(aliases), apply, "All aliases have the configured descriptions")
+}
+
+// applyDescriptionFixes shows, and with apply makes, the description changes,
+// in chunked set requests. none is shown when there are no changes.
+func applyDescriptionFixes(client *FastmailClient, fixes []descriptionFix, apply bool, none string) error {
+	var result *BatchResult
+	var err error
+	if apply && len(fixes) > 0 {
+		updates := make(map[string]MaskedEmailUpdate, len(fixes))
+		for _, fix := range fixes {
+			description := fix.Description
+			updates[fix.Alias.ID] = MaskedEmailUpdate{Description: &description}
+		}
+		ctx, stop := withInterrupt()
+		defer stop()
+		result, err = client.UpdateAliases(ctx, updates, nil)
+	}
+
+	if porcelain != "" {
+		// email, forDomain, current description, new description, outcome, reason
+		for _, fix := range fixes {
+			outcome, reason := tidyOutcome(fix, apply, result)
+			printPorcelain(fix.Alias.Email, fix.Alias.ForDomain, fix.Alias.Description, fix.Description, outcome, reason)
+		}
+	} else {
+		printDescriptionFixes(fixes, apply, result, none)
+	}
+
+	if errors.Is(err, ErrInterrupted) {
+		return err
+	}
+	if err != nil {
+		return formatAPIError("failed to update descriptions", err)
+	}
+	if result != nil && len(result.Failed) > 0 {
+		return fmt.Errorf("%d of %d descriptions could not be updated", len(result.Failed), len(fixes))
+	}
+	return nil
+}
+
+// tidyOutcome returns the outcome of a description change: planned,
+// updated, failed or pending (not sent because the run stopped), with a
+// reason.
+func tidyOutcome(fix descriptionFix, apply bool, result *BatchResult) (string, string) {
+	switch {
+	case !apply:
+		return "planned", ""
+	case result == nil:
+		return "pending", ""
+	}
+	if setErr, ok := result.Failed[fix.Alias.ID]; ok {
+		return "failed", setErr.String()
+	}
+	for _, id := range result.Updated {
+		if id == fix.Alias.ID {
+			return "updated", ""
+		}
+	}
+	return "pending", ""
+}
+
+// printDescriptionFixes prints the description changes in the format of
+// restore, with their outcome once applied, or none if there are none.
+func printDescriptionFixes(fixes []descriptionFix, apply bool, result *BatchResult, none string) {
+	if len(fixes) == 0 {
+		fmt.Println(none)
+		return
+	}
+	for _, fix := range fixes {
+		line := fmt.Sprintf("~ %s description: %s -> %s", fix.Alias.Email, strconv.Quote(fix.Alias.Description), strconv.Quote(fix.Description))
+		switch outcome, reason := tidyOutcome(fix, apply, result); outcome {
+		case "failed":
+			line += fmt.Sprintf("  (failed: %s)", reason)
+		case "pending":
+			line += "  (not applied)"
+		}
+		fmt.Println(line)
+	}
+	if !apply {
+		fmt.Fprintln(os.Stderr, "\nRun again with --apply to make these changes.")
+	}
+}
+
+// aliasesByDescription returns the aliases whose description is the text,
+// ignoring case and surrounding spaces, or, if none is, those whose
+// description contains it.
+func aliasesByDescription(aliases []MaskedEmailInfo, text string) []MaskedEmailInfo {
+	needle := strings.ToLower(strings.TrimSpace(text))
+	var exact, partial []MaskedEmailInfo
+	for _, alias := range aliases {
+		description := strings.ToLower(strings.TrimSpace(alias.Description))
+		switch {
+		case description == needle:
+			exact = append(exact, alias)
+		case strings.Contains(description, needle):
+			partial = append(partial, alias)
+		}
+	}
+	if len(exact) > 0 {
+		return exact
+	}
+	return partial
+}
+
+// resolveByDescription finds the alias meant by --by-description. When
+// several aliases match, the user picks one; when no question can be asked,
+// the matches are listed in the error.
+func resolveByDescription(client *FastmailClient, text string) (*MaskedEmailInfo, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, fmt.Errorf("--by-description cannot be empty")
+	}
+	aliases, err := client.FetchAllAliases()
+	if err != nil {
+		return nil, formatAPIError("failed to get aliases", err)
+	}
+	matches := aliasesByDescription(aliases, text)
+	switch {
+	case len(matches) == 0:
+		return nil, fmt.Errorf("%w: no alias has a description matching %q", ErrAliasNotFound, text)
+	case len(matches) == 1:
+		return &matches[0], nil
+	case canPrompt():
+		fmt.Fprintf(os.Stderr, "%s have a description matching %q:\n", plural(len(matches), "alias", "aliases"), text)
+		return pickAlias(os.Stderr, os.Stdin, matches, nil), nil
+	}
+	emails := make([]string, 0, len(matches))
+	for _, alias := range matches {
+		emails = append(emails, alias.Email)
+	}
+	return nil, fmt.Errorf("%d aliases have a description matching %q: %s; name the alias instead",
+		len(matches), text, strings.Join(emails, ", "))
+}
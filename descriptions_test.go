@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCanonicalDescription(t *testing.T) {
+	domainDescriptions = map[string]string{
+		"github.com":               "GitHub (work org)",
+		"example.com":              "Example",
+		"https://shop.example.com": "Example shop",
+	}
+	defer func() { domainDescriptions = nil }()
+
+	tests := []struct {
+		domain string
+		want   string
+	}{
+		{"https://github.com", "GitHub (work org)"},
+		{"https://shop.example.com", "Example shop"},
+		{"https://example.com", "Example"},
+		{"https://gitlab.com", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got, _ := canonicalDescription(tt.domain); got != tt.want {
+			t.Errorf("canonicalDescription(%q) = %q, want %q", tt.domain, got, tt.want)
+		}
+	}
+
+	given := "Personal"
+	if got := defaultDescription("https://github.com", &given); *got != given {
+		t.Errorf("expected a given description to win, got %q", *got)
+	}
+	if got := defaultDescription("https://github.com", nil); got == nil || *got != "GitHub (work org)" {
+		t.Errorf("expected the canonical description, got %v", got)
+	}
+}
+
+func TestHandleTidyDescriptions(t *testing.T) {
+	domainDescriptions = map[string]string{"github.com": "GitHub (work org)"}
+	defer func() { domainDescriptions = nil }()
+	fake, client := newFakeJMAP(t,
+		MaskedEmailInfo{ID: "a1", Email: "gh.1@fastmail.com", ForDomain: "https://github.com", Description: "gh", State: AliasEnabled},
+		MaskedEmailInfo{ID: "a2", Email: "gh.2@fastmail.com", ForDomain: "https://github.com", Description: "GitHub (work org)", State: AliasEnabled},
+		MaskedEmailInfo{ID: "a3", Email: "gh.3@fastmail.com", ForDomain: "https://github.com", Description: "old", State: AliasDeleted},
+		MaskedEmailInfo{ID: "a4", Email: "other.4@fastmail.com", ForDomain: "https://other.com", Description: "other", State: AliasEnabled},
+	)
+
+	fixes := planDescriptionFixes(allFakeAliases(fake))
+	if len(fixes) != 1 || fixes[0].Alias.ID != "a1" {
+		t.Fatalf("expected only a1 to need a new description, got %+v", fixes)
+	}
+
+	captureStdout(t, func() {
+		if err := handleTidyDescriptions(client, true); err != nil {
+			t.Fatalf("handleTidyDescriptions returned error: %v", err)
+		}
+	})
+	if got := fake.aliases["a1"].Description; got != "GitHub (work org)" {
+		t.Errorf("expected a1 to get the canonical description, got %q", got)
+	}
+	if got := fake.aliases["a3"].Description; got != "old" {
+		t.Errorf("expected the deleted alias to be left alone, got %q", got)
+	}
+}
+
+// allFakeAliases returns the aliases of the fake API.
+func allFakeAliases(f *fakeJMAP) []MaskedEmailInfo {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var aliases []MaskedEmailInfo
+	for _, alias := range f.aliases {
+		aliases = append(aliases, *alias)
+	}
+	return aliases
+}
+
+func TestAliasesByDescription(t *testing.T) {
+	aliases := []MaskedEmailInfo{
+		{Email: "a@fastmail.com", Description: "Streaming trial"},
+		{Email: "b@fastmail.com", Description: "Streaming trial (family)"},
+		{Email: "c@fastmail.com", Description: "Shop"},
+	}
+	if got := aliasesByDescription(aliases, " streaming TRIAL "); len(got) != 1 || got[0].Email != "a@fastmail.com" {
+		t.Errorf("an exact match should win over partial ones, got %v", got)
+	}
+	if got := aliasesByDescription(aliases, "stream"); len(got) != 2 {
+		t.Errorf("expected two partial matches, got %v", got)
+	}
+	if got := aliasesByDescription(aliases, "bank"); len(got) != 0 {
+		t.Errorf("expected no match, got %v", got)
+	}
+}
+
+func TestResolveByDescription(t *testing.T) {
+	_, client := newFakeJMAP(t,
+		MaskedEmailInfo{ID: "a1", Email: "a@fastmail.com", Description: "Streaming trial", State: AliasEnabled},
+		MaskedEmailInfo{ID: "a2", Email: "b@fastmail.com", Description: "Streaming (family)", State: AliasEnabled},
+	)
+
+	alias, err := resolveByDescription(client, "streaming trial")
+	if err != nil || alias.Email != "a@fastmail.com" {
+		t.Fatalf("resolveByDescription() = %v, %v", alias, err)
+	}
+	// Tests run without a terminal, so an ambiguous match lists the aliases
+	_, err = resolveByDescription(client, "streaming")
+	if err == nil || !strings.Contains(err.Error(), "a@fastmail.com") || !strings.Contains(err.Error(), "b@fastmail.com") {
+		t.Errorf("expected the matches in the error, got %v", err)
+	}
+	if _, err := resolveByDescription(client, "bank"); !errors.Is(err, ErrAliasNotFound) {
+		t.Errorf("expected ErrAliasNotFound, got %v", err)
+	}
+}
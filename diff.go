@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// Fields compared by diff
+const (
+	fieldState       = "state"
+	fieldDescription = "description"
+	fieldDomain      = "domain"
+)
+
+// fieldChange is a field of an alias that differs between two snapshots.
+type fieldChange struct {
+	Field string
+	Old   string
+	New   string
+}
+
+// aliasChange is an alias present in both snapshots with different fields.
+type aliasChange struct {
+	// Old and New are the alias in the earlier and the later snapshot
+	Old     MaskedEmailInfo
+	New     MaskedEmailInfo
+	Changes []fieldChange
+}
+
+// aliasDiff lists the differences between two snapshots of an account's
+// aliases.
+type aliasDiff struct {
+	Added   []MaskedEmailInfo
+	Removed []MaskedEmailInfo
+	Changed []aliasChange
+}
+
+// Empty reports whether the snapshots are the same.
+func (d aliasDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// aliasKey identifies an alias across snapshots: by ID, or by email for
+// exports without IDs.
+func aliasKey(alias MaskedEmailInfo) string {
+	if alias.ID != "" {
+		return alias.ID
+	}
+	return alias.Email
+}
+
+// diffAliases compares an earlier snapshot of the aliases (before) with a
+// later one (after). Results are sorted by email.
+func diffAliases(before, after []MaskedEmailInfo) aliasDiff {
+	oldByKey := make(map[string]MaskedEmailInfo, len(before))
+	for _, alias := range before {
+		oldByKey[aliasKey(alias)] = alias
+	}
+
+	var diff aliasDiff
+	seen := make(map[string]bool, len(after))
+	for _, alias := range after {
+		key := aliasKey(alias)
+		seen[key] = true
+		previous, ok := oldByKey[key]
+		if !ok {
+			diff.Added = append(diff.Added, alias)
+			continue
+		}
+		if changes := compareAliases(previous, alias); len(changes) > 0 {
+			diff.Changed = append(diff.Changed, aliasChange{Old: previous, New: alias, Changes: changes})
+		}
+	}
+	for _, alias := range before {
+		if !seen[aliasKey(alias)] {
+			diff.Removed = append(diff.Removed, alias)
+		}
+	}
+
+	byEmail := func(aliases []MaskedEmailInfo) {
+		sort.Slice(aliases, func(i, j int) bool { return aliases[i].Email < aliases[j].Email })
+	}
+	byEmail(diff.Added)
+	byEmail(diff.Removed)
+	sort.Slice(diff.Changed, func(i, j int) bool { return diff.Changed[i].New.Email < diff.Changed[j].New.Email })
+	return diff
+}
+
+// compareAliases returns the fields that differ between two versions of an
+// alias.
+func compareAliases(before, after MaskedEmailInfo) []fieldChange {
+	var changes []fieldChange
+	if before.State != after.State {
+		changes = append(changes, fieldChange{fieldState, string(before.State), string(after.State)})
+	}
+	if before.Description != after.Description {
+		changes = append(changes, fieldChange{fieldDescription, before.Description, after.Description})
+	}
+	if before.ForDomain != after.ForDomain {
+		changes = append(changes, fieldChange{fieldDomain, before.ForDomain, after.ForDomain})
+	}
+	return changes
+}
+
+// loadExport reads an export written by `export --format json`.
+func loadExport(path string) (*aliasExport, error) {
+	export := &aliasExport{}
+	if err := readJSONPath(path, export); err != nil {
+		return nil, fmt.Errorf("failed to read export: %w", err)
+	}
+	if export.Aliases == nil {
+		return nil, fmt.Errorf("%s is not a JSON export (no aliases found); create one with `masked_fastmail export --file %s`", path, path)
+	}
+	return export, nil
+}
+
+// writeUnifiedDiff writes the diff with one line per added ("+") or removed
+// ("-") alias and per changed field ("~"), after a header naming both sides.
+func writeUnifiedDiff(w io.Writer, diff aliasDiff, oldLabel, newLabel string) error {
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, "--- %s\n+++ %s\n", oldLabel, newLabel)
+	for _, alias := range diff.Added {
+		fmt.Fprintf(out, "+ %s %s %s %s\n", alias.Email, alias.State, alias.ForDomain, strconv.Quote(alias.Description))
+	}
+	for _, alias := range diff.Removed {
+		fmt.Fprintf(out, "- %s %s %s %s\n", alias.Email, alias.State, alias.ForDomain, strconv.Quote(alias.Description))
+	}
+	for _, change := range diff.Changed {
+		for _, field := range change.Changes {
+			fmt.Fprintf(out, "~ %s %s: %s -> %s\n", change.New.Email, field.Field, strconv.Quote(field.Old), strconv.Quote(field.New))
+		}
+	}
+	return out.Flush()
+}
+
+// printDiffPorcelain prints one record per added or removed alias and per
+// changed field.
+func printDiffPorcelain(diff aliasDiff) {
+	// change (added|removed|changed), email, field, old value, new value
+	for _, alias := range diff.Added {
+		printPorcelain("added", alias.Email, "", "", string(alias.State))
+	}
+	for _, alias := range diff.Removed {
+		printPorcelain("removed", alias.Email, "", string(alias.State), "")
+	}
+	for _, change := range diff.Changed {
+		for _, field := range change.Changes {
+			printPorcelain("changed", change.New.Email, field.Field, field.Old, field.New)
+		}
+	}
+}
+
+// newDiffCmd creates the command that compares the account with an export.
+func newDiffCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "diff <export.json>",
+		Short: "Show aliases added, removed and changed since a JSON export was taken",
+		Long: `Compare the account with a JSON export and show the aliases added and removed
+since the export was taken, and the changes to the state, description and domain
+of the others. Lines start with "+" for added aliases, "-" for removed ones and
+"~" for each changed field.`,
+		Example: `  masked_fastmail export --file backup.json
+  masked_fastmail diff backup.json`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, err := newClientFromCmd(cmd)
+			if err != nil {
+				return err
+			}
+			return handleDiff(client, args[0])
+		},
+	}
+}
+
+// handleDiff prints the differences between an export and the account.
+func handleDiff(client *FastmailClient, path string) error {
+	export, err := loadExport(path)
+	if err != nil {
+		return err
+	}
+	if export.AccountID != "" && export.AccountID != client.AccountID {
+		fmt.Fprintf(os.Stderr, "Warning: %s was exported from account %s, not %s\n", path, export.AccountID, client.AccountID)
+	}
+
+	aliases, err := fetchAllAliasesWithProgress(client)
+	if err != nil {
+		return formatAPIError("failed to get aliases", err)
+	}
+	diff := diffAliases(export.Aliases, aliases)
+
+	if porcelain != "" {
+		printDiffPorcelain(diff)
+		return nil
+	}
+	if diff.Empty() {
+		fmt.Printf("No changes since %s was exported\n", path)
+		return nil
+	}
+	oldLabel := path
+	if !export.ExportedAt.IsZero() {
+		oldLabel += " (exported " + export.ExportedAt.UTC().Format(time.RFC3339) + ")"
+	}
+	return writeUnifiedDiff(os.Stdout, diff, oldLabel, "account "+client.AccountID)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDiffAliases(t *testing.T) {
+	before := []MaskedEmailInfo{
+		{ID: "1", Email: "kept@fastmail.com", State: AliasEnabled, Description: "Shop"},
+		{ID: "2", Email: "changed@fastmail.com", State: AliasEnabled, Description: "Old", ForDomain: "https://a.com"},
+		{ID: "3", Email: "removed@fastmail.com", State: AliasDisabled},
+	}
+	after := []MaskedEmailInfo{
+		{ID: "4", Email: "added@fastmail.com", State: AliasPending},
+		{ID: "2", Email: "changed@fastmail.com", State: AliasDisabled, Description: "New", ForDomain: "https://a.com"},
+		{ID: "1", Email: "kept@fastmail.com", State: AliasEnabled, Description: "Shop"},
+	}
+
+	diff := diffAliases(before, after)
+	if len(diff.Added) != 1 || diff.Added[0].Email != "added@fastmail.com" {
+		t.Fatalf("unexpected added aliases: %+v", diff.Added)
+	}
+	if len(diff.Removed) != 1 || diff.Removed[0].Email != "removed@fastmail.com" {
+		t.Fatalf("unexpected removed aliases: %+v", diff.Removed)
+	}
+	if len(diff.Changed) != 1 {
+		t.Fatalf("expected one changed alias, got %+v", diff.Changed)
+	}
+	changes := diff.Changed[0].Changes
+	if len(changes) != 2 || changes[0] != (fieldChange{fieldState, "enabled", "disabled"}) || changes[1] != (fieldChange{fieldDescription, "Old", "New"}) {
+		t.Fatalf("unexpected field changes: %+v", changes)
+	}
+
+	if !diffAliases(after, after).Empty() {
+		t.Fatalf("expected no differences between identical snapshots")
+	}
+}
+
+func TestWriteUnifiedDiff(t *testing.T) {
+	diff := diffAliases(
+		[]MaskedEmailInfo{{ID: "1", Email: "a@fastmail.com", State: AliasEnabled, Description: "x"}},
+		[]MaskedEmailInfo{{ID: "1", Email: "a@fastmail.com", State: AliasEnabled, Description: "y"}},
+	)
+	var out bytes.Buffer
+	if err := writeUnifiedDiff(&out, diff, "backup.json", "account u1"); err != nil {
+		t.Fatal(err)
+	}
+	want := "--- backup.json\n+++ account u1\n~ a@fastmail.com description: \"x\" -> \"y\"\n"
+	if out.String() != want {
+		t.Fatalf("unexpected diff:\n%s", out.String())
+	}
+}
+
+func TestLoadExport(t *testing.T) {
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "backup.json")
+	if err := os.WriteFile(valid, []byte(`{"accountId":"u1","aliases":[{"id":"1","email":"a@fastmail.com"}]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	export, err := loadExport(valid)
+	if err != nil || len(export.Aliases) != 1 {
+		t.Fatalf("expected one alias, got %+v, %v", export, err)
+	}
+
+	invalid := filepath.Join(dir, "other.json")
+	if err := os.WriteFile(invalid, []byte(`{"something":"else"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadExport(invalid); err == nil || !strings.Contains(err.Error(), "not a JSON export") {
+		t.Fatalf("expected an error for a file that is not an export, got %v", err)
+	}
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	digestStateFile = "digest.json"
+	// defaultDigestPeriod is covered by the first digest, when there is no
+	// previous run to continue from
+	defaultDigestPeriod = 7 * 24 * time.Hour
+)
+
+// digestFormats lists the supported digest formats
+var digestFormats = []string{"text", "html"}
+
+// digestSnapshot records the alias states seen by the last digest, so that
+// the next one can report state changes.
+type digestSnapshot struct {
+	RunAt time.Time `json:"runAt"`
+	// States maps alias IDs to their state at RunAt
+	States map[string]AliasState `json:"states"`
+}
+
+// stateChange is an alias whose state changed since the last digest.
+type stateChange struct {
+	Alias MaskedEmailInfo
+	From  AliasState
+}
+
+// aliasDigest summarizes the changes to an account's aliases in a period.
+type aliasDigest struct {
+	Since time.Time
+	Until time.Time
+	// Created are aliases created in the period
+	Created []MaskedEmailInfo
+	// StateChanges are aliases whose state changed since the last digest
+	StateChanges []stateChange
+	// Active are aliases that received mail in the period
+	Active []MaskedEmailInfo
+	// Total is the number of aliases that are not deleted
+	Total int
+}
+
+// Empty reports whether nothing changed in the period.
+func (d aliasDigest) Empty() bool {
+	return len(d.Created) == 0 && len(d.StateChanges) == 0 && len(d.Active) == 0
+}
+
+// parseSince parses a period such as "7d", "2w" or "36h".
+func parseSince(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if number, ok := strings.CutSuffix(value, suffix); ok {
+			n, err := strconv.Atoi(number)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid period %q (expected e.g. 7d, 2w or 36h)", value)
+			}
+			return time.Duration(n) * unit, nil
+		}
+	}
+	period, err := time.ParseDuration(value)
+	if err != nil || period <= 0 {
+		return 0, fmt.Errorf("invalid period %q (expected e.g. 7d, 2w or 36h)", value)
+	}
+	return period, nil
+}
+
+// buildDigest collects the changes to the aliases between since and until.
+// State changes are found by comparing with the previous snapshot, if any.
+func buildDigest(aliases []MaskedEmailInfo, previous *digestSnapshot, since, until time.Time) aliasDigest {
+	digest := aliasDigest{Since: since, Until: until}
+	for _, alias := range aliases {
+		if alias.State != AliasDeleted {
+			digest.Total++
+		}
+
+		created := !alias.CreatedAt.Before(since)
+		if created {
+			digest.Created = append(digest.Created, alias)
+		} else if previous != nil {
+			if from, ok := previous.States[alias.ID]; ok && from != alias.State {
+				digest.StateChanges = append(digest.StateChanges, stateChange{Alias: alias, From: from})
+			}
+		}
+		if alias.LastMessageAt != nil && !alias.LastMessageAt.Before(since) {
+			digest.Active = append(digest.Active, alias)
+		}
+	}
+
+	byEmail := func(aliases []MaskedEmailInfo) {
+		sort.Slice(aliases, func(i, j int) bool { return aliases[i].Email < aliases[j].Email })
+	}
+	byEmail(digest.Created)
+	byEmail(digest.Active)
+	sort.Slice(digest.StateChanges, func(i, j int) bool {
+		return digest.StateChanges[i].Alias.Email < digest.StateChanges[j].Alias.Email
+	})
+	return digest
+}
+
+// newSnapshot records the current alias states.
+func newSnapshot(aliases []MaskedEmailInfo, now time.Time) digestSnapshot {
+	snapshot := digestSnapshot{RunAt: now.UTC(), States: make(map[string]AliasState, len(aliases))}
+	for _, alias := range aliases {
+		snapshot.States[alias.ID] = alias.State
+	}
+	return snapshot
+}
+
+// writeTextDigest writes the digest as plain text, suitable for an email.
+func writeTextDigest(w io.Writer, digest aliasDigest) error {
+	fmt.Fprintf(w, "Masked email digest, %s to %s\n", digest.Since.In(displayLocation).Format("2 Jan 2006"), digest.Until.In(displayLocation).Format("2 Jan 2006"))
+	if digest.Empty() {
+		fmt.Fprintf(w, "\nNo changes. %d aliases in use.\n", digest.Total)
+		return nil
+	}
+
+	if len(digest.Created) > 0 {
+		fmt.Fprintf(w, "\nNew aliases (%d):\n", len(digest.Created))
+		for _, alias := range digest.Created {
+			fmt.Fprintf(w, "- %s for %s\n", alias.Email, describeAliasOrigin(alias))
+		}
+	}
+	if len(digest.StateChanges) > 0 {
+		fmt.Fprintf(w, "\nState changes (%d):\n", len(digest.StateChanges))
+		for _, change := range digest.StateChanges {
+			fmt.Fprintf(w, "- %s: %s -> %s\n", change.Alias.Email, themedState(change.From), themedState(change.Alias.State))
+		}
+	}
+	if len(digest.Active) > 0 {
+		fmt.Fprintf(w, "\nReceived mail (%d):\n", len(digest.Active))
+		for _, alias := range digest.Active {
+			fmt.Fprintf(w, "- %s, last message %s\n", alias.Email, formatTimeAt(*alias.LastMessageAt, digest.Until))
+		}
+	}
+	fmt.Fprintf(w, "\n%d aliases in use.\n", digest.Total)
+	return nil
+}
+
+// writeHTMLDigest writes the digest as a self-contained HTML document.
+func writeHTMLDigest(w io.Writer, digest aliasDigest) error {
+	tmpl, err := template.New("digest").Funcs(template.FuncMap{
+		"date":   func(t time.Time) string { return t.In(displayLocation).Format("2 Jan 2006") },
+		"when":   func(t *time.Time) string { return formatTimeAt(*t, digest.Until) },
+		"origin": describeAliasOrigin,
+		"state":  themedState,
+	}).Parse(htmlDigestTemplate)
+	if err != nil {
+		return err
+	}
+	return tmpl.Execute(w, digest)
+}
+
+const htmlDigestTemplate = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Masked email digest</title>
+</head>
+<body style="font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Helvetica, Arial, sans-serif; color: #1f2328;">
+<h1 style="font-size: 1.3rem;">Masked email digest</h1>
+<p style="color: #656d76;">{{date .Since}} to {{date .Until}}</p>
+{{- if .Empty}}
+<p>No changes.</p>
+{{- end}}
+{{- if .Created}}
+<h2 style="font-size: 1.1rem;">New aliases ({{len .Created}})</h2>
+<ul>
+{{- range .Created}}
+<li>{{.Email}} for {{origin .}}</li>
+{{- end}}
+</ul>
+{{- end}}
+{{- if .StateChanges}}
+<h2 style="font-size: 1.1rem;">State changes ({{len .StateChanges}})</h2>
+<ul>
+{{- range .StateChanges}}
+<li>{{.Alias.Email}}: {{state .From}} &rarr; {{state .Alias.State}}</li>
+{{- end}}
+</ul>
+{{- end}}
+{{- if .Active}}
+<h2 style="font-size: 1.1rem;">Received mail ({{len .Active}})</h2>
+<ul>
+{{- range .Active}}
+<li>{{.Email}}, last message {{when .LastMessageAt}}</li>
+{{- end}}
+</ul>
+{{- end}}
+<p style="color: #656d76;">{{.Total}} aliases in use.</p>
+</body>
+</html>
+`
+
+// newDigestCmd creates the command that summarizes recent changes to the
+// account's aliases.
+func newDigestCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "digest",
+		Short: "Summarize new aliases, state changes and aliases receiving mail since the last digest",
+		Long: `Summarize what changed since the last digest: new aliases, aliases whose state
+changed and aliases that received mail. The first digest covers the last 7 days;
+each run remembers the alias states it saw, so a weekly cron job gets a report of
+the past week. The output is plain text or HTML, ready to be mailed.`,
+		Example: `  # Weekly digest from cron:
+  0 8 * * 1  masked_fastmail digest | mail -s "Alias digest" me@example.com
+
+  # Everything in the last 30 days:
+  masked_fastmail digest --since 30d`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			format, _ := cmd.Flags().GetString("format")
+			var period time.Duration
+			if since, _ := cmd.Flags().GetString("since"); cmd.Flags().Changed("since") {
+				parsed, err := parseSince(since)
+				if err != nil {
+					return err
+				}
+				period = parsed
+			}
+			client, err := newClientFromCmd(cmd)
+			if err != nil {
+				return err
+			}
+			return handleDigest(client, period, format)
+		},
+	}
+	cmd.Flags().String("since", "", "period to cover, e.g. 7d, 2w or 36h (default since the last digest)")
+	cmd.Flags().String("format", "text", "digest format: "+strings.Join(digestFormats, ", "))
+	return cmd
+}
+
+// handleDigest prints the digest of the period, or of the time since the
+// last digest if period is zero, and saves the alias states for the next one.
+func handleDigest(client *FastmailClient, period time.Duration, format string) error {
+	format = strings.ToLower(strings.TrimSpace(format))
+	write := writeTextDigest
+	switch format {
+	case "text":
+	case "html":
+		write = writeHTMLDigest
+	default:
+		return fmt.Errorf("unknown digest format %q (expected one of: %s)", format, strings.Join(digestFormats, ", "))
+	}
+
+	var previous *digestSnapshot
+	snapshot := &digestSnapshot{}
+	if err := readJSONFile(digestStateFile, snapshot); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not read the previous digest: %v\n", err)
+	} else if !snapshot.RunAt.IsZero() {
+		previous = snapshot
+	}
+
+	now := time.Now()
+	since := now.Add(-defaultDigestPeriod)
+	switch {
+	case period > 0:
+		since = now.Add(-period)
+	case previous != nil:
+		since = previous.RunAt
+	}
+
+	aliases, err := fetchAllAliasesWithProgress(client)
+	if err != nil {
+		return formatAPIError("failed to get aliases", err)
+	}
+	if err := write(os.Stdout, buildDigest(aliases, previous, since, now)); err != nil {
+		return err
+	}
+
+	if err := writeJSONFile(digestStateFile, newSnapshot(aliases, now)); err != nil {
+		return fmt.Errorf("failed to save digest state: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSince(t *testing.T) {
+	tests := map[string]time.Duration{
+		"7d":  7 * 24 * time.Hour,
+		"2w":  14 * 24 * time.Hour,
+		"36h": 36 * time.Hour,
+	}
+	for input, expected := range tests {
+		got, err := parseSince(input)
+		if err != nil || got != expected {
+			t.Fatalf("parseSince(%q) = %s, %v; want %s", input, got, err, expected)
+		}
+	}
+	for _, input := range []string{"", "d", "-1d", "0h", "week"} {
+		if _, err := parseSince(input); err == nil {
+			t.Fatalf("expected parseSince(%q) to fail", input)
+		}
+	}
+}
+
+func TestBuildDigest(t *testing.T) {
+	now := time.Date(2024, 5, 8, 8, 0, 0, 0, time.UTC)
+	since := now.Add(-7 * 24 * time.Hour)
+	recent := now.Add(-time.Hour)
+	old := since.Add(-time.Hour)
+
+	aliases := []MaskedEmailInfo{
+		{ID: "1", Email: "new@fastmail.com", State: AliasPending, ForDomain: "https://example.com", CreatedAt: recent},
+		{ID: "2", Email: "disabled@fastmail.com", State: AliasDisabled, CreatedAt: old},
+		{ID: "3", Email: "active@fastmail.com", State: AliasEnabled, CreatedAt: old, LastMessageAt: &recent},
+		{ID: "4", Email: "quiet@fastmail.com", State: AliasEnabled, CreatedAt: old, LastMessageAt: &old},
+		{ID: "5", Email: "gone@fastmail.com", State: AliasDeleted, CreatedAt: old},
+	}
+	previous := &digestSnapshot{RunAt: since, States: map[string]AliasState{
+		"2": AliasEnabled,
+		"3": AliasEnabled,
+		"4": AliasEnabled,
+		"5": AliasDeleted,
+	}}
+
+	digest := buildDigest(aliases, previous, since, now)
+	if len(digest.Created) != 1 || digest.Created[0].ID != "1" {
+		t.Fatalf("unexpected new aliases: %+v", digest.Created)
+	}
+	if len(digest.StateChanges) != 1 || digest.StateChanges[0].Alias.ID != "2" || digest.StateChanges[0].From != AliasEnabled {
+		t.Fatalf("unexpected state changes: %+v", digest.StateChanges)
+	}
+	if len(digest.Active) != 1 || digest.Active[0].ID != "3" {
+		t.Fatalf("unexpected active aliases: %+v", digest.Active)
+	}
+	if digest.Total != 4 {
+		t.Fatalf("expected 4 aliases in use, got %d", digest.Total)
+	}
+
+	// Without a previous run there is nothing to compare states with
+	if digest := buildDigest(aliases, nil, since, now); len(digest.StateChanges) != 0 {
+		t.Fatalf("expected no state changes without a previous digest, got %+v", digest.StateChanges)
+	}
+}
+
+func TestWriteDigest(t *testing.T) {
+	now := time.Date(2024, 5, 8, 8, 0, 0, 0, time.UTC)
+	digest := aliasDigest{
+		Since:        now.Add(-7 * 24 * time.Hour),
+		Until:        now,
+		Created:      []MaskedEmailInfo{{Email: "new@fastmail.com", ForDomain: "https://example.com"}},
+		StateChanges: []stateChange{{Alias: MaskedEmailInfo{Email: "old@fastmail.com", State: AliasDisabled}, From: AliasEnabled}},
+		Total:        2,
+	}
+
+	var text bytes.Buffer
+	if err := writeTextDigest(&text, digest); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"New aliases (1):", "- new@fastmail.com for https://example.com", "- old@fastmail.com: enabled -> disabled", "2 aliases in use."} {
+		if !strings.Contains(text.String(), want) {
+			t.Fatalf("expected %q in the text digest:\n%s", want, text.String())
+		}
+	}
+
+	var html bytes.Buffer
+	if err := writeHTMLDigest(&html, digest); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(html.String(), "<li>old@fastmail.com: enabled &rarr; disabled</li>") {
+		t.Fatalf("unexpected HTML digest:\n%s", html.String())
+	}
+
+	var empty bytes.Buffer
+	writeTextDigest(&empty, aliasDigest{Since: digest.Since, Until: now})
+	if !strings.Contains(empty.String(), "No changes.") {
+		t.Fatalf("expected an empty digest to say so:\n%s", empty.String())
+	}
+}
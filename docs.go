@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/cobra/doc"
+)
+
+// exitStatus describes a process exit code in the generated documentation.
+type exitStatus struct {
+	Code    int
+	Meaning string
+}
+
+// Exit codes of the CLI, so that scripts and automation apps can tell
+// failures apart without parsing messages
+const (
+	exitSuccess      = 0
+	exitFailure      = 1
+	exitInvalidInput = 2
+	exitAuth         = 3
+	exitUnreachable  = 4
+	exitReadOnly     = 5
+)
+
+// exitStatuses lists the exit codes of the CLI.
+var exitStatuses = []exitStatus{
+	{exitSuccess, "success"},
+	{exitFailure, "any other error, including invalid arguments and API failures; for exists, no enabled alias exists"},
+	{exitInvalidInput, "the shortcut or exists command received no valid URL, domain or alias"},
+	{exitAuth, "the API token is missing or was rejected"},
+	{exitUnreachable, "Fastmail could not be reached (network error or timeout)"},
+	{exitReadOnly, "the change is not allowed in read-only mode or with this API token"},
+}
+
+// exitStatusHelp renders the exit codes as a help section. The table is
+// indented so that it is kept as preformatted text in markdown and man pages.
+func exitStatusHelp() string {
+	var b strings.Builder
+	b.WriteString("Exit status:\n\n")
+	for _, status := range exitStatuses {
+		fmt.Fprintf(&b, "    %-3d %s\n", status.Code, status.Meaning)
+	}
+	return b.String()
+}
+
+// newDocsCmd creates the command that generates documentation from the
+// command tree, for packagers shipping man pages or markdown docs.
+func newDocsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "docs",
+		Short: "Generate man pages or markdown documentation",
+		Example: `  # Generate man pages into ./man:
+  masked_fastmail docs man --dir man
+
+  # Generate one markdown file per command into ./docs:
+  masked_fastmail docs markdown --dir docs`,
+	}
+
+	manCmd := &cobra.Command{
+		Use:   "man",
+		Short: "Generate a man page for every command",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dir, _ := cmd.Flags().GetString("dir")
+			return generateDocs(cmd.Root(), dir, "man")
+		},
+	}
+	manCmd.Flags().String("dir", "man", "directory to write the man pages to")
+
+	markdownCmd := &cobra.Command{
+		Use:   "markdown",
+		Short: "Generate a markdown file for every command",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dir, _ := cmd.Flags().GetString("dir")
+			return generateDocs(cmd.Root(), dir, "markdown")
+		},
+	}
+	markdownCmd.Flags().String("dir", "docs", "directory to write the markdown files to")
+
+	cmd.AddCommand(manCmd, markdownCmd)
+	return cmd
+}
+
+// generateDocs writes documentation for root and all its subcommands.
+func generateDocs(root *cobra.Command, dir string, format string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create documentation directory: %w", err)
+	}
+
+	// Leave out the generation date so that builds are reproducible
+	root.DisableAutoGenTag = true
+
+	var err error
+	switch format {
+	case "man":
+		err = doc.GenManTree(root, &doc.GenManHeader{
+			Title:   strings.ToUpper(root.Name()),
+			Section: "1",
+			Source:  fmt.Sprintf("%s %s", root.Name(), version),
+			Manual:  "User Commands",
+		}, dir)
+	case "markdown":
+		err = doc.GenMarkdownTree(root, dir)
+	default:
+		err = fmt.Errorf("unknown documentation format %q", format)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to generate %s documentation: %w", format, err)
+	}
+
+	fmt.Fprintf(os.Stderr, "Wrote %s documentation to %s\n", format, dir)
+	return nil
+}
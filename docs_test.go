@@ -0,0 +1,59 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func TestExitStatusHelp(t *testing.T) {
+	help := exitStatusHelp()
+	for _, status := range exitStatuses {
+		if !strings.Contains(help, status.Meaning) {
+			t.Errorf("expected exit status help to describe %d, got:\n%s", status.Code, help)
+		}
+	}
+}
+
+func TestGenerateDocs(t *testing.T) {
+	root := &cobra.Command{Use: "masked_fastmail", Long: exitStatusHelp()}
+	root.AddCommand(&cobra.Command{
+		Use:     "export",
+		Short:   "Export all aliases",
+		Example: "  masked_fastmail export --file aliases.json",
+		Run:     func(cmd *cobra.Command, args []string) {},
+	})
+
+	tests := []struct {
+		format string
+		files  []string
+	}{
+		{format: "man", files: []string{"masked_fastmail.1", "masked_fastmail-export.1"}},
+		{format: "markdown", files: []string{"masked_fastmail.md", "masked_fastmail_export.md"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "out")
+			if err := generateDocs(root, dir, tt.format); err != nil {
+				t.Fatalf("generateDocs returned error: %v", err)
+			}
+			for _, name := range tt.files {
+				content, err := os.ReadFile(filepath.Join(dir, name))
+				if err != nil {
+					t.Fatalf("expected %s to be generated: %v", name, err)
+				}
+				if name == tt.files[1] && !strings.Contains(string(content), "aliases.json") {
+					t.Errorf("expected %s to include the example, got:\n%s", name, content)
+				}
+			}
+		})
+	}
+
+	if err := generateDocs(root, t.TempDir(), "pdf"); err == nil {
+		t.Fatalf("expected an error for an unknown format")
+	}
+}
@@ -2,18 +2,93 @@ package main
 
 import (
 	"fmt"
+	"net"
 	"net/url"
 	"strings"
+
+	"golang.org/x/net/publicsuffix"
 )
 
 const (
 	defaultScheme = "https"
 )
 
+// domainStrategy controls how precisely a domain is matched to aliases.
+type domainStrategy string
+
+const (
+	// strategyOrigin keeps one alias per scheme and host (the default)
+	strategyOrigin domainStrategy = "origin"
+	// strategyHost keeps one alias per host, regardless of scheme
+	strategyHost domainStrategy = "host"
+	// strategyRegistrable keeps one alias per registrable domain, so that
+	// shop.example.com and example.com share an alias
+	strategyRegistrable domainStrategy = "registrable"
+)
+
+// activeDomainStrategy is the strategy selected in the config file.
+var activeDomainStrategy = strategyOrigin
+
+// ignoreScheme treats http:// and https:// origins as the same site when
+// matching domains to aliases.
+var ignoreScheme bool
+
+// stripWWWPrefix removes a leading "www." from hosts during normalization;
+// set by strip_www in the config file.
+var stripWWWPrefix bool
+
+// allowIP accepts IP addresses and localhost as domains; set by --allow-ip.
+// They are rejected by default because they rarely identify a site: the same
+// address is reused by different services, and localhost is a different
+// machine everywhere.
+var allowIP bool
+
+func (s domainStrategy) valid() bool {
+	switch s {
+	case strategyOrigin, strategyHost, strategyRegistrable:
+		return true
+	}
+	return false
+}
+
+// normalizeDomain normalizes user input into the origin stored for new
+// aliases. With the registrable strategy the host is reduced to its
+// registrable domain, e.g. https://shop.example.com -> https://example.com.
+func (s domainStrategy) normalizeDomain(input string) (string, error) {
+	normalized, err := normalizeOrigin(input)
+	if err != nil || s != strategyRegistrable {
+		return normalized, err
+	}
+
+	scheme, _, _ := strings.Cut(normalized, "://")
+	return fmt.Sprintf("%s://%s", scheme, registrableDomain(normalized)), nil
+}
+
+// domainsMatch reports whether two domains refer to the same site under the
+// active strategy.
+func domainsMatch(a, b string) bool {
+	var key func(string) string
+	switch {
+	case activeDomainStrategy == strategyRegistrable:
+		key = registrableDomain
+	case activeDomainStrategy == strategyHost, ignoreScheme:
+		key = hostFromOrigin
+	default:
+		return domainsEqual(a, b)
+	}
+
+	keyA := key(a)
+	return keyA != "" && keyA == key(b)
+}
+
 // normalizeOrigin converts a user-supplied URL or domain into a canonical origin
 // string consisting of "<scheme>://<host>". Paths, queries, ports, fragments,
 // and casing differences are removed. If the input lacks a scheme, https is
 // assumed. Subdomains are preserved so that different subdomains remain unique.
+// IP addresses and localhost are rejected unless allowIP is set; IPv6
+// addresses are kept in brackets, e.g. https://[2001:db8::1]. With
+// stripWWWPrefix, a leading "www." is removed unless what remains is a public
+// suffix, such as "com" in www.com.
 func normalizeOrigin(input string) (string, error) {
 	trimmed := strings.TrimSpace(input)
 	if trimmed == "" {
@@ -21,6 +96,10 @@ func normalizeOrigin(input string) (string, error) {
 	}
 
 	if !strings.Contains(trimmed, "://") {
+		// The colons of a bare IPv6 address would be taken for a port
+		if ip := net.ParseIP(trimmed); ip != nil && strings.Contains(trimmed, ":") {
+			trimmed = "[" + trimmed + "]"
+		}
 		trimmed = defaultScheme + "://" + trimmed
 	}
 
@@ -40,10 +119,33 @@ func normalizeOrigin(input string) (string, error) {
 	}
 
 	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if strings.Contains(host, "%") {
+		return "", fmt.Errorf("invalid domain %q: IPv6 zone identifiers are not supported", input)
+	}
+	if isIPOrLocalhost(host) {
+		if !allowIP {
+			return "", fmt.Errorf("%q is an IP address or localhost, which doesn't identify a site; pass --allow-ip to use it anyway", input)
+		}
+		if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+	} else if stripWWWPrefix {
+		if rest, ok := strings.CutPrefix(host, "www."); ok {
+			if _, err := publicsuffix.EffectiveTLDPlusOne(rest); err == nil {
+				host = rest
+			}
+		}
+	}
 
 	return fmt.Sprintf("%s://%s", scheme, host), nil
 }
 
+// isIPOrLocalhost reports whether a host is an IP address or localhost. The
+// host is expected in lower case and without brackets.
+func isIPOrLocalhost(host string) bool {
+	return net.ParseIP(host) != nil || host == "localhost" || strings.HasSuffix(host, ".localhost")
+}
+
 // domainsEqual compares two domain strings by normalizing them, ignoring any
 // errors from normalization by falling back to a case-insensitive comparison
 // without trailing slashes.
@@ -62,7 +164,7 @@ func domainsEqual(a, b string) bool {
 
 // prepareDomainInput trims the user-provided identifier, ensures it is a domain
 // (not an email address), and returns both the trimmed display value and the
-// normalized domain used for API calls.
+// normalized domain used for API calls, according to the active strategy.
 func prepareDomainInput(input string) (string, string, error) {
 	trimmed := strings.TrimSpace(input)
 	if trimmed == "" {
@@ -72,7 +174,7 @@ func prepareDomainInput(input string) (string, string, error) {
 		return "", "", fmt.Errorf("expected a domain but received an email address: %s", trimmed)
 	}
 
-	normalized, err := normalizeOrigin(trimmed)
+	normalized, err := activeDomainStrategy.normalizeDomain(trimmed)
 	if err != nil {
 		return "", "", err
 	}
@@ -139,3 +241,23 @@ func isSubdomain(candidateHost, rootHost string) bool {
 
 	return strings.HasSuffix(candidate, "."+root)
 }
+
+// registrableDomain returns the registrable domain (public suffix plus one
+// label) for a host or origin, e.g. "shop.example.co.uk" -> "example.co.uk".
+// Hosts that cannot be reduced, including IP addresses and localhost, are
+// returned unchanged.
+func registrableDomain(input string) string {
+	host := hostFromOrigin(input)
+	if host == "" {
+		return ""
+	}
+	if isIPOrLocalhost(strings.Trim(host, "[]")) {
+		return host
+	}
+
+	domain, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return host
+	}
+	return domain
+}
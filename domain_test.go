@@ -114,3 +114,147 @@ func TestNormalizeEmailInput(t *testing.T) {
 		t.Fatalf("normalizeEmailInput should error on domains")
 	}
 }
+
+func TestRegistrableDomain(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"https://shop.example.com", "example.com"},
+		{"mail.example.co.uk", "example.co.uk"},
+		{"https://example.com/login", "example.com"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := registrableDomain(tt.input); got != tt.expected {
+			t.Fatalf("registrableDomain(%q) = %q, want %q", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestDomainStrategies(t *testing.T) {
+	defer func(saved domainStrategy) { activeDomainStrategy = saved }(activeDomainStrategy)
+
+	tests := []struct {
+		strategy   domainStrategy
+		a, b       string
+		match      bool
+		normalized string
+	}{
+		{strategyOrigin, "https://shop.example.com", "https://example.com", false, "https://shop.example.com"},
+		{strategyOrigin, "http://example.com", "https://example.com", false, "https://shop.example.com"},
+		{strategyHost, "http://example.com", "https://example.com", true, "https://shop.example.com"},
+		{strategyHost, "https://shop.example.com", "https://example.com", false, "https://shop.example.com"},
+		{strategyRegistrable, "https://shop.example.com", "https://example.com", true, "https://example.com"},
+		{strategyRegistrable, "https://example.co.uk", "https://example.com", false, "https://example.com"},
+	}
+
+	for _, tt := range tests {
+		activeDomainStrategy = tt.strategy
+		if got := domainsMatch(tt.a, tt.b); got != tt.match {
+			t.Errorf("%s: domainsMatch(%q, %q) = %v, want %v", tt.strategy, tt.a, tt.b, got, tt.match)
+		}
+		_, normalized, err := prepareDomainInput("shop.example.com/login")
+		if err != nil {
+			t.Fatalf("%s: prepareDomainInput returned error: %v", tt.strategy, err)
+		}
+		if normalized != tt.normalized {
+			t.Errorf("%s: prepareDomainInput normalized to %q, want %q", tt.strategy, normalized, tt.normalized)
+		}
+	}
+}
+
+func TestIgnoreScheme(t *testing.T) {
+	defer func(saved bool) { ignoreScheme = saved }(ignoreScheme)
+
+	ignoreScheme = false
+	if aliasMatchesDomain(MaskedEmailInfo{ForDomain: "http://example.com"}, "https://example.com") {
+		t.Fatalf("expected schemes to be distinct by default")
+	}
+
+	ignoreScheme = true
+	if !aliasMatchesDomain(MaskedEmailInfo{ForDomain: "http://example.com"}, "https://example.com") {
+		t.Fatalf("expected http and https to match with ignoreScheme")
+	}
+	if aliasMatchesDomain(MaskedEmailInfo{ForDomain: "http://shop.example.com"}, "https://example.com") {
+		t.Fatalf("expected subdomains to stay distinct with ignoreScheme")
+	}
+
+	primary, related := filterAliasesForList([]MaskedEmailInfo{{ID: "1", ForDomain: "http://example.com"}}, "https://example.com", "example.com")
+	if len(primary) != 1 || len(related) != 0 {
+		t.Fatalf("expected the http alias to be a primary match, got primary=%v related=%v", primary, related)
+	}
+}
+
+func TestNormalizeOriginIPAndLocalhost(t *testing.T) {
+	rejected := []string{"192.168.1.1", "http://10.0.0.1:8080/admin", "localhost", "http://app.localhost:3000", "::1", "[2001:db8::1]"}
+	for _, input := range rejected {
+		if _, err := normalizeOrigin(input); err == nil {
+			t.Errorf("normalizeOrigin(%q) should be rejected without --allow-ip", input)
+		}
+	}
+
+	allowIP = true
+	defer func() { allowIP = false }()
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"192.168.1.1", "https://192.168.1.1"},
+		{"http://10.0.0.1:8080/admin", "http://10.0.0.1"},
+		{"LOCALHOST.", "https://localhost"},
+		{"::1", "https://[::1]"},
+		{"https://[2001:DB8::1]:8443/x", "https://[2001:db8::1]"},
+	}
+	for _, tt := range tests {
+		got, err := normalizeOrigin(tt.input)
+		if err != nil {
+			t.Fatalf("normalizeOrigin(%q) returned error: %v", tt.input, err)
+		}
+		if got != tt.expected {
+			t.Errorf("normalizeOrigin(%q) = %q, want %q", tt.input, got, tt.expected)
+		}
+	}
+
+	if _, err := normalizeOrigin("https://[fe80::1%25eth0]"); err == nil {
+		t.Errorf("expected IPv6 zone identifiers to be rejected")
+	}
+	if got := registrableDomain("https://192.168.1.1"); got != "192.168.1.1" {
+		t.Errorf("registrableDomain should keep IP addresses, got %q", got)
+	}
+	if !domainsEqual("::1", "https://[::1]:8443") {
+		t.Errorf("expected bare and bracketed IPv6 addresses to be equal")
+	}
+}
+
+func TestNormalizeOriginStripWWW(t *testing.T) {
+	stripWWWPrefix = true
+	defer func() { stripWWWPrefix = false }()
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"www.example.com", "https://example.com"},
+		{"http://WWW.Example.com/login", "http://example.com"},
+		{"www.shop.example.co.uk", "https://shop.example.co.uk"},
+		{"shop.www.example.com", "https://shop.www.example.com"},
+		// What would remain is a public suffix
+		{"www.com", "https://www.com"},
+		{"www.co.uk", "https://www.co.uk"},
+	}
+	for _, tt := range tests {
+		got, err := normalizeOrigin(tt.input)
+		if err != nil {
+			t.Fatalf("normalizeOrigin(%q) returned error: %v", tt.input, err)
+		}
+		if got != tt.expected {
+			t.Errorf("normalizeOrigin(%q) = %q, want %q", tt.input, got, tt.expected)
+		}
+	}
+
+	if !domainsMatch("https://www.example.com", "example.com") {
+		t.Error("an alias stored for the www. host should match the bare domain")
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// errorObject is the JSON form of an error printed with --json, so that GUI
+// wrappers and extensions can tell failures apart without parsing messages.
+type errorObject struct {
+	// Type is one of the errorType values
+	Type    string `json:"type"`
+	Message string `json:"message"`
+	// HTTPStatus is the status of the failed API request, if any
+	HTTPStatus int `json:"httpStatus,omitempty"`
+	// JMAPType is the error type returned by the JMAP API, if any
+	JMAPType string `json:"jmapType,omitempty"`
+	ExitCode int    `json:"exitCode"`
+}
+
+// errorType classifies an error for the --json error output.
+func errorType(err error) string {
+	var apiErr *APIError
+	switch {
+	case errors.Is(err, ErrMissingToken) || errors.Is(err, ErrUnauthorized):
+		return "auth"
+	case errors.Is(err, ErrReadOnly):
+		return "readOnly"
+	case errors.Is(err, ErrPinMismatch):
+		return "tlsPin"
+	case errors.Is(err, ErrAliasNotFound):
+		return "notFound"
+	case errors.Is(err, ErrAliasDeleted):
+		return "deleted"
+	case errors.Is(err, ErrProtected):
+		return "protected"
+	case errors.Is(err, ErrCancelled):
+		return "cancelled"
+	case errors.Is(err, ErrNotConfirmed):
+		return "notConfirmed"
+	case errors.Is(err, ErrInterrupted):
+		return "interrupted"
+	case errors.Is(err, ErrResponseTooLarge):
+		return "responseTooLarge"
+	case errors.Is(err, ErrGuardrail):
+		return "guardrail"
+	case errors.As(err, &apiErr):
+		return "api"
+	case errors.Is(err, context.DeadlineExceeded) || isTransportError(err):
+		return "unreachable"
+	case exitCode(err) == exitInvalidInput:
+		return "invalidInput"
+	default:
+		return "error"
+	}
+}
+
+// newErrorObject describes an error for the --json error output.
+func newErrorObject(err error) errorObject {
+	obj := errorObject{
+		Type:     errorType(err),
+		Message:  err.Error(),
+		ExitCode: exitCode(err),
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		obj.HTTPStatus = apiErr.StatusCode
+		obj.JMAPType = apiErr.Type
+	} else if errors.Is(err, ErrUnauthorized) {
+		obj.HTTPStatus = http.StatusUnauthorized
+	}
+	return obj
+}
+
+// printErrorJSON writes the error as one line of JSON:
+// {"error": {"type": ..., "message": ..., "httpStatus": ...}}
+func printErrorJSON(w io.Writer, err error) {
+	data, marshalErr := json.Marshal(struct {
+		Error errorObject `json:"error"`
+	}{newErrorObject(err)})
+	if marshalErr != nil {
+		fmt.Fprintln(w, "Error:", err)
+		return
+	}
+	fmt.Fprintln(w, string(data))
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestPrintErrorJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want errorObject
+	}{
+		{
+			name: "HTTP error",
+			err:  formatAPIError("failed to get aliases", &APIError{StatusCode: 503, Message: "unavailable"}),
+			want: errorObject{Type: "api", Message: "failed to get aliases: Fastmail API returned HTTP 503: unavailable", HTTPStatus: 503, ExitCode: exitFailure},
+		},
+		{
+			name: "JMAP error",
+			err:  formatAPIError("failed to update alias", &APIError{Type: "invalidArguments", Message: "bad state"}),
+			want: errorObject{Type: "api", Message: "failed to update alias: Fastmail API error (invalidArguments): bad state", JMAPType: "invalidArguments", ExitCode: exitFailure},
+		},
+		{
+			name: "rejected token",
+			err:  formatAPIError("failed to get aliases", &APIError{StatusCode: 401}),
+			want: errorObject{Type: "auth", HTTPStatus: 401, ExitCode: exitAuth},
+		},
+		{
+			name: "not found",
+			err:  fmt.Errorf("failed to get alias: %w", ErrAliasNotFound),
+			want: errorObject{Type: "notFound", Message: "failed to get alias: alias not found", ExitCode: exitFailure},
+		},
+		{
+			name: "not confirmed",
+			err:  fmt.Errorf("%w: no terminal to ask on", ErrNotConfirmed),
+			want: errorObject{Type: "notConfirmed", Message: "refusing to go ahead without confirmation: no terminal to ask on", ExitCode: exitFailure},
+		},
+		{
+			name: "invalid input",
+			err:  &exitCodeError{code: exitInvalidInput, err: errors.New("not a domain")},
+			want: errorObject{Type: "invalidInput", Message: "not a domain", ExitCode: exitInvalidInput},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			printErrorJSON(&buf, tt.err)
+			var got struct {
+				Error errorObject `json:"error"`
+			}
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("invalid JSON %q: %v", buf.String(), err)
+			}
+			if tt.want.Message == "" {
+				tt.want.Message = tt.err.Error()
+			}
+			if got.Error != tt.want {
+				t.Errorf("got %+v, want %+v", got.Error, tt.want)
+			}
+		})
+	}
+}
+
+func TestMalformedSitesAreInvalidInput(t *testing.T) {
+	_, client := newFakeJMAP(t)
+	for _, site := range []string{"http://exa mple.com", "https://example.com:99999x", "%zz"} {
+		_, err := handleAliasLookupOrCreation(client, site, nil, false, false)
+		if exitCode(err) != exitInvalidInput || errorType(err) != "invalidInput" {
+			t.Errorf("%q: exit code %d, type %q (%v); want invalid input, not unreachable", site, exitCode(err), errorType(err), err)
+		}
+	}
+}
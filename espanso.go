@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"text/template"
+
+	"github.com/spf13/cobra"
+)
+
+// espansoPackageName is the name of the generated Espanso package, and of its
+// directory in the packages directory of Espanso
+const espansoPackageName = "masked-fastmail"
+
+// espansoPackageVersion is the version of the generated package. Espanso
+// requires one; it changes when the generated matches do.
+const espansoPackageVersion = "1.0.0"
+
+// espansoMatchFile is the name of the match file in the match directory of
+// Espanso
+const espansoMatchFile = "masked_fastmail.yml"
+
+// espansoTemplates are the files of the Espanso package; package.yml is also
+// the match file. They use << >> as delimiters, since Espanso itself uses
+// {{ }}.
+var espansoTemplates = map[string]string{
+	"_manifest.yml": `name: << quote .Name >>
+title: "Masked Fastmail"
+description: "Type << .Trigger >> to get the Fastmail masked email for the URL in the clipboard"
+version: << quote .Version >>
+author: "masked_fastmail"
+`,
+	"package.yml": `# Generated by masked_fastmail integrations espanso. Typing the trigger types the
+# masked email for the URL in the clipboard, creating one if there is none.
+matches:
+  - trigger: << quote .Trigger >>
+    replace: "{{alias}}"
+    vars:
+      - name: url
+        type: clipboard
+      - name: alias
+        type: shell
+        params:
+          cmd: << quote .Command >>
+<<- if .Shell >>
+          shell: << quote .Shell >>
+<<- end >>
+          trim: true
+`,
+	"README.md": `# Masked Fastmail
+
+Copy the URL of a signup page and type ` + "`<< .Trigger >>`" + ` to get its Fastmail masked
+email, created if there is none yet. The package runs masked_fastmail, which
+must be set up with an API token.
+`,
+}
+
+// espansoPackage is the data of the templates.
+type espansoPackage struct {
+	Name    string
+	Version string
+	Trigger string
+	// Command is the shell command printing the alias for the URL in the
+	// clipboard, and Shell the shell running it ("" for the default)
+	Command string
+	Shell   string
+}
+
+// newEspansoCmd creates the command that generates the Espanso match file
+// or package.
+func newEspansoCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "espanso",
+		Short: "Generate an Espanso match that types the alias for the URL in the clipboard",
+		Long: `Generate a match file for the Espanso text expander: typing the trigger types
+the alias for the URL in the clipboard, creating one if there is none, as the
+shortcut command does. Copy the URL of the signup page, then type the trigger
+in the email field.
+
+Without --dir, the match file is printed. With --dir, it is written to
+masked_fastmail.yml in the directory, e.g. the match directory of Espanso. With
+--package, a package is written to the masked-fastmail directory in it
+instead, e.g. the packages directory of Espanso.`,
+		Example: `  masked_fastmail integrations espanso --dir "$(espanso path config)/match"
+  masked_fastmail integrations espanso --package --dir "$(espanso path packages)"`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dir, _ := cmd.Flags().GetString("dir")
+			trigger, _ := cmd.Flags().GetString("trigger")
+			command, _ := cmd.Flags().GetString("command")
+			asPackage, _ := cmd.Flags().GetBool("package")
+			if !strings.HasPrefix(trigger, ":") || strings.ContainsAny(trigger, " \t\n") {
+				return &exitCodeError{code: exitInvalidInput, err: fmt.Errorf("invalid trigger %q: expected e.g. :alias", trigger)}
+			}
+			if asPackage && dir == "" {
+				return &exitCodeError{code: exitInvalidInput, err: fmt.Errorf("--package requires --dir")}
+			}
+			if command == "" {
+				executable, err := os.Executable()
+				if err != nil {
+					return fmt.Errorf("failed to find masked_fastmail: %w; set --command", err)
+				}
+				command = executable
+			}
+			pkg := newEspansoPackage(trigger, command, runtime.GOOS)
+			switch {
+			case asPackage:
+				return writeEspansoPackage(filepath.Join(dir, espansoPackageName), pkg)
+			case dir != "":
+				return writeEspansoMatchFile(filepath.Join(dir, espansoMatchFile), pkg)
+			}
+			return writeEspansoFile(cmd.OutOrStdout(), "package.yml", pkg)
+		},
+	}
+	cmd.Flags().String("dir", "", "directory to write the match file or package to")
+	cmd.Flags().Bool("package", false, "write a package, in a masked-fastmail directory, instead of a match file")
+	cmd.Flags().String("trigger", ":alias", "text that triggers the match")
+	cmd.Flags().String("command", "", "path of masked_fastmail for Espanso to run (default: this executable)")
+	return cmd
+}
+
+// newEspansoPackage returns the package running the executable at path on
+// goos. Espanso runs commands with sh, or PowerShell on Windows, and passes
+// the clipboard in the ESPANSO_URL variable.
+func newEspansoPackage(trigger, path, goos string) espansoPackage {
+	pkg := espansoPackage{Name: espansoPackageName, Version: espansoPackageVersion, Trigger: trigger}
+	if goos == "windows" {
+		pkg.Shell = "powershell"
+		pkg.Command = "& '" + strings.ReplaceAll(path, "'", "''") + "' shortcut $env:ESPANSO_URL"
+	} else {
+		pkg.Command = shellQuote(path) + ` shortcut "$ESPANSO_URL"`
+	}
+	return pkg
+}
+
+// writeEspansoFile writes the file of the package named name to w.
+func writeEspansoFile(w io.Writer, name string, pkg espansoPackage) error {
+	tmpl, err := template.New(name).Delims("<<", ">>").Funcs(template.FuncMap{
+		// JSON strings are valid YAML strings
+		"quote": func(s string) (string, error) {
+			quoted, err := json.Marshal(s)
+			return string(quoted), err
+		},
+	}).Parse(espansoTemplates[name])
+	if err != nil {
+		return err
+	}
+	return tmpl.Execute(w, pkg)
+}
+
+// writeEspansoMatchFile writes the match file to path.
+func writeEspansoMatchFile(path string, pkg espansoPackage) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create the match directory: %w", err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to write the match file: %w", err)
+	}
+	err = writeEspansoFile(f, "package.yml", pkg)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	fmt.Fprintf(os.Stderr, "Wrote the Espanso match file to %s\n", path)
+	return nil
+}
+
+// writeEspansoPackage writes every file of the package to dir.
+func writeEspansoPackage(dir string, pkg espansoPackage) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create the package directory: %w", err)
+	}
+	for name := range espansoTemplates {
+		f, err := os.Create(filepath.Join(dir, name))
+		if err != nil {
+			return fmt.Errorf("failed to write the package: %w", err)
+		}
+		err = writeEspansoFile(f, name, pkg)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Wrote the Espanso package to %s; run \"espanso restart\" to load it\n", dir)
+	return nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// espansoMatches is the part of an Espanso match file the tests check.
+type espansoMatches struct {
+	Matches []struct {
+		Trigger string `yaml:"trigger"`
+		Replace string `yaml:"replace"`
+		Vars    []struct {
+			Name   string            `yaml:"name"`
+			Type   string            `yaml:"type"`
+			Params map[string]string `yaml:"params"`
+		} `yaml:"vars"`
+	} `yaml:"matches"`
+}
+
+func TestEspansoPackage(t *testing.T) {
+	var buf bytes.Buffer
+	pkg := newEspansoPackage(":mail", "/opt/masked fastmail/masked_fastmail", "linux")
+	if err := writeEspansoFile(&buf, "package.yml", pkg); err != nil {
+		t.Fatal(err)
+	}
+	var file espansoMatches
+	if err := yaml.Unmarshal(buf.Bytes(), &file); err != nil {
+		t.Fatalf("invalid YAML: %v\n%s", err, buf.String())
+	}
+	if len(file.Matches) != 1 || len(file.Matches[0].Vars) != 2 {
+		t.Fatalf("unexpected matches:\n%s", buf.String())
+	}
+	match := file.Matches[0]
+	if match.Trigger != ":mail" || match.Replace != "{{alias}}" {
+		t.Errorf("unexpected match %+v", match)
+	}
+	clipboard, shell := match.Vars[0], match.Vars[1]
+	if clipboard.Name != "url" || clipboard.Type != "clipboard" {
+		t.Errorf("unexpected clipboard var %+v", clipboard)
+	}
+	want := `'/opt/masked fastmail/masked_fastmail' shortcut "$ESPANSO_URL"`
+	if shell.Name != "alias" || shell.Type != "shell" || shell.Params["cmd"] != want || shell.Params["shell"] != "" {
+		t.Errorf("unexpected shell var %+v; want the command %s", shell, want)
+	}
+}
+
+func TestEspansoPackageWindows(t *testing.T) {
+	var buf bytes.Buffer
+	pkg := newEspansoPackage(":alias", `C:\Users\o'neil\masked_fastmail.exe`, "windows")
+	if err := writeEspansoFile(&buf, "package.yml", pkg); err != nil {
+		t.Fatal(err)
+	}
+	var file espansoMatches
+	if err := yaml.Unmarshal(buf.Bytes(), &file); err != nil {
+		t.Fatalf("invalid YAML: %v\n%s", err, buf.String())
+	}
+	params := file.Matches[0].Vars[1].Params
+	want := `& 'C:\Users\o''neil\masked_fastmail.exe' shortcut $env:ESPANSO_URL`
+	if params["shell"] != "powershell" || params["cmd"] != want {
+		t.Errorf("unexpected shell params %v; want the command %s", params, want)
+	}
+}
+
+func TestWriteEspansoPackage(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), espansoPackageName)
+	if err := writeEspansoPackage(dir, newEspansoPackage(":alias", "masked_fastmail", "linux")); err != nil {
+		t.Fatal(err)
+	}
+	var manifest map[string]string
+	data, err := os.ReadFile(filepath.Join(dir, "_manifest.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := yaml.Unmarshal(data, &manifest); err != nil {
+		t.Fatalf("invalid manifest: %v\n%s", err, data)
+	}
+	if manifest["name"] != espansoPackageName || manifest["version"] != espansoPackageVersion || manifest["title"] == "" {
+		t.Errorf("unexpected manifest %v", manifest)
+	}
+	for _, name := range []string{"package.yml", "README.md"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestIntegrationsEspansoMatchFile(t *testing.T) {
+	dir := t.TempDir()
+	cmd := newIntegrationsCmd()
+	cmd.SetArgs([]string{"espanso", "--dir", dir, "--trigger", ":mfa", "--command", "/usr/bin/masked_fastmail"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, espansoMatchFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var file espansoMatches
+	if err := yaml.Unmarshal(data, &file); err != nil || len(file.Matches) != 1 || file.Matches[0].Trigger != ":mfa" {
+		t.Errorf("unexpected match file (%v):\n%s", err, data)
+	}
+
+	for _, args := range [][]string{{"espanso", "--trigger", "alias"}, {"espanso", "--package"}} {
+		cmd := newIntegrationsCmd()
+		cmd.SetArgs(args)
+		cmd.SilenceErrors, cmd.SilenceUsage = true, true
+		if err := cmd.Execute(); exitCode(err) != exitInvalidInput {
+			t.Errorf("%v: got %v, want invalid input", args, err)
+		}
+	}
+}
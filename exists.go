@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+// errNoEnabledAlias is the quiet failure of exists
+var errNoEnabledAlias = errors.New("no enabled alias exists")
+
+// newExistsCmd creates the command that checks whether an enabled alias
+// exists, answering with its exit code.
+func newExistsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "exists <domain|alias>",
+		Short: "Exit with 0 if an enabled alias exists for a domain, or an alias is enabled",
+		Long: `Check whether an enabled alias exists for a URL or domain, or whether an alias
+email is enabled, without creating anything. Nothing is printed: the exit
+status is 0 if it does and 1 if it doesn't, so that scripts can set up
+accounts idempotently. Errors, such as a missing API token, print a message
+and exit with the codes below.
+
+` + exitStatusHelp(),
+		Example: `  masked_fastmail exists example.com || masked_fastmail shortcut example.com
+  if masked_fastmail exists shop.1234@fastmail.com; then echo enabled; fi`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			print, _ := cmd.Flags().GetBool("print")
+			client, err := newClientFromCmd(cmd)
+			if err != nil {
+				return err
+			}
+			alias, err := findEnabledAlias(client, args[0])
+			if err != nil {
+				return err
+			}
+			if alias == nil {
+				return &exitCodeError{code: exitFailure, err: errNoEnabledAlias, quiet: true}
+			}
+			if print {
+				fmt.Fprintln(cmd.OutOrStdout(), alias.Email)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().Bool("print", false, "print the enabled alias if there is one")
+	return cmd
+}
+
+// findEnabledAlias returns the alias email if it is enabled, or an enabled
+// alias for the URL or domain, or nil if there is none.
+func findEnabledAlias(client *FastmailClient, input string) (*MaskedEmailInfo, error) {
+	if looksLikeEmail(input) {
+		email, err := normalizeEmailInput(input)
+		if err != nil {
+			return nil, &exitCodeError{code: exitInvalidInput, err: err}
+		}
+		alias, err := client.GetAliasByEmail(email)
+		if errors.Is(err, ErrAliasNotFound) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, formatAPIError("failed to get alias", err)
+		}
+		if alias.State != AliasEnabled {
+			return nil, nil
+		}
+		return alias, nil
+	}
+
+	_, normalizedDomain, err := prepareDomainInput(input)
+	if err != nil {
+		return nil, &exitCodeError{code: exitInvalidInput, err: err}
+	}
+	aliases, err := client.GetAliases(normalizedDomain)
+	if err != nil {
+		return nil, formatAPIError("failed to get aliases", err)
+	}
+	for i := range aliases {
+		if aliases[i].State == AliasEnabled {
+			return &aliases[i], nil
+		}
+	}
+	return nil, nil
+}
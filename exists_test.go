@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFindEnabledAlias(t *testing.T) {
+	_, client := newFakeJMAP(t,
+		MaskedEmailInfo{ID: "a1", Email: "shop.1@fastmail.com", ForDomain: "https://shop.example.com", State: AliasEnabled},
+		MaskedEmailInfo{ID: "a2", Email: "news.2@fastmail.com", ForDomain: "https://news.example.com", State: AliasDisabled},
+	)
+
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"shop.example.com", "shop.1@fastmail.com"},
+		{"https://shop.example.com/login", "shop.1@fastmail.com"},
+		{"news.example.com", ""},
+		{"other.example.com", ""},
+		{"shop.1@fastmail.com", "shop.1@fastmail.com"},
+		{"news.2@fastmail.com", ""},
+		{"missing.3@fastmail.com", ""},
+	}
+	for _, tt := range tests {
+		alias, err := findEnabledAlias(client, tt.input)
+		if err != nil {
+			t.Fatalf("findEnabledAlias(%q) returned error: %v", tt.input, err)
+		}
+		got := ""
+		if alias != nil {
+			got = alias.Email
+		}
+		if got != tt.want {
+			t.Errorf("findEnabledAlias(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+
+	_, err := findEnabledAlias(client, "not a domain")
+	if exitCode(err) != exitInvalidInput {
+		t.Errorf("expected exit code %d for an invalid domain, got %d (%v)", exitInvalidInput, exitCode(err), err)
+	}
+}
+
+func TestQuietExitCode(t *testing.T) {
+	err := error(&exitCodeError{code: exitFailure, err: errNoEnabledAlias, quiet: true})
+	if exitCode(err) != exitFailure {
+		t.Fatalf("expected exit code %d, got %d", exitFailure, exitCode(err))
+	}
+	if !errors.Is(err, errNoEnabledAlias) {
+		t.Fatalf("expected the error to wrap errNoEnabledAlias")
+	}
+}
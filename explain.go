@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// explain prints why the command does what it does before each step; set
+// with --explain
+var explain bool
+
+// explainOutput receives the explanation, separately from the results on
+// stdout
+var explainOutput io.Writer = os.Stderr
+
+// explainf prints a line of the explanation if --explain is set.
+func explainf(format string, args ...interface{}) {
+	if explain {
+		fmt.Fprintf(explainOutput, "explain: %s\n", fmt.Sprintf(format, args...))
+	}
+}
+
+// explainNormalization explains how the input became the domain aliases are
+// matched against.
+func explainNormalization(input, normalized string) {
+	if !explain {
+		return
+	}
+	var settings []string
+	if ignoreScheme {
+		settings = append(settings, "ignore_scheme")
+	}
+	if stripWWWPrefix {
+		settings = append(settings, "strip_www")
+	}
+	detail := ""
+	if len(settings) > 0 {
+		detail = " and " + strings.Join(settings, ", ")
+	}
+	explainf("%q is normalized to %s by the %s domain strategy%s", input, normalized, activeDomainStrategy, detail)
+}
+
+// selectionReasons explain the choice of the selection strategies other than
+// state priority
+var selectionReasons = map[selectionStrategy]string{
+	selectByRecentMail: "it received mail most recently",
+	selectNewest:       "it was created last",
+	selectOldest:       "it was created first",
+	selectInteractive:  "it was chosen",
+}
+
+// explainSelection explains which of the matching aliases is used and why.
+// By state priority, states rank enabled, pending, disabled, deleted, and
+// the first alias Fastmail returned wins among aliases in the same state.
+func explainSelection(domain string, matching []MaskedEmailInfo, selected *MaskedEmailInfo) {
+	if !explain {
+		return
+	}
+	if len(matching) == 0 {
+		explainf("no alias matches %s (deleted aliases are never used)", domain)
+		return
+	}
+	explainf("%s %s %s:", plural(len(matching), "alias", "aliases"), choose(len(matching) == 1, "matches", "match"), domain)
+	for _, alias := range matching {
+		explainf("  %s %s (rank %d), for %s", alias.Email, alias.State, getStatePriority(alias.State)+1, describeAliasOrigin(alias))
+	}
+	if len(matching) == 1 {
+		explainf("%s is the only match", selected.Email)
+		return
+	}
+	if reason, ok := selectionReasons[activeSelection]; ok {
+		explainf("selected %s: %s (selection strategy %s)", selected.Email, reason, activeSelection)
+		return
+	}
+	ties := 0
+	for _, alias := range matching {
+		if alias.State == selected.State {
+			ties++
+		}
+	}
+	reason := fmt.Sprintf("%s ranks above the other states (enabled > pending > disabled > deleted)", selected.State)
+	if ties == len(matching) {
+		reason = fmt.Sprintf("all are %s, and the first one Fastmail returned is used", selected.State)
+	} else if ties > 1 {
+		reason += fmt.Sprintf("; of the %d %s aliases, the first one Fastmail returned is used", ties, selected.State)
+	}
+	explainf("selected %s: %s", selected.Email, reason)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func captureExplain(t *testing.T, fn func()) string {
+	t.Helper()
+	var buf bytes.Buffer
+	explain, explainOutput = true, &buf
+	defer func() { explain, explainOutput = false, os.Stderr }()
+	fn()
+	return buf.String()
+}
+
+func TestExplainSelection(t *testing.T) {
+	matching := []MaskedEmailInfo{
+		{Email: "a@fastmail.com", State: AliasDisabled, ForDomain: "https://example.com"},
+		{Email: "b@fastmail.com", State: AliasEnabled, ForDomain: "https://example.com"},
+		{Email: "c@fastmail.com", State: AliasEnabled, ForDomain: "https://example.com"},
+	}
+	selected := selectPreferredAlias(matching)
+	out := captureExplain(t, func() { explainSelection("https://example.com", matching, selected) })
+
+	for _, want := range []string{
+		"3 aliases match https://example.com",
+		"a@fastmail.com disabled (rank 3)",
+		"selected b@fastmail.com: enabled ranks above the other states",
+		"of the 2 enabled aliases, the first one Fastmail returned is used",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("explanation doesn't contain %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestExplainSelectionNoMatch(t *testing.T) {
+	out := captureExplain(t, func() { explainSelection("https://example.com", nil, nil) })
+	if !strings.Contains(out, "no alias matches https://example.com") {
+		t.Errorf("unexpected explanation:\n%s", out)
+	}
+}
+
+func TestExplainfIsQuietByDefault(t *testing.T) {
+	var buf bytes.Buffer
+	explainOutput = &buf
+	defer func() { explainOutput = os.Stderr }()
+	explainf("fetching")
+	if buf.Len() != 0 {
+		t.Errorf("explainf wrote %q without --explain", buf.String())
+	}
+}
+
+func TestExplainLookupOrCreation(t *testing.T) {
+	_, client := newFakeJMAP(t)
+	out := captureExplain(t, func() {
+		captureStdout(t, func() {
+			if _, err := handleAliasLookupOrCreation(client, "Example.com", nil, false, true); err != nil {
+				t.Fatal(err)
+			}
+		})
+	})
+	for _, want := range []string{`"Example.com" is normalized to https://example.com`, "creating an alias for https://example.com"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("explanation doesn't contain %q:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,420 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// exportFormats lists the supported export formats
+var exportFormats = []string{"json", "ndjson", "csv", "html"}
+
+// exportFields are the alias fields --fields can select, in the order of
+// the default CSV columns
+var exportFields = []string{"id", "email", "state", "forDomain", "description", "createdAt", "lastMessageAt", "createdBy", "url"}
+
+// defaultCSVFields are the columns of a CSV export without --fields
+var defaultCSVFields = exportFields[:7]
+
+// aliasExport is the document written by `export --format json`.
+type aliasExport struct {
+	ExportedAt time.Time         `json:"exportedAt"`
+	AccountID  string            `json:"accountId"`
+	Aliases    []MaskedEmailInfo `json:"aliases"`
+	// Fields are the alias fields to write, selected with --fields; empty
+	// writes all of them
+	Fields []string `json:"-"`
+}
+
+// fieldRecord is an alias reduced to some fields, encoded as a JSON object
+// with the fields in order.
+type fieldRecord struct {
+	alias  MaskedEmailInfo
+	fields []string
+}
+
+func (r fieldRecord) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, field := range r.fields {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(field)
+		var value interface{} = exportFieldValue(r.alias, field)
+		if value == "" && (field == "createdAt" || field == "lastMessageAt") {
+			value = nil
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(encoded)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// newExportCmd creates the command that exports the full alias inventory.
+func newExportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export all aliases as JSON, CSV or a self-contained HTML page",
+		Long: `Export all aliases as JSON, CSV or a self-contained HTML page. The ndjson
+format, also selected with --output ndjson, writes one JSON object per alias
+and line, for tools such as jq that process results as they arrive.`,
+		Example: `  masked_fastmail export --file aliases.json
+  masked_fastmail export --format html --file aliases.html
+  masked_fastmail export --format csv --fields email,state,forDomain,lastMessageAt
+
+  # Aliases created in 2023 that never received mail:
+  masked_fastmail export --created-after 2023-01-01 --created-before 2024-01-01 --last-message-before 2023-01-01
+  masked_fastmail export --output ndjson | jq -r 'select(.state == "disabled") | .email'`,
+		Args:        cobra.NoArgs,
+		Annotations: map[string]string{outputAnnotation: outputNDJSON},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			format, _ := cmd.Flags().GetString("format")
+			if outputFormat == outputNDJSON {
+				if cmd.Flags().Changed("format") && format != "ndjson" {
+					return fmt.Errorf("--output ndjson cannot be combined with --format %s", format)
+				}
+				format = "ndjson"
+			}
+			file, _ := cmd.Flags().GetString("file")
+			dates, err := dateFilterFromCmd(cmd)
+			if err != nil {
+				return err
+			}
+			fieldList, _ := cmd.Flags().GetString("fields")
+			fields, err := parseExportFields(fieldList)
+			if err != nil {
+				return err
+			}
+			client, err := newClientFromCmd(cmd)
+			if err != nil {
+				return err
+			}
+			return handleExport(client, format, file, dates, fields)
+		},
+	}
+	cmd.Flags().String("format", "json", "export format: "+strings.Join(exportFormats, ", "))
+	cmd.Flags().String("fields", "", "comma-separated alias fields to export, in order: "+strings.Join(exportFields, ", "))
+	cmd.Flags().StringP("file", "f", "", "write the export to this file instead of stdout")
+	addDateFilterFlags(cmd)
+	return cmd
+}
+
+// handleExport writes every alias of the account matching the date filter in
+// the requested format, with only the given fields if there are any.
+func handleExport(client *FastmailClient, format string, file string, dates dateFilter, fields []string) error {
+	format = strings.ToLower(strings.TrimSpace(format))
+	if !isExportFormat(format) {
+		return fmt.Errorf("unknown export format %q (expected one of: %s)", format, strings.Join(exportFormats, ", "))
+	}
+	if len(fields) > 0 && format == "html" {
+		return fmt.Errorf("--fields cannot be combined with --format html")
+	}
+
+	aliases, err := fetchAllAliasesWithProgress(client)
+	if err != nil {
+		return formatAPIError("failed to export aliases", err)
+	}
+	aliases = dates.filter(aliases)
+	sortAliasesForExport(aliases)
+
+	export := aliasExport{
+		ExportedAt: time.Now().UTC().Truncate(time.Second),
+		AccountID:  client.AccountID,
+		Aliases:    aliases,
+		Fields:     fields,
+	}
+
+	if file == "" {
+		return writeExport(os.Stdout, format, export)
+	}
+
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, privateFileMode)
+	if err != nil {
+		return fmt.Errorf("failed to create export file: %w", err)
+	}
+	if err := writeExport(f, format, export); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Exported %d aliases to %s\n", len(aliases), file)
+	return nil
+}
+
+// parseExportFields reads the --fields list. Field names are matched
+// without regard to case, and an unknown one is reported with the closest
+// known name.
+func parseExportFields(list string) ([]string, error) {
+	if strings.TrimSpace(list) == "" {
+		return nil, nil
+	}
+	var fields []string
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			return nil, fmt.Errorf("--fields has an empty field name")
+		}
+		field, ok := "", false
+		for _, known := range exportFields {
+			if strings.EqualFold(name, known) {
+				field, ok = known, true
+				break
+			}
+		}
+		if !ok {
+			return nil, fmt.Errorf("unknown field %q%s (known fields: %s)", name, closestField(name), strings.Join(exportFields, ", "))
+		}
+		if seen[field] {
+			return nil, fmt.Errorf("--fields lists %s more than once", field)
+		}
+		seen[field] = true
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// closestField suggests the known field closest to a misspelt one, if any is
+// close enough to be meant.
+func closestField(name string) string {
+	best, bestDistance := "", 3
+	for _, known := range exportFields {
+		if distance := editDistance(strings.ToLower(name), strings.ToLower(known)); distance < bestDistance {
+			best, bestDistance = known, distance
+		}
+	}
+	if best == "" {
+		return ""
+	}
+	return fmt.Sprintf("; did you mean %s?", best)
+}
+
+// exportFieldValue returns a field of an alias as written to a CSV export.
+func exportFieldValue(alias MaskedEmailInfo, field string) string {
+	switch field {
+	case "id":
+		return alias.ID
+	case "email":
+		return alias.Email
+	case "state":
+		return string(alias.State)
+	case "forDomain":
+		return alias.ForDomain
+	case "description":
+		return alias.Description
+	case "createdBy":
+		return alias.CreatedBy
+	case "url":
+		return alias.URL
+	case "createdAt":
+		return formatExportTime(&alias.CreatedAt)
+	case "lastMessageAt":
+		return formatExportTime(alias.LastMessageAt)
+	}
+	return ""
+}
+
+func isExportFormat(format string) bool {
+	for _, known := range exportFormats {
+		if format == known {
+			return true
+		}
+	}
+	return false
+}
+
+// sortAliasesForExport orders aliases by domain, then email, so that exports
+// of the same account are stable and easy to compare.
+func sortAliasesForExport(aliases []MaskedEmailInfo) {
+	sort.SliceStable(aliases, func(i, j int) bool {
+		if aliases[i].ForDomain != aliases[j].ForDomain {
+			return aliases[i].ForDomain < aliases[j].ForDomain
+		}
+		return aliases[i].Email < aliases[j].Email
+	})
+}
+
+// writeExport renders the export in the given format.
+func writeExport(w io.Writer, format string, export aliasExport) error {
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if len(export.Fields) == 0 {
+			return encoder.Encode(export)
+		}
+		return encoder.Encode(struct {
+			ExportedAt time.Time     `json:"exportedAt"`
+			AccountID  string        `json:"accountId"`
+			Aliases    []fieldRecord `json:"aliases"`
+		}{export.ExportedAt, export.AccountID, fieldRecords(export)})
+	case "ndjson":
+		encoder := json.NewEncoder(w)
+		if len(export.Fields) > 0 {
+			for _, record := range fieldRecords(export) {
+				if err := encoder.Encode(record); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		for _, alias := range export.Aliases {
+			if err := encoder.Encode(alias); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "csv":
+		fields := export.Fields
+		if len(fields) == 0 {
+			fields = defaultCSVFields
+		}
+		return writeCSVExport(w, export.Aliases, fields)
+	case "html":
+		return writeHTMLExport(w, export)
+	}
+	return fmt.Errorf("unknown export format %q", format)
+}
+
+// fieldRecords returns the aliases of the export reduced to its fields.
+func fieldRecords(export aliasExport) []fieldRecord {
+	records := make([]fieldRecord, 0, len(export.Aliases))
+	for _, alias := range export.Aliases {
+		records = append(records, fieldRecord{alias: alias, fields: export.Fields})
+	}
+	return records
+}
+
+// writeCSVExport writes one row per alias with the fields as columns, after a
+// header row.
+func writeCSVExport(w io.Writer, aliases []MaskedEmailInfo, fields []string) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(fields); err != nil {
+		return err
+	}
+	for _, alias := range aliases {
+		record := make([]string, 0, len(fields))
+		for _, field := range fields {
+			record = append(record, exportFieldValue(alias, field))
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// formatExportTime renders a timestamp as RFC 3339, or an empty string if unset.
+func formatExportTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// writeHTMLExport writes a self-contained HTML page with a sortable,
+// searchable table of all aliases.
+func writeHTMLExport(w io.Writer, export aliasExport) error {
+	tmpl, err := template.New("export").Funcs(template.FuncMap{
+		"timestamp": func(v interface{}) string {
+			switch t := v.(type) {
+			case time.Time:
+				return formatExportTime(&t)
+			case *time.Time:
+				return formatExportTime(t)
+			}
+			return ""
+		},
+	}).Parse(htmlExportTemplate)
+	if err != nil {
+		return err
+	}
+	return tmpl.Execute(w, export)
+}
+
+const htmlExportTemplate = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Masked email aliases</title>
+<style>
+  body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2rem; color: #1f2328; }
+  h1 { font-size: 1.4rem; margin-bottom: 0.2rem; }
+  p.meta { color: #656d76; margin-top: 0; }
+  input[type=search] { width: 100%; max-width: 28rem; padding: 0.4rem 0.6rem; margin: 1rem 0; font-size: 1rem; }
+  table { border-collapse: collapse; width: 100%; font-size: 0.9rem; }
+  th, td { text-align: left; padding: 0.4rem 0.6rem; border-bottom: 1px solid #d0d7de; vertical-align: top; }
+  th { cursor: pointer; user-select: none; background: #f6f8fa; position: sticky; top: 0; }
+  th[aria-sort=ascending]::after { content: " \25B2"; }
+  th[aria-sort=descending]::after { content: " \25BC"; }
+  td.state { text-transform: capitalize; }
+  tr.deleted, tr.disabled { color: #8c959f; }
+  @media print { input[type=search] { display: none; } th { position: static; } }
+</style>
+</head>
+<body>
+<h1>Masked email aliases</h1>
+<p class="meta">{{len .Aliases}} aliases in account {{.AccountID}}, exported {{timestamp .ExportedAt}}</p>
+<input type="search" id="search" placeholder="Search aliases, domains and descriptions" aria-label="Search">
+<table id="aliases">
+<thead>
+<tr><th>Email</th><th>State</th><th>Domain</th><th>Description</th><th>Created</th><th>Last message</th></tr>
+</thead>
+<tbody>
+{{- range .Aliases}}
+<tr class="{{.State}}"><td>{{.Email}}</td><td class="state">{{.State}}</td><td>{{.ForDomain}}</td><td>{{.Description}}</td><td>{{timestamp .CreatedAt}}</td><td>{{timestamp .LastMessageAt}}</td></tr>
+{{- end}}
+</tbody>
+</table>
+<script>
+(function () {
+  var table = document.getElementById("aliases");
+  var body = table.tBodies[0];
+  var headers = table.tHead.rows[0].cells;
+
+  document.getElementById("search").addEventListener("input", function (event) {
+    var needle = event.target.value.toLowerCase();
+    Array.prototype.forEach.call(body.rows, function (row) {
+      row.hidden = needle !== "" && row.textContent.toLowerCase().indexOf(needle) === -1;
+    });
+  });
+
+  Array.prototype.forEach.call(headers, function (header, column) {
+    header.addEventListener("click", function () {
+      var ascending = header.getAttribute("aria-sort") !== "ascending";
+      Array.prototype.forEach.call(headers, function (h) { h.removeAttribute("aria-sort"); });
+      header.setAttribute("aria-sort", ascending ? "ascending" : "descending");
+      var rows = Array.prototype.slice.call(body.rows);
+      rows.sort(function (a, b) {
+        var x = a.cells[column].textContent, y = b.cells[column].textContent;
+        return ascending ? x.localeCompare(y) : y.localeCompare(x);
+      });
+      rows.forEach(function (row) { body.appendChild(row); });
+    });
+  });
+})();
+</script>
+</body>
+</html>
+`
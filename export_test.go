@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testExport() aliasExport {
+	created := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	return aliasExport{
+		ExportedAt: created,
+		AccountID:  "u123",
+		Aliases: []MaskedEmailInfo{
+			{ID: "1", Email: "one@fastmail.com", State: AliasEnabled, ForDomain: "https://example.com", Description: `Shop, "main"`, CreatedAt: created},
+			{ID: "2", Email: "two@fastmail.com", State: AliasDisabled, Description: "<script>alert(1)</script>"},
+		},
+	}
+}
+
+func TestWriteCSVExport(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeExport(&buf, "csv", testExport()); err != nil {
+		t.Fatalf("writeExport returned error: %v", err)
+	}
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("export is not valid CSV: %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("expected header and two rows, got %d records", len(records))
+	}
+	if records[1][4] != `Shop, "main"` {
+		t.Fatalf("expected description to round-trip, got %q", records[1][4])
+	}
+	if records[1][5] != "2024-06-01T12:00:00Z" || records[2][5] != "" {
+		t.Fatalf("unexpected createdAt values %q and %q", records[1][5], records[2][5])
+	}
+}
+
+func TestWriteNDJSONExport(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeExport(&buf, "ndjson", testExport()); err != nil {
+		t.Fatalf("writeExport returned error: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected one line per alias, got %q", buf.String())
+	}
+	var alias MaskedEmailInfo
+	if err := json.Unmarshal([]byte(lines[1]), &alias); err != nil {
+		t.Fatalf("line is not a JSON object: %v", err)
+	}
+	if alias.Email != "two@fastmail.com" || alias.State != AliasDisabled {
+		t.Fatalf("unexpected alias %+v", alias)
+	}
+}
+
+func TestWriteHTMLExport(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeExport(&buf, "html", testExport()); err != nil {
+		t.Fatalf("writeExport returned error: %v", err)
+	}
+
+	page := buf.String()
+	if !strings.Contains(page, "one@fastmail.com") {
+		t.Fatalf("expected aliases in HTML export")
+	}
+	if strings.Contains(page, "<script>alert(1)</script>") {
+		t.Fatalf("expected descriptions to be HTML-escaped")
+	}
+}
+
+func TestSortAliasesForExport(t *testing.T) {
+	aliases := []MaskedEmailInfo{
+		{Email: "b@fastmail.com", ForDomain: "https://b.com"},
+		{Email: "z@fastmail.com", ForDomain: "https://a.com"},
+		{Email: "a@fastmail.com", ForDomain: "https://a.com"},
+	}
+	sortAliasesForExport(aliases)
+	if aliases[0].Email != "a@fastmail.com" || aliases[2].Email != "b@fastmail.com" {
+		t.Fatalf("unexpected export order: %+v", aliases)
+	}
+}
+
+func TestParseExportFields(t *testing.T) {
+	fields, err := parseExportFields(" Email,state , forDomain,lastMessageAt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(fields, ",") != "email,state,forDomain,lastMessageAt" {
+		t.Errorf("fields = %v", fields)
+	}
+	if fields, err := parseExportFields(""); err != nil || fields != nil {
+		t.Errorf("an empty list should select all fields, got %v, %v", fields, err)
+	}
+
+	tests := map[string]string{
+		"email,domain":  `unknown field "domain"`,
+		"email,lastMsg": "known fields: id, email",
+		"emial":         "did you mean email?",
+		"email,,state":  "empty field name",
+		"email,EMAIL":   "more than once",
+	}
+	for list, want := range tests {
+		if _, err := parseExportFields(list); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("parseExportFields(%q) error = %v, want it to contain %q", list, err, want)
+		}
+	}
+}
+
+func TestWriteExportWithFields(t *testing.T) {
+	export := testExport()
+	export.Fields = []string{"email", "lastMessageAt", "state"}
+
+	var buf bytes.Buffer
+	if err := writeExport(&buf, "csv", export); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(records[0], ",") != "email,lastMessageAt,state" || strings.Join(records[2], ",") != "two@fastmail.com,,disabled" {
+		t.Errorf("unexpected CSV %v", records)
+	}
+
+	buf.Reset()
+	if err := writeExport(&buf, "ndjson", export); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"email":"one@fastmail.com","lastMessageAt":null,"state":"enabled"}`
+	if line := strings.SplitN(buf.String(), "\n", 2)[0]; line != want {
+		t.Errorf("ndjson line = %s, want %s", line, want)
+	}
+
+	buf.Reset()
+	if err := writeExport(&buf, "json", export); err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		AccountID string                   `json:"accountId"`
+		Aliases   []map[string]interface{} `json:"aliases"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("export is not valid JSON: %v", err)
+	}
+	if doc.AccountID != "u123" || len(doc.Aliases) != 2 || len(doc.Aliases[0]) != 3 {
+		t.Errorf("unexpected document %+v", doc)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+var (
+	// urlPattern finds http and https URLs in text
+	urlPattern = regexp.MustCompile(`(?i)\bhttps?://[^\s<>"'()\[\]{}]+`)
+	// hostPattern finds bare host names such as shop.example.com, and the
+	// domains of email addresses
+	hostPattern = regexp.MustCompile(`(?i)\b(?:[a-z0-9](?:[a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z]{2,63}\b`)
+)
+
+// mailProviderDomains are registrable domains that appear in mail about any
+// site, such as the alias itself, and are never the site
+var mailProviderDomains = map[string]bool{
+	"fastmail.com":        true,
+	"fastmail.fm":         true,
+	"messagingengine.com": true,
+}
+
+// extractDomains finds the sites mentioned in text, such as a signup
+// confirmation or a terms of service page: the hosts of URLs, bare host
+// names and the domains of email addresses. Only hosts under a public
+// suffix count, which leaves out file names such as terms.pdf. Each
+// registrable domain is returned once, as the host closest to it, in the
+// order the domains first appear.
+func extractDomains(text string) []string {
+	var hosts []string
+	for _, match := range urlPattern.FindAllString(text, -1) {
+		hosts = append(hosts, looseHostname(match))
+	}
+	// Hosts inside the URLs found above are found again; that's harmless
+	hosts = append(hosts, hostPattern.FindAllString(text, -1)...)
+
+	var domains []string
+	index := make(map[string]int)
+	for _, host := range hosts {
+		host, _, _ = strings.Cut(strings.ToLower(host), ":")
+		host = strings.TrimSuffix(host, ".")
+		if host == "" || !isSiteHost(host) {
+			continue
+		}
+		site := registrableDomain(host)
+		if mailProviderDomains[site] {
+			continue
+		}
+		i, seen := index[site]
+		switch {
+		case !seen:
+			index[site] = len(domains)
+			domains = append(domains, host)
+		case strings.Count(host, ".") < strings.Count(domains[i], "."):
+			domains[i] = host
+		}
+	}
+	return domains
+}
+
+// isSiteHost reports whether a host is under a public suffix run by a
+// registry, so that it can be a site.
+func isSiteHost(host string) bool {
+	if isIPOrLocalhost(host) {
+		return false
+	}
+	suffix, icann := publicsuffix.PublicSuffix(host)
+	return icann && suffix != host
+}
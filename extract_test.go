@@ -0,0 +1,27 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtractDomains(t *testing.T) {
+	text := `Welcome to Example Shop!
+
+Confirm your address at https://accounts.example.com/confirm?token=abc, or
+visit example.com. Questions? Write to support@help.example.com.
+
+This mail was sent to shop.1234@fastmail.com. Our partner pay-provider.co.uk
+processes payments; see terms.pdf, http://127.0.0.1:8080/x and www.partner.io:443.`
+
+	want := []string{"example.com", "pay-provider.co.uk", "www.partner.io"}
+	if got := extractDomains(text); !reflect.DeepEqual(got, want) {
+		t.Errorf("extractDomains() = %v, want %v", got, want)
+	}
+}
+
+func TestExtractDomainsEmpty(t *testing.T) {
+	if got := extractDomains("nothing to see here, v1.2 and report.docx"); len(got) != 0 {
+		t.Errorf("extractDomains() = %v, want none", got)
+	}
+}
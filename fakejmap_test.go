@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+)
+
+// fakeJMAP is an in-memory Fastmail API serving the session and the
+// MaskedEmail methods, for testing handlers end to end.
+type fakeJMAP struct {
+	mu      sync.Mutex
+	aliases map[string]*MaskedEmailInfo
+	// maxSet is the maxObjectsInSet limit of the session
+	maxSet int
+	// requests counts the API requests, excluding the session
+	requests int
+	created  int
+}
+
+// redirectTransport sends all requests to the fake server.
+type redirectTransport struct {
+	target *url.URL
+	next   http.RoundTripper
+}
+
+func (rt redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	redirected := req.Clone(req.Context())
+	redirected.URL.Scheme = rt.target.Scheme
+	redirected.URL.Host = rt.target.Host
+	return rt.next.RoundTrip(redirected)
+}
+
+// newFakeJMAP starts a fake API with the aliases and returns it with a
+// client using it. The data directory is a temporary one.
+func newFakeJMAP(t *testing.T, aliases ...MaskedEmailInfo) (*fakeJMAP, *FastmailClient) {
+	t.Helper()
+	t.Setenv(dataDirEnv, t.TempDir())
+	fake := &fakeJMAP{aliases: make(map[string]*MaskedEmailInfo), maxSet: 50}
+	for i := range aliases {
+		alias := aliases[i]
+		fake.aliases[alias.ID] = &alias
+	}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	target, _ := url.Parse(server.URL)
+
+	client := &FastmailClient{
+		AccountID: "u1",
+		Token:     "token",
+		client:    &http.Client{Transport: redirectTransport{target: target, next: http.DefaultTransport}},
+	}
+	return fake, client
+}
+
+// withTransport wraps the transport of the client, e.g. to inject failures.
+func withTransport(client *FastmailClient, wrap func(http.RoundTripper) http.RoundTripper) {
+	client.client.Transport = wrap(client.client.Transport)
+}
+
+func (f *fakeJMAP) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method == http.MethodGet {
+		fmt.Fprintf(w, `{"username":"me@example.com","apiUrl":%q,"state":"s1",
+			"capabilities":{"urn:ietf:params:jmap:core":{"maxObjectsInSet":%d,"maxCallsInRequest":16}},
+			"accounts":{"u1":{"name":"me@example.com","isPersonal":true,"accountCapabilities":{%q:{}}}},
+			"primaryAccounts":{%q:"u1"}}`, apiURL, f.maxSet, maskedEmailNamespace, maskedEmailNamespace)
+		return
+	}
+	f.requests++
+
+	body, _ := io.ReadAll(r.Body)
+	var request struct {
+		MethodCalls [][]json.RawMessage `json:"methodCalls"`
+	}
+	if err := json.Unmarshal(body, &request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var responses [][]interface{}
+	createdIDs := make(map[string][]string)
+	for _, call := range request.MethodCalls {
+		var name, callID string
+		var args map[string]json.RawMessage
+		json.Unmarshal(call[0], &name)
+		json.Unmarshal(call[1], &args)
+		json.Unmarshal(call[2], &callID)
+
+		switch name {
+		case methodGet:
+			responses = append(responses, []interface{}{name, map[string]interface{}{"list": f.get(args, createdIDs)}, callID})
+		case methodSet:
+			responses = append(responses, []interface{}{name, f.set(args, callID, createdIDs), callID})
+		default:
+			responses = append(responses, []interface{}{"error", map[string]string{"type": "unknownMethod"}, callID})
+		}
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"methodResponses": responses, "sessionState": "s1"})
+}
+
+// get returns the aliases with the requested IDs, or all of them.
+func (f *fakeJMAP) get(args map[string]json.RawMessage, createdIDs map[string][]string) []*MaskedEmailInfo {
+	var ids []string
+	if raw, ok := args["#ids"]; ok {
+		var ref resultReference
+		json.Unmarshal(raw, &ref)
+		ids = append([]string{}, createdIDs[ref.ResultOf]...)
+	} else if raw, ok := args["ids"]; ok {
+		json.Unmarshal(raw, &ids)
+	}
+
+	list := []*MaskedEmailInfo{}
+	for _, alias := range f.aliases {
+		if ids == nil || containsID(ids, alias.ID) {
+			list = append(list, alias)
+		}
+	}
+	return list
+}
+
+// set applies the creations and updates of a MaskedEmail/set.
+func (f *fakeJMAP) set(args map[string]json.RawMessage, callID string, createdIDs map[string][]string) map[string]interface{} {
+	var create map[string]MaskedEmailCreate
+	var update map[string]MaskedEmailUpdate
+	var destroy []string
+	json.Unmarshal(args["create"], &create)
+	json.Unmarshal(args["update"], &update)
+	json.Unmarshal(args["destroy"], &destroy)
+
+	created := make(map[string]interface{})
+	for creationID, c := range create {
+		f.created++
+		alias := &MaskedEmailInfo{
+			ID:          fmt.Sprintf("new%d", f.created),
+			Email:       fmt.Sprintf("new%d@fastmail.com", f.created),
+			ForDomain:   c.ForDomain,
+			Description: c.Description,
+			State:       AliasPending,
+		}
+		if c.State != "" {
+			alias.State = c.State
+		}
+		f.aliases[alias.ID] = alias
+		created[creationID] = map[string]string{"id": alias.ID, "email": alias.Email}
+		createdIDs[callID] = append(createdIDs[callID], alias.ID)
+	}
+
+	updated := make(map[string]interface{})
+	notUpdated := make(map[string]interface{})
+	for id, u := range update {
+		alias, ok := f.aliases[id]
+		if !ok {
+			notUpdated[id] = map[string]string{"type": "notFound"}
+			continue
+		}
+		if u.State != nil {
+			alias.State = *u.State
+		}
+		if u.Description != nil {
+			alias.Description = *u.Description
+		}
+		updated[id] = nil
+	}
+
+	destroyed := []string{}
+	notDestroyed := make(map[string]interface{})
+	for _, id := range destroy {
+		if _, ok := f.aliases[id]; !ok {
+			notDestroyed[id] = map[string]string{"type": "notFound"}
+			continue
+		}
+		delete(f.aliases, id)
+		destroyed = append(destroyed, id)
+	}
+	return map[string]interface{}{"created": created, "updated": updated, "notUpdated": notUpdated, "destroyed": destroyed, "notDestroyed": notDestroyed}
+}
+
+// state returns the state of an alias of the fake API.
+func (f *fakeJMAP) state(id string) AliasState {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.aliases[id].State
+}
+
+func containsID(ids []string, id string) bool {
+	for _, candidate := range ids {
+		if candidate == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cobra"
+)
+
+// Failures injected by --inject-failure
+const (
+	// failureRateLimit answers an API request with HTTP 429
+	failureRateLimit = "rate-limit"
+	// failureTimeout holds an API request until it times out
+	failureTimeout = "timeout"
+	// failurePartialSet reports half the updates of a MaskedEmail/set as
+	// failed, after applying them all
+	failurePartialSet = "partial-set"
+)
+
+var failureKinds = []string{failureRateLimit, failureTimeout, failurePartialSet}
+
+// faultInjector is an http.RoundTripper failing API requests on purpose, to
+// exercise the error paths of the CLI against the real API or a replay. Each
+// pending failure is injected once, into the first request it applies to.
+type faultInjector struct {
+	next http.RoundTripper
+
+	mu      sync.Mutex
+	pending []string
+}
+
+// take removes the first pending failure of one of the kinds, returning it
+// or "" if there is none.
+func (f *faultInjector) take(kinds ...string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, pending := range f.pending {
+		for _, kind := range kinds {
+			if pending == kind {
+				f.pending = append(f.pending[:i], f.pending[i+1:]...)
+				return kind
+			}
+		}
+	}
+	return ""
+}
+
+func (f *faultInjector) RoundTrip(req *http.Request) (*http.Response, error) {
+	// The session request is left alone, so that failures hit API calls
+	if req.Method != http.MethodPost {
+		return f.next.RoundTrip(req)
+	}
+
+	switch f.take(failureRateLimit, failureTimeout) {
+	case failureRateLimit:
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		body := "Too many requests (injected failure)"
+		return &http.Response{
+			Status:        "429 Too Many Requests",
+			StatusCode:    http.StatusTooManyRequests,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        http.Header{"Content-Type": {"text/plain"}, "Retry-After": {"30"}},
+			Body:          io.NopCloser(strings.NewReader(body)),
+			ContentLength: int64(len(body)),
+			Request:       req,
+		}, nil
+	case failureTimeout:
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		<-req.Context().Done()
+		return nil, req.Context().Err()
+	}
+
+	resp, err := f.next.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+	return f.failSetUpdates(resp)
+}
+
+// failSetUpdates moves half the updated aliases of the MaskedEmail/set
+// responses into notUpdated, if a partial-set failure is pending.
+func (f *faultInjector) failSetUpdates(resp *http.Response) (*http.Response, error) {
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+
+	body, err := decodedBody(data, resp.Header.Get("Content-Encoding"))
+	if err != nil {
+		return resp, nil
+	}
+	var response JMAPResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return resp, nil
+	}
+
+	changed := false
+	for _, call := range response.MethodResponses {
+		var name string
+		if len(call) < 2 || json.Unmarshal(call[0], &name) != nil || name != methodSet {
+			continue
+		}
+		var args map[string]json.RawMessage
+		var updated, notUpdated map[string]json.RawMessage
+		if json.Unmarshal(call[1], &args) != nil || json.Unmarshal(args["updated"], &updated) != nil || len(updated) == 0 {
+			continue
+		}
+		if f.take(failurePartialSet) == "" {
+			break
+		}
+		_ = json.Unmarshal(args["notUpdated"], &notUpdated)
+		if notUpdated == nil {
+			notUpdated = make(map[string]json.RawMessage)
+		}
+
+		ids := make([]string, 0, len(updated))
+		for id := range updated {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		for i := 0; i < len(ids); i += 2 {
+			delete(updated, ids[i])
+			notUpdated[ids[i]] = json.RawMessage(`{"type":"serverFail","description":"injected failure"}`)
+		}
+		args["updated"], _ = json.Marshal(updated)
+		args["notUpdated"], _ = json.Marshal(notUpdated)
+		call[1], _ = json.Marshal(args)
+		changed = true
+	}
+	if !changed {
+		return resp, nil
+	}
+
+	body, err = json.Marshal(response)
+	if err != nil {
+		return nil, err
+	}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = int64(len(body))
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}
+
+// setFailureInjection sets up the failures of the hidden --inject-failure
+// flag for a client, on top of any recording or replay.
+func setFailureInjection(cmd *cobra.Command, opts *ClientOptions) error {
+	failures, _ := cmd.Flags().GetStringArray("inject-failure")
+	if len(failures) == 0 {
+		return nil
+	}
+	for _, failure := range failures {
+		if !isFailureKind(failure) {
+			return fmt.Errorf("unknown --inject-failure %q (expected one of: %s)", failure, strings.Join(failureKinds, ", "))
+		}
+	}
+
+	wrap := opts.WrapTransport
+	opts.WrapTransport = func(next http.RoundTripper) http.RoundTripper {
+		if wrap != nil {
+			next = wrap(next)
+		}
+		return &faultInjector{next: next, pending: append([]string(nil), failures...)}
+	}
+	return nil
+}
+
+// isFailureKind reports whether --inject-failure supports the failure.
+func isFailureKind(failure string) bool {
+	for _, kind := range failureKinds {
+		if kind == failure {
+			return true
+		}
+	}
+	return false
+}
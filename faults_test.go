@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// injectFailures makes the client's requests fail as --inject-failure would.
+func injectFailures(client *FastmailClient, failures ...string) {
+	withTransport(client, func(next http.RoundTripper) http.RoundTripper {
+		return &faultInjector{next: next, pending: failures}
+	})
+}
+
+func TestBulkStateUpdatePartialSet(t *testing.T) {
+	fake, client := newFakeJMAP(t,
+		MaskedEmailInfo{ID: "1", Email: "a@fastmail.com", State: AliasEnabled},
+		MaskedEmailInfo{ID: "2", Email: "b@fastmail.com", State: AliasEnabled},
+		MaskedEmailInfo{ID: "3", Email: "c@fastmail.com", State: AliasEnabled},
+		MaskedEmailInfo{ID: "4", Email: "d@fastmail.com", State: AliasDisabled},
+	)
+	injectFailures(client, failurePartialSet)
+
+	checkpoint := newBulkCheckpoint(AliasDisabled, []string{"a@fastmail.com", "b@fastmail.com", "c@fastmail.com", "d@fastmail.com"})
+	if err := runBulkStateUpdate(context.Background(), client, checkpoint); err == nil || err.Error() != "2 of 4 aliases could not be updated" {
+		t.Fatalf("expected a partial failure, got %v", err)
+	}
+	// IDs 1 and 3 are reported as failed although the server applied them
+	if len(checkpoint.Failed) != 2 || checkpoint.Failed["a@fastmail.com"] == "" || checkpoint.Failed["c@fastmail.com"] == "" {
+		t.Fatalf("expected a and c to fail, got %v", checkpoint.Failed)
+	}
+	if len(checkpoint.Done) != 2 || len(checkpoint.Pending) != 0 {
+		t.Fatalf("expected b and d to be done, got done %v, pending %v", checkpoint.Done, checkpoint.Pending)
+	}
+	if fake.state("2") != AliasDisabled {
+		t.Fatalf("expected b to be disabled")
+	}
+}
+
+func TestResumeRetriesFailures(t *testing.T) {
+	fake, client := newFakeJMAP(t,
+		MaskedEmailInfo{ID: "1", Email: "a@fastmail.com", State: AliasEnabled},
+		MaskedEmailInfo{ID: "2", Email: "b@fastmail.com", State: AliasEnabled},
+	)
+	injectFailures(client, failurePartialSet)
+
+	checkpoint := newBulkCheckpoint(AliasDisabled, []string{"a@fastmail.com", "b@fastmail.com"})
+	if err := runBulkStateUpdate(context.Background(), client, checkpoint); err == nil {
+		t.Fatal("expected a partial failure")
+	}
+	saved, err := loadBulkCheckpoint(checkpoint.path)
+	if err != nil {
+		t.Fatalf("expected the checkpoint to be kept for the failed alias: %v", err)
+	}
+	if len(saved.Pending) != 0 || saved.Failed["a@fastmail.com"] == "" {
+		t.Fatalf("expected a to be saved as failed, got %+v", saved)
+	}
+
+	// Undo the update the server applied, as if it had really failed; the
+	// injected failure is used up, so the retry goes through
+	fake.mu.Lock()
+	fake.aliases["1"].State = AliasEnabled
+	fake.mu.Unlock()
+	if err := handleResume(client, checkpoint.path); err != nil {
+		t.Fatalf("handleResume returned error: %v", err)
+	}
+	if fake.state("1") != AliasDisabled {
+		t.Errorf("expected the failed alias to be retried and disabled")
+	}
+	if _, err := loadBulkCheckpoint(checkpoint.path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected the checkpoint to be removed once nothing failed, got %v", err)
+	}
+}
+
+func TestBulkStateUpdateChunksAroundFailure(t *testing.T) {
+	fake, client := newFakeJMAP(t,
+		MaskedEmailInfo{ID: "1", Email: "a@fastmail.com", State: AliasEnabled},
+		MaskedEmailInfo{ID: "2", Email: "b@fastmail.com", State: AliasEnabled},
+		MaskedEmailInfo{ID: "3", Email: "c@fastmail.com", State: AliasEnabled},
+	)
+	fake.maxSet = 2
+	injectFailures(client, failurePartialSet)
+
+	checkpoint := newBulkCheckpoint(AliasDisabled, []string{"a@fastmail.com", "b@fastmail.com", "c@fastmail.com"})
+	if err := runBulkStateUpdate(context.Background(), client, checkpoint); err == nil || err.Error() != "1 of 3 aliases could not be updated" {
+		t.Fatalf("expected a partial failure, got %v", err)
+	}
+	// Only the first chunk gets the injected failure
+	if len(checkpoint.Failed) != 1 || checkpoint.Failed["a@fastmail.com"] == "" {
+		t.Fatalf("expected only a to fail, got %v", checkpoint.Failed)
+	}
+	if fake.requests != 3 {
+		t.Fatalf("expected a get and two set requests, got %d", fake.requests)
+	}
+}
+
+func TestCreateAliasRetriesAfterTimeout(t *testing.T) {
+	fake, client := newFakeJMAP(t)
+	client.Timeout = 100 * time.Millisecond
+	injectFailures(client, failureTimeout)
+
+	alias, err := client.CreateAliasWith(AliasCreation{Domain: "https://example.com"})
+	if err != nil {
+		t.Fatalf("expected the creation to be retried, got %v", err)
+	}
+	if alias.ForDomain != "https://example.com" || fake.created != 1 {
+		t.Fatalf("expected one alias to be created, got %+v (%d created)", alias, fake.created)
+	}
+}
+
+func TestTimeoutWithoutRetry(t *testing.T) {
+	_, client := newFakeJMAP(t)
+	client.Timeout = 50 * time.Millisecond
+	injectFailures(client, failureTimeout)
+
+	_, err := lookupOrCreateAlias(client, "example.com", nil, false)
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "use --timeout") {
+		t.Fatalf("expected a timeout error, got %v", err)
+	}
+	if exitCode(err) != exitUnreachable {
+		t.Fatalf("expected exit code %d, got %d", exitUnreachable, exitCode(err))
+	}
+}
+
+func TestRateLimitError(t *testing.T) {
+	fake, client := newFakeJMAP(t)
+	injectFailures(client, failureRateLimit)
+
+	_, err := lookupOrCreateAlias(client, "example.com", nil, false)
+	if err == nil || !strings.Contains(err.Error(), "HTTP 429") {
+		t.Fatalf("expected a rate limit error, got %v", err)
+	}
+	if fake.created != 0 {
+		t.Fatalf("expected nothing to be created")
+	}
+
+	// The failure is injected once
+	if _, err := lookupOrCreateAlias(client, "example.com", nil, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestSetFailureInjection(t *testing.T) {
+	cmd := &cobra.Command{}
+	cmd.Flags().StringArray("inject-failure", nil, "")
+	cmd.Flags().Set("inject-failure", "rate-limit")
+	opts := ClientOptions{}
+	if err := setFailureInjection(cmd, &opts); err != nil || opts.WrapTransport == nil {
+		t.Fatalf("expected a transport wrapper, got %v", err)
+	}
+
+	cmd.Flags().Set("inject-failure", "crash")
+	if err := setFailureInjection(cmd, &ClientOptions{}); err == nil || !strings.Contains(err.Error(), "unknown --inject-failure") {
+		t.Fatalf("expected an unknown failure error, got %v", err)
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// graphFormats lists the supported graph formats
+var graphFormats = []string{"dot", "mermaid"}
+
+// graphDomain is a registrable domain with the origins aliases were created for.
+type graphDomain struct {
+	Name    string
+	Origins []graphOrigin
+}
+
+// graphOrigin is an origin with the aliases created for it.
+type graphOrigin struct {
+	Name    string
+	Aliases []MaskedEmailInfo
+}
+
+// aliasCount returns the number of aliases under the domain.
+func (d graphDomain) aliasCount() int {
+	count := 0
+	for _, origin := range d.Origins {
+		count += len(origin.Aliases)
+	}
+	return count
+}
+
+// buildAliasGraph groups aliases by registrable domain and then by origin,
+// sorted by name. Aliases without a domain are left out, as are deleted
+// aliases unless includeDeleted is set.
+func buildAliasGraph(aliases []MaskedEmailInfo, includeDeleted bool) []graphDomain {
+	byDomain := make(map[string]map[string][]MaskedEmailInfo)
+	for _, alias := range aliases {
+		if strings.TrimSpace(alias.ForDomain) == "" || (alias.State == AliasDeleted && !includeDeleted) {
+			continue
+		}
+		domain := registrableDomain(alias.ForDomain)
+		if byDomain[domain] == nil {
+			byDomain[domain] = make(map[string][]MaskedEmailInfo)
+		}
+		byDomain[domain][alias.ForDomain] = append(byDomain[domain][alias.ForDomain], alias)
+	}
+
+	domains := make([]graphDomain, 0, len(byDomain))
+	for _, name := range sortedKeys(byDomain) {
+		domain := graphDomain{Name: name}
+		for _, origin := range sortedKeys(byDomain[name]) {
+			originAliases := byDomain[name][origin]
+			sort.Slice(originAliases, func(i, j int) bool {
+				return originAliases[i].Email < originAliases[j].Email
+			})
+			domain.Origins = append(domain.Origins, graphOrigin{Name: origin, Aliases: originAliases})
+		}
+		domains = append(domains, domain)
+	}
+	return domains
+}
+
+// graphAliasLabel labels an alias node, noting states other than enabled.
+func graphAliasLabel(alias MaskedEmailInfo) string {
+	if alias.State == AliasEnabled {
+		return alias.Email
+	}
+	return fmt.Sprintf("%s (%s)", alias.Email, alias.State)
+}
+
+// graphDomainLabel labels a domain node with the number of aliases under it.
+func graphDomainLabel(domain graphDomain) string {
+	count := domain.aliasCount()
+	if count == 1 {
+		return domain.Name + " (1 alias)"
+	}
+	return fmt.Sprintf("%s (%d aliases)", domain.Name, count)
+}
+
+// writeDotGraph writes the graph in the Graphviz DOT language.
+func writeDotGraph(w io.Writer, domains []graphDomain) error {
+	out := bufio.NewWriter(w)
+	fmt.Fprintln(out, "digraph aliases {")
+	fmt.Fprintln(out, "  rankdir=LR;")
+	fmt.Fprintln(out, "  node [shape=box];")
+	for i, domain := range domains {
+		domainID := fmt.Sprintf("d%d", i)
+		fmt.Fprintf(out, "  %s [label=%s, shape=folder];\n", domainID, dotQuote(graphDomainLabel(domain)))
+		for j, origin := range domain.Origins {
+			originID := fmt.Sprintf("%so%d", domainID, j)
+			fmt.Fprintf(out, "  %s [label=%s];\n", originID, dotQuote(origin.Name))
+			fmt.Fprintf(out, "  %s -> %s;\n", domainID, originID)
+			for k, alias := range origin.Aliases {
+				aliasID := fmt.Sprintf("%sa%d", originID, k)
+				style := ""
+				if alias.State != AliasEnabled {
+					style = ", style=dashed"
+				}
+				fmt.Fprintf(out, "  %s [label=%s, shape=ellipse%s];\n", aliasID, dotQuote(graphAliasLabel(alias)), style)
+				fmt.Fprintf(out, "  %s -> %s;\n", originID, aliasID)
+			}
+		}
+	}
+	fmt.Fprintln(out, "}")
+	return out.Flush()
+}
+
+// dotQuote quotes a string as a DOT identifier.
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// writeMermaidGraph writes the graph as a Mermaid flowchart, which renders in
+// Markdown documents on GitHub and elsewhere.
+func writeMermaidGraph(w io.Writer, domains []graphDomain) error {
+	out := bufio.NewWriter(w)
+	fmt.Fprintln(out, "flowchart LR")
+	for i, domain := range domains {
+		domainID := fmt.Sprintf("d%d", i)
+		fmt.Fprintf(out, "  %s[%s]\n", domainID, mermaidQuote(graphDomainLabel(domain)))
+		for j, origin := range domain.Origins {
+			originID := fmt.Sprintf("%so%d", domainID, j)
+			fmt.Fprintf(out, "  %s --> %s[%s]\n", domainID, originID, mermaidQuote(origin.Name))
+			for k, alias := range origin.Aliases {
+				aliasID := fmt.Sprintf("%sa%d", originID, k)
+				fmt.Fprintf(out, "  %s --> %s(%s)\n", originID, aliasID, mermaidQuote(graphAliasLabel(alias)))
+			}
+		}
+	}
+	return out.Flush()
+}
+
+// mermaidQuote quotes a node label for Mermaid.
+func mermaidQuote(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, "#quot;") + `"`
+}
+
+// newGraphCmd creates the command that prints the domains and aliases of the
+// account as a graph.
+func newGraphCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "graph",
+		Short: "Print a graph of domains, the sites under them and their aliases",
+		Long: `Print a graph of registrable domains, the sites (origins) under them and the
+aliases created for each, in Graphviz DOT or Mermaid format. This shows at a
+glance where aliases have sprawled across the subdomains of a single site.
+Aliases without a domain are not included.`,
+		Example: `  masked_fastmail graph | dot -Tsvg > aliases.svg
+  masked_fastmail graph --format mermaid > aliases.mmd`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			format, _ := cmd.Flags().GetString("format")
+			includeDeleted, _ := cmd.Flags().GetBool("include-deleted")
+			client, err := newClientFromCmd(cmd)
+			if err != nil {
+				return err
+			}
+			return handleGraph(client, format, includeDeleted)
+		},
+	}
+	cmd.Flags().String("format", "dot", "graph format: "+strings.Join(graphFormats, ", "))
+	cmd.Flags().Bool("include-deleted", false, "include deleted aliases")
+	return cmd
+}
+
+// handleGraph prints the alias graph of the account in the requested format.
+func handleGraph(client *FastmailClient, format string, includeDeleted bool) error {
+	format = strings.ToLower(strings.TrimSpace(format))
+	var write func(io.Writer, []graphDomain) error
+	switch format {
+	case "dot":
+		write = writeDotGraph
+	case "mermaid":
+		write = writeMermaidGraph
+	default:
+		return fmt.Errorf("unknown graph format %q (expected one of: %s)", format, strings.Join(graphFormats, ", "))
+	}
+
+	aliases, err := fetchAllAliasesWithProgress(client)
+	if err != nil {
+		return formatAPIError("failed to get aliases", err)
+	}
+	return write(os.Stdout, buildAliasGraph(aliases, includeDeleted))
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+var graphAliases = []MaskedEmailInfo{
+	{Email: "b@fastmail.com", ForDomain: "https://mail.google.com", State: AliasEnabled},
+	{Email: "a@fastmail.com", ForDomain: "https://accounts.google.com", State: AliasDisabled},
+	{Email: "c@fastmail.com", ForDomain: "https://mail.google.com", State: AliasEnabled},
+	{Email: "d@fastmail.com", ForDomain: "https://example.com", State: AliasDeleted},
+	{Email: "e@fastmail.com", Description: "no domain", State: AliasEnabled},
+}
+
+func TestBuildAliasGraph(t *testing.T) {
+	domains := buildAliasGraph(graphAliases, false)
+	if len(domains) != 1 || domains[0].Name != "google.com" {
+		t.Fatalf("expected only google.com without deleted aliases, got %+v", domains)
+	}
+	origins := domains[0].Origins
+	if len(origins) != 2 || origins[0].Name != "https://accounts.google.com" || origins[1].Name != "https://mail.google.com" {
+		t.Fatalf("unexpected origins: %+v", origins)
+	}
+	if len(origins[1].Aliases) != 2 || origins[1].Aliases[0].Email != "b@fastmail.com" {
+		t.Fatalf("expected the aliases of an origin sorted by email, got %+v", origins[1].Aliases)
+	}
+	if domains[0].aliasCount() != 3 {
+		t.Fatalf("expected 3 aliases under google.com, got %d", domains[0].aliasCount())
+	}
+
+	if domains := buildAliasGraph(graphAliases, true); len(domains) != 2 || domains[0].Name != "example.com" {
+		t.Fatalf("expected deleted aliases to be included on request, got %+v", domains)
+	}
+}
+
+func TestWriteDotGraph(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeDotGraph(&buf, buildAliasGraph(graphAliases, false)); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"digraph aliases {",
+		`d0 [label="google.com (3 aliases)", shape=folder];`,
+		`d0o0 [label="https://accounts.google.com"];`,
+		"d0 -> d0o0;",
+		`d0o0a0 [label="a@fastmail.com (disabled)", shape=ellipse, style=dashed];`,
+		"d0o1 -> d0o1a1;",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in the DOT output:\n%s", want, out)
+		}
+	}
+}
+
+func TestWriteMermaidGraph(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeMermaidGraph(&buf, buildAliasGraph(graphAliases, false)); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"flowchart LR",
+		`d0["google.com (3 aliases)"]`,
+		`d0 --> d0o1["https://mail.google.com"]`,
+		`d0o1 --> d0o1a0("b@fastmail.com")`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in the Mermaid output:\n%s", want, out)
+		}
+	}
+}
+
+func TestGraphQuoting(t *testing.T) {
+	if got := dotQuote(`a "b" \c`); got != `"a \"b\" \\c"` {
+		t.Fatalf("dotQuote = %s", got)
+	}
+	if got := mermaidQuote(`a "b"`); got != `"a #quot;b#quot;"` {
+		t.Fatalf("mermaidQuote = %s", got)
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"time"
+
+	"github.com/fredrmb/masked_fastmail/aliaspb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// grpcServer answers the gRPC AliasService with the alias operations of
+// serve mode, as the line protocol and the HTTP API do.
+type grpcServer struct {
+	aliaspb.UnimplementedAliasServiceServer
+	aliases aliasHandler
+}
+
+// aliasStates maps the states of the gRPC API to those of the aliases.
+var aliasStates = map[aliaspb.AliasState]AliasState{
+	aliaspb.AliasState_ALIAS_STATE_PENDING:  AliasPending,
+	aliaspb.AliasState_ALIAS_STATE_ENABLED:  AliasEnabled,
+	aliaspb.AliasState_ALIAS_STATE_DISABLED: AliasDisabled,
+	aliaspb.AliasState_ALIAS_STATE_DELETED:  AliasDeleted,
+}
+
+// GetAlias returns the alias for a site, created if there is none.
+func (s *grpcServer) GetAlias(ctx context.Context, req *aliaspb.GetAliasRequest) (*aliaspb.Alias, error) {
+	alias, err := s.aliases.GetAlias(req.GetSite())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return aliasMessage(alias), nil
+}
+
+// CreateAlias creates a new alias for a site, even if it already has one.
+func (s *grpcServer) CreateAlias(ctx context.Context, req *aliaspb.CreateAliasRequest) (*aliaspb.Alias, error) {
+	alias, err := s.aliases.CreateAlias(req.GetSite(), req.GetDescription())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return aliasMessage(alias), nil
+}
+
+// ListAliases streams the aliases of a site, or every alias.
+func (s *grpcServer) ListAliases(req *aliaspb.ListAliasesRequest, stream aliaspb.AliasService_ListAliasesServer) error {
+	aliases, err := s.aliases.ListAliases(req.GetSite())
+	if err != nil {
+		return grpcError(err)
+	}
+	for i := range aliases {
+		if err := stream.Send(aliasMessage(&aliases[i])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UpdateState sets the state of each alias sent on the stream. A failed
+// update is answered with its error, and the stream goes on.
+func (s *grpcServer) UpdateState(stream aliaspb.AliasService_UpdateStateServer) error {
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		resp := &aliaspb.UpdateStateResponse{Email: req.GetEmail()}
+		// An unknown state is passed on empty, for the service to reject
+		alias, err := s.aliases.UpdateState(req.GetEmail(), string(aliasStates[req.GetState()]))
+		if err != nil {
+			obj := newErrorObject(err)
+			resp.Error = &aliaspb.Error{Type: obj.Type, Message: obj.Message, ExitCode: int32(obj.ExitCode)}
+		} else {
+			resp.Alias = aliasMessage(alias)
+		}
+		if err := stream.Send(resp); err != nil {
+			return err
+		}
+	}
+}
+
+// Refresh loads the session again.
+func (s *grpcServer) Refresh(ctx context.Context, req *aliaspb.RefreshRequest) (*aliaspb.RefreshResponse, error) {
+	result, err := s.aliases.Refresh()
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &aliaspb.RefreshResponse{RefreshedAt: timestamppb.New(result.RefreshedAt)}, nil
+}
+
+// aliasMessage converts an alias to its gRPC message.
+func aliasMessage(alias *MaskedEmailInfo) *aliaspb.Alias {
+	msg := &aliaspb.Alias{
+		Id:          alias.ID,
+		Email:       alias.Email,
+		ForDomain:   alias.ForDomain,
+		Description: alias.Description,
+		CreatedBy:   alias.CreatedBy,
+		Url:         alias.URL,
+	}
+	for state, name := range aliasStates {
+		if name == alias.State {
+			msg.State = state
+		}
+	}
+	if !alias.CreatedAt.IsZero() {
+		msg.CreatedAt = timestamppb.New(alias.CreatedAt)
+	}
+	if alias.LastMessageAt != nil {
+		msg.LastMessageAt = timestamppb.New(*alias.LastMessageAt)
+	}
+	return msg
+}
+
+// grpcError returns the gRPC status of a failed operation, with the fields
+// of the --json error output as its detail.
+func grpcError(err error) error {
+	var code codes.Code
+	switch errorType(err) {
+	case "invalidInput":
+		code = codes.InvalidArgument
+	case "notFound":
+		code = codes.NotFound
+	case "protected", "deleted", "guardrail":
+		code = codes.FailedPrecondition
+	case "readOnly":
+		code = codes.PermissionDenied
+	case "api", "auth", "unreachable", "tlsPin", "responseTooLarge":
+		// The request was fine, but Fastmail couldn't answer it
+		code = codes.Unavailable
+	default:
+		code = codes.Internal
+	}
+	obj := newErrorObject(err)
+	st, detailErr := status.New(code, obj.Message).WithDetails(&aliaspb.Error{
+		Type: obj.Type, Message: obj.Message, ExitCode: int32(obj.ExitCode),
+	})
+	if detailErr != nil {
+		return status.Error(code, obj.Message)
+	}
+	return st.Err()
+}
+
+// authorizeRPC lets only calls with the token of a client through, as
+// requireToken does for the HTTP API, and limits the calls of each client.
+// Tokens are sent as "authorization: Bearer <token>" metadata.
+func authorizeRPC(ctx context.Context, method string, clients []serveClient, limiter *rateLimiter) error {
+	var authorization string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("authorization"); len(values) > 0 {
+			authorization = values[0]
+		}
+	}
+	client, ok := authenticateBearer(authorization, clients)
+	key := "client " + client.Name
+	if !ok {
+		key = "address unknown"
+		if p, found := peer.FromContext(ctx); found {
+			host, _, err := net.SplitHostPort(p.Addr.String())
+			if err != nil {
+				host = p.Addr.String()
+			}
+			key = "address " + host
+		}
+	}
+	if allowed, _ := limiter.allow(key); !allowed {
+		return status.Error(codes.ResourceExhausted, "too many requests; try again later")
+	}
+	if !ok {
+		return status.Error(codes.Unauthenticated, "missing or invalid bearer token")
+	}
+	if client.LookupOnly && method != aliaspb.AliasService_GetAlias_FullMethodName {
+		return status.Errorf(codes.PermissionDenied, "the token of %s only allows GetAlias", client.Name)
+	}
+	return nil
+}
+
+// newGRPCServer returns a gRPC server of the alias operations. With clients,
+// every call must carry the token of one of them; with a certificate, the
+// server speaks TLS.
+func newGRPCServer(aliases aliasHandler, clients []serveClient, limiter *rateLimiter, certFile, keyFile string) (*grpc.Server, error) {
+	var opts []grpc.ServerOption
+	if certFile != "" {
+		creds, err := credentials.NewServerTLSFromFile(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the TLS certificate: %w", err)
+		}
+		opts = append(opts, grpc.Creds(creds))
+	}
+	if clients != nil {
+		opts = append(opts,
+			grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+				if err := authorizeRPC(ctx, info.FullMethod, clients, limiter); err != nil {
+					return nil, err
+				}
+				return handler(ctx, req)
+			}),
+			grpc.StreamInterceptor(func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+				if err := authorizeRPC(stream.Context(), info.FullMethod, clients, limiter); err != nil {
+					return err
+				}
+				return handler(srv, stream)
+			}),
+		)
+	}
+	server := grpc.NewServer(opts...)
+	aliaspb.RegisterAliasServiceServer(server, &grpcServer{aliases: aliases})
+	return server, nil
+}
+
+// serveGRPC serves the gRPC API on addr until ctx is done.
+func serveGRPC(ctx context.Context, addr string, server *grpc.Server) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	go func() {
+		<-ctx.Done()
+		// Let calls in progress finish, but don't wait on open streams forever
+		stopped := make(chan struct{})
+		go func() {
+			server.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-time.After(5 * time.Second):
+			server.Stop()
+		}
+	}()
+	fmt.Fprintf(os.Stderr, "Serving the gRPC API on %s\n", listener.Addr())
+	return server.Serve(listener)
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/fredrmb/masked_fastmail/aliaspb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// dialGRPC serves the alias operations over an in-memory connection and
+// returns a client of it.
+func dialGRPC(t *testing.T, aliases aliasHandler, clients []serveClient) aliaspb.AliasServiceClient {
+	t.Helper()
+	server, err := newGRPCServer(aliases, clients, newRateLimiter(60), "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener := bufconn.Listen(1 << 20)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return aliaspb.NewAliasServiceClient(conn)
+}
+
+func TestGRPCServer(t *testing.T) {
+	client := dialGRPC(t, stubAliases{}, nil)
+	ctx := context.Background()
+
+	alias, err := client.GetAlias(ctx, &aliaspb.GetAliasRequest{Site: "example.com"})
+	if err != nil || alias.GetEmail() != "alias-for-example.com@fastmail.com" {
+		t.Errorf("GetAlias() = %v, %v", alias, err)
+	}
+	alias, err = client.CreateAlias(ctx, &aliaspb.CreateAliasRequest{Site: "example.com", Description: "Shop"})
+	if err != nil || alias.GetEmail() != "new-for-example.com@fastmail.com" || alias.GetDescription() != "Shop" {
+		t.Errorf("CreateAlias() = %v, %v", alias, err)
+	}
+	refreshed, err := client.Refresh(ctx, &aliaspb.RefreshRequest{})
+	if err != nil || refreshed.GetRefreshedAt().AsTime().Hour() != 9 {
+		t.Errorf("Refresh() = %v, %v", refreshed, err)
+	}
+
+	list, err := client.ListAliases(ctx, &aliaspb.ListAliasesRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var emails []string
+	for {
+		alias, err := list.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		emails = append(emails, alias.GetEmail())
+	}
+	if len(emails) != 2 || emails[0] != "a@fastmail.com" || emails[1] != "b@fastmail.com" {
+		t.Errorf("ListAliases() streamed %v", emails)
+	}
+}
+
+func TestGRPCUpdateStateStream(t *testing.T) {
+	_, fastmail := newFakeJMAP(t,
+		MaskedEmailInfo{ID: "a1", Email: "shop.1@fastmail.com", State: AliasEnabled},
+		MaskedEmailInfo{ID: "a2", Email: "news.2@fastmail.com", State: AliasEnabled},
+	)
+	client := dialGRPC(t, &aliasService{client: fastmail}, nil)
+
+	stream, err := client.UpdateState(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	requests := []*aliaspb.UpdateStateRequest{
+		{Email: "shop.1@fastmail.com", State: aliaspb.AliasState_ALIAS_STATE_DISABLED},
+		{Email: "missing@fastmail.com", State: aliaspb.AliasState_ALIAS_STATE_DISABLED},
+		{Email: "news.2@fastmail.com", State: aliaspb.AliasState_ALIAS_STATE_UNSPECIFIED},
+		{Email: "news.2@fastmail.com", State: aliaspb.AliasState_ALIAS_STATE_DELETED},
+	}
+	for _, req := range requests {
+		if err := stream.Send(req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stream.CloseSend()
+
+	var responses []*aliaspb.UpdateStateResponse
+	for {
+		resp, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		responses = append(responses, resp)
+	}
+	if len(responses) != len(requests) {
+		t.Fatalf("got %d responses, want one per request: %v", len(responses), responses)
+	}
+	if got := responses[0].GetAlias().GetState(); got != aliaspb.AliasState_ALIAS_STATE_DISABLED {
+		t.Errorf("first update: state %v, want disabled", got)
+	}
+	if got := responses[1].GetError().GetType(); got != "notFound" || responses[1].GetEmail() != "missing@fastmail.com" {
+		t.Errorf("missing alias: %v, want a notFound error", responses[1])
+	}
+	if got := responses[2].GetError().GetType(); got != "invalidInput" {
+		t.Errorf("unspecified state: %v, want an invalidInput error", responses[2])
+	}
+	if got := responses[3].GetAlias().GetState(); got != aliaspb.AliasState_ALIAS_STATE_DELETED {
+		t.Errorf("last update: state %v, want deleted after the failures", got)
+	}
+}
+
+func TestGRPCMalformedSite(t *testing.T) {
+	_, fastmail := newFakeJMAP(t)
+	client := dialGRPC(t, &aliasService{client: fastmail}, nil)
+	_, err := client.GetAlias(context.Background(), &aliaspb.GetAliasRequest{Site: "https://example.com:99999x"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("GetAlias() = %v; want InvalidArgument, not Unavailable", err)
+	}
+}
+
+func TestGRPCError(t *testing.T) {
+	for err, want := range map[error]codes.Code{
+		&exitCodeError{code: exitInvalidInput, err: errors.New("bad site")}: codes.InvalidArgument,
+		ErrAliasNotFound:             codes.NotFound,
+		ErrProtected:                 codes.FailedPrecondition,
+		ErrReadOnly:                  codes.PermissionDenied,
+		&APIError{StatusCode: 500}:   codes.Unavailable,
+		errors.New("something else"): codes.Internal,
+	} {
+		st := status.Convert(grpcError(err))
+		if st.Code() != want {
+			t.Errorf("grpcError(%v) = %v, want %v", err, st.Code(), want)
+		}
+		details := st.Details()
+		if len(details) != 1 {
+			t.Errorf("grpcError(%v) has details %v, want the error object", err, details)
+			continue
+		}
+		if detail, ok := details[0].(*aliaspb.Error); !ok || detail.GetType() != errorType(err) {
+			t.Errorf("grpcError(%v) detail = %v", err, details[0])
+		}
+	}
+}
+
+func TestGRPCRequiresToken(t *testing.T) {
+	clients := []serveClient{
+		{Name: "phone", Token: "0123456789abcdef"},
+		{Name: "browser", Token: "abcdef0123456789", LookupOnly: true},
+	}
+	client := dialGRPC(t, stubAliases{}, clients)
+	withToken := func(token string) context.Context {
+		return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+	}
+
+	_, err := client.GetAlias(context.Background(), &aliaspb.GetAliasRequest{Site: "example.com"})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("without a token: %v, want Unauthenticated", err)
+	}
+	_, err = client.GetAlias(withToken("wrong-token-0000000"), &aliaspb.GetAliasRequest{Site: "example.com"})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("with a wrong token: %v, want Unauthenticated", err)
+	}
+	if _, err := client.CreateAlias(withToken("0123456789abcdef"), &aliaspb.CreateAliasRequest{Site: "example.com"}); err != nil {
+		t.Errorf("with a token: %v", err)
+	}
+	if _, err := client.GetAlias(withToken("abcdef0123456789"), &aliaspb.GetAliasRequest{Site: "example.com"}); err != nil {
+		t.Errorf("GetAlias with a lookup token: %v", err)
+	}
+	_, err = client.CreateAlias(withToken("abcdef0123456789"), &aliaspb.CreateAliasRequest{Site: "example.com"})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("CreateAlias with a lookup token: %v, want PermissionDenied", err)
+	}
+	stream, err := client.ListAliases(withToken("abcdef0123456789"), &aliaspb.ListAliasesRequest{})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("ListAliases with a lookup token: %v, want PermissionDenied", err)
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// What happens when a creation would go over a guardrail
+const (
+	guardrailWarn   = "warn"
+	guardrailRefuse = "refuse"
+)
+
+var (
+	// maxAliasesPerDomain caps the aliases of a registrable domain; zero
+	// disables the cap. Set by max_aliases_per_domain.
+	maxAliasesPerDomain int
+	// domainCapAction is guardrailWarn or guardrailRefuse; set by
+	// max_aliases_action
+	domainCapAction = guardrailRefuse
+)
+
+// ErrGuardrail is returned when a creation is refused by a guardrail
+var ErrGuardrail = errors.New("refused by a guardrail")
+
+// validateGuardrailAction checks a guardrail action setting.
+func validateGuardrailAction(setting, action string) error {
+	switch action {
+	case guardrailWarn, guardrailRefuse:
+		return nil
+	}
+	return fmt.Errorf("unknown %s %q (expected %s or %s)", setting, action, guardrailWarn, guardrailRefuse)
+}
+
+// checkDomainCap enforces max_aliases_per_domain before an alias is created
+// for the domain. Aliases of all hosts of the registrable domain count, so
+// that a script can't get around the cap with subdomains; deleted aliases
+// and the alias being replaced, if any, don't.
+func (fc *FastmailClient) checkDomainCap(domain string, replaces *MaskedEmailInfo) error {
+	if maxAliasesPerDomain <= 0 {
+		return nil
+	}
+	aliases, err := fc.FetchAllAliases()
+	if err != nil {
+		return err
+	}
+	site := registrableDomain(domain)
+	count := 0
+	for _, alias := range aliases {
+		if alias.State == AliasDeleted || (replaces != nil && alias.ID == replaces.ID) {
+			continue
+		}
+		if registrableDomain(alias.ForDomain) == site {
+			count++
+		}
+	}
+	if count < maxAliasesPerDomain {
+		return nil
+	}
+
+	message := fmt.Sprintf("%s already has %s, the max_aliases_per_domain limit", site, plural(count, "alias", "aliases"))
+	if domainCapAction == guardrailWarn {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", message)
+		return nil
+	}
+	return fmt.Errorf("%w: %s; disable or merge some of them, or raise the limit in the config file", ErrGuardrail, message)
+}
+
+// creationLogFile counts the aliases created with this tool per day
+const creationLogFile = "creations.json"
+
+// creationLogDays is how many days of the creation log are kept
+const creationLogDays = 12 * 7
+
+var (
+	// maxCreationsPerDay and maxCreationsPerWeek are the creation quotas;
+	// zero disables a quota. Set by max_creations_per_day and
+	// max_creations_per_week.
+	maxCreationsPerDay  int
+	maxCreationsPerWeek int
+)
+
+// creationLog maps local dates ("2006-01-02") to the number of aliases
+// created on them.
+type creationLog map[string]int
+
+// add counts a creation on the day of now.
+func (l creationLog) add(now time.Time) {
+	l[now.Format(time.DateOnly)]++
+}
+
+// prune forgets the days older than creationLogDays.
+func (l creationLog) prune(now time.Time) {
+	oldest := now.AddDate(0, 0, -creationLogDays+1).Format(time.DateOnly)
+	for day := range l {
+		if day < oldest {
+			delete(l, day)
+		}
+	}
+}
+
+// since returns the creations in the last days days, today included.
+func (l creationLog) since(now time.Time, days int) int {
+	total := 0
+	for i := 0; i < days; i++ {
+		total += l[now.AddDate(0, 0, -i).Format(time.DateOnly)]
+	}
+	return total
+}
+
+// quotaWarnings describes the creation quotas that are exceeded.
+func (l creationLog) quotaWarnings(now time.Time) []string {
+	var warnings []string
+	if today := l.since(now, 1); maxCreationsPerDay > 0 && today > maxCreationsPerDay {
+		warnings = append(warnings, fmt.Sprintf("%s created today, over the max_creations_per_day quota of %d",
+			plural(today, "alias", "aliases"), maxCreationsPerDay))
+	}
+	if week := l.since(now, 7); maxCreationsPerWeek > 0 && week > maxCreationsPerWeek {
+		warnings = append(warnings, fmt.Sprintf("%s created in the last 7 days, over the max_creations_per_week quota of %d",
+			plural(week, "alias", "aliases"), maxCreationsPerWeek))
+	}
+	return warnings
+}
+
+// trackCreations reports whether creations are logged: when a quota is
+// configured or the usage statistics are enabled.
+func trackCreations() bool {
+	if maxCreationsPerDay > 0 || maxCreationsPerWeek > 0 {
+		return true
+	}
+	stats, err := loadStats()
+	return err == nil && stats.Enabled
+}
+
+// recordCreation logs a creation and warns when it takes the account over a
+// creation quota, a sign of a script creating aliases in a loop. Failures
+// only print a warning, since the alias was already created.
+func recordCreation(now time.Time) {
+	if !trackCreations() {
+		return
+	}
+	creations := creationLog{}
+	err := updateJSONFile(creationLogFile, &creations, func() error {
+		creations.add(now)
+		creations.prune(now)
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not record the creation: %v\n", err)
+		return
+	}
+	for _, warning := range creations.quotaWarnings(now) {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCheckDomainCap(t *testing.T) {
+	oldMax, oldAction := maxAliasesPerDomain, domainCapAction
+	t.Cleanup(func() { maxAliasesPerDomain, domainCapAction = oldMax, oldAction })
+
+	existing := []MaskedEmailInfo{
+		{ID: "a1", Email: "a1@fastmail.com", ForDomain: "https://example.com", State: AliasEnabled},
+		{ID: "a2", Email: "a2@fastmail.com", ForDomain: "https://shop.example.com", State: AliasDisabled},
+		{ID: "a3", Email: "a3@fastmail.com", ForDomain: "https://example.com", State: AliasDeleted},
+		{ID: "a4", Email: "a4@fastmail.com", ForDomain: "https://other.com", State: AliasEnabled},
+	}
+	fake, client := newFakeJMAP(t, existing...)
+
+	maxAliasesPerDomain = 2
+	domainCapAction = guardrailRefuse
+	if _, err := client.CreateAlias("login.example.com", nil); !errors.Is(err, ErrGuardrail) {
+		t.Fatalf("expected the cap to refuse a third alias, got %v", err)
+	}
+	if fake.created != 0 {
+		t.Fatalf("no alias should have been created, got %d", fake.created)
+	}
+
+	// The alias being replaced doesn't count
+	if err := client.checkDomainCap("https://example.com", &existing[0]); err != nil {
+		t.Errorf("replacing an alias should stay within the cap: %v", err)
+	}
+	if err := client.checkDomainCap("https://other.com", nil); err != nil {
+		t.Errorf("other domains are not affected: %v", err)
+	}
+
+	domainCapAction = guardrailWarn
+	if _, err := client.CreateAlias("login.example.com", nil); err != nil {
+		t.Fatalf("with warn, the alias should be created: %v", err)
+	}
+
+	maxAliasesPerDomain = 0
+	if err := client.checkDomainCap("https://example.com", nil); err != nil {
+		t.Errorf("a zero cap is disabled: %v", err)
+	}
+}
+
+func TestCreationLog(t *testing.T) {
+	oldDay, oldWeek := maxCreationsPerDay, maxCreationsPerWeek
+	t.Cleanup(func() { maxCreationsPerDay, maxCreationsPerWeek = oldDay, oldWeek })
+
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	creations := creationLog{
+		"2026-10-10": 4,
+		"2026-10-09": 7, // 8 days ago, outside the week
+		"2026-06-01": 1,
+	}
+	creations.add(now)
+	creations.add(now)
+	creations.prune(now)
+
+	if _, ok := creations["2026-06-01"]; ok {
+		t.Error("days older than the log keeps should be pruned")
+	}
+	if got := creations.since(now, 1); got != 2 {
+		t.Errorf("today = %d, want 2", got)
+	}
+	if got := creations.since(now, 7); got != 6 {
+		t.Errorf("last 7 days = %d, want 6", got)
+	}
+
+	maxCreationsPerDay, maxCreationsPerWeek = 0, 0
+	if warnings := creations.quotaWarnings(now); len(warnings) != 0 {
+		t.Errorf("no quotas are configured, got %v", warnings)
+	}
+	maxCreationsPerDay, maxCreationsPerWeek = 1, 5
+	if warnings := creations.quotaWarnings(now); len(warnings) != 2 {
+		t.Errorf("both quotas are exceeded, got %v", warnings)
+	}
+	maxCreationsPerDay, maxCreationsPerWeek = 2, 6
+	if warnings := creations.quotaWarnings(now); len(warnings) != 0 {
+		t.Errorf("reaching a quota is not exceeding it, got %v", warnings)
+	}
+}
+
+func TestCreationsAreLogged(t *testing.T) {
+	oldDay, oldWeek := maxCreationsPerDay, maxCreationsPerWeek
+	t.Cleanup(func() { maxCreationsPerDay, maxCreationsPerWeek = oldDay, oldWeek })
+	_, client := newFakeJMAP(t)
+
+	maxCreationsPerDay, maxCreationsPerWeek = 0, 0
+	if _, err := client.CreateAliasWith(AliasCreation{Domain: "https://shop.example"}); err != nil {
+		t.Fatal(err)
+	}
+	creations := creationLog{}
+	if err := readJSONFile(creationLogFile, &creations); err != nil || len(creations) != 0 {
+		t.Fatalf("creation log = %v, %v; want nothing logged without quotas or statistics", creations, err)
+	}
+
+	maxCreationsPerDay = 5
+	for _, domain := range []string{"https://news.example", "https://forum.example"} {
+		if _, err := client.CreateAliasWith(AliasCreation{Domain: domain}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := readJSONFile(creationLogFile, &creations); err != nil {
+		t.Fatal(err)
+	}
+	if got := creations.since(time.Now(), 1); got != 2 {
+		t.Errorf("creation log = %v; want the 2 creations made with a quota counted today", creations)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+)
+
+// readyTTL is how long a successful readiness check is reused, so that
+// frequent probes don't each call Fastmail
+const readyTTL = 30 * time.Second
+
+// readinessProbeID is the ID of the alias the readiness check asks for. No
+// alias has it, so the request is cheap, but it still needs a valid token.
+const readinessProbeID = "readiness-probe"
+
+// readiness is the result of the readiness checks of serve mode.
+type readiness struct {
+	Ready bool `json:"ready"`
+	// Checks are "ok" or what failed, for fastmail (the API answers), token
+	// (the API token is accepted) and session (the session is loaded)
+	Checks    map[string]string `json:"checks"`
+	CheckedAt time.Time         `json:"checkedAt"`
+}
+
+// Ready checks that the service can answer requests: Fastmail answers, the
+// token is accepted and the session is loaded. The result is reused for
+// readyTTL once the checks pass.
+func (s *aliasService) Ready() readiness {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if s.ready != nil && s.ready.Ready && now.Sub(s.ready.CheckedAt) < readyTTL {
+		return *s.ready
+	}
+
+	checks := map[string]string{"fastmail": "ok", "token": "ok", "session": "ok"}
+	fail := func(err error) {
+		err = formatAPIError("request failed", err)
+		if errorType(err) == "auth" {
+			checks["token"] = ErrUnauthorized.Error()
+			return
+		}
+		checks["fastmail"], checks["token"] = err.Error(), "unknown"
+	}
+	if _, err := s.client.GetSession(); err != nil {
+		checks["session"] = "not loaded"
+		fail(err)
+	} else if _, err := s.client.GetAliasByID(readinessProbeID); err != nil && !errors.Is(err, ErrAliasNotFound) {
+		fail(err)
+	}
+
+	result := readiness{Ready: true, Checks: checks, CheckedAt: now.UTC()}
+	for _, check := range checks {
+		result.Ready = result.Ready && check == "ok"
+	}
+	s.ready = &result
+	return result
+}
+
+// withHealth serves the health endpoints for reverse proxies and uptime
+// monitors, without authentication: /healthz answers while the process runs,
+// and /readyz once Fastmail can be used. Other requests go to next.
+func withHealth(next http.Handler, ready func() readiness) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+	mux.HandleFunc("GET /readyz", func(w http.ResponseWriter, r *http.Request) {
+		result := ready()
+		status := http.StatusOK
+		if !result.Ready {
+			noteResult(r, "notReady")
+			status = http.StatusServiceUnavailable
+		}
+		writeJSON(w, status, result)
+	})
+	mux.Handle("/", next)
+	return mux
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// roundTripFunc is a transport answering with a function.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func TestReadyzChecksFastmail(t *testing.T) {
+	fake, client := newFakeJMAP(t)
+	service := &aliasService{client: client}
+	handler := withHealth(newAPIHandler(service), service.Ready)
+
+	var result readiness
+	if code := apiRequest(t, handler, "GET", "/readyz", "", &result); code != http.StatusOK || !result.Ready {
+		t.Fatalf("readyz = %d %+v; want ready", code, result)
+	}
+	for name, check := range result.Checks {
+		if check != "ok" {
+			t.Errorf("check %s = %q", name, check)
+		}
+	}
+
+	// A recent successful check is reused
+	requests := fake.requests
+	apiRequest(t, handler, "GET", "/readyz", "", &result)
+	if fake.requests != requests {
+		t.Errorf("readyz called Fastmail again within %v", readyTTL)
+	}
+	service.ready.CheckedAt = time.Now().Add(-readyTTL)
+	apiRequest(t, handler, "GET", "/readyz", "", &result)
+	if fake.requests != requests+1 {
+		t.Errorf("readyz didn't check again after %v", readyTTL)
+	}
+}
+
+func TestReadyzReportsFailures(t *testing.T) {
+	tests := map[string]struct {
+		transport roundTripFunc
+		failed    []string
+		passed    []string
+	}{
+		"rejected token": {
+			transport: func(r *http.Request) (*http.Response, error) {
+				return &http.Response{StatusCode: http.StatusUnauthorized, Body: io.NopCloser(strings.NewReader("")), Header: http.Header{}, Request: r}, nil
+			},
+			failed: []string{"token", "session"},
+			passed: []string{"fastmail"},
+		},
+		"unreachable": {
+			transport: func(r *http.Request) (*http.Response, error) { return nil, errors.New("connection refused") },
+			failed:    []string{"fastmail", "token", "session"},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, client := newFakeJMAP(t)
+			withTransport(client, func(http.RoundTripper) http.RoundTripper { return tt.transport })
+			service := &aliasService{client: client}
+
+			var result readiness
+			code := apiRequest(t, withHealth(newAPIHandler(service), service.Ready), "GET", "/readyz", "", &result)
+			if code != http.StatusServiceUnavailable || result.Ready {
+				t.Fatalf("readyz = %d %+v; want not ready", code, result)
+			}
+			for _, check := range tt.failed {
+				if result.Checks[check] == "ok" {
+					t.Errorf("check %s passed: %+v", check, result.Checks)
+				}
+			}
+			for _, check := range tt.passed {
+				if result.Checks[check] != "ok" {
+					t.Errorf("check %s failed: %+v", check, result.Checks)
+				}
+			}
+		})
+	}
+}
+
+func TestHealthzNeedsNoToken(t *testing.T) {
+	clients := []serveClient{{Name: "phone", Token: "0123456789abcdef"}}
+	handler := withHealth(requireToken(newAPIHandler(stubAliases{}), clients, newRateLimiter(60)), func() readiness {
+		return readiness{Ready: true}
+	})
+
+	var status map[string]string
+	if code := apiRequest(t, handler, "GET", "/healthz", "", &status); code != http.StatusOK || status["status"] != "ok" {
+		t.Errorf("healthz = %d %v", code, status)
+	}
+	if code := apiRequest(t, handler, "GET", "/readyz", "", &readiness{}); code != http.StatusOK {
+		t.Errorf("readyz = %d without a token", code)
+	}
+	if code := apiRequest(t, handler, "GET", "/aliases", "", &apiError{}); code != http.StatusUnauthorized {
+		t.Errorf("the API was open without a token: %d", code)
+	}
+}
@@ -0,0 +1,405 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// maxAPIRequestBytes bounds the JSON body of an HTTP API request
+const maxAPIRequestBytes = 64 * 1024
+
+// apiParam is a query or path parameter of an HTTP API endpoint.
+type apiParam struct {
+	Name string
+	// In is "query" or "path"
+	In          string
+	Description string
+	Required    bool
+}
+
+// apiEndpoint is an operation of the HTTP API of serve mode. The routes and
+// the OpenAPI description are both generated from the endpoints, so that they
+// can't drift apart.
+type apiEndpoint struct {
+	Method string
+	// Path is a net/http pattern path, with parameters such as {email}
+	Path    string
+	ID      string
+	Summary string
+	Params  []apiParam
+	// Request is a value of the type of the JSON request body, or nil if the
+	// endpoint takes none
+	Request interface{}
+	// Response is a value of the type of the JSON response
+	Response interface{}
+	// Status is the status of a successful response
+	Status int
+	handle func(aliases aliasHandler, r *http.Request) (interface{}, error)
+}
+
+// lookupAliasRequest is the request body of getAlias.
+type lookupAliasRequest struct {
+	Site string `json:"site" doc:"URL or domain of the site"`
+}
+
+// createAliasRequest is the request body of createAlias.
+type createAliasRequest struct {
+	Site        string `json:"site" doc:"URL or domain of the site"`
+	Description string `json:"description,omitempty" doc:"description of the alias; empty selects the default one"`
+}
+
+// updateStateRequest is the request body of updateState.
+type updateStateRequest struct {
+	State AliasState `json:"state" enum:"enabled,disabled,deleted"`
+}
+
+// apiError is the response to a failed request, in the form of the --json
+// error output.
+type apiError struct {
+	Error errorObject `json:"error"`
+}
+
+// apiEndpoints are the operations of the HTTP API, the same as those of the
+// line protocol.
+var apiEndpoints = []apiEndpoint{
+	{
+		Method: http.MethodGet, Path: "/aliases", ID: "listAliases",
+		Summary:  "List the aliases of a site that aren't deleted, or every alias",
+		Params:   []apiParam{{Name: "site", In: "query", Description: "URL or domain of the site"}},
+		Response: []MaskedEmailInfo{}, Status: http.StatusOK,
+		handle: func(aliases aliasHandler, r *http.Request) (interface{}, error) {
+			return aliases.ListAliases(r.URL.Query().Get("site"))
+		},
+	},
+	{
+		Method: http.MethodPost, Path: "/aliases", ID: "createAlias",
+		Summary: "Create a new alias for a site, even if it already has one",
+		Request: createAliasRequest{}, Response: MaskedEmailInfo{}, Status: http.StatusCreated,
+		handle: func(aliases aliasHandler, r *http.Request) (interface{}, error) {
+			var req createAliasRequest
+			if err := decodeAPIRequest(r, &req); err != nil {
+				return nil, err
+			}
+			return aliases.CreateAlias(req.Site, req.Description)
+		},
+	},
+	{
+		Method: http.MethodPost, Path: "/aliases/lookup", ID: "getAlias",
+		Summary: "Get the alias for a site, created if there is none",
+		Request: lookupAliasRequest{}, Response: MaskedEmailInfo{}, Status: http.StatusOK,
+		handle: func(aliases aliasHandler, r *http.Request) (interface{}, error) {
+			var req lookupAliasRequest
+			if err := decodeAPIRequest(r, &req); err != nil {
+				return nil, err
+			}
+			return aliases.GetAlias(req.Site)
+		},
+	},
+	{
+		Method: http.MethodPut, Path: "/aliases/{email}/state", ID: "updateState",
+		Summary:  "Set the state of an alias; protected aliases are not deleted",
+		Params:   []apiParam{{Name: "email", In: "path", Description: "email address of the alias", Required: true}},
+		Request:  updateStateRequest{},
+		Response: MaskedEmailInfo{}, Status: http.StatusOK,
+		handle: func(aliases aliasHandler, r *http.Request) (interface{}, error) {
+			var req updateStateRequest
+			if err := decodeAPIRequest(r, &req); err != nil {
+				return nil, err
+			}
+			return aliases.UpdateState(r.PathValue("email"), string(req.State))
+		},
+	},
+	{
+		Method: http.MethodPost, Path: "/refresh", ID: "refresh",
+		Summary:  "Load the session again and forget the last readiness check",
+		Response: refreshResult{}, Status: http.StatusOK,
+		handle: func(aliases aliasHandler, r *http.Request) (interface{}, error) {
+			return aliases.Refresh()
+		},
+	},
+}
+
+// decodeAPIRequest decodes the JSON body of a request into v. Unknown fields
+// are rejected, so that a misspelled field isn't silently ignored. Bodies
+// must be sent as application/json: browsers only send that type across
+// origins after a preflight request, so a page can't post to the API with a
+// plain form or text body.
+func decodeAPIRequest(r *http.Request, v interface{}) error {
+	if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mediaType != "application/json" {
+		return &exitCodeError{code: exitInvalidInput, err: fmt.Errorf("invalid request body: expected Content-Type: application/json")}
+	}
+	decoder := json.NewDecoder(io.LimitReader(r.Body, maxAPIRequestBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return &exitCodeError{code: exitInvalidInput, err: fmt.Errorf("invalid request body: %w", err)}
+	}
+	return nil
+}
+
+// apiStatus returns the HTTP status of a failed request.
+func apiStatus(err error) int {
+	switch errorType(err) {
+	case "invalidInput":
+		return http.StatusBadRequest
+	case "notFound":
+		return http.StatusNotFound
+	case "protected", "deleted", "guardrail":
+		return http.StatusConflict
+	case "readOnly":
+		return http.StatusForbidden
+	case "api", "auth", "unreachable", "tlsPin", "responseTooLarge":
+		// The request was fine, but Fastmail couldn't answer it
+		return http.StatusBadGateway
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// writeJSON writes v as the JSON response with the status.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// newAPIHandler routes the HTTP API to the alias operations, and serves its
+// OpenAPI description at /openapi.json.
+func newAPIHandler(aliases aliasHandler) http.Handler {
+	mux := http.NewServeMux()
+	for _, endpoint := range apiEndpoints {
+		endpoint := endpoint
+		mux.HandleFunc(endpoint.Method+" "+endpoint.Path, func(w http.ResponseWriter, r *http.Request) {
+			result, err := endpoint.handle(aliases, r)
+			if err != nil {
+				noteResult(r, errorType(err))
+				writeJSON(w, apiStatus(err), apiError{Error: newErrorObject(err)})
+				return
+			}
+			writeJSON(w, endpoint.Status, result)
+		})
+	}
+	spec, err := json.MarshalIndent(openAPISpec(apiEndpoints), "", "  ")
+	if err != nil {
+		panic(fmt.Sprintf("failed to encode the OpenAPI description: %v", err))
+	}
+	mux.HandleFunc("GET /openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(spec)
+	})
+	return mux
+}
+
+// allowLocalHosts rejects requests whose Host isn't a loopback name or
+// address, or the host of the listen address. Without it, a page could read
+// the API through a name of its own that resolves to 127.0.0.1 (DNS
+// rebinding). It guards the API when there are no tokens; pages don't know
+// the tokens, and a reverse proxy may pass on any host.
+func allowLocalHosts(next http.Handler, listen string) http.Handler {
+	listenHost, _, _ := net.SplitHostPort(listen)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+		host = strings.TrimSuffix(strings.Trim(host, "[]"), ".")
+		ip := net.ParseIP(host)
+		if !strings.EqualFold(host, "localhost") && !(ip != nil && ip.IsLoopback()) && (listenHost == "" || !strings.EqualFold(host, listenHost)) {
+			noteResult(r, "forbidden")
+			writeJSON(w, http.StatusForbidden, apiError{Error: errorObject{
+				Type: "forbidden", Message: fmt.Sprintf("unexpected host %q: use a loopback address such as 127.0.0.1", r.Host), ExitCode: exitFailure,
+			}})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// checkListenAddress rejects addresses that other machines can reach, unless
+// remote is set: without --remote, the HTTP API has no authentication.
+func checkListenAddress(addr string, remote bool) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return &exitCodeError{code: exitInvalidInput, err: fmt.Errorf("invalid listen address %q: %w", addr, err)}
+	}
+	if remote || host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return nil
+	}
+	return &exitCodeError{code: exitInvalidInput, err: fmt.Errorf("refusing to listen on %s without --remote: only loopback addresses such as 127.0.0.1 are allowed", addr)}
+}
+
+// serveHTTP serves the HTTP API on addr until ctx is done, with TLS if a
+// certificate is given.
+func serveHTTP(ctx context.Context, addr string, handler http.Handler, certFile, keyFile string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	server := &http.Server{Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+	if certFile != "" {
+		fmt.Fprintf(os.Stderr, "Serving the HTTP API on https://%s\n", listener.Addr())
+		err = server.ServeTLS(listener, certFile, keyFile)
+	} else {
+		fmt.Fprintf(os.Stderr, "Serving the HTTP API on http://%s\n", listener.Addr())
+		err = server.Serve(listener)
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// openAPISpec describes the endpoints as an OpenAPI 3 document. The schemas
+// of the request and response bodies are derived from their Go types.
+func openAPISpec(endpoints []apiEndpoint) map[string]interface{} {
+	schemas := make(map[string]interface{})
+	paths := make(map[string]map[string]interface{})
+	errorResponse := map[string]interface{}{
+		"description": "the request failed",
+		"content":     jsonContent(schemaOf(reflect.TypeOf(apiError{}), schemas)),
+	}
+
+	for _, endpoint := range endpoints {
+		path := endpoint.Path
+		if paths[path] == nil {
+			paths[path] = make(map[string]interface{})
+		}
+
+		params := make([]interface{}, 0, len(endpoint.Params))
+		for _, param := range endpoint.Params {
+			params = append(params, map[string]interface{}{
+				"name":        param.Name,
+				"in":          param.In,
+				"description": param.Description,
+				"required":    param.Required,
+				"schema":      map[string]interface{}{"type": "string"},
+			})
+		}
+		operation := map[string]interface{}{
+			"operationId": endpoint.ID,
+			"summary":     endpoint.Summary,
+			"parameters":  params,
+			"responses": map[string]interface{}{
+				fmt.Sprint(endpoint.Status): map[string]interface{}{
+					"description": "success",
+					"content":     jsonContent(schemaOf(reflect.TypeOf(endpoint.Response), schemas)),
+				},
+				"default": errorResponse,
+			},
+		}
+		if endpoint.Request != nil {
+			operation["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content":  jsonContent(schemaOf(reflect.TypeOf(endpoint.Request), schemas)),
+			}
+		}
+		paths[path][strings.ToLower(endpoint.Method)] = operation
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "masked_fastmail",
+			"version": version,
+		},
+		"paths": paths,
+		// Tokens are only required with --tokens-file
+		"security": []interface{}{map[string]interface{}{}, map[string]interface{}{"bearerAuth": []string{}}},
+		"components": map[string]interface{}{
+			"schemas":         schemas,
+			"securitySchemes": map[string]interface{}{"bearerAuth": map[string]interface{}{"type": "http", "scheme": "bearer"}},
+		},
+	}
+}
+
+// jsonContent is the content of a JSON request or response body.
+func jsonContent(schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{"application/json": map[string]interface{}{"schema": schema}}
+}
+
+// schemaOf returns the JSON schema of a type. Structs are added to schemas
+// under their name and referenced, so that generated bindings get one type
+// for each.
+func schemaOf(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	if t == reflect.TypeOf(time.Time{}) {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		schema := schemaOf(t.Elem(), schemas)
+		if _, isRef := schema["$ref"]; isRef {
+			return map[string]interface{}{"allOf": []interface{}{schema}, "nullable": true}
+		}
+		schema["nullable"] = true
+		return schema
+	case reflect.Slice:
+		return map[string]interface{}{"type": "array", "items": schemaOf(t.Elem(), schemas)}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Struct:
+		name := strings.ToUpper(t.Name()[:1]) + t.Name()[1:]
+		if _, done := schemas[name]; !done {
+			// Mark the schema as in progress, in case the type refers to itself
+			schemas[name] = nil
+			schemas[name] = structSchema(t, schemas)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	default:
+		return map[string]interface{}{"type": "string"}
+	}
+}
+
+// structSchema returns the object schema of a struct from its JSON field
+// tags. Fields without omitempty are required.
+func structSchema(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	properties := make(map[string]interface{})
+	var required []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		name, options, _ := strings.Cut(tag, ",")
+		if !field.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		schema := schemaOf(field.Type, schemas)
+		if doc := field.Tag.Get("doc"); doc != "" {
+			schema["description"] = doc
+		}
+		if enum := field.Tag.Get("enum"); enum != "" {
+			schema["enum"] = strings.Split(enum, ",")
+		}
+		properties[name] = schema
+		if !strings.Contains(options, "omitempty") {
+			required = append(required, name)
+		}
+	}
+	schema := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
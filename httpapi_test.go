@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// apiRequest sends a request to the handler and decodes the JSON response.
+func apiRequest(t *testing.T, handler http.Handler, method, path, body string, v interface{}) int {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if v != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+			t.Fatalf("%s %s: invalid JSON response %q: %v", method, path, rec.Body.String(), err)
+		}
+	}
+	return rec.Code
+}
+
+func TestAPIHandlerEndToEnd(t *testing.T) {
+	fake, client := newFakeJMAP(t,
+		MaskedEmailInfo{ID: "a1", Email: "shop.1@fastmail.com", ForDomain: "https://example.com", State: AliasEnabled},
+	)
+	handler := newAPIHandler(&aliasService{client: client})
+
+	var alias MaskedEmailInfo
+	if code := apiRequest(t, handler, "POST", "/aliases/lookup", `{"site": "example.com"}`, &alias); code != http.StatusOK || alias.Email != "shop.1@fastmail.com" {
+		t.Fatalf("lookup = %d %+v; want the existing alias", code, alias)
+	}
+	if code := apiRequest(t, handler, "POST", "/aliases", `{"site": "example.com", "description": "Second"}`, &alias); code != http.StatusCreated || fake.created != 1 {
+		t.Fatalf("create = %d %+v; want a new alias", code, alias)
+	}
+
+	var aliases []MaskedEmailInfo
+	if code := apiRequest(t, handler, "GET", "/aliases?site=example.com", "", &aliases); code != http.StatusOK || len(aliases) != 2 {
+		t.Fatalf("list = %d %v; want 2 aliases", code, aliases)
+	}
+
+	if code := apiRequest(t, handler, "PUT", "/aliases/shop.1@fastmail.com/state", `{"state": "disabled"}`, &alias); code != http.StatusOK || fake.state("a1") != AliasDisabled {
+		t.Fatalf("update state = %d %+v; want the alias disabled", code, alias)
+	}
+
+	var refreshed refreshResult
+	if code := apiRequest(t, handler, "POST", "/refresh", "", &refreshed); code != http.StatusOK || refreshed.RefreshedAt.IsZero() {
+		t.Fatalf("refresh = %d %+v", code, refreshed)
+	}
+}
+
+func TestAPIHandlerErrors(t *testing.T) {
+	_, client := newFakeJMAP(t)
+	handler := newAPIHandler(&aliasService{client: client})
+
+	tests := []struct {
+		method, path, body string
+		status             int
+		errType            string
+	}{
+		{"POST", "/aliases/lookup", `{"site": ""}`, http.StatusBadRequest, "invalidInput"},
+		{"POST", "/aliases/lookup", `{"sight": "example.com"}`, http.StatusBadRequest, "invalidInput"},
+		{"PUT", "/aliases/missing@fastmail.com/state", `{"state": "enabled"}`, http.StatusNotFound, "notFound"},
+		{"PUT", "/aliases/missing@fastmail.com/state", `{"state": "pending"}`, http.StatusBadRequest, "invalidInput"},
+	}
+	for _, tt := range tests {
+		var response apiError
+		if code := apiRequest(t, handler, tt.method, tt.path, tt.body, &response); code != tt.status || response.Error.Type != tt.errType {
+			t.Errorf("%s %s %s = %d %+v; want %d %s", tt.method, tt.path, tt.body, code, response.Error, tt.status, tt.errType)
+		}
+	}
+	if code := apiRequest(t, handler, "DELETE", "/aliases", "", nil); code != http.StatusMethodNotAllowed {
+		t.Errorf("DELETE /aliases = %d; want 405", code)
+	}
+}
+
+func TestOpenAPISpec(t *testing.T) {
+	var spec struct {
+		OpenAPI    string                                       `json:"openapi"`
+		Paths      map[string]map[string]map[string]interface{} `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]map[string]interface{} `json:"properties"`
+				Required   []string                          `json:"required"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if code := apiRequest(t, newAPIHandler(stubAliases{}), "GET", "/openapi.json", "", &spec); code != http.StatusOK {
+		t.Fatalf("GET /openapi.json = %d", code)
+	}
+	if spec.OpenAPI != "3.0.3" {
+		t.Errorf("openapi = %q", spec.OpenAPI)
+	}
+	// Every endpoint is described, under its operation ID
+	for _, endpoint := range apiEndpoints {
+		operation := spec.Paths[endpoint.Path][strings.ToLower(endpoint.Method)]
+		if operation["operationId"] != endpoint.ID {
+			t.Errorf("%s %s is not described as %s: %v", endpoint.Method, endpoint.Path, endpoint.ID, operation)
+		}
+	}
+
+	alias := spec.Components.Schemas["MaskedEmailInfo"]
+	if alias.Properties["createdAt"]["format"] != "date-time" || alias.Properties["lastMessageAt"]["nullable"] != true {
+		t.Errorf("unexpected alias schema: %v", alias.Properties)
+	}
+	create := spec.Components.Schemas["CreateAliasRequest"]
+	if len(create.Required) != 1 || create.Required[0] != "site" {
+		t.Errorf("CreateAliasRequest requires %v; want only site", create.Required)
+	}
+	state := spec.Components.Schemas["UpdateStateRequest"].Properties["state"]
+	if enum, _ := state["enum"].([]interface{}); len(enum) != 3 {
+		t.Errorf("the state isn't an enum of 3 states: %v", state)
+	}
+	if _, ok := spec.Components.Schemas["ApiError"]; !ok {
+		t.Error("the error response has no schema")
+	}
+}
+
+func TestCheckListenAddress(t *testing.T) {
+	for _, addr := range []string{"127.0.0.1:8787", "localhost:8787", "[::1]:0"} {
+		if err := checkListenAddress(addr, false); err != nil {
+			t.Errorf("checkListenAddress(%q) = %v", addr, err)
+		}
+	}
+	for _, addr := range []string{"0.0.0.0:8787", ":8787", "192.168.1.2:80", "localhost"} {
+		if err := checkListenAddress(addr, false); exitCode(err) != exitInvalidInput {
+			t.Errorf("checkListenAddress(%q) = %v; want invalid input", addr, err)
+		}
+	}
+	if err := checkListenAddress("0.0.0.0:8787", true); err != nil {
+		t.Errorf("checkListenAddress() = %v in remote mode", err)
+	}
+}
+
+func TestRunServersStopsTheOthers(t *testing.T) {
+	failure := errors.New("address in use")
+	stopped := make(chan bool, 1)
+	err := runServers(context.Background(),
+		func(ctx context.Context) error { return failure },
+		func(ctx context.Context) error { <-ctx.Done(); stopped <- true; return nil },
+	)
+	if !errors.Is(err, failure) {
+		t.Errorf("runServers() = %v; want the failure", err)
+	}
+	if !<-stopped {
+		t.Error("the other server wasn't stopped")
+	}
+}
+
+func TestAPIRequiresJSONBodies(t *testing.T) {
+	handler := newAPIHandler(stubAliases{})
+	for _, contentType := range []string{"", "text/plain", "application/x-www-form-urlencoded", "multipart/form-data; boundary=x"} {
+		req := httptest.NewRequest("POST", "/aliases", strings.NewReader(`{"site": "example.com"}`))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "application/json") {
+			t.Errorf("Content-Type %q: %d %s; want 400", contentType, rec.Code, rec.Body.String())
+		}
+	}
+
+	req := httptest.NewRequest("POST", "/aliases/lookup", strings.NewReader(`{"site": "example.com"}`))
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("application/json with a charset: %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestAPIMalformedSite(t *testing.T) {
+	_, client := newFakeJMAP(t)
+	handler := newAPIHandler(&aliasService{client: client})
+	var body apiError
+	if code := apiRequest(t, handler, "POST", "/aliases/lookup", `{"site": "http://exa mple.com"}`, &body); code != http.StatusBadRequest || body.Error.Type != "invalidInput" {
+		t.Errorf("malformed site: %d %+v; want 400 invalidInput, not 502", code, body)
+	}
+}
+
+func TestAllowLocalHosts(t *testing.T) {
+	handler := allowLocalHosts(newAPIHandler(stubAliases{}), "127.0.0.2:8787")
+	for host, want := range map[string]int{
+		"127.0.0.1:8787":      http.StatusOK,
+		"localhost:8787":      http.StatusOK,
+		"LOCALHOST":           http.StatusOK,
+		"[::1]:8787":          http.StatusOK,
+		"127.0.0.2:8787":      http.StatusOK,
+		"attacker.example":    http.StatusForbidden,
+		"rebind.example:8787": http.StatusForbidden,
+		"":                    http.StatusForbidden,
+	} {
+		req := httptest.NewRequest("GET", "/aliases", nil)
+		req.Host = host
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != want {
+			t.Errorf("Host %q: %d; want %d", host, rec.Code, want)
+		}
+	}
+
+	named := allowLocalHosts(newAPIHandler(stubAliases{}), "devbox.lan:8787")
+	req := httptest.NewRequest("GET", "/aliases", nil)
+	req.Host = "devbox.lan:8787"
+	rec := httptest.NewRecorder()
+	named.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("the host of the listen address: %d; want 200", rec.Code)
+	}
+}
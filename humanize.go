@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Thresholds of the warnings about the age of aliases being changed
+const (
+	// newAliasAge is the age under which a pending alias may still be
+	// waiting for the confirmation mail of the site it was created for
+	newAliasAge = 48 * time.Hour
+	// oldAliasAge is the age from which an alias may be used by accounts the
+	// user no longer remembers
+	oldAliasAge = 3 * 365 * 24 * time.Hour
+)
+
+// displayLocation is the time zone timestamps are shown in; it is set by the
+// timezone setting and defaults to the local time zone
+var displayLocation = time.Local
+
+// loadTimezone returns the time zone of the timezone setting: an IANA name
+// such as "Europe/Oslo", "UTC", or "local" or "" for the local time zone.
+func loadTimezone(name string) (*time.Location, error) {
+	if name == "" || strings.EqualFold(name, "local") {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown timezone %q (expected an IANA name such as Europe/Oslo, UTC or local)", name)
+	}
+	return loc, nil
+}
+
+// dateLayout is the layout of the dates accepted by filters
+const dateLayout = "2006-01-02"
+
+// parseDate parses a date filter value: a date such as 2024-06-01, which is
+// midnight in the display time zone, or an RFC 3339 timestamp.
+func parseDate(value string) (time.Time, error) {
+	if t, err := time.ParseInLocation(dateLayout, value, displayLocation); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q (expected e.g. 2024-06-01 or 2024-06-01T15:04:05Z)", value)
+}
+
+// absoluteTimes shows timestamps as ISO 8601 instead of relative times; it is
+// set by the absolute_times setting and --absolute-times
+var absoluteTimes bool
+
+// formatTime renders a timestamp for people, relative to now ("3 days ago",
+// "in 2 hours") or as ISO 8601 in the display time zone with absoluteTimes. Porcelain
+// and exported output always use RFC 3339 in UTC instead.
+func formatTime(t time.Time) string {
+	return formatTimeAt(t, time.Now())
+}
+
+// formatTimeAt is formatTime relative to a given time.
+func formatTimeAt(t, now time.Time) string {
+	if absoluteTimes {
+		return t.In(displayLocation).Format(time.RFC3339)
+	}
+	d := now.Sub(t)
+	switch {
+	case d > -time.Minute && d < time.Minute:
+		return "just now"
+	case d < 0:
+		return "in " + humanizeDuration(d)
+	default:
+		return humanizeDuration(d) + " ago"
+	}
+}
+
+// humanizeDuration renders a duration in the largest whole unit, such as
+// "5 minutes", "3 days" or "2 years". Months are 30 days and years 365 days.
+func humanizeDuration(d time.Duration) string {
+	if d < 0 {
+		d = -d
+	}
+	units := []struct {
+		size time.Duration
+		name string
+	}{
+		{365 * 24 * time.Hour, "year"},
+		{30 * 24 * time.Hour, "month"},
+		{7 * 24 * time.Hour, "week"},
+		{24 * time.Hour, "day"},
+		{time.Hour, "hour"},
+		{time.Minute, "minute"},
+	}
+	for _, unit := range units {
+		if n := int(d / unit.size); n > 0 {
+			return plural(n, unit.name, unit.name+"s")
+		}
+	}
+	return "less than a minute"
+}
+
+// aliasCreated returns when the alias was created, or "" if the API did not
+// report it.
+func aliasCreated(alias MaskedEmailInfo, now time.Time) string {
+	if alias.CreatedAt.IsZero() {
+		return ""
+	}
+	return formatTimeAt(alias.CreatedAt, now)
+}
+
+// ageWarning returns a warning about changing the alias to the state because
+// of its age, or "" if there is nothing to warn about: a recent pending alias
+// may still be waiting for the site's confirmation mail, and a very old one
+// may be used by accounts the user has forgotten about.
+func ageWarning(alias MaskedEmailInfo, state AliasState, now time.Time) string {
+	if alias.CreatedAt.IsZero() || (state != AliasDisabled && state != AliasDeleted) {
+		return ""
+	}
+	age := now.Sub(alias.CreatedAt)
+	switch {
+	case alias.State == AliasPending && age < newAliasAge:
+		return fmt.Sprintf("%s was created %s ago and is still pending; the site may not have sent its confirmation mail yet", alias.Email, humanizeDuration(age))
+	case age >= oldAliasAge:
+		return fmt.Sprintf("%s is %s old; accounts you no longer remember may still send mail to it", alias.Email, humanizeDuration(age))
+	}
+	return ""
+}
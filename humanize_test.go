@@ -0,0 +1,106 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHumanizeDuration(t *testing.T) {
+	tests := map[time.Duration]string{
+		30 * time.Second:         "less than a minute",
+		time.Minute:              "1 minute",
+		90 * time.Minute:         "1 hour",
+		-3 * time.Hour:           "3 hours",
+		50 * time.Hour:           "2 days",
+		15 * 24 * time.Hour:      "2 weeks",
+		65 * 24 * time.Hour:      "2 months",
+		2 * 365 * 24 * time.Hour: "2 years",
+	}
+	for d, want := range tests {
+		if got := humanizeDuration(d); got != want {
+			t.Errorf("humanizeDuration(%s) = %q, want %q", d, got, want)
+		}
+	}
+}
+
+func TestAgeWarning(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	fresh := MaskedEmailInfo{Email: "new@fastmail.com", State: AliasPending, CreatedAt: now.Add(-5 * time.Hour)}
+	old := MaskedEmailInfo{Email: "old@fastmail.com", State: AliasEnabled, CreatedAt: now.AddDate(-4, 0, 0)}
+	recent := MaskedEmailInfo{Email: "recent@fastmail.com", State: AliasEnabled, CreatedAt: now.Add(-5 * time.Hour)}
+
+	if warning := ageWarning(fresh, AliasDeleted, now); !strings.Contains(warning, "created 5 hours ago and is still pending") {
+		t.Errorf("expected a pending warning, got %q", warning)
+	}
+	if warning := ageWarning(old, AliasDisabled, now); !strings.Contains(warning, "is 4 years old") {
+		t.Errorf("expected an old alias warning, got %q", warning)
+	}
+	for _, tt := range []struct {
+		alias MaskedEmailInfo
+		state AliasState
+	}{
+		{fresh, AliasEnabled},
+		{old, AliasEnabled},
+		{recent, AliasDeleted},
+		{MaskedEmailInfo{Email: "unknown@fastmail.com", State: AliasPending}, AliasDeleted},
+	} {
+		if warning := ageWarning(tt.alias, tt.state, now); warning != "" {
+			t.Errorf("expected no warning for %s to %s, got %q", tt.alias.Email, tt.state, warning)
+		}
+	}
+}
+
+func TestFormatTimeAt(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		t    time.Time
+		want string
+	}{
+		{now.Add(-20 * time.Second), "just now"},
+		{now.Add(-3 * 24 * time.Hour), "3 days ago"},
+		{now.Add(-time.Hour), "1 hour ago"},
+		{now.Add(2 * time.Hour), "in 2 hours"},
+	}
+	for _, tt := range tests {
+		if got := formatTimeAt(tt.t, now); got != tt.want {
+			t.Errorf("formatTimeAt(%v) = %q, want %q", tt.t, got, tt.want)
+		}
+	}
+
+	absoluteTimes = true
+	defer func() { absoluteTimes = false }()
+	want := now.Add(-time.Hour).Local().Format(time.RFC3339)
+	if got := formatTimeAt(now.Add(-time.Hour), now); got != want {
+		t.Errorf("with absoluteTimes, got %q, want %q", got, want)
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	oslo, err := time.LoadLocation("Europe/Oslo")
+	if err != nil {
+		t.Skip("time zone database not available")
+	}
+	displayLocation = oslo
+	defer func() { displayLocation = time.Local }()
+
+	got, err := parseDate("2024-06-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2024, 5, 31, 22, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("parseDate(2024-06-01) = %v, want midnight in Oslo (%v)", got, want)
+	}
+
+	got, err = parseDate("2024-06-01T12:00:00Z")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("parseDate with a timestamp = %v, want %v", got, want)
+	}
+
+	if _, err := parseDate("June 1st"); err == nil {
+		t.Errorf("expected an error for an unsupported date")
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// JMAP submission capability and methods
+const (
+	capabilitySubmission = "urn:ietf:params:jmap:submission"
+	methodIdentityGet    = "Identity/get"
+	methodIdentitySet    = "Identity/set"
+)
+
+// submissionCapabilities are declared by requests for sending identities
+var submissionCapabilities = []string{capabilityCore, capabilitySubmission}
+
+// Identity is an address the account can send mail as (RFC 8621).
+type Identity struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	// MayDelete is false for identities the user cannot remove, such as the
+	// account's own address
+	MayDelete bool `json:"mayDelete"`
+}
+
+// GetIdentities retrieves the sending identities of the account.
+// Requires an API token with access to sending mail.
+func (fc *FastmailClient) GetIdentities() ([]Identity, error) {
+	response, err := fc.invoke(submissionCapabilities, methodCall{
+		name: methodIdentityGet,
+		arguments: map[string]interface{}{
+			"accountId": fc.AccountID,
+		},
+		clientID: nil,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var responseData struct {
+		List []Identity `json:"list"`
+	}
+	if err := fc.methodResult(response, 0, &responseData); err != nil {
+		return nil, err
+	}
+	return responseData.List, nil
+}
+
+// CreateIdentity creates a sending identity for the address, with the given
+// display name.
+func (fc *FastmailClient) CreateIdentity(email, name string) (*Identity, error) {
+	if err := fc.ensureWritable(); err != nil {
+		return nil, err
+	}
+	logf(levelActions, "creating a sending identity for %s", email)
+
+	const createID = "identity"
+	response, err := fc.invoke(submissionCapabilities, methodCall{
+		name: methodIdentitySet,
+		arguments: map[string]interface{}{
+			"accountId": fc.AccountID,
+			"create": map[string]interface{}{
+				createID: map[string]string{"email": email, "name": name},
+			},
+		},
+		clientID: nil,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var responseData struct {
+		Created    map[string]Identity `json:"created"`
+		NotCreated map[string]SetError `json:"notCreated"`
+	}
+	if err := fc.methodResult(response, 0, &responseData); err != nil {
+		return nil, err
+	}
+	if setErr, ok := responseData.NotCreated[createID]; ok {
+		return nil, &APIError{Type: setErr.Type, Message: setErr.Description}
+	}
+	created, ok := responseData.Created[createID]
+	if !ok {
+		return nil, fmt.Errorf("server did not confirm the identity creation")
+	}
+	// The server only returns the properties it set or changed
+	created.Email = email
+	created.Name = name
+	fc.recordAudit(auditEntry{Action: auditCreateIdentity, Alias: email, ID: created.ID, Changes: map[string]string{"name": name}})
+	return &created, nil
+}
+
+// identityFor returns the identity that can send as the address, or nil if
+// there is none. An identity of the form "*@example.com" can send as any
+// address at the domain.
+func identityFor(identities []Identity, email string) *Identity {
+	email = strings.ToLower(strings.TrimSpace(email))
+	var wildcard *Identity
+	for i := range identities {
+		identityEmail := strings.ToLower(identities[i].Email)
+		if identityEmail == email {
+			return &identities[i]
+		}
+		if domain, ok := strings.CutPrefix(identityEmail, "*@"); ok && wildcard == nil && strings.HasSuffix(email, "@"+domain) {
+			wildcard = &identities[i]
+		}
+	}
+	return wildcard
+}
+
+// warnIfNotSendingIdentity warns on stderr when replies to mail sent to the
+// alias could not come from the alias itself, because it is not a sending
+// identity. Nothing is printed if the identities cannot be read, e.g.
+// because the token has no access to sending mail.
+func warnIfNotSendingIdentity(client *FastmailClient, email string) {
+	identities, err := client.GetIdentities()
+	if err != nil {
+		client.debugf("Could not check sending identities: %v", err)
+		return
+	}
+	if identityFor(identities, email) == nil {
+		fmt.Fprintf(os.Stderr, "Warning: %s is not a sending identity, so replies will be sent from another address and reveal it. Run `masked_fastmail send-as %s` to reply from the alias.\n", email, email)
+	}
+}
+
+// newIdentitiesCmd creates the command group for inspecting sending identities.
+func newIdentitiesCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "identities",
+		Short: "Inspect the addresses the account can send mail as",
+	}
+	cmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List sending identities and the addresses they send as",
+		Long: `List the sending identities of the account and the addresses they send as.
+Requires an API token with access to sending mail (not just masked email).`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, err := newClientFromCmd(cmd)
+			if err != nil {
+				return err
+			}
+			return handleIdentitiesList(client)
+		},
+	})
+	return cmd
+}
+
+// handleIdentitiesList prints the sending identities of the account, sorted
+// by address.
+func handleIdentitiesList(client *FastmailClient) error {
+	identities, err := client.GetIdentities()
+	if err != nil {
+		return formatAPIError("failed to get sending identities", err)
+	}
+	sort.Slice(identities, func(i, j int) bool {
+		return identities[i].Email < identities[j].Email
+	})
+
+	if porcelain != "" {
+		// id, email, name, deletable (yes|no)
+		for _, identity := range identities {
+			printPorcelain(identity.ID, identity.Email, identity.Name, choose(identity.MayDelete, "yes", "no"))
+		}
+		return nil
+	}
+
+	if len(identities) == 0 {
+		fmt.Println("No sending identities found")
+		return nil
+	}
+	fmt.Println("Sending identities:")
+	for _, identity := range identities {
+		if identity.Name != "" {
+			fmt.Printf("- %s (%s)\n", identity.Email, sanitizeText(identity.Name))
+		} else {
+			fmt.Printf("- %s\n", identity.Email)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIdentityFor(t *testing.T) {
+	identities := []Identity{
+		{ID: "i1", Email: "me@fastmail.com"},
+		{ID: "i2", Email: "*@example.com"},
+		{ID: "i3", Email: "Neat.Sun1234@Fastmail.com"},
+	}
+
+	cases := map[string]string{
+		"me@fastmail.com":           "i1",
+		"neat.sun1234@fastmail.com": "i3",
+		"anything@example.com":      "i2",
+		"other@fastmail.com":        "",
+		"someone@sub.example.com":   "",
+	}
+	for email, want := range cases {
+		identity := identityFor(identities, email)
+		got := ""
+		if identity != nil {
+			got = identity.ID
+		}
+		if got != want {
+			t.Errorf("identityFor(%q) = %q, want %q", email, got, want)
+		}
+	}
+}
+
+func TestIdentityForPrefersExactMatch(t *testing.T) {
+	identities := []Identity{
+		{ID: "wildcard", Email: "*@example.com"},
+		{ID: "exact", Email: "sales@example.com"},
+	}
+	if identity := identityFor(identities, "sales@example.com"); identity == nil || identity.ID != "exact" {
+		t.Fatalf("expected the exact identity, got %+v", identity)
+	}
+}
+
+func TestCreateIdentity(t *testing.T) {
+	var request JMAPRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("bad request: %v", err)
+		}
+		fmt.Fprint(w, `{"methodResponses":[["Identity/set",{"created":{"identity":{"id":"i9","mayDelete":true}}},"0"]]}`)
+	}))
+	defer server.Close()
+
+	client := &FastmailClient{
+		AccountID: "u1",
+		Token:     "token",
+		client:    server.Client(),
+		session:   &Session{APIURL: server.URL, Accounts: map[string]SessionAccount{"u1": {}}},
+	}
+	identity, err := client.CreateIdentity("neat.sun1234@fastmail.com", "Jane")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if identity.ID != "i9" || identity.Email != "neat.sun1234@fastmail.com" || identity.Name != "Jane" {
+		t.Fatalf("unexpected identity: %+v", identity)
+	}
+	if len(request.Using) != 2 || request.Using[1] != capabilitySubmission {
+		t.Fatalf("expected the submission capability, got %v", request.Using)
+	}
+}
+
+func TestCreateIdentityRejected(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"methodResponses":[["Identity/set",{"notCreated":{"identity":{"type":"forbiddenFrom","description":"not your address"}}},"0"]]}`)
+	}))
+	defer server.Close()
+
+	client := &FastmailClient{
+		AccountID: "u1",
+		Token:     "token",
+		client:    server.Client(),
+		session:   &Session{APIURL: server.URL, Accounts: map[string]SessionAccount{"u1": {}}},
+	}
+	_, err := client.CreateIdentity("someone@example.com", "")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Type != "forbiddenFrom" {
+		t.Fatalf("expected a forbiddenFrom error, got %v", err)
+	}
+}
@@ -0,0 +1,373 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	searchIndexFile = "search-index.json"
+	// maxIndexedSenders limits the senders kept per alias
+	maxIndexedSenders = 20
+	// staleIndexAge is the age after which search suggests --refresh
+	staleIndexAge = 7 * 24 * time.Hour
+)
+
+// Fields of an alias in the search index, by decreasing weight
+const (
+	indexFieldDomain      = "domain"
+	indexFieldDescription = "description"
+	indexFieldEmail       = "email"
+	indexFieldSenders     = "senders"
+)
+
+var indexFieldWeights = map[string]int{
+	indexFieldDomain:      4,
+	indexFieldDescription: 3,
+	indexFieldEmail:       2,
+	indexFieldSenders:     1,
+}
+
+// indexStopTerms are too common in domains to be worth indexing
+var indexStopTerms = map[string]bool{"http": true, "https": true, "www": true}
+
+// indexedAlias is an alias as kept in the search index.
+type indexedAlias struct {
+	State       AliasState `json:"state,omitempty"`
+	Domain      string     `json:"domain,omitempty"`
+	Description string     `json:"description,omitempty"`
+	// Senders are the addresses and names of senders seen in mail to the
+	// alias by the mail and leaks commands
+	Senders []string `json:"senders,omitempty"`
+}
+
+// fields returns the indexed text of the alias by field.
+func (a indexedAlias) fields(email string) map[string]string {
+	return map[string]string{
+		indexFieldDomain:      a.Domain,
+		indexFieldDescription: a.Description,
+		indexFieldEmail:       email,
+		indexFieldSenders:     strings.Join(a.Senders, " "),
+	}
+}
+
+// searchIndex is a local full-text index of the aliases, so that they can
+// be searched without contacting Fastmail.
+type searchIndex struct {
+	UpdatedAt time.Time               `json:"updatedAt"`
+	Aliases   map[string]indexedAlias `json:"aliases"`
+	// Terms maps each term to the emails of the aliases containing it
+	Terms map[string][]string `json:"terms"`
+
+	// sortedTerms are the keys of Terms, sorted for prefix lookups
+	sortedTerms []string
+}
+
+// indexTerms splits text into lowercase terms of letters and digits.
+func indexTerms(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	terms := words[:0]
+	for _, word := range words {
+		if len(word) > 1 && !indexStopTerms[word] {
+			terms = append(terms, word)
+		}
+	}
+	return terms
+}
+
+// reindex rebuilds the terms from the indexed aliases.
+func (idx *searchIndex) reindex() {
+	idx.Terms = make(map[string][]string)
+	for email, alias := range idx.Aliases {
+		seen := make(map[string]bool)
+		for _, text := range alias.fields(email) {
+			for _, term := range indexTerms(text) {
+				if !seen[term] {
+					seen[term] = true
+					idx.Terms[term] = append(idx.Terms[term], email)
+				}
+			}
+		}
+	}
+	idx.sortedTerms = nil
+}
+
+// update replaces the indexed aliases with the account's, keeping the
+// senders already recorded for them.
+func (idx *searchIndex) update(aliases []MaskedEmailInfo, now time.Time) {
+	previous := idx.Aliases
+	idx.Aliases = make(map[string]indexedAlias, len(aliases))
+	for _, alias := range aliases {
+		idx.Aliases[alias.Email] = indexedAlias{
+			State:       alias.State,
+			Domain:      alias.ForDomain,
+			Description: alias.Description,
+			Senders:     previous[alias.Email].Senders,
+		}
+	}
+	idx.UpdatedAt = now
+	idx.reindex()
+}
+
+// addSenders records the senders of messages received by aliases.
+func (idx *searchIndex) addSenders(messages map[string][]EmailSummary) {
+	if idx.Aliases == nil {
+		idx.Aliases = make(map[string]indexedAlias)
+	}
+	for email, summaries := range messages {
+		alias := idx.Aliases[email]
+		known := make(map[string]bool, len(alias.Senders))
+		for _, sender := range alias.Senders {
+			known[sender] = true
+		}
+		for _, message := range summaries {
+			for _, from := range message.From {
+				for _, value := range []string{strings.ToLower(from.Email), from.Name} {
+					if value != "" && !known[value] && len(alias.Senders) < maxIndexedSenders {
+						known[value] = true
+						alias.Senders = append(alias.Senders, value)
+					}
+				}
+			}
+		}
+		idx.Aliases[email] = alias
+	}
+	idx.reindex()
+}
+
+// searchHit is an alias matching a search, with the fields that matched.
+type searchHit struct {
+	Email  string
+	Alias  indexedAlias
+	Fields []string
+	score  int
+}
+
+// search returns the aliases matching every word of the query, best first.
+// Words match the start of indexed terms, so "amaz" finds amazon.com.
+func (idx *searchIndex) search(query string) []searchHit {
+	words := indexTerms(query)
+	if len(words) == 0 {
+		return nil
+	}
+	if idx.sortedTerms == nil {
+		for term := range idx.Terms {
+			idx.sortedTerms = append(idx.sortedTerms, term)
+		}
+		sort.Strings(idx.sortedTerms)
+	}
+
+	var candidates map[string]bool
+	for _, word := range words {
+		matches := make(map[string]bool)
+		for i := sort.SearchStrings(idx.sortedTerms, word); i < len(idx.sortedTerms) && strings.HasPrefix(idx.sortedTerms[i], word); i++ {
+			for _, email := range idx.Terms[idx.sortedTerms[i]] {
+				if candidates == nil || candidates[email] {
+					matches[email] = true
+				}
+			}
+		}
+		candidates = matches
+	}
+
+	hits := make([]searchHit, 0, len(candidates))
+	for email := range candidates {
+		alias := idx.Aliases[email]
+		hit := searchHit{Email: email, Alias: alias}
+		matched := make(map[string]bool)
+		for field, text := range alias.fields(email) {
+			terms := indexTerms(text)
+			for _, word := range words {
+				for _, term := range terms {
+					if strings.HasPrefix(term, word) {
+						matched[field] = true
+						hit.score += indexFieldWeights[field]
+						if term == word {
+							hit.score++
+						}
+						break
+					}
+				}
+			}
+		}
+		for _, field := range []string{indexFieldDomain, indexFieldDescription, indexFieldEmail, indexFieldSenders} {
+			if matched[field] {
+				hit.Fields = append(hit.Fields, field)
+			}
+		}
+		// Deleted aliases are only shown after the others
+		if alias.State == AliasDeleted {
+			hit.score -= 100
+		}
+		hits = append(hits, hit)
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].score != hits[j].score {
+			return hits[i].score > hits[j].score
+		}
+		return hits[i].Email < hits[j].Email
+	})
+	return hits
+}
+
+// loadSearchIndex reads the search index; a missing index is empty.
+func loadSearchIndex() (*searchIndex, error) {
+	idx := &searchIndex{}
+	if err := readJSONFile(searchIndexFile, idx); err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+// refreshSearchIndex updates the search index with all aliases of the
+// account. Failures are ignored: the index is only a cache.
+func refreshSearchIndex(aliases []MaskedEmailInfo) {
+	idx := &searchIndex{}
+	_ = updateJSONFile(searchIndexFile, idx, func() error {
+		idx.update(aliases, time.Now())
+		return nil
+	})
+}
+
+// recordSenders adds the senders of messages received by aliases to the
+// search index. Failures are ignored: the index is only a cache.
+func recordSenders(messages map[string][]EmailSummary) {
+	if len(messages) == 0 {
+		return
+	}
+	idx := &searchIndex{}
+	_ = updateJSONFile(searchIndexFile, idx, func() error {
+		idx.addSenders(messages)
+		return nil
+	})
+}
+
+// newSearchCmd creates the command that searches the local index.
+func newSearchCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "search <words>...",
+		Short: "Search aliases instantly in a local index, without contacting Fastmail",
+		Long: `Search the aliases in a local full-text index of their domains, descriptions
+and emails, and of the senders seen by the mail and leaks commands. Every word
+must match the start of a word in one of them, so "amaz prime" finds an alias
+for amazon.com described as "Prime video".
+
+The index is built on first use and updated whenever all aliases are fetched,
+e.g. by export or diff. Use --refresh to update it first.`,
+		Example: `  masked_fastmail search amazon
+  masked_fastmail search --refresh news letter
+  masked_fastmail search --count=state newsletter`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			refresh, _ := cmd.Flags().GetBool("refresh")
+			limit, _ := cmd.Flags().GetInt("limit")
+			count, err := countModeFromCmd(cmd)
+			if err != nil {
+				return err
+			}
+			var sites map[string]bool
+			if brand, _ := cmd.Flags().GetString("brand"); brand != "" {
+				if sites, err = brandSites(brand); err != nil {
+					return err
+				}
+			}
+			connect := func() (*FastmailClient, error) { return newClientFromCmd(cmd) }
+			return handleSearch(connect, strings.Join(args, " "), refresh, limit, count, sites)
+		},
+	}
+	cmd.Flags().Bool("refresh", false, "update the index from the account before searching")
+	cmd.Flags().IntP("limit", "n", 20, "maximum number of aliases to show (0 for all)")
+	cmd.Flags().String("brand", "", "only show aliases for the domains of a brand in the brands setting")
+	addCountFlag(cmd)
+	return cmd
+}
+
+// handleSearch searches the local index, building or refreshing it from the
+// account first if needed. With count, only the number of matches is
+// printed, regardless of the limit. With sites, only aliases for these
+// registrable domains are shown, see brandSites.
+func handleSearch(connect func() (*FastmailClient, error), query string, refresh bool, limit int, count string, sites map[string]bool) error {
+	if limit < 0 {
+		return fmt.Errorf("--limit must not be negative")
+	}
+	idx, err := loadSearchIndex()
+	if err != nil {
+		return fmt.Errorf("failed to read search index: %w", err)
+	}
+
+	if refresh || idx.UpdatedAt.IsZero() {
+		client, err := connect()
+		if err != nil {
+			return err
+		}
+		if idx.UpdatedAt.IsZero() {
+			fmt.Fprintln(os.Stderr, "Building the search index...")
+		}
+		aliases, err := fetchAllAliasesWithProgress(client)
+		if err != nil {
+			return formatAPIError("failed to get aliases", err)
+		}
+		// fetchAllAliasesWithProgress has saved the refreshed index
+		idx.update(aliases, time.Now())
+	} else if time.Since(idx.UpdatedAt) > staleIndexAge {
+		fmt.Fprintf(os.Stderr, "Note: the search index was last updated %s; use --refresh to update it\n", formatTime(idx.UpdatedAt))
+	}
+
+	hits := idx.search(query)
+	if sites != nil {
+		var kept []searchHit
+		for _, hit := range hits {
+			if hit.Alias.Domain != "" && sites[registrableDomain(hit.Alias.Domain)] {
+				kept = append(kept, hit)
+			}
+		}
+		hits = kept
+	}
+	if count != "" {
+		states := make([]AliasState, len(hits))
+		for i, hit := range hits {
+			states[i] = hit.Alias.State
+		}
+		printCount(states, count)
+		return nil
+	}
+	total := len(hits)
+	if limit > 0 && total > limit {
+		hits = hits[:limit]
+	}
+
+	if porcelain != "" {
+		// email, state, forDomain, description, matched fields (comma-separated)
+		for _, hit := range hits {
+			printPorcelain(hit.Email, string(hit.Alias.State), hit.Alias.Domain, hit.Alias.Description, strings.Join(hit.Fields, ","))
+		}
+		return nil
+	}
+
+	if total == 0 {
+		fmt.Printf("No aliases match %q\n", query)
+		return nil
+	}
+	for _, hit := range hits {
+		fmt.Printf("%s (%s)", hit.Email, hit.Alias.State)
+		if hit.Alias.Domain != "" {
+			fmt.Printf("  %s", hit.Alias.Domain)
+		}
+		if hit.Alias.Description != "" {
+			fmt.Printf("  %q", hit.Alias.Description)
+		}
+		fmt.Printf("  [%s]\n", strings.Join(hit.Fields, ", "))
+	}
+	if len(hits) < total {
+		fmt.Fprintf(os.Stderr, "%d more matches; use --limit 0 to show all\n", total-len(hits))
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestIndexTerms(t *testing.T) {
+	got := indexTerms("https://www.Shop-Example.co.uk Prime, 2FA a")
+	want := []string{"shop", "example", "co", "uk", "prime", "2fa"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("indexTerms = %v, want %v", got, want)
+	}
+}
+
+func TestSearchIndex(t *testing.T) {
+	idx := &searchIndex{}
+	idx.update([]MaskedEmailInfo{
+		{Email: "amazon.1@fastmail.com", ForDomain: "https://amazon.com", Description: "Prime video", State: AliasEnabled},
+		{Email: "shop.2@fastmail.com", ForDomain: "https://shop.com", Description: "Bought on amazon", State: AliasEnabled},
+		{Email: "old.3@fastmail.com", ForDomain: "https://amazon.de", State: AliasDeleted},
+		{Email: "news.4@fastmail.com", ForDomain: "https://paper.com", State: AliasEnabled},
+	}, time.Now())
+	idx.addSenders(map[string][]EmailSummary{
+		"news.4@fastmail.com": {{From: []EmailAddress{{Name: "Daily Letter", Email: "Digest@Paper.com"}}}},
+	})
+
+	emails := func(hits []searchHit) []string {
+		var out []string
+		for _, hit := range hits {
+			out = append(out, hit.Email)
+		}
+		return out
+	}
+
+	if got := emails(idx.search("amaz")); !reflect.DeepEqual(got, []string{"amazon.1@fastmail.com", "shop.2@fastmail.com", "old.3@fastmail.com"}) {
+		t.Fatalf("unexpected ranking for amaz: %v", got)
+	}
+	if got := emails(idx.search("amaz prime")); !reflect.DeepEqual(got, []string{"amazon.1@fastmail.com"}) {
+		t.Fatalf("expected every word to match, got %v", got)
+	}
+	hits := idx.search("letter")
+	if len(hits) != 1 || hits[0].Email != "news.4@fastmail.com" || !reflect.DeepEqual(hits[0].Fields, []string{indexFieldSenders}) {
+		t.Fatalf("expected a match on the senders, got %+v", hits)
+	}
+	if hits := idx.search("https"); len(hits) != 0 {
+		t.Fatalf("expected stop terms not to match, got %+v", hits)
+	}
+
+	// Refreshing from the account keeps the recorded senders
+	idx.update([]MaskedEmailInfo{{Email: "news.4@fastmail.com", ForDomain: "https://paper.com", State: AliasDisabled}}, time.Now())
+	if hits := idx.search("digest"); len(hits) != 1 || hits[0].Alias.State != AliasDisabled {
+		t.Fatalf("expected senders to survive a refresh, got %+v", hits)
+	}
+	if hits := idx.search("amazon"); len(hits) != 0 {
+		t.Fatalf("expected removed aliases to leave the index, got %+v", hits)
+	}
+}
+
+func TestSearchIndexPersisted(t *testing.T) {
+	t.Setenv(dataDirEnv, t.TempDir())
+	idx := &searchIndex{}
+	idx.update([]MaskedEmailInfo{{Email: "a@fastmail.com", ForDomain: "https://example.com"}}, time.Now())
+	if err := writeJSONFile(searchIndexFile, idx); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := loadSearchIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hits := loaded.search("exam"); len(hits) != 1 {
+		t.Fatalf("expected the loaded index to be searchable, got %+v", hits)
+	}
+}
+
+func TestSearchIndexUpdatedByFetches(t *testing.T) {
+	_, client := newFakeJMAP(t,
+		MaskedEmailInfo{ID: "a1", Email: "shop.1@fastmail.com", ForDomain: "https://amazon.com", State: AliasEnabled},
+		MaskedEmailInfo{ID: "a2", Email: "news.2@fastmail.com", ForDomain: "https://example.org", State: AliasEnabled},
+	)
+	if _, err := fetchAllAliasesWithProgress(client); err != nil {
+		t.Fatal(err)
+	}
+	recordSenders(map[string][]EmailSummary{
+		"news.2@fastmail.com": {{From: []EmailAddress{{Name: "Weekly Digest", Email: "digest@example.org"}}}},
+	})
+
+	idx, err := loadSearchIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hits := idx.search("amaz"); len(hits) != 1 || hits[0].Email != "shop.1@fastmail.com" {
+		t.Errorf("search(amaz) = %+v; want the fetched alias", hits)
+	}
+	if hits := idx.search("weekly"); len(hits) != 1 || hits[0].Email != "news.2@fastmail.com" {
+		t.Errorf("search(weekly) = %+v; want the alias of the recorded sender", hits)
+	}
+}
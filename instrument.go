@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// commandStats accumulates the API calls of the clients created for the
+// current command, for the summary logged at levelAPI.
+var commandStats callStats
+
+// callStats counts the API calls a client made and the objects they changed.
+// A nil *callStats records nothing.
+type callStats struct {
+	Calls     int
+	Duration  time.Duration
+	Created   int
+	Updated   int
+	Destroyed int
+}
+
+// recordCall records one API call that took d.
+func (s *callStats) recordCall(d time.Duration) {
+	if s == nil {
+		return
+	}
+	s.Calls++
+	s.Duration += d
+}
+
+// recordChanges counts the objects created, updated and destroyed by the
+// /set method responses in a JMAP response.
+func (s *callStats) recordChanges(response *JMAPResponse) {
+	if s == nil {
+		return
+	}
+	for _, methodResponse := range response.MethodResponses {
+		var name string
+		if len(methodResponse) < 2 || json.Unmarshal(methodResponse[0], &name) != nil || !strings.HasSuffix(name, "/set") {
+			continue
+		}
+		var changes struct {
+			Created   map[string]json.RawMessage `json:"created"`
+			Updated   map[string]json.RawMessage `json:"updated"`
+			Destroyed []string                   `json:"destroyed"`
+		}
+		if json.Unmarshal(methodResponse[1], &changes) != nil {
+			continue
+		}
+		s.Created += len(changes.Created)
+		s.Updated += len(changes.Updated)
+		s.Destroyed += len(changes.Destroyed)
+	}
+}
+
+// String summarizes the calls, e.g. "2 API calls, 1.2s total, 1 object created".
+func (s callStats) String() string {
+	parts := []string{
+		plural(s.Calls, "API call", "API calls"),
+		fmt.Sprintf("%.1fs total", s.Duration.Seconds()),
+	}
+	for _, change := range []struct {
+		count int
+		verb  string
+	}{{s.Created, "created"}, {s.Updated, "updated"}, {s.Destroyed, "destroyed"}} {
+		if change.count > 0 {
+			parts = append(parts, plural(change.count, "object", "objects")+" "+change.verb)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// plural formats a count with the singular or plural noun.
+func plural(n int, singular, pluralForm string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, singular)
+	}
+	return fmt.Sprintf("%d %s", n, pluralForm)
+}
+
+// printCommandStats logs a summary of the command's API calls at levelAPI.
+func printCommandStats() {
+	if verbosity >= levelAPI && commandStats.Calls > 0 {
+		logf(levelAPI, "%s", commandStats)
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestCallStatsString(t *testing.T) {
+	tests := []struct {
+		stats    callStats
+		expected string
+	}{
+		{callStats{Calls: 1, Duration: 300 * time.Millisecond}, "1 API call, 0.3s total"},
+		{callStats{Calls: 2, Duration: 1200 * time.Millisecond, Created: 1}, "2 API calls, 1.2s total, 1 object created"},
+		{callStats{Calls: 3, Duration: 2 * time.Second, Updated: 4, Destroyed: 1}, "3 API calls, 2.0s total, 4 objects updated, 1 object destroyed"},
+	}
+	for _, tt := range tests {
+		if got := tt.stats.String(); got != tt.expected {
+			t.Fatalf("String() = %q, want %q", got, tt.expected)
+		}
+	}
+}
+
+func TestCallStatsRecordChanges(t *testing.T) {
+	response := &JMAPResponse{MethodResponses: [][]json.RawMessage{
+		{json.RawMessage(`"MaskedEmail/get"`), json.RawMessage(`{"list": [{"id": "1"}]}`), json.RawMessage(`"0"`)},
+		{json.RawMessage(`"MaskedEmail/set"`), json.RawMessage(`{"created": {"new": {"id": "2"}}, "updated": {"1": null, "3": null}}`), json.RawMessage(`"1"`)},
+	}}
+
+	stats := &callStats{}
+	stats.recordChanges(response)
+	if stats.Created != 1 || stats.Updated != 2 || stats.Destroyed != 0 {
+		t.Fatalf("unexpected changes recorded: %+v", stats)
+	}
+
+	// A nil *callStats records nothing
+	var none *callStats
+	none.recordChanges(response)
+	none.recordCall(time.Second)
+}
@@ -0,0 +1,16 @@
+package main
+
+import "github.com/spf13/cobra"
+
+// newIntegrationsCmd creates the command grouping the generators of
+// integrations with other tools.
+func newIntegrationsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "integrations",
+		Short: "Generate the configuration of integrations with other tools",
+		Long: `Generate the configuration that lets other tools get aliases from
+masked_fastmail, such as text expanders.`,
+	}
+	cmd.AddCommand(newEspansoCmd())
+	return cmd
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// apiURL is the default JMAP API endpoint, used until the session object
+// names another one
+const apiURL = "https://api.fastmail.com/jmap/api"
+
+// APIError represents an error from the Fastmail API
+type APIError struct {
+	// StatusCode is the HTTP status code (0 if not applicable)
+	StatusCode int
+	// Type is the JMAP error type (empty for HTTP errors)
+	Type string
+	// Message is the error message
+	Message string
+	// ResponseBody is the raw response body for debugging
+	ResponseBody string
+	// Incident is the problem reported on the Fastmail status page, if it
+	// was checked after repeated server errors
+	Incident string
+}
+
+func (e *APIError) Error() string {
+	if e.StatusCode > 0 {
+		return fmt.Sprintf("API error (HTTP %d): %s%s", e.StatusCode, e.Message, e.incidentSuffix())
+	}
+	if e.Type != "" {
+		return fmt.Sprintf("API error (%s): %s", e.Type, e.Message)
+	}
+	return fmt.Sprintf("API error: %s", e.Message)
+}
+
+// incidentSuffix describes the status page incident, if any, for appending
+// to an error message.
+func (e *APIError) incidentSuffix() string {
+	if e.Incident == "" {
+		return ""
+	}
+	return fmt.Sprintf("\nFastmail is reporting an incident: %s", e.Incident)
+}
+
+// JMAPRequest is a JMAP request object: the capabilities it uses and its
+// method calls, each a [name, arguments, callId] triple.
+type JMAPRequest struct {
+	Using       []string            `json:"using"`
+	MethodCalls [][]json.RawMessage `json:"methodCalls"`
+}
+
+// JMAPResponse is a JMAP response object. The arguments of each method
+// response are left undecoded for the capability that made the call.
+type JMAPResponse struct {
+	MethodResponses [][]json.RawMessage `json:"methodResponses"`
+	SessionState    string              `json:"sessionState,omitempty"`
+	MethodErrors    []interface{}       `json:"methodErrors,omitempty"`
+}
+
+// resultReference is a JMAP back-reference to the result of an earlier call
+// in the same request.
+type resultReference struct {
+	ResultOf string `json:"resultOf"`
+	Name     string `json:"name"`
+	Path     string `json:"path"`
+}
+
+// JMAPError represents a JMAP method error
+type JMAPError struct {
+	Type    string `json:"type"`
+	Message string `json:"message,omitempty"`
+	// Description is where RFC 8620 puts the explanation
+	Description string `json:"description,omitempty"`
+}
+
+// methodCall represents a JMAP method call
+type methodCall struct {
+	arguments interface{}
+	clientID  interface{}
+	name      string
+}
+
+// buildRequestUsing builds a JMAP request declaring the given capabilities.
+func (fc *FastmailClient) buildRequestUsing(using []string, calls ...methodCall) (*JMAPRequest, error) {
+	methodCalls := make([][]json.RawMessage, len(calls))
+
+	for i, call := range calls {
+		name, err := json.Marshal(call.name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal method name: %w", err)
+		}
+		args, err := json.Marshal(call.arguments)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal method arguments: %w", err)
+		}
+		clientID, err := json.Marshal(call.clientID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal client ID: %w", err)
+		}
+
+		methodCalls[i] = []json.RawMessage{name, args, clientID}
+	}
+
+	return &JMAPRequest{
+		Using:       using,
+		MethodCalls: methodCalls,
+	}, nil
+}
+
+// invoke builds a request declaring the given capabilities from the method
+// calls and sends it.
+func (fc *FastmailClient) invoke(using []string, calls ...methodCall) (*JMAPResponse, error) {
+	payload, err := fc.buildRequestUsing(using, calls...)
+	if err != nil {
+		return nil, err
+	}
+	return fc.sendRequest(payload)
+}
+
+// sendRequest sends a JMAP request with the default timeout.
+func (fc *FastmailClient) sendRequest(payload *JMAPRequest) (*JMAPResponse, error) {
+	return fc.sendRequestFor(opDefault, payload)
+}
+
+// sendRequestFor sends a JMAP request with the timeout of the given operation.
+func (fc *FastmailClient) sendRequestFor(op operation, payload *JMAPRequest) (*JMAPResponse, error) {
+	var result JMAPResponse
+	err := fc.postJMAP(op, payload, func(body io.Reader) error {
+		if err := decodeJSON(body, &result); err != nil {
+			return fmt.Errorf("failed to unmarshal JSON response: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	fc.checkSessionState(result.SessionState)
+
+	// Validate JMAP error responses
+	if err := fc.validateJMAPResponse(&result); err != nil {
+		return nil, err
+	}
+	fc.stats.recordChanges(&result)
+
+	return &result, nil
+}
+
+// postJMAP sends a JMAP request with the timeout of the given operation and
+// passes the response body to decode.
+func (fc *FastmailClient) postJMAP(op operation, payload *JMAPRequest, decode func(io.Reader) error) error {
+	jsonPayload, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	body := jsonPayload
+	compressed := fc.CompressRequests && len(jsonPayload) >= compressRequestThreshold
+	if compressed {
+		if body, err = gzipBytes(jsonPayload); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequest("POST", fc.apiEndpoint(), bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if compressed {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+
+	return fc.doHTTP(req, jsonPayload, op, decode)
+}
+
+// validateJMAPResponse checks for JMAP errors in the response
+func (fc *FastmailClient) validateJMAPResponse(response *JMAPResponse) error {
+	// Check for top-level methodErrors
+	if len(response.MethodErrors) > 0 {
+		return &APIError{
+			Type:    "methodError",
+			Message: fmt.Sprintf("JMAP method errors in response: %v", response.MethodErrors),
+		}
+	}
+
+	// Check if MethodResponses is empty
+	if len(response.MethodResponses) == 0 {
+		return fmt.Errorf("failed to process JMAP response: empty MethodResponses array")
+	}
+
+	// Check each method response for errors
+	for i, methodResponse := range response.MethodResponses {
+		if len(methodResponse) == 0 {
+			return fmt.Errorf("failed to process JMAP response: empty method response at index %d", i)
+		}
+
+		// Check if method name indicates an error
+		var methodName string
+		if err := json.Unmarshal(methodResponse[0], &methodName); err != nil {
+			return fmt.Errorf("failed to unmarshal method name at index %d: %w", i, err)
+		}
+
+		if isErrorResponse(methodName) {
+			// Try to extract error details
+			if len(methodResponse) > 1 {
+				var jmapError JMAPError
+				if err := json.Unmarshal(methodResponse[1], &jmapError); err == nil {
+					message := jmapError.Message
+					if message == "" {
+						message = jmapError.Description
+					}
+					return &APIError{
+						Type:    jmapError.Type,
+						Message: message,
+					}
+				}
+				// If we can't parse the error structure, return the raw JSON
+				return &APIError{
+					Type:         "unknown",
+					Message:      fmt.Sprintf("JMAP error in method '%s': %s", methodName, string(methodResponse[1])),
+					ResponseBody: string(methodResponse[1]),
+				}
+			}
+			return &APIError{
+				Type:    "unknown",
+				Message: fmt.Sprintf("JMAP error in method '%s'", methodName),
+			}
+		}
+
+		// Validate that the response has at least method name and response data
+		if len(methodResponse) < 2 {
+			return fmt.Errorf("failed to validate method response structure at index %d: expected at least 2 elements, got %d", i, len(methodResponse))
+		}
+	}
+
+	return nil
+}
+
+// validateMethodResponse validates that a specific method response in the JMAP response
+// has the expected structure before accessing it. Returns an error if the response
+// structure is invalid.
+func (fc *FastmailClient) validateMethodResponse(response *JMAPResponse, index int, minElements int) error {
+	if len(response.MethodResponses) == 0 {
+		return fmt.Errorf("failed to validate response structure: MethodResponses is empty")
+	}
+	if index >= len(response.MethodResponses) {
+		return fmt.Errorf("failed to validate response structure: method response index %d out of range (have %d responses)", index, len(response.MethodResponses))
+	}
+	if len(response.MethodResponses[index]) < minElements {
+		return fmt.Errorf("failed to validate response structure: method response at index %d has %d elements, expected at least %d", index, len(response.MethodResponses[index]), minElements)
+	}
+	return nil
+}
+
+// isErrorResponse reports whether a method response name denotes an error.
+// RFC 8620 names method errors "error"; older Fastmail responses used the
+// method name followed by "/error".
+func isErrorResponse(methodName string) bool {
+	return methodName == "error" || strings.HasSuffix(methodName, "/error")
+}
+
+// methodResult decodes the arguments of the method response at index into v,
+// after checking that the response is there.
+func (fc *FastmailClient) methodResult(response *JMAPResponse, index int, v interface{}) error {
+	if err := fc.validateMethodResponse(response, index, 2); err != nil {
+		return err
+	}
+	name := ""
+	_ = json.Unmarshal(response.MethodResponses[index][0], &name)
+	if err := json.Unmarshal(response.MethodResponses[index][1], v); err != nil {
+		return fmt.Errorf("failed to parse %s response: %w", name, err)
+	}
+	return nil
+}
+
+// isOutcomeUnknown reports whether a request error leaves it unclear if the
+// server applied the request, i.e. transport failures and server errors.
+func isOutcomeUnknown(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode >= 500
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
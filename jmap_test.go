@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestIsOutcomeUnknown(t *testing.T) {
+	if isOutcomeUnknown(&APIError{StatusCode: 400}) {
+		t.Fatalf("client errors should not be treated as unknown outcomes")
+	}
+	if !isOutcomeUnknown(&APIError{StatusCode: 502}) {
+		t.Fatalf("server errors should be treated as unknown outcomes")
+	}
+	if !isOutcomeUnknown(&net.DNSError{IsTimeout: true}) {
+		t.Fatalf("network errors should be treated as unknown outcomes")
+	}
+}
+
+func TestIsErrorResponse(t *testing.T) {
+	for name, want := range map[string]bool{
+		"error":             true,
+		"MaskedEmail/error": true,
+		"MaskedEmail/get":   false,
+		"Identity/get":      false,
+		"errors":            false,
+	} {
+		if got := isErrorResponse(name); got != want {
+			t.Errorf("isErrorResponse(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestValidateJMAPResponseMethodError(t *testing.T) {
+	response := &JMAPResponse{MethodResponses: [][]json.RawMessage{{
+		json.RawMessage(`"error"`),
+		json.RawMessage(`{"type":"unknownMethod","description":"MaskedEmail/frob"}`),
+		json.RawMessage(`"0"`),
+	}}}
+
+	err := (&FastmailClient{}).validateJMAPResponse(response)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Type != "unknownMethod" || apiErr.Message != "MaskedEmail/frob" {
+		t.Fatalf("expected unknownMethod API error, got %v", err)
+	}
+}
+
+func TestMethodResult(t *testing.T) {
+	client := &FastmailClient{}
+	response := &JMAPResponse{MethodResponses: [][]json.RawMessage{{
+		json.RawMessage(`"Mailbox/get"`),
+		json.RawMessage(`{"list":[{"id":"m1","name":"Inbox"}]}`),
+		json.RawMessage(`"0"`),
+	}}}
+
+	var result struct {
+		List []Mailbox `json:"list"`
+	}
+	if err := client.methodResult(response, 0, &result); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(result.List) != 1 || result.List[0].Name != "Inbox" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if err := client.methodResult(response, 1, &result); err == nil {
+		t.Fatalf("expected an error for a missing method response")
+	}
+}
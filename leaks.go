@@ -0,0 +1,248 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// leakReport summarizes the senders of one alias's recent messages.
+type leakReport struct {
+	Alias MaskedEmailInfo
+	// Messages is the number of recent messages examined
+	Messages int
+	// Unrelated counts messages per sender domain unrelated to the alias's domain
+	Unrelated map[string]int
+}
+
+// UnrelatedMessages returns the number of messages from unrelated senders.
+func (r leakReport) UnrelatedMessages() int {
+	total := 0
+	for _, count := range r.Unrelated {
+		total += count
+	}
+	return total
+}
+
+// aliasOrigin returns the domain an alias was created for, falling back to
+// the description for aliases created without a forDomain.
+func aliasOrigin(alias MaskedEmailInfo) string {
+	if strings.TrimSpace(alias.ForDomain) != "" {
+		return alias.ForDomain
+	}
+	return alias.Description
+}
+
+// analyzeSenders compares the sender domains of the messages against the
+// registrable domain the alias was created for.
+func analyzeSenders(alias MaskedEmailInfo, messages []EmailSummary) leakReport {
+	report := leakReport{
+		Alias:     alias,
+		Messages:  len(messages),
+		Unrelated: make(map[string]int),
+	}
+
+	expected := registrableDomain(aliasOrigin(alias))
+	for _, message := range messages {
+		if len(message.From) == 0 {
+			continue
+		}
+		address := message.From[0].Email
+		at := strings.LastIndex(address, "@")
+		if at == -1 {
+			continue
+		}
+		senderDomain := registrableDomain(address[at+1:])
+		if senderDomain == "" || senderDomain == expected {
+			continue
+		}
+		report.Unrelated[senderDomain]++
+	}
+	return report
+}
+
+// rankLeakReports returns the reports with unrelated senders, most
+// suspicious first: by number of unrelated messages, then by number of
+// distinct unrelated sender domains.
+func rankLeakReports(reports []leakReport) []leakReport {
+	var suspicious []leakReport
+	for _, report := range reports {
+		if len(report.Unrelated) > 0 {
+			suspicious = append(suspicious, report)
+		}
+	}
+
+	sort.SliceStable(suspicious, func(i, j int) bool {
+		a, b := suspicious[i], suspicious[j]
+		if a.UnrelatedMessages() != b.UnrelatedMessages() {
+			return a.UnrelatedMessages() > b.UnrelatedMessages()
+		}
+		if len(a.Unrelated) != len(b.Unrelated) {
+			return len(a.Unrelated) > len(b.Unrelated)
+		}
+		return a.Alias.Email < b.Alias.Email
+	})
+	return suspicious
+}
+
+// newLeaksCmd creates the command that reports aliases that are likely leaked.
+func newLeaksCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "leaks",
+		Short: "Report aliases receiving mail from senders unrelated to their domain",
+		Long: `Report aliases receiving mail from senders unrelated to the domain they were
+created for. Such aliases have probably been sold or leaked.
+Requires an API token with access to mail (not just masked email).`,
+		Example: `  masked_fastmail leaks --limit 50`,
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			limit, _ := cmd.Flags().GetInt("limit")
+			client, err := newClientFromCmd(cmd)
+			if err != nil {
+				return err
+			}
+			return handleLeakReport(client, limit)
+		},
+	}
+	cmd.Flags().IntP("limit", "n", defaultMessageLimit, "number of recent messages to examine per alias")
+	return cmd
+}
+
+// handleLeakReport prints a ranked report of aliases with unrelated senders.
+func handleLeakReport(client *FastmailClient, limit int) error {
+	if limit <= 0 {
+		return fmt.Errorf("--limit must be positive")
+	}
+
+	aliases, err := fetchAllAliasesWithProgress(client)
+	if err != nil {
+		return formatAPIError("failed to get aliases", err)
+	}
+
+	var candidates []MaskedEmailInfo
+	for _, alias := range aliases {
+		if alias.State == AliasDeleted || registrableDomain(aliasOrigin(alias)) == "" {
+			continue
+		}
+		candidates = append(candidates, alias)
+	}
+
+	emails := make([]string, len(candidates))
+	for i, alias := range candidates {
+		emails[i] = alias.Email
+	}
+
+	messages, err := client.RecentMessagesFor(emails, limit)
+	if err != nil {
+		return formatAPIError("failed to get messages", err)
+	}
+	recordSenders(messages)
+
+	reports := make([]leakReport, 0, len(candidates))
+	for _, alias := range candidates {
+		reports = append(reports, analyzeSenders(alias, messages[alias.Email]))
+	}
+
+	suspicious := rankLeakReports(reports)
+	if porcelain != "" {
+		// email, state, origin domain, unrelated messages, messages checked, unrelated sender domains
+		for _, report := range suspicious {
+			domains := make([]string, 0, len(report.Unrelated))
+			for domain := range report.Unrelated {
+				domains = append(domains, domain)
+			}
+			sort.Strings(domains)
+			printPorcelain(report.Alias.Email, string(report.Alias.State), aliasOrigin(report.Alias),
+				strconv.Itoa(report.UnrelatedMessages()), strconv.Itoa(report.Messages), strings.Join(domains, ","))
+		}
+		return nil
+	}
+	if len(suspicious) == 0 {
+		fmt.Printf("No unrelated senders found across %d aliases\n", len(candidates))
+		return nil
+	}
+
+	fmt.Println("Aliases receiving mail from unrelated senders (most suspicious first):")
+	for idx, report := range suspicious {
+		domains := make([]string, 0, len(report.Unrelated))
+		for domain := range report.Unrelated {
+			domains = append(domains, domain)
+		}
+		sort.Slice(domains, func(i, j int) bool {
+			if report.Unrelated[domains[i]] != report.Unrelated[domains[j]] {
+				return report.Unrelated[domains[i]] > report.Unrelated[domains[j]]
+			}
+			return domains[i] < domains[j]
+		})
+		senders := make([]string, len(domains))
+		for i, domain := range domains {
+			senders[i] = fmt.Sprintf("%s (%d)", domain, report.Unrelated[domain])
+		}
+
+		if idx > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("%d. %s (state: %s)\n", idx+1, report.Alias.Email, report.Alias.State)
+		fmt.Printf("   Domain:    %s\n", aliasOrigin(report.Alias))
+		fmt.Printf("   Unrelated: %d of %d messages from %s\n", report.UnrelatedMessages(), report.Messages, strings.Join(senders, ", "))
+		fmt.Printf("   Rotate:    masked_fastmail rotate %s\n", report.Alias.Email)
+	}
+	return nil
+}
+
+// newRotateCmd creates the command that replaces an alias with a new one.
+func newRotateCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:     "rotate <alias>",
+		Short:   "Replace an alias with a new one for the same domain and disable the old one",
+		Example: `  masked_fastmail rotate user.1234@fastmail.com`,
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, err := newClientFromCmd(cmd)
+			if err != nil {
+				return err
+			}
+			return handleRotate(client, args[0])
+		},
+	}
+}
+
+// handleRotate creates a new alias with the same domain and description as
+// the given one, then disables the old alias.
+func handleRotate(client *FastmailClient, identifier string) error {
+	email, err := normalizeEmailInput(identifier)
+	if err != nil {
+		return err
+	}
+
+	oldAlias, err := client.GetAliasByEmail(email)
+	if err != nil {
+		return formatAPIError("failed to get alias", err)
+	}
+	if strings.TrimSpace(oldAlias.ForDomain) == "" {
+		return fmt.Errorf("alias %s has no domain; create a replacement manually", oldAlias.Email)
+	}
+
+	// The replacement is created and the old alias disabled in one request
+	description := oldAlias.Description
+	creation := AliasCreation{Domain: oldAlias.ForDomain, Description: &description}
+	if oldAlias.State != AliasDisabled && oldAlias.State != AliasDeleted {
+		creation.Replaces = oldAlias
+	}
+	newAlias, err := client.CreateAliasWith(creation)
+	if err != nil {
+		return formatAPIError("failed to create replacement alias", err)
+	}
+	recordUsage(eventCreated)
+	recordOwnership(newAlias.Email)
+	fmt.Fprintf(humanOut, "Created %s for %s\n", newAlias.Email, newAlias.ForDomain)
+	if creation.Replaces != nil {
+		fmt.Fprintf(humanOut, "Disabled %s\n", oldAlias.Email)
+	}
+
+	printAndCopyAlias(newAlias, true)
+	return nil
+}
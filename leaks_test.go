@@ -0,0 +1,37 @@
+package main
+
+import "testing"
+
+func TestAnalyzeSenders(t *testing.T) {
+	alias := MaskedEmailInfo{Email: "a@fastmail.com", ForDomain: "https://shop.example.com"}
+	messages := []EmailSummary{
+		{From: []EmailAddress{{Email: "news@mail.example.com"}}},
+		{From: []EmailAddress{{Email: "deals@spam.biz"}}},
+		{From: []EmailAddress{{Email: "more@spam.biz"}}},
+		{},
+	}
+
+	report := analyzeSenders(alias, messages)
+	if report.Messages != 4 {
+		t.Fatalf("expected 4 messages examined, got %d", report.Messages)
+	}
+	if report.UnrelatedMessages() != 2 || report.Unrelated["spam.biz"] != 2 {
+		t.Fatalf("expected two unrelated messages from spam.biz, got %+v", report.Unrelated)
+	}
+}
+
+func TestRankLeakReports(t *testing.T) {
+	reports := []leakReport{
+		{Alias: MaskedEmailInfo{Email: "clean@fastmail.com"}, Unrelated: map[string]int{}},
+		{Alias: MaskedEmailInfo{Email: "some@fastmail.com"}, Unrelated: map[string]int{"a.com": 1}},
+		{Alias: MaskedEmailInfo{Email: "many@fastmail.com"}, Unrelated: map[string]int{"a.com": 2, "b.com": 3}},
+	}
+
+	ranked := rankLeakReports(reports)
+	if len(ranked) != 2 {
+		t.Fatalf("expected aliases without unrelated senders to be dropped, got %d reports", len(ranked))
+	}
+	if ranked[0].Alias.Email != "many@fastmail.com" {
+		t.Fatalf("expected alias with most unrelated mail first, got %s", ranked[0].Alias.Email)
+	}
+}
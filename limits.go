@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+// newLimitsCmd creates the command that displays the server limits relevant
+// to masked email operations.
+func newLimitsCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "limits",
+		Short: "Show account limits relevant to masked email operations",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, err := newClientFromCmd(cmd)
+			if err != nil {
+				return err
+			}
+			return handleLimits(client)
+		},
+	}
+}
+
+// handleLimits prints the session capability limits for the account.
+func handleLimits(client *FastmailClient) error {
+	session, err := client.GetSession()
+	if err != nil {
+		return formatAPIError("failed to get session", err)
+	}
+	limits := session.CoreLimits()
+
+	if porcelain != "" {
+		// limit name, value
+		printPorcelain("maxCallsInRequest", strconv.Itoa(limits.MaxCallsInRequest))
+		printPorcelain("maxObjectsInGet", strconv.Itoa(limits.MaxObjectsInGet))
+		printPorcelain("maxObjectsInSet", strconv.Itoa(limits.MaxObjectsInSet))
+		printPorcelain("maxSizeRequest", strconv.Itoa(limits.MaxSizeRequest))
+		printPorcelain("maxConcurrentRequests", strconv.Itoa(limits.MaxConcurrentRequests))
+		return nil
+	}
+
+	fmt.Printf("Limits for %s:\n", session.Username)
+	fmt.Printf("  Max calls per request:    %d\n", limits.MaxCallsInRequest)
+	fmt.Printf("  Max objects per get:      %d\n", limits.MaxObjectsInGet)
+	fmt.Printf("  Max objects per set:      %d\n", limits.MaxObjectsInSet)
+	fmt.Printf("  Max request size:         %s\n", formatBytes(limits.MaxSizeRequest))
+	fmt.Printf("  Max concurrent requests:  %d\n", limits.MaxConcurrentRequests)
+	return nil
+}
+
+// formatBytes renders a byte count using binary units.
+func formatBytes(n int) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := unit, 0
+	for value := n / unit; value >= unit; value /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
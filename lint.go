@@ -0,0 +1,257 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Severities of inventory lint issues. Errors stop sync; warnings are only
+// reported by sync --lint.
+const (
+	severityError   = "error"
+	severityWarning = "warning"
+)
+
+// inventoryFields are the keys of an inventory entry
+var inventoryFields = []string{"email", "domain", "description", "state"}
+
+// inventoryStates are the states an inventory entry may declare
+var inventoryStates = []AliasState{AliasEnabled, AliasDisabled, AliasDeleted, AliasPending}
+
+// yamlErrorLine extracts the line from a YAML syntax error
+var yamlErrorLine = regexp.MustCompile(`^yaml: line (\d+): (.*)$`)
+
+// lintIssue is a problem found in an inventory file. Line and Column are
+// 1-based; 0 means the position is unknown.
+type lintIssue struct {
+	Line     int
+	Column   int
+	Severity string
+	Message  string
+}
+
+// format returns the issue in the file:line:column format of compilers.
+func (i lintIssue) format(path string) string {
+	position := path
+	if i.Line > 0 {
+		position += ":" + strconv.Itoa(i.Line)
+	}
+	if i.Column > 0 {
+		position += ":" + strconv.Itoa(i.Column)
+	}
+	return fmt.Sprintf("%s: %s: %s", position, i.Severity, i.Message)
+}
+
+// inventoryError reports the lint errors that make an inventory unusable.
+type inventoryError struct {
+	path   string
+	issues []lintIssue
+}
+
+func (e *inventoryError) Error() string {
+	path := choose(e.path != "", e.path, "inventory")
+	if len(e.issues) == 1 {
+		return e.issues[0].format(path)
+	}
+	lines := []string{fmt.Sprintf("%s has %d errors:", path, len(e.issues))}
+	for _, issue := range e.issues {
+		lines = append(lines, "  "+issue.format(path))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// lintErrors returns an *inventoryError for the error-level issues, or nil if
+// there are none.
+func lintErrors(issues []lintIssue) error {
+	var errs []lintIssue
+	for _, issue := range issues {
+		if issue.Severity == severityError {
+			errs = append(errs, issue)
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return &inventoryError{issues: errs}
+}
+
+// lintInventory checks an inventory file without contacting the API:
+// structure, unknown fields, invalid emails, domains and states, duplicate
+// entries and missing descriptions. Issues are in file order.
+func lintInventory(data []byte) []lintIssue {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		issue := lintIssue{Severity: severityError, Message: strings.TrimPrefix(err.Error(), "yaml: ")}
+		if match := yamlErrorLine.FindStringSubmatch(err.Error()); match != nil {
+			issue.Line, _ = strconv.Atoi(match[1])
+			issue.Message = match[2]
+		}
+		return []lintIssue{issue}
+	}
+	if doc.Kind == 0 {
+		return nil
+	}
+
+	var issues []lintIssue
+	report := func(node *yaml.Node, severity, format string, args ...interface{}) {
+		issues = append(issues, lintIssue{Line: node.Line, Column: node.Column, Severity: severity, Message: fmt.Sprintf(format, args...)})
+	}
+
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		report(root, severityError, "expected a mapping with an aliases list")
+		return issues
+	}
+	var aliases *yaml.Node
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if key := root.Content[i]; key.Value == "aliases" {
+			aliases = root.Content[i+1]
+		} else {
+			report(key, severityWarning, "unknown key %q is ignored", key.Value)
+		}
+	}
+	if aliases == nil || aliases.Tag == "!!null" {
+		return issues
+	}
+	if aliases.Kind != yaml.SequenceNode {
+		report(aliases, severityError, "aliases must be a list")
+		return issues
+	}
+
+	emails := make(map[string]*yaml.Node)
+	// domains maps each domain to the first entry declaring it, and whether
+	// that entry names its alias by email
+	type declaration struct {
+		node     *yaml.Node
+		hasEmail bool
+	}
+	domains := make(map[string]declaration)
+
+	for _, entry := range aliases.Content {
+		if entry.Kind != yaml.MappingNode {
+			report(entry, severityError, "alias must be a mapping with %s", strings.Join(inventoryFields, ", "))
+			continue
+		}
+
+		fields := make(map[string]*yaml.Node)
+		for i := 0; i+1 < len(entry.Content); i += 2 {
+			key, value := entry.Content[i], entry.Content[i+1]
+			switch {
+			case !isInventoryField(key.Value):
+				report(key, severityError, "unknown field %q (expected one of: %s)", key.Value, strings.Join(inventoryFields, ", "))
+			case fields[key.Value] != nil:
+				report(key, severityError, "duplicate field %q", key.Value)
+			case value.Kind != yaml.ScalarNode:
+				report(value, severityError, "%s must be a string", key.Value)
+			default:
+				fields[key.Value] = value
+			}
+		}
+
+		email, domain := fields["email"], fields["domain"]
+		if email == nil && domain == nil {
+			report(entry, severityError, "alias needs an email or a domain")
+		}
+		if email != nil {
+			address := strings.ToLower(strings.TrimSpace(email.Value))
+			if !looksLikeEmail(address) {
+				report(email, severityError, "invalid email %q", email.Value)
+			} else if first, ok := emails[address]; ok {
+				report(email, severityError, "duplicate email %s (also declared at line %d)", email.Value, first.Line)
+			} else {
+				emails[address] = email
+			}
+		}
+		if domain != nil {
+			if _, normalized, err := prepareDomainInput(domain.Value); err != nil {
+				report(domain, severityError, "invalid domain %q: %v", domain.Value, err)
+			} else if first, ok := domains[normalized]; ok {
+				// Aliases named by email may share a domain, e.g. after a
+				// rotation; an alias found by domain would be ambiguous
+				if email == nil || !first.hasEmail {
+					report(domain, severityError, "duplicate domain %s (also declared at line %d)", domain.Value, first.node.Line)
+				}
+			} else {
+				domains[normalized] = declaration{node: domain, hasEmail: email != nil}
+			}
+		}
+		if state := fields["state"]; state != nil && !isInventoryState(AliasState(state.Value)) {
+			names := make([]string, len(inventoryStates))
+			for i, s := range inventoryStates {
+				names[i] = string(s)
+			}
+			report(state, severityError, "invalid state %q (expected one of: %s)", state.Value, strings.Join(names, ", "))
+		}
+		if description := fields["description"]; description == nil {
+			report(entry, severityWarning, "alias has no description")
+		} else if strings.TrimSpace(description.Value) == "" {
+			report(description, severityWarning, "description is empty")
+		}
+	}
+	sort.SliceStable(issues, func(i, j int) bool {
+		if issues[i].Line != issues[j].Line {
+			return issues[i].Line < issues[j].Line
+		}
+		return issues[i].Column < issues[j].Column
+	})
+	return issues
+}
+
+// isInventoryField reports whether name is a field of an inventory entry.
+func isInventoryField(name string) bool {
+	for _, field := range inventoryFields {
+		if field == name {
+			return true
+		}
+	}
+	return false
+}
+
+// isInventoryState reports whether an inventory entry may declare the state.
+func isInventoryState(state AliasState) bool {
+	for _, s := range inventoryStates {
+		if s == state {
+			return true
+		}
+	}
+	return false
+}
+
+// handleSyncLint prints the issues in an inventory file. It fails if any of
+// them are errors.
+func handleSyncLint(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read inventory: %w", err)
+	}
+	issues := lintInventory(data)
+
+	for _, issue := range issues {
+		if porcelain != "" {
+			// line, column, severity (error|warning), message
+			printPorcelain(strconv.Itoa(issue.Line), strconv.Itoa(issue.Column), issue.Severity, issue.Message)
+		} else {
+			fmt.Println(issue.format(path))
+		}
+	}
+
+	errorCount := 0
+	for _, issue := range issues {
+		if issue.Severity == severityError {
+			errorCount++
+		}
+	}
+	if errorCount > 0 {
+		return fmt.Errorf("%s has %d errors and %d warnings", path, errorCount, len(issues)-errorCount)
+	}
+	if porcelain == "" && len(issues) == 0 {
+		fmt.Fprintf(humanOut, "%s: no problems found\n", path)
+	}
+	return nil
+}
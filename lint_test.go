@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestLintInventory(t *testing.T) {
+	data := `aliases:
+  - domain: https://shop.com
+    description: Shop
+  - domain: shop.com
+    description: Shop again
+    state: paused
+  - email: a@fastmail.com
+    domain: https://bank.com
+  - email: a@fastmail.com
+    description: ""
+    colour: red
+  - description: Nothing else
+  - email: b@fastmail.com
+    domain: https://bank.com
+    description: Bank
+version: 2
+`
+	var got []string
+	for _, issue := range lintInventory([]byte(data)) {
+		got = append(got, issue.format("aliases.yaml"))
+	}
+	want := []string{
+		"aliases.yaml:4:13: error: duplicate domain shop.com (also declared at line 2)",
+		`aliases.yaml:6:12: error: invalid state "paused" (expected one of: enabled, disabled, deleted, pending)`,
+		"aliases.yaml:7:5: warning: alias has no description",
+		"aliases.yaml:9:12: error: duplicate email a@fastmail.com (also declared at line 7)",
+		"aliases.yaml:10:18: warning: description is empty",
+		"aliases.yaml:11:5: error: unknown field \"colour\" (expected one of: email, domain, description, state)",
+		"aliases.yaml:12:5: error: alias needs an email or a domain",
+		`aliases.yaml:16:1: warning: unknown key "version" is ignored`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected issues:\n%s", strings.Join(got, "\n"))
+	}
+}
+
+func TestLintInventorySyntaxError(t *testing.T) {
+	issues := lintInventory([]byte("aliases:\n  - domain: a.com\n\tdescription: x\n"))
+	if len(issues) != 1 || issues[0].Line == 0 || issues[0].Severity != severityError || strings.HasPrefix(issues[0].Message, "yaml:") {
+		t.Fatalf("expected a syntax error with its line, got %+v", issues)
+	}
+}
+
+func TestLintInventoryClean(t *testing.T) {
+	if issues := lintInventory([]byte("aliases:\n  - domain: a.com\n    description: A\n    state: enabled\n")); len(issues) != 0 {
+		t.Fatalf("expected no issues, got %+v", issues)
+	}
+	if issues := lintInventory(nil); len(issues) != 0 {
+		t.Fatalf("expected no issues for an empty file, got %+v", issues)
+	}
+}
+
+func TestParseInventoryReportsPositions(t *testing.T) {
+	_, err := parseInventory([]byte("aliases:\n  - domain: a.com\n    state: paused\n"))
+	var invErr *inventoryError
+	if !errors.As(err, &invErr) || !strings.HasPrefix(err.Error(), "inventory:3:12: error: invalid state") {
+		t.Fatalf("expected a positioned error, got %v", err)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// Verbosity levels, selected with -v, -vv and -vvv
+const (
+	// levelActions logs what the command does to the account, such as
+	// creating an alias or updating several
+	levelActions = 1
+	// levelAPI also logs each API call and a summary of the calls when the
+	// command finishes
+	levelAPI = 2
+	// levelPayloads also logs the full requests and responses, with the
+	// API token redacted; it is what --debug selects
+	levelPayloads = 3
+)
+
+// verbosity is the verbosity level; 0 logs nothing
+var verbosity int
+
+// logOutput receives the log, separately from the results on stdout
+var logOutput io.Writer = os.Stderr
+
+// logPrefixes label the log lines of each level, like the "timing:" lines
+var logPrefixes = map[int]string{
+	levelActions:  "info",
+	levelAPI:      "api",
+	levelPayloads: "DEBUG",
+}
+
+// logf logs a line if the verbosity is at least level.
+func logf(level int, format string, args ...interface{}) {
+	if verbosity < level {
+		return
+	}
+	fmt.Fprintf(logOutput, "%s: %s\n", logPrefixes[level], fmt.Sprintf(format, args...))
+}
+
+// debugf logs a line of the --debug output of a client, which has its own
+// setting so that clients created for tests stay quiet.
+func (fc *FastmailClient) debugf(format string, args ...interface{}) {
+	if fc.Debug {
+		fmt.Fprintf(logOutput, "%s: %s\n", logPrefixes[levelPayloads], fmt.Sprintf(format, args...))
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestLogfLevels(t *testing.T) {
+	var buf bytes.Buffer
+	oldOutput, oldVerbosity := logOutput, verbosity
+	t.Cleanup(func() { logOutput, verbosity = oldOutput, oldVerbosity })
+	logOutput = &buf
+
+	tests := []struct {
+		verbosity int
+		want      string
+	}{
+		{0, ""},
+		{levelActions, "info: created\n"},
+		{levelAPI, "info: created\napi: POST /jmap/api\n"},
+		{levelPayloads, "info: created\napi: POST /jmap/api\nDEBUG: {}\n"},
+	}
+	for _, tt := range tests {
+		buf.Reset()
+		verbosity = tt.verbosity
+		logf(levelActions, "created")
+		logf(levelAPI, "POST %s", "/jmap/api")
+		logf(levelPayloads, "{}")
+		if buf.String() != tt.want {
+			t.Errorf("verbosity %d: got %q, want %q", tt.verbosity, buf.String(), tt.want)
+		}
+	}
+}
+
+func TestActionsAreLogged(t *testing.T) {
+	var buf bytes.Buffer
+	oldOutput, oldVerbosity := logOutput, verbosity
+	t.Cleanup(func() { logOutput, verbosity = oldOutput, oldVerbosity })
+	logOutput = &buf
+	verbosity = levelActions
+
+	_, client := newFakeJMAP(t)
+	if _, err := client.CreateAlias("example.com", nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "info: creating an alias for https://example.com\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestDebugLogsPayloads(t *testing.T) {
+	var buf bytes.Buffer
+	oldOutput := logOutput
+	t.Cleanup(func() { logOutput = oldOutput })
+	logOutput = &buf
+
+	_, client := newFakeJMAP(t)
+	client.Debug = true
+	if _, err := client.GetAliases("example.com"); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"DEBUG: Request Body:", "DEBUG: Response Body:"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("%q not logged:\n%s", want, buf.String())
+		}
+	}
+}
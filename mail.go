@@ -0,0 +1,219 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// JMAP mail capability and methods
+const (
+	capabilityMail   = "urn:ietf:params:jmap:mail"
+	methodEmailQuery = "Email/query"
+	methodEmailGet   = "Email/get"
+)
+
+// mailCapabilities are declared by requests for mail data
+var mailCapabilities = []string{capabilityCore, capabilityMail}
+
+const defaultMessageLimit = 10
+
+// EmailAddress is a JMAP EmailAddress object.
+type EmailAddress struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+func (a EmailAddress) String() string {
+	if a.Name == "" {
+		return a.Email
+	}
+	return fmt.Sprintf("%s <%s>", a.Name, a.Email)
+}
+
+// EmailSummary holds the message properties shown by the mail command.
+type EmailSummary struct {
+	ID         string         `json:"id"`
+	From       []EmailAddress `json:"from"`
+	Subject    string         `json:"subject"`
+	ReceivedAt time.Time      `json:"receivedAt"`
+}
+
+// Sender returns the first From address of the message, if any.
+func (e EmailSummary) Sender() string {
+	if len(e.From) == 0 {
+		return "(unknown sender)"
+	}
+	return e.From[0].String()
+}
+
+// RecentMessages returns the most recent messages addressed to the given
+// email address, newest first. The query and the fetch of the matching
+// messages happen in a single request using a result reference.
+func (fc *FastmailClient) RecentMessages(email string, limit int) ([]EmailSummary, error) {
+	messages, err := fc.RecentMessagesFor([]string{email}, limit)
+	if err != nil {
+		return nil, err
+	}
+	return messages[email], nil
+}
+
+// RecentMessagesFor returns the most recent messages for each of the given
+// addresses. Lookups for several addresses are combined into as few requests
+// as the server's maxCallsInRequest limit allows.
+func (fc *FastmailClient) RecentMessagesFor(emails []string, limit int) (map[string][]EmailSummary, error) {
+	limits, err := fc.Limits()
+	if err != nil {
+		return nil, err
+	}
+
+	// Each address needs an Email/query and an Email/get call
+	perRequest := limits.MaxCallsInRequest / 2
+	if perRequest < 1 {
+		perRequest = 1
+	}
+
+	chunks := chunkIDs(emails, perRequest)
+	var progress *Progress
+	if len(chunks) > 1 {
+		progress = newProgress("Checking mail", len(emails))
+		defer progress.Done()
+	}
+
+	results := make(map[string][]EmailSummary, len(emails))
+	for _, chunk := range chunks {
+		calls := make([]methodCall, 0, 2*len(chunk))
+		for i, email := range chunk {
+			queryID := fmt.Sprintf("query%d", i)
+			calls = append(calls,
+				methodCall{
+					name: methodEmailQuery,
+					arguments: map[string]interface{}{
+						"accountId": fc.AccountID,
+						"filter":    addressedToFilter(email),
+						"sort": []map[string]interface{}{
+							{"property": "receivedAt", "isAscending": false},
+						},
+						"limit": limit,
+					},
+					clientID: queryID,
+				},
+				methodCall{
+					name: methodEmailGet,
+					arguments: map[string]interface{}{
+						"accountId": fc.AccountID,
+						"#ids": resultReference{
+							ResultOf: queryID,
+							Name:     methodEmailQuery,
+							Path:     "/ids",
+						},
+						"properties": []string{"id", "from", "subject", "receivedAt"},
+					},
+					clientID: fmt.Sprintf("get%d", i),
+				},
+			)
+		}
+
+		response, err := fc.invoke(mailCapabilities, calls...)
+		if err != nil {
+			return nil, err
+		}
+
+		for i, email := range chunk {
+			index := 2*i + 1
+			var responseData struct {
+				List []EmailSummary `json:"list"`
+			}
+			if err := fc.methodResult(response, index, &responseData); err != nil {
+				return nil, err
+			}
+			results[email] = responseData.List
+		}
+		progress.Add(len(chunk))
+	}
+
+	return results, nil
+}
+
+// addressedToFilter builds an Email/query filter matching messages that list
+// the address as a recipient.
+func addressedToFilter(email string) map[string]interface{} {
+	return map[string]interface{}{
+		"operator": "OR",
+		"conditions": []map[string]string{
+			{"to": email},
+			{"cc": email},
+			{"bcc": email},
+		},
+	}
+}
+
+// newMailCmd creates the command that lists recent messages sent to an alias.
+func newMailCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "mail <alias>",
+		Short: "Show senders and subjects of recent messages sent to an alias",
+		Long: `Show senders and subjects of recent messages sent to an alias.
+Requires an API token with access to mail (not just masked email).`,
+		Example: `  masked_fastmail mail user.1234@fastmail.com --limit 20`,
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			limit, _ := cmd.Flags().GetInt("limit")
+			client, err := newClientFromCmd(cmd)
+			if err != nil {
+				return err
+			}
+			return handleRecentMail(client, args[0], limit)
+		},
+	}
+	cmd.Flags().IntP("limit", "n", defaultMessageLimit, "number of messages to show")
+	return cmd
+}
+
+// handleRecentMail prints the most recent messages received by an alias.
+func handleRecentMail(client *FastmailClient, identifier string, limit int) error {
+	email, err := normalizeEmailInput(identifier)
+	if err != nil {
+		return err
+	}
+	if limit <= 0 {
+		return fmt.Errorf("--limit must be positive")
+	}
+
+	messages, err := client.RecentMessages(email, limit)
+	if err != nil {
+		return formatAPIError("failed to get messages", err)
+	}
+	recordSenders(map[string][]EmailSummary{email: messages})
+
+	if porcelain != "" {
+		// receivedAt, sender email, sender name, subject
+		for _, message := range messages {
+			var from EmailAddress
+			if len(message.From) > 0 {
+				from = message.From[0]
+			}
+			printPorcelain(message.ReceivedAt.UTC().Format(time.RFC3339), from.Email, from.Name, message.Subject)
+		}
+		return nil
+	}
+
+	if len(messages) == 0 {
+		fmt.Printf("No messages found for %s\n", email)
+		return nil
+	}
+
+	fmt.Printf("Recent messages to %s:\n", email)
+	for _, message := range messages {
+		subject := sanitizeText(strings.TrimSpace(message.Subject))
+		if subject == "" {
+			subject = "(no subject)"
+		}
+		fmt.Printf("- %s  %s\n", formatTime(message.ReceivedAt), sanitizeText(message.Sender()))
+		fmt.Printf("  Subject: %s\n", subject)
+	}
+	warnIfNotSendingIdentity(client, email)
+	return nil
+}
@@ -0,0 +1,19 @@
+package main
+
+import "testing"
+
+func TestEmailSummarySender(t *testing.T) {
+	message := EmailSummary{From: []EmailAddress{{Name: "Example", Email: "noreply@example.com"}}}
+	if got := message.Sender(); got != "Example <noreply@example.com>" {
+		t.Fatalf("Sender() = %q, want %q", got, "Example <noreply@example.com>")
+	}
+
+	message = EmailSummary{From: []EmailAddress{{Email: "noreply@example.com"}}}
+	if got := message.Sender(); got != "noreply@example.com" {
+		t.Fatalf("Sender() = %q, want bare address", got)
+	}
+
+	if got := (EmailSummary{}).Sender(); got != "(unknown sender)" {
+		t.Fatalf("expected placeholder for missing sender, got %q", got)
+	}
+}
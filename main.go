@@ -1,17 +1,18 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"flag"
 	"fmt"
 	"math"
+	"net/http"
+	"net/url"
 	"os"
 	"runtime/debug"
 	"strings"
 	"time"
-	"unicode/utf8"
 
-	"github.com/atotto/clipboard"
 	"github.com/spf13/cobra"
 )
 
@@ -24,7 +25,8 @@ var (
 )
 
 const (
-	shortCommitLength = 7 // length of short commit hash
+	shortCommitLength = 7                // length of short commit hash
+	mailPollInterval  = 15 * time.Second // how often --wait-for-mail checks for new messages
 )
 
 // initVersionInfo attempts to populate version information from Go's build info
@@ -111,7 +113,11 @@ func main() {
   manage_fastmail <alias>`,
 		Short: "Manage masked email aliases",
 		Long: `A command-line tool to manage Fastmail.com masked email addresses.
-Requires FASTMAIL_ACCOUNT_ID and FASTMAIL_API_KEY environment variables to be set.`,
+Requires the FASTMAIL_API_KEY environment variable to be set, or
+FASTMAIL_API_KEY_FILE to name a file holding the token. FASTMAIL_ACCOUNT_ID
+selects the account; it defaults to the token's primary account.
+
+` + exitStatusHelp(),
 		Example: `  # Create or get alias for a website:
   masked_fastmail example.com
 
@@ -120,6 +126,8 @@ Requires FASTMAIL_ACCOUNT_ID and FASTMAIL_API_KEY environment variables to be se
 
 		SilenceUsage:  true,
 		SilenceErrors: true,
+		// Positional arguments are domains or aliases, not subcommands
+		Args: cobra.ArbitraryArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			showVersion, _ := cmd.Flags().GetBool("version")
 			if showVersion {
@@ -128,38 +136,244 @@ Requires FASTMAIL_ACCOUNT_ID and FASTMAIL_API_KEY environment variables to be se
 			}
 			return runMaskedFastmail(cmd, args)
 		},
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := applyConfig(); err != nil {
+				return err
+			}
+			if cmd.Flags().Changed("ignore-scheme") {
+				ignoreScheme, _ = cmd.Flags().GetBool("ignore-scheme")
+			}
+			verbosity, _ = cmd.Flags().GetCount("verbose")
+			if debug, _ := cmd.Flags().GetBool("debug"); debug {
+				verbosity = levelPayloads
+			}
+			if exact, _ := cmd.Flags().GetBool("exact"); exact {
+				fuzzySearch = false
+			} else if cmd.Flags().Changed("fuzzy") {
+				fuzzySearch = true
+			}
+			if cmd.Flags().Changed("timeout") {
+				requestTimeout, _ = cmd.Flags().GetDuration("timeout")
+			}
+			allowIP, _ = cmd.Flags().GetBool("allow-ip")
+			assumeYes, _ = cmd.Flags().GetBool("yes")
+			yesReally, _ = cmd.Flags().GetBool("yes-really")
+			explain, _ = cmd.Flags().GetBool("explain")
+			if selection, _ := cmd.Flags().GetString("selection"); selection != "" {
+				strategy, err := parseSelectionStrategy(selection)
+				if err != nil {
+					return err
+				}
+				activeSelection = strategy
+			}
+			noInput, _ = cmd.Flags().GetBool("no-input")
+			if cmd.Flags().Changed("prompt-timeout") {
+				promptTimeout, _ = cmd.Flags().GetDuration("prompt-timeout")
+				if promptTimeout < 0 {
+					return fmt.Errorf("--prompt-timeout must not be negative, got %s", promptTimeout)
+				}
+			}
+			if cmd.Flags().Changed("absolute-times") {
+				absoluteTimes, _ = cmd.Flags().GetBool("absolute-times")
+			}
+			version, _ := cmd.Flags().GetString("porcelain")
+			if !cmd.Flags().Changed("porcelain") && !isTerminal(os.Stdout) {
+				version = porcelainV1
+			}
+			if err := setPorcelain(version); err != nil {
+				return err
+			}
+			output, _ := cmd.Flags().GetString("output")
+			if err := setOutput(cmd, output); err != nil {
+				return err
+			}
+			escape, _ := cmd.Flags().GetString("escape")
+			return setEscape(escape)
+		},
+		Annotations: map[string]string{outputAnnotation: outputNDJSON + "," + outputURI + "," + outputAutofill},
+		// Runs after any command that succeeded, including subcommands
+		PersistentPostRun: func(cmd *cobra.Command, args []string) {
+			if event := commandEvent(cmd); event != "" {
+				recordUsage(event)
+			}
+		},
 	}
 
-	rootCmd.Flags().BoolP("version", "v", false, "show version information")
+	rootCmd.Flags().BoolP("version", "V", false, "show version information")
 	rootCmd.Flags().BoolP("enable", "e", false, "enable alias")
 	rootCmd.Flags().BoolP("disable", "d", false, "disable alias (send to trash)")
 	rootCmd.Flags().Bool("delete", false, "delete alias (bounce messages)")
-	rootCmd.Flags().Bool("debug", false, "enable debug output (shows raw API requests and responses)")
+	rootCmd.Flags().Bool("force", false, "with --delete, also delete protected aliases")
+	rootCmd.Flags().String("by-description", "", "with --enable, --disable, --delete or --set-description, find the alias by its description")
+	rootCmd.PersistentFlags().Bool("debug", false, "enable debug output (shows raw API requests and responses); same as -vvv")
+	rootCmd.PersistentFlags().String("porcelain", "", "print stable, machine-readable output in the given format version (default v1 when stdout is not a terminal)")
+	rootCmd.PersistentFlags().Lookup("porcelain").NoOptDefVal = porcelainV1
+	rootCmd.PersistentFlags().StringP("output", "o", outputText, "result format: "+strings.Join(outputFormats, ", ")+" (ndjson prints one JSON object per line, for --list and export; uri prints the alias as a maskedfastmail:// URI; autofill prints the email, a password placeholder and the domain as JSON)")
+	rootCmd.PersistentFlags().Bool("allow-ip", false, "accept IP addresses and localhost as domains, e.g. for a router's admin page")
+	rootCmd.PersistentFlags().String("escape", escapeNone, "escape porcelain fields for a consumer: "+strings.Join(escapeModes, ", ")+" (shell quotes each field for eval and implies --porcelain)")
+	rootCmd.PersistentFlags().Bool("json", false, "print errors to stderr as JSON objects, for programs running the command")
+	rootCmd.PersistentFlags().Bool("ignore-scheme", false, "treat http:// and https:// versions of a site as the same when matching aliases")
+	rootCmd.PersistentFlags().Duration("timeout", 0, "maximum time for each API request, e.g. 90s (default depends on the operation)")
+	rootCmd.PersistentFlags().CountP("verbose", "v", "log to stderr: -v changes to the account, -vv also API calls and a summary, -vvv also full requests and responses")
+	rootCmd.PersistentFlags().Bool("timing", false, "print the duration and transferred size of each API request to stderr")
+	rootCmd.PersistentFlags().Bool("ignore-tls-pins", false, "connect even if the API certificate matches none of the tls_pins in the config file")
+	rootCmd.PersistentFlags().Bool("fuzzy", false, "also match searches with the characters in order but not adjacent (default unless fuzzy_search is off)")
+	rootCmd.PersistentFlags().Bool("exact", false, "only match searches that appear verbatim")
+	rootCmd.PersistentFlags().Bool("absolute-times", false, "show timestamps as ISO 8601 instead of relative times such as \"3 days ago\"")
+	rootCmd.PersistentFlags().BoolP("yes", "y", false, "answer yes to confirmations instead of asking")
+	rootCmd.PersistentFlags().String("selection", "", "which alias to use when several match a site: state-priority, most-recent-mail, newest, oldest or interactive (default: selection_strategy from the config file)")
+	rootCmd.PersistentFlags().Bool("explain", false, "print what the command fetches, matches and changes, and why, before doing it")
+	rootCmd.PersistentFlags().Bool("yes-really", false, "also go ahead with changes to more aliases than confirm_phrase_over without typing the confirmation phrase")
+	rootCmd.PersistentFlags().Bool("no-input", false, "never wait for input from the terminal, for cron and CI; missing input is an error and confirmations need --yes")
+	rootCmd.PersistentFlags().Duration("prompt-timeout", 0, "stop waiting for the answer to a prompt after this long, e.g. 30s, and take prompt_timeout_action from the config file (default: prompt_timeout from the config file, or no timeout)")
+	rootCmd.PersistentFlags().Bool("read-only", false, "refuse to create or modify aliases")
+	rootCmd.PersistentFlags().String("account", "", "ID or name of the account to use instead of FASTMAIL_ACCOUNT_ID")
+	rootCmd.PersistentFlags().String("record", "", "save the API requests and responses of this run, sanitized, to a YAML file")
+	rootCmd.PersistentFlags().String("replay", "", "answer API requests from a file saved by --record instead of contacting Fastmail")
+	rootCmd.MarkFlagsMutuallyExclusive("record", "replay")
+	// For testing error handling; see DEVELOPMENT.md
+	rootCmd.PersistentFlags().StringArray("inject-failure", nil, "inject a failure into the API requests: "+strings.Join(failureKinds, ", ")+" (repeatable)")
+	rootCmd.PersistentFlags().MarkHidden("inject-failure")
 	rootCmd.Flags().BoolP("list", "l", false, "list all aliases for a domain without creating new ones")
+	rootCmd.Flags().StringArray("regex", nil, "only include aliases whose email, domain or description matches this regular expression; prefix with email:, domain: or description: to match one field (repeatable)")
+	rootCmd.Flags().String("brand", "", "only include aliases for the domains of a brand in the brands setting, e.g. Amazon")
+	rootCmd.Flags().String("owner", "", "with --list, only show aliases created by this user (alice), on this machine (@laptop) or both; \"me\" is the current user")
+	addDateFilterFlags(rootCmd)
+	addCountFlag(rootCmd)
+	rootCmd.Flags().Bool("wide", false, "with --list, show additional details such as the folder mail is routed to")
 	rootCmd.Flags().String("set-description", "", "update the description for an alias")
+	rootCmd.Flags().String("folder", "", "print a filter rule routing the alias's mail into this folder")
+	rootCmd.Flags().Bool("activate", false, "create new aliases enabled instead of pending, so they don't expire before receiving mail")
+	rootCmd.Flags().Bool("force-new", false, "create a new alias even if one exists for a nearby site, such as www. or http:// versions of it")
+	rootCmd.Flags().String("resume", "", "continue an interrupted bulk change from the checkpoint file it saved")
+	rootCmd.Flags().Duration("wait-for-mail", 0, "after creating an alias, wait up to this long (e.g. 5m) for its first message")
 
 	// Make flags mutually exclusive
 	rootCmd.MarkFlagsMutuallyExclusive("enable", "disable", "delete")
+	rootCmd.MarkFlagsMutuallyExclusive("fuzzy", "exact")
 	rootCmd.MarkFlagsMutuallyExclusive("list", "enable", "disable", "delete", "set-description")
+	rootCmd.MarkFlagsMutuallyExclusive("by-description", "list", "regex", "brand", "resume")
 	rootCmd.MarkFlagsMutuallyExclusive("set-description", "enable", "disable", "delete")
+	rootCmd.MarkFlagsMutuallyExclusive("wait-for-mail", "list", "enable", "disable", "delete", "set-description")
+	rootCmd.MarkFlagsMutuallyExclusive("folder", "list", "enable", "disable", "delete", "set-description")
+	rootCmd.MarkFlagsMutuallyExclusive("regex", "set-description", "resume")
+	rootCmd.MarkFlagsMutuallyExclusive("brand", "set-description", "resume")
+	rootCmd.MarkFlagsMutuallyExclusive("resume", "list", "enable", "disable", "delete", "set-description", "folder", "wait-for-mail")
+	rootCmd.MarkFlagsMutuallyExclusive("activate", "list", "enable", "disable", "delete", "set-description", "resume")
+	rootCmd.MarkFlagsMutuallyExclusive("force-new", "list", "enable", "disable", "delete", "set-description", "resume")
+
+	rootCmd.AddCommand(newLimitsCmd())
+	rootCmd.AddCommand(newWhoamiCmd())
+	rootCmd.AddCommand(newDoctorCmd())
+	rootCmd.AddCommand(newMailCmd())
+	rootCmd.AddCommand(newLeaksCmd())
+	rootCmd.AddCommand(newRotateCmd())
+	rootCmd.AddCommand(newExportCmd())
+	rootCmd.AddCommand(newDiffCmd())
+	rootCmd.AddCommand(newRestoreCmd())
+	rootCmd.AddCommand(newSyncCmd())
+	rootCmd.AddCommand(newSearchCmd())
+	rootCmd.AddCommand(newAccountsCmd())
+	rootCmd.AddCommand(newIdentitiesCmd())
+	rootCmd.AddCommand(newSendAsCmd())
+	rootCmd.AddCommand(newSimilarCmd())
+	rootCmd.AddCommand(newProtectCmd())
+	rootCmd.AddCommand(newUnprotectCmd())
+	rootCmd.AddCommand(newStatsCmd())
+	rootCmd.AddCommand(newDocsCmd())
+	rootCmd.AddCommand(newCompletionCmd())
+	rootCmd.AddCommand(newPinsCmd())
+	rootCmd.AddCommand(newGraphCmd())
+	rootCmd.AddCommand(newDigestCmd())
+	rootCmd.AddCommand(newShortcutCmd())
+	rootCmd.AddCommand(newServeCmd())
+	rootCmd.AddCommand(newBookmarkletCmd())
+	rootCmd.AddCommand(newIntegrationsCmd())
+	rootCmd.AddCommand(newPurgeCmd())
+	rootCmd.AddCommand(newExistsCmd())
+	rootCmd.AddCommand(newTidyDescriptionsCmd())
+	rootCmd.AddCommand(newDescribeCmd())
+	rootCmd.AddCommand(newMergeCmd())
+	rootCmd.AddCommand(newAuthCmd())
+	rootCmd.AddCommand(newParseCmd())
+	rootCmd.AddCommand(newAuditLogCmd())
+
+	err := rootCmd.Execute()
+	printCommandStats()
+	saveRecording()
+	if err != nil {
+		var codeErr *exitCodeError
+		if !errors.As(err, &codeErr) || !codeErr.quiet {
+			if jsonErrors, _ := rootCmd.PersistentFlags().GetBool("json"); jsonErrors {
+				printErrorJSON(os.Stderr, err)
+			} else {
+				fmt.Fprintln(os.Stderr, "Error:", err)
+			}
+		}
+		os.Exit(exitCode(err))
+	}
+}
 
-	// Add completion support
-	rootCmd.CompletionOptions.DisableDefaultCmd = true
+// exitCode returns the exit code for an error returned by a command.
+func exitCode(err error) int {
+	var codeErr *exitCodeError
+	var urlErr *url.Error
+	switch {
+	case err == nil:
+		return exitSuccess
+	case errors.As(err, &codeErr):
+		return codeErr.code
+	case errors.Is(err, ErrMissingToken) || errors.Is(err, ErrUnauthorized):
+		return exitAuth
+	case errors.Is(err, ErrReadOnly):
+		return exitReadOnly
+	case errors.Is(err, ErrPinMismatch):
+		return exitFailure
+	case errors.As(err, &urlErr) && !isTransportError(err):
+		// A URL that couldn't be parsed, such as a malformed site
+		return exitInvalidInput
+	case errors.Is(err, context.DeadlineExceeded) || isTransportError(err):
+		return exitUnreachable
+	default:
+		return exitFailure
+	}
+}
 
-	if err := rootCmd.Execute(); err != nil {
-		fmt.Fprintln(os.Stderr, "Error:", err)
-		os.Exit(1)
+// isTransportError reports whether err is the failure of an HTTP request to
+// reach the server. The HTTP client and url.Parse both return *url.Error;
+// only the former has the method of the request as its operation.
+func isTransportError(err error) bool {
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		return false
 	}
+	switch strings.ToUpper(urlErr.Op) {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions:
+		return true
+	}
+	return false
 }
 
+// exitCodeError is an error with an explicit exit code, for failures that
+// can't be recognized from their cause. Quiet errors are not printed, for
+// exit codes that answer a question, such as those of exists.
+type exitCodeError struct {
+	code  int
+	err   error
+	quiet bool
+}
+
+func (e *exitCodeError) Error() string { return e.err.Error() }
+func (e *exitCodeError) Unwrap() error { return e.err }
+
 // isTestMode returns true if the code is running under go test
 func isTestMode() bool {
 	return flag.Lookup("test.v") != nil
 }
 
-// selectPreferredAlias selects the best alias based on state priority
-// Priority order: enabled > pending > disabled > deleted
-// Returns nil if the input slice is empty.
+// selectPreferredAlias selects the alias to use among those of a site with
+// the active selection strategy; by default by state priority: enabled >
+// pending > disabled > deleted. Returns nil if the input slice is empty.
 func selectPreferredAlias(aliases []MaskedEmailInfo) *MaskedEmailInfo {
 	if len(aliases) == 0 {
 		return nil
@@ -175,18 +389,7 @@ func selectPreferredAlias(aliases []MaskedEmailInfo) *MaskedEmailInfo {
 		}
 	}
 
-	selected := &aliases[0]
-	selectedPriority := getStatePriority(selected.State)
-
-	for i := 1; i < len(aliases); i++ {
-		priority := getStatePriority(aliases[i].State)
-		if priority < selectedPriority {
-			selected = &aliases[i]
-			selectedPriority = priority
-		}
-	}
-
-	return selected
+	return activeSelection.pick(aliases)
 }
 
 func getStatePriority(state AliasState) int {
@@ -199,14 +402,129 @@ func getStatePriority(state AliasState) int {
 // runMaskedFastmail is the main command handler for the CLI application.
 // It handles both alias creation/lookup and state management operations.
 func runMaskedFastmail(cmd *cobra.Command, args []string) error {
-	if len(args) == 0 || len(args) > 2 {
+	// Check for state update flags
+	enable, _ := cmd.Flags().GetBool("enable")
+	disable, _ := cmd.Flags().GetBool("disable")
+	delete, _ := cmd.Flags().GetBool("delete")
+	list, _ := cmd.Flags().GetBool("list")
+	newDescriptionValue, _ := cmd.Flags().GetString("set-description")
+	setDescription := cmd.Flags().Changed("set-description")
+	stateChange := enable || disable || delete
+	force, _ := cmd.Flags().GetBool("force")
+	if force && !delete {
+		return fmt.Errorf("--force can only be used with --delete")
+	}
+	if outputFormat == outputNDJSON && !list {
+		return fmt.Errorf("--output %s can only be used with --list", outputFormat)
+	}
+	if (outputFormat == outputURI || outputFormat == outputAutofill) && (list || stateChange || setDescription) {
+		return fmt.Errorf("--output %s can only be used to get or create an alias", outputFormat)
+	}
+
+	if resume, _ := cmd.Flags().GetString("resume"); resume != "" {
+		if len(args) > 0 {
+			return fmt.Errorf("--resume does not accept aliases or domains")
+		}
+		client, err := newClientFromCmd(cmd)
+		if err != nil {
+			return err
+		}
+		return handleResume(client, resume)
+	}
+
+	patterns, _ := cmd.Flags().GetStringArray("regex")
+	filters, err := parseRegexFilters(patterns)
+	if err != nil {
+		return err
+	}
+	if brand, _ := cmd.Flags().GetString("brand"); brand != "" {
+		filter, err := brandFilter(brand)
+		if err != nil {
+			return err
+		}
+		filters = append(filters, filter)
+	}
+	owner, _ := cmd.Flags().GetString("owner")
+	if owner != "" && !list {
+		return fmt.Errorf("--owner can only be used with --list")
+	}
+	dates, err := dateFilterFromCmd(cmd)
+	if err != nil {
+		return err
+	}
+	count, err := countModeFromCmd(cmd)
+	if err != nil {
+		return err
+	}
+	if count != "" && !list {
+		return fmt.Errorf("--count can only be used with --list")
+	}
+	if len(filters) > 0 || owner != "" || dates.active() {
+		if !list && !stateChange {
+			return fmt.Errorf("--regex, --brand and the date filters can only be used with --list, --enable, --disable or --delete")
+		}
+		if list && len(args) > 1 {
+			return fmt.Errorf("this operation accepts at most one domain")
+		}
+		client, err := newClientFromCmd(cmd)
+		if err != nil {
+			return err
+		}
+		if list {
+			wide, _ := cmd.Flags().GetBool("wide")
+			identifier := ""
+			if len(args) == 1 {
+				identifier = args[0]
+			}
+			return handleAliasList(client, identifier, listOptions{wide: wide, filters: filters, owner: owner, dates: dates, count: count})
+		}
+		return handleRegexStateUpdate(client, args, filters, dates, enable, disable, delete, force)
+	}
+
+	if byDescription, _ := cmd.Flags().GetString("by-description"); cmd.Flags().Changed("by-description") {
+		if !stateChange && !setDescription {
+			return fmt.Errorf("--by-description can only be used with --enable, --disable, --delete or --set-description")
+		}
+		if len(args) > 0 {
+			return fmt.Errorf("--by-description replaces the alias argument")
+		}
+		client, err := newClientFromCmd(cmd)
+		if err != nil {
+			return err
+		}
+		alias, err := resolveByDescription(client, byDescription)
+		if err != nil {
+			return err
+		}
+		if setDescription {
+			return handleDescriptionUpdate(client, alias.Email, newDescriptionValue)
+		}
+		return handleStateUpdate(client, alias.Email, enable, disable, delete, force)
+	}
+
+	if len(args) == 0 && cmd.Flags().Changed("verbose") {
+		// -v used to show the version
+		return fmt.Errorf("specify a domain/alias; -v makes commands verbose, use -V or --version to show the version")
+	}
+	if len(args) == 0 || (len(args) > 2 && !stateChange) {
 		return fmt.Errorf("specify a domain/alias, optionally followed by a description\n\n%s", cmd.UsageString())
 	}
 
-	debug, _ := cmd.Flags().GetBool("debug")
-	client, err := NewFastmailClient(debug)
+	requiresSingleArg := list || setDescription
+	if requiresSingleArg && len(args) != 1 {
+		return fmt.Errorf("this operation accepts exactly one identifier (alias or domain)")
+	}
+
+	client, err := newClientFromCmd(cmd)
 	if err != nil {
-		return fmt.Errorf("failed to initialize client: %w", err)
+		return err
+	}
+
+	if stateChange {
+		if len(args) > 1 {
+			return handleBulkStateUpdate(client, args, enable, disable, delete, force)
+		}
+		return handleStateUpdate(client, args[0], enable, disable, delete, force)
 	}
 
 	identifier := args[0]
@@ -215,19 +533,6 @@ func runMaskedFastmail(cmd *cobra.Command, args []string) error {
 		desc := args[1]
 		descriptionArg = &desc
 	}
-
-	// Check for state update flags
-	enable, _ := cmd.Flags().GetBool("enable")
-	disable, _ := cmd.Flags().GetBool("disable")
-	delete, _ := cmd.Flags().GetBool("delete")
-	list, _ := cmd.Flags().GetBool("list")
-	newDescriptionValue, _ := cmd.Flags().GetString("set-description")
-	setDescription := cmd.Flags().Changed("set-description")
-
-	requiresSingleArg := enable || disable || delete || list || setDescription
-	if requiresSingleArg && len(args) != 1 {
-		return fmt.Errorf("this operation accepts exactly one identifier (alias or domain)")
-	}
 	if descriptionArg != nil && requiresSingleArg {
 		return fmt.Errorf("the positional description argument is only allowed when creating or looking up aliases without flags")
 	}
@@ -235,62 +540,531 @@ func runMaskedFastmail(cmd *cobra.Command, args []string) error {
 	if setDescription {
 		return handleDescriptionUpdate(client, identifier, newDescriptionValue)
 	}
+	if list {
+		wide, _ := cmd.Flags().GetBool("wide")
+		return handleAliasList(client, identifier, listOptions{wide: wide, count: count})
+	}
+	activate, _ := cmd.Flags().GetBool("activate")
+	forceNew, _ := cmd.Flags().GetBool("force-new")
+	alias, err := handleAliasLookupOrCreation(client, identifier, descriptionArg, activate, forceNew)
+	if err != nil {
+		return err
+	}
 
-	if enable || disable || delete {
-		return handleStateUpdate(client, identifier, enable, disable, delete)
+	if folder, _ := cmd.Flags().GetString("folder"); cmd.Flags().Changed("folder") {
+		if err := handleFolderRule(client, alias, folder); err != nil {
+			return err
+		}
 	}
-	if list {
-		return handleAliasList(client, identifier)
+
+	if waitForMail, _ := cmd.Flags().GetDuration("wait-for-mail"); waitForMail > 0 {
+		return waitForFirstMessage(client, alias, waitForMail)
 	}
-	return handleAliasLookupOrCreation(client, identifier, descriptionArg)
+	return nil
 }
 
-// handleStateUpdate manages the state changes of existing aliases
-func handleStateUpdate(client *FastmailClient, identifier string, enable, disable, delete bool) error {
-	email, err := normalizeEmailInput(identifier)
+// newClientFromCmd creates a Fastmail client configured from the command's flags.
+func newClientFromCmd(cmd *cobra.Command) (*FastmailClient, error) {
+	return newClientWithToken(cmd, "")
+}
+
+// newClientWithToken is newClientFromCmd with another API token than the
+// configured one, such as a token being tried out. Only the sessions of the
+// configured token are cached.
+func newClientWithToken(cmd *cobra.Command, token string) (*FastmailClient, error) {
+	readOnly, _ := cmd.Flags().GetBool("read-only")
+	readOnly = readOnly || readOnlyForced()
+	timing, _ := cmd.Flags().GetBool("timing")
+	pins := tlsPins
+	if ignorePins, _ := cmd.Flags().GetBool("ignore-tls-pins"); ignorePins && len(pins) > 0 {
+		fmt.Fprintln(os.Stderr, "Warning: TLS pinning is disabled for this run")
+		pins = nil
+	}
+	account, _ := cmd.Flags().GetString("account")
+	opts := ClientOptions{
+		Debug:    verbosity >= levelPayloads,
+		ReadOnly: readOnly,
+		Timeout:  requestTimeout,
+		// Converted from MiB to bytes
+		MaxResponseSize:  maxResponseMB << 20,
+		CompressRequests: compressRequests,
+		Timing:           timing,
+		TLSPins:          pins,
+		Token:            token,
+		CacheSession:     token == "",
+	}
+	if err := setRecordingOptions(cmd, &opts); err != nil {
+		return nil, err
+	}
+	if err := setFailureInjection(cmd, &opts); err != nil {
+		return nil, err
+	}
+	client, err := NewFastmailClient(opts)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("failed to initialize client: %w", err)
 	}
+	client.stats = &commandStats
 
-	var newState AliasState
 	switch {
-	case enable:
-		newState = AliasEnabled
-	case disable:
-		newState = AliasDisabled
-	case delete:
-		newState = AliasDeleted
+	case account != "":
+		err = client.SelectAccount(account)
+	case client.AccountID == "":
+		err = client.SelectPrimaryAccount()
 	}
+	if err != nil {
+		return nil, formatAPIError("failed to select account", err)
+	}
+	return client, nil
+}
+
+// handleStateUpdate manages the state changes of existing aliases. Protected
+// aliases are only deleted with force.
+func handleStateUpdate(client *FastmailClient, identifier string, enable, disable, delete, force bool) error {
+	email, err := normalizeEmailInput(identifier)
+	if err != nil {
+		return err
+	}
+
+	newState := requestedState(enable, disable, delete)
 
 	// Get current state
+	explainf("fetching %s to read its current state", email)
 	targetAlias, err := client.GetAliasByEmail(email)
 	if err != nil {
 		return formatAPIError("failed to get alias", err)
 	}
+	explainf("%s is %s; setting it to %s", email, targetAlias.State, newState)
+	note, err := deletedAliasNote(*targetAlias, newState)
+	if err != nil {
+		return err
+	}
+	if note != "" {
+		fmt.Fprintf(os.Stderr, "Note: %s\n", note)
+	}
+	if newState == AliasDeleted {
+		if err := checkDeletable(*targetAlias, force); err != nil {
+			return err
+		}
+	}
+	if warning := ageWarning(*targetAlias, newState, time.Now()); warning != "" {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
 
 	err = client.UpdateAliasStatus(targetAlias, newState)
 	if err != nil {
 		return formatAPIError("failed to update alias status", err)
 	}
+	recordStateChanges([]MaskedEmailInfo{*targetAlias}, newState, time.Now())
+	if porcelain != "" {
+		// email, state
+		printPorcelain(targetAlias.Email, string(newState))
+	}
 	return nil
 }
 
-// handleAliasList prints metadata for all aliases associated with a domain
-// without creating or modifying anything.
-func handleAliasList(client *FastmailClient, identifier string) error {
-	displayInput, normalizedDomain, err := prepareDomainInput(identifier)
+// requestedState maps the mutually exclusive state flags to an alias state.
+func requestedState(enable, disable, delete bool) AliasState {
+	switch {
+	case enable:
+		return AliasEnabled
+	case disable:
+		return AliasDisabled
+	case delete:
+		return AliasDeleted
+	}
+	return ""
+}
+
+// deletedAliasNote tells what setting a deleted alias to state does, or
+// returns "" for aliases that aren't deleted. Deleting a deleted alias fails
+// with ErrAliasDeleted rather than with a plain state mismatch.
+func deletedAliasNote(alias MaskedEmailInfo, state AliasState) (string, error) {
+	if alias.State != AliasDeleted {
+		return "", nil
+	}
+	switch state {
+	case AliasDeleted:
+		return "", deletedAliasError(alias.Email)
+	case AliasDisabled:
+		return fmt.Sprintf("%s is deleted; disabling restores it as disabled, so that new mail goes to the trash instead of bouncing", alias.Email), nil
+	}
+	return fmt.Sprintf("%s is deleted; enabling restores it", alias.Email), nil
+}
+
+// stateVerb returns the verb that sets a state, e.g. disable for disabled.
+func stateVerb(state AliasState) string {
+	return strings.TrimSuffix(string(state), "d")
+}
+
+// handleBulkStateUpdate changes the state of several aliases at once. Updates
+// are sent in as few requests as the server limits allow, and a summary with
+// per-alias failures is printed at the end. If the run is interrupted with
+// Ctrl-C or a request fails, the aliases not yet processed are saved to a
+// checkpoint file. Protected aliases are skipped unless force is set.
+func handleBulkStateUpdate(client *FastmailClient, identifiers []string, enable, disable, delete, force bool) error {
+	emails := make([]string, 0, len(identifiers))
+	for _, identifier := range identifiers {
+		email, err := normalizeEmailInput(identifier)
+		if err != nil {
+			return err
+		}
+		emails = append(emails, email)
+	}
+
+	state := requestedState(enable, disable, delete)
+	if err := confirmBulk(stateVerb(state), len(emails), ""); err != nil {
+		return err
+	}
+
+	ctx, stop := withInterrupt()
+	defer stop()
+	checkpoint := newBulkCheckpoint(state, emails)
+	checkpoint.Force = force
+	return runBulkStateUpdate(ctx, client, checkpoint)
+}
+
+// handleRegexStateUpdate changes the state of the aliases given as arguments
+// and of every alias matching the --regex, --brand and date filters, as a
+// bulk change.
+func handleRegexStateUpdate(client *FastmailClient, identifiers []string, filters []aliasFilter, dates dateFilter, enable, disable, delete, force bool) error {
+	aliases, err := fetchAllAliasesWithProgress(client)
 	if err != nil {
+		return formatAPIError("failed to get aliases", err)
+	}
+	matched := dates.filter(filterAliases(aliases, filters))
+	if len(matched) == 0 && len(identifiers) == 0 {
+		return fmt.Errorf("no aliases match the filters")
+	}
+	fmt.Fprintf(humanOut, "%d aliases match the filters\n", len(matched))
+
+	seen := make(map[string]bool, len(identifiers)+len(matched))
+	emails := make([]string, 0, len(identifiers)+len(matched))
+	for _, identifier := range identifiers {
+		email, err := normalizeEmailInput(identifier)
+		if err != nil {
+			return err
+		}
+		if !seen[email] {
+			seen[email] = true
+			emails = append(emails, email)
+		}
+	}
+	for _, alias := range matched {
+		if !seen[alias.Email] {
+			seen[alias.Email] = true
+			emails = append(emails, alias.Email)
+		}
+	}
+	// A pattern matching more than intended is easy to write and deleting
+	// is hard to undo, so this is worth a question when someone can answer
+	state := requestedState(enable, disable, delete)
+	question := ""
+	if delete {
+		question = fmt.Sprintf("Delete %s?", plural(len(emails), "alias", "aliases"))
+	}
+	if err := confirmBulk(stateVerb(state), len(emails), question); err != nil {
+		return err
+	}
+
+	ctx, stop := withInterrupt()
+	defer stop()
+	checkpoint := newBulkCheckpoint(state, emails)
+	checkpoint.Force = force
+	return runBulkStateUpdate(ctx, client, checkpoint)
+}
+
+// handleResume continues a bulk state change from a checkpoint, processing
+// only the aliases that were not handled by the interrupted run and those
+// that failed.
+func handleResume(client *FastmailClient, path string) error {
+	checkpoint, err := loadBulkCheckpoint(path)
+	if err != nil {
+		return err
+	}
+	failed := checkpoint.retryFailed()
+	if len(checkpoint.Pending) == 0 {
+		fmt.Fprintln(humanOut, "Nothing left to do; all aliases in the checkpoint were processed")
+		return checkpoint.remove()
+	}
+
+	fmt.Fprintf(humanOut, "Resuming: setting %d remaining aliases to '%s' (%d done, %d failed previously and retried)\n",
+		len(checkpoint.Pending), checkpoint.State, len(checkpoint.Done), failed)
+	if err := confirmBulk(stateVerb(checkpoint.State), len(checkpoint.Pending), ""); err != nil {
+		return err
+	}
+
+	ctx, stop := withInterrupt()
+	defer stop()
+	return runBulkStateUpdate(ctx, client, checkpoint)
+}
+
+// runBulkStateUpdate processes the pending aliases of a checkpoint and prints
+// a summary of what was and wasn't changed.
+func runBulkStateUpdate(ctx context.Context, client *FastmailClient, checkpoint *bulkCheckpoint) error {
+	newState := checkpoint.State
+	emails := append([]string(nil), checkpoint.Pending...)
+	explainf("fetching all aliases to set %s to %s; aliases already %s are skipped%s", plural(len(emails), "alias", "aliases"), newState, newState,
+		choose(newState == AliasDeleted && !checkpoint.Force, ", and protected aliases are left alone without --force", ""))
+
+	aliases, err := fetchAllAliasesWithProgress(client)
+	if err != nil {
+		return formatAPIError("failed to get aliases", err)
+	}
+	byEmail := make(map[string]MaskedEmailInfo, len(aliases))
+	for _, alias := range aliases {
+		byEmail[alias.Email] = alias
+	}
+
+	metadata := map[string]AliasMetadata{}
+	if newState == AliasDeleted && !checkpoint.Force {
+		if metadata, err = loadMetadata(); err != nil {
+			return fmt.Errorf("failed to read alias metadata: %w", err)
+		}
+	}
+
+	updates := make(map[string]MaskedEmailUpdate)
+	emailByID := make(map[string]string)
+	var skipped, protected []string
+	for _, email := range emails {
+		alias, ok := byEmail[email]
+		if !ok {
+			checkpoint.markFailed(email, ErrAliasNotFound.Error())
+			continue
+		}
+		if alias.State == newState {
+			skipped = append(skipped, email)
+			checkpoint.markDone(email)
+			continue
+		}
+		if newState == AliasDeleted && !checkpoint.Force && isProtected(alias, metadata) {
+			protected = append(protected, email)
+			checkpoint.removePending(email)
+			continue
+		}
+		desiredState := newState
+		updates[alias.ID] = MaskedEmailUpdate{State: &desiredState}
+		emailByID[alias.ID] = email
+		if note, _ := deletedAliasNote(alias, newState); note != "" {
+			fmt.Fprintf(os.Stderr, "Note: %s\n", note)
+		}
+		if warning := ageWarning(alias, newState, time.Now()); warning != "" {
+			fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+		}
+	}
+
+	outcomes := make(map[string]string, len(emails))
+	for _, email := range skipped {
+		outcomes[email] = "unchanged"
+	}
+	for _, email := range protected {
+		outcomes[email] = "protected"
+	}
+
+	// The checkpoint is saved before the first request and after each one, so
+	// that a run killed outright, e.g. by a second Ctrl-C, can be resumed too
+	saveFailed := false
+	saveProgress := func() {
+		if err := checkpoint.save(time.Now()); err != nil && !saveFailed {
+			saveFailed = true
+			fmt.Fprintf(os.Stderr, "Warning: could not save progress: %v\n", err)
+		}
+	}
+	if len(updates) > 0 {
+		saveProgress()
+		logf(levelActions, "saving progress to %s", checkpoint.path)
+	}
+	result, err := client.UpdateAliases(ctx, updates, func(chunk *BatchResult) {
+		changed := make([]MaskedEmailInfo, 0, len(chunk.Updated))
+		for _, id := range chunk.Updated {
+			checkpoint.markDone(emailByID[id])
+			outcomes[emailByID[id]] = "updated"
+			changed = append(changed, byEmail[emailByID[id]])
+		}
+		recordStateChanges(changed, newState, time.Now())
+		for id, setErr := range chunk.Failed {
+			checkpoint.markFailed(emailByID[id], setErr.String())
+		}
+		saveProgress()
+	})
+	if result != nil {
+		fmt.Fprintf(humanOut, "Set %d of %d aliases to '%s'\n", len(result.Updated), len(emails), newState)
+	}
+	for _, email := range skipped {
+		fmt.Fprintf(humanOut, "- %s: already '%s'\n", email, newState)
+	}
+	for _, email := range protected {
+		fmt.Fprintf(humanOut, "- %s: protected, not deleted (use --force to delete it)\n", email)
+	}
+	failed := 0
+	for _, email := range emails {
+		if reason, ok := checkpoint.Failed[email]; ok {
+			if failed == 0 {
+				fmt.Fprintln(humanOut, "Failed:")
+			}
+			failed++
+			fmt.Fprintf(humanOut, "- %s: %s\n", email, reason)
+		}
+	}
+
+	if porcelain != "" {
+		// email, state, outcome (updated, unchanged, protected, failed or pending), reason
+		for _, email := range emails {
+			outcome, reason := outcomes[email], ""
+			if failure, ok := checkpoint.Failed[email]; ok {
+				outcome, reason = "failed", failure
+			} else if outcome == "" {
+				outcome = "pending"
+			}
+			printPorcelain(email, string(newState), outcome, reason)
+		}
+	}
+
+	// Failures are kept for --resume to retry, unless no request was sent
+	if len(checkpoint.Pending) > 0 || len(checkpoint.Failed) > 0 && checkpoint.path != "" {
+		if len(checkpoint.Pending) > 0 {
+			fmt.Fprintf(humanOut, "Not changed: %d aliases were not processed\n", len(checkpoint.Pending))
+		}
+		if saveErr := checkpoint.save(time.Now()); saveErr != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not save progress: %v\n", saveErr)
+		} else {
+			fmt.Fprintf(humanOut, "Progress saved; %s with: masked_fastmail --resume %s\n",
+				choose(len(checkpoint.Pending) > 0, "continue", "retry the failed aliases"), checkpoint.path)
+		}
+	} else if checkpoint.path != "" {
+		// The run completed, so the checkpoint is no longer needed
+		if removeErr := checkpoint.remove(); removeErr != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not remove checkpoint: %v\n", removeErr)
+		}
+	}
+
+	if errors.Is(err, ErrInterrupted) {
 		return err
 	}
+	if err != nil {
+		return formatAPIError("failed to update alias status", err)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d aliases could not be updated", failed, len(emails))
+	}
+	return nil
+}
+
+// listOptions controls how handleAliasList renders its results.
+type listOptions struct {
+	// wide includes details beyond state and description
+	wide bool
+	// filters are --regex and --brand filters every listed alias must match
+	filters []aliasFilter
+	// owner limits the list to aliases created by an owner, see ownerMatches
+	owner string
+	// dates limits the list to aliases created or last receiving mail in a
+	// period
+	dates dateFilter
+	// count prints the number of aliases instead of listing them, see
+	// printCount
+	count string
+}
+
+// listRecord is an alias as printed by --list --output ndjson.
+type listRecord struct {
+	MaskedEmailInfo
+	// Match is how the alias matched: domain, search or filter
+	Match  string `json:"match"`
+	Folder string `json:"folder,omitempty"`
+	Owner  string `json:"owner,omitempty"`
+}
+
+// handleAliasList prints metadata for all aliases associated with a domain
+// without creating or modifying anything. With regex, owner or date filters,
+// the identifier may be empty to list every alias matching them.
+func handleAliasList(client *FastmailClient, identifier string, opts listOptions) error {
+	var displayInput, normalizedDomain string
+	if identifier != "" || (len(opts.filters) == 0 && opts.owner == "" && !opts.dates.active()) {
+		var err error
+		displayInput, normalizedDomain, err = prepareDomainInput(identifier)
+		if err != nil {
+			return err
+		}
+	}
 
 	aliases, err := client.FetchAllAliases()
 	if err != nil {
 		return formatAPIError("failed to list aliases", err)
 	}
 
-	matching, related := filterAliasesForList(aliases, normalizedDomain, displayInput)
+	var matching, related []MaskedEmailInfo
+	if normalizedDomain == "" {
+		matching = filterAliases(aliases, opts.filters)
+	} else {
+		matching, related = filterAliasesForList(aliases, normalizedDomain, displayInput)
+		if len(opts.filters) > 0 {
+			matching = filterAliases(matching, opts.filters)
+			related = filterAliases(related, opts.filters)
+		}
+	}
+
+	metadata, err := loadMetadata()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not read alias metadata: %v\n", err)
+	}
+	if opts.owner != "" {
+		byOwner := func(in []MaskedEmailInfo) []MaskedEmailInfo {
+			var out []MaskedEmailInfo
+			for _, alias := range in {
+				if ownerMatches(metadata[alias.Email], opts.owner) {
+					out = append(out, alias)
+				}
+			}
+			return out
+		}
+		matching, related = byOwner(matching), byOwner(related)
+	}
+	matching, related = opts.dates.filter(matching), opts.dates.filter(related)
+	if opts.count != "" {
+		var states []AliasState
+		for _, alias := range append(append([]MaskedEmailInfo{}, matching...), related...) {
+			states = append(states, alias.State)
+		}
+		printCount(states, opts.count)
+		return nil
+	}
 	if len(matching) == 0 && len(related) == 0 {
-		fmt.Printf("No aliases found matching %s\n", displayInput)
+		if displayInput == "" {
+			fmt.Fprintln(humanOut, "No aliases found matching the filters")
+		} else {
+			fmt.Fprintf(humanOut, "No aliases found matching %s\n", displayInput)
+		}
+		return nil
+	}
+
+	var folders map[string]string
+	if opts.wide {
+		folders = resolveFolders(client, append(append([]MaskedEmailInfo{}, matching...), related...))
+	}
+
+	groups := []struct {
+		aliases []MaskedEmailInfo
+		match   string
+	}{{matching, choose(normalizedDomain == "", "filter", "domain")}, {related, "search"}}
+	if outputFormat == outputNDJSON {
+		for _, group := range groups {
+			for _, alias := range group.aliases {
+				record := listRecord{MaskedEmailInfo: alias, Match: group.match, Folder: folders[alias.Email], Owner: ownerLabel(metadata[alias.Email])}
+				if err := printNDJSON(record); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	if porcelain != "" {
+		// email, state, forDomain, description, folder, match, owner, createdAt
+		for _, group := range groups {
+			for _, alias := range group.aliases {
+				printPorcelain(alias.Email, string(alias.State), alias.ForDomain, alias.Description, folders[alias.Email], group.match,
+					ownerLabel(metadata[alias.Email]), formatExportTime(&alias.CreatedAt))
+			}
+		}
 		return nil
 	}
 
@@ -299,8 +1073,12 @@ func handleAliasList(client *FastmailClient, identifier string) error {
 		state       string
 		url         string
 		description string
+		folder      string
+		owner       string
+		created     string
 	}
 
+	now := time.Now()
 	buildRows := func(in []MaskedEmailInfo) []aliasRow {
 		rows := make([]aliasRow, 0, len(in))
 		for _, alias := range in {
@@ -312,11 +1090,26 @@ func handleAliasList(client *FastmailClient, identifier string) error {
 			if url == "" {
 				url = "(unknown domain)"
 			}
+			folder := folders[alias.Email]
+			if folder == "" {
+				folder = "(none)"
+			}
+			owner := ownerLabel(metadata[alias.Email])
+			if owner == "" {
+				owner = "(unknown)"
+			}
+			created := aliasCreated(alias, now)
+			if created == "" {
+				created = "(unknown)"
+			}
 			rows = append(rows, aliasRow{
-				email:       alias.Email,
-				state:       string(alias.State),
-				url:         url,
-				description: description,
+				email:       sanitizeText(alias.Email),
+				state:       themedState(alias.State),
+				url:         sanitizeText(url),
+				description: sanitizeText(description),
+				folder:      sanitizeText(folder),
+				owner:       owner,
+				created:     created,
 			})
 		}
 		return rows
@@ -328,15 +1121,14 @@ func handleAliasList(client *FastmailClient, identifier string) error {
 	maxEmailWidth := 0
 
 	for _, row := range allRows {
-		if emailWidth := utf8.RuneCountInString(row.email); emailWidth > maxEmailWidth {
+		if emailWidth := displayWidth(row.email); emailWidth > maxEmailWidth {
 			maxEmailWidth = emailWidth
 		}
 	}
 
-	firstLineFormat := fmt.Sprintf("- %%-%ds (state: %%s)\n", maxEmailWidth)
 	printRows := func(rows []aliasRow, includeURL bool) {
 		for idx, row := range rows {
-			fmt.Printf(firstLineFormat, row.email, row.state)
+			fmt.Printf("- %s (state: %s)\n", padRight(row.email, maxEmailWidth), row.state)
 			if includeURL {
 				domainLabel := strings.TrimSpace(row.url)
 				if domainLabel == "" {
@@ -345,13 +1137,21 @@ func handleAliasList(client *FastmailClient, identifier string) error {
 				fmt.Printf("  Domain:      %s\n", domainLabel)
 			}
 			fmt.Printf("  Description: %s\n", row.description)
+			fmt.Printf("  Created:     %s\n", row.created)
+			if opts.wide {
+				fmt.Printf("  Folder:      %s\n", row.folder)
+				fmt.Printf("  Owner:       %s\n", row.owner)
+			}
 			if idx < len(rows)-1 {
 				fmt.Println()
 			}
 		}
 	}
 
-	if len(matchingRows) == 0 {
+	if normalizedDomain == "" {
+		fmt.Println("Aliases matching the filters:")
+		printRows(matchingRows, true)
+	} else if len(matchingRows) == 0 {
 		fmt.Printf("No aliases found for domain %s\n", normalizedDomain)
 	} else {
 		fmt.Printf("Aliases for %s:\n", normalizedDomain)
@@ -364,42 +1164,69 @@ func handleAliasList(client *FastmailClient, identifier string) error {
 		} else {
 			fmt.Println()
 		}
-		fmt.Printf("Additional matches containing %q:\n", strings.TrimSpace(displayInput))
+		fmt.Printf("Additional matches for %q, best first:\n", strings.TrimSpace(displayInput))
 		printRows(relatedRows, true)
 	}
 
 	return nil
 }
 
-// handleAliasLookupOrCreation handles alias lookup and creation if needed
-func handleAliasLookupOrCreation(client *FastmailClient, identifier string, description *string) error {
+// handleAliasLookupOrCreation handles alias lookup and creation if needed,
+// returning the selected alias. With activate, a new alias is created enabled
+// rather than pending. Unless forceNew is set, the user is offered an alias
+// of a nearby site, such as www.example.com, before a new one is created.
+func handleAliasLookupOrCreation(client *FastmailClient, identifier string, description *string, activate, forceNew bool) (*MaskedEmailInfo, error) {
 	_, normalizedDomain, err := prepareDomainInput(identifier)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	explainNormalization(identifier, normalizedDomain)
 
+	explainf("fetching the aliases of account %s to find those for %s", client.AccountID, normalizedDomain)
 	aliases, err := client.GetAliases(normalizedDomain)
 	if err != nil {
-		return formatAPIError("failed to get aliases", err)
+		return nil, formatAPIError("failed to get aliases", err)
 	}
 	selectedAlias := selectPreferredAlias(aliases)
+	explainSelection(normalizedDomain, aliases, selectedAlias)
+	if selectedAlias == nil && !forceNew {
+		explainf("looking for an alias of a nearby site, such as the www. or http:// variant")
+		if selectedAlias, err = offerSiblingAlias(client, normalizedDomain); err != nil {
+			return nil, err
+		}
+	}
 
 	createdNew := false
 	if selectedAlias == nil {
 		// Create new alias
-		fmt.Printf("No alias found for %s, creating new one...\n", normalizedDomain)
-		newAlias, err := client.CreateAlias(normalizedDomain, description)
+		fmt.Fprintf(humanOut, "No alias found for %s, creating new one...\n", normalizedDomain)
+		creation := AliasCreation{Domain: normalizedDomain, Description: defaultDescription(normalizedDomain, description)}
+		if activate {
+			creation.State = AliasEnabled
+		}
+		if explain {
+			descriptionText := ""
+			if creation.Description != nil {
+				descriptionText = *creation.Description
+			}
+			explainf("creating an alias for %s with the description %q, %s", normalizedDomain, descriptionText,
+				choose(activate, "enabled (--activate)", "pending until it receives mail"))
+		}
+		newAlias, err := client.CreateAliasWith(creation)
 		if err != nil {
-			return formatAPIError("failed to create alias", err)
+			return nil, formatAPIError("failed to create alias", err)
 		}
 		selectedAlias = newAlias
 		createdNew = true
+		recordUsage(eventCreated)
+		recordOwnership(newAlias.Email)
+		warnSimilarAliases(client, newAlias)
 	} else if len(aliases) > 1 {
-		fmt.Printf("Found %d aliases for %s:\n", len(aliases), normalizedDomain)
+		fmt.Fprintf(humanOut, "Found %d aliases for %s:\n", len(aliases), normalizedDomain)
 		for _, alias := range aliases {
-			fmt.Printf("- %s (state: %s)\n", alias.Email, alias.State)
+			fmt.Fprintf(humanOut, "- %s (state: %s)\n", alias.Email, alias.State)
 		}
-		fmt.Println("\nSelected alias:")
+		fmt.Fprintln(humanOut, "\nSelected alias:")
 	}
 
 	if description != nil && !createdNew {
@@ -409,21 +1236,38 @@ func handleAliasLookupOrCreation(client *FastmailClient, identifier string, desc
 		}
 	}
 
-	fmt.Printf("%s (state: %s)", selectedAlias.Email, selectedAlias.State)
-	if err := copyToClipboard(selectedAlias.Email); err != nil {
-		fmt.Fprintf(os.Stderr, "\nWarning: Could not copy to clipboard: %v\n", err)
-	} else {
-		fmt.Println(" (copied to clipboard)")
-	}
-	return nil
+	printAndCopyAlias(selectedAlias, createdNew)
+	return selectedAlias, nil
 }
 
-// copyToClipboard attempts to copy the given text to the system clipboard
-func copyToClipboard(text string) error {
-	if err := clipboard.WriteAll(text); err != nil {
-		return fmt.Errorf("failed to copy to clipboard: %w", err)
+// waitForFirstMessage polls the alias until it receives its first message or
+// the timeout expires.
+func waitForFirstMessage(client *FastmailClient, alias *MaskedEmailInfo, timeout time.Duration) error {
+	if alias.LastMessageAt != nil {
+		fmt.Fprintf(humanOut, "%s already received mail (last message %s)\n", alias.Email, formatTime(*alias.LastMessageAt))
+		return nil
 	}
-	return nil
+
+	fmt.Fprintf(humanOut, "Waiting up to %s for the first message to %s...\n", timeout, alias.Email)
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		wait := mailPollInterval
+		if remaining := time.Until(deadline); remaining < wait {
+			wait = remaining
+		}
+		time.Sleep(wait)
+
+		current, err := client.GetAliasByID(alias.ID)
+		if err != nil {
+			return formatAPIError("failed to check alias", err)
+		}
+		if current.LastMessageAt != nil {
+			fmt.Fprintf(humanOut, "First message received %s (state: %s)\n", formatTime(*current.LastMessageAt), current.State)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("no message received for %s within %s", alias.Email, timeout)
 }
 
 // formatAPIError augments Fastmail API errors with helpful context so users
@@ -432,21 +1276,35 @@ func formatAPIError(action string, err error) error {
 	var apiErr *APIError
 	if errors.As(err, &apiErr) {
 		switch {
+		case apiErr.StatusCode == http.StatusUnauthorized:
+			return unauthorizedError(action)
+		case apiErr.Type == "accountReadOnly" || apiErr.Type == "forbidden" || apiErr.StatusCode == http.StatusForbidden:
+			return fmt.Errorf("%s: %w: the API token is not allowed to perform this operation; check that it has write access to masked email", action, ErrReadOnly)
 		case apiErr.StatusCode > 0:
 			body := strings.TrimSpace(apiErr.ResponseBody)
 			if body == "" {
 				body = apiErr.Message
 			}
-			return fmt.Errorf("%s: Fastmail API returned HTTP %d: %s", action, apiErr.StatusCode, body)
+			return &apiFailure{fmt.Sprintf("%s: Fastmail API returned HTTP %d: %s%s", action, apiErr.StatusCode, body, apiErr.incidentSuffix()), apiErr}
 		case apiErr.Type != "":
-			return fmt.Errorf("%s: Fastmail API error (%s): %s", action, apiErr.Type, apiErr.Message)
+			return &apiFailure{fmt.Sprintf("%s: Fastmail API error (%s): %s", action, apiErr.Type, apiErr.Message), apiErr}
 		default:
-			return fmt.Errorf("%s: Fastmail API error: %s", action, apiErr.Message)
+			return &apiFailure{fmt.Sprintf("%s: Fastmail API error: %s", action, apiErr.Message), apiErr}
 		}
 	}
 	return fmt.Errorf("%s: %w", action, err)
 }
 
+// apiFailure is an API error reworded by formatAPIError. The APIError stays
+// in the chain for the --json error output.
+type apiFailure struct {
+	message string
+	cause   *APIError
+}
+
+func (e *apiFailure) Error() string { return e.message }
+func (e *apiFailure) Unwrap() error { return e.cause }
+
 // handleDescriptionUpdate updates the description for an existing alias identified by email.
 func handleDescriptionUpdate(client *FastmailClient, identifier string, newDescription string) error {
 	email, err := normalizeEmailInput(identifier)
@@ -458,25 +1316,32 @@ func handleDescriptionUpdate(client *FastmailClient, identifier string, newDescr
 	if err != nil {
 		return formatAPIError("failed to get alias", err)
 	}
+	if alias.State == AliasDeleted {
+		return deletedAliasError(alias.Email)
+	}
 
 	if alias.Description == newDescription {
-		fmt.Println("Description already set to the requested value.")
-		return nil
+		fmt.Fprintln(humanOut, "Description already set to the requested value.")
+	} else {
+		if err := client.UpdateAliasDescription(alias, newDescription); err != nil {
+			return formatAPIError("failed to update alias description", err)
+		}
+		fmt.Fprintln(humanOut, "Description updated.")
 	}
 
-	if err := client.UpdateAliasDescription(alias, newDescription); err != nil {
-		return formatAPIError("failed to update alias description", err)
+	if porcelain != "" {
+		// email, description
+		printPorcelain(alias.Email, newDescription)
 	}
-
-	fmt.Println("Description updated.")
 	return nil
 }
 
-// filterAliasesForList splits aliases into primary (forDomain matches) and related (search matches).
+// filterAliasesForList splits aliases into primary (forDomain matches) and
+// related (subdomain and search matches). Related aliases are ordered best
+// match first.
 func filterAliasesForList(aliases []MaskedEmailInfo, normalizedDomain string, searchInput string) (primary []MaskedEmailInfo, related []MaskedEmailInfo) {
-	needleDomain := strings.ToLower(strings.TrimSpace(normalizedDomain))
-	needleSearch := strings.ToLower(strings.TrimSpace(searchInput))
 	seen := make(map[string]struct{})
+	var ranked []rankedAlias
 
 	for _, alias := range aliases {
 		if alias.State == AliasDeleted {
@@ -491,52 +1356,25 @@ func filterAliasesForList(aliases []MaskedEmailInfo, normalizedDomain string, se
 			continue
 		}
 
+		rank := rankNone
 		if aliasMatchesSubdomain(alias, normalizedDomain) {
-			if alias.ID != "" {
-				if _, ok := seen[alias.ID]; ok {
-					continue
-				}
-				seen[alias.ID] = struct{}{}
-			}
-			related = append(related, alias)
-			continue
-		}
-
-		if aliasMatchesSearch(alias, needleDomain, needleSearch) {
-			if alias.ID != "" {
-				if _, ok := seen[alias.ID]; ok {
-					continue
-				}
-				seen[alias.ID] = struct{}{}
-			}
-			related = append(related, alias)
+			rank = rankSubdomain
+		} else {
+			rank = aliasSearchRank(alias, fuzzySearch, normalizedDomain, searchInput)
 		}
-	}
-
-	return primary, related
-}
-
-func aliasMatchesSearch(alias MaskedEmailInfo, needles ...string) bool {
-	fields := []string{
-		strings.ToLower(alias.Email),
-		strings.ToLower(alias.Description),
-		strings.ToLower(alias.ForDomain),
-		strings.ToLower(alias.ID),
-	}
-
-	for _, needle := range needles {
-		needle = strings.TrimSpace(needle)
-		if needle == "" {
+		if rank == rankNone {
 			continue
 		}
-		for _, field := range fields {
-			if field != "" && strings.Contains(field, needle) {
-				return true
+		if alias.ID != "" {
+			if _, ok := seen[alias.ID]; ok {
+				continue
 			}
+			seen[alias.ID] = struct{}{}
 		}
+		ranked = append(ranked, rankedAlias{alias: alias, rank: rank})
 	}
 
-	return false
+	return primary, sortByRank(ranked)
 }
 
 func aliasMatchesSubdomain(alias MaskedEmailInfo, targetDomain string) bool {
@@ -1,6 +1,27 @@
 package main
 
-import "testing"
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestMain keeps the tests out of the data directory of the user: commands
+// record local data, such as the recycle bin and the audit log, as they
+// change aliases. Tests that read that data set a directory of their own.
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "masked_fastmail-test-")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Setenv(dataDirEnv, dir)
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
 
 func TestSelectPreferredAliasUnknownState(t *testing.T) {
 	aliases := []MaskedEmailInfo{
@@ -68,3 +89,64 @@ func TestFilterAliasesForList(t *testing.T) {
 		t.Fatalf("expected subdomain alias to appear in related matches, got %+v", related)
 	}
 }
+
+func TestFormatAPIErrorReadOnly(t *testing.T) {
+	err := formatAPIError("failed to update alias status", &APIError{Type: "accountReadOnly"})
+	if !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("expected accountReadOnly errors to map to ErrReadOnly, got %v", err)
+	}
+
+	err = formatAPIError("failed to get alias", &APIError{Type: "serverFail", Message: "oops"})
+	if errors.Is(err, ErrReadOnly) {
+		t.Fatalf("did not expect unrelated errors to map to ErrReadOnly")
+	}
+}
+
+func TestEnsureWritable(t *testing.T) {
+	client := &FastmailClient{AccountID: "u1", ReadOnly: true}
+	if err := client.ensureWritable(); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("expected read-only mode to refuse writes, got %v", err)
+	}
+
+	client = &FastmailClient{AccountID: "u1", session: &Session{Accounts: map[string]SessionAccount{
+		"u1": {Name: "me@example.com", IsReadOnly: true},
+	}}}
+	if err := client.ensureWritable(); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("expected read-only account to refuse writes, got %v", err)
+	}
+
+	client.session.Accounts["u1"] = SessionAccount{Name: "me@example.com"}
+	if err := client.ensureWritable(); err != nil {
+		t.Fatalf("expected writable account to allow writes, got %v", err)
+	}
+}
+
+func TestDeletedAliasNote(t *testing.T) {
+	deleted := MaskedEmailInfo{Email: "old.1@fastmail.com", State: AliasDeleted}
+	if note, err := deletedAliasNote(deleted, AliasEnabled); err != nil || !strings.Contains(note, "enabling restores it") {
+		t.Errorf("enable: %q, %v; want a restore note", note, err)
+	}
+	if note, err := deletedAliasNote(deleted, AliasDisabled); err != nil || !strings.Contains(note, "restores it as disabled") {
+		t.Errorf("disable: %q, %v; want a note that it is restored as disabled", note, err)
+	}
+	_, err := deletedAliasNote(deleted, AliasDeleted)
+	if !errors.Is(err, ErrAliasDeleted) || !strings.Contains(err.Error(), "use --enable to restore it") {
+		t.Errorf("delete: %v; want ErrAliasDeleted with a hint", err)
+	}
+	if note, err := deletedAliasNote(MaskedEmailInfo{Email: "shop.2@fastmail.com", State: AliasEnabled}, AliasDisabled); note != "" || err != nil {
+		t.Errorf("enabled alias: %q, %v; want nothing", note, err)
+	}
+}
+
+func TestHandleDescriptionUpdateDeleted(t *testing.T) {
+	fake, client := newFakeJMAP(t,
+		MaskedEmailInfo{ID: "a1", Email: "old.1@fastmail.com", Description: "Old shop", State: AliasDeleted},
+	)
+	err := handleDescriptionUpdate(client, "old.1@fastmail.com", "New shop")
+	if !errors.Is(err, ErrAliasDeleted) || errorType(err) != "deleted" {
+		t.Fatalf("handleDescriptionUpdate() = %v; want ErrAliasDeleted", err)
+	}
+	if got := fake.aliases["a1"].Description; got != "Old shop" {
+		t.Errorf("description = %q; want it unchanged", got)
+	}
+}
@@ -0,0 +1,551 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// MaskedEmail capability and methods
+const (
+	maskedEmailNamespace = "https://www.fastmail.com/dev/maskedemail"
+	methodGet            = "MaskedEmail/get"
+	methodSet            = "MaskedEmail/set"
+)
+
+// maskedEmailCapabilities are declared by requests for masked email aliases
+var maskedEmailCapabilities = []string{capabilityCore, maskedEmailNamespace}
+
+// Alias creation retry settings
+const (
+	createAttempts     = 2
+	creationWindow     = 10 * time.Minute // period during which creation IDs are reused
+	clockSkewTolerance = time.Minute      // allowed difference between local and server clocks
+)
+
+// aliasProperties are the MaskedEmail properties fetched by the CLI
+var aliasProperties = []string{"email", "forDomain", "state", "description", "id", "createdAt", "lastMessageAt"}
+
+// ErrAliasNotFound is returned when an alias cannot be found
+var ErrAliasNotFound = errors.New("alias not found")
+
+// ErrAliasDeleted is returned when an alias exists but is deleted, for
+// changes that only make sense on aliases in use
+var ErrAliasDeleted = errors.New("alias exists but is deleted")
+
+// deletedAliasError returns ErrAliasDeleted for the alias, telling how to
+// restore or destroy it.
+func deletedAliasError(email string) error {
+	return fmt.Errorf("%w: %s (use --enable to restore it, or purge to destroy it)", ErrAliasDeleted, email)
+}
+
+// getMaskedEmail performs a MaskedEmail/get request with the given properties.
+// If ids is empty, all aliases are returned.
+// Note: The API does not support server-side filtering, so we filter the results client-side.
+func (fc *FastmailClient) getMaskedEmail(ids []string, properties []string) ([]MaskedEmailInfo, error) {
+	payload, err := fc.buildRequest(methodCall{
+		name: methodGet,
+		arguments: struct {
+			AccountID  string   `json:"accountId"`
+			IDs        []string `json:"ids,omitempty"`
+			Properties []string `json:"properties"`
+		}{
+			AccountID:  fc.AccountID,
+			IDs:        ids,
+			Properties: properties,
+		},
+		clientID: nil,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	op := opDefault
+	if len(ids) == 0 {
+		op = opFullFetch
+	}
+	// The full list can be large, so it is decoded while it is received
+	var list []MaskedEmailInfo
+	var response *JMAPResponse
+	err = fc.postJMAP(op, payload, func(body io.Reader) error {
+		var err error
+		list, response, err = decodeMaskedEmailList(body)
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal response data: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	fc.checkSessionState(response.SessionState)
+
+	// Validate response structure before accessing
+	if err := fc.validateJMAPResponse(response); err != nil {
+		return nil, err
+	}
+	if err := fc.validateMethodResponse(response, 0, 2); err != nil {
+		return nil, err
+	}
+
+	if fc.aliasEmails == nil {
+		fc.aliasEmails = make(map[string]string, len(list))
+	}
+	for _, alias := range list {
+		fc.aliasEmails[alias.ID] = alias.Email
+	}
+	return list, nil
+}
+
+// setMaskedEmail performs a MaskedEmail/set request with the given updates or creates
+func (fc *FastmailClient) setMaskedEmail(create map[string]MaskedEmailCreate, update map[string]MaskedEmailUpdate) (*JMAPResponse, error) {
+	if err := fc.ensureWritable(); err != nil {
+		return nil, err
+	}
+
+	args := struct {
+		Create    map[string]MaskedEmailCreate `json:"create,omitempty"`
+		Update    map[string]MaskedEmailUpdate `json:"update,omitempty"`
+		AccountID string                       `json:"accountId"`
+	}{
+		AccountID: fc.AccountID,
+		Create:    create,
+		Update:    update,
+	}
+
+	return fc.invoke(maskedEmailCapabilities, methodCall{
+		name:      methodSet,
+		arguments: args,
+		clientID:  nil,
+	})
+}
+
+// FetchAllAliases retrieves all masked email aliases with the fields needed by the CLI.
+func (fc *FastmailClient) FetchAllAliases() ([]MaskedEmailInfo, error) {
+	return fc.getMaskedEmail(nil, aliasProperties)
+}
+
+// GetAliasByID retrieves a single alias by its ID.
+// Returns ErrAliasNotFound if the alias doesn't exist.
+func (fc *FastmailClient) GetAliasByID(id string) (*MaskedEmailInfo, error) {
+	aliases, err := fc.getMaskedEmail([]string{id}, aliasProperties)
+	if err != nil {
+		return nil, err
+	}
+	if len(aliases) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrAliasNotFound, id)
+	}
+	return &aliases[0], nil
+}
+
+// AliasState represents the possible states of a masked email
+type AliasState string
+
+const (
+	AliasPending  AliasState = "pending"
+	AliasEnabled  AliasState = "enabled"
+	AliasDisabled AliasState = "disabled"
+	AliasDeleted  AliasState = "deleted"
+)
+
+type MaskedEmailInfo struct {
+	ID            string     `json:"id"`
+	Email         string     `json:"email"`
+	State         AliasState `json:"state"`
+	ForDomain     string     `json:"forDomain"`
+	Description   string     `json:"description"`
+	CreatedBy     string     `json:"createdBy"`
+	URL           string     `json:"url,omitempty"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	LastMessageAt *time.Time `json:"lastMessageAt,omitempty"`
+}
+
+// MaskedEmailCreate defines the payload for creating a masked email
+type MaskedEmailCreate struct {
+	ForDomain   string `json:"forDomain"`
+	Description string `json:"description"`
+	URL         string `json:"url,omitempty"`
+	EmailPrefix string `json:"emailPrefix,omitempty"`
+	// State is the initial state; empty leaves the server default (pending)
+	State AliasState `json:"state,omitempty"`
+}
+
+// MaskedEmailUpdate defines the payload for updating a masked email
+type MaskedEmailUpdate struct {
+	State       *AliasState `json:"state,omitempty"`
+	Description *string     `json:"description,omitempty"`
+}
+
+// buildRequest builds a request for MaskedEmail methods.
+func (fc *FastmailClient) buildRequest(calls ...methodCall) (*JMAPRequest, error) {
+	return fc.buildRequestUsing(maskedEmailCapabilities, calls...)
+}
+
+func (fc *FastmailClient) GetAliases(domain string) ([]MaskedEmailInfo, error) {
+	targetDomain, err := normalizeOrigin(domain)
+	if err != nil {
+		return nil, err
+	}
+
+	maskedEmails, err := fc.FetchAllAliases()
+	if err != nil {
+		return nil, err
+	}
+
+	var filteredAliases []MaskedEmailInfo
+	for _, alias := range maskedEmails {
+		if alias.State == AliasDeleted {
+			continue
+		}
+
+		if aliasMatchesDomain(alias, targetDomain) {
+			filteredAliases = append(filteredAliases, alias)
+		}
+	}
+
+	return filteredAliases, nil
+}
+
+// parseCreatedAlias extracts the alias created under the given creation ID
+// from a JMAP response
+func (fc *FastmailClient) parseCreatedAlias(response *JMAPResponse, creationID string) (*MaskedEmailInfo, error) {
+	var createdAlias struct {
+		Created    map[string]MaskedEmailInfo `json:"created"`
+		NotCreated map[string]SetError        `json:"notCreated"`
+	}
+	if err := fc.methodResult(response, 0, &createdAlias); err != nil {
+		return nil, err
+	}
+
+	if setErr, ok := createdAlias.NotCreated[creationID]; ok {
+		return nil, &APIError{
+			Type:    setErr.Type,
+			Message: setErr.Description,
+		}
+	}
+
+	alias, ok := createdAlias.Created[creationID]
+	if !ok {
+		return nil, fmt.Errorf("server did not confirm the alias creation")
+	}
+	return &alias, nil
+}
+
+// parseUpdatedAlias verifies that an alias update was successful
+func (fc *FastmailClient) parseUpdatedAlias(response *JMAPResponse, aliasID string) error {
+	// Verify the update was successful
+	var updateResponse struct {
+		Updated map[string]interface{} `json:"updated"`
+	}
+	if err := fc.methodResult(response, 0, &updateResponse); err != nil {
+		return err
+	}
+
+	if _, ok := updateResponse.Updated[aliasID]; !ok {
+		return fmt.Errorf("server did not confirm the alias update")
+	}
+
+	return nil
+}
+
+// AliasCreation describes an alias to create and what else to do in the
+// same request.
+type AliasCreation struct {
+	Domain      string
+	Description *string
+	// State is the state to create the alias in; empty leaves it pending
+	// until it receives mail
+	State AliasState
+	// Replaces is an alias to disable in the same request, so that the old
+	// alias is never disabled without its replacement existing
+	Replaces *MaskedEmailInfo
+}
+
+// CreateAlias creates a new alias for the domain. See CreateAliasWith.
+func (fc *FastmailClient) CreateAlias(domain string, description *string) (*MaskedEmailInfo, error) {
+	return fc.CreateAliasWith(AliasCreation{Domain: domain, Description: description})
+}
+
+// CreateAliasWith creates a new alias. The creation, any change to the
+// replaced alias and a read of the stored alias are made in a single request,
+// leaving no window in which only some of the steps happened. The request
+// uses a deterministic creation ID, and if it fails in a way that leaves the
+// outcome unknown (e.g. a network timeout), the account is checked for an
+// alias created by the lost request before the creation is retried.
+func (fc *FastmailClient) CreateAliasWith(creation AliasCreation) (*MaskedEmailInfo, error) {
+	targetDomain, err := normalizeOrigin(creation.Domain)
+	if err != nil {
+		return nil, err
+	}
+
+	descValue := ""
+	if creation.Description != nil {
+		descValue = *creation.Description
+	}
+	if err := fc.checkDomainCap(targetDomain, creation.Replaces); err != nil {
+		return nil, err
+	}
+	logf(levelActions, "creating an alias for %s", targetDomain)
+
+	started := time.Now()
+	id := creationID(targetDomain, started)
+	create := map[string]MaskedEmailCreate{
+		id: {
+			ForDomain:   targetDomain,
+			Description: descValue,
+			State:       creation.State,
+		},
+	}
+	var update map[string]MaskedEmailUpdate
+	if creation.Replaces != nil {
+		disabled := AliasDisabled
+		update = map[string]MaskedEmailUpdate{
+			creation.Replaces.ID: {State: &disabled},
+		}
+	}
+
+	var lastErr error
+	for attempt := 1; attempt <= createAttempts; attempt++ {
+		if attempt > 1 {
+			existing, err := fc.findAliasCreatedSince(targetDomain, started)
+			if err != nil {
+				return nil, fmt.Errorf("failed to check for duplicate alias after %v: %w", lastErr, err)
+			}
+			if existing != nil {
+				fc.auditCreation(existing, creation)
+				return existing, nil
+			}
+		}
+
+		alias, err := fc.createPipeline(id, create, update)
+		if err == nil {
+			recordCreation(time.Now())
+			fc.auditCreation(alias, creation)
+		}
+		if err == nil || !isOutcomeUnknown(err) {
+			return alias, err
+		}
+		lastErr = err
+	}
+
+	return nil, lastErr
+}
+
+// auditCreation records a created alias, and the alias it replaces, in the
+// audit log.
+func (fc *FastmailClient) auditCreation(alias *MaskedEmailInfo, creation AliasCreation) {
+	entries := []auditEntry{{
+		Action: auditCreate,
+		Alias:  alias.Email,
+		ID:     alias.ID,
+		Changes: map[string]string{
+			"forDomain":   alias.ForDomain,
+			"description": alias.Description,
+			"state":       string(alias.State),
+		},
+	}}
+	if creation.Replaces != nil {
+		entries = append(entries, auditEntry{
+			Action:  auditUpdate,
+			Alias:   creation.Replaces.Email,
+			ID:      creation.Replaces.ID,
+			Changes: map[string]string{"state": string(AliasDisabled)},
+		})
+	}
+	fc.recordAudit(entries...)
+}
+
+// createPipeline sends a MaskedEmail/set that creates the alias and applies
+// the updates, followed by a MaskedEmail/get of the created alias through a
+// result reference to its ID, and returns the alias as stored.
+func (fc *FastmailClient) createPipeline(id string, create map[string]MaskedEmailCreate, update map[string]MaskedEmailUpdate) (*MaskedEmailInfo, error) {
+	if err := fc.ensureWritable(); err != nil {
+		return nil, err
+	}
+
+	payload, err := fc.buildRequest(
+		methodCall{
+			name: methodSet,
+			arguments: struct {
+				Create    map[string]MaskedEmailCreate `json:"create"`
+				Update    map[string]MaskedEmailUpdate `json:"update,omitempty"`
+				AccountID string                       `json:"accountId"`
+			}{
+				AccountID: fc.AccountID,
+				Create:    create,
+				Update:    update,
+			},
+			clientID: "set",
+		},
+		methodCall{
+			name: methodGet,
+			arguments: map[string]interface{}{
+				"accountId": fc.AccountID,
+				"#ids": resultReference{
+					ResultOf: "set",
+					Name:     methodSet,
+					Path:     "/created/*/id",
+				},
+				"properties": aliasProperties,
+			},
+			clientID: "get",
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &JMAPResponse{}
+	err = fc.postJMAP(opDefault, payload, func(body io.Reader) error {
+		if err := decodeJSON(body, response); err != nil {
+			return fmt.Errorf("failed to unmarshal JSON response: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	fc.checkSessionState(response.SessionState)
+	// Only the set has to succeed; the get merely reads the result back
+	if err := fc.validateJMAPResponse(&JMAPResponse{
+		MethodResponses: response.MethodResponses[:min(1, len(response.MethodResponses))],
+		MethodErrors:    response.MethodErrors,
+	}); err != nil {
+		return nil, err
+	}
+	fc.stats.recordChanges(response)
+
+	created, err := fc.parseCreatedAlias(response, id)
+	if err != nil {
+		return nil, err
+	}
+	for aliasID := range update {
+		if err := fc.parseUpdatedAlias(response, aliasID); err != nil {
+			return nil, fmt.Errorf("created %s, but failed to update %s: %w", created.Email, aliasID, err)
+		}
+	}
+
+	// Prefer the alias as read back, which includes properties the create
+	// response omits; fall back to the create response if the read failed
+	var stored struct {
+		List []MaskedEmailInfo `json:"list"`
+	}
+	if fc.methodResult(response, 1, &stored) == nil && len(stored.List) == 1 {
+		return &stored.List[0], nil
+	}
+	return created, nil
+}
+
+// creationID derives a JMAP creation ID from the domain and the time window
+// the request was made in, so that resubmissions of the same create share an ID.
+func creationID(domain string, now time.Time) string {
+	window := now.Truncate(creationWindow).Unix()
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%d", domain, window)))
+	return "mf-" + hex.EncodeToString(sum[:8])
+}
+
+// findAliasCreatedSince returns an alias for the domain created at or after
+// the given time, or nil if there is none.
+func (fc *FastmailClient) findAliasCreatedSince(domain string, since time.Time) (*MaskedEmailInfo, error) {
+	aliases, err := fc.GetAliases(domain)
+	if err != nil {
+		return nil, err
+	}
+
+	threshold := since.Add(-clockSkewTolerance)
+	for i := range aliases {
+		if !aliases[i].CreatedAt.Before(threshold) {
+			return &aliases[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// GetAliasByEmail retrieves a specific alias by its email address.
+// Returns ErrAliasNotFound if the alias doesn't exist. Deleted aliases are
+// returned too: callers check the state, since enabling restores them.
+func (fc *FastmailClient) GetAliasByEmail(email string) (*MaskedEmailInfo, error) {
+	aliases, err := fc.FetchAllAliases()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get aliases: %w", err)
+	}
+
+	for _, alias := range aliases {
+		if alias.Email == email {
+			return &alias, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrAliasNotFound, email)
+}
+
+// UpdateAliasStatus changes the state of an existing alias.
+// Returns an error if the alias is already in the requested state or if the update fails.
+func (fc *FastmailClient) UpdateAliasStatus(alias *MaskedEmailInfo, state AliasState) error {
+	// Print current state for user feedback
+	fmt.Fprintf(humanOut, "Setting '%s' for '%s' to '%s'\n", alias.Email, alias.ForDomain, state)
+
+	if state == alias.State {
+		return fmt.Errorf("alias '%s' is already '%s'", alias.Email, state)
+	}
+
+	desiredState := state
+	update := map[string]MaskedEmailUpdate{
+		alias.ID: {
+			State: &desiredState,
+		},
+	}
+
+	response, err := fc.setMaskedEmail(nil, update)
+	if err != nil {
+		return fmt.Errorf("update request failed: %w", err)
+	}
+
+	if err := fc.parseUpdatedAlias(response, alias.ID); err != nil {
+		return err
+	}
+	fc.recordAudit(auditEntry{Action: auditUpdate, Alias: alias.Email, ID: alias.ID, Changes: updateChanges(update[alias.ID])})
+
+	fmt.Fprintln(humanOut, "Success")
+	return nil
+}
+
+// UpdateAliasDescription changes only the description field for an alias.
+func (fc *FastmailClient) UpdateAliasDescription(alias *MaskedEmailInfo, description string) error {
+	logf(levelActions, "setting the description of %s to %q", alias.Email, description)
+	desc := description
+	update := map[string]MaskedEmailUpdate{
+		alias.ID: {
+			Description: &desc,
+		},
+	}
+
+	response, err := fc.setMaskedEmail(nil, update)
+	if err != nil {
+		return fmt.Errorf("failed to update alias description: %w", err)
+	}
+
+	if err := fc.parseUpdatedAlias(response, alias.ID); err != nil {
+		return err
+	}
+	fc.recordAudit(auditEntry{Action: auditUpdate, Alias: alias.Email, ID: alias.ID, Changes: updateChanges(update[alias.ID])})
+	return nil
+}
+
+// aliasMatchesDomain reports whether the alias belongs to the domain under the
+// active domain strategy. Aliases without a domain are matched by description.
+func aliasMatchesDomain(alias MaskedEmailInfo, targetDomain string) bool {
+	if domainsMatch(alias.ForDomain, targetDomain) {
+		return true
+	}
+
+	if strings.TrimSpace(alias.ForDomain) == "" {
+		return domainsMatch(alias.Description, targetDomain)
+	}
+
+	return false
+}
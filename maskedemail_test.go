@@ -0,0 +1,57 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAliasMatchesDomain(t *testing.T) {
+	target := "https://example.com"
+
+	if !aliasMatchesDomain(MaskedEmailInfo{
+		ForDomain: target,
+	}, target) {
+		t.Fatalf("expected direct forDomain match")
+	}
+
+	if aliasMatchesDomain(MaskedEmailInfo{
+		ForDomain: "https://other.com",
+	}, target) {
+		t.Fatalf("did not expect different domain to match")
+	}
+
+	if !aliasMatchesDomain(MaskedEmailInfo{
+		ForDomain:   "",
+		Description: "https://example.com",
+	}, target) {
+		t.Fatalf("expected description fallback to match")
+	}
+
+	if aliasMatchesDomain(MaskedEmailInfo{
+		ForDomain:   "",
+		Description: "https://other.com",
+	}, target) {
+		t.Fatalf("description fallback should not match different domains")
+	}
+
+	if !aliasMatchesDomain(MaskedEmailInfo{
+		ForDomain: "https://Example.com/signup",
+	}, target) {
+		t.Fatalf("expected ForDomain to match (casing and trailing slash should be ignored)")
+	}
+}
+
+func TestCreationID(t *testing.T) {
+	base := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	first := creationID("https://example.com", base)
+	if first != creationID("https://example.com", base.Add(time.Minute)) {
+		t.Fatalf("expected creation ID to be stable within the creation window")
+	}
+	if first == creationID("https://other.com", base) {
+		t.Fatalf("expected different domains to get different creation IDs")
+	}
+	if first == creationID("https://example.com", base.Add(creationWindow)) {
+		t.Fatalf("expected creation ID to change in the next window")
+	}
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// mergeHistoryFile records the merges made with the merge command
+const mergeHistoryFile = "merges.json"
+
+// mergeRecord is a merge in the merge history.
+type mergeRecord struct {
+	Kept        string     `json:"kept"`
+	Merged      []string   `json:"merged"`
+	Description string     `json:"description,omitempty"`
+	State       AliasState `json:"state"`
+	At          time.Time  `json:"at"`
+}
+
+// mergePlan is the change that merges duplicates into the kept alias.
+type mergePlan struct {
+	Keep       MaskedEmailInfo
+	Duplicates []MaskedEmailInfo
+	// Description is the description the kept alias ends up with
+	Description string
+	// State is the state the duplicates are set to
+	State AliasState
+}
+
+// newMergeCmd creates the command that folds duplicate aliases into one.
+func newMergeCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "merge <keep-alias> <duplicate-alias>...",
+		Short: "Keep one alias and disable or delete its duplicates",
+		Long: `Merge duplicate aliases of a site into one: the kept alias gets the best
+description of the group, and the duplicates are disabled, or deleted with
+--delete, in a single request. The best description is the one configured for
+the domain, else the kept alias's own, else that of the duplicate that
+received mail most recently. Merges are recorded in the data directory.`,
+		Example: `  masked_fastmail merge shop.1234@fastmail.com shop.5678@fastmail.com
+  masked_fastmail merge --delete shop.1234@fastmail.com www.9012@fastmail.com`,
+		Args: cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			del, _ := cmd.Flags().GetBool("delete")
+			force, _ := cmd.Flags().GetBool("force")
+			client, err := newClientFromCmd(cmd)
+			if err != nil {
+				return err
+			}
+			state := AliasDisabled
+			if del {
+				state = AliasDeleted
+			}
+			return handleMerge(client, args[0], args[1:], state, force)
+		},
+	}
+	cmd.Flags().Bool("delete", false, "delete the duplicates instead of disabling them")
+	cmd.Flags().Bool("force", false, "with --delete, also delete protected duplicates")
+	return cmd
+}
+
+// handleMerge merges the duplicates into the kept alias.
+func handleMerge(client *FastmailClient, keep string, duplicates []string, state AliasState, force bool) error {
+	aliases, err := client.FetchAllAliases()
+	if err != nil {
+		return formatAPIError("failed to get aliases", err)
+	}
+	plan, err := planMerge(aliases, keep, duplicates, state)
+	if err != nil {
+		return err
+	}
+	if state == AliasDeleted {
+		for _, dup := range plan.Duplicates {
+			if err := checkDeletable(dup, force); err != nil {
+				return err
+			}
+		}
+	}
+
+	if porcelain == "" {
+		printMergePlan(plan)
+	}
+	question := fmt.Sprintf("Merge %s into %s?", plural(len(plan.Duplicates), "duplicate", "duplicates"), plan.Keep.Email)
+	if err := confirmBulk("merge", len(plan.Duplicates)+1, question); err != nil {
+		return err
+	}
+
+	updates := make(map[string]MaskedEmailUpdate, len(plan.Duplicates)+1)
+	if plan.Description != plan.Keep.Description {
+		description := plan.Description
+		updates[plan.Keep.ID] = MaskedEmailUpdate{Description: &description}
+	}
+	for _, dup := range plan.Duplicates {
+		dupState := plan.State
+		updates[dup.ID] = MaskedEmailUpdate{State: &dupState}
+	}
+	ctx, stop := withInterrupt()
+	defer stop()
+	result, err := client.UpdateAliases(ctx, updates, nil)
+	if err != nil && !errors.Is(err, ErrInterrupted) {
+		return formatAPIError("failed to merge aliases", err)
+	}
+
+	var changed []MaskedEmailInfo
+	for _, dup := range plan.Duplicates {
+		if mergeOutcome(dup.ID, result) == "updated" {
+			changed = append(changed, dup)
+		}
+	}
+	recordStateChanges(changed, plan.State, time.Now())
+	if len(result.Failed) == 0 && err == nil {
+		recordMerge(plan, time.Now())
+	}
+
+	if porcelain != "" {
+		// email, role (kept|merged), state, outcome, reason
+		keepOutcome := "unchanged"
+		if plan.Description != plan.Keep.Description {
+			keepOutcome = mergeOutcome(plan.Keep.ID, result)
+		}
+		printPorcelain(plan.Keep.Email, "kept", string(plan.Keep.State), keepOutcome, failureReason(plan.Keep.ID, result))
+		for _, dup := range plan.Duplicates {
+			printPorcelain(dup.Email, "merged", string(plan.State), mergeOutcome(dup.ID, result), failureReason(dup.ID, result))
+		}
+	} else {
+		for id, setErr := range result.Failed {
+			fmt.Fprintf(os.Stderr, "Failed to update %s: %s\n", aliasEmailByID(plan, id), setErr)
+		}
+		if len(result.Failed) == 0 && err == nil {
+			fmt.Printf("Merged %s into %s\n", plural(len(plan.Duplicates), "alias", "aliases"), plan.Keep.Email)
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+	if len(result.Failed) > 0 {
+		return fmt.Errorf("%d of %d aliases could not be updated", len(result.Failed), len(updates))
+	}
+	return nil
+}
+
+// planMerge looks up the aliases of a merge and works out the change. The
+// kept alias must not be deleted, and every duplicate must be a different
+// alias.
+func planMerge(aliases []MaskedEmailInfo, keep string, duplicates []string, state AliasState) (mergePlan, error) {
+	byEmail := make(map[string]MaskedEmailInfo, len(aliases))
+	for _, alias := range aliases {
+		byEmail[alias.Email] = alias
+	}
+	lookup := func(identifier string) (MaskedEmailInfo, error) {
+		email, err := normalizeEmailInput(identifier)
+		if err != nil {
+			return MaskedEmailInfo{}, err
+		}
+		alias, ok := byEmail[email]
+		if !ok {
+			return MaskedEmailInfo{}, fmt.Errorf("%w: %s", ErrAliasNotFound, email)
+		}
+		return alias, nil
+	}
+
+	plan := mergePlan{State: state}
+	var err error
+	if plan.Keep, err = lookup(keep); err != nil {
+		return mergePlan{}, err
+	}
+	if plan.Keep.State == AliasDeleted {
+		return mergePlan{}, fmt.Errorf("%s is deleted; enable it first or keep another alias", plan.Keep.Email)
+	}
+	seen := map[string]bool{plan.Keep.Email: true}
+	for _, identifier := range duplicates {
+		dup, err := lookup(identifier)
+		if err != nil {
+			return mergePlan{}, err
+		}
+		if seen[dup.Email] {
+			return mergePlan{}, fmt.Errorf("%s is given more than once", dup.Email)
+		}
+		seen[dup.Email] = true
+		plan.Duplicates = append(plan.Duplicates, dup)
+	}
+	plan.Description = bestDescription(plan.Keep, plan.Duplicates)
+	return plan, nil
+}
+
+// bestDescription returns the description the kept alias should have: the
+// canonical one of its domain, its own, or that of the duplicate that
+// received mail most recently.
+func bestDescription(keep MaskedEmailInfo, duplicates []MaskedEmailInfo) string {
+	if canonical, ok := canonicalDescription(keep.ForDomain); ok {
+		return canonical
+	}
+	if keep.Description != "" {
+		return keep.Description
+	}
+	best := ""
+	var bestAt *time.Time
+	for _, dup := range duplicates {
+		if dup.Description == "" {
+			continue
+		}
+		if best == "" || (dup.LastMessageAt != nil && (bestAt == nil || dup.LastMessageAt.After(*bestAt))) {
+			best, bestAt = dup.Description, dup.LastMessageAt
+		}
+	}
+	return best
+}
+
+// printMergePlan prints the changes of a merge in the format of restore.
+func printMergePlan(plan mergePlan) {
+	if plan.Description != plan.Keep.Description {
+		fmt.Printf("~ %s description: %s -> %s\n", plan.Keep.Email, strconv.Quote(sanitizeText(plan.Keep.Description)), strconv.Quote(sanitizeText(plan.Description)))
+	} else {
+		fmt.Printf("= %s is kept\n", plan.Keep.Email)
+	}
+	for _, dup := range plan.Duplicates {
+		fmt.Printf("~ %s state: %s -> %s\n", dup.Email, dup.State, plan.State)
+	}
+}
+
+// mergeOutcome returns updated, failed or pending (not sent because the run
+// stopped) for an alias changed by a merge.
+func mergeOutcome(id string, result *BatchResult) string {
+	if _, ok := result.Failed[id]; ok {
+		return "failed"
+	}
+	for _, updated := range result.Updated {
+		if updated == id {
+			return "updated"
+		}
+	}
+	return "pending"
+}
+
+// failureReason returns why the server rejected an update, if it did.
+func failureReason(id string, result *BatchResult) string {
+	if setErr, ok := result.Failed[id]; ok {
+		return setErr.String()
+	}
+	return ""
+}
+
+// aliasEmailByID returns the email of an alias of the merge.
+func aliasEmailByID(plan mergePlan, id string) string {
+	for _, alias := range append([]MaskedEmailInfo{plan.Keep}, plan.Duplicates...) {
+		if alias.ID == id {
+			return alias.Email
+		}
+	}
+	return id
+}
+
+// recordMerge appends the merge to the merge history. A failure only prints
+// a warning, since the aliases were already changed.
+func recordMerge(plan mergePlan, now time.Time) {
+	record := mergeRecord{Kept: plan.Keep.Email, Description: plan.Description, State: plan.State, At: now.UTC()}
+	for _, dup := range plan.Duplicates {
+		record.Merged = append(record.Merged, dup.Email)
+	}
+	var history []mergeRecord
+	err := updateJSONFile(mergeHistoryFile, &history, func() error {
+		history = append(history, record)
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not record the merge: %v\n", err)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPlanMerge(t *testing.T) {
+	aliases := []MaskedEmailInfo{
+		{ID: "a1", Email: "keep@fastmail.com", ForDomain: "https://example.com", State: AliasEnabled},
+		{ID: "a2", Email: "dup@fastmail.com", ForDomain: "https://www.example.com", State: AliasEnabled},
+		{ID: "a3", Email: "gone@fastmail.com", State: AliasDeleted},
+	}
+
+	plan, err := planMerge(aliases, "keep@fastmail.com", []string{"dup@fastmail.com"}, AliasDisabled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Keep.ID != "a1" || len(plan.Duplicates) != 1 || plan.Duplicates[0].ID != "a2" {
+		t.Errorf("unexpected plan %+v", plan)
+	}
+
+	failures := map[string][]string{
+		"unknown alias":      {"keep@fastmail.com", "missing@fastmail.com"},
+		"repeated alias":     {"keep@fastmail.com", "dup@fastmail.com", "dup@fastmail.com"},
+		"kept as duplicate":  {"keep@fastmail.com", "keep@fastmail.com"},
+		"deleted kept alias": {"gone@fastmail.com", "dup@fastmail.com"},
+	}
+	for name, args := range failures {
+		if _, err := planMerge(aliases, args[0], args[1:], AliasDisabled); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestBestDescription(t *testing.T) {
+	older := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer := older.AddDate(0, 1, 0)
+	dups := []MaskedEmailInfo{
+		{Description: "old shop", LastMessageAt: &older},
+		{Description: ""},
+		{Description: "Example shop", LastMessageAt: &newer},
+	}
+
+	if got := bestDescription(MaskedEmailInfo{Description: "mine"}, dups); got != "mine" {
+		t.Errorf("the kept alias's own description should win, got %q", got)
+	}
+	if got := bestDescription(MaskedEmailInfo{}, dups); got != "Example shop" {
+		t.Errorf("the most recently used description should win, got %q", got)
+	}
+
+	domainDescriptions = map[string]string{"example.com": "Example"}
+	defer func() { domainDescriptions = nil }()
+	if got := bestDescription(MaskedEmailInfo{ForDomain: "https://example.com", Description: "mine"}, dups); got != "Example" {
+		t.Errorf("the canonical description should win, got %q", got)
+	}
+}
+
+func TestHandleMerge(t *testing.T) {
+	fake, client := newFakeJMAP(t,
+		MaskedEmailInfo{ID: "a1", Email: "keep@fastmail.com", ForDomain: "https://example.com", State: AliasEnabled},
+		MaskedEmailInfo{ID: "a2", Email: "dup@fastmail.com", ForDomain: "https://www.example.com", Description: "Example", State: AliasEnabled},
+	)
+
+	// Without a terminal, only --yes approves the merge
+	if err := handleMerge(client, "keep@fastmail.com", []string{"dup@fastmail.com"}, AliasDisabled, false); !errors.Is(err, ErrNotConfirmed) {
+		t.Fatalf("handleMerge() = %v; want ErrNotConfirmed without --yes", err)
+	}
+	if fake.aliases["a2"].State != AliasEnabled {
+		t.Fatalf("the duplicate was changed without confirmation")
+	}
+	fake.requests = 0
+	assumeYes = true
+	defer func() { assumeYes = false }()
+	out := captureStdout(t, func() {
+		if err := handleMerge(client, "keep@fastmail.com", []string{"dup@fastmail.com"}, AliasDisabled, false); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if got := fake.aliases["a1"].Description; got != "Example" {
+		t.Errorf("kept alias description = %q, want the duplicate's", got)
+	}
+	if got := fake.aliases["a2"].State; got != AliasDisabled {
+		t.Errorf("duplicate state = %s, want disabled", got)
+	}
+	if !strings.Contains(out, "Merged 1 alias into keep@fastmail.com") {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+	if fake.requests != 2 {
+		t.Errorf("got %d requests, want a fetch and a single update", fake.requests)
+	}
+
+	var history []mergeRecord
+	if err := readJSONFile(mergeHistoryFile, &history); err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 1 || history[0].Kept != "keep@fastmail.com" || len(history[0].Merged) != 1 || history[0].Merged[0] != "dup@fastmail.com" ||
+		history[0].Description != "Example" || history[0].State != AliasDisabled {
+		t.Errorf("merge history = %+v; want only the merge that went ahead", history)
+	}
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// Porcelain output versions. The format of a version never changes once
+// released; incompatible changes get a new version.
+const porcelainV1 = "v1"
+
+var porcelainVersions = []string{porcelainV1}
+
+var (
+	// porcelain is the porcelain output version, or "" for human output. It
+	// is set with --porcelain[=version], and defaults to the latest version
+	// when stdout is not a terminal.
+	porcelain string
+	// humanOut receives progress messages and other human-oriented chrome.
+	// In porcelain mode it is stderr so that stdout only carries results.
+	humanOut io.Writer = os.Stdout
+)
+
+// Result formats selected with --output
+const (
+	outputText     = "text"
+	outputNDJSON   = "ndjson"
+	outputURI      = "uri"
+	outputAutofill = "autofill"
+)
+
+var outputFormats = []string{outputText, outputNDJSON, outputURI, outputAutofill}
+
+// uriScheme is the scheme of the URIs printed with --output uri, which
+// mobile automation apps and companion apps can open or parse
+const uriScheme = "maskedfastmail"
+
+// autofillPasswordPlaceholder is the password of --output autofill. Passwords
+// are not managed here, so the autofill tool puts its own in its place.
+const autofillPasswordPlaceholder = "{{password}}"
+
+// autofillEntry is what --output autofill prints for snippet tools such as
+// Espanso or AutoHotkey to fill in a signup form.
+type autofillEntry struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+	// Domain is the host of the site of the alias, or "" if it has none
+	Domain string `json:"domain"`
+}
+
+// outputFormat is the format of results selected with --output. Text is the
+// human or porcelain output; other formats are only supported by the
+// commands that list them in their outputAnnotation.
+var outputFormat = outputText
+
+// outputAnnotation is the command annotation listing the --output formats
+// the command supports besides text, separated by commas
+const outputAnnotation = "outputs"
+
+// setOutput selects the result format for a command. Formats other than text
+// move human-oriented messages to stderr, like porcelain mode.
+func setOutput(cmd *cobra.Command, format string) error {
+	format = strings.ToLower(strings.TrimSpace(format))
+	known := false
+	for _, f := range outputFormats {
+		known = known || f == format
+	}
+	if !known {
+		return fmt.Errorf("unknown output format %q (expected one of: %s)", format, strings.Join(outputFormats, ", "))
+	}
+
+	outputFormat = format
+	if format == outputText {
+		return nil
+	}
+	humanOut = os.Stderr
+	for _, supported := range strings.Split(cmd.Annotations[outputAnnotation], ",") {
+		if supported == format {
+			return nil
+		}
+	}
+	return fmt.Errorf("--output %s is not supported by %s", format, cmd.CommandPath())
+}
+
+// printNDJSON writes v to stdout as one line of JSON.
+func printNDJSON(v interface{}) error {
+	return json.NewEncoder(os.Stdout).Encode(v)
+}
+
+// Escaping modes of --escape
+const (
+	escapeNone  = "none"
+	escapeShell = "shell"
+)
+
+var escapeModes = []string{escapeNone, escapeShell}
+
+// escapeMode is how porcelain fields are escaped for the program reading
+// them; set with --escape.
+var escapeMode = escapeNone
+
+// setEscape selects the escaping of porcelain fields. Shell escaping implies
+// porcelain output, since human output is not meant to be parsed.
+func setEscape(mode string) error {
+	mode = strings.ToLower(strings.TrimSpace(mode))
+	switch mode {
+	case escapeNone:
+	case escapeShell:
+		if outputFormat != outputText {
+			return fmt.Errorf("--escape %s cannot be combined with --output %s", mode, outputFormat)
+		}
+		if porcelain == "" {
+			if err := setPorcelain(porcelainV1); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unknown escaping %q (expected one of: %s)", mode, strings.Join(escapeModes, ", "))
+	}
+	escapeMode = mode
+	return nil
+}
+
+// setPorcelain switches between human output ("") and a porcelain version.
+func setPorcelain(version string) error {
+	if version != "" && !isPorcelainVersion(version) {
+		return fmt.Errorf("unknown porcelain version %q (expected one of: %s)", version, strings.Join(porcelainVersions, ", "))
+	}
+
+	porcelain = version
+	if version != "" {
+		humanOut = os.Stderr
+	} else {
+		humanOut = os.Stdout
+	}
+	return nil
+}
+
+func isPorcelainVersion(version string) bool {
+	for _, known := range porcelainVersions {
+		if version == known {
+			return true
+		}
+	}
+	return false
+}
+
+// printPorcelain writes one porcelain record to stdout: the fields in a fixed
+// order, separated by tabs and terminated by a newline. Fields are escaped
+// with escapePorcelain so that a record always occupies exactly one line.
+// With --escape shell, the fields are also quoted for the shell and
+// separated by spaces.
+func printPorcelain(fields ...string) {
+	escaped := make([]string, len(fields))
+	for i, field := range fields {
+		escaped[i] = escapePorcelain(field)
+	}
+	if escapeMode == escapeShell {
+		for i, field := range escaped {
+			escaped[i] = shellQuote(field)
+		}
+		fmt.Println(strings.Join(escaped, " "))
+		return
+	}
+	fmt.Println(strings.Join(escaped, "\t"))
+}
+
+// shellQuote quotes a string as a single word for POSIX shells, so that
+// quotes, $ and backticks in it are taken literally by eval. Words of only
+// safe characters are left unquoted.
+func shellQuote(s string) string {
+	if s != "" && strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("@%+=:,./_-", r))
+	}) < 0 {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// escapePorcelain escapes backslashes, tabs, newlines and other control
+// characters using C-style escape sequences.
+func escapePorcelain(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '\\':
+			b.WriteString(`\\`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&b, `\x%02x`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// choose returns a if cond is true and b otherwise.
+func choose(cond bool, a, b string) string {
+	if cond {
+		return a
+	}
+	return b
+}
+
+// printAndCopyAlias prints the alias and copies it to the clipboard. In
+// porcelain mode only the email is printed and the clipboard is left alone,
+// since the output is consumed by another program. With --output uri or
+// autofill, the alias is printed as a URI or a JSON object and still copied,
+// in porcelain mode too. created tells whether the alias was just created.
+func printAndCopyAlias(alias *MaskedEmailInfo, created bool) {
+	// An --output format asked for wins over porcelain, which is turned on
+	// whenever stdout is not a terminal, e.g. in a pipe to xargs open
+	if outputFormat == outputURI || outputFormat == outputAutofill {
+		if outputFormat == outputURI {
+			fmt.Println(aliasURI(*alias, created))
+		} else if err := printNDJSON(aliasAutofill(*alias)); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Could not print the alias: %v\n", err)
+		}
+		copyAlias(alias.Email)
+		notifyAlias(alias)
+		return
+	}
+	if porcelain != "" {
+		printPorcelain(alias.Email)
+		return
+	}
+
+	fmt.Printf("%s (state: %s)", alias.Email, alias.State)
+	if copyAlias(alias.Email) {
+		fmt.Println(" (copied to clipboard)")
+	} else {
+		fmt.Println()
+	}
+	notifyAlias(alias)
+}
+
+// copyAlias copies the email to the clipboard and reports whether it did.
+// Without a clipboard, e.g. over SSH, a note tells why instead of a failed
+// attempt.
+func copyAlias(email string) bool {
+	if clip := detectClipboard(currentPlatform()); !clip.Available {
+		fmt.Fprintf(os.Stderr, "Note: not copied, the clipboard is not available: %s (see masked_fastmail doctor)\n", clip.Detail)
+		return false
+	}
+	if err := copyToClipboard(email); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Could not copy to clipboard: %v\n", err)
+		return false
+	}
+	return true
+}
+
+// aliasURI returns the alias as a URI such as
+// maskedfastmail://created?email=user.1234%40fastmail.com&state=pending for an
+// alias just created, or maskedfastmail://alias?... for an existing one.
+func aliasURI(alias MaskedEmailInfo, created bool) string {
+	query := url.Values{}
+	query.Set("email", alias.Email)
+	query.Set("state", string(alias.State))
+	if alias.ID != "" {
+		query.Set("id", alias.ID)
+	}
+	if alias.ForDomain != "" {
+		query.Set("domain", alias.ForDomain)
+	}
+	return (&url.URL{Scheme: uriScheme, Host: choose(created, "created", "alias"), RawQuery: query.Encode()}).String()
+}
+
+// aliasAutofill returns the alias as --output autofill prints it.
+func aliasAutofill(alias MaskedEmailInfo) autofillEntry {
+	entry := autofillEntry{Email: alias.Email, Password: autofillPasswordPlaceholder}
+	if alias.ForDomain != "" {
+		entry.Domain = hostFromOrigin(alias.ForDomain)
+	}
+	return entry
+}
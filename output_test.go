@@ -0,0 +1,205 @@
+package main
+
+import (
+	"io"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// captureStdout returns what fn writes to stdout.
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("failed to create pipe: %v", err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	fn()
+	w.Close()
+
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("failed to read output: %v", err)
+	}
+	return string(out)
+}
+
+func TestPorcelainPrintsOnlyEmail(t *testing.T) {
+	if err := setPorcelain(porcelainV1); err != nil {
+		t.Fatalf("setPorcelain returned error: %v", err)
+	}
+	defer setPorcelain("")
+
+	if humanOut != os.Stderr {
+		t.Fatalf("expected human chrome to go to stderr in porcelain mode")
+	}
+
+	out := captureStdout(t, func() {
+		printAndCopyAlias(&MaskedEmailInfo{Email: "user.1234@fastmail.com", State: AliasEnabled}, false)
+	})
+	if out != "user.1234@fastmail.com\n" {
+		t.Fatalf("expected only the email, got %q", out)
+	}
+}
+
+func TestWaitForMailInPorcelain(t *testing.T) {
+	if err := setPorcelain(porcelainV1); err != nil {
+		t.Fatal(err)
+	}
+	defer setPorcelain("")
+
+	received := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	alias := &MaskedEmailInfo{ID: "a1", Email: "user.1234@fastmail.com", State: AliasEnabled, LastMessageAt: &received}
+	out := captureStdout(t, func() {
+		printAndCopyAlias(alias, false)
+		if err := waitForFirstMessage(nil, alias, time.Minute); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if out != "user.1234@fastmail.com\n" {
+		t.Fatalf("expected the progress of --wait-for-mail on stderr, got %q", out)
+	}
+}
+
+func TestOutputURIInPipe(t *testing.T) {
+	_, client := newFakeJMAP(t, MaskedEmailInfo{ID: "a1", Email: "shop.1@fastmail.com", ForDomain: "https://example.com", State: AliasEnabled})
+	// Porcelain is turned on when stdout is not a terminal
+	if err := setPorcelain(porcelainV1); err != nil {
+		t.Fatal(err)
+	}
+	outputFormat = outputURI
+	defer func() { setPorcelain(""); outputFormat = outputText }()
+
+	out := captureStdout(t, func() {
+		if _, err := handleAliasLookupOrCreation(client, "example.com", nil, false, false); err != nil {
+			t.Fatal(err)
+		}
+	})
+	want := "maskedfastmail://alias?domain=https%3A%2F%2Fexample.com&email=shop.1%40fastmail.com&id=a1&state=enabled\n"
+	if out != want {
+		t.Errorf("output = %q, want the URI %q", out, want)
+	}
+}
+
+func TestOutputAutofillWithoutTerminal(t *testing.T) {
+	_, client := newFakeJMAP(t, MaskedEmailInfo{ID: "a1", Email: "shop.1@fastmail.com", ForDomain: "https://example.com", State: AliasEnabled})
+	outputFormat = outputAutofill
+	defer func() { setPorcelain(""); outputFormat = outputText }()
+
+	out := captureStdout(t, func() {
+		// As PersistentPreRunE does when a password manager reads stdout
+		if isTerminal(os.Stdout) {
+			t.Fatal("stdout is a terminal while captured")
+		}
+		if err := setPorcelain(porcelainV1); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := handleAliasLookupOrCreation(client, "example.com", nil, false, false); err != nil {
+			t.Fatal(err)
+		}
+	})
+	want := `{"email":"shop.1@fastmail.com","password":"` + autofillPasswordPlaceholder + `","domain":"example.com"}` + "\n"
+	if out != want {
+		t.Errorf("output = %q, want the autofill JSON %q", out, want)
+	}
+}
+
+func TestSetPorcelainRejectsUnknownVersion(t *testing.T) {
+	if err := setPorcelain("v9"); err == nil {
+		t.Fatalf("expected an error for an unknown porcelain version")
+	}
+	if porcelain != "" {
+		t.Fatalf("expected output mode to be unchanged, got %q", porcelain)
+	}
+}
+
+func TestSetOutput(t *testing.T) {
+	defer setPorcelain("")
+	defer func() { outputFormat = outputText }()
+
+	export := &cobra.Command{Use: "export", Annotations: map[string]string{outputAnnotation: outputNDJSON}}
+	if err := setOutput(export, "NDJSON"); err != nil {
+		t.Fatalf("setOutput returned error: %v", err)
+	}
+	if outputFormat != outputNDJSON || humanOut != os.Stderr {
+		t.Fatalf("expected ndjson output with messages on stderr")
+	}
+
+	if err := setOutput(&cobra.Command{Use: "limits"}, outputNDJSON); err == nil {
+		t.Fatalf("expected an error for a command without ndjson output")
+	}
+	if err := setOutput(export, "yaml"); err == nil {
+		t.Fatalf("expected an error for an unknown output format")
+	}
+}
+
+func TestPrintPorcelainEscapesFields(t *testing.T) {
+	out := captureStdout(t, func() {
+		printPorcelain("user@fastmail.com", "line one\nline two", "tab\there", `back\slash`, "", "bell\a")
+	})
+
+	expected := "user@fastmail.com\tline one\\nline two\ttab\\there\tback\\\\slash\t\tbell\\x07\n"
+	if out != expected {
+		t.Fatalf("printPorcelain() wrote %q, want %q", out, expected)
+	}
+}
+
+func TestEscapeShell(t *testing.T) {
+	defer setPorcelain("")
+	defer func() { escapeMode = escapeNone }()
+
+	if err := setEscape(escapeShell); err != nil {
+		t.Fatalf("setEscape returned error: %v", err)
+	}
+	if porcelain != porcelainV1 {
+		t.Fatalf("expected --escape shell to imply porcelain output")
+	}
+
+	out := captureStdout(t, func() {
+		printPorcelain("user@fastmail.com", "it's $HOME `id`", "", "two\nlines")
+	})
+	expected := `user@fastmail.com 'it'\''s $HOME ` + "`id`" + `' '' 'two\nlines'` + "\n"
+	if out != expected {
+		t.Fatalf("printPorcelain() wrote %q, want %q", out, expected)
+	}
+
+	if err := setEscape("json"); err == nil {
+		t.Fatalf("expected an error for an unknown escaping")
+	}
+}
+
+func TestAliasURI(t *testing.T) {
+	alias := MaskedEmailInfo{ID: "me-1", Email: "user.1234@fastmail.com", State: AliasPending, ForDomain: "https://example.com"}
+	got := aliasURI(alias, true)
+	want := "maskedfastmail://created?domain=https%3A%2F%2Fexample.com&email=user.1234%40fastmail.com&id=me-1&state=pending"
+	if got != want {
+		t.Errorf("aliasURI() = %q, want %q", got, want)
+	}
+
+	parsed, err := url.Parse(aliasURI(MaskedEmailInfo{Email: "a+b@fastmail.com", State: AliasEnabled}, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Host != "alias" || parsed.Query().Get("email") != "a+b@fastmail.com" || parsed.Query().Has("domain") {
+		t.Errorf("unexpected URI for an existing alias: %s", parsed)
+	}
+}
+
+func TestAliasAutofill(t *testing.T) {
+	got := aliasAutofill(MaskedEmailInfo{Email: "user.1234@fastmail.com", State: AliasEnabled, ForDomain: "https://shop.example.com"})
+	want := autofillEntry{Email: "user.1234@fastmail.com", Password: autofillPasswordPlaceholder, Domain: "shop.example.com"}
+	if got != want {
+		t.Errorf("aliasAutofill() = %+v, want %+v", got, want)
+	}
+	if got := aliasAutofill(MaskedEmailInfo{Email: "a@fastmail.com"}); got.Domain != "" {
+		t.Errorf("expected no domain for an alias without a site, got %q", got.Domain)
+	}
+}
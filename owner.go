@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strings"
+)
+
+// currentOwner returns the local user name and host name recorded for the
+// aliases created on this machine. Either may be empty if it is unknown.
+func currentOwner() (owner, host string) {
+	if u, err := user.Current(); err == nil {
+		owner = u.Username
+		// Windows user names include the domain, e.g. DESKTOP\alice
+		if i := strings.LastIndex(owner, `\`); i >= 0 {
+			owner = owner[i+1:]
+		}
+	}
+	if owner == "" {
+		owner = os.Getenv("USER")
+	}
+	host, _ = os.Hostname()
+	return owner, host
+}
+
+// recordOwnership stores who created the alias and on which machine in the
+// local metadata, so that aliases can be told apart on a shared account. A
+// failure only prints a warning, since the alias itself was created.
+func recordOwnership(email string) {
+	owner, host := currentOwner()
+	if owner == "" && host == "" {
+		return
+	}
+	err := updateMetadata(email, func(entry *AliasMetadata) {
+		entry.Owner = owner
+		entry.CreatedOn = host
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not record the owner of %s: %v\n", email, err)
+	}
+}
+
+// ownerLabel describes who created an alias, e.g. "alice@laptop", or returns
+// an empty string if it is unknown.
+func ownerLabel(metadata AliasMetadata) string {
+	switch {
+	case metadata.Owner != "" && metadata.CreatedOn != "":
+		return metadata.Owner + "@" + metadata.CreatedOn
+	case metadata.Owner != "":
+		return metadata.Owner
+	case metadata.CreatedOn != "":
+		return "@" + metadata.CreatedOn
+	}
+	return ""
+}
+
+// ownerMatches reports whether an alias's ownership matches an --owner
+// filter: a user ("alice"), a machine ("@laptop") or both ("alice@laptop").
+// "me" stands for the current user.
+func ownerMatches(metadata AliasMetadata, filter string) bool {
+	filter = strings.TrimSpace(filter)
+	if filter == "me" {
+		filter, _ = currentOwner()
+	}
+	owner, host, hasHost := strings.Cut(filter, "@")
+	if owner != "" && !strings.EqualFold(owner, metadata.Owner) {
+		return false
+	}
+	if hasHost && !strings.EqualFold(host, metadata.CreatedOn) {
+		return false
+	}
+	return owner != "" || hasHost
+}
@@ -0,0 +1,54 @@
+package main
+
+import "testing"
+
+func TestOwnerLabel(t *testing.T) {
+	cases := map[AliasMetadata]string{
+		{Owner: "alice", CreatedOn: "laptop"}: "alice@laptop",
+		{Owner: "alice"}:                      "alice",
+		{CreatedOn: "server"}:                 "@server",
+		{}:                                    "",
+	}
+	for metadata, want := range cases {
+		if got := ownerLabel(metadata); got != want {
+			t.Errorf("ownerLabel(%+v) = %q, want %q", metadata, got, want)
+		}
+	}
+}
+
+func TestOwnerMatches(t *testing.T) {
+	metadata := AliasMetadata{Owner: "alice", CreatedOn: "laptop"}
+
+	for filter, want := range map[string]bool{
+		"alice":        true,
+		"Alice":        true,
+		"@laptop":      true,
+		"alice@laptop": true,
+		"alice@server": false,
+		"bob":          false,
+		"@server":      false,
+		"":             false,
+	} {
+		if got := ownerMatches(metadata, filter); got != want {
+			t.Errorf("ownerMatches(%q) = %v, want %v", filter, got, want)
+		}
+	}
+
+	if ownerMatches(AliasMetadata{}, "alice") {
+		t.Fatalf("did not expect an alias without an owner to match")
+	}
+}
+
+func TestRecordOwnership(t *testing.T) {
+	t.Setenv(dataDirEnv, t.TempDir())
+
+	recordOwnership("new@fastmail.com")
+	metadata, err := loadMetadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+	owner, host := currentOwner()
+	if got := metadata["new@fastmail.com"]; got.Owner != owner || got.CreatedOn != host {
+		t.Fatalf("expected %s@%s to be recorded, got %+v", owner, host, got)
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// parsedSite is a site found by parse, with the alias it already has.
+type parsedSite struct {
+	Domain string
+	// Alias is the alias the site would get, or nil if it has none
+	Alias *MaskedEmailInfo
+	// Created is set when parse created the alias
+	Created bool
+}
+
+// newParseCmd creates the command that finds sites in pasted text.
+func newParseCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "parse [file]",
+		Short: "Find the sites in pasted text and look up or create their aliases",
+		Long: `Read text such as a signup confirmation email or a terms of service page from
+a file, or from stdin, and find the sites it mentions: the hosts of URLs, bare
+domain names and the domains of email addresses. Each site is shown with the
+alias it already has. In a terminal, parse then asks whether to create an alias
+for each site without one; with --yes it creates them all.
+
+When the text is pasted at the terminal, end it with Ctrl-D.`,
+		Example: `  pbpaste | masked_fastmail parse
+  masked_fastmail parse signup-email.txt`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			text, err := readParseInput(args)
+			if err != nil {
+				return err
+			}
+			domains := extractDomains(text)
+			if len(domains) == 0 {
+				return fmt.Errorf("no domains found in the text")
+			}
+			client, err := newClientFromCmd(cmd)
+			if err != nil {
+				return err
+			}
+			return handleParse(client, domains)
+		},
+	}
+}
+
+// readParseInput reads the text from the file given as argument, or stdin.
+func readParseInput(args []string) (string, error) {
+	if len(args) == 1 {
+		data, err := os.ReadFile(args[0])
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s: %w", args[0], err)
+		}
+		return string(data), nil
+	}
+	if isTerminal(os.Stdin) {
+		if noInput {
+			return "", errNoInput
+		}
+		fmt.Fprintln(os.Stderr, "Paste the text, then press Ctrl-D:")
+	}
+	data, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return "", fmt.Errorf("failed to read the text: %w", err)
+	}
+	return string(data), nil
+}
+
+// handleParse looks up the aliases of the domains and offers to create the
+// missing ones.
+func handleParse(client *FastmailClient, domains []string) error {
+	sites, err := lookupParsedSites(client, domains)
+	if err != nil {
+		return err
+	}
+
+	if assumeYes || canPrompt() {
+		for i := range sites {
+			site := &sites[i]
+			if site.Alias != nil {
+				continue
+			}
+			if !assumeYes && !askYesNo(os.Stderr, os.Stdin, fmt.Sprintf("Create an alias for %s?", site.Domain)) {
+				continue
+			}
+			alias, err := client.CreateAliasWith(AliasCreation{Domain: site.Domain, Description: defaultDescription(site.Domain, nil)})
+			if err != nil {
+				printParsedSites(sites)
+				return formatAPIError(fmt.Sprintf("failed to create an alias for %s", site.Domain), err)
+			}
+			recordUsage(eventCreated)
+			recordOwnership(alias.Email)
+			site.Alias, site.Created = alias, true
+		}
+	}
+	printParsedSites(sites)
+	return nil
+}
+
+// lookupParsedSites normalizes the domains with the domain strategy and
+// finds the alias each already has. Domains that normalize to the same site
+// are kept once.
+func lookupParsedSites(client *FastmailClient, domains []string) ([]parsedSite, error) {
+	aliases, err := client.FetchAllAliases()
+	if err != nil {
+		return nil, formatAPIError("failed to get aliases", err)
+	}
+	var sites []parsedSite
+	seen := make(map[string]bool, len(domains))
+	for _, domain := range domains {
+		_, normalized, err := prepareDomainInput(domain)
+		if err != nil || seen[normalized] {
+			continue
+		}
+		seen[normalized] = true
+		var matching []MaskedEmailInfo
+		for _, alias := range aliases {
+			if alias.State != AliasDeleted && aliasMatchesDomain(alias, normalized) {
+				matching = append(matching, alias)
+			}
+		}
+		sites = append(sites, parsedSite{Domain: normalized, Alias: selectPreferredAlias(matching)})
+	}
+	return sites, nil
+}
+
+// printParsedSites prints each site with its alias.
+func printParsedSites(sites []parsedSite) {
+	if porcelain != "" {
+		// domain, email, state, outcome (existing, created or none)
+		for _, site := range sites {
+			if site.Alias == nil {
+				printPorcelain(site.Domain, "", "", "none")
+				continue
+			}
+			printPorcelain(site.Domain, site.Alias.Email, string(site.Alias.State), choose(site.Created, "created", "existing"))
+		}
+		return
+	}
+
+	width := 0
+	for _, site := range sites {
+		width = max(width, displayWidth(site.Domain))
+	}
+	for _, site := range sites {
+		switch {
+		case site.Alias == nil:
+			fmt.Printf("%s  no alias\n", padRight(site.Domain, width))
+		case site.Created:
+			fmt.Printf("%s  %s (created)\n", padRight(site.Domain, width), site.Alias.Email)
+		default:
+			fmt.Printf("%s  %s (%s)\n", padRight(site.Domain, width), site.Alias.Email, themedState(site.Alias.State))
+		}
+	}
+}
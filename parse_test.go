@@ -0,0 +1,46 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHandleParseListsAliases(t *testing.T) {
+	fake, client := newFakeJMAP(t,
+		MaskedEmailInfo{ID: "a1", Email: "shop.1@fastmail.com", ForDomain: "https://example.com", State: AliasEnabled},
+	)
+
+	out := captureStdout(t, func() {
+		if err := handleParse(client, []string{"example.com", "other.org", "EXAMPLE.com"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if !strings.Contains(out, "https://example.com  shop.1@fastmail.com (enabled)") {
+		t.Errorf("the existing alias isn't shown:\n%s", out)
+	}
+	if !strings.Contains(out, "https://other.org    no alias") {
+		t.Errorf("the site without an alias isn't shown:\n%s", out)
+	}
+	if strings.Count(out, "example.com") != 1 {
+		t.Errorf("example.com is shown more than once:\n%s", out)
+	}
+	// Without a terminal and without --yes, nothing is created
+	if fake.created != 0 {
+		t.Errorf("created %d aliases, want none", fake.created)
+	}
+}
+
+func TestHandleParseCreatesWithYes(t *testing.T) {
+	fake, client := newFakeJMAP(t)
+	assumeYes = true
+	defer func() { assumeYes = false }()
+
+	out := captureStdout(t, func() {
+		if err := handleParse(client, []string{"other.org"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if fake.created != 1 || !strings.Contains(out, "(created)") {
+		t.Errorf("expected one alias to be created, got %d:\n%s", fake.created, out)
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const pinPrefix = "sha256/"
+
+// tlsPins are the public key pins for the Fastmail API from the config file.
+var tlsPins []string
+
+// ErrPinMismatch is returned when the API server presents a certificate
+// chain that matches none of the configured pins.
+var ErrPinMismatch = errors.New("certificate does not match the configured TLS pins")
+
+// pinnedHost returns the host the pins apply to: the Fastmail API.
+func pinnedHost() string {
+	u, err := url.Parse(apiURL)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+// spkiPin returns the pin of a certificate: the base64 SHA-256 hash of its
+// public key, in the "sha256/..." form also used by HPKP and curl.
+func spkiPin(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return pinPrefix + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// validatePin checks that a pin is a well-formed "sha256/<base64>" hash.
+func validatePin(pin string) error {
+	encoded, ok := strings.CutPrefix(pin, pinPrefix)
+	if !ok {
+		return fmt.Errorf("TLS pin %q must start with %q", pin, pinPrefix)
+	}
+	hash, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(hash) != sha256.Size {
+		return fmt.Errorf("TLS pin %q is not a base64 SHA-256 hash", pin)
+	}
+	return nil
+}
+
+// verifyPins returns a TLS connection check that accepts connections to the
+// API host only if a certificate in a verified chain matches one of the
+// pins. Pinning an intermediate or root as well as the leaf, or listing the
+// next key before it is deployed, lets the pins survive certificate rotation.
+// The check runs in addition to the usual certificate verification.
+func verifyPins(host string, pins []string) func(tls.ConnectionState) error {
+	pinned := make(map[string]bool, len(pins))
+	for _, pin := range pins {
+		pinned[pin] = true
+	}
+
+	return func(state tls.ConnectionState) error {
+		if state.ServerName != host {
+			return nil
+		}
+		for _, chain := range state.VerifiedChains {
+			for _, cert := range chain {
+				if pinned[spkiPin(cert)] {
+					return nil
+				}
+			}
+		}
+		return fmt.Errorf("%w for %s; if Fastmail rotated its keys, check the new pins with `masked_fastmail pins` and update tls_pins, or use --ignore-tls-pins", ErrPinMismatch, host)
+	}
+}
+
+// pinnedTransport returns an HTTP transport that enforces the pins for the
+// Fastmail API.
+func pinnedTransport(pins []string) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{
+		VerifyConnection: verifyPins(pinnedHost(), pins),
+	}
+	return transport
+}
+
+// newPinsCmd creates the command that prints the pins of the certificates
+// the Fastmail API currently presents.
+func newPinsCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "pins",
+		Short: "Show the TLS public key pins of the Fastmail API certificates",
+		Long: `Show the TLS public key pins of the certificate chain the Fastmail API currently
+presents, for use in the tls_pins config setting. Check the output on a network
+you trust.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return handlePins(pinnedHost())
+		},
+	}
+}
+
+// handlePins connects to host and prints the pin of each certificate in the
+// verified chain, leaf first.
+func handlePins(host string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), operationTimeouts[opSession])
+	defer cancel()
+
+	dialer := &tls.Dialer{Config: &tls.Config{ServerName: host}}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, "443"))
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", host, err)
+	}
+	defer conn.Close()
+
+	state := conn.(*tls.Conn).ConnectionState()
+	if len(state.VerifiedChains) == 0 {
+		return fmt.Errorf("no verified certificate chain for %s", host)
+	}
+	chain := state.VerifiedChains[0]
+
+	if porcelain != "" {
+		// pin, subject, expiry (RFC 3339)
+		for _, cert := range chain {
+			printPorcelain(spkiPin(cert), cert.Subject.String(), cert.NotAfter.UTC().Format(time.RFC3339))
+		}
+		return nil
+	}
+
+	fmt.Printf("Certificate chain for %s:\n", host)
+	for _, cert := range chain {
+		fmt.Printf("- %s\n", cert.Subject)
+		fmt.Printf("  pin: %s\n", spkiPin(cert))
+		fmt.Printf("  expires: %s\n", formatTime(cert.NotAfter))
+	}
+	if len(tlsPins) > 0 {
+		fmt.Fprintf(os.Stderr, "\n%d pins are configured in tls_pins.\n", len(tlsPins))
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestValidatePin(t *testing.T) {
+	if err := validatePin("sha256/47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="); err != nil {
+		t.Fatalf("expected a valid pin, got %v", err)
+	}
+	for _, pin := range []string{
+		"47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=",
+		"sha256/not base64",
+		"sha256/AAAA",
+	} {
+		if err := validatePin(pin); err == nil {
+			t.Fatalf("expected %q to be rejected", pin)
+		}
+	}
+}
+
+func TestVerifyPins(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	cert := server.Certificate()
+	state := tls.ConnectionState{
+		ServerName:     "api.fastmail.com",
+		VerifiedChains: [][]*x509.Certificate{{cert}},
+	}
+
+	// Any of the pins may match, so a backup pin can be listed for rotation
+	if err := verifyPins("api.fastmail.com", []string{"sha256/47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=", spkiPin(cert)})(state); err != nil {
+		t.Fatalf("expected a matching pin to be accepted, got %v", err)
+	}
+
+	err := verifyPins("api.fastmail.com", []string{"sha256/47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="})(state)
+	if !errors.Is(err, ErrPinMismatch) {
+		t.Fatalf("expected ErrPinMismatch, got %v", err)
+	}
+
+	// Other hosts, such as the status page, are not pinned
+	state.ServerName = "fastmailstatus.com"
+	if err := verifyPins("api.fastmail.com", []string{"sha256/47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="})(state); err != nil {
+		t.Fatalf("expected other hosts not to be pinned, got %v", err)
+	}
+}
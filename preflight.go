@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// siblingAliases returns the aliases for sites close to the domain that the
+// active strategy still treats as different: the other scheme, the host with
+// or without www., or another host of the same registrable domain. Aliases
+// for the same host come first, then by state as in selectPreferredAlias.
+func siblingAliases(aliases []MaskedEmailInfo, domain string) []MaskedEmailInfo {
+	target := registrableDomain(domain)
+	if target == "" {
+		return nil
+	}
+	host := stripWWW(hostFromOrigin(domain))
+
+	var siblings []MaskedEmailInfo
+	for _, alias := range aliases {
+		if alias.State == AliasDeleted || alias.ForDomain == "" || aliasMatchesDomain(alias, domain) {
+			continue
+		}
+		if registrableDomain(alias.ForDomain) == target {
+			siblings = append(siblings, alias)
+		}
+	}
+	sameHost := func(alias MaskedEmailInfo) bool {
+		return stripWWW(hostFromOrigin(alias.ForDomain)) == host
+	}
+	sort.SliceStable(siblings, func(i, j int) bool {
+		if sameHost(siblings[i]) != sameHost(siblings[j]) {
+			return sameHost(siblings[i])
+		}
+		return statePriority[siblings[i].State] < statePriority[siblings[j].State]
+	})
+	return siblings
+}
+
+// stripWWW removes a leading "www." from a host.
+func stripWWW(host string) string {
+	if rest, ok := strings.CutPrefix(host, "www."); ok && rest != "" {
+		return rest
+	}
+	return host
+}
+
+// offerSiblingAlias checks for an alias of a nearby site before one is
+// created for the domain, and returns it if the user chooses to use it
+// instead. With --yes it is used without asking. When no question can be
+// asked, a new alias is created as before and the sibling is only pointed
+// out.
+func offerSiblingAlias(client *FastmailClient, domain string) (*MaskedEmailInfo, error) {
+	aliases, err := client.FetchAllAliases()
+	if err != nil {
+		return nil, formatAPIError("failed to get aliases", err)
+	}
+	siblings := siblingAliases(aliases, domain)
+	if len(siblings) == 0 {
+		return nil, nil
+	}
+	sibling := siblings[0]
+
+	switch {
+	case assumeYes:
+		fmt.Fprintf(os.Stderr, "Using the alias for %s\n", describeAliasOrigin(sibling))
+		return &sibling, nil
+	case !canPrompt():
+		fmt.Fprintf(os.Stderr, "Note: you already have an alias for %s (%s); use --force-new to create one without checking\n",
+			describeAliasOrigin(sibling), sibling.Email)
+		return nil, nil
+	}
+	question := fmt.Sprintf("You already have an alias for %s (%s). Use it instead?", describeAliasOrigin(sibling), sibling.Email)
+	if askYesNo(os.Stderr, os.Stdin, question) {
+		return &sibling, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestSiblingAliases(t *testing.T) {
+	aliases := []MaskedEmailInfo{
+		{ID: "1", Email: "shop.1@fastmail.com", ForDomain: "https://shop.example.com", State: AliasEnabled},
+		{ID: "2", Email: "www.2@fastmail.com", ForDomain: "https://www.example.com", State: AliasDisabled},
+		{ID: "3", Email: "plain.3@fastmail.com", ForDomain: "http://example.com", State: AliasEnabled},
+		{ID: "4", Email: "gone.4@fastmail.com", ForDomain: "https://example.com.au", State: AliasEnabled},
+		{ID: "5", Email: "deleted.5@fastmail.com", ForDomain: "https://m.example.com", State: AliasDeleted},
+	}
+
+	var got []string
+	for _, alias := range siblingAliases(aliases, "https://example.com") {
+		got = append(got, alias.ID)
+	}
+	// The same host comes first, enabled before disabled, then other hosts
+	want := []string{"3", "2", "1"}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+}
+
+func TestSiblingAliasesExcludesMatches(t *testing.T) {
+	oldIgnoreScheme := ignoreScheme
+	t.Cleanup(func() { ignoreScheme = oldIgnoreScheme })
+	ignoreScheme = true
+
+	aliases := []MaskedEmailInfo{
+		{ID: "1", Email: "plain.1@fastmail.com", ForDomain: "http://example.com", State: AliasEnabled},
+	}
+	if got := siblingAliases(aliases, "https://example.com"); len(got) != 0 {
+		t.Errorf("an alias the strategy matches is not a sibling: %v", got)
+	}
+}
+
+func TestStripWWW(t *testing.T) {
+	tests := map[string]string{
+		"www.example.com": "example.com",
+		"example.com":     "example.com",
+		"www.":            "www.",
+		"wwwexample.com":  "wwwexample.com",
+	}
+	for input, want := range tests {
+		if got := stripWWW(input); got != want {
+			t.Errorf("stripWWW(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestOfferSiblingAliasWithYes(t *testing.T) {
+	oldYes := assumeYes
+	t.Cleanup(func() { assumeYes = oldYes })
+	assumeYes = true
+
+	_, client := newFakeJMAP(t, MaskedEmailInfo{ID: "1", Email: "www.1@fastmail.com", ForDomain: "https://www.example.com", State: AliasEnabled})
+	alias, err := offerSiblingAlias(client, "https://example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if alias == nil || alias.Email != "www.1@fastmail.com" {
+		t.Errorf("got %v, want the www. alias", alias)
+	}
+}
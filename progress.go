@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	progressBarWidth = 30
+	// progressLogInterval is how often progress is logged when stderr is not a terminal
+	progressLogInterval = 5 * time.Second
+)
+
+// Progress reports the progress of a long operation on stderr. On a terminal
+// it draws a bar that is updated in place; otherwise it logs a line at most
+// every progressLogInterval. A nil *Progress is valid and reports nothing, so
+// callers that don't want progress can pass nil.
+type Progress struct {
+	label   string
+	total   int
+	done    int
+	out     io.Writer
+	tty     bool
+	start   time.Time
+	lastLog time.Time
+	now     func() time.Time
+}
+
+// newProgress starts reporting progress for an operation with total items.
+// A total of 0 means the number of items is not known in advance.
+func newProgress(label string, total int) *Progress {
+	if isTestMode() {
+		return nil
+	}
+
+	p := &Progress{
+		label: label,
+		total: total,
+		out:   os.Stderr,
+		tty:   isTerminal(os.Stderr),
+		now:   time.Now,
+	}
+	p.start = p.now()
+	p.lastLog = p.start
+	if p.tty {
+		p.draw()
+	}
+	return p
+}
+
+// isTerminal reports whether f is connected to a terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// Add records that n more items have been processed.
+func (p *Progress) Add(n int) {
+	if p == nil {
+		return
+	}
+	p.done += n
+
+	if p.tty {
+		p.draw()
+		return
+	}
+	if now := p.now(); now.Sub(p.lastLog) >= progressLogInterval {
+		p.lastLog = now
+		fmt.Fprintf(p.out, "%s\n", p.status())
+	}
+}
+
+// Done finishes the progress report.
+func (p *Progress) Done() {
+	if p == nil {
+		return
+	}
+
+	elapsed := p.now().Sub(p.start).Round(100 * time.Millisecond)
+	if p.tty {
+		p.draw()
+		fmt.Fprintln(p.out)
+		return
+	}
+	// Only log operations that took long enough to have logged progress
+	if elapsed >= progressLogInterval {
+		fmt.Fprintf(p.out, "%s: %d done in %s\n", p.label, p.done, elapsed)
+	}
+}
+
+// draw redraws the progress bar in place.
+func (p *Progress) draw() {
+	line := p.status()
+	if p.total > 0 {
+		filled := progressBarWidth * min(p.done, p.total) / p.total
+		bar := strings.Repeat("=", filled) + strings.Repeat(" ", progressBarWidth-filled)
+		line = fmt.Sprintf("%s [%s]", line, bar)
+	}
+	// Clear the rest of the line in case the previous status was longer
+	fmt.Fprintf(p.out, "\r%s\033[K", line)
+}
+
+// status describes the current progress, e.g. "Checking mail: 40/120 (33%), ETA 12s".
+func (p *Progress) status() string {
+	if p.total <= 0 {
+		return fmt.Sprintf("%s: %d", p.label, p.done)
+	}
+
+	status := fmt.Sprintf("%s: %d/%d (%d%%)", p.label, p.done, p.total, 100*p.done/p.total)
+	if eta, ok := p.eta(); ok {
+		status += fmt.Sprintf(", ETA %s", eta)
+	}
+	return status
+}
+
+// fetchAllAliasesWithProgress fetches every alias of the account, reporting
+// progress while the full inventory is downloaded. The aliases also refresh
+// the local search index.
+func fetchAllAliasesWithProgress(client *FastmailClient) ([]MaskedEmailInfo, error) {
+	progress := newProgress("Fetching aliases", 0)
+	aliases, err := client.FetchAllAliases()
+	progress.Add(len(aliases))
+	progress.Done()
+	if err == nil {
+		refreshSearchIndex(aliases)
+	}
+	return aliases, err
+}
+
+// eta estimates the remaining time from the average rate so far.
+func (p *Progress) eta() (time.Duration, bool) {
+	if p.total <= 0 || p.done <= 0 || p.done >= p.total {
+		return 0, false
+	}
+	elapsed := p.now().Sub(p.start)
+	remaining := time.Duration(float64(elapsed) / float64(p.done) * float64(p.total-p.done))
+	return remaining.Round(time.Second), true
+}
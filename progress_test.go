@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestProgress returns a progress report writing to a buffer with a clock
+// controlled by the test.
+func newTestProgress(total int, tty bool) (*Progress, *bytes.Buffer, *time.Time) {
+	var buf bytes.Buffer
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	p := &Progress{
+		label: "Checking mail",
+		total: total,
+		out:   &buf,
+		tty:   tty,
+		start: now,
+	}
+	p.now = func() time.Time { return now }
+	p.lastLog = p.start
+	return p, &buf, &now
+}
+
+func TestProgressStatus(t *testing.T) {
+	p, _, now := newTestProgress(120, false)
+	p.done = 40
+	*now = now.Add(10 * time.Second)
+
+	if got, want := p.status(), "Checking mail: 40/120 (33%), ETA 20s"; got != want {
+		t.Errorf("status() = %q, want %q", got, want)
+	}
+
+	p.total = 0
+	if got, want := p.status(), "Checking mail: 40"; got != want {
+		t.Errorf("status() without total = %q, want %q", got, want)
+	}
+}
+
+func TestProgressLogsPeriodicallyWithoutTerminal(t *testing.T) {
+	p, buf, now := newTestProgress(100, false)
+
+	p.Add(10)
+	if buf.Len() != 0 {
+		t.Fatalf("expected nothing to be logged before the interval, got %q", buf.String())
+	}
+
+	*now = now.Add(progressLogInterval)
+	p.Add(10)
+	if !strings.Contains(buf.String(), "20/100") {
+		t.Fatalf("expected a progress line after the interval, got %q", buf.String())
+	}
+
+	p.Add(80)
+	p.Done()
+	if !strings.Contains(buf.String(), "100 done in 5s") {
+		t.Fatalf("expected a final line for a long operation, got %q", buf.String())
+	}
+}
+
+func TestProgressDrawsBarOnTerminal(t *testing.T) {
+	p, buf, _ := newTestProgress(4, true)
+	p.Add(2)
+
+	output := buf.String()
+	if !strings.HasPrefix(output, "\r") || !strings.Contains(output, "2/4 (50%)") {
+		t.Fatalf("expected the bar to be redrawn in place, got %q", output)
+	}
+	if !strings.Contains(output, "["+strings.Repeat("=", progressBarWidth/2)+strings.Repeat(" ", progressBarWidth/2)+"]") {
+		t.Fatalf("expected a half-filled bar, got %q", output)
+	}
+}
+
+func TestNilProgress(t *testing.T) {
+	var p *Progress
+	p.Add(1)
+	p.Done()
+}
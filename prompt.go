@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+var (
+	// assumeYes answers yes to every confirmation; set with --yes
+	assumeYes bool
+	// noInput guarantees that nothing waits for input from the terminal;
+	// set with --no-input
+	noInput bool
+	// yesReally also answers the confirmation phrase of large bulk changes;
+	// set with --yes-really
+	yesReally bool
+	// bulkPhraseOver is the number of aliases above which a bulk change
+	// must be confirmed by typing a phrase; zero disables the phrase. It is
+	// set by the confirm_phrase_over setting.
+	bulkPhraseOver = defaultBulkPhraseOver
+	// promptTimeout is how long prompts wait for an answer; zero waits
+	// forever. It is set by the prompt_timeout setting and --prompt-timeout.
+	promptTimeout time.Duration
+	// promptTimeoutAction is the answer taken when a prompt times out; set
+	// by the prompt_timeout_action setting
+	promptTimeoutAction = timeoutDefault
+)
+
+// Actions of the prompt_timeout_action setting
+const (
+	// timeoutDefault takes the default answer of the prompt: no to
+	// confirmations and the default alias of the picker
+	timeoutDefault = "default"
+	// timeoutYes goes ahead with confirmations as --yes does, and takes
+	// the default alias of the picker. The phrase of large bulk changes is
+	// still not answered.
+	timeoutYes = "yes"
+)
+
+var timeoutActions = []string{timeoutDefault, timeoutYes}
+
+// defaultBulkPhraseOver is the default of the confirm_phrase_over setting
+const defaultBulkPhraseOver = 50
+
+// ErrCancelled is returned when the user declines a confirmation
+var ErrCancelled = errors.New("cancelled")
+
+// ErrNotConfirmed is returned when a confirmation can't be asked and --yes
+// wasn't given
+var ErrNotConfirmed = errors.New("refusing to go ahead without confirmation")
+
+// errNoInput is returned by operations that would have to wait for input
+// from the terminal with --no-input
+var errNoInput = errors.New("input would be read from the terminal, which --no-input forbids")
+
+// errPromptTimeout is returned by readAnswer when no answer came within
+// promptTimeout
+var errPromptTimeout = errors.New("no answer before the prompt timed out")
+
+// validateTimeoutAction checks a value of the prompt_timeout_action setting.
+func validateTimeoutAction(action string) error {
+	for _, known := range timeoutActions {
+		if action == known {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown prompt_timeout_action %q (expected one of: %s)", action, strings.Join(timeoutActions, ", "))
+}
+
+// readAnswer reads a line from reader, giving up after promptTimeout. The
+// read goes on in the background after a timeout, which is fine since the
+// command no longer asks anything then.
+func readAnswer(reader *bufio.Reader) (string, error) {
+	if promptTimeout <= 0 {
+		return reader.ReadString('\n')
+	}
+	type answer struct {
+		line string
+		err  error
+	}
+	answers := make(chan answer, 1)
+	go func() {
+		line, err := reader.ReadString('\n')
+		answers <- answer{line, err}
+	}()
+	select {
+	case a := <-answers:
+		return a.line, a.err
+	case <-time.After(promptTimeout):
+		return "", errPromptTimeout
+	}
+}
+
+// canPrompt reports whether questions can be asked: the user is at a
+// terminal and didn't pass --no-input.
+func canPrompt() bool {
+	return !noInput && isTerminal(os.Stdin) && isTerminal(os.Stderr)
+}
+
+// confirm asks a yes/no question on stderr and reads the answer from stdin,
+// and returns ErrCancelled unless the answer is yes. With --yes, the question
+// is not asked and the command goes ahead. When no question can be asked
+// (see canPrompt), e.g. in cron jobs, pipes or with --no-input, the change
+// is refused with ErrNotConfirmed: only --yes approves it.
+func confirm(question string) error {
+	if assumeYes {
+		return nil
+	}
+	if !canPrompt() {
+		reason := "no terminal to ask on"
+		if noInput {
+			reason = "--no-input forbids asking"
+		}
+		return fmt.Errorf("%w: %q can't be answered, %s; pass --yes to go ahead", ErrNotConfirmed, question, reason)
+	}
+	if !askYesNo(os.Stderr, os.Stdin, question) {
+		return ErrCancelled
+	}
+	return nil
+}
+
+// askYesNo writes the question to out and reads the answer from in; only
+// "y" and "yes" are taken as yes. Without an answer within promptTimeout,
+// the answer is given by promptTimeoutAction.
+func askYesNo(out io.Writer, in io.Reader, question string) bool {
+	fmt.Fprintf(out, "%s [y/N] ", question)
+	answer, err := readAnswer(bufio.NewReader(in))
+	if errors.Is(err, errPromptTimeout) {
+		yes := promptTimeoutAction == timeoutYes
+		fmt.Fprintf(out, "\nNo answer within %s; answering %s\n", promptTimeout, choose(yes, "yes", "no"))
+		return yes
+	}
+	if err != nil && answer == "" {
+		fmt.Fprintln(out)
+		return false
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
+
+// confirmBulk confirms a change of count aliases described by verb, such as
+// "disable". Above the confirm_phrase_over setting the user has to type a
+// phrase such as "disable 214 aliases", which --yes doesn't answer: only
+// --yes-really does, and without it a command that can't ask fails rather
+// than going ahead. Smaller changes only ask question with confirm, unless
+// it is empty.
+func confirmBulk(verb string, count int, question string) error {
+	if bulkPhraseOver == 0 || count <= bulkPhraseOver {
+		if question != "" {
+			return confirm(question)
+		}
+		return nil
+	}
+	if yesReally {
+		return nil
+	}
+	phrase := fmt.Sprintf("%s %s", verb, plural(count, "alias", "aliases"))
+	if !canPrompt() {
+		return fmt.Errorf("refusing to %s without confirmation; pass --yes-really to go ahead", phrase)
+	}
+	if !askPhrase(os.Stderr, os.Stdin, phrase) {
+		return ErrCancelled
+	}
+	return nil
+}
+
+// askPhrase asks the user to type phrase on in and reports whether they
+// did, ignoring case and surrounding spaces. Without an answer within
+// promptTimeout, the phrase is taken as not typed, whatever
+// promptTimeoutAction is.
+func askPhrase(out io.Writer, in io.Reader, phrase string) bool {
+	fmt.Fprintf(out, "This changes many aliases. Type %q to go ahead: ", phrase)
+	answer, err := readAnswer(bufio.NewReader(in))
+	if errors.Is(err, errPromptTimeout) {
+		fmt.Fprintf(out, "\nNo answer within %s\n", promptTimeout)
+		return false
+	}
+	if err != nil && answer == "" {
+		fmt.Fprintln(out)
+		return false
+	}
+	return strings.EqualFold(strings.Join(strings.Fields(answer), " "), phrase)
+}
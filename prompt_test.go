@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAskYesNo(t *testing.T) {
+	tests := []struct {
+		answer string
+		want   bool
+	}{
+		{"y\n", true},
+		{"YES\n", true},
+		{"n\n", false},
+		{"\n", false},
+		{"", false},
+		{"sure\n", false},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		if got := askYesNo(&out, strings.NewReader(tt.answer), "Delete 3 aliases?"); got != tt.want {
+			t.Errorf("askYesNo(%q) = %v, want %v", tt.answer, got, tt.want)
+		}
+		if !strings.HasPrefix(out.String(), "Delete 3 aliases? [y/N] ") {
+			t.Errorf("unexpected question %q", out.String())
+		}
+	}
+}
+
+func TestConfirmWithoutTerminal(t *testing.T) {
+	// Tests run without a terminal, where nobody can approve the change
+	err := confirm("Delete 3 aliases?")
+	if !errors.Is(err, ErrNotConfirmed) || !strings.Contains(err.Error(), "--yes") {
+		t.Fatalf("confirm() = %v; want ErrNotConfirmed naming --yes", err)
+	}
+	noInput = true
+	defer func() { noInput, assumeYes = false, false }()
+	if err := confirm("Delete 3 aliases?"); !errors.Is(err, ErrNotConfirmed) || !strings.Contains(err.Error(), "--no-input") {
+		t.Fatalf("confirm() = %v; want ErrNotConfirmed with --no-input", err)
+	}
+	assumeYes = true
+	if err := confirm("Delete 3 aliases?"); err != nil {
+		t.Fatalf("confirm() = %v; want --yes to go ahead", err)
+	}
+}
+
+func TestAskPhrase(t *testing.T) {
+	tests := []struct {
+		answer string
+		want   bool
+	}{
+		{"disable 214 aliases\n", true},
+		{"  Disable  214 aliases \n", true},
+		{"disable 213 aliases\n", false},
+		{"y\n", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		if got := askPhrase(&out, strings.NewReader(tt.answer), "disable 214 aliases"); got != tt.want {
+			t.Errorf("askPhrase(%q) = %v, want %v", tt.answer, got, tt.want)
+		}
+		if !strings.Contains(out.String(), `"disable 214 aliases"`) {
+			t.Errorf("the question doesn't show the phrase: %q", out.String())
+		}
+	}
+}
+
+func TestConfirmBulk(t *testing.T) {
+	defer func() { bulkPhraseOver, yesReally, assumeYes = defaultBulkPhraseOver, false, false }()
+	bulkPhraseOver = 10
+
+	// Tests run without a terminal, where the question can't be answered
+	if err := confirmBulk("disable", 10, "Disable 10 aliases?"); !errors.Is(err, ErrNotConfirmed) {
+		t.Errorf("expected a refusal without --yes, got %v", err)
+	}
+	if err := confirmBulk("disable", 10, ""); err != nil {
+		t.Errorf("a change without a question goes ahead: %v", err)
+	}
+	assumeYes = true
+	if err := confirmBulk("disable", 10, "Disable 10 aliases?"); err != nil {
+		t.Errorf("a change at the threshold needs no phrase: %v", err)
+	}
+	// The phrase can't be typed either, and --yes doesn't stand in for it
+	err := confirmBulk("disable", 11, "")
+	if err == nil || !strings.Contains(err.Error(), "disable 11 aliases") || !strings.Contains(err.Error(), "--yes-really") {
+		t.Errorf("expected a refusal naming the phrase and --yes-really, got %v", err)
+	}
+	yesReally = true
+	if err := confirmBulk("disable", 11, ""); err != nil {
+		t.Errorf("--yes-really should go ahead: %v", err)
+	}
+	yesReally, bulkPhraseOver = false, 0
+	if err := confirmBulk("disable", 1000, ""); err != nil {
+		t.Errorf("a threshold of 0 disables the phrase: %v", err)
+	}
+}
+
+func TestPromptTimeout(t *testing.T) {
+	defer func() { promptTimeout, promptTimeoutAction = 0, timeoutDefault }()
+	promptTimeout = 10 * time.Millisecond
+
+	// A reader that never answers, like a hung terminal
+	hung, _ := io.Pipe()
+	var out bytes.Buffer
+	if askYesNo(&out, hung, "Delete 3 aliases?") {
+		t.Error("expected no after the timeout with the default action")
+	}
+	if !strings.Contains(out.String(), "No answer within 10ms; answering no") {
+		t.Errorf("the timeout isn't reported: %q", out.String())
+	}
+
+	promptTimeoutAction = timeoutYes
+	if !askYesNo(&out, hung, "Delete 3 aliases?") {
+		t.Error("expected yes after the timeout with the yes action")
+	}
+	if askPhrase(&out, hung, "disable 214 aliases") {
+		t.Error("the phrase was taken as typed after the timeout")
+	}
+
+	aliases := selectionTestAliases()
+	if got := pickAlias(&out, hung, aliases, &aliases[1]); got.ID != aliases[1].ID {
+		t.Errorf("picked %s after the timeout, want the default %s", got.Email, aliases[1].Email)
+	}
+
+	// Answers within the timeout are taken as usual
+	if !askYesNo(&out, strings.NewReader("y\n"), "Delete 3 aliases?") {
+		t.Error("expected the answer to be read")
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// protectedTag marks an alias as protected when it appears in its
+// description, so that the protection is shared by every machine using the
+// account.
+const protectedTag = "[protected]"
+
+// ErrProtected is returned when deleting a protected alias without --force.
+var ErrProtected = errors.New("alias is protected")
+
+// isProtected reports whether the alias is protected, either in the local
+// metadata or by the tag in its description.
+func isProtected(alias MaskedEmailInfo, metadata map[string]AliasMetadata) bool {
+	return metadata[alias.Email].Protected || strings.Contains(strings.ToLower(alias.Description), protectedTag)
+}
+
+// protectedError explains that a protected alias was left alone.
+func protectedError(email string) error {
+	return fmt.Errorf("%w: refusing to delete %s (use --force to delete it anyway)", ErrProtected, email)
+}
+
+// checkDeletable returns ErrProtected if the alias is protected, unless force
+// is set.
+func checkDeletable(alias MaskedEmailInfo, force bool) error {
+	if force {
+		return nil
+	}
+	metadata, err := loadMetadata()
+	if err != nil {
+		return fmt.Errorf("failed to read alias metadata: %w", err)
+	}
+	if isProtected(alias, metadata) {
+		return protectedError(alias.Email)
+	}
+	return nil
+}
+
+// newProtectCmd creates the command that protects aliases from deletion.
+func newProtectCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "protect <alias>...",
+		Short: "Protect aliases from being deleted without --force",
+		Long: `Protect aliases from being deleted without --force, e.g. the alias your bank
+uses. The protection is kept in the local metadata; to protect an alias on every
+machine, add "` + protectedTag + `" to its description instead.`,
+		Example: `  masked_fastmail protect user.1234@fastmail.com`,
+		Args:    cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, err := newClientFromCmd(cmd)
+			if err != nil {
+				return err
+			}
+			return handleProtect(client, args, true)
+		},
+	}
+}
+
+// newUnprotectCmd creates the command that removes the protection of aliases.
+func newUnprotectCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "unprotect <alias>...",
+		Short: "Remove the protection from aliases",
+		Long: `Remove the protection from aliases, so that they can be deleted without
+--force. It is refused in read-only mode, like the changes it allows.`,
+		Example: `  masked_fastmail unprotect user.1234@fastmail.com`,
+		Args:    cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, err := newClientFromCmd(cmd)
+			if err != nil {
+				return err
+			}
+			return handleProtect(client, args, false)
+		},
+	}
+}
+
+// handleProtect sets or clears the protection of the aliases in the local
+// metadata. Clearing it is refused in read-only mode, since it lets the
+// aliases be deleted; protecting only makes changes safer.
+func handleProtect(client *FastmailClient, identifiers []string, protect bool) error {
+	if !protect {
+		switch {
+		case readOnlyForced():
+			return fmt.Errorf("%w: refusing to remove the protection of aliases, since %s is set", ErrReadOnly, readOnlyEnv)
+		case client.ReadOnly:
+			return fmt.Errorf("%w: refusing to remove the protection of aliases in read-only mode", ErrReadOnly)
+		}
+	}
+	emails := make([]string, 0, len(identifiers))
+	for _, identifier := range identifiers {
+		email, err := normalizeEmailInput(identifier)
+		if err != nil {
+			return err
+		}
+		emails = append(emails, email)
+	}
+
+	aliases, err := client.FetchAllAliases()
+	if err != nil {
+		return formatAPIError("failed to get aliases", err)
+	}
+	byEmail := make(map[string]MaskedEmailInfo, len(aliases))
+	for _, alias := range aliases {
+		byEmail[alias.Email] = alias
+	}
+
+	for _, email := range emails {
+		alias, ok := byEmail[email]
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrAliasNotFound, email)
+		}
+		if err := updateMetadata(email, func(entry *AliasMetadata) { entry.Protected = protect }); err != nil {
+			return fmt.Errorf("failed to save alias metadata: %w", err)
+		}
+
+		if porcelain != "" {
+			// email, protected (yes|no)
+			printPorcelain(email, choose(protect, "yes", "no"))
+			continue
+		}
+		if protect {
+			fmt.Printf("Protected %s\n", email)
+		} else if strings.Contains(strings.ToLower(alias.Description), protectedTag) {
+			fmt.Printf("Removed the local protection of %s, but its description still contains %s\n", email, protectedTag)
+		} else {
+			fmt.Printf("%s is no longer protected\n", email)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestIsProtected(t *testing.T) {
+	metadata := map[string]AliasMetadata{"bank@fastmail.com": {Protected: true}}
+
+	if !isProtected(MaskedEmailInfo{Email: "bank@fastmail.com"}, metadata) {
+		t.Fatalf("expected an alias protected in the metadata to be protected")
+	}
+	if !isProtected(MaskedEmailInfo{Email: "tax@fastmail.com", Description: "Tax office [Protected]"}, metadata) {
+		t.Fatalf("expected the description tag to protect an alias")
+	}
+	if isProtected(MaskedEmailInfo{Email: "shop@fastmail.com", Description: "Shop"}, metadata) {
+		t.Fatalf("did not expect an unmarked alias to be protected")
+	}
+}
+
+func TestCheckDeletable(t *testing.T) {
+	t.Setenv(dataDirEnv, t.TempDir())
+	alias := MaskedEmailInfo{Email: "bank@fastmail.com"}
+
+	if err := checkDeletable(alias, false); err != nil {
+		t.Fatalf("expected an unprotected alias to be deletable, got %v", err)
+	}
+
+	if err := updateMetadata(alias.Email, func(entry *AliasMetadata) { entry.Protected = true }); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkDeletable(alias, false); !errors.Is(err, ErrProtected) {
+		t.Fatalf("expected ErrProtected, got %v", err)
+	}
+	if err := checkDeletable(alias, true); err != nil {
+		t.Fatalf("expected --force to allow deleting a protected alias, got %v", err)
+	}
+}
+
+func TestUnprotectReadOnly(t *testing.T) {
+	fake, client := newFakeJMAP(t, MaskedEmailInfo{ID: "a1", Email: "bank@fastmail.com", State: AliasEnabled})
+	if err := updateMetadata("bank@fastmail.com", func(entry *AliasMetadata) { entry.Protected = true }); err != nil {
+		t.Fatal(err)
+	}
+	stillProtected := func() bool {
+		metadata, err := loadMetadata()
+		return err == nil && metadata["bank@fastmail.com"].Protected
+	}
+
+	client.ReadOnly = true
+	if err := handleProtect(client, []string{"bank@fastmail.com"}, false); !errors.Is(err, ErrReadOnly) || exitCode(err) != exitReadOnly {
+		t.Errorf("unprotect with --read-only = %v; want ErrReadOnly", err)
+	}
+	client.ReadOnly = false
+	t.Setenv(readOnlyEnv, "1")
+	if err := handleProtect(client, []string{"bank@fastmail.com"}, false); !errors.Is(err, ErrReadOnly) || !strings.Contains(err.Error(), readOnlyEnv) {
+		t.Errorf("unprotect with %s = %v; want ErrReadOnly naming it", readOnlyEnv, err)
+	}
+	if !stillProtected() || fake.requests != 0 {
+		t.Fatalf("the protection was removed in read-only mode")
+	}
+
+	// Protecting only makes changes safer, so it still works
+	captureStdout(t, func() {
+		if err := handleProtect(client, []string{"bank@fastmail.com"}, true); err != nil {
+			t.Errorf("protect in read-only mode = %v", err)
+		}
+	})
+	t.Setenv(readOnlyEnv, "")
+	captureStdout(t, func() {
+		if err := handleProtect(client, []string{"bank@fastmail.com"}, false); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if stillProtected() {
+		t.Errorf("unprotect did not remove the protection")
+	}
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// recordingVersion is the format version of recording files
+const recordingVersion = 1
+
+// recordedEmail matches the email addresses replaced when saving a recording
+var recordedEmail = regexp.MustCompile(`[A-Za-z0-9._%+-]+@([A-Za-z0-9-]+\.)+[A-Za-z]{2,}`)
+
+// recording is a file of API interactions captured by --record and served
+// by --replay.
+type recording struct {
+	Version      int           `yaml:"version"`
+	Interactions []interaction `yaml:"interactions"`
+}
+
+// interaction is an API request and the response it received. Bodies are
+// kept decompressed, so that recordings can be read and edited.
+type interaction struct {
+	Request  recordedRequest  `yaml:"request"`
+	Response recordedResponse `yaml:"response"`
+}
+
+type recordedRequest struct {
+	Method string `yaml:"method"`
+	URL    string `yaml:"url"`
+	Body   string `yaml:"body,omitempty"`
+}
+
+type recordedResponse struct {
+	Status      int    `yaml:"status"`
+	ContentType string `yaml:"contentType,omitempty"`
+	Body        string `yaml:"body,omitempty"`
+}
+
+// activeRecorder captures the API interactions of this run for --record
+var activeRecorder *recorder
+
+// recorder is an http.RoundTripper capturing the interactions sent through
+// it to a recording.
+type recorder struct {
+	next  http.RoundTripper
+	path  string
+	token string
+
+	mu  sync.Mutex
+	rec recording
+}
+
+func (r *recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	recorded := recordedRequest{Method: req.Method, URL: req.URL.String()}
+	if req.Body != nil {
+		data, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(data))
+		body, err := decodedBody(data, req.Header.Get("Content-Encoding"))
+		if err != nil {
+			return nil, err
+		}
+		recorded.Body = string(body)
+	}
+
+	resp, err := r.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	body, err := decodedBody(data, resp.Header.Get("Content-Encoding"))
+	if err != nil {
+		return nil, err
+	}
+	// The caller gets the decompressed body, as it would from a replay
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = int64(len(body))
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	r.mu.Lock()
+	r.rec.Interactions = append(r.rec.Interactions, interaction{
+		Request:  recorded,
+		Response: recordedResponse{Status: resp.StatusCode, ContentType: resp.Header.Get("Content-Type"), Body: string(body)},
+	})
+	r.mu.Unlock()
+	return resp, nil
+}
+
+// decodedBody decompresses a request or response body.
+func decodedBody(data []byte, encoding string) ([]byte, error) {
+	reader, err := decompressBody(bytes.NewReader(data), encoding)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(reader)
+}
+
+// save writes the sanitized recording to its file.
+func (r *recorder) save() error {
+	r.mu.Lock()
+	rec := r.rec
+	r.mu.Unlock()
+	rec.Version = recordingVersion
+	sanitizeRecording(&rec, r.token)
+
+	data, err := yaml.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(r.path, data, privateFileMode)
+}
+
+// sanitizeRecording replaces the secrets and personal details in a
+// recording with placeholders, so that it can be attached to a bug report:
+// the API token, the account IDs and every email address. Each value is
+// always replaced by the same placeholder, so the recording stays consistent.
+func sanitizeRecording(rec *recording, token string) {
+	var replacements []string
+	if token != "" {
+		replacements = append(replacements, token, "[redacted token]")
+	}
+	for i, id := range recordedAccountIDs(rec) {
+		replacements = append(replacements, id, "account"+strconv.Itoa(i+1))
+	}
+	replacer := strings.NewReplacer(replacements...)
+
+	emails := make(map[string]string)
+	sanitize := func(s string) string {
+		s = replacer.Replace(s)
+		return recordedEmail.ReplaceAllStringFunc(s, func(email string) string {
+			key := strings.ToLower(email)
+			if placeholder, ok := emails[key]; ok {
+				return placeholder
+			}
+			domain := email[strings.LastIndex(email, "@"):]
+			emails[key] = "user" + strconv.Itoa(len(emails)+1) + domain
+			return emails[key]
+		})
+	}
+	for i := range rec.Interactions {
+		interaction := &rec.Interactions[i]
+		interaction.Request.URL = sanitize(interaction.Request.URL)
+		interaction.Request.Body = sanitize(interaction.Request.Body)
+		interaction.Response.Body = sanitize(interaction.Response.Body)
+	}
+}
+
+// recordedAccountIDs returns the account IDs in the session responses of a
+// recording, longest first so that no ID is replaced inside another.
+func recordedAccountIDs(rec *recording) []string {
+	seen := make(map[string]bool)
+	var ids []string
+	for _, interaction := range rec.Interactions {
+		var session struct {
+			Accounts map[string]json.RawMessage `json:"accounts"`
+		}
+		if err := json.Unmarshal([]byte(interaction.Response.Body), &session); err != nil {
+			continue
+		}
+		for id := range session.Accounts {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if len(ids[i]) != len(ids[j]) {
+			return len(ids[i]) > len(ids[j])
+		}
+		return ids[i] < ids[j]
+	})
+	return ids
+}
+
+// replayer is an http.RoundTripper answering requests with the responses of
+// a recording, in order, without contacting the API.
+type replayer struct {
+	mu   sync.Mutex
+	rec  recording
+	next int
+}
+
+// loadReplay reads a recording to replay.
+func loadReplay(path string) (*replayer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read recording: %w", err)
+	}
+	var rec recording
+	if err := yaml.Unmarshal(data, &rec); err != nil {
+		return nil, fmt.Errorf("failed to parse recording %s: %w", path, err)
+	}
+	if rec.Version != recordingVersion {
+		return nil, fmt.Errorf("unsupported recording version %d in %s", rec.Version, path)
+	}
+	return &replayer{rec: rec}, nil
+}
+
+func (r *replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.next >= len(r.rec.Interactions) {
+		return nil, fmt.Errorf("replay: no recorded response left for request %d (%s %s)", r.next+1, req.Method, req.URL)
+	}
+	recorded := r.rec.Interactions[r.next]
+	if recorded.Request.Method != req.Method || recorded.Request.URL != req.URL.String() {
+		return nil, fmt.Errorf("replay: request %d is %s %s but the recording has %s %s",
+			r.next+1, req.Method, req.URL, recorded.Request.Method, recorded.Request.URL)
+	}
+	r.next++
+
+	header := make(http.Header)
+	if recorded.Response.ContentType != "" {
+		header.Set("Content-Type", recorded.Response.ContentType)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", recorded.Response.Status, http.StatusText(recorded.Response.Status)),
+		StatusCode:    recorded.Response.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(recorded.Response.Body)),
+		ContentLength: int64(len(recorded.Response.Body)),
+		Request:       req,
+	}, nil
+}
+
+// setRecordingOptions sets up --record or --replay for a client. Both
+// bypass the session cache, so that the session request is part of the
+// recording; replays need no API token.
+func setRecordingOptions(cmd *cobra.Command, opts *ClientOptions) error {
+	record, _ := cmd.Flags().GetString("record")
+	replay, _ := cmd.Flags().GetString("replay")
+	switch {
+	case record != "":
+		opts.CacheSession = false
+		opts.WrapTransport = func(next http.RoundTripper) http.RoundTripper {
+			if activeRecorder == nil {
+				activeRecorder = &recorder{path: record, token: opts.Token}
+				if activeRecorder.token == "" {
+					activeRecorder.token, _ = loadToken()
+				}
+			}
+			activeRecorder.next = next
+			return activeRecorder
+		}
+	case replay != "":
+		replayer, err := loadReplay(replay)
+		if err != nil {
+			return err
+		}
+		opts.CacheSession = false
+		opts.Token = "replay"
+		opts.WrapTransport = func(http.RoundTripper) http.RoundTripper { return replayer }
+	}
+	return nil
+}
+
+// saveRecording writes the recording of this run, if --record was given.
+func saveRecording() {
+	if activeRecorder == nil {
+		return
+	}
+	if err := activeRecorder.save(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to save recording: %v\n", err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Recorded %d API requests to %s\n", len(activeRecorder.rec.Interactions), activeRecorder.path)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		defer gz.Close()
+		if r.Method == http.MethodGet {
+			io.WriteString(gz, `{"username":"me@example.com","accounts":{"u123":{"name":"me@example.com"}},"apiUrl":"https://api.example.com/u123"}`)
+			return
+		}
+		io.WriteString(gz, `{"methodResponses":[["MaskedEmail/get",{"accountId":"u123","list":[{"email":"shop.abc@fastmail.com","description":"token-secret"}]},"0"]]}`)
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "session.yaml")
+	rec := &recorder{next: http.DefaultTransport, path: path, token: "token-secret"}
+	client := &http.Client{Transport: rec}
+	var bodies []string
+	for _, req := range []*http.Request{
+		mustRequest(t, http.MethodGet, server.URL+"/session", ""),
+		mustRequest(t, http.MethodPost, server.URL+"/api", `{"methodCalls":[["MaskedEmail/get",{"accountId":"u123"},"0"]]}`),
+	} {
+		req.Header.Set("Accept-Encoding", "gzip")
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		bodies = append(bodies, string(data))
+	}
+	if !strings.Contains(bodies[1], "shop.abc@fastmail.com") {
+		t.Fatalf("expected the caller to get the decompressed response, got %q", bodies[1])
+	}
+	if err := rec.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"token-secret", "u123", "me@example.com", "shop.abc@"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("recording contains %q:\n%s", secret, data)
+		}
+	}
+
+	replay, err := loadReplay(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client = &http.Client{Transport: replay}
+	resp, err := client.Do(mustRequest(t, http.MethodGet, server.URL+"/session", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := io.ReadAll(resp.Body)
+	want := `{"username":"user1@example.com","accounts":{"account1":{"name":"user1@example.com"}},"apiUrl":"https://api.example.com/account1"}`
+	if string(got) != want {
+		t.Fatalf("replayed %s, want %s", got, want)
+	}
+	if _, err := client.Do(mustRequest(t, http.MethodGet, server.URL+"/session", "")); err == nil || !strings.Contains(err.Error(), "recording has POST") {
+		t.Fatalf("expected a mismatch error, got %v", err)
+	}
+}
+
+func mustRequest(t *testing.T, method, url, body string) *http.Request {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
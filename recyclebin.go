@@ -0,0 +1,290 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	recycleBinFile = "recycle-bin.json"
+	// defaultPurgeAfter is how long deleted aliases stay in the recycle bin
+	defaultPurgeAfter = 30 * 24 * time.Hour
+)
+
+// purgeAfter is the purge_after setting from the config file
+var purgeAfter = defaultPurgeAfter
+
+// binEntry is a deleted alias kept in the recycle bin.
+type binEntry struct {
+	ID            string     `json:"id"`
+	ForDomain     string     `json:"forDomain,omitempty"`
+	PreviousState AliasState `json:"previousState"`
+	DeletedAt     time.Time  `json:"deletedAt"`
+}
+
+// recycleBin lists the aliases deleted with this CLI, by email, until they
+// are purged. Deleted aliases only bounce mail and can still be enabled
+// again; purging destroys them for good.
+type recycleBin map[string]binEntry
+
+// loadRecycleBin reads the recycle bin; a missing bin is empty.
+func loadRecycleBin() (recycleBin, error) {
+	bin := recycleBin{}
+	if err := readJSONFile(recycleBinFile, &bin); err != nil {
+		return nil, err
+	}
+	return bin, nil
+}
+
+// recordStateChanges keeps the recycle bin in step with state changes:
+// aliases set to deleted are added with their previous state, and aliases
+// set to any other state are taken out. The aliases have their state from
+// before the change. Failures are reported but don't fail the command, which
+// has already changed the aliases.
+func recordStateChanges(aliases []MaskedEmailInfo, state AliasState, now time.Time) {
+	if len(aliases) == 0 {
+		return
+	}
+	bin := recycleBin{}
+	err := updateJSONFile(recycleBinFile, &bin, func() error {
+		bin.apply(aliases, state, now)
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not update the recycle bin: %v\n", err)
+	}
+}
+
+// apply adds or removes the aliases for a change to the state.
+func (b recycleBin) apply(aliases []MaskedEmailInfo, state AliasState, now time.Time) {
+	for _, alias := range aliases {
+		if state != AliasDeleted {
+			delete(b, alias.Email)
+			continue
+		}
+		if _, ok := b[alias.Email]; ok || alias.State == AliasDeleted {
+			continue
+		}
+		b[alias.Email] = binEntry{
+			ID:            alias.ID,
+			ForDomain:     alias.ForDomain,
+			PreviousState: alias.State,
+			DeletedAt:     now.UTC(),
+		}
+	}
+}
+
+// Outcomes of the aliases in the recycle bin during a purge
+const (
+	purgeWaiting  = "waiting"
+	purgeDue      = "due"
+	purgePurged   = "purged"
+	purgeFailed   = "failed"
+	purgeRestored = "restored"
+	purgeGone     = "gone"
+)
+
+// purgeItem is an alias of the recycle bin and what a purge does with it.
+type purgeItem struct {
+	Email string
+	binEntry
+	Outcome string
+	Reason  string
+}
+
+// planPurge decides what to do with each alias of the recycle bin: aliases
+// deleted longer ago than the grace period are due, aliases enabled or
+// disabled again since are restored, and aliases that no longer exist are
+// gone. Items are sorted by deletion time.
+func planPurge(bin recycleBin, aliases []MaskedEmailInfo, grace time.Duration, now time.Time) []purgeItem {
+	byID := make(map[string]MaskedEmailInfo, len(aliases))
+	for _, alias := range aliases {
+		byID[alias.ID] = alias
+	}
+
+	items := make([]purgeItem, 0, len(bin))
+	for email, entry := range bin {
+		item := purgeItem{Email: email, binEntry: entry}
+		alias, ok := byID[entry.ID]
+		switch {
+		case !ok:
+			item.Outcome = purgeGone
+		case alias.State != AliasDeleted:
+			item.Outcome, item.Reason = purgeRestored, "now "+string(alias.State)
+		case now.Sub(entry.DeletedAt) < grace:
+			item.Outcome = purgeWaiting
+			item.Reason = "due " + formatTimeAt(entry.DeletedAt.Add(grace), now)
+		default:
+			item.Outcome = purgeDue
+		}
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if !items[i].DeletedAt.Equal(items[j].DeletedAt) {
+			return items[i].DeletedAt.Before(items[j].DeletedAt)
+		}
+		return items[i].Email < items[j].Email
+	})
+	return items
+}
+
+// newPurgeCmd creates the command that destroys the aliases of the recycle
+// bin once their grace period is over.
+func newPurgeCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "purge",
+		Short: "Destroy deleted aliases once their grace period in the recycle bin is over",
+		Long: `Aliases deleted with --delete only get the deleted state, in which they bounce
+mail but can still be enabled again, and are kept in a local recycle bin.
+purge lists the recycle bin and, with --apply, destroys the aliases deleted
+longer ago than the grace period (purge_after in the config file, 30 days by
+default). Destroyed aliases can't be restored.
+
+Aliases enabled or disabled again since they were deleted, and aliases that no
+longer exist, are taken out of the recycle bin.`,
+		Example: `  # Show the recycle bin and what is due:
+  masked_fastmail purge
+
+  # Destroy the aliases deleted more than a week ago:
+  masked_fastmail purge --older-than 168h --apply`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			apply, _ := cmd.Flags().GetBool("apply")
+			grace := purgeAfter
+			if cmd.Flags().Changed("older-than") {
+				grace, _ = cmd.Flags().GetDuration("older-than")
+			}
+			if grace < 0 {
+				return fmt.Errorf("--older-than must not be negative")
+			}
+			client, err := newClientFromCmd(cmd)
+			if err != nil {
+				return err
+			}
+			return handlePurge(client, grace, apply)
+		},
+	}
+	cmd.Flags().Bool("apply", false, "destroy the aliases that are due instead of only listing them")
+	cmd.Flags().Duration("older-than", defaultPurgeAfter, "grace period after deletion (default: purge_after from the config file, or 720h)")
+	return cmd
+}
+
+// handlePurge lists the recycle bin and, with apply, destroys the aliases
+// that are due.
+func handlePurge(client *FastmailClient, grace time.Duration, apply bool) error {
+	bin, err := loadRecycleBin()
+	if err != nil {
+		return fmt.Errorf("failed to read the recycle bin: %w", err)
+	}
+	if len(bin) == 0 {
+		if porcelain == "" {
+			fmt.Println("The recycle bin is empty")
+		}
+		return nil
+	}
+
+	aliases, err := fetchAllAliasesWithProgress(client)
+	if err != nil {
+		return formatAPIError("failed to get aliases", err)
+	}
+	items := planPurge(bin, aliases, grace, time.Now())
+
+	var due []string
+	for _, item := range items {
+		if item.Outcome == purgeDue {
+			due = append(due, item.ID)
+		}
+	}
+	if apply && len(due) > 0 {
+		if err := confirmBulk("destroy", len(due), ""); err != nil {
+			return err
+		}
+	}
+	var failures int
+	if apply && len(due) > 0 {
+		result, err := client.DestroyAliases(due)
+		destroyed := make(map[string]bool, len(result.Destroyed))
+		for _, id := range result.Destroyed {
+			destroyed[id] = true
+		}
+		for i := range items {
+			item := &items[i]
+			if item.Outcome != purgeDue {
+				continue
+			}
+			switch setErr, failed := result.Failed[item.ID]; {
+			case destroyed[item.ID]:
+				item.Outcome = purgePurged
+			case failed:
+				item.Outcome, item.Reason = purgeFailed, setErr.String()
+				failures++
+			}
+		}
+		if err != nil {
+			updateRecycleBin(items)
+			return formatAPIError("failed to destroy aliases", err)
+		}
+	}
+	updateRecycleBin(items)
+
+	if porcelain != "" {
+		// email, deletedAt (RFC 3339), outcome (waiting, due, purged, failed, restored or gone), reason
+		for _, item := range items {
+			printPorcelain(item.Email, item.DeletedAt.Format(time.RFC3339), item.Outcome, item.Reason)
+		}
+	} else {
+		printPurge(items, apply)
+	}
+	if failures > 0 {
+		return fmt.Errorf("%d of %d aliases could not be destroyed", failures, len(due))
+	}
+	return nil
+}
+
+// printPurge prints the recycle bin and what the purge did.
+func printPurge(items []purgeItem, apply bool) {
+	labels := map[string]string{
+		purgeWaiting:  "waiting",
+		purgeDue:      "due",
+		purgePurged:   "destroyed",
+		purgeFailed:   "failed",
+		purgeRestored: "restored, removed from the bin",
+		purgeGone:     "no longer exists, removed from the bin",
+	}
+	due := 0
+	for _, item := range items {
+		line := fmt.Sprintf("%s  deleted %s  %s", item.Email, formatTime(item.DeletedAt), labels[item.Outcome])
+		if item.Reason != "" {
+			line += " (" + item.Reason + ")"
+		}
+		fmt.Println(line)
+		if item.Outcome == purgeDue {
+			due++
+		}
+	}
+	if !apply && due > 0 {
+		fmt.Fprintf(humanOut, "\n%d aliases are due; run with --apply to destroy them for good\n", due)
+	}
+}
+
+// updateRecycleBin takes the purged, restored and gone aliases out of the
+// recycle bin.
+func updateRecycleBin(items []purgeItem) {
+	bin := recycleBin{}
+	err := updateJSONFile(recycleBinFile, &bin, func() error {
+		for _, item := range items {
+			switch item.Outcome {
+			case purgePurged, purgeRestored, purgeGone:
+				delete(bin, item.Email)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not update the recycle bin: %v\n", err)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecycleBinApply(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	bin := recycleBin{}
+	bin.apply([]MaskedEmailInfo{
+		{ID: "1", Email: "a@fastmail.com", ForDomain: "https://a.com", State: AliasEnabled},
+		{ID: "2", Email: "b@fastmail.com", State: AliasDeleted},
+	}, AliasDeleted, now)
+	if len(bin) != 1 || bin["a@fastmail.com"].PreviousState != AliasEnabled || !bin["a@fastmail.com"].DeletedAt.Equal(now) {
+		t.Fatalf("expected a to be in the bin, got %+v", bin)
+	}
+
+	// Deleting again keeps the original deletion time
+	bin.apply([]MaskedEmailInfo{{ID: "1", Email: "a@fastmail.com", State: AliasDisabled}}, AliasDeleted, now.Add(time.Hour))
+	if !bin["a@fastmail.com"].DeletedAt.Equal(now) {
+		t.Fatalf("expected the deletion time to be kept, got %+v", bin)
+	}
+
+	bin.apply([]MaskedEmailInfo{{ID: "1", Email: "a@fastmail.com", State: AliasDeleted}}, AliasEnabled, now)
+	if len(bin) != 0 {
+		t.Fatalf("expected enabling to take the alias out of the bin, got %+v", bin)
+	}
+}
+
+func TestPlanPurge(t *testing.T) {
+	now := time.Date(2026, 3, 31, 12, 0, 0, 0, time.UTC)
+	bin := recycleBin{
+		"old@fastmail.com":      {ID: "1", DeletedAt: now.Add(-40 * 24 * time.Hour)},
+		"new@fastmail.com":      {ID: "2", DeletedAt: now.Add(-2 * 24 * time.Hour)},
+		"back@fastmail.com":     {ID: "3", DeletedAt: now.Add(-50 * 24 * time.Hour)},
+		"vanished@fastmail.com": {ID: "4", DeletedAt: now.Add(-60 * 24 * time.Hour)},
+	}
+	aliases := []MaskedEmailInfo{
+		{ID: "1", Email: "old@fastmail.com", State: AliasDeleted},
+		{ID: "2", Email: "new@fastmail.com", State: AliasDeleted},
+		{ID: "3", Email: "back@fastmail.com", State: AliasEnabled},
+	}
+
+	var got []string
+	for _, item := range planPurge(bin, aliases, 30*24*time.Hour, now) {
+		got = append(got, strings.TrimSpace(item.Email+" "+item.Outcome+" "+item.Reason))
+	}
+	want := []string{
+		"vanished@fastmail.com gone",
+		"back@fastmail.com restored now enabled",
+		"old@fastmail.com due",
+		"new@fastmail.com waiting due in 4 weeks",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected plan:\n%s", strings.Join(got, "\n"))
+	}
+}
+
+func TestHandlePurge(t *testing.T) {
+	fake, client := newFakeJMAP(t,
+		MaskedEmailInfo{ID: "1", Email: "old@fastmail.com", State: AliasDeleted},
+		MaskedEmailInfo{ID: "2", Email: "new@fastmail.com", State: AliasDeleted},
+	)
+	now := time.Now()
+	bin := recycleBin{
+		"old@fastmail.com": {ID: "1", DeletedAt: now.Add(-40 * 24 * time.Hour)},
+		"new@fastmail.com": {ID: "2", DeletedAt: now.Add(-time.Hour)},
+	}
+	if err := writeJSONFile(recycleBinFile, bin); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := handlePurge(client, defaultPurgeAfter, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(fake.aliases) != 2 {
+		t.Fatalf("expected a dry run to destroy nothing")
+	}
+	if err := handlePurge(client, defaultPurgeAfter, true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := fake.aliases["1"]; ok || len(fake.aliases) != 1 {
+		t.Fatalf("expected only the old alias to be destroyed, got %v", fake.aliases)
+	}
+	bin, err := loadRecycleBin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := bin["old@fastmail.com"]; ok || len(bin) != 1 {
+		t.Errorf("recycle bin = %v; want only the alias that isn't due", bin)
+	}
+}
+
+func TestStateChangesUpdateRecycleBin(t *testing.T) {
+	_, client := newFakeJMAP(t,
+		MaskedEmailInfo{ID: "1", Email: "shop@fastmail.com", ForDomain: "https://shop.example", State: AliasDisabled},
+	)
+
+	before := time.Now()
+	if err := handleStateUpdate(client, "shop@fastmail.com", false, false, true, false); err != nil {
+		t.Fatal(err)
+	}
+	bin, err := loadRecycleBin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, ok := bin["shop@fastmail.com"]
+	if !ok || entry.ID != "1" || entry.PreviousState != AliasDisabled || entry.ForDomain != "https://shop.example" || entry.DeletedAt.Before(before) {
+		t.Fatalf("recycle bin = %v; want the deleted alias with its previous state", bin)
+	}
+
+	if err := handleStateUpdate(client, "shop@fastmail.com", true, false, false, false); err != nil {
+		t.Fatal(err)
+	}
+	if bin, err = loadRecycleBin(); err != nil || len(bin) != 0 {
+		t.Errorf("recycle bin = %v, %v; want it empty after enabling the alias", bin, err)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// regexFields are the alias fields a --regex filter can be limited to with a
+// "field:" prefix
+var regexFields = []string{"email", "domain", "description"}
+
+// aliasFilter is a regular expression matched against alias fields, or a
+// predicate such as the one of --brand.
+type aliasFilter struct {
+	// field limits the filter to one field; empty matches any of them
+	field string
+	re    *regexp.Regexp
+	// match replaces the regular expression when set
+	match func(MaskedEmailInfo) bool
+}
+
+// parseRegexFilters compiles --regex patterns. A pattern may start with
+// "email:", "domain:" or "description:" to match only that field.
+func parseRegexFilters(patterns []string) ([]aliasFilter, error) {
+	filters := make([]aliasFilter, 0, len(patterns))
+	for _, pattern := range patterns {
+		filter := aliasFilter{}
+		for _, field := range regexFields {
+			if rest, ok := strings.CutPrefix(pattern, field+":"); ok {
+				filter.field = field
+				pattern = rest
+				break
+			}
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --regex %q: %w", pattern, err)
+		}
+		filter.re = re
+		filters = append(filters, filter)
+	}
+	return filters, nil
+}
+
+// matches reports whether the filter matches the alias.
+func (f aliasFilter) matches(alias MaskedEmailInfo) bool {
+	if f.match != nil {
+		return f.match(alias)
+	}
+	fields := map[string]string{
+		"email":       alias.Email,
+		"domain":      alias.ForDomain,
+		"description": alias.Description,
+	}
+	if f.field != "" {
+		return f.re.MatchString(fields[f.field])
+	}
+	for _, field := range regexFields {
+		if f.re.MatchString(fields[field]) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesAllFilters reports whether every filter matches the alias.
+func matchesAllFilters(alias MaskedEmailInfo, filters []aliasFilter) bool {
+	for _, filter := range filters {
+		if !filter.matches(alias) {
+			return false
+		}
+	}
+	return true
+}
+
+// filterAliases returns the aliases that are not deleted and match every
+// filter.
+func filterAliases(aliases []MaskedEmailInfo, filters []aliasFilter) []MaskedEmailInfo {
+	var matched []MaskedEmailInfo
+	for _, alias := range aliases {
+		if alias.State != AliasDeleted && matchesAllFilters(alias, filters) {
+			matched = append(matched, alias)
+		}
+	}
+	return matched
+}
@@ -0,0 +1,46 @@
+package main
+
+import "testing"
+
+func TestParseRegexFilters(t *testing.T) {
+	filters, err := parseRegexFilters([]string{"description:^temp-", "shop"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filters[0].field != "description" || filters[0].re.String() != "^temp-" {
+		t.Fatalf("expected a description filter, got %+v", filters[0])
+	}
+	if filters[1].field != "" {
+		t.Fatalf("expected an unprefixed filter to match any field, got %q", filters[1].field)
+	}
+
+	if _, err := parseRegexFilters([]string{"email:("}); err == nil {
+		t.Fatalf("expected an error for an invalid pattern")
+	}
+}
+
+func TestFilterAliases(t *testing.T) {
+	aliases := []MaskedEmailInfo{
+		{Email: "a@fastmail.com", Description: "temp-signup", ForDomain: "https://shop.com", State: AliasEnabled},
+		{Email: "b@fastmail.com", Description: "not temp-", ForDomain: "https://temp-files.com", State: AliasEnabled},
+		{Email: "temp-c@fastmail.com", Description: "temp-old", State: AliasDeleted},
+	}
+
+	filters, err := parseRegexFilters([]string{"description:^temp-"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	matched := filterAliases(aliases, filters)
+	if len(matched) != 1 || matched[0].Email != "a@fastmail.com" {
+		t.Fatalf("expected only the enabled alias with a temp- description, got %+v", matched)
+	}
+
+	filters, err = parseRegexFilters([]string{"temp-", "domain:shop"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	matched = filterAliases(aliases, filters)
+	if len(matched) != 1 || matched[0].Email != "a@fastmail.com" {
+		t.Fatalf("expected all filters to have to match, got %+v", matched)
+	}
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// minServeTokenLength is the shortest token accepted for the HTTP API
+const minServeTokenLength = 16
+
+// defaultRateLimit is the number of HTTP API requests a client may make per
+// minute
+const defaultRateLimit = 60
+
+// httpOptions are the flags of serve that configure the HTTP and gRPC APIs.
+type httpOptions struct {
+	Listen string
+	// GRPCListen is the address of the gRPC API, or empty for none
+	GRPCListen string
+	// Remote allows addresses that other machines can reach
+	Remote     bool
+	TokensFile string
+	TLSCert    string
+	TLSKey     string
+	// RateLimit is the number of requests per minute of each client
+	RateLimit int
+	// Browser lets pages in browsers call the API, for the bookmarklet
+	Browser bool
+	// AccessLog is the file of the access log, "-" for stderr, or empty for
+	// none
+	AccessLog      string
+	AccessLogMaxMB int
+	AccessLogKeep  int
+}
+
+// check validates the combination of flags. Addresses that other machines
+// can reach require --remote, which in turn requires tokens and TLS.
+func (o httpOptions) check() error {
+	invalid := func(format string, args ...interface{}) error {
+		return &exitCodeError{code: exitInvalidInput, err: fmt.Errorf(format, args...)}
+	}
+	if o.Listen == "" && o.GRPCListen == "" {
+		if o.Remote || o.TokensFile != "" || o.TLSCert != "" || o.TLSKey != "" || o.AccessLog != "" || o.Browser {
+			return invalid("--remote, --tokens-file, --tls-cert and --tls-key require --listen or --grpc-listen, and --browser and --access-log require --listen")
+		}
+		return nil
+	}
+	if o.Listen == "" && (o.Browser || o.AccessLog != "") {
+		return invalid("--browser and --access-log require --listen: they only apply to the HTTP API")
+	}
+	if o.Browser && o.TokensFile == "" {
+		return invalid("--browser requires --tokens-file: otherwise any page could use the API")
+	}
+	if o.AccessLog != "" && (o.AccessLogMaxMB < 1 || o.AccessLogKeep < 0) {
+		return invalid("--access-log-max-mb must be at least 1 and --access-log-keep can't be negative")
+	}
+	if (o.TLSCert == "") != (o.TLSKey == "") {
+		return invalid("--tls-cert and --tls-key must be given together")
+	}
+	if o.RateLimit < 1 {
+		return invalid("--rate-limit must be at least 1")
+	}
+	if o.Remote {
+		if o.TokensFile == "" {
+			return invalid("--remote requires --tokens-file: remote clients must authenticate")
+		}
+		if o.TLSCert == "" {
+			return invalid("--remote requires --tls-cert and --tls-key: tokens must not cross the network in clear text")
+		}
+	}
+	for _, addr := range []string{o.Listen, o.GRPCListen} {
+		if addr == "" {
+			continue
+		}
+		if err := checkListenAddress(addr, o.Remote); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scopeLookup restricts a client to getting the alias of a site, e.g. for a
+// token embedded in a bookmarklet
+const scopeLookup = "lookup"
+
+// serveClient is a client allowed to use the HTTP and gRPC APIs.
+type serveClient struct {
+	Name  string
+	Token string
+	// LookupOnly restricts the client to POST /aliases/lookup, and GetAlias
+	// over gRPC
+	LookupOnly bool
+}
+
+// allows reports whether the client may make the request.
+func (c serveClient) allows(r *http.Request) bool {
+	return !c.LookupOnly || (r.Method == http.MethodPost && r.URL.Path == "/aliases/lookup")
+}
+
+// readServeTokens reads the clients allowed to use the HTTP API from a file
+// with one client per line, as "<name> <token> [lookup]" or only "<token>".
+// Blank lines and lines starting with # are ignored.
+func readServeTokens(path string) ([]serveClient, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the tokens: %w", err)
+	}
+	defer f.Close()
+	if info, err := f.Stat(); err == nil && runtime.GOOS != "windows" && info.Mode().Perm()&0o077 != 0 {
+		fmt.Fprintf(os.Stderr, "Warning: %s can be read by other users; restrict it with chmod 600\n", path)
+	}
+
+	var clients []serveClient
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		client := serveClient{Name: "client " + strconv.Itoa(len(clients)+1)}
+		switch len(fields) {
+		case 1:
+			client.Token = fields[0]
+		case 2, 3:
+			client.Name, client.Token = fields[0], fields[1]
+			if len(fields) == 3 {
+				if fields[2] != scopeLookup {
+					return nil, fmt.Errorf("%s line %d: unknown scope %q (expected %s)", path, line, fields[2], scopeLookup)
+				}
+				client.LookupOnly = true
+			}
+		default:
+			return nil, fmt.Errorf("%s line %d: expected \"<name> <token> [%s]\" or \"<token>\"", path, line, scopeLookup)
+		}
+		if len(client.Token) < minServeTokenLength {
+			return nil, fmt.Errorf("%s line %d: tokens must be at least %d characters", path, line, minServeTokenLength)
+		}
+		if seen[client.Token] {
+			return nil, fmt.Errorf("%s line %d: the token of %s is already used by another client", path, line, client.Name)
+		}
+		seen[client.Token] = true
+		clients = append(clients, client)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read the tokens: %w", err)
+	}
+	if len(clients) == 0 {
+		return nil, fmt.Errorf("%s has no tokens", path)
+	}
+	return clients, nil
+}
+
+// authenticate returns the client whose token the request carries as a
+// bearer token.
+func authenticate(r *http.Request, clients []serveClient) (serveClient, bool) {
+	return authenticateBearer(r.Header.Get("Authorization"), clients)
+}
+
+// authenticateBearer returns the client whose token an Authorization value
+// of the form "Bearer <token>" carries. Every token is compared in constant
+// time.
+func authenticateBearer(authorization string, clients []serveClient) (serveClient, bool) {
+	scheme, token, _ := strings.Cut(authorization, " ")
+	if !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return serveClient{}, false
+	}
+	found, ok := serveClient{}, false
+	for _, client := range clients {
+		if subtle.ConstantTimeCompare([]byte(strings.TrimSpace(token)), []byte(client.Token)) == 1 {
+			found, ok = client, true
+		}
+	}
+	return found, ok
+}
+
+// rateLimiter allows each client a number of requests per minute, refilled
+// continuously, with bursts of up to a minute's worth.
+type rateLimiter struct {
+	perMinute int
+	now       func() time.Time
+	mu        sync.Mutex
+	buckets   map[string]*rateBucket
+}
+
+// rateBucket holds the requests a client has left.
+type rateBucket struct {
+	left    float64
+	updated time.Time
+}
+
+// newRateLimiter returns a limiter allowing perMinute requests per minute.
+func newRateLimiter(perMinute int) *rateLimiter {
+	return &rateLimiter{perMinute: perMinute, now: time.Now, buckets: make(map[string]*rateBucket)}
+}
+
+// allow reports whether the client may make a request now, and if not, how
+// long it should wait.
+func (l *rateLimiter) allow(client string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	rate := float64(l.perMinute) / time.Minute.Seconds()
+	bucket, ok := l.buckets[client]
+	if !ok {
+		// Forget clients whose bucket has refilled, so that the map doesn't
+		// grow with every address that ever connected
+		if len(l.buckets) >= 1024 {
+			for key, b := range l.buckets {
+				if b.left+now.Sub(b.updated).Seconds()*rate >= float64(l.perMinute) {
+					delete(l.buckets, key)
+				}
+			}
+		}
+		bucket = &rateBucket{left: float64(l.perMinute), updated: now}
+		l.buckets[client] = bucket
+	}
+	bucket.left = math.Min(float64(l.perMinute), bucket.left+now.Sub(bucket.updated).Seconds()*rate)
+	bucket.updated = now
+	if bucket.left < 1 {
+		return false, time.Duration((1 - bucket.left) / rate * float64(time.Second))
+	}
+	bucket.left--
+	return true, 0
+}
+
+// requireToken lets only requests with the token of a client through, and
+// limits the requests of each client. Failed attempts are limited by
+// address, so that tokens can't be guessed quickly.
+func requireToken(next http.Handler, clients []serveClient, limiter *rateLimiter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client, ok := authenticate(r, clients)
+		key := "client " + client.Name
+		if record := accessRecordOf(r); record != nil && ok {
+			record.Client = client.Name
+		}
+		if !ok {
+			host, _, err := net.SplitHostPort(r.RemoteAddr)
+			if err != nil {
+				host = r.RemoteAddr
+			}
+			key = "address " + host
+		}
+		if allowed, wait := limiter.allow(key); !allowed {
+			noteResult(r, "rateLimited")
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			writeJSON(w, http.StatusTooManyRequests, apiError{Error: errorObject{
+				Type: "rateLimited", Message: "too many requests; try again later", ExitCode: exitFailure,
+			}})
+			return
+		}
+		if !ok {
+			noteResult(r, "auth")
+			w.Header().Set("WWW-Authenticate", `Bearer realm="masked_fastmail"`)
+			writeJSON(w, http.StatusUnauthorized, apiError{Error: errorObject{
+				Type: "auth", Message: "missing or invalid bearer token", ExitCode: exitAuth,
+			}})
+			return
+		}
+		if !client.allows(r) {
+			noteResult(r, "forbidden")
+			writeJSON(w, http.StatusForbidden, apiError{Error: errorObject{
+				Type: "forbidden", Message: fmt.Sprintf("the token of %s only allows POST /aliases/lookup", client.Name), ExitCode: exitFailure,
+			}})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
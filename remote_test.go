@@ -0,0 +1,147 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHTTPOptionsCheck(t *testing.T) {
+	remote := httpOptions{Listen: "0.0.0.0:8787", Remote: true, TokensFile: "tokens", TLSCert: "c", TLSKey: "k", RateLimit: 60}
+	valid := []httpOptions{
+		{},
+		{Listen: "127.0.0.1:8787", RateLimit: 60},
+		{Listen: "127.0.0.1:8787", TokensFile: "tokens", RateLimit: 60},
+		{GRPCListen: "127.0.0.1:8788", TokensFile: "tokens", RateLimit: 60},
+		remote,
+	}
+	for _, opts := range valid {
+		if err := opts.check(); err != nil {
+			t.Errorf("%+v: %v", opts, err)
+		}
+	}
+
+	invalid := map[string]httpOptions{
+		"require --listen":        {Remote: true},
+		"must be given together":  {Listen: "127.0.0.1:8787", TLSCert: "c", RateLimit: 60},
+		"--rate-limit":            {Listen: "127.0.0.1:8787"},
+		"requires --tokens-file":  {Listen: "0.0.0.0:8787", Remote: true, TLSCert: "c", TLSKey: "k", RateLimit: 60},
+		"requires --tls-cert":     {Listen: "0.0.0.0:8787", Remote: true, TokensFile: "tokens", RateLimit: 60},
+		"only loopback addresses": {Listen: "0.0.0.0:8787", TokensFile: "tokens", TLSCert: "c", TLSKey: "k", RateLimit: 60},
+		"invalid listen address":  {Listen: "8787", RateLimit: 60},
+		"--access-log-max-mb":     {Listen: "127.0.0.1:8787", RateLimit: 60, AccessLog: "access.log"},
+		"--browser requires":      {Listen: "127.0.0.1:8787", RateLimit: 60, Browser: true},
+		"only apply to the HTTP":  {GRPCListen: "127.0.0.1:8788", RateLimit: 60, AccessLog: "access.log"},
+		"without --remote":        {Listen: "127.0.0.1:8787", GRPCListen: "0.0.0.0:8788", RateLimit: 60},
+	}
+	for want, opts := range invalid {
+		err := opts.check()
+		if exitCode(err) != exitInvalidInput || !strings.Contains(err.Error(), want) {
+			t.Errorf("%+v: got %v, want an error about %q", opts, err, want)
+		}
+	}
+}
+
+func TestReadServeTokens(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens")
+	content := "# devices\nphone 0123456789abcdef\n\nfedcba9876543210\nbrowser abcdef0123456789 lookup\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	clients, err := readServeTokens(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []serveClient{
+		{Name: "phone", Token: "0123456789abcdef"},
+		{Name: "client 2", Token: "fedcba9876543210"},
+		{Name: "browser", Token: "abcdef0123456789", LookupOnly: true},
+	}
+	if len(clients) != len(want) || clients[0] != want[0] || clients[1] != want[1] || clients[2] != want[2] {
+		t.Errorf("readServeTokens() = %v, want %v", clients, want)
+	}
+
+	for content, problem := range map[string]string{
+		"phone short\n": "at least 16 characters",
+		"a 0123456789abcdef\nb 0123456789abcdef\n": "already used",
+		"one two three\n":                          "unknown scope",
+		"one two three four\n":                     "expected",
+		"# nothing\n":                              "has no tokens",
+	} {
+		os.WriteFile(path, []byte(content), 0o600)
+		if _, err := readServeTokens(path); err == nil || !strings.Contains(err.Error(), problem) {
+			t.Errorf("readServeTokens(%q) = %v, want an error about %q", content, err, problem)
+		}
+	}
+}
+
+func TestRequireToken(t *testing.T) {
+	clients := []serveClient{{Name: "phone", Token: "0123456789abcdef"}}
+	limiter := newRateLimiter(2)
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	limiter.now = func() time.Time { return now }
+	handler := requireToken(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}), clients, limiter)
+
+	request := func(auth string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/aliases", nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := request(""); rec.Code != http.StatusUnauthorized || rec.Header().Get("WWW-Authenticate") == "" {
+		t.Errorf("no token: %d", rec.Code)
+	}
+	if rec := request("Bearer wrong-token-000000"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("wrong token: %d", rec.Code)
+	}
+	// The failed attempts used up the requests of the address
+	if rec := request("Bearer nope"); rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "30" {
+		t.Errorf("third failed attempt: %d, Retry-After %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+
+	// The client has its own allowance
+	for i := 0; i < 2; i++ {
+		if rec := request("Bearer 0123456789abcdef"); rec.Code != http.StatusNoContent {
+			t.Fatalf("request %d: %d", i+1, rec.Code)
+		}
+	}
+	if rec := request("bearer 0123456789abcdef"); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("over the limit: %d", rec.Code)
+	}
+	now = now.Add(30 * time.Second)
+	if rec := request("Bearer 0123456789abcdef"); rec.Code != http.StatusNoContent {
+		t.Errorf("after the limit refilled: %d", rec.Code)
+	}
+}
+
+func TestRequireTokenLookupScope(t *testing.T) {
+	clients := []serveClient{{Name: "browser", Token: "0123456789abcdef", LookupOnly: true}}
+	handler := requireToken(newAPIHandler(stubAliases{}), clients, newRateLimiter(60))
+	request := func(method, path, body string) int {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer 0123456789abcdef")
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	if code := request("POST", "/aliases/lookup", `{"site": "example.com"}`); code != http.StatusOK {
+		t.Errorf("lookup = %d", code)
+	}
+	if code := request("GET", "/aliases", ""); code != http.StatusForbidden {
+		t.Errorf("list = %d; want 403", code)
+	}
+	if code := request("PUT", "/aliases/a@fastmail.com/state", `{"state": "enabled"}`); code != http.StatusForbidden {
+		t.Errorf("update = %d; want 403", code)
+	}
+}
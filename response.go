@@ -0,0 +1,203 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// defaultMaxResponseMB is the largest API response read unless the config
+// file sets max_response_mb.
+const defaultMaxResponseMB = 64
+
+// maxResponseMB is the response size limit selected in the config file.
+var maxResponseMB int64 = defaultMaxResponseMB
+
+// ErrResponseTooLarge is returned when an API response exceeds the maximum
+// response size.
+var ErrResponseTooLarge = errors.New("response too large")
+
+// responseSizeError explains which limit a response exceeded and how to
+// raise it.
+func responseSizeError(limit int64) error {
+	return fmt.Errorf("%w: the API response exceeds %d MiB (raise max_response_mb in the config file to allow larger responses)",
+		ErrResponseTooLarge, limit>>20)
+}
+
+// sizeLimitedReader reads from r until more than limit bytes have been read,
+// at which point it fails with ErrResponseTooLarge. Unlike io.LimitReader, a
+// response that is too large is an error rather than silently truncated.
+type sizeLimitedReader struct {
+	r         io.Reader
+	limit     int64
+	remaining int64
+}
+
+func newSizeLimitedReader(r io.Reader, limit int64) *sizeLimitedReader {
+	return &sizeLimitedReader{r: r, limit: limit, remaining: limit}
+}
+
+func (l *sizeLimitedReader) Read(p []byte) (int, error) {
+	if l.remaining <= 0 {
+		// Only fail if there is more data, so a response of exactly the
+		// limit is accepted
+		var probe [1]byte
+		n, err := l.r.Read(probe[:])
+		if n > 0 {
+			return 0, responseSizeError(l.limit)
+		}
+		return 0, err
+	}
+	if int64(len(p)) > l.remaining {
+		p = p[:l.remaining]
+	}
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	return n, err
+}
+
+// decodeJSON decodes a single JSON value from r.
+func decodeJSON(r io.Reader, v interface{}) error {
+	err := json.NewDecoder(r).Decode(v)
+	if errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to receive response: empty response body")
+	}
+	return err
+}
+
+// decodeMaskedEmailList decodes a MaskedEmail/get response, returning the
+// aliases from its list and the rest of the response for validation. The
+// aliases are decoded one at a time as they are read, so the response body
+// of a large account is never held in memory as a whole.
+func decodeMaskedEmailList(r io.Reader) ([]MaskedEmailInfo, *JMAPResponse, error) {
+	dec := json.NewDecoder(r)
+	var list []MaskedEmailInfo
+	response := &JMAPResponse{}
+
+	if err := expectDelim(dec, '{'); err != nil {
+		return nil, nil, err
+	}
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return nil, nil, err
+		}
+		switch key {
+		case "methodResponses":
+			if err := expectDelim(dec, '['); err != nil {
+				return nil, nil, err
+			}
+			for dec.More() {
+				methodResponse, err := decodeMethodResponse(dec, &list)
+				if err != nil {
+					return nil, nil, err
+				}
+				response.MethodResponses = append(response.MethodResponses, methodResponse)
+			}
+			if err := expectDelim(dec, ']'); err != nil {
+				return nil, nil, err
+			}
+		case "sessionState":
+			if err := dec.Decode(&response.SessionState); err != nil {
+				return nil, nil, err
+			}
+		case "methodErrors":
+			if err := dec.Decode(&response.MethodErrors); err != nil {
+				return nil, nil, err
+			}
+		default:
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	if err := expectDelim(dec, '}'); err != nil {
+		return nil, nil, err
+	}
+	return list, response, nil
+}
+
+// decodeMethodResponse decodes one [name, arguments, callId] method response.
+// The list of a MaskedEmail/get response is appended to list and replaced by
+// empty arguments; other method responses, including errors, are kept as is.
+func decodeMethodResponse(dec *json.Decoder, list *[]MaskedEmailInfo) ([]json.RawMessage, error) {
+	if err := expectDelim(dec, '['); err != nil {
+		return nil, err
+	}
+
+	var methodResponse []json.RawMessage
+	var name string
+	for i := 0; dec.More(); i++ {
+		if i == 1 && name == methodGet {
+			if err := decodeGetArguments(dec, list); err != nil {
+				return nil, err
+			}
+			methodResponse = append(methodResponse, json.RawMessage(`{}`))
+			continue
+		}
+
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			// A malformed name is reported by validateJMAPResponse
+			_ = json.Unmarshal(raw, &name)
+		}
+		methodResponse = append(methodResponse, raw)
+	}
+	return methodResponse, expectDelim(dec, ']')
+}
+
+// decodeGetArguments decodes the arguments of a MaskedEmail/get response,
+// appending each alias in its list to list.
+func decodeGetArguments(dec *json.Decoder, list *[]MaskedEmailInfo) error {
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		if key != "list" {
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := expectDelim(dec, '['); err != nil {
+			return err
+		}
+		for dec.More() {
+			var alias MaskedEmailInfo
+			if err := dec.Decode(&alias); err != nil {
+				return err
+			}
+			*list = append(*list, alias)
+		}
+		if err := expectDelim(dec, ']'); err != nil {
+			return err
+		}
+	}
+	return expectDelim(dec, '}')
+}
+
+// expectDelim reads the next token and checks that it is the given delimiter.
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	token, err := dec.Token()
+	if errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to receive response: empty or truncated response body")
+	}
+	if err != nil {
+		return err
+	}
+	if token != delim {
+		return fmt.Errorf("unexpected %v in response, expected %v", token, delim)
+	}
+	return nil
+}
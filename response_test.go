@@ -0,0 +1,62 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestSizeLimitedReader(t *testing.T) {
+	data, err := io.ReadAll(newSizeLimitedReader(strings.NewReader("12345"), 5))
+	if err != nil || string(data) != "12345" {
+		t.Fatalf("expected a response of exactly the limit to be read, got %q, %v", data, err)
+	}
+
+	_, err = io.ReadAll(newSizeLimitedReader(strings.NewReader("123456"), 5))
+	if !errors.Is(err, ErrResponseTooLarge) {
+		t.Fatalf("expected ErrResponseTooLarge, got %v", err)
+	}
+}
+
+func TestDecodeMaskedEmailList(t *testing.T) {
+	body := `{
+		"sessionState": "abc",
+		"methodResponses": [
+			["MaskedEmail/get", {"accountId": "u1", "list": [
+				{"id": "1", "email": "one@fastmail.com", "state": "enabled"},
+				{"id": "2", "email": "two@fastmail.com", "state": "disabled", "forDomain": "https://example.com"}
+			], "notFound": []}, "0"]
+		]
+	}`
+
+	list, response, err := decodeMaskedEmailList(strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("decodeMaskedEmailList returned error: %v", err)
+	}
+	if len(list) != 2 || list[1].Email != "two@fastmail.com" || list[1].ForDomain != "https://example.com" {
+		t.Fatalf("unexpected aliases: %+v", list)
+	}
+	if len(response.MethodResponses) != 1 || len(response.MethodResponses[0]) != 3 {
+		t.Fatalf("expected the method response to be kept for validation, got %v", response.MethodResponses)
+	}
+}
+
+func TestDecodeMaskedEmailListError(t *testing.T) {
+	body := `{"methodResponses": [["error", {"type": "accountNotFound"}, "0"]]}`
+
+	list, response, err := decodeMaskedEmailList(strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("decodeMaskedEmailList returned error: %v", err)
+	}
+	if len(list) != 0 {
+		t.Fatalf("expected no aliases, got %+v", list)
+	}
+	if string(response.MethodResponses[0][1]) != `{"type": "accountNotFound"}` {
+		t.Fatalf("expected the error arguments to be kept, got %s", response.MethodResponses[0][1])
+	}
+
+	if _, _, err := decodeMaskedEmailList(strings.NewReader(`{"methodResponses": [`)); err == nil {
+		t.Fatalf("expected an error for a truncated response")
+	}
+}
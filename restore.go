@@ -0,0 +1,269 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// restoreScopes lists the values of restore --only
+var restoreScopes = []string{"descriptions", "states"}
+
+// restoreStep is one field of an alias to set back to its value in a backup.
+type restoreStep struct {
+	Alias MaskedEmailInfo
+	fieldChange
+	// Skip explains why the step cannot be applied; empty if it can
+	Skip string
+}
+
+// restorePlan is what restoring a backup would change.
+type restorePlan struct {
+	Steps []restoreStep
+	// Missing are aliases in the backup that no longer exist. Masked email
+	// addresses cannot be recreated, so they are only reported.
+	Missing []MaskedEmailInfo
+	// Extra is the number of aliases created since the backup, which are
+	// left alone
+	Extra int
+}
+
+// Updates returns the MaskedEmail updates that apply the plan, keyed by
+// alias ID.
+func (p restorePlan) Updates() map[string]MaskedEmailUpdate {
+	updates := make(map[string]MaskedEmailUpdate)
+	for _, step := range p.Steps {
+		if step.Skip != "" {
+			continue
+		}
+		update := updates[step.Alias.ID]
+		value := step.New
+		switch step.Field {
+		case fieldState:
+			state := AliasState(value)
+			update.State = &state
+		case fieldDescription:
+			update.Description = &value
+		}
+		updates[step.Alias.ID] = update
+	}
+	return updates
+}
+
+// planRestore works out how to bring the current aliases back to the backup.
+// Only descriptions and states are restored; only restricts the plan to one
+// of them. Protected aliases are not deleted unless force is set.
+func planRestore(backup, current []MaskedEmailInfo, only string, metadata map[string]AliasMetadata, force bool) restorePlan {
+	// The diff runs from the account to the backup, so New is the value to restore
+	diff := diffAliases(current, backup)
+	plan := restorePlan{Missing: diff.Added, Extra: len(diff.Removed)}
+
+	for _, change := range diff.Changed {
+		for _, field := range change.Changes {
+			step := restoreStep{Alias: change.Old, fieldChange: field}
+			switch {
+			case field.Field == fieldDomain:
+				continue
+			case only == "descriptions" && field.Field != fieldDescription,
+				only == "states" && field.Field != fieldState:
+				continue
+			case field.Field == fieldState && AliasState(field.New) == AliasPending:
+				step.Skip = "aliases cannot be made pending again"
+			case field.Field == fieldState && AliasState(field.New) == AliasDeleted && !force && isProtected(change.Old, metadata):
+				step.Skip = "protected (use --force to delete it)"
+			}
+			plan.Steps = append(plan.Steps, step)
+		}
+	}
+	sort.SliceStable(plan.Steps, func(i, j int) bool { return plan.Steps[i].Alias.Email < plan.Steps[j].Alias.Email })
+	return plan
+}
+
+// newRestoreCmd creates the command that restores descriptions and states
+// from an export, or restores deleted aliases.
+func newRestoreCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "restore <export.json> | restore <alias>...",
+		Short: "Restore alias descriptions and states from a JSON export, or restore deleted aliases",
+		Long: `Bring the account back to a JSON export: restore the descriptions and states of
+aliases that changed since the export was taken. Without --apply, only the
+changes that would be made are shown.
+
+Aliases deleted since the export can't be recreated with the same address, and
+aliases created since are left alone; both are reported. Protected aliases are
+not deleted unless --force is given.
+
+Given alias emails instead, restore enables the aliases that are deleted, after
+asking for confirmation with how long ago they were deleted. The time is known
+for aliases deleted with this tool, from the recycle bin.`,
+		Example: `  masked_fastmail restore backup.json
+  masked_fastmail restore backup.json --only descriptions --apply
+  masked_fastmail restore user.1234@fastmail.com`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			only, _ := cmd.Flags().GetString("only")
+			apply, _ := cmd.Flags().GetBool("apply")
+			force, _ := cmd.Flags().GetBool("force")
+			if restoresAliases(args) {
+				if only != "" || apply || force {
+					return &exitCodeError{code: exitInvalidInput, err: fmt.Errorf("--only, --apply and --force only apply to restoring an export")}
+				}
+			} else if len(args) > 1 {
+				return &exitCodeError{code: exitInvalidInput, err: fmt.Errorf("expected one export file or alias emails, got %d arguments", len(args))}
+			}
+			client, err := newClientFromCmd(cmd)
+			if err != nil {
+				return err
+			}
+			if restoresAliases(args) {
+				return handleUndelete(client, args)
+			}
+			return handleRestore(client, args[0], only, apply, force)
+		},
+	}
+	cmd.Flags().String("only", "", "restore only "+strings.Join(restoreScopes, " or "))
+	cmd.Flags().Bool("apply", false, "apply the changes instead of only showing them")
+	cmd.Flags().Bool("force", false, "also delete protected aliases that are deleted in the export")
+	return cmd
+}
+
+// restoresAliases reports whether the arguments of restore are alias emails
+// rather than an export file. An existing file is always an export.
+func restoresAliases(args []string) bool {
+	for _, arg := range args {
+		if !looksLikeEmail(arg) {
+			return false
+		}
+		if _, err := os.Stat(arg); err == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// handleRestore shows, and with apply makes, the changes that restore the
+// account to an export.
+func handleRestore(client *FastmailClient, path, only string, apply, force bool) error {
+	only = strings.ToLower(strings.TrimSpace(only))
+	if only != "" && only != "descriptions" && only != "states" {
+		return fmt.Errorf("unknown --only value %q (expected one of: %s)", only, strings.Join(restoreScopes, ", "))
+	}
+
+	export, err := loadExport(path)
+	if err != nil {
+		return err
+	}
+	if export.AccountID != "" && export.AccountID != client.AccountID {
+		return fmt.Errorf("%s was exported from account %s, not %s; select it with --account", path, export.AccountID, client.AccountID)
+	}
+
+	aliases, err := fetchAllAliasesWithProgress(client)
+	if err != nil {
+		return formatAPIError("failed to get aliases", err)
+	}
+	metadata, err := loadMetadata()
+	if err != nil {
+		return fmt.Errorf("failed to read alias metadata: %w", err)
+	}
+	plan := planRestore(export.Aliases, aliases, only, metadata, force)
+
+	var result *BatchResult
+	if apply {
+		ctx, stop := withInterrupt()
+		defer stop()
+		result, err = client.UpdateAliases(ctx, plan.Updates(), nil)
+	}
+
+	if porcelain != "" {
+		printRestorePorcelain(plan, apply, result)
+	} else {
+		printRestorePlan(path, plan, apply, result)
+	}
+
+	if errors.Is(err, ErrInterrupted) {
+		return err
+	}
+	if err != nil {
+		return formatAPIError("failed to restore aliases", err)
+	}
+	if result != nil && len(result.Failed) > 0 {
+		return fmt.Errorf("%d aliases could not be restored", len(result.Failed))
+	}
+	return nil
+}
+
+// restoreOutcome returns the outcome of a step: planned, updated, skipped,
+// failed or pending (not sent because the run stopped), with a reason.
+func restoreOutcome(step restoreStep, apply bool, result *BatchResult) (string, string) {
+	switch {
+	case step.Skip != "":
+		return "skipped", step.Skip
+	case !apply:
+		return "planned", ""
+	case result == nil:
+		return "pending", ""
+	}
+	if setErr, ok := result.Failed[step.Alias.ID]; ok {
+		return "failed", setErr.String()
+	}
+	for _, id := range result.Updated {
+		if id == step.Alias.ID {
+			return "updated", ""
+		}
+	}
+	return "pending", ""
+}
+
+// printRestorePlan prints the restore steps in the format of diff, with the
+// outcome of each step once applied.
+func printRestorePlan(path string, plan restorePlan, apply bool, result *BatchResult) {
+	if len(plan.Steps) == 0 {
+		fmt.Printf("Nothing to restore from %s\n", path)
+	} else if apply {
+		fmt.Printf("Restoring from %s:\n", path)
+	} else {
+		fmt.Printf("Restoring from %s would change:\n", path)
+	}
+	for _, step := range plan.Steps {
+		line := fmt.Sprintf("~ %s %s: %s -> %s", step.Alias.Email, step.Field, strconv.Quote(step.Old), strconv.Quote(step.New))
+		outcome, reason := restoreOutcome(step, apply, result)
+		switch outcome {
+		case "skipped", "failed":
+			line += fmt.Sprintf("  (%s: %s)", outcome, reason)
+		case "pending":
+			line += "  (not applied)"
+		}
+		fmt.Println(line)
+	}
+
+	if len(plan.Missing) > 0 {
+		fmt.Fprintf(os.Stderr, "\n%d aliases in the export no longer exist and can't be recreated:\n", len(plan.Missing))
+		for _, alias := range plan.Missing {
+			fmt.Fprintf(os.Stderr, "- %s (%s)\n", alias.Email, alias.ForDomain)
+		}
+	}
+	if plan.Extra > 0 {
+		fmt.Fprintf(os.Stderr, "%d aliases created since the export were left alone\n", plan.Extra)
+	}
+	if !apply && len(plan.Updates()) > 0 {
+		fmt.Fprintln(os.Stderr, "\nRun again with --apply to make these changes.")
+	}
+}
+
+// printRestorePorcelain prints one record per restore step and per missing
+// alias.
+func printRestorePorcelain(plan restorePlan, apply bool, result *BatchResult) {
+	// email, field, current value, restored value, outcome, reason
+	for _, step := range plan.Steps {
+		outcome, reason := restoreOutcome(step, apply, result)
+		printPorcelain(step.Alias.Email, step.Field, step.Old, step.New, outcome, reason)
+	}
+	for _, alias := range plan.Missing {
+		printPorcelain(alias.Email, "", "", "", "missing", "the alias no longer exists")
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import "testing"
+
+func TestPlanRestore(t *testing.T) {
+	backup := []MaskedEmailInfo{
+		{ID: "1", Email: "renamed@fastmail.com", State: AliasEnabled, Description: "Shop"},
+		{ID: "2", Email: "disabled@fastmail.com", State: AliasEnabled, Description: "Bank"},
+		{ID: "3", Email: "gone@fastmail.com", State: AliasEnabled},
+		{ID: "4", Email: "used@fastmail.com", State: AliasPending},
+		{ID: "5", Email: "bank@fastmail.com", State: AliasDeleted, Description: "Bank [protected]"},
+	}
+	current := []MaskedEmailInfo{
+		{ID: "1", Email: "renamed@fastmail.com", State: AliasEnabled, Description: "Shopping"},
+		{ID: "2", Email: "disabled@fastmail.com", State: AliasDisabled, Description: "Bank"},
+		{ID: "4", Email: "used@fastmail.com", State: AliasEnabled},
+		{ID: "5", Email: "bank@fastmail.com", State: AliasEnabled, Description: "Bank [protected]"},
+		{ID: "6", Email: "new@fastmail.com", State: AliasEnabled},
+	}
+
+	plan := planRestore(backup, current, "", nil, false)
+	if len(plan.Missing) != 1 || plan.Missing[0].Email != "gone@fastmail.com" || plan.Extra != 1 {
+		t.Fatalf("unexpected missing or extra aliases: %+v, %d", plan.Missing, plan.Extra)
+	}
+	skipped := map[string]bool{}
+	for _, step := range plan.Steps {
+		skipped[step.Alias.Email] = step.Skip != ""
+	}
+	want := map[string]bool{
+		"renamed@fastmail.com":  false,
+		"disabled@fastmail.com": false,
+		"used@fastmail.com":     true,
+		"bank@fastmail.com":     true,
+	}
+	if len(skipped) != len(want) {
+		t.Fatalf("unexpected steps: %+v", plan.Steps)
+	}
+	for email, skip := range want {
+		if skipped[email] != skip {
+			t.Errorf("%s: skipped = %v, want %v", email, skipped[email], skip)
+		}
+	}
+
+	updates := plan.Updates()
+	if len(updates) != 2 || *updates["1"].Description != "Shop" || *updates["2"].State != AliasEnabled {
+		t.Fatalf("unexpected updates: %+v", updates)
+	}
+
+	if forced := planRestore(backup, current, "", nil, true).Updates(); forced["5"].State == nil || *forced["5"].State != AliasDeleted {
+		t.Fatalf("expected --force to delete the protected alias, got %+v", forced["5"])
+	}
+}
+
+func TestPlanRestoreOnly(t *testing.T) {
+	backup := []MaskedEmailInfo{{ID: "1", Email: "a@fastmail.com", State: AliasEnabled, Description: "old"}}
+	current := []MaskedEmailInfo{{ID: "1", Email: "a@fastmail.com", State: AliasDisabled, Description: "new"}}
+
+	for only, field := range map[string]string{"descriptions": fieldDescription, "states": fieldState} {
+		plan := planRestore(backup, current, only, nil, false)
+		if len(plan.Steps) != 1 || plan.Steps[0].Field != field {
+			t.Errorf("--only %s: unexpected steps %+v", only, plan.Steps)
+		}
+	}
+}
+
+func TestRestoreOutcome(t *testing.T) {
+	step := restoreStep{Alias: MaskedEmailInfo{ID: "1"}}
+	result := &BatchResult{Updated: []string{"1"}, Failed: map[string]SetError{"2": {Type: "forbidden"}}}
+
+	if outcome, _ := restoreOutcome(step, false, nil); outcome != "planned" {
+		t.Errorf("dry run: got %s", outcome)
+	}
+	if outcome, _ := restoreOutcome(step, true, result); outcome != "updated" {
+		t.Errorf("applied: got %s", outcome)
+	}
+	failed := restoreStep{Alias: MaskedEmailInfo{ID: "2"}}
+	if outcome, reason := restoreOutcome(failed, true, result); outcome != "failed" || reason != "forbidden" {
+		t.Errorf("failed: got %s, %s", outcome, reason)
+	}
+	if outcome, _ := restoreOutcome(restoreStep{Alias: MaskedEmailInfo{ID: "3"}}, true, result); outcome != "pending" {
+		t.Errorf("not sent: got %s", outcome)
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Search match ranks, best last. A higher rank means the search text was
+// found in a more prominent position.
+const (
+	rankNone = iota
+	// rankFuzzy: the characters of the search text appear in order, with
+	// gaps (e.g. "amzn" in "amazon.com")
+	rankFuzzy
+	// rankSubstring: the search text appears anywhere
+	rankSubstring
+	// rankWordBoundary: the search text starts a word (e.g. "shop" in
+	// "online shop")
+	rankWordBoundary
+	// rankPrefix: the field starts with the search text
+	rankPrefix
+	// rankSubdomain is used for aliases of a subdomain of the listed domain,
+	// which are listed before any text match
+	rankSubdomain
+)
+
+// minFuzzyLength is the shortest search text matched fuzzily; shorter ones
+// would match almost anything.
+const minFuzzyLength = 3
+
+// fuzzySearch enables fuzzy matches in searches; --exact and the
+// fuzzy_search config setting turn it off.
+var fuzzySearch = true
+
+// matchRank returns how well needle matches field. Both are expected in
+// lower case.
+func matchRank(field, needle string, fuzzy bool) int {
+	if field == "" || needle == "" {
+		return rankNone
+	}
+	if strings.HasPrefix(field, needle) {
+		return rankPrefix
+	}
+
+	best := rankNone
+	for offset := 0; ; {
+		i := strings.Index(field[offset:], needle)
+		if i < 0 {
+			break
+		}
+		i += offset
+		r := lastRune(field[:i])
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			return rankWordBoundary
+		}
+		best = rankSubstring
+		offset = i + 1
+	}
+	if best != rankNone {
+		return best
+	}
+
+	if fuzzy && len(needle) >= minFuzzyLength && isCompactSubsequence(field, needle) {
+		return rankFuzzy
+	}
+	return rankNone
+}
+
+// lastRune returns the last rune of s, or a space if s is empty.
+func lastRune(s string) rune {
+	r := ' '
+	for _, c := range s {
+		r = c
+	}
+	return r
+}
+
+// isCompactSubsequence reports whether the runes of needle appear in order in
+// field, spread over at most twice the length of needle, so that a short
+// needle doesn't match every long description.
+func isCompactSubsequence(field, needle string) bool {
+	target := []rune(needle)
+	runes := []rune(field)
+	for start := range runes {
+		if runes[start] != target[0] {
+			continue
+		}
+		matched := 0
+		for i := start; i < len(runes) && i-start < 2*len(target); i++ {
+			if runes[i] == target[matched] {
+				matched++
+				if matched == len(target) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// aliasSearchRank returns the best rank of any of the needles in the alias's
+// email, description, domain or ID.
+func aliasSearchRank(alias MaskedEmailInfo, fuzzy bool, needles ...string) int {
+	fields := []string{
+		strings.ToLower(alias.Email),
+		strings.ToLower(alias.Description),
+		strings.ToLower(alias.ForDomain),
+		strings.ToLower(alias.ID),
+	}
+
+	best := rankNone
+	for _, needle := range needles {
+		needle = strings.ToLower(strings.TrimSpace(needle))
+		if needle == "" {
+			continue
+		}
+		for _, field := range fields {
+			best = max(best, matchRank(field, needle, fuzzy))
+		}
+	}
+	return best
+}
+
+// aliasMatchesSearch reports whether any of the needles matches the alias.
+func aliasMatchesSearch(alias MaskedEmailInfo, needles ...string) bool {
+	return aliasSearchRank(alias, fuzzySearch, needles...) > rankNone
+}
+
+// rankedAlias is an alias with the rank of its search match.
+type rankedAlias struct {
+	alias MaskedEmailInfo
+	rank  int
+}
+
+// sortByRank returns the aliases best match first, keeping the original
+// order among equal ranks.
+func sortByRank(ranked []rankedAlias) []MaskedEmailInfo {
+	sort.SliceStable(ranked, func(i, j int) bool { return ranked[i].rank > ranked[j].rank })
+	aliases := make([]MaskedEmailInfo, len(ranked))
+	for i, r := range ranked {
+		aliases[i] = r.alias
+	}
+	return aliases
+}
@@ -0,0 +1,56 @@
+package main
+
+import "testing"
+
+func TestMatchRank(t *testing.T) {
+	cases := []struct {
+		field, needle string
+		fuzzy         bool
+		want          int
+	}{
+		{"shopping account", "shop", true, rankPrefix},
+		{"online shop", "shop", true, rankWordBoundary},
+		{"https://shop.example.com", "shop", true, rankWordBoundary},
+		{"workshop", "shop", true, rankSubstring},
+		{"the workshop shop", "shop", true, rankWordBoundary},
+		{"https://amazon.com", "amzn", true, rankFuzzy},
+		{"https://amazon.com", "amzn", false, rankNone},
+		{"a very long description mentioning zebras", "amz", true, rankNone},
+		{"example", "ex", true, rankPrefix},
+		{"", "shop", true, rankNone},
+	}
+	for _, c := range cases {
+		if got := matchRank(c.field, c.needle, c.fuzzy); got != c.want {
+			t.Errorf("matchRank(%q, %q, %v) = %d, want %d", c.field, c.needle, c.fuzzy, got, c.want)
+		}
+	}
+}
+
+func TestFilterAliasesForListRanking(t *testing.T) {
+	defer func(saved bool) { fuzzySearch = saved }(fuzzySearch)
+	fuzzySearch = true
+
+	aliases := []MaskedEmailInfo{
+		{ID: "1", Email: "fuzzy@fastmail.com", ForDomain: "https://shxoxp.com", State: AliasEnabled},
+		{ID: "2", Email: "substring@fastmail.com", Description: "Workshop signup", State: AliasEnabled},
+		{ID: "3", Email: "prefix@fastmail.com", Description: "Shop account", State: AliasEnabled},
+		{ID: "4", Email: "boundary@fastmail.com", Description: "Online shop", State: AliasEnabled},
+	}
+
+	_, related := filterAliasesForList(aliases, "https://shop.com", "shop")
+	want := []string{"prefix@fastmail.com", "boundary@fastmail.com", "substring@fastmail.com", "fuzzy@fastmail.com"}
+	if len(related) != len(want) {
+		t.Fatalf("expected %d related matches, got %+v", len(want), related)
+	}
+	for i, email := range want {
+		if related[i].Email != email {
+			t.Fatalf("expected %s at position %d, got %s", email, i, related[i].Email)
+		}
+	}
+
+	fuzzySearch = false
+	_, related = filterAliasesForList(aliases, "https://shop.com", "shop")
+	if len(related) != 3 {
+		t.Fatalf("expected no fuzzy matches with exact search, got %+v", related)
+	}
+}
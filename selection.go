@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// selectionStrategy decides which alias is used when several match a site.
+type selectionStrategy string
+
+const (
+	// selectByState prefers enabled, then pending, disabled and deleted
+	// aliases (the default)
+	selectByState selectionStrategy = "state-priority"
+	// selectByRecentMail prefers the alias that received mail most recently
+	selectByRecentMail selectionStrategy = "most-recent-mail"
+	// selectNewest prefers the alias created last
+	selectNewest selectionStrategy = "newest"
+	// selectOldest prefers the alias created first
+	selectOldest selectionStrategy = "oldest"
+	// selectInteractive asks which alias to use, when it can ask
+	selectInteractive selectionStrategy = "interactive"
+)
+
+var selectionStrategies = []selectionStrategy{selectByState, selectByRecentMail, selectNewest, selectOldest, selectInteractive}
+
+// activeSelection is the strategy selected with selection_strategy or
+// --selection.
+var activeSelection = selectByState
+
+// parseSelectionStrategy checks a strategy name.
+func parseSelectionStrategy(name string) (selectionStrategy, error) {
+	for _, strategy := range selectionStrategies {
+		if string(strategy) == strings.ToLower(strings.TrimSpace(name)) {
+			return strategy, nil
+		}
+	}
+	names := make([]string, len(selectionStrategies))
+	for i, strategy := range selectionStrategies {
+		names[i] = string(strategy)
+	}
+	return "", fmt.Errorf("unknown selection strategy %q (expected one of: %s)", name, strings.Join(names, ", "))
+}
+
+// pick returns the alias the strategy prefers. Every strategy falls back to
+// state priority between aliases it ranks the same, and then to the order
+// Fastmail returned them in. The interactive strategy uses state priority
+// when no question can be asked, as in scripts and serve mode.
+func (s selectionStrategy) pick(aliases []MaskedEmailInfo) *MaskedEmailInfo {
+	if len(aliases) == 0 {
+		return nil
+	}
+	if s == selectInteractive {
+		if len(aliases) > 1 && canPrompt() {
+			return pickAlias(os.Stderr, os.Stdin, aliases, selectByState.pick(aliases))
+		}
+		s = selectByState
+	}
+
+	selected := &aliases[0]
+	for i := 1; i < len(aliases); i++ {
+		if s.prefers(aliases[i], *selected) {
+			selected = &aliases[i]
+		}
+	}
+	return selected
+}
+
+// prefers reports whether the strategy ranks a above b.
+func (s selectionStrategy) prefers(a, b MaskedEmailInfo) bool {
+	switch s {
+	case selectByRecentMail:
+		if at, bt := lastMessageTime(a), lastMessageTime(b); !at.Equal(bt) {
+			return at.After(bt)
+		}
+	case selectNewest:
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.After(b.CreatedAt)
+		}
+	case selectOldest:
+		// Aliases without a creation time are the least known, not the oldest
+		if a.CreatedAt.IsZero() != b.CreatedAt.IsZero() {
+			return b.CreatedAt.IsZero()
+		}
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+	}
+	return getStatePriority(a.State) < getStatePriority(b.State)
+}
+
+// lastMessageTime returns when the alias last received mail, or the zero
+// time if it never did.
+func lastMessageTime(alias MaskedEmailInfo) time.Time {
+	if alias.LastMessageAt == nil {
+		return time.Time{}
+	}
+	return *alias.LastMessageAt
+}
+
+// pickAlias lists the aliases on out and asks which one to use, reading the
+// answer from in. An empty answer, the end of the input, or no answer within
+// promptTimeout selects fallback.
+func pickAlias(out io.Writer, in io.Reader, aliases []MaskedEmailInfo, fallback *MaskedEmailInfo) *MaskedEmailInfo {
+	defaultChoice := 1
+	for i, alias := range aliases {
+		if fallback != nil && alias.ID == fallback.ID {
+			defaultChoice = i + 1
+		}
+		lastMessage := "never received mail"
+		if alias.LastMessageAt != nil {
+			lastMessage = "last message " + formatTime(*alias.LastMessageAt)
+		}
+		fmt.Fprintf(out, "%2d) %s (%s) for %s, %s\n", i+1, alias.Email, themedState(alias.State), describeAliasOrigin(alias), lastMessage)
+	}
+
+	reader := bufio.NewReader(in)
+	for {
+		fmt.Fprintf(out, "Use which alias? [1-%d, default %d] ", len(aliases), defaultChoice)
+		answer, err := readAnswer(reader)
+		if errors.Is(err, errPromptTimeout) {
+			fmt.Fprintf(out, "\nNo answer within %s; using %d\n", promptTimeout, defaultChoice)
+			return &aliases[defaultChoice-1]
+		}
+		answer = strings.TrimSpace(answer)
+		if answer == "" {
+			if err != nil {
+				fmt.Fprintln(out)
+			}
+			return &aliases[defaultChoice-1]
+		}
+		if n, convErr := strconv.Atoi(answer); convErr == nil && n >= 1 && n <= len(aliases) {
+			return &aliases[n-1]
+		}
+		if err != nil {
+			fmt.Fprintln(out)
+			return &aliases[defaultChoice-1]
+		}
+		fmt.Fprintf(out, "Enter a number from 1 to %d\n", len(aliases))
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func selectionTestAliases() []MaskedEmailInfo {
+	day := func(d int) time.Time { return time.Date(2024, 6, d, 0, 0, 0, 0, time.UTC) }
+	mail := day(20)
+	return []MaskedEmailInfo{
+		{ID: "1", Email: "enabled-mid@fastmail.com", State: AliasEnabled, CreatedAt: day(10)},
+		{ID: "2", Email: "disabled-mail@fastmail.com", State: AliasDisabled, CreatedAt: day(5), LastMessageAt: &mail},
+		{ID: "3", Email: "pending-new@fastmail.com", State: AliasPending, CreatedAt: day(15)},
+		{ID: "4", Email: "unknown-created@fastmail.com", State: AliasDisabled},
+	}
+}
+
+func TestSelectionStrategies(t *testing.T) {
+	tests := map[selectionStrategy]string{
+		selectByState:      "enabled-mid@fastmail.com",
+		selectByRecentMail: "disabled-mail@fastmail.com",
+		selectNewest:       "pending-new@fastmail.com",
+		selectOldest:       "disabled-mail@fastmail.com",
+		// Without a terminal, interactive falls back to state priority
+		selectInteractive: "enabled-mid@fastmail.com",
+	}
+	for strategy, want := range tests {
+		if got := strategy.pick(selectionTestAliases()); got == nil || got.Email != want {
+			t.Errorf("%s picked %v, want %s", strategy, got, want)
+		}
+	}
+}
+
+func TestSelectionFallsBackToStatePriority(t *testing.T) {
+	// Neither alias received mail, so the enabled one wins
+	aliases := []MaskedEmailInfo{
+		{Email: "disabled@fastmail.com", State: AliasDisabled},
+		{Email: "enabled@fastmail.com", State: AliasEnabled},
+	}
+	if got := selectByRecentMail.pick(aliases); got.Email != "enabled@fastmail.com" {
+		t.Errorf("picked %s, want enabled@fastmail.com", got.Email)
+	}
+}
+
+func TestParseSelectionStrategy(t *testing.T) {
+	if strategy, err := parseSelectionStrategy(" Newest "); err != nil || strategy != selectNewest {
+		t.Errorf("parseSelectionStrategy() = %q, %v", strategy, err)
+	}
+	if _, err := parseSelectionStrategy("random"); err == nil || !strings.Contains(err.Error(), "most-recent-mail") {
+		t.Errorf("expected an error listing the strategies, got %v", err)
+	}
+}
+
+func TestPickAlias(t *testing.T) {
+	aliases := selectionTestAliases()
+	fallback := &aliases[0]
+	tests := []struct {
+		answer string
+		want   string
+	}{
+		{"3\n", "pending-new@fastmail.com"},
+		{"\n", "enabled-mid@fastmail.com"},
+		{"", "enabled-mid@fastmail.com"},
+		{"9\n2\n", "disabled-mail@fastmail.com"},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		if got := pickAlias(&out, strings.NewReader(tt.answer), aliases, fallback); got.Email != tt.want {
+			t.Errorf("answer %q picked %s, want %s", tt.answer, got.Email, tt.want)
+		}
+		if !strings.Contains(out.String(), " 2) disabled-mail@fastmail.com (disabled)") {
+			t.Errorf("the aliases aren't listed:\n%s", out.String())
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// sendAsSteps are the manual steps for sending mail from an alias, for when
+// the identity cannot be created through the API.
+func sendAsSteps(email string) []string {
+	return []string{
+		"In Fastmail, open Settings > Sending identities",
+		fmt.Sprintf("Choose Add identity and enter %s as the email address", email),
+		fmt.Sprintf("When replying to mail sent to %s, pick it in the From menu", email),
+	}
+}
+
+// printSendAsSteps prints the manual steps for sending mail from an alias.
+func printSendAsSteps(email string) {
+	fmt.Fprintf(os.Stderr, "To send mail from %s:\n", email)
+	for i, step := range sendAsSteps(email) {
+		fmt.Fprintf(os.Stderr, "  %d. %s\n", i+1, step)
+	}
+}
+
+// newSendAsCmd creates the command that sets up sending mail from an alias.
+func newSendAsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "send-as <alias>",
+		Short: "Set up replying from a masked alias by creating a sending identity for it",
+		Long: `Set up replying from a masked alias. Replies are sent from one of the account's
+sending identities, so unless the alias is one of them, replying reveals another
+address. This creates a sending identity for the alias if the API token allows
+it, and otherwise prints the steps to add one in Fastmail.
+Requires an API token with access to sending mail (not just masked email).`,
+		Example: `  masked_fastmail send-as user.1234@fastmail.com --name "Jane Doe"
+  masked_fastmail send-as user.1234@fastmail.com --instructions`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name, _ := cmd.Flags().GetString("name")
+			instructionsOnly, _ := cmd.Flags().GetBool("instructions")
+			client, err := newClientFromCmd(cmd)
+			if err != nil {
+				return err
+			}
+			return handleSendAs(client, args[0], name, instructionsOnly)
+		},
+	}
+	cmd.Flags().String("name", "", "display name of the new sending identity")
+	cmd.Flags().Bool("instructions", false, "only print the steps to add the identity in Fastmail")
+	return cmd
+}
+
+// handleSendAs makes sure the account can send as the alias, creating a
+// sending identity for it unless instructionsOnly is set or the client is
+// read-only, in which case the manual steps are printed instead.
+func handleSendAs(client *FastmailClient, identifier, name string, instructionsOnly bool) error {
+	email, err := normalizeEmailInput(identifier)
+	if err != nil {
+		return err
+	}
+
+	alias, err := client.GetAliasByEmail(email)
+	if err != nil {
+		return formatAPIError("failed to get alias", err)
+	}
+	if alias.State == AliasDeleted {
+		return fmt.Errorf("alias %s is deleted; restore it before sending from it", alias.Email)
+	}
+
+	identities, err := client.GetIdentities()
+	if err != nil {
+		return formatAPIError("failed to get sending identities", err)
+	}
+	if identity := identityFor(identities, alias.Email); identity != nil {
+		if porcelain != "" {
+			// email, identity id, outcome (existing|created|manual)
+			printPorcelain(alias.Email, identity.ID, "existing")
+			return nil
+		}
+		fmt.Printf("You can already send as %s (identity %s)\n", alias.Email, identity.Email)
+		return nil
+	}
+
+	if instructionsOnly || client.ReadOnly {
+		if porcelain != "" {
+			printPorcelain(alias.Email, "", "manual")
+		}
+		printSendAsSteps(alias.Email)
+		return nil
+	}
+
+	identity, err := client.CreateIdentity(alias.Email, name)
+	if err != nil {
+		printSendAsSteps(alias.Email)
+		return formatAPIError("failed to create sending identity", err)
+	}
+	if porcelain != "" {
+		printPorcelain(alias.Email, identity.ID, "created")
+		return nil
+	}
+	fmt.Printf("Created a sending identity for %s\n", alias.Email)
+	fmt.Printf("When replying to mail sent to %s, pick it in the From menu.\n", alias.Email)
+	if alias.State == AliasDisabled {
+		fmt.Fprintf(os.Stderr, "Note: %s is disabled, so replies to what you send will go to the trash.\n", alias.Email)
+	}
+	return nil
+}
@@ -0,0 +1,369 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/spf13/cobra"
+)
+
+// socketFile is the default Unix socket of serve mode in the data directory
+const socketFile = "serve.sock"
+
+// newServeCmd creates the command that answers alias queries from editor
+// plugins and scripts over a Unix domain socket.
+func newServeCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Answer alias queries from editors and scripts over a Unix socket",
+		Long: `Listen on a Unix domain socket and answer queries with a line protocol, so that
+editor plugins and small scripts can get aliases without starting a process for
+each one. Each request is one line, and gets one line in response:
+
+    GET <url or domain>                   the alias for the site, created if
+                                          there is none
+    CREATE <url or domain> [description]  a new alias for the site
+    LIST [url or domain]                  the aliases of the site, or all
+                                          aliases, separated by spaces
+    STATE <alias> <state>                 sets the state of the alias to
+                                          enabled, disabled or deleted
+    REFRESH                               loads the session again
+    PING                                  PONG
+
+Errors are answered with "ERR <message>". Protected aliases are not deleted.
+Requests are answered one at a time, so that two clients asking for a new site
+create one alias. The socket is only accessible to the current user; it is
+removed when the server stops.
+
+Aliases are read from Fastmail for every request, so aliases created elsewhere,
+such as on the phone, are seen right away. The session is kept, though: after
+changing the account or the permissions of the token, send REFRESH, POST
+/refresh or SIGHUP to load it again.
+
+With --listen, the same operations are also offered as a JSON API over HTTP on a
+loopback address, described by the OpenAPI document at /openapi.json:
+
+    GET  /aliases?site=<site>         the aliases of the site, or all aliases
+    POST /aliases                     {"site": ..., "description": ...}
+    POST /aliases/lookup              {"site": ...}, created if there is none
+    PUT  /aliases/{email}/state       {"state": "enabled"}
+    POST /refresh                     loads the session again
+
+Request bodies must be sent with "Content-Type: application/json". Without
+--tokens-file, requests must name a loopback host, such as 127.0.0.1 or
+localhost, or the host of --listen, so that web pages can't reach the API.
+
+With --tokens-file, every HTTP request must carry the token of a client in an
+"Authorization: Bearer <token>" header, and each client may make --rate-limit
+requests per minute. To serve other devices, such as from a home server, add
+--remote to listen on any address; remote mode requires tokens, and TLS with
+--tls-cert and --tls-key. A client can be restricted to getting aliases by
+adding the "lookup" scope after its token.
+
+With --grpc-listen, the same operations are also offered as a gRPC service,
+maskedfastmail.v1.AliasService, described by aliaspb/aliases.proto in the
+source: GetAlias, CreateAlias, ListAliases (streaming the aliases),
+UpdateState (a stream of updates, each answered in order) and Refresh. Failed
+calls carry the fields of the --json errors as a detail. --remote, --tls-cert,
+--tls-key, --tokens-file and --rate-limit apply to it as to the HTTP API;
+tokens are sent as "authorization: Bearer <token>" metadata, each call counts
+as one request, and "lookup" clients may only call GetAlias.
+
+With --browser, pages in browsers may call the HTTP API too, which the
+bookmarklet printed by the bookmarklet command does. It requires --tokens-file.
+
+/healthz answers while the server runs, and /readyz once Fastmail answers and
+accepts the token, with 503 otherwise; both are open without a token, for
+reverse proxies and uptime monitors.
+
+With --access-log, each HTTP request is logged as a line of JSON with the
+method, path, status, latency, client and result. The log is rotated when it
+reaches --access-log-max-mb, keeping --access-log-keep old logs as
+<file>.1, <file>.2 and so on. With -v, requests are also logged to stderr.`,
+		Example: `  masked_fastmail serve &
+  printf 'GET example.com\n' | nc -U ~/.local/share/masked_fastmail/serve.sock
+
+  masked_fastmail serve --listen 127.0.0.1:8787 &
+  curl -s -H 'Content-Type: application/json' -d '{"site": "example.com"}' http://127.0.0.1:8787/aliases/lookup
+
+  masked_fastmail serve --listen :8787 --remote --tokens-file tokens \
+    --tls-cert server.crt --tls-key server.key
+
+  masked_fastmail serve --grpc-listen 127.0.0.1:8788 &
+  grpcurl -plaintext -import-path aliaspb -proto aliases.proto -d '{"site": "example.com"}' \
+    127.0.0.1:8788 maskedfastmail.v1.AliasService/GetAlias
+
+  # Emacs Lisp:
+  (process-send-string proc "GET example.com\n")`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			socket, _ := cmd.Flags().GetString("socket")
+			activate, _ := cmd.Flags().GetBool("activate")
+			var opts httpOptions
+			opts.Listen, _ = cmd.Flags().GetString("listen")
+			opts.GRPCListen, _ = cmd.Flags().GetString("grpc-listen")
+			opts.Remote, _ = cmd.Flags().GetBool("remote")
+			opts.TokensFile, _ = cmd.Flags().GetString("tokens-file")
+			opts.TLSCert, _ = cmd.Flags().GetString("tls-cert")
+			opts.TLSKey, _ = cmd.Flags().GetString("tls-key")
+			opts.RateLimit, _ = cmd.Flags().GetInt("rate-limit")
+			opts.Browser, _ = cmd.Flags().GetBool("browser")
+			opts.AccessLog, _ = cmd.Flags().GetString("access-log")
+			opts.AccessLogMaxMB, _ = cmd.Flags().GetInt("access-log-max-mb")
+			opts.AccessLogKeep, _ = cmd.Flags().GetInt("access-log-keep")
+			if socket == "" {
+				dir, err := dataDir()
+				if err != nil {
+					return fmt.Errorf("failed to locate data directory: %w", err)
+				}
+				socket = filepath.Join(dir, socketFile)
+			}
+
+			if err := opts.check(); err != nil {
+				return err
+			}
+			var clients []serveClient
+			if opts.TokensFile != "" {
+				var err error
+				if clients, err = readServeTokens(opts.TokensFile); err != nil {
+					return err
+				}
+			}
+
+			client, err := newClientFromCmd(cmd)
+			if err != nil {
+				return err
+			}
+			service := &aliasService{client: client, activate: activate}
+			// HTTP requests and gRPC calls share the rate limit of each client
+			limiter := newRateLimiter(opts.RateLimit)
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			hangups := make(chan os.Signal, 1)
+			signal.Notify(hangups, syscall.SIGHUP)
+			defer signal.Stop(hangups)
+			servers := []func(context.Context) error{
+				func(ctx context.Context) error { return serveSocket(ctx, socket, &lineServer{aliases: service}) },
+				func(ctx context.Context) error { refreshOn(ctx, hangups, service); return nil },
+			}
+			if opts.Listen != "" {
+				handler := newAPIHandler(service)
+				if clients != nil {
+					handler = requireToken(handler, clients, limiter)
+				}
+				if opts.Browser {
+					handler = allowBrowsers(handler)
+				}
+				var accessLog io.Writer
+				switch opts.AccessLog {
+				case "":
+				case "-":
+					accessLog = os.Stderr
+				default:
+					file, err := openRotatingFile(opts.AccessLog, int64(opts.AccessLogMaxMB)<<20, opts.AccessLogKeep)
+					if err != nil {
+						return err
+					}
+					defer file.Close()
+					accessLog = file
+				}
+				handler = withHealth(handler, service.Ready)
+				if clients == nil {
+					handler = allowLocalHosts(handler, opts.Listen)
+				}
+				handler = logAccess(handler, accessLog)
+				servers = append(servers, func(ctx context.Context) error {
+					return serveHTTP(ctx, opts.Listen, handler, opts.TLSCert, opts.TLSKey)
+				})
+			}
+			if opts.GRPCListen != "" {
+				server, err := newGRPCServer(service, clients, limiter, opts.TLSCert, opts.TLSKey)
+				if err != nil {
+					return err
+				}
+				servers = append(servers, func(ctx context.Context) error {
+					return serveGRPC(ctx, opts.GRPCListen, server)
+				})
+			}
+			return runServers(ctx, servers...)
+		},
+	}
+	cmd.Flags().String("socket", "", "path of the Unix socket (default: serve.sock in the data directory)")
+	cmd.Flags().Bool("activate", false, "create new aliases enabled instead of pending")
+	cmd.Flags().String("listen", "", "also serve the HTTP API on this loopback address, e.g. 127.0.0.1:8787")
+	cmd.Flags().String("grpc-listen", "", "also serve the gRPC API on this loopback address, e.g. 127.0.0.1:8788")
+	cmd.Flags().Bool("remote", false, "allow --listen and --grpc-listen addresses other machines can reach; requires --tokens-file and TLS")
+	cmd.Flags().String("tokens-file", "", "file of the bearer tokens of the HTTP and gRPC API clients, one \"<name> <token>\" per line")
+	cmd.Flags().String("tls-cert", "", "certificate file to serve the HTTP and gRPC APIs over TLS")
+	cmd.Flags().String("tls-key", "", "private key file of --tls-cert")
+	cmd.Flags().Int("rate-limit", defaultRateLimit, "requests per minute allowed to each HTTP or gRPC API client with --tokens-file")
+	cmd.Flags().Bool("browser", false, "let pages in browsers call the HTTP API, for the bookmarklet; requires --tokens-file")
+	cmd.Flags().String("access-log", "", "append a JSON line for each HTTP request to this file, or - for stderr")
+	cmd.Flags().Int("access-log-max-mb", defaultAccessLogMaxMB, "size at which the access log is rotated")
+	cmd.Flags().Int("access-log-keep", defaultAccessLogKeep, "number of rotated access logs to keep")
+	return cmd
+}
+
+// lineServer answers the requests of the line protocol with the alias
+// operations of serve mode.
+type lineServer struct {
+	aliases aliasHandler
+}
+
+// respond returns the response line to a request line.
+func (s *lineServer) respond(line string) string {
+	verb, arg, _ := strings.Cut(strings.TrimSpace(line), " ")
+	arg = strings.TrimSpace(arg)
+	switch strings.ToUpper(verb) {
+	case "GET":
+		if arg == "" {
+			return "ERR GET requires a URL or domain"
+		}
+		alias, err := s.aliases.GetAlias(arg)
+		if err != nil {
+			return errorLine(err)
+		}
+		return alias.Email
+	case "CREATE":
+		site, description, _ := strings.Cut(arg, " ")
+		if site == "" {
+			return "ERR CREATE requires a URL or domain"
+		}
+		alias, err := s.aliases.CreateAlias(site, strings.TrimSpace(description))
+		if err != nil {
+			return errorLine(err)
+		}
+		return alias.Email
+	case "LIST":
+		aliases, err := s.aliases.ListAliases(arg)
+		if err != nil {
+			return errorLine(err)
+		}
+		emails := make([]string, len(aliases))
+		for i, alias := range aliases {
+			emails[i] = alias.Email
+		}
+		return strings.Join(emails, " ")
+	case "STATE":
+		fields := strings.Fields(arg)
+		if len(fields) != 2 {
+			return "ERR STATE requires an alias and a state"
+		}
+		alias, err := s.aliases.UpdateState(fields[0], fields[1])
+		if err != nil {
+			return errorLine(err)
+		}
+		return alias.Email + " " + string(alias.State)
+	case "REFRESH":
+		if _, err := s.aliases.Refresh(); err != nil {
+			return errorLine(err)
+		}
+		return "OK"
+	case "PING":
+		return "PONG"
+	case "":
+		return "ERR empty request"
+	default:
+		return fmt.Sprintf("ERR unknown command %q (expected GET, CREATE, LIST, STATE, REFRESH or PING)", verb)
+	}
+}
+
+// errorLine returns the response to a failed request, kept on one line.
+func errorLine(err error) string {
+	return "ERR " + strings.Join(strings.Fields(err.Error()), " ")
+}
+
+// serve answers the requests of a connection until it is closed.
+func (s *lineServer) serve(conn io.ReadWriteCloser) {
+	defer conn.Close()
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		if _, err := fmt.Fprintln(conn, s.respond(scanner.Text())); err != nil {
+			return
+		}
+	}
+}
+
+// refreshOn refreshes the service on each signal until ctx is done; serve
+// refreshes on SIGHUP, as daemons reload on it.
+func refreshOn(ctx context.Context, signals <-chan os.Signal, service aliasHandler) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-signals:
+			if _, err := service.Refresh(); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			}
+		}
+	}
+}
+
+// runServers runs the servers until ctx is done or one of them fails, and
+// then stops the others.
+func runServers(ctx context.Context, servers ...func(context.Context) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	errs := make(chan error, len(servers))
+	for _, server := range servers {
+		go func(server func(context.Context) error) { errs <- server(ctx) }(server)
+	}
+	var first error
+	for range servers {
+		if err := <-errs; err != nil && first == nil {
+			first = err
+		}
+		cancel()
+	}
+	return first
+}
+
+// serveSocket listens on a Unix socket until ctx is done. A socket file left
+// by a server that is no longer running is replaced.
+func serveSocket(ctx context.Context, path string, server *lineServer) error {
+	if err := os.MkdirAll(filepath.Dir(path), privateDirMode); err != nil {
+		return err
+	}
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return fmt.Errorf("another server is already listening on %s", path)
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove stale socket: %w", err)
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", path, err)
+	}
+	defer listener.Close()
+	if err := os.Chmod(path, privateFileMode); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Listening on %s\n", path)
+
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("failed to accept connection: %w", err)
+		}
+		go server.serve(conn)
+	}
+}
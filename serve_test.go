@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// stubAliases answers the alias operations without an account.
+type stubAliases struct{}
+
+func (stubAliases) GetAlias(site string) (*MaskedEmailInfo, error) {
+	if site == "bad" {
+		return nil, errors.New("invalid\ndomain")
+	}
+	return &MaskedEmailInfo{Email: "alias-for-" + site + "@fastmail.com"}, nil
+}
+
+func (stubAliases) CreateAlias(site, description string) (*MaskedEmailInfo, error) {
+	return &MaskedEmailInfo{Email: "new-for-" + site + "@fastmail.com", Description: description}, nil
+}
+
+func (stubAliases) ListAliases(site string) ([]MaskedEmailInfo, error) {
+	if site == "" {
+		return []MaskedEmailInfo{{Email: "a@fastmail.com"}, {Email: "b@fastmail.com"}}, nil
+	}
+	return []MaskedEmailInfo{{Email: "a@fastmail.com"}}, nil
+}
+
+func (stubAliases) UpdateState(email, state string) (*MaskedEmailInfo, error) {
+	if state != "enabled" {
+		return nil, fmt.Errorf("invalid state %q", state)
+	}
+	return &MaskedEmailInfo{Email: email, State: AliasState(state)}, nil
+}
+
+func (stubAliases) Refresh() (*refreshResult, error) {
+	return &refreshResult{RefreshedAt: time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)}, nil
+}
+
+func TestLineServerRespond(t *testing.T) {
+	server := &lineServer{aliases: stubAliases{}}
+	tests := map[string]string{
+		"GET example.com":                 "alias-for-example.com@fastmail.com",
+		"get  example.com ":               "alias-for-example.com@fastmail.com",
+		"GET bad":                         "ERR invalid domain",
+		"GET":                             "ERR GET requires a URL or domain",
+		"CREATE example.com Shop account": "new-for-example.com@fastmail.com",
+		"CREATE":                          "ERR CREATE requires a URL or domain",
+		"LIST":                            "a@fastmail.com b@fastmail.com",
+		"LIST example.com":                "a@fastmail.com",
+		"STATE a@fastmail.com enabled":    "a@fastmail.com enabled",
+		"STATE a@fastmail.com":            "ERR STATE requires an alias and a state",
+		"STATE a@fastmail.com sleepy":     `ERR invalid state "sleepy"`,
+		"REFRESH":                         "OK",
+		"PING":                            "PONG",
+		"":                                "ERR empty request",
+		"PUT example.com":                 `ERR unknown command "PUT" (expected GET, CREATE, LIST, STATE, REFRESH or PING)`,
+	}
+	for line, want := range tests {
+		if got := server.respond(line); got != want {
+			t.Errorf("respond(%q) = %q, want %q", line, got, want)
+		}
+	}
+}
+
+func TestServeSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "serve.sock")
+	// A socket file left by a crashed server is replaced
+	if err := os.WriteFile(path, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	server := &lineServer{aliases: stubAliases{}}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- serveSocket(ctx, path, server) }()
+
+	var conn net.Conn
+	var err error
+	for i := 0; i < 50; i++ {
+		if conn, err = net.Dial("unix", path); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	reader := bufio.NewReader(conn)
+	for _, site := range []string{"a.com", "b.com"} {
+		fmt.Fprintf(conn, "GET %s\n", site)
+		if line, _ := reader.ReadString('\n'); line != "alias-for-"+site+"@fastmail.com\n" {
+			t.Fatalf("unexpected response %q", line)
+		}
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("expected a private socket, got %v, %v", info, err)
+	}
+	if err := serveSocket(ctx, path, server); err == nil {
+		t.Fatal("expected an error for a socket already in use")
+	}
+	conn.Close()
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("serveSocket() = %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected the socket to be removed, got %v", err)
+	}
+}
+
+// countingRefresh counts the refreshes of the alias operations.
+type countingRefresh struct {
+	stubAliases
+	refreshes chan struct{}
+}
+
+func (c countingRefresh) Refresh() (*refreshResult, error) {
+	c.refreshes <- struct{}{}
+	return &refreshResult{}, nil
+}
+
+func TestRefreshOn(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	service := countingRefresh{refreshes: make(chan struct{}, 2)}
+	done := make(chan struct{})
+	go func() {
+		refreshOn(ctx, signals, service)
+		close(done)
+	}()
+
+	signals <- syscall.SIGHUP
+	select {
+	case <-service.refreshes:
+	case <-time.After(time.Second):
+		t.Fatal("the signal didn't refresh the service")
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("refreshOn didn't stop with its context")
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// aliasHandler is the contract between the protocols of serve mode and the
+// alias operations, so that every protocol offers the same operations.
+type aliasHandler interface {
+	GetAlias(site string) (*MaskedEmailInfo, error)
+	CreateAlias(site, description string) (*MaskedEmailInfo, error)
+	ListAliases(site string) ([]MaskedEmailInfo, error)
+	UpdateState(email, state string) (*MaskedEmailInfo, error)
+	Refresh() (*refreshResult, error)
+}
+
+// refreshResult is the answer to a refresh.
+type refreshResult struct {
+	RefreshedAt time.Time `json:"refreshedAt"`
+}
+
+// aliasService holds the alias operations of serve mode, so that each
+// protocol the server speaks only translates requests and responses.
+// Operations are made one at a time, so that two clients asking for a new
+// site create one alias.
+type aliasService struct {
+	client *FastmailClient
+	// activate creates new aliases enabled instead of pending
+	activate bool
+	mu       sync.Mutex
+	// ready is the result of the last readiness check
+	ready *readiness
+}
+
+// GetAlias returns the alias for a site, created if there is none.
+func (s *aliasService) GetAlias(site string) (*MaskedEmailInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return lookupOrCreateAlias(s.client, site, nil, s.activate)
+}
+
+// CreateAlias creates a new alias for a site, even if it already has one.
+// An empty description selects the default one.
+func (s *aliasService) CreateAlias(site, description string) (*MaskedEmailInfo, error) {
+	_, domain, err := prepareDomainInput(site)
+	if err != nil {
+		return nil, &exitCodeError{code: exitInvalidInput, err: err}
+	}
+	var desc *string
+	if description != "" {
+		desc = &description
+	}
+	creation := AliasCreation{Domain: domain, Description: defaultDescription(domain, desc)}
+	if s.activate {
+		creation.State = AliasEnabled
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	alias, err := s.client.CreateAliasWith(creation)
+	if err != nil {
+		return nil, formatAPIError("failed to create alias", err)
+	}
+	recordUsage(eventCreated)
+	recordOwnership(alias.Email)
+	return alias, nil
+}
+
+// ListAliases returns the aliases of a site that aren't deleted, or every
+// alias when site is empty.
+func (s *aliasService) ListAliases(site string) ([]MaskedEmailInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if site == "" {
+		aliases, err := s.client.FetchAllAliases()
+		if err != nil {
+			return nil, formatAPIError("failed to get aliases", err)
+		}
+		return aliases, nil
+	}
+	_, domain, err := prepareDomainInput(site)
+	if err != nil {
+		return nil, &exitCodeError{code: exitInvalidInput, err: err}
+	}
+	aliases, err := s.client.GetAliases(domain)
+	if err != nil {
+		return nil, formatAPIError("failed to get aliases", err)
+	}
+	return aliases, nil
+}
+
+// UpdateState sets the state of an alias and returns it with the new state.
+// Protected aliases are never deleted: there is no --force over the wire.
+func (s *aliasService) UpdateState(email, state string) (*MaskedEmailInfo, error) {
+	email, err := normalizeEmailInput(email)
+	if err != nil {
+		return nil, &exitCodeError{code: exitInvalidInput, err: err}
+	}
+	newState := AliasState(strings.ToLower(strings.TrimSpace(state)))
+	if newState != AliasEnabled && newState != AliasDisabled && newState != AliasDeleted {
+		return nil, &exitCodeError{code: exitInvalidInput, err: fmt.Errorf("invalid state %q (expected enabled, disabled or deleted)", state)}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	alias, err := s.client.GetAliasByEmail(email)
+	if err != nil {
+		return nil, formatAPIError("failed to get alias", err)
+	}
+	if _, err := deletedAliasNote(*alias, newState); err != nil {
+		return nil, err
+	}
+	if newState == AliasDeleted {
+		if err := checkDeletable(*alias, false); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.client.UpdateAliasStatus(alias, newState); err != nil {
+		return nil, formatAPIError("failed to update alias status", err)
+	}
+	recordStateChanges([]MaskedEmailInfo{*alias}, newState, time.Now())
+	alias.State = newState
+	return alias, nil
+}
+
+// Refresh drops what the service keeps between requests, the session and the
+// result of the readiness check, and loads the session again, e.g. after the
+// account or the permissions of the token changed. Aliases are not kept:
+// every request reads them from Fastmail.
+func (s *aliasService) Refresh() (*refreshResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.client.invalidateSession()
+	s.ready = nil
+	if _, err := s.client.GetSession(); err != nil {
+		return nil, formatAPIError("failed to refresh the session", err)
+	}
+	logf(levelActions, "refreshed the session")
+	return &refreshResult{RefreshedAt: time.Now().UTC()}, nil
+}
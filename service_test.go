@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAliasServiceGetAndCreate(t *testing.T) {
+	fake, client := newFakeJMAP(t,
+		MaskedEmailInfo{ID: "a1", Email: "shop.1@fastmail.com", ForDomain: "https://example.com", State: AliasEnabled},
+	)
+	service := &aliasService{client: client, activate: true}
+
+	alias, err := service.GetAlias("https://example.com/login")
+	if err != nil || alias.Email != "shop.1@fastmail.com" {
+		t.Fatalf("GetAlias() = %v, %v; want the existing alias", alias, err)
+	}
+	if fake.created != 0 {
+		t.Fatalf("GetAlias() created an alias for a site that has one")
+	}
+
+	alias, err = service.CreateAlias("example.com", "Second account")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fake.created != 1 || alias.Description != "Second account" || fake.state(alias.ID) != AliasEnabled {
+		t.Errorf("CreateAlias() = %+v; want a new enabled alias with the description", alias)
+	}
+
+	if _, err := service.CreateAlias("", ""); exitCode(err) != exitInvalidInput {
+		t.Errorf("CreateAlias(\"\") = %v; want invalid input", err)
+	}
+}
+
+func TestAliasServiceListAliases(t *testing.T) {
+	_, client := newFakeJMAP(t,
+		MaskedEmailInfo{ID: "a1", Email: "shop.1@fastmail.com", ForDomain: "https://example.com", State: AliasEnabled},
+		MaskedEmailInfo{ID: "a2", Email: "old.2@fastmail.com", ForDomain: "https://example.com", State: AliasDeleted},
+		MaskedEmailInfo{ID: "a3", Email: "news.3@fastmail.com", ForDomain: "https://other.org", State: AliasDisabled},
+	)
+	service := &aliasService{client: client}
+
+	all, err := service.ListAliases("")
+	if err != nil || len(all) != 3 {
+		t.Fatalf("ListAliases(\"\") = %d aliases, %v; want all 3", len(all), err)
+	}
+	site, err := service.ListAliases("example.com")
+	if err != nil || len(site) != 1 || site[0].ID != "a1" {
+		t.Errorf("ListAliases(example.com) = %v, %v; want only the alias that isn't deleted", site, err)
+	}
+}
+
+func TestAliasServiceUpdateState(t *testing.T) {
+	fake, client := newFakeJMAP(t,
+		MaskedEmailInfo{ID: "a1", Email: "shop.1@fastmail.com", ForDomain: "https://example.com", State: AliasEnabled},
+		MaskedEmailInfo{ID: "a2", Email: "bank.2@fastmail.com", ForDomain: "https://bank.com", State: AliasEnabled, Description: "Bank " + protectedTag},
+		MaskedEmailInfo{ID: "a3", Email: "old.3@fastmail.com", ForDomain: "https://old.com", State: AliasDeleted},
+	)
+	service := &aliasService{client: client}
+
+	alias, err := service.UpdateState("shop.1@fastmail.com", "Disabled")
+	if err != nil || alias.State != AliasDisabled || fake.state("a1") != AliasDisabled {
+		t.Fatalf("UpdateState() = %v, %v; want the alias disabled", alias, err)
+	}
+	if _, err := service.UpdateState("shop.1@fastmail.com", "pending"); exitCode(err) != exitInvalidInput {
+		t.Errorf("UpdateState(pending) = %v; want invalid input", err)
+	}
+	if _, err := service.UpdateState("bank.2@fastmail.com", "deleted"); !errors.Is(err, ErrProtected) {
+		t.Errorf("UpdateState() deleted a protected alias: %v", err)
+	}
+	if _, err := service.UpdateState("missing@fastmail.com", "enabled"); !errors.Is(err, ErrAliasNotFound) {
+		t.Errorf("UpdateState() = %v; want ErrAliasNotFound", err)
+	}
+	if _, err := service.UpdateState("old.3@fastmail.com", "deleted"); !errors.Is(err, ErrAliasDeleted) {
+		t.Errorf("UpdateState() = %v; want ErrAliasDeleted", err)
+	}
+	if alias, err := service.UpdateState("old.3@fastmail.com", "enabled"); err != nil || fake.state("a3") != AliasEnabled {
+		t.Errorf("UpdateState() = %v, %v; want the deleted alias restored", alias, err)
+	}
+}
+
+func TestAliasServiceRefresh(t *testing.T) {
+	_, client := newFakeJMAP(t)
+	client.session = &Session{Username: "old@example.com"}
+	service := &aliasService{client: client, ready: &readiness{Ready: true, CheckedAt: time.Now()}}
+
+	result, err := service.Refresh()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.session == nil || client.session.Username != "me@example.com" {
+		t.Errorf("the session wasn't loaded again: %+v", client.session)
+	}
+	if service.ready != nil || result.RefreshedAt.IsZero() {
+		t.Errorf("the readiness check was kept (%+v) or the result is empty (%+v)", service.ready, result)
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// JMAP session endpoint and capability identifiers
+const (
+	sessionURL     = "https://api.fastmail.com/jmap/session"
+	capabilityCore = "urn:ietf:params:jmap:core"
+)
+
+// Fallback limits used when the session does not advertise its own.
+// These are the minimums recommended by RFC 8620.
+const (
+	defaultMaxCallsInRequest = 16
+	defaultMaxObjectsInGet   = 500
+	defaultMaxObjectsInSet   = 500
+)
+
+// Session holds the subset of the JMAP session object used by the CLI.
+type Session struct {
+	Username        string                     `json:"username"`
+	APIURL          string                     `json:"apiUrl"`
+	State           string                     `json:"state"`
+	Capabilities    map[string]json.RawMessage `json:"capabilities"`
+	Accounts        map[string]SessionAccount  `json:"accounts"`
+	PrimaryAccounts map[string]string          `json:"primaryAccounts"`
+}
+
+// SessionAccount describes an account the token has access to.
+type SessionAccount struct {
+	Name                string                     `json:"name"`
+	IsPersonal          bool                       `json:"isPersonal"`
+	IsReadOnly          bool                       `json:"isReadOnly"`
+	AccountCapabilities map[string]json.RawMessage `json:"accountCapabilities"`
+}
+
+// CoreLimits are the server limits advertised by the core JMAP capability.
+type CoreLimits struct {
+	MaxSizeUpload         int `json:"maxSizeUpload"`
+	MaxConcurrentUpload   int `json:"maxConcurrentUpload"`
+	MaxSizeRequest        int `json:"maxSizeRequest"`
+	MaxConcurrentRequests int `json:"maxConcurrentRequests"`
+	MaxCallsInRequest     int `json:"maxCallsInRequest"`
+	MaxObjectsInGet       int `json:"maxObjectsInGet"`
+	MaxObjectsInSet       int `json:"maxObjectsInSet"`
+}
+
+// GetSession fetches the JMAP session object. The result is kept for the
+// lifetime of the client and, with CacheSession, saved for later runs until
+// it expires or a response reports a new session state.
+func (fc *FastmailClient) GetSession() (*Session, error) {
+	if fc.session != nil {
+		return fc.session, nil
+	}
+	if fc.CacheSession {
+		if session := loadCachedSession(fc.Token, time.Now()); session != nil {
+			fc.session = session
+			return session, nil
+		}
+	}
+
+	req, err := http.NewRequest("GET", sessionURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var session *Session
+	err = fc.doHTTP(req, nil, opSession, func(body io.Reader) error {
+		data, err := io.ReadAll(body)
+		if err != nil {
+			return err
+		}
+		session, err = parseSession(data)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	fc.session = session
+	if fc.CacheSession {
+		if err := saveCachedSession(fc.Token, session, time.Now()); err != nil {
+			fc.debugf("Could not cache the session: %v", err)
+		}
+	}
+	return session, nil
+}
+
+// parseSession decodes a JMAP session object.
+func parseSession(body []byte) (*Session, error) {
+	var session Session
+	if err := json.Unmarshal(body, &session); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal session: %w", err)
+	}
+	return &session, nil
+}
+
+// CoreLimits returns the core capability limits advertised by the session.
+// Limits the server omits are filled in with the RFC 8620 minimums.
+func (s *Session) CoreLimits() CoreLimits {
+	var limits CoreLimits
+	if raw, ok := s.Capabilities[capabilityCore]; ok {
+		// Ignore decode errors and fall back to defaults below
+		_ = json.Unmarshal(raw, &limits)
+	}
+
+	if limits.MaxCallsInRequest <= 0 {
+		limits.MaxCallsInRequest = defaultMaxCallsInRequest
+	}
+	if limits.MaxObjectsInGet <= 0 {
+		limits.MaxObjectsInGet = defaultMaxObjectsInGet
+	}
+	if limits.MaxObjectsInSet <= 0 {
+		limits.MaxObjectsInSet = defaultMaxObjectsInSet
+	}
+	return limits
+}
+
+// Limits returns the server limits for the current session.
+func (fc *FastmailClient) Limits() (CoreLimits, error) {
+	session, err := fc.GetSession()
+	if err != nil {
+		return CoreLimits{}, err
+	}
+	return session.CoreLimits(), nil
+}
+
+// resolveAccount finds an account by ID or, case-insensitively, by name.
+func (s *Session) resolveAccount(idOrName string) (string, error) {
+	target := strings.TrimSpace(idOrName)
+	if _, ok := s.Accounts[target]; ok {
+		return target, nil
+	}
+
+	var matches []string
+	for id, account := range s.Accounts {
+		if strings.EqualFold(account.Name, target) {
+			matches = append(matches, id)
+		}
+	}
+	sort.Strings(matches)
+
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("account %q not found (see `masked_fastmail accounts list`)", idOrName)
+	case 1:
+		return matches[0], nil
+	default:
+		return "", fmt.Errorf("account name %q is ambiguous, use one of the IDs: %s", idOrName, strings.Join(matches, ", "))
+	}
+}
+
+// SupportsMaskedEmail reports whether the account has the masked email capability.
+func (a SessionAccount) SupportsMaskedEmail() bool {
+	_, ok := a.AccountCapabilities[maskedEmailNamespace]
+	return ok
+}
+
+// SelectAccount switches the client to the account with the given ID or name,
+// as listed in the session.
+func (fc *FastmailClient) SelectAccount(idOrName string) error {
+	session, err := fc.GetSession()
+	if err != nil {
+		return err
+	}
+
+	id, err := session.resolveAccount(idOrName)
+	if err != nil {
+		return err
+	}
+	if !session.Accounts[id].SupportsMaskedEmail() {
+		return fmt.Errorf("account %q (%s) does not support masked email", session.Accounts[id].Name, id)
+	}
+
+	fc.AccountID = id
+	return nil
+}
+
+// SelectPrimaryAccount switches the client to the token's primary account
+// for masked email.
+func (fc *FastmailClient) SelectPrimaryAccount() error {
+	session, err := fc.GetSession()
+	if err != nil {
+		return err
+	}
+
+	id, ok := session.PrimaryAccounts[maskedEmailNamespace]
+	if !ok {
+		return errors.New("the API token has no primary account for masked email; set FASTMAIL_ACCOUNT_ID or --account")
+	}
+
+	fc.AccountID = id
+	return nil
+}
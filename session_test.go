@@ -0,0 +1,70 @@
+package main
+
+import "testing"
+
+func TestSessionCoreLimits(t *testing.T) {
+	session, err := parseSession([]byte(`{
+		"username": "user@example.com",
+		"apiUrl": "https://api.fastmail.com/jmap/api/",
+		"capabilities": {
+			"urn:ietf:params:jmap:core": {
+				"maxSizeRequest": 10000000,
+				"maxCallsInRequest": 50,
+				"maxObjectsInSet": 4096
+			}
+		}
+	}`))
+	if err != nil {
+		t.Fatalf("parseSession returned error: %v", err)
+	}
+
+	limits := session.CoreLimits()
+	if limits.MaxCallsInRequest != 50 {
+		t.Fatalf("MaxCallsInRequest = %d, want 50", limits.MaxCallsInRequest)
+	}
+	if limits.MaxObjectsInSet != 4096 {
+		t.Fatalf("MaxObjectsInSet = %d, want 4096", limits.MaxObjectsInSet)
+	}
+	if limits.MaxObjectsInGet != defaultMaxObjectsInGet {
+		t.Fatalf("expected missing MaxObjectsInGet to fall back to %d, got %d", defaultMaxObjectsInGet, limits.MaxObjectsInGet)
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := []struct {
+		input    int
+		expected string
+	}{
+		{512, "512 B"},
+		{2048, "2.0 KiB"},
+		{10000000, "9.5 MiB"},
+	}
+
+	for _, tt := range tests {
+		if got := formatBytes(tt.input); got != tt.expected {
+			t.Fatalf("formatBytes(%d) = %q, want %q", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestSessionResolveAccount(t *testing.T) {
+	session := &Session{Accounts: map[string]SessionAccount{
+		"u1": {Name: "me@example.com"},
+		"u2": {Name: "family@example.com"},
+		"u3": {Name: "Shared"},
+		"u4": {Name: "shared"},
+	}}
+
+	if id, err := session.resolveAccount("u2"); err != nil || id != "u2" {
+		t.Fatalf("expected account ID to resolve, got %q (%v)", id, err)
+	}
+	if id, err := session.resolveAccount("Family@Example.com"); err != nil || id != "u2" {
+		t.Fatalf("expected account name to resolve case-insensitively, got %q (%v)", id, err)
+	}
+	if _, err := session.resolveAccount("shared"); err == nil {
+		t.Fatalf("expected ambiguous account name to error")
+	}
+	if _, err := session.resolveAccount("missing"); err == nil {
+		t.Fatalf("expected unknown account to error")
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	sessionCacheFile = "session.json"
+	// sessionCacheTTL is how long a cached session is used before it is
+	// fetched again, even if no response reported a newer session state
+	sessionCacheTTL = 24 * time.Hour
+)
+
+// ErrUnauthorized is returned when the API rejects the token.
+var ErrUnauthorized = errors.New("the API token was rejected")
+
+// cachedSession is a JMAP session object saved between runs, so that
+// commands don't have to fetch it every time.
+type cachedSession struct {
+	// TokenHash identifies the token the session belongs to; the token
+	// itself is never stored
+	TokenHash string    `json:"tokenHash"`
+	FetchedAt time.Time `json:"fetchedAt"`
+	Session   *Session  `json:"session"`
+}
+
+// tokenFingerprint returns a hash identifying the token without revealing it.
+func tokenFingerprint(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:16])
+}
+
+// loadCachedSession returns the cached session for the token, or nil if
+// there is none, it belongs to another token or it has expired.
+func loadCachedSession(token string, now time.Time) *Session {
+	var cached cachedSession
+	if err := readJSONFile(sessionCacheFile, &cached); err != nil {
+		return nil
+	}
+	if cached.Session == nil || cached.TokenHash != tokenFingerprint(token) || now.Sub(cached.FetchedAt) > sessionCacheTTL {
+		return nil
+	}
+	return cached.Session
+}
+
+// saveCachedSession stores the session for the token.
+func saveCachedSession(token string, session *Session, now time.Time) error {
+	return writeJSONFile(sessionCacheFile, cachedSession{
+		TokenHash: tokenFingerprint(token),
+		FetchedAt: now.UTC(),
+		Session:   session,
+	})
+}
+
+// clearCachedSession removes the cached session, if any.
+func clearCachedSession() error {
+	return deleteJSONFile(sessionCacheFile)
+}
+
+// invalidateSession forgets the session, both in memory and in the cache,
+// so that it is fetched again when next needed.
+func (fc *FastmailClient) invalidateSession() {
+	fc.session = nil
+	if !fc.CacheSession {
+		return
+	}
+	if err := clearCachedSession(); err != nil {
+		fc.debugf("Could not remove the cached session: %v", err)
+	}
+}
+
+// checkSessionState invalidates the session if an API response reports a
+// session state other than the one of the session in use, as RFC 8620
+// requires.
+func (fc *FastmailClient) checkSessionState(state string) {
+	if fc.session != nil && state != "" && state != fc.session.State {
+		fc.invalidateSession()
+	}
+}
+
+// apiEndpoint returns the URL API requests are sent to: the apiUrl of the
+// session if it has been fetched, otherwise the default Fastmail endpoint.
+func (fc *FastmailClient) apiEndpoint() string {
+	if fc.session != nil && fc.session.APIURL != "" {
+		return fc.session.APIURL
+	}
+	return apiURL
+}
+
+// unauthorizedError explains a rejected token and how to replace it.
+func unauthorizedError(action string) error {
+	return fmt.Errorf("%s: %w (HTTP 401): it may have expired or been revoked. Create a new token in Fastmail under Settings > Privacy & Security > Manage API tokens and update %s",
+		action, ErrUnauthorized, credentialSource())
+}
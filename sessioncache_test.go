@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestSessionCache runs against every storage backend of the build, since
+// clearing the cache must remove the session wherever it is kept.
+func TestSessionCache(t *testing.T) {
+	for _, backend := range storageNames() {
+		t.Run(backend, func(t *testing.T) {
+			t.Setenv(dataDirEnv, t.TempDir())
+			previous := storageBackend
+			storageBackend = backend
+			t.Cleanup(func() { storageBackend = previous })
+
+			now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+			session := &Session{Username: "me@example.com", APIURL: "https://api.fastmail.com/jmap/api/", State: "s1"}
+
+			if loadCachedSession("token", now) != nil {
+				t.Fatalf("expected no cached session before one is saved")
+			}
+			if err := saveCachedSession("token", session, now); err != nil {
+				t.Fatalf("saveCachedSession returned error: %v", err)
+			}
+
+			cached := loadCachedSession("token", now.Add(time.Hour))
+			if cached == nil || cached.Username != session.Username || cached.State != "s1" {
+				t.Fatalf("expected the cached session, got %+v", cached)
+			}
+			if loadCachedSession("other token", now) != nil {
+				t.Fatalf("expected the session of another token not to be used")
+			}
+			if loadCachedSession("token", now.Add(sessionCacheTTL+time.Minute)) != nil {
+				t.Fatalf("expected an expired session not to be used")
+			}
+
+			if err := clearCachedSession(); err != nil {
+				t.Fatalf("clearCachedSession returned error: %v", err)
+			}
+			if loadCachedSession("token", now) != nil {
+				t.Fatalf("expected no cached session after clearing it")
+			}
+			if err := clearCachedSession(); err != nil {
+				t.Fatalf("clearing a missing cache should not fail, got %v", err)
+			}
+		})
+	}
+}
+
+func TestCheckSessionState(t *testing.T) {
+	t.Setenv(dataDirEnv, t.TempDir())
+	client := &FastmailClient{Token: "token", CacheSession: true, session: &Session{State: "s1", APIURL: "https://example.com/api/"}}
+	if err := saveCachedSession(client.Token, client.session, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	client.checkSessionState("s1")
+	if client.session == nil || client.apiEndpoint() != "https://example.com/api/" {
+		t.Fatalf("expected an unchanged session state to keep the session")
+	}
+
+	client.checkSessionState("s2")
+	if client.session != nil || loadCachedSession(client.Token, time.Now()) != nil {
+		t.Fatalf("expected a new session state to invalidate the session and the cache")
+	}
+	if client.apiEndpoint() != apiURL {
+		t.Fatalf("expected the default endpoint without a session, got %q", client.apiEndpoint())
+	}
+}
+
+func TestFormatAPIErrorUnauthorized(t *testing.T) {
+	err := formatAPIError("failed to get aliases", &APIError{StatusCode: 401, Message: "401 Unauthorized"})
+	if !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("expected ErrUnauthorized, got %v", err)
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// newShortcutCmd creates the command for automation apps such as Apple
+// Shortcuts, which pass the page URL and use the output as the alias.
+func newShortcutCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "shortcut [url] [description]",
+		Short: "Print only the alias for a URL, for Apple Shortcuts and automation apps",
+		Long: `Get or create the alias for a URL like the default command, but print only the
+alias on stdout, without copying it to the clipboard. The URL is read from the
+first line of stdin if it is not given or is "-", as "Run Shell Script" passes
+its input.
+
+Failures print a single line on stderr and exit with a code telling them apart,
+so that the automation can react to them without parsing messages:
+
+` + exitStatusHelp(),
+		Example: `  # Apple Shortcuts "Run Shell Script" with input passed to stdin:
+  masked_fastmail shortcut
+
+  # Android automation apps passing the URL as an argument:
+  masked_fastmail shortcut "https://example.com/signup" "Newsletter"`,
+		Args: cobra.MaximumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			input, err := shortcutURL(args, cmd.InOrStdin())
+			if err != nil {
+				return &exitCodeError{code: exitInvalidInput, err: err}
+			}
+			var description *string
+			if len(args) == 2 {
+				description = &args[1]
+			}
+			activate, _ := cmd.Flags().GetBool("activate")
+
+			client, err := newClientFromCmd(cmd)
+			if err != nil {
+				return err
+			}
+			alias, err := lookupOrCreateAlias(client, input, description, activate)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), alias.Email)
+			return nil
+		},
+	}
+	cmd.Flags().Bool("activate", false, "create new aliases enabled instead of pending")
+	return cmd
+}
+
+// shortcutURL returns the URL argument, or the first line of stdin if there
+// is none or it is "-".
+func shortcutURL(args []string, stdin io.Reader) (string, error) {
+	if len(args) > 0 && args[0] != "-" {
+		return args[0], nil
+	}
+	if f, ok := stdin.(*os.File); ok && noInput && isTerminal(f) {
+		return "", fmt.Errorf("no URL given as argument: %w", errNoInput)
+	}
+	line, err := bufio.NewReader(stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", fmt.Errorf("failed to read URL from stdin: %w", err)
+	}
+	if strings.TrimSpace(line) == "" {
+		return "", fmt.Errorf("no URL given as argument or on stdin")
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// lookupOrCreateAlias returns the preferred alias for the URL, creating one if
+// there is none. Unlike the default command it prints nothing, for the
+// shortcut command and serve mode.
+func lookupOrCreateAlias(client *FastmailClient, input string, description *string, activate bool) (*MaskedEmailInfo, error) {
+	_, normalizedDomain, err := prepareDomainInput(input)
+	if err != nil {
+		return nil, &exitCodeError{code: exitInvalidInput, err: err}
+	}
+
+	aliases, err := client.GetAliases(normalizedDomain)
+	if err != nil {
+		return nil, formatAPIError("failed to get aliases", err)
+	}
+	if selected := selectPreferredAlias(aliases); selected != nil {
+		return selected, nil
+	}
+
+	creation := AliasCreation{Domain: normalizedDomain, Description: defaultDescription(normalizedDomain, description)}
+	if activate {
+		creation.State = AliasEnabled
+	}
+	alias, err := client.CreateAliasWith(creation)
+	if err != nil {
+		return nil, formatAPIError("failed to create alias", err)
+	}
+	recordUsage(eventCreated)
+	recordOwnership(alias.Email)
+	return alias, nil
+}
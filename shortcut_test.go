@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestShortcutURL(t *testing.T) {
+	tests := []struct {
+		name  string
+		args  []string
+		stdin string
+		want  string
+		err   bool
+	}{
+		{name: "argument", args: []string{"https://example.com/signup"}, stdin: "ignored\n", want: "https://example.com/signup"},
+		{name: "stdin", stdin: " https://example.com \nsecond line\n", want: "https://example.com"},
+		{name: "dash", args: []string{"-", "Newsletter"}, stdin: "example.com", want: "example.com"},
+		{name: "empty stdin", stdin: "\n", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := shortcutURL(tt.args, strings.NewReader(tt.stdin))
+			if (err != nil) != tt.err || got != tt.want {
+				t.Fatalf("shortcutURL() = %q, %v", got, err)
+			}
+		})
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{nil, exitSuccess},
+		{errors.New("boom"), exitFailure},
+		{&exitCodeError{code: exitInvalidInput, err: errors.New("bad url")}, exitInvalidInput},
+		{ErrMissingToken, exitAuth},
+		{unauthorizedError("failed to get aliases"), exitAuth},
+		{fmt.Errorf("failed to create alias: %w", ErrReadOnly), exitReadOnly},
+		{fmt.Errorf("request timed out: %w", context.DeadlineExceeded), exitUnreachable},
+		{fmt.Errorf("failed to get aliases: %w", &url.Error{Op: "Post", Err: &net.OpError{Op: "dial", Err: errors.New("refused")}}), exitUnreachable},
+		{fmt.Errorf("failed: %w", &url.Error{Op: "Post", Err: ErrPinMismatch}), exitFailure},
+		{fmt.Errorf("invalid domain: %w", &url.Error{Op: "parse", URL: "%zz", Err: errors.New("invalid URL escape")}), exitInvalidInput},
+		{fmt.Errorf("failed to read config: %w", os.ErrNotExist), exitFailure},
+		{&os.PathError{Op: "open", Path: "x", Err: syscall.ENOENT}, exitFailure},
+	}
+	for _, tt := range tests {
+		if got := exitCode(tt.err); got != tt.want {
+			t.Errorf("exitCode(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestLookupOrCreateAlias(t *testing.T) {
+	fake, client := newFakeJMAP(t,
+		MaskedEmailInfo{ID: "1", Email: "old@fastmail.com", ForDomain: "https://example.com", State: AliasDisabled},
+		MaskedEmailInfo{ID: "2", Email: "shop@fastmail.com", ForDomain: "https://example.com", State: AliasEnabled},
+	)
+	alias, err := lookupOrCreateAlias(client, "https://example.com/signup", nil, false)
+	if err != nil || alias.Email != "shop@fastmail.com" {
+		t.Fatalf("expected the enabled alias, got %+v, %v", alias, err)
+	}
+
+	description := "Newsletter"
+	alias, err = lookupOrCreateAlias(client, "news.com", &description, true)
+	if err != nil || alias.State != AliasEnabled || alias.Description != description || fake.created != 1 {
+		t.Fatalf("expected a new enabled alias, got %+v, %v", alias, err)
+	}
+
+	_, err = lookupOrCreateAlias(client, "me@example.com", nil, false)
+	if exitCode(err) != exitInvalidInput {
+		t.Fatalf("expected an invalid input error, got %v", err)
+	}
+}
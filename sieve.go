@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+const methodMailboxGet = "Mailbox/get"
+
+// Mailbox is a JMAP mailbox (folder).
+type Mailbox struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	ParentID string `json:"parentId"`
+}
+
+// GetMailboxes retrieves all mailboxes of the account.
+func (fc *FastmailClient) GetMailboxes() ([]Mailbox, error) {
+	response, err := fc.invoke(mailCapabilities, methodCall{
+		name: methodMailboxGet,
+		arguments: map[string]interface{}{
+			"accountId":  fc.AccountID,
+			"properties": []string{"id", "name", "parentId"},
+		},
+		clientID: nil,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var responseData struct {
+		List []Mailbox `json:"list"`
+	}
+	if err := fc.methodResult(response, 0, &responseData); err != nil {
+		return nil, err
+	}
+	return responseData.List, nil
+}
+
+// mailboxPaths maps mailbox IDs to their full path, e.g. "Shopping/Receipts".
+func mailboxPaths(mailboxes []Mailbox) map[string]string {
+	byID := make(map[string]Mailbox, len(mailboxes))
+	for _, mailbox := range mailboxes {
+		byID[mailbox.ID] = mailbox
+	}
+
+	paths := make(map[string]string, len(mailboxes))
+	for _, mailbox := range mailboxes {
+		parts := []string{mailbox.Name}
+		seen := map[string]bool{mailbox.ID: true}
+		for parent, ok := byID[mailbox.ParentID]; ok && !seen[parent.ID]; parent, ok = byID[parent.ParentID] {
+			seen[parent.ID] = true
+			parts = append([]string{parent.Name}, parts...)
+		}
+		paths[mailbox.ID] = strings.Join(parts, "/")
+	}
+	return paths
+}
+
+// findMailbox resolves a folder given by full path or, if unambiguous, by
+// name. Matching is case-insensitive.
+func findMailbox(mailboxes []Mailbox, folder string) (*Mailbox, string, error) {
+	target := strings.Trim(strings.TrimSpace(folder), "/")
+	if target == "" {
+		return nil, "", fmt.Errorf("folder name cannot be empty")
+	}
+
+	paths := mailboxPaths(mailboxes)
+	var byName []int
+	for i, mailbox := range mailboxes {
+		if strings.EqualFold(paths[mailbox.ID], target) {
+			return &mailboxes[i], paths[mailbox.ID], nil
+		}
+		if strings.EqualFold(mailbox.Name, target) {
+			byName = append(byName, i)
+		}
+	}
+
+	switch len(byName) {
+	case 0:
+		return nil, "", fmt.Errorf("folder %q not found", folder)
+	case 1:
+		mailbox := &mailboxes[byName[0]]
+		return mailbox, paths[mailbox.ID], nil
+	default:
+		candidates := make([]string, len(byName))
+		for i, idx := range byName {
+			candidates[i] = paths[mailboxes[idx].ID]
+		}
+		return nil, "", fmt.Errorf("folder %q is ambiguous, use the full path: %s", folder, strings.Join(candidates, ", "))
+	}
+}
+
+// buildSieveRule returns a Sieve rule filing mail addressed to the alias into
+// the mailbox. The mailbox is referenced by ID (RFC 9042) so the rule keeps
+// working if the folder is renamed.
+func buildSieveRule(email string, mailbox Mailbox, path string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "require [\"fileinto\", \"mailboxid\"];\n\n")
+	fmt.Fprintf(&b, "# masked_fastmail: route %s to %s\n", email, path)
+	fmt.Fprintf(&b, "if address :is [\"to\", \"cc\"] %s {\n", sieveQuote(email))
+	fmt.Fprintf(&b, "  fileinto :mailboxid %s %s;\n", sieveQuote(mailbox.ID), sieveQuote(path))
+	fmt.Fprintf(&b, "  stop;\n")
+	fmt.Fprintf(&b, "}\n")
+	return b.String()
+}
+
+// sieveQuote renders s as a Sieve quoted string.
+func sieveQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
+
+// handleFolderRule prints a filter rule routing the alias's mail into the
+// folder and records the route in the local alias metadata.
+func handleFolderRule(client *FastmailClient, alias *MaskedEmailInfo, folder string) error {
+	mailboxes, err := client.GetMailboxes()
+	if err != nil {
+		return formatAPIError("failed to get folders", err)
+	}
+
+	mailbox, path, err := findMailbox(mailboxes, folder)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("\nFilter rule routing %s to %q:\n\n", alias.Email, path)
+	fmt.Print(buildSieveRule(alias.Email, *mailbox, path))
+	fmt.Println()
+	fmt.Println("Fastmail's API does not allow creating filters, so add this rule under")
+	fmt.Println("Settings > Filters & Rules > Edit custom Sieve code.")
+
+	err = updateMetadata(alias.Email, func(entry *AliasMetadata) {
+		entry.Folder = path
+		entry.MailboxID = mailbox.ID
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not save folder for %s: %v\n", alias.Email, err)
+	}
+	return nil
+}
+
+// resolveFolders returns the folder each alias's mail is routed to, based on
+// the rules recorded in the local metadata. Folder paths are looked up by
+// mailbox ID so renamed folders are shown under their current name.
+func resolveFolders(client *FastmailClient, aliases []MaskedEmailInfo) map[string]string {
+	folders := make(map[string]string)
+
+	metadata, err := loadMetadata()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not read alias metadata: %v\n", err)
+		return folders
+	}
+
+	needsLookup := false
+	for _, alias := range aliases {
+		if entry, ok := metadata[alias.Email]; ok && entry.Folder != "" {
+			folders[alias.Email] = entry.Folder
+			needsLookup = needsLookup || entry.MailboxID != ""
+		}
+	}
+	if !needsLookup {
+		return folders
+	}
+
+	mailboxes, err := client.GetMailboxes()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not verify folders, showing saved names: %v\n", formatAPIError("failed to get folders", err))
+		return folders
+	}
+	paths := mailboxPaths(mailboxes)
+
+	for email := range folders {
+		id := metadata[email].MailboxID
+		if id == "" {
+			continue
+		}
+		if path, ok := paths[id]; ok {
+			folders[email] = path
+		} else {
+			folders[email] = fmt.Sprintf("%s (folder no longer exists)", metadata[email].Folder)
+		}
+	}
+	return folders
+}
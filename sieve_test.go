@@ -0,0 +1,47 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFindMailbox(t *testing.T) {
+	mailboxes := []Mailbox{
+		{ID: "1", Name: "Inbox"},
+		{ID: "2", Name: "Shopping"},
+		{ID: "3", Name: "Receipts", ParentID: "2"},
+		{ID: "4", Name: "Work"},
+		{ID: "5", Name: "Receipts", ParentID: "4"},
+	}
+
+	mailbox, path, err := findMailbox(mailboxes, "shopping/receipts")
+	if err != nil {
+		t.Fatalf("findMailbox returned error: %v", err)
+	}
+	if mailbox.ID != "3" || path != "Shopping/Receipts" {
+		t.Fatalf("expected Shopping/Receipts, got %s (%s)", path, mailbox.ID)
+	}
+
+	if _, path, err := findMailbox(mailboxes, "Work"); err != nil || path != "Work" {
+		t.Fatalf("expected unique name to resolve, got %q (%v)", path, err)
+	}
+
+	if _, _, err := findMailbox(mailboxes, "Receipts"); err == nil {
+		t.Fatalf("expected ambiguous folder name to error")
+	}
+
+	if _, _, err := findMailbox(mailboxes, "Missing"); err == nil {
+		t.Fatalf("expected missing folder to error")
+	}
+}
+
+func TestBuildSieveRule(t *testing.T) {
+	rule := buildSieveRule("user@fastmail.com", Mailbox{ID: "P1"}, `Say "hi"`)
+
+	if !strings.Contains(rule, `address :is ["to", "cc"] "user@fastmail.com"`) {
+		t.Fatalf("expected rule to match the alias address, got:\n%s", rule)
+	}
+	if !strings.Contains(rule, `fileinto :mailboxid "P1" "Say \"hi\""`) {
+		t.Fatalf("expected folder name to be quoted, got:\n%s", rule)
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// confusables maps characters and sequences that are easily mistaken for one
+// another when read or typed to a common form.
+var confusables = strings.NewReplacer(
+	"rn", "m",
+	"vv", "w",
+	"cl", "d",
+	"0", "o",
+	"1", "l",
+	"i", "l",
+	"5", "s",
+	"8", "b",
+)
+
+// aliasLocalPart returns the part of an email address before the @.
+func aliasLocalPart(email string) string {
+	local, _, _ := strings.Cut(strings.ToLower(email), "@")
+	return local
+}
+
+// aliasPrefix returns the local part without the trailing number Fastmail
+// appends to generated aliases, e.g. "neat.sun" for "neat.sun1234@fastmail.com".
+func aliasPrefix(email string) string {
+	return strings.TrimRight(aliasLocalPart(email), "0123456789")
+}
+
+// similarity explains why two aliases may be confused, or returns "" if they
+// are distinct enough.
+func similarity(a, b string) string {
+	localA, localB := aliasLocalPart(a), aliasLocalPart(b)
+	switch {
+	case localA == localB:
+		return "same name at a different domain"
+	case aliasPrefix(a) != "" && aliasPrefix(a) == aliasPrefix(b):
+		return "same prefix"
+	case confusables.Replace(localA) == confusables.Replace(localB):
+		return "visually confusable"
+	case editDistance(localA, localB) <= 1:
+		return "differs by one character"
+	}
+	return ""
+}
+
+// similarAlias is an alias that may be confused with another.
+type similarAlias struct {
+	Alias  MaskedEmailInfo
+	Reason string
+}
+
+// findSimilarAliases returns the aliases that may be confused with email,
+// ordered by email. The alias itself and deleted aliases are ignored.
+func findSimilarAliases(email string, aliases []MaskedEmailInfo) []similarAlias {
+	var similar []similarAlias
+	for _, alias := range aliases {
+		if strings.EqualFold(alias.Email, email) || alias.State == AliasDeleted {
+			continue
+		}
+		if reason := similarity(email, alias.Email); reason != "" {
+			similar = append(similar, similarAlias{Alias: alias, Reason: reason})
+		}
+	}
+	sort.Slice(similar, func(i, j int) bool {
+		return similar[i].Alias.Email < similar[j].Alias.Email
+	})
+	return similar
+}
+
+// editDistance returns the Levenshtein distance between two strings.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(rb)]
+}
+
+// warnSimilarAliases prints a warning if a newly created alias may be
+// confused with an existing one. Failures are reported but not fatal.
+func warnSimilarAliases(client *FastmailClient, alias *MaskedEmailInfo) {
+	aliases, err := client.FetchAllAliases()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not check for similar aliases: %v\n", formatAPIError("failed to get aliases", err))
+		return
+	}
+
+	similar := findSimilarAliases(alias.Email, aliases)
+	if len(similar) == 0 {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Warning: %s looks similar to existing aliases:\n", alias.Email)
+	for _, match := range similar {
+		fmt.Fprintf(os.Stderr, "- %s (%s, %s)\n", match.Alias.Email, match.Reason, describeAliasOrigin(match.Alias))
+	}
+}
+
+// describeAliasOrigin returns the domain or description identifying an alias.
+func describeAliasOrigin(alias MaskedEmailInfo) string {
+	switch {
+	case alias.ForDomain != "":
+		return sanitizeText(alias.ForDomain)
+	case alias.Description != "":
+		return sanitizeText(alias.Description)
+	}
+	return "no domain"
+}
+
+// newSimilarCmd creates the command listing aliases that may be confused
+// with a given alias.
+func newSimilarCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "similar <alias>",
+		Short: "List aliases that are easily confused with an alias when typed or read",
+		Long: `List aliases that are easily confused with an alias when typed or read: aliases
+with the same prefix, visually confusable characters (such as 1 and l, or rn
+and m), or that differ by a single character.`,
+		Example: `  masked_fastmail similar neat.sun1234@fastmail.com`,
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			email, err := normalizeEmailInput(args[0])
+			if err != nil {
+				return err
+			}
+			client, err := newClientFromCmd(cmd)
+			if err != nil {
+				return err
+			}
+			return handleSimilar(client, email)
+		},
+	}
+}
+
+// handleSimilar prints the aliases that may be confused with email.
+func handleSimilar(client *FastmailClient, email string) error {
+	aliases, err := client.FetchAllAliases()
+	if err != nil {
+		return formatAPIError("failed to get aliases", err)
+	}
+
+	similar := findSimilarAliases(email, aliases)
+	if porcelain != "" {
+		// email, state, reason, forDomain
+		for _, match := range similar {
+			printPorcelain(match.Alias.Email, string(match.Alias.State), match.Reason, match.Alias.ForDomain)
+		}
+		return nil
+	}
+	if len(similar) == 0 {
+		fmt.Printf("No aliases similar to %s\n", email)
+		return nil
+	}
+
+	fmt.Printf("Aliases similar to %s:\n", email)
+	for _, match := range similar {
+		fmt.Printf("- %s (state: %s)\n", match.Alias.Email, match.Alias.State)
+		fmt.Printf("  %s; %s\n", match.Reason, describeAliasOrigin(match.Alias))
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import "testing"
+
+func TestSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected string
+	}{
+		{"neat.sun1234@fastmail.com", "neat.sun5678@fastmail.com", "same prefix"},
+		{"neat.sun1234@fastmail.com", "neat.sun1234@example.com", "same name at a different domain"},
+		{"corn.hill12@fastmail.com", "com.hill12@fastmail.com", "visually confusable"},
+		{"bold.tree42@fastmail.com", "bo1d.tree42@fastmail.com", "visually confusable"},
+		{"bold.tree42@fastmail.com", "bold.tree43@fastmail.com", "same prefix"},
+		{"fast.river1@fastmail.com", "fast.rover1@fastmail.com", "differs by one character"},
+		{"neat.sun1234@fastmail.com", "calm.lake9876@fastmail.com", ""},
+		{"1234@fastmail.com", "5678@fastmail.com", ""},
+	}
+
+	for _, tt := range tests {
+		if got := similarity(tt.a, tt.b); got != tt.expected {
+			t.Errorf("similarity(%q, %q) = %q, want %q", tt.a, tt.b, got, tt.expected)
+		}
+	}
+}
+
+func TestFindSimilarAliases(t *testing.T) {
+	aliases := []MaskedEmailInfo{
+		{Email: "neat.sun1234@fastmail.com", State: AliasEnabled},
+		{Email: "neat.sun9999@fastmail.com", State: AliasEnabled},
+		{Email: "neat.sun5678@fastmail.com", State: AliasDeleted},
+		{Email: "calm.lake1@fastmail.com", State: AliasEnabled},
+	}
+
+	similar := findSimilarAliases("neat.sun1234@fastmail.com", aliases)
+	if len(similar) != 1 || similar[0].Alias.Email != "neat.sun9999@fastmail.com" {
+		t.Fatalf("expected only the active alias with the same prefix, got %+v", similar)
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"kitten", "sitting", 3},
+		{"river", "rover", 1},
+	}
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.expected {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.expected)
+		}
+	}
+}
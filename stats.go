@@ -0,0 +1,285 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	statsFile     = "stats.json"
+	statsMonthFmt = "2006-01"
+	eventCreated  = "aliases created"
+)
+
+// usageStats holds locally recorded usage counts. Nothing is recorded unless
+// the user opts in, and nothing is ever transmitted.
+type usageStats struct {
+	Enabled bool `json:"enabled"`
+	// Months maps "YYYY-MM" to per-event counts
+	Months map[string]map[string]int `json:"months,omitempty"`
+}
+
+func loadStats() (*usageStats, error) {
+	stats := &usageStats{}
+	if err := readJSONFile(statsFile, stats); err != nil {
+		return nil, err
+	}
+	if stats.Months == nil {
+		stats.Months = make(map[string]map[string]int)
+	}
+	return stats, nil
+}
+
+// record increments the count of an event in the month of t.
+func (s *usageStats) record(event string, t time.Time) {
+	month := t.Format(statsMonthFmt)
+	if s.Months[month] == nil {
+		s.Months[month] = make(map[string]int)
+	}
+	s.Months[month][event]++
+}
+
+// recordUsage counts an event in the local usage statistics if the user has
+// opted in. Failures are ignored since statistics are never essential.
+func recordUsage(event string) {
+	stats, err := loadStats()
+	if err != nil || !stats.Enabled {
+		return
+	}
+	stats.record(event, time.Now())
+	_ = writeJSONFile(statsFile, stats)
+}
+
+// commandEvent names the action performed by a command for usage statistics,
+// or returns "" for commands that are not counted.
+func commandEvent(cmd *cobra.Command) string {
+	if cmd.HasParent() {
+		path := strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()+" ")
+		if path == "stats" || strings.HasPrefix(path, "stats ") {
+			return ""
+		}
+		return path
+	}
+
+	if cmd.Flags().Changed("version") {
+		return ""
+	}
+
+	for _, flag := range []string{"list", "enable", "disable", "delete", "set-description"} {
+		if cmd.Flags().Changed(flag) {
+			return flag
+		}
+	}
+	return "lookup"
+}
+
+// newStatsCmd creates the command group for the opt-in local usage statistics.
+func newStatsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "stats",
+		Short: "Opt-in usage statistics, recorded and kept only on this machine",
+		Long: `Opt-in usage statistics, recorded and kept only on this machine.
+Once enabled, the tool counts which commands you run and how many aliases you
+create per month. Nothing is ever transmitted.`,
+	}
+
+	setEnabled := func(enabled bool) func(*cobra.Command, []string) error {
+		return func(cmd *cobra.Command, args []string) error {
+			stats, err := loadStats()
+			if err != nil {
+				return err
+			}
+			stats.Enabled = enabled
+			if err := writeJSONFile(statsFile, stats); err != nil {
+				return fmt.Errorf("failed to save statistics settings: %w", err)
+			}
+			if enabled {
+				fmt.Println("Local usage statistics enabled.")
+			} else {
+				fmt.Println("Local usage statistics disabled. Existing statistics are kept; use `stats reset` to remove them.")
+			}
+			return nil
+		}
+	}
+
+	cmd.AddCommand(
+		&cobra.Command{
+			Use:   "enable",
+			Short: "Start recording usage statistics locally",
+			Args:  cobra.NoArgs,
+			RunE:  setEnabled(true),
+		},
+		&cobra.Command{
+			Use:   "disable",
+			Short: "Stop recording usage statistics",
+			Args:  cobra.NoArgs,
+			RunE:  setEnabled(false),
+		},
+		&cobra.Command{
+			Use:   "show",
+			Short: "Show recorded usage statistics",
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				stats, err := loadStats()
+				if err != nil {
+					return err
+				}
+				printStats(stats)
+				return nil
+			},
+		},
+		&cobra.Command{
+			Use:   "creations",
+			Short: "Show the aliases created per day and week, against the creation quotas",
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				creations := creationLog{}
+				if err := readJSONFile(creationLogFile, &creations); err != nil {
+					return err
+				}
+				printCreationTrend(creations, time.Now())
+				return nil
+			},
+		},
+		&cobra.Command{
+			Use:   "reset",
+			Short: "Delete all recorded usage statistics",
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				stats, err := loadStats()
+				if err != nil {
+					return err
+				}
+				stats.Months = nil
+				if err := writeJSONFile(statsFile, stats); err != nil {
+					return fmt.Errorf("failed to reset statistics: %w", err)
+				}
+				fmt.Println("Usage statistics deleted.")
+				return nil
+			},
+		},
+	)
+	return cmd
+}
+
+// sortedKeys returns the keys of a map in ascending order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// printStats prints the recorded statistics, newest month first.
+func printStats(stats *usageStats) {
+	if porcelain != "" {
+		// month, event, count
+		for _, month := range sortedKeys(stats.Months) {
+			for _, event := range sortedKeys(stats.Months[month]) {
+				printPorcelain(month, event, strconv.Itoa(stats.Months[month][event]))
+			}
+		}
+		return
+	}
+
+	location := statsFile
+	if dir, err := dataDir(); err == nil {
+		location = filepath.Join(dir, statsFile)
+		if storageBackend != storageJSON {
+			location = fmt.Sprintf("the %s storage in %s", storageBackend, dir)
+		}
+	}
+
+	if !stats.Enabled {
+		fmt.Println("Local usage statistics are disabled. Run `masked_fastmail stats enable` to start recording.")
+	}
+	if len(stats.Months) == 0 {
+		fmt.Println("No usage recorded yet.")
+		return
+	}
+
+	months := make([]string, 0, len(stats.Months))
+	for month := range stats.Months {
+		months = append(months, month)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(months)))
+
+	fmt.Printf("Usage statistics (stored in %s):\n", location)
+	for _, month := range months {
+		events := stats.Months[month]
+		var commands []string
+		for event, count := range events {
+			if event != eventCreated {
+				commands = append(commands, fmt.Sprintf("%s %d", event, count))
+			}
+		}
+		sort.Strings(commands)
+
+		fmt.Printf("\n%s\n", month)
+		fmt.Printf("  Aliases created: %d\n", events[eventCreated])
+		if len(commands) > 0 {
+			fmt.Printf("  Commands:        %s\n", strings.Join(commands, ", "))
+		}
+	}
+}
+
+// printCreationTrend prints the creations of the last 7 days and, by week,
+// of the last 8 weeks, with the quotas they are held to.
+func printCreationTrend(creations creationLog, now time.Time) {
+	type period struct {
+		kind  string
+		start time.Time
+		count int
+		limit int
+	}
+	var periods []period
+	for i := 6; i >= 0; i-- {
+		day := now.AddDate(0, 0, -i)
+		periods = append(periods, period{"day", day, creations.since(day, 1), maxCreationsPerDay})
+	}
+	for i := 7; i >= 0; i-- {
+		end := now.AddDate(0, 0, -7*i)
+		periods = append(periods, period{"week", end.AddDate(0, 0, -6), creations.since(end, 7), maxCreationsPerWeek})
+	}
+
+	if porcelain != "" {
+		// period (day|week), start date, count, quota (0 if none)
+		for _, p := range periods {
+			printPorcelain(p.kind, p.start.Format(time.DateOnly), strconv.Itoa(p.count), strconv.Itoa(p.limit))
+		}
+		return
+	}
+
+	if len(creations) == 0 {
+		fmt.Println("No creations recorded. They are recorded when a creation quota is configured or usage statistics are enabled.")
+		return
+	}
+	heading := map[string]string{"day": "Last 7 days", "week": "Last 8 weeks (7 days ending on the date)"}
+	last := ""
+	for _, p := range periods {
+		if p.kind != last {
+			if last != "" {
+				fmt.Println()
+			}
+			fmt.Println(heading[p.kind] + ":")
+			last = p.kind
+		}
+		date := p.start
+		if p.kind == "week" {
+			date = p.start.AddDate(0, 0, 6)
+		}
+		line := fmt.Sprintf("  %s  %3d %s", date.Format(time.DateOnly), p.count, strings.Repeat("#", min(p.count, 50)))
+		if p.limit > 0 && p.count > p.limit {
+			line += fmt.Sprintf("  over the quota of %d", p.limit)
+		}
+		fmt.Println(line)
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func TestUsageStatsRecord(t *testing.T) {
+	t.Setenv(dataDirEnv, t.TempDir())
+
+	stats, err := loadStats()
+	if err != nil {
+		t.Fatalf("loadStats returned error: %v", err)
+	}
+	if stats.Enabled {
+		t.Fatalf("expected statistics to be disabled by default")
+	}
+
+	june := time.Date(2024, 6, 30, 23, 0, 0, 0, time.UTC)
+	july := time.Date(2024, 7, 1, 8, 0, 0, 0, time.UTC)
+	stats.record(eventCreated, june)
+	stats.record(eventCreated, june)
+	stats.record("list", july)
+
+	if err := writeJSONFile(statsFile, stats); err != nil {
+		t.Fatalf("writeJSONFile returned error: %v", err)
+	}
+	loaded, err := loadStats()
+	if err != nil {
+		t.Fatalf("loadStats returned error: %v", err)
+	}
+	if got := loaded.Months["2024-06"][eventCreated]; got != 2 {
+		t.Errorf("expected 2 creations in 2024-06, got %d", got)
+	}
+	if got := loaded.Months["2024-07"]["list"]; got != 1 {
+		t.Errorf("expected 1 list in 2024-07, got %d", got)
+	}
+
+	recordUsage(eventCreated)
+	if loaded, err = loadStats(); err != nil || len(loaded.Months) != 2 {
+		t.Fatalf("expected disabled statistics to record nothing, got %v, %v", loaded, err)
+	}
+	loaded.Enabled = true
+	if err := writeJSONFile(statsFile, loaded); err != nil {
+		t.Fatalf("writeJSONFile returned error: %v", err)
+	}
+	recordUsage(eventCreated)
+	if loaded, err = loadStats(); err != nil {
+		t.Fatalf("loadStats returned error: %v", err)
+	}
+	if got := loaded.Months[time.Now().Format(statsMonthFmt)][eventCreated]; got != 1 {
+		t.Errorf("expected recordUsage to count 1 creation this month, got %d", got)
+	}
+}
+
+func TestCommandEvent(t *testing.T) {
+	newRoot := func() *cobra.Command {
+		root := &cobra.Command{Use: "masked_fastmail"}
+		root.Flags().Bool("list", false, "")
+		root.Flags().Bool("disable", false, "")
+		root.Flags().Bool("version", false, "")
+		return root
+	}
+
+	tests := []struct {
+		name     string
+		setup    func() *cobra.Command
+		expected string
+	}{
+		{
+			name:     "lookup",
+			setup:    newRoot,
+			expected: "lookup",
+		},
+		{
+			name: "root action flag",
+			setup: func() *cobra.Command {
+				root := newRoot()
+				_ = root.Flags().Set("disable", "true")
+				return root
+			},
+			expected: "disable",
+		},
+		{
+			name: "version is not counted",
+			setup: func() *cobra.Command {
+				root := newRoot()
+				_ = root.Flags().Set("version", "true")
+				return root
+			},
+			expected: "",
+		},
+		{
+			name: "subcommand",
+			setup: func() *cobra.Command {
+				root := newRoot()
+				accounts := &cobra.Command{Use: "accounts"}
+				list := &cobra.Command{Use: "list"}
+				accounts.AddCommand(list)
+				root.AddCommand(accounts)
+				return list
+			},
+			expected: "accounts list",
+		},
+		{
+			name: "stats commands are not counted",
+			setup: func() *cobra.Command {
+				root := newRoot()
+				stats := newStatsCmd()
+				root.AddCommand(stats)
+				show, _, _ := stats.Find([]string{"show"})
+				return show
+			},
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := commandEvent(tt.setup()); got != tt.expected {
+				t.Errorf("commandEvent() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestPrintCreationTrend(t *testing.T) {
+	oldWeek := maxCreationsPerWeek
+	t.Cleanup(func() { maxCreationsPerWeek = oldWeek })
+	maxCreationsPerWeek = 3
+
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	out := captureStdout(t, func() {
+		printCreationTrend(creationLog{"2026-10-16": 2, "2026-10-14": 3}, now)
+	})
+	for _, want := range []string{
+		"Last 7 days:",
+		"  2026-10-16    2 ##\n",
+		"  2026-10-16    5 #####  over the quota of 3\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output is missing %q:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultStatusURL is Fastmail's status page summary in the Statuspage format
+	defaultStatusURL = "https://fastmailstatus.com/api/v2/status.json"
+	// serverErrorsBeforeStatusCheck is how many server errors in a row make
+	// the client check the status page
+	serverErrorsBeforeStatusCheck = 2
+	statusCheckTimeout            = 5 * time.Second
+	maxStatusResponse             = 64 << 10
+)
+
+// statusURL is the status page queried after repeated server errors; it can
+// be changed with status_url in the config file, or set to "" to disable the check.
+var statusURL = defaultStatusURL
+
+// statusPage is the part of a Statuspage status.json document the client uses.
+type statusPage struct {
+	Status struct {
+		// Indicator is "none" when all systems are operational, otherwise
+		// "minor", "major" or "critical"
+		Indicator   string `json:"indicator"`
+		Description string `json:"description"`
+	} `json:"status"`
+}
+
+// parseStatusPage returns the incident described by a status page, or "" if
+// it reports no problems.
+func parseStatusPage(r io.Reader) (string, error) {
+	var page statusPage
+	if err := json.NewDecoder(r).Decode(&page); err != nil {
+		return "", fmt.Errorf("failed to parse status page: %w", err)
+	}
+	indicator := strings.ToLower(page.Status.Indicator)
+	if indicator == "" || indicator == "none" {
+		return "", nil
+	}
+	if page.Status.Description == "" {
+		return indicator, nil
+	}
+	return page.Status.Description, nil
+}
+
+// recordServerError counts consecutive server errors and, once they repeat,
+// adds any incident reported on the status page to the error.
+// The status page is checked at most once per client.
+func (fc *FastmailClient) recordServerError(apiErr *APIError) {
+	fc.serverErrors++
+	if fc.serverErrors < serverErrorsBeforeStatusCheck || statusURL == "" {
+		return
+	}
+	if !fc.statusChecked {
+		fc.statusChecked = true
+		incident, err := fc.checkStatusPage()
+		if err != nil {
+			fc.debugf("Could not check the status page: %v", err)
+		}
+		fc.incident = incident
+	}
+	apiErr.Incident = fc.incident
+}
+
+// checkStatusPage fetches the status page and returns the incident it
+// reports, if any. Failures are returned so the caller can ignore them; the
+// check is a hint and must not replace the original error.
+func (fc *FastmailClient) checkStatusPage() (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), statusCheckTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", statusURL, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := fc.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("status page returned %s", resp.Status)
+	}
+	return parseStatusPage(io.LimitReader(resp.Body, maxStatusResponse))
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseStatusPage(t *testing.T) {
+	incident, err := parseStatusPage(strings.NewReader(`{"status": {"indicator": "none", "description": "All Systems Operational"}}`))
+	if err != nil || incident != "" {
+		t.Fatalf("expected no incident, got %q, %v", incident, err)
+	}
+
+	incident, err = parseStatusPage(strings.NewReader(`{"status": {"indicator": "major", "description": "Partial System Outage"}}`))
+	if err != nil || incident != "Partial System Outage" {
+		t.Fatalf("expected the incident description, got %q, %v", incident, err)
+	}
+
+	if _, err := parseStatusPage(strings.NewReader("<html>")); err == nil {
+		t.Fatalf("expected an error for a page that is not JSON")
+	}
+}
+
+func TestRepeatedServerErrorsCheckStatusPage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/status.json" {
+			io.WriteString(w, `{"status": {"indicator": "major", "description": "Partial System Outage"}}`)
+			return
+		}
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	oldStatusURL := statusURL
+	statusURL = server.URL + "/status.json"
+	defer func() { statusURL = oldStatusURL }()
+
+	client := &FastmailClient{Token: "token", client: server.Client()}
+	request := func() error {
+		req, err := http.NewRequest("GET", server.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return client.doHTTP(req, nil, opDefault, func(io.Reader) error { return nil })
+	}
+
+	if err := request(); strings.Contains(err.Error(), "incident") {
+		t.Fatalf("expected a single server error not to check the status page, got %q", err)
+	}
+	err := request()
+	if !strings.Contains(err.Error(), "Fastmail is reporting an incident: Partial System Outage") {
+		t.Fatalf("expected the incident in the error, got %q", err)
+	}
+}
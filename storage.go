@@ -0,0 +1,120 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Storage backends for the local data
+const (
+	storageJSON   = "json"
+	storageSQLite = "sqlite"
+)
+
+// storageBackend is the backend selected by the storage setting
+var storageBackend = storageJSON
+
+// dataStore keeps the documents of the local data, such as the alias
+// metadata and the usage statistics, by name.
+type dataStore interface {
+	// Read decodes the named document into v; a missing document leaves v
+	// untouched
+	Read(name string, v interface{}) error
+	// Write replaces the named document with v
+	Write(name string, v interface{}) error
+	// Update reads the named document into v, calls fn and writes v back
+	// unless fn fails. Backends that can do so make this atomic, so that
+	// concurrent runs don't lose each other's changes.
+	Update(name string, v interface{}, fn func() error) error
+	// Delete removes the named document; a missing document is not an error
+	Delete(name string) error
+	Close() error
+}
+
+// storageBackends opens each available backend in a data directory. The
+// SQLite backend registers itself in builds with the sqlite tag.
+var storageBackends = map[string]func(dir string) (dataStore, error){
+	storageJSON: openJSONStore,
+}
+
+// storageNames returns the names of the available backends, sorted.
+func storageNames() []string {
+	names := make([]string, 0, len(storageBackends))
+	for name := range storageBackends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// validateStorage checks that a backend is available in this build.
+func validateStorage(name string) error {
+	if _, ok := storageBackends[name]; ok {
+		return nil
+	}
+	if name == storageSQLite {
+		return fmt.Errorf("storage %q is not available in this build; rebuild with -tags sqlite", name)
+	}
+	return fmt.Errorf("unknown storage %q (expected one of: %s)", name, strings.Join(storageNames(), ", "))
+}
+
+// openDataStore opens the selected backend in the data directory.
+func openDataStore() (dataStore, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to locate data directory: %w", err)
+	}
+	if err := validateStorage(storageBackend); err != nil {
+		return nil, err
+	}
+	return storageBackends[storageBackend](dir)
+}
+
+// jsonStore keeps each document in a JSON file of the data directory. It is
+// the default backend.
+type jsonStore struct {
+	dir string
+}
+
+func openJSONStore(dir string) (dataStore, error) {
+	return jsonStore{dir: dir}, nil
+}
+
+func (s jsonStore) Read(name string, v interface{}) error {
+	err := readJSONPath(filepath.Join(s.dir, name), v)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (s jsonStore) Write(name string, v interface{}) error {
+	return writeJSONPath(filepath.Join(s.dir, name), v)
+}
+
+// Update is not atomic: a concurrent run may overwrite the changes.
+func (s jsonStore) Update(name string, v interface{}, fn func() error) error {
+	if err := s.Read(name, v); err != nil {
+		return err
+	}
+	if err := fn(); err != nil {
+		return err
+	}
+	return s.Write(name, v)
+}
+
+func (s jsonStore) Delete(name string) error {
+	err := os.Remove(filepath.Join(s.dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (s jsonStore) Close() error {
+	return nil
+}
@@ -0,0 +1,131 @@
+//go:build sqlite
+
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// sqliteFile is the database of the SQLite storage in the data directory
+const sqliteFile = "data.db"
+
+func init() {
+	storageBackends[storageSQLite] = openSQLiteStore
+}
+
+// sqliteStore keeps the documents in a SQLite database, which several runs
+// can use at once: updates are transactions, and a run waits for another's
+// transaction instead of failing. Documents not in the database yet are read
+// from the JSON files of the default storage, so switching keeps the data.
+type sqliteStore struct {
+	db       *sql.DB
+	fallback jsonStore
+}
+
+func openSQLiteStore(dir string) (dataStore, error) {
+	if err := os.MkdirAll(dir, privateDirMode); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, sqliteFile)
+	// Transactions take the write lock when they start, so that two updates
+	// can't both read the old document and then fail to upgrade their lock
+	dsn := "file:" + path + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_txlock=immediate"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS documents (
+		name       TEXT PRIMARY KEY,
+		data       TEXT NOT NULL,
+		updated_at TEXT NOT NULL
+	)`)
+	if err == nil {
+		err = os.Chmod(path, privateFileMode)
+	}
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	return &sqliteStore{db: db, fallback: jsonStore{dir: dir}}, nil
+}
+
+// sqlQueryer is implemented by *sql.DB and *sql.Tx.
+type sqlQueryer interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+func (s *sqliteStore) read(q sqlQueryer, name string, v interface{}) error {
+	var data string
+	err := q.QueryRow(`SELECT data FROM documents WHERE name = ?`, name).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return s.fallback.Read(name, v)
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal([]byte(data), v); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	return nil
+}
+
+func (s *sqliteStore) write(q sqlQueryer, name string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = q.Exec(`INSERT INTO documents (name, data, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT (name) DO UPDATE SET data = excluded.data, updated_at = excluded.updated_at`,
+		name, string(data), time.Now().UTC().Format(time.RFC3339))
+	return err
+}
+
+func (s *sqliteStore) Read(name string, v interface{}) error {
+	return s.read(s.db, name, v)
+}
+
+func (s *sqliteStore) Write(name string, v interface{}) error {
+	return s.write(s.db, name, v)
+}
+
+func (s *sqliteStore) Update(name string, v interface{}, fn func() error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := s.read(tx, name, v); err != nil {
+		return err
+	}
+	if err := fn(); err != nil {
+		return err
+	}
+	if err := s.write(tx, name, v); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Delete also removes the JSON file of the document, which reads would
+// otherwise fall back to.
+func (s *sqliteStore) Delete(name string) error {
+	if _, err := s.db.Exec(`DELETE FROM documents WHERE name = ?`, name); err != nil {
+		return err
+	}
+	return s.fallback.Delete(name)
+}
+
+func (s *sqliteStore) Close() error {
+	return s.db.Close()
+}
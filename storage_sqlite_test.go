@@ -0,0 +1,43 @@
+//go:build sqlite
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSQLiteStore(t *testing.T) {
+	dir := t.TempDir()
+	// A document saved by the JSON storage before switching
+	if err := writeJSONPath(filepath.Join(dir, metadataFile), map[string]AliasMetadata{"a@fastmail.com": {Folder: "Shops"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := openSQLiteStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	metadata := map[string]AliasMetadata{}
+	err = store.Update(metadataFile, &metadata, func() error {
+		metadata["b@fastmail.com"] = AliasMetadata{Protected: true}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := map[string]AliasMetadata{}
+	if err := store.Read(metadataFile, &loaded); err != nil {
+		t.Fatal(err)
+	}
+	if loaded["a@fastmail.com"].Folder != "Shops" || !loaded["b@fastmail.com"].Protected {
+		t.Fatalf("expected the JSON data and the update, got %+v", loaded)
+	}
+	if _, err := os.Stat(filepath.Join(dir, sqliteFile)); err != nil {
+		t.Fatalf("expected the database in the data directory: %v", err)
+	}
+}
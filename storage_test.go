@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestJSONStoreUpdate(t *testing.T) {
+	dir := t.TempDir()
+	store, _ := openJSONStore(dir)
+
+	counts := map[string]int{}
+	err := store.Update("counts.json", &counts, func() error {
+		counts["runs"]++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	failed := errors.New("stop")
+	err = store.Update("counts.json", &counts, func() error {
+		counts["runs"] = 100
+		return failed
+	})
+	if !errors.Is(err, failed) {
+		t.Fatalf("expected the error of fn, got %v", err)
+	}
+
+	loaded := map[string]int{}
+	if err := store.Read("counts.json", &loaded); err != nil {
+		t.Fatal(err)
+	}
+	if loaded["runs"] != 1 {
+		t.Fatalf("expected only the successful update to be saved, got %v", loaded)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "counts.json")); err != nil {
+		t.Fatalf("expected a JSON file in the data directory: %v", err)
+	}
+}
+
+func TestValidateStorage(t *testing.T) {
+	if err := validateStorage(storageJSON); err != nil {
+		t.Fatalf("expected json storage to be available: %v", err)
+	}
+	if err := validateStorage("postgres"); err == nil || !strings.Contains(err.Error(), "unknown storage") {
+		t.Fatalf("expected an unknown storage error, got %v", err)
+	}
+	if _, ok := storageBackends[storageSQLite]; !ok {
+		if err := validateStorage(storageSQLite); err == nil || !strings.Contains(err.Error(), "-tags sqlite") {
+			t.Fatalf("expected a hint to build with the sqlite tag, got %v", err)
+		}
+	}
+}
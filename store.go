@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+const (
+	appDirName      = "masked_fastmail"
+	metadataFile    = "aliases.json"
+	dataDirEnv      = "MASKED_FASTMAIL_DATA_DIR"
+	privateDirMode  = 0o700
+	privateFileMode = 0o600
+)
+
+// AliasMetadata is information about an alias that Fastmail does not store,
+// kept locally and keyed by alias email.
+type AliasMetadata struct {
+	// Folder is the mailbox a generated filter rule routes mail to
+	Folder string `json:"folder,omitempty"`
+	// MailboxID is the JMAP ID of Folder
+	MailboxID string `json:"mailboxId,omitempty"`
+	// Protected aliases are not deleted without --force
+	Protected bool `json:"protected,omitempty"`
+	// Owner is the local user who created the alias with this tool
+	Owner string `json:"owner,omitempty"`
+	// CreatedOn is the host name of the machine the alias was created on
+	CreatedOn string `json:"createdOn,omitempty"`
+}
+
+// dataDir returns the directory holding local data such as alias metadata.
+// MASKED_FASTMAIL_DATA_DIR overrides the platform default.
+func dataDir() (string, error) {
+	if dir := os.Getenv(dataDirEnv); dir != "" {
+		return dir, nil
+	}
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, appDirName), nil
+	}
+	// Termux builds report android but follow the Linux layout
+	if runtime.GOOS == "linux" || runtime.GOOS == "android" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(home, ".local", "share", appDirName), nil
+	}
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, appDirName), nil
+}
+
+// readJSONFile decodes a document of the local data into v. A missing
+// document leaves v untouched. With the default storage, the document is a
+// JSON file in the data directory.
+func readJSONFile(name string, v interface{}) error {
+	store, err := openDataStore()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	return store.Read(name, v)
+}
+
+// readJSONPath decodes the JSON file at path into v.
+func readJSONPath(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return nil
+}
+
+// writeJSONFile replaces a document of the local data with v.
+func writeJSONFile(name string, v interface{}) error {
+	store, err := openDataStore()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	return store.Write(name, v)
+}
+
+// updateJSONFile reads a document of the local data into v, calls fn and
+// saves v, as a single transaction if the storage supports it.
+func updateJSONFile(name string, v interface{}, fn func() error) error {
+	store, err := openDataStore()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	return store.Update(name, v, fn)
+}
+
+// deleteJSONFile removes a document of the local data, if it exists.
+func deleteJSONFile(name string) error {
+	store, err := openDataStore()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	return store.Delete(name)
+}
+
+// writeJSONPath atomically replaces the file at path with the JSON encoding
+// of v, creating its directory if needed. The file is only readable by the
+// current user.
+func writeJSONPath(path string, v interface{}) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, privateDirMode); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, append(data, '\n'), privateFileMode)
+}
+
+// writeFileAtomic replaces the file at path with data through a temporary
+// file in the same directory, so that readers never see a partial file.
+func writeFileAtomic(path string, data []byte, mode os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// loadMetadata reads the local alias metadata.
+func loadMetadata() (map[string]AliasMetadata, error) {
+	metadata := make(map[string]AliasMetadata)
+	if err := readJSONFile(metadataFile, &metadata); err != nil {
+		return nil, err
+	}
+	return metadata, nil
+}
+
+// updateMetadata applies fn to the stored metadata for an alias and saves it.
+func updateMetadata(email string, fn func(*AliasMetadata)) error {
+	metadata := make(map[string]AliasMetadata)
+	return updateJSONFile(metadataFile, &metadata, func() error {
+		entry := metadata[email]
+		fn(&entry)
+		if entry == (AliasMetadata{}) {
+			delete(metadata, email)
+		} else {
+			metadata[email] = entry
+		}
+		return nil
+	})
+}
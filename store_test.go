@@ -0,0 +1,33 @@
+package main
+
+import "testing"
+
+func TestUpdateMetadata(t *testing.T) {
+	t.Setenv(dataDirEnv, t.TempDir())
+
+	err := updateMetadata("user@fastmail.com", func(entry *AliasMetadata) {
+		entry.Folder = "Shopping"
+	})
+	if err != nil {
+		t.Fatalf("updateMetadata returned error: %v", err)
+	}
+
+	metadata, err := loadMetadata()
+	if err != nil {
+		t.Fatalf("loadMetadata returned error: %v", err)
+	}
+	if metadata["user@fastmail.com"].Folder != "Shopping" {
+		t.Fatalf("expected folder to be stored, got %+v", metadata)
+	}
+
+	err = updateMetadata("user@fastmail.com", func(entry *AliasMetadata) {
+		*entry = AliasMetadata{}
+	})
+	if err != nil {
+		t.Fatalf("updateMetadata returned error: %v", err)
+	}
+	metadata, _ = loadMetadata()
+	if _, ok := metadata["user@fastmail.com"]; ok {
+		t.Fatalf("expected empty metadata entries to be removed")
+	}
+}
@@ -0,0 +1,526 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// Kinds of sync actions
+const (
+	syncCreate     = "create"
+	syncUpdate     = "update"
+	syncExtraneous = "extraneous"
+	syncMissing    = "missing"
+)
+
+// inventoryEntry is an alias declared in the inventory file.
+type inventoryEntry struct {
+	// Email identifies an existing alias; without it, the alias is found by
+	// domain and its email is recorded in the file by sync
+	Email  string `yaml:"email,omitempty"`
+	Domain string `yaml:"domain,omitempty"`
+	// Description and State are left alone when omitted
+	Description *string    `yaml:"description,omitempty"`
+	State       AliasState `yaml:"state,omitempty"`
+}
+
+// inventory is a YAML file declaring the aliases an account should have.
+type inventory struct {
+	Aliases []inventoryEntry `yaml:"aliases"`
+
+	// node is the parsed document, kept so that the emails recorded by sync
+	// can be written back without losing comments and ordering
+	node yaml.Node
+	// mode is the permission of the file, kept when it is rewritten
+	mode os.FileMode
+}
+
+// loadInventory reads an inventory file, failing if lintInventory finds
+// errors in it.
+func loadInventory(path string) (*inventory, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read inventory: %w", err)
+	}
+	inv, err := parseInventory(data)
+	var invErr *inventoryError
+	if errors.As(err, &invErr) {
+		invErr.path = path
+		return nil, invErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid inventory %s: %w", path, err)
+	}
+	inv.mode = 0o644
+	if info, err := os.Stat(path); err == nil {
+		inv.mode = info.Mode().Perm()
+	}
+	return inv, nil
+}
+
+// parseInventory parses the YAML of an inventory file.
+func parseInventory(data []byte) (*inventory, error) {
+	if err := lintErrors(lintInventory(data)); err != nil {
+		return nil, err
+	}
+	inv := &inventory{}
+	if err := yaml.Unmarshal(data, &inv.node); err != nil {
+		return nil, err
+	}
+	if inv.node.Kind == 0 {
+		return inv, nil
+	}
+	if err := inv.node.Decode(inv); err != nil {
+		return nil, err
+	}
+	return inv, nil
+}
+
+// aliasesNode returns the sequence of declared aliases in the document,
+// creating it if needed.
+func (inv *inventory) aliasesNode() *yaml.Node {
+	if inv.node.Kind == 0 {
+		inv.node = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	root := inv.node.Content[0]
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value == "aliases" {
+			return root.Content[i+1]
+		}
+	}
+	sequence := &yaml.Node{Kind: yaml.SequenceNode}
+	root.Content = append(root.Content, scalarNode("aliases"), sequence)
+	return sequence
+}
+
+// scalarNode returns a YAML string node.
+func scalarNode(value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+}
+
+// setEmail records the email of the alias found or created for an entry.
+func (inv *inventory) setEmail(index int, email string) {
+	inv.Aliases[index].Email = email
+	entry := inv.aliasesNode().Content[index]
+	for i := 0; i+1 < len(entry.Content); i += 2 {
+		if entry.Content[i].Value == "email" {
+			entry.Content[i+1].Value = email
+			return
+		}
+	}
+	entry.Content = append([]*yaml.Node{scalarNode("email"), scalarNode(email)}, entry.Content...)
+}
+
+// adopt declares an alias of the account in the inventory as it is.
+func (inv *inventory) adopt(alias MaskedEmailInfo) {
+	description := alias.Description
+	inv.Aliases = append(inv.Aliases, inventoryEntry{Email: alias.Email, Domain: alias.ForDomain, Description: &description, State: alias.State})
+	entry := &yaml.Node{Kind: yaml.MappingNode}
+	entry.Content = append(entry.Content, scalarNode("email"), scalarNode(alias.Email))
+	if alias.ForDomain != "" {
+		entry.Content = append(entry.Content, scalarNode("domain"), scalarNode(alias.ForDomain))
+	}
+	entry.Content = append(entry.Content,
+		scalarNode("description"), scalarNode(alias.Description),
+		scalarNode("state"), scalarNode(string(alias.State)))
+	sequence := inv.aliasesNode()
+	sequence.Style = 0
+	sequence.Content = append(sequence.Content, entry)
+}
+
+// encode returns the YAML of the inventory.
+func (inv *inventory) encode() ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&inv.node); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// save writes the inventory back to path.
+func (inv *inventory) save(path string) error {
+	data, err := inv.encode()
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data, inv.mode)
+}
+
+// syncAction is a difference between the inventory and the account.
+type syncAction struct {
+	Kind string
+	// Entry is the index of the inventory entry, or -1 for aliases that are
+	// not in the inventory
+	Entry int
+	// Alias is the alias in the account; for creations, the alias to create
+	Alias MaskedEmailInfo
+	// fieldChange is the change made by an update
+	fieldChange
+	// Skip explains why the action cannot be applied; empty if it can
+	Skip string
+	// Outcome and Reason describe what happened when the plan was applied
+	Outcome string
+	Reason  string
+}
+
+// syncPlan is what syncing the account with an inventory would change.
+type syncPlan struct {
+	Actions []syncAction
+	// Found maps inventory entries without an email to the alias found for
+	// their domain
+	Found map[int]string
+}
+
+// Updates returns the MaskedEmail updates of the plan, keyed by alias ID.
+func (p syncPlan) Updates() map[string]MaskedEmailUpdate {
+	updates := make(map[string]MaskedEmailUpdate)
+	for _, action := range p.Actions {
+		if action.Kind != syncUpdate || action.Skip != "" {
+			continue
+		}
+		update := updates[action.Alias.ID]
+		value := action.New
+		switch action.Field {
+		case fieldState:
+			state := AliasState(value)
+			update.State = &state
+		case fieldDescription:
+			update.Description = &value
+		}
+		updates[action.Alias.ID] = update
+	}
+	return updates
+}
+
+// planSync compares the inventory with the account's aliases. Entries with
+// an email are matched by it; the others claim a live alias for their domain,
+// or are created. Live aliases that no entry claims are extraneous. Protected
+// aliases are not deleted unless force is set.
+func planSync(inv *inventory, aliases []MaskedEmailInfo, metadata map[string]AliasMetadata, force bool) (syncPlan, error) {
+	plan := syncPlan{Found: make(map[int]string)}
+	claimed := make(map[string]bool)
+
+	for i, entry := range inv.Aliases {
+		var alias *MaskedEmailInfo
+		if entry.Email != "" {
+			for j := range aliases {
+				if strings.EqualFold(aliases[j].Email, entry.Email) {
+					alias = &aliases[j]
+					break
+				}
+			}
+			if alias == nil {
+				plan.Actions = append(plan.Actions, syncAction{Kind: syncMissing, Entry: i, Alias: MaskedEmailInfo{Email: entry.Email, ForDomain: entry.Domain}})
+				continue
+			}
+		} else {
+			_, domain, err := prepareDomainInput(entry.Domain)
+			if err != nil {
+				return plan, fmt.Errorf("alias %d: %w", i+1, err)
+			}
+			var candidates []MaskedEmailInfo
+			for _, candidate := range aliases {
+				if candidate.State != AliasDeleted && !claimed[candidate.ID] && aliasMatchesDomain(candidate, domain) {
+					candidates = append(candidates, candidate)
+				}
+			}
+			alias = selectPreferredAlias(candidates)
+			if alias == nil {
+				if entry.State != AliasDeleted {
+					create := MaskedEmailInfo{ForDomain: domain, State: entry.State}
+					if entry.Description != nil {
+						create.Description = *entry.Description
+					}
+					plan.Actions = append(plan.Actions, syncAction{Kind: syncCreate, Entry: i, Alias: create})
+				}
+				continue
+			}
+			plan.Found[i] = alias.Email
+		}
+
+		claimed[alias.ID] = true
+		for _, change := range compareAliases(*alias, declaredAlias(*alias, entry)) {
+			if change.Field == fieldDomain {
+				continue
+			}
+			action := syncAction{Kind: syncUpdate, Entry: i, Alias: *alias, fieldChange: change}
+			switch {
+			case change.Field == fieldState && AliasState(change.New) == AliasPending:
+				action.Skip = "aliases cannot be made pending again"
+			case change.Field == fieldState && AliasState(change.New) == AliasDeleted && !force && isProtected(*alias, metadata):
+				action.Skip = "protected (use --force to delete it)"
+			}
+			plan.Actions = append(plan.Actions, action)
+		}
+	}
+
+	var extraneous []MaskedEmailInfo
+	for _, alias := range aliases {
+		if alias.State != AliasDeleted && !claimed[alias.ID] {
+			extraneous = append(extraneous, alias)
+		}
+	}
+	sort.Slice(extraneous, func(i, j int) bool { return extraneous[i].Email < extraneous[j].Email })
+	for _, alias := range extraneous {
+		plan.Actions = append(plan.Actions, syncAction{Kind: syncExtraneous, Entry: -1, Alias: alias})
+	}
+	return plan, nil
+}
+
+// declaredAlias returns the alias as the inventory entry declares it: the
+// fields the entry omits keep their current value.
+func declaredAlias(alias MaskedEmailInfo, entry inventoryEntry) MaskedEmailInfo {
+	if entry.Description != nil {
+		alias.Description = *entry.Description
+	}
+	if entry.State != "" {
+		alias.State = entry.State
+	}
+	return alias
+}
+
+// newSyncCmd creates the command that reconciles the account with an
+// inventory file.
+func newSyncCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "sync <inventory.yaml>",
+		Short: "Reconcile the account with the aliases declared in a YAML file",
+		Long: `Reconcile the account with an inventory: a YAML file, e.g. kept in git, that
+declares the aliases the account should have:
+
+  aliases:
+    - domain: https://example.com
+      description: Example shop
+      state: enabled
+
+Aliases declared without an email are matched to an alias for their domain,
+or created. Descriptions and states that differ from the inventory are
+updated; fields an entry omits are left alone. Aliases in the account but not
+in the inventory are reported, and with --adopt added to it.
+
+Without --apply, only the changes that would be made are shown. With --apply,
+the email of every alias matched or created is written back to the inventory,
+so that later runs find the same alias.
+
+--lint only checks the inventory, without contacting Fastmail: it reports
+invalid states, emails and domains, duplicate entries and missing descriptions
+with their line and column.`,
+		Example: `  masked_fastmail sync aliases.yaml
+  masked_fastmail sync aliases.yaml --apply
+  masked_fastmail sync --lint aliases.yaml`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if lint, _ := cmd.Flags().GetBool("lint"); lint {
+				return handleSyncLint(args[0])
+			}
+			apply, _ := cmd.Flags().GetBool("apply")
+			adopt, _ := cmd.Flags().GetBool("adopt")
+			force, _ := cmd.Flags().GetBool("force")
+			client, err := newClientFromCmd(cmd)
+			if err != nil {
+				return err
+			}
+			return handleSync(client, args[0], apply, adopt, force)
+		},
+	}
+	cmd.Flags().Bool("apply", false, "apply the changes instead of only showing them")
+	cmd.Flags().Bool("adopt", false, "add aliases missing from the inventory to it instead of only reporting them")
+	cmd.Flags().Bool("force", false, "also delete protected aliases declared as deleted")
+	cmd.Flags().Bool("lint", false, "only check the inventory for problems, without contacting Fastmail")
+	cmd.MarkFlagsMutuallyExclusive("lint", "apply")
+	return cmd
+}
+
+// handleSync shows, and with apply makes, the changes that bring the account
+// in line with an inventory.
+func handleSync(client *FastmailClient, path string, apply, adopt, force bool) error {
+	inv, err := loadInventory(path)
+	if err != nil {
+		return err
+	}
+	aliases, err := fetchAllAliasesWithProgress(client)
+	if err != nil {
+		return formatAPIError("failed to get aliases", err)
+	}
+	metadata, err := loadMetadata()
+	if err != nil {
+		return fmt.Errorf("failed to read alias metadata: %w", err)
+	}
+	plan, err := planSync(inv, aliases, metadata, force)
+	if err != nil {
+		return fmt.Errorf("invalid inventory %s: %w", path, err)
+	}
+
+	if !apply {
+		for i := range plan.Actions {
+			action := &plan.Actions[i]
+			action.Outcome = choose(action.Skip != "", "skipped", "planned")
+			action.Reason = action.Skip
+		}
+		printSyncPlan(path, plan, apply, adopt)
+		return nil
+	}
+
+	applyErr := applySync(client, inv, &plan, adopt)
+	// Record what was done even if the run stopped part way
+	if err := inv.save(path); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not update %s: %v\n", path, err)
+	}
+	printSyncPlan(path, plan, apply, adopt)
+
+	if errors.Is(applyErr, ErrInterrupted) {
+		return applyErr
+	}
+	if applyErr != nil {
+		return formatAPIError("failed to sync aliases", applyErr)
+	}
+	failed := 0
+	for _, action := range plan.Actions {
+		if action.Outcome == "failed" {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d changes could not be made", failed)
+	}
+	return nil
+}
+
+// applySync creates and updates the aliases of the plan and records their
+// emails in the inventory, setting the outcome of each action.
+func applySync(client *FastmailClient, inv *inventory, plan *syncPlan, adopt bool) error {
+	for index, email := range plan.Found {
+		inv.setEmail(index, email)
+	}
+
+	ctx, stop := withInterrupt()
+	defer stop()
+
+	for i := range plan.Actions {
+		action := &plan.Actions[i]
+		switch {
+		case action.Skip != "":
+			action.Outcome, action.Reason = "skipped", action.Skip
+		case action.Kind == syncExtraneous && adopt:
+			inv.adopt(action.Alias)
+			action.Outcome = "adopted"
+		case action.Kind == syncExtraneous, action.Kind == syncMissing:
+			action.Outcome = "reported"
+		case action.Kind == syncCreate:
+			if ctx.Err() != nil {
+				action.Outcome = "pending"
+				continue
+			}
+			creation := AliasCreation{Domain: action.Alias.ForDomain, Description: &action.Alias.Description}
+			if action.Alias.State != AliasPending {
+				creation.State = action.Alias.State
+			}
+			created, err := client.CreateAliasWith(creation)
+			if err != nil {
+				action.Outcome, action.Reason = "failed", err.Error()
+				continue
+			}
+			action.Alias = *created
+			action.Outcome = "created"
+			inv.setEmail(action.Entry, created.Email)
+			recordUsage(eventCreated)
+			recordOwnership(created.Email)
+		}
+	}
+
+	result, err := client.UpdateAliases(ctx, plan.Updates(), nil)
+	for i := range plan.Actions {
+		action := &plan.Actions[i]
+		if action.Kind != syncUpdate || action.Outcome != "" {
+			continue
+		}
+		action.Outcome = "pending"
+		if setErr, ok := result.Failed[action.Alias.ID]; ok {
+			action.Outcome, action.Reason = "failed", setErr.String()
+		}
+		for _, id := range result.Updated {
+			if id == action.Alias.ID {
+				action.Outcome = "updated"
+			}
+		}
+	}
+	if err == nil && ctx.Err() != nil {
+		err = ErrInterrupted
+	}
+	return err
+}
+
+// printSyncPlan prints one line per action: "+" for aliases to create, "~"
+// for changed fields, "?" for aliases not in the inventory and "!" for
+// declared aliases missing from the account.
+func printSyncPlan(path string, plan syncPlan, apply, adopt bool) {
+	if porcelain != "" {
+		printSyncPorcelain(plan)
+		return
+	}
+
+	if len(plan.Actions) == 0 {
+		fmt.Printf("The account matches %s\n", path)
+		return
+	}
+	if apply {
+		fmt.Printf("Syncing with %s:\n", path)
+	} else {
+		fmt.Printf("Differences between the account and %s:\n", path)
+	}
+
+	changes := 0
+	for _, action := range plan.Actions {
+		var line string
+		switch action.Kind {
+		case syncCreate:
+			line = fmt.Sprintf("+ %s %s %s", choose(action.Alias.Email != "", action.Alias.Email, "(new alias)"),
+				action.Alias.ForDomain, strconv.Quote(action.Alias.Description))
+		case syncUpdate:
+			line = fmt.Sprintf("~ %s %s: %s -> %s", action.Alias.Email, action.Field, strconv.Quote(action.Old), strconv.Quote(action.New))
+		case syncExtraneous:
+			line = fmt.Sprintf("? %s %s: not in the inventory", action.Alias.Email, action.Alias.ForDomain)
+			if action.Outcome == "adopted" {
+				line += ", added to it"
+			}
+		case syncMissing:
+			line = fmt.Sprintf("! %s: not in the account (masked email addresses can't be recreated)", action.Alias.Email)
+		}
+		switch action.Outcome {
+		case "skipped", "failed":
+			line += fmt.Sprintf("  (%s: %s)", action.Outcome, action.Reason)
+		case "pending":
+			line += "  (not applied)"
+		}
+		if (action.Kind == syncCreate || action.Kind == syncUpdate) && action.Skip == "" {
+			changes++
+		}
+		fmt.Println(line)
+	}
+
+	if !apply && (changes > 0 || len(plan.Found) > 0 || adopt) {
+		fmt.Fprintln(os.Stderr, "\nRun again with --apply to make these changes and record the emails in the inventory.")
+	}
+}
+
+// printSyncPorcelain prints one record per action.
+func printSyncPorcelain(plan syncPlan) {
+	// action (create|update|extraneous|missing), email, domain, field, old
+	// value, new value, outcome, reason
+	for _, action := range plan.Actions {
+		printPorcelain(action.Kind, action.Alias.Email, action.Alias.ForDomain, action.Field, action.Old, action.New, action.Outcome, action.Reason)
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testInventory = `# Aliases for the shops I use
+aliases:
+  - domain: https://shop.com
+    description: Shop
+    state: enabled
+  - domain: https://new.com # signed up last week
+    description: New
+  - email: gone@fastmail.com
+  - email: bank@fastmail.com
+    state: disabled
+`
+
+func TestParseInventory(t *testing.T) {
+	inv, err := parseInventory([]byte(testInventory))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inv.Aliases) != 4 || inv.Aliases[0].Domain != "https://shop.com" || *inv.Aliases[1].Description != "New" {
+		t.Fatalf("unexpected entries: %+v", inv.Aliases)
+	}
+	if inv.Aliases[2].Description != nil || inv.Aliases[3].State != AliasDisabled {
+		t.Fatalf("unexpected optional fields: %+v", inv.Aliases)
+	}
+
+	for name, data := range map[string]string{
+		"no identifier": "aliases:\n  - description: x\n",
+		"bad state":     "aliases:\n  - domain: a.com\n    state: paused\n",
+		"not a list":    "aliases: a.com\n",
+	} {
+		if _, err := parseInventory([]byte(data)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestPlanSync(t *testing.T) {
+	inv, err := parseInventory([]byte(testInventory))
+	if err != nil {
+		t.Fatal(err)
+	}
+	aliases := []MaskedEmailInfo{
+		{ID: "1", Email: "shop@fastmail.com", ForDomain: "https://shop.com", State: AliasPending, Description: "Old shop"},
+		{ID: "2", Email: "bank@fastmail.com", ForDomain: "https://bank.com", State: AliasEnabled},
+		{ID: "3", Email: "other@fastmail.com", ForDomain: "https://other.com", State: AliasEnabled},
+		{ID: "4", Email: "old@fastmail.com", ForDomain: "https://new.com", State: AliasDeleted},
+	}
+
+	plan, err := planSync(inv, aliases, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, action := range plan.Actions {
+		label := choose(action.Alias.Email != "", action.Alias.Email, action.Alias.ForDomain)
+		got = append(got, strings.TrimSpace(action.Kind+" "+label+" "+action.Field))
+	}
+	want := []string{
+		"update shop@fastmail.com state",
+		"update shop@fastmail.com description",
+		"create https://new.com",
+		"missing gone@fastmail.com",
+		"update bank@fastmail.com state",
+		"extraneous other@fastmail.com",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected actions:\n%s", strings.Join(got, "\n"))
+	}
+	if plan.Found[0] != "shop@fastmail.com" || len(plan.Found) != 1 {
+		t.Fatalf("unexpected found aliases: %v", plan.Found)
+	}
+
+	updates := plan.Updates()
+	if *updates["1"].State != AliasEnabled || *updates["1"].Description != "Shop" || *updates["2"].State != AliasDisabled {
+		t.Fatalf("unexpected updates: %+v", updates)
+	}
+}
+
+func TestPlanSyncProtected(t *testing.T) {
+	inv, err := parseInventory([]byte("aliases:\n  - email: bank@fastmail.com\n    state: deleted\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	aliases := []MaskedEmailInfo{{ID: "1", Email: "bank@fastmail.com", State: AliasEnabled}}
+	metadata := map[string]AliasMetadata{"bank@fastmail.com": {Protected: true}}
+
+	plan, err := planSync(inv, aliases, metadata, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Actions) != 1 || plan.Actions[0].Skip == "" || len(plan.Updates()) != 0 {
+		t.Fatalf("expected the protected alias to be skipped, got %+v", plan.Actions)
+	}
+	if plan, _ = planSync(inv, aliases, metadata, true); len(plan.Updates()) != 1 {
+		t.Fatalf("expected --force to delete the protected alias, got %+v", plan.Actions)
+	}
+}
+
+func TestInventoryWriteBack(t *testing.T) {
+	inv, err := parseInventory([]byte(testInventory))
+	if err != nil {
+		t.Fatal(err)
+	}
+	inv.setEmail(0, "shop@fastmail.com")
+	inv.setEmail(2, "renamed@fastmail.com")
+	inv.adopt(MaskedEmailInfo{Email: "other@fastmail.com", ForDomain: "https://other.com", State: AliasEnabled, Description: "Other"})
+
+	data, err := inv.encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(data)
+	for _, want := range []string{
+		"# Aliases for the shops I use",
+		"# signed up last week",
+		"  - email: shop@fastmail.com\n    domain: https://shop.com\n",
+		"  - email: renamed@fastmail.com\n",
+		"  - email: other@fastmail.com\n    domain: https://other.com\n    description: Other\n    state: enabled\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in:\n%s", want, out)
+		}
+	}
+
+	reloaded, err := parseInventory(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reloaded.Aliases) != 5 || reloaded.Aliases[0].Email != "shop@fastmail.com" {
+		t.Fatalf("unexpected entries after writing back: %+v", reloaded.Aliases)
+	}
+}
+
+func TestInventoryAdoptIntoEmptyFile(t *testing.T) {
+	inv, err := parseInventory(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inv.adopt(MaskedEmailInfo{Email: "a@fastmail.com", State: AliasPending})
+	data, err := inv.encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), "aliases:\n  - email: a@fastmail.com\n") {
+		t.Fatalf("unexpected inventory:\n%s", data)
+	}
+}
+
+func TestHandleSyncApply(t *testing.T) {
+	fake, client := newFakeJMAP(t,
+		MaskedEmailInfo{ID: "1", Email: "shop@fastmail.com", ForDomain: "https://shop.com", State: AliasPending, Description: "Old"},
+		MaskedEmailInfo{ID: "2", Email: "bank@fastmail.com", ForDomain: "https://bank.com", State: AliasEnabled},
+	)
+	path := filepath.Join(t.TempDir(), "aliases.yaml")
+	if err := os.WriteFile(path, []byte(testInventory), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := handleSync(client, path, true, false, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fake.state("1") != AliasEnabled || fake.aliases["1"].Description != "Shop" || fake.state("2") != AliasDisabled {
+		t.Fatalf("expected the declared state, got %+v and %+v", fake.aliases["1"], fake.aliases["2"])
+	}
+	if fake.created != 1 {
+		t.Fatalf("expected new.com to be created, got %d aliases", fake.created)
+	}
+
+	// The created alias was recorded in the file, so nothing is left to do
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), "email: new1@fastmail.com") || !strings.Contains(string(data), "# signed up last week") {
+		t.Fatalf("expected the email to be recorded, keeping comments:\n%s", data)
+	}
+	inv, err := loadInventory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aliases, err := client.getMaskedEmail(nil, aliasProperties)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan, err := planSync(inv, aliases, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, action := range plan.Actions {
+		if action.Kind != syncMissing {
+			t.Errorf("unexpected action after apply: %+v", action)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// wideRanges are the ranges of characters shown two columns wide by
+// terminals: East Asian wide and fullwidth characters and emoji presented as
+// pictures. The list is ordered and covers the common blocks rather than the
+// whole of Unicode's East Asian Width property.
+var wideRanges = [][2]rune{
+	{0x1100, 0x115F}, {0x231A, 0x231B}, {0x2329, 0x232A}, {0x23E9, 0x23EC},
+	{0x23F0, 0x23F0}, {0x23F3, 0x23F3}, {0x25FD, 0x25FE}, {0x2614, 0x2615},
+	{0x2648, 0x2653}, {0x267F, 0x267F}, {0x2693, 0x2693}, {0x26A1, 0x26A1},
+	{0x26AA, 0x26AB}, {0x26BD, 0x26BE}, {0x26C4, 0x26C5}, {0x26CE, 0x26CE},
+	{0x26D4, 0x26D4}, {0x26EA, 0x26EA}, {0x26F2, 0x26F3}, {0x26F5, 0x26F5},
+	{0x26FA, 0x26FA}, {0x26FD, 0x26FD}, {0x2705, 0x2705}, {0x270A, 0x270B},
+	{0x2728, 0x2728}, {0x274C, 0x274C}, {0x274E, 0x274E}, {0x2753, 0x2755},
+	{0x2757, 0x2757}, {0x2795, 0x2797}, {0x27B0, 0x27B0}, {0x27BF, 0x27BF},
+	{0x2B1B, 0x2B1C}, {0x2B50, 0x2B50}, {0x2B55, 0x2B55}, {0x2E80, 0x303E},
+	{0x3041, 0x33FF}, {0x3400, 0x4DBF}, {0x4E00, 0x9FFF}, {0xA000, 0xA4CF},
+	{0xA960, 0xA97F}, {0xAC00, 0xD7A3}, {0xF900, 0xFAFF}, {0xFE10, 0xFE19},
+	{0xFE30, 0xFE6F}, {0xFF00, 0xFF60}, {0xFFE0, 0xFFE6}, {0x1F004, 0x1F004},
+	{0x1F0CF, 0x1F0CF}, {0x1F18E, 0x1F18E}, {0x1F191, 0x1F19A}, {0x1F200, 0x1F251},
+	{0x1F300, 0x1F64F}, {0x1F680, 0x1F6FF}, {0x1F7E0, 0x1F7EB}, {0x1F900, 0x1F9FF},
+	{0x1FA70, 0x1FAFF}, {0x20000, 0x2FFFD}, {0x30000, 0x3FFFD},
+}
+
+const (
+	zeroWidthJoiner = '\u200d'
+	// replacementChar stands in for characters that must not reach the
+	// terminal
+	replacementChar = '\ufffd'
+)
+
+// runeWidth returns the number of terminal columns a character takes: 0 for
+// combining marks and format characters, 2 for wide characters, 1 otherwise.
+func runeWidth(r rune) int {
+	switch {
+	case unicode.Is(unicode.Mn, r), unicode.Is(unicode.Me, r), unicode.Is(unicode.Cf, r):
+		return 0
+	case r >= 0x1F3FB && r <= 0x1F3FF:
+		// Skin tone modifiers merge into the preceding emoji
+		return 0
+	case r < wideRanges[0][0]:
+		return 1
+	}
+	for _, wide := range wideRanges {
+		if r < wide[0] {
+			break
+		}
+		if r <= wide[1] {
+			return 2
+		}
+	}
+	return 1
+}
+
+// displayWidth returns the number of terminal columns a string takes, for
+// aligning output. Characters joined to an emoji with a zero width joiner,
+// as in family emoji, are drawn as part of it.
+func displayWidth(s string) int {
+	width := 0
+	joined := false
+	for _, r := range s {
+		if !joined {
+			width += runeWidth(r)
+		}
+		joined = r == zeroWidthJoiner
+	}
+	return width
+}
+
+// padRight pads a string with spaces to a display width.
+func padRight(s string, width int) string {
+	if pad := width - displayWidth(s); pad > 0 {
+		return s + strings.Repeat(" ", pad)
+	}
+	return s
+}
+
+// sanitizeText makes text from the account or from senders, such as
+// descriptions and subjects, safe to print on one line: line breaks and tabs
+// become a single space, and other control characters, including escape
+// sequences and bidirectional overrides that could disguise the output, are
+// replaced with U+FFFD.
+func sanitizeText(s string) string {
+	if strings.IndexFunc(s, isUnsafeRune) < 0 {
+		return s
+	}
+	var b strings.Builder
+	space := false
+	for _, r := range s {
+		switch {
+		case r == '\n' || r == '\r' || r == '\t':
+			if !space {
+				b.WriteRune(' ')
+			}
+			space = true
+			continue
+		case isUnsafeRune(r):
+			b.WriteRune(replacementChar)
+		default:
+			b.WriteRune(r)
+		}
+		space = false
+	}
+	return b.String()
+}
+
+// isUnsafeRune reports whether a character must not be printed as is.
+func isUnsafeRune(r rune) bool {
+	return unicode.IsControl(r) || (r >= 0x202A && r <= 0x202E) || (r >= 0x2066 && r <= 0x2069)
+}
@@ -0,0 +1,51 @@
+package main
+
+import "testing"
+
+func TestDisplayWidth(t *testing.T) {
+	tests := []struct {
+		s    string
+		want int
+	}{
+		{"shop.1234@fastmail.com", 22},
+		{"日本語", 6},
+		{"ｆｕｌｌ", 8},
+		{"café", 4},
+		{"cafe\u0301", 4},
+		{"🎉 party", 8},
+		{"👍🏽", 2},
+		{"👨\u200d👩\u200d👧", 2},
+		{"", 0},
+	}
+	for _, tt := range tests {
+		if got := displayWidth(tt.s); got != tt.want {
+			t.Errorf("displayWidth(%q) = %d, want %d", tt.s, got, tt.want)
+		}
+	}
+
+	if got := padRight("日本", 6); got != "日本  " {
+		t.Errorf("padRight should pad to the display width, got %q", got)
+	}
+	if got := padRight("toolong", 3); got != "toolong" {
+		t.Errorf("padRight should not truncate, got %q", got)
+	}
+}
+
+func TestSanitizeText(t *testing.T) {
+	tests := []struct {
+		s    string
+		want string
+	}{
+		{"Personal finance", "Personal finance"},
+		{"line one\r\nline two", "line one line two"},
+		{"tab\there", "tab here"},
+		{"\x1b[31mred\x1b[0m", "�[31mred�[0m"},
+		{"invoice\u202egpj.exe", "invoice�gpj.exe"},
+		{"日本語 🎉", "日本語 🎉"},
+	}
+	for _, tt := range tests {
+		if got := sanitizeText(tt.s); got != tt.want {
+			t.Errorf("sanitizeText(%q) = %q, want %q", tt.s, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// defaultStateGlyphs are the symbols shown before alias states when glyphs
+// are on; all are one column wide in common terminal fonts
+var defaultStateGlyphs = map[AliasState]string{
+	AliasEnabled:  "●",
+	AliasPending:  "◐",
+	AliasDisabled: "○",
+	AliasDeleted:  "✕",
+}
+
+// stateGlyphs are the symbols shown before alias states in list and digest
+// output; nil, the default, shows the states as plain words. It is set by the
+// glyphs and state_glyphs settings.
+var stateGlyphs map[AliasState]string
+
+// validateStateGlyphs checks the state_glyphs setting: every key must be an
+// alias state, and every glyph short, printable text.
+func validateStateGlyphs(glyphs map[string]string) error {
+	for state, glyph := range glyphs {
+		if !isInventoryState(AliasState(state)) {
+			return fmt.Errorf("state_glyphs: unknown state %q (expected one of: %s, %s, %s, %s)",
+				state, AliasEnabled, AliasPending, AliasDisabled, AliasDeleted)
+		}
+		if strings.TrimSpace(glyph) == "" || sanitizeText(glyph) != glyph {
+			return fmt.Errorf("state_glyphs: the glyph of %s must be printable text", state)
+		}
+		if displayWidth(glyph) > 4 {
+			return fmt.Errorf("state_glyphs: the glyph of %s is wider than 4 columns", state)
+		}
+	}
+	return nil
+}
+
+// themeGlyphs returns the glyphs the settings select: none unless glyphs is
+// on, and otherwise the defaults with the configured ones in their place.
+func themeGlyphs(enabled bool, overrides map[string]string) map[AliasState]string {
+	if !enabled {
+		return nil
+	}
+	glyphs := make(map[AliasState]string, len(defaultStateGlyphs))
+	for state, glyph := range defaultStateGlyphs {
+		glyphs[state] = glyph
+	}
+	for state, glyph := range overrides {
+		glyphs[AliasState(state)] = glyph
+	}
+	return glyphs
+}
+
+// themedState returns a state as shown to people: the state, preceded by its
+// glyph when glyphs are on. Porcelain and JSON output always use the bare
+// state.
+func themedState(state AliasState) string {
+	if glyph := stateGlyphs[state]; glyph != "" {
+		return glyph + " " + string(state)
+	}
+	return string(state)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestThemedStateIsPlainByDefault(t *testing.T) {
+	stateGlyphs = themeGlyphs(false, map[string]string{"enabled": "+"})
+	if got := themedState(AliasEnabled); got != "enabled" {
+		t.Errorf("themedState() = %q, want enabled", got)
+	}
+}
+
+func TestThemedStateWithGlyphs(t *testing.T) {
+	stateGlyphs = themeGlyphs(true, map[string]string{"disabled": "-"})
+	defer func() { stateGlyphs = nil }()
+
+	tests := map[AliasState]string{
+		AliasEnabled:  "● enabled",
+		AliasDisabled: "- disabled",
+		AliasDeleted:  "✕ deleted",
+	}
+	for state, want := range tests {
+		if got := themedState(state); got != want {
+			t.Errorf("themedState(%s) = %q, want %q", state, got, want)
+		}
+	}
+}
+
+func TestValidateStateGlyphs(t *testing.T) {
+	tests := []struct {
+		name    string
+		glyphs  map[string]string
+		wantErr string
+	}{
+		{name: "valid", glyphs: map[string]string{"enabled": "✓", "deleted": "[x]"}},
+		{name: "unknown state", glyphs: map[string]string{"active": "✓"}, wantErr: "unknown state"},
+		{name: "empty glyph", glyphs: map[string]string{"enabled": " "}, wantErr: "printable"},
+		{name: "escape sequence", glyphs: map[string]string{"enabled": "\x1b[32m✓"}, wantErr: "printable"},
+		{name: "too wide", glyphs: map[string]string{"enabled": "enabled!"}, wantErr: "wider"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateStateGlyphs(tt.glyphs)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error = %v, want it to mention %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTextDigestUsesGlyphs(t *testing.T) {
+	stateGlyphs = themeGlyphs(true, nil)
+	defer func() { stateGlyphs = nil }()
+
+	now := time.Date(2024, 6, 8, 12, 0, 0, 0, time.UTC)
+	digest := aliasDigest{
+		Since:        now.AddDate(0, 0, -7),
+		Until:        now,
+		StateChanges: []stateChange{{Alias: MaskedEmailInfo{Email: "a@fastmail.com", State: AliasDisabled}, From: AliasEnabled}},
+	}
+	var buf bytes.Buffer
+	if err := writeTextDigest(&buf, digest); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "a@fastmail.com: ● enabled -> ○ disabled") {
+		t.Errorf("digest doesn't show the glyphs:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// acceptEncoding lists the response compressions the client can decode.
+const acceptEncoding = "gzip, deflate"
+
+// compressRequestThreshold is the smallest request body that is compressed
+// when compress_requests is enabled; smaller bodies aren't worth it.
+const compressRequestThreshold = 16 << 10
+
+// compressRequests is the compress_requests setting from the config file.
+var compressRequests bool
+
+// decompressBody returns a reader of the response body decoded according to
+// its Content-Encoding.
+func decompressBody(body io.Reader, encoding string) (io.Reader, error) {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "", "identity":
+		return body, nil
+	case "gzip", "x-gzip":
+		return gzip.NewReader(body)
+	case "deflate":
+		return zlib.NewReader(body)
+	}
+	return nil, fmt.Errorf("unsupported response Content-Encoding %q", encoding)
+}
+
+// gzipBytes compresses data with gzip.
+func gzipBytes(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// requestTiming describes the duration and size of one API request.
+type requestTiming struct {
+	// Label names the request, e.g. the JMAP methods it calls
+	Label    string
+	Duration time.Duration
+	// Sent is the size of the request body as sent
+	Sent int64
+	// Received is the size of the response body as received, and Decoded
+	// its size after decompression
+	Received int64
+	Decoded  int64
+}
+
+// String formats the timing for --timing, e.g.
+// "MaskedEmail/get: 420ms, sent 180 B, received 3.1 KiB (18.4 KiB uncompressed)".
+func (t requestTiming) String() string {
+	line := fmt.Sprintf("%s: %s, sent %s, received %s", t.Label, t.Duration.Round(time.Millisecond), formatBytes(int(t.Sent)), formatBytes(int(t.Received)))
+	if t.Decoded != t.Received {
+		line += fmt.Sprintf(" (%s uncompressed)", formatBytes(int(t.Decoded)))
+	}
+	return line
+}
+
+// requestLabel names a request for timing output: the JMAP methods it calls,
+// or the HTTP method and path for other requests.
+func requestLabel(req *http.Request, requestBody []byte) string {
+	var payload JMAPRequest
+	if json.Unmarshal(requestBody, &payload) == nil && len(payload.MethodCalls) > 0 {
+		var names []string
+		for _, call := range payload.MethodCalls {
+			var name string
+			if len(call) > 0 && json.Unmarshal(call[0], &name) == nil {
+				names = append(names, name)
+			}
+		}
+		return strings.Join(names, ", ")
+	}
+	return req.Method + " " + req.URL.Path
+}
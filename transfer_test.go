@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecompressBody(t *testing.T) {
+	const text = `{"methodResponses": []}`
+
+	compressed, err := gzipBytes([]byte(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var deflated bytes.Buffer
+	writer := zlib.NewWriter(&deflated)
+	writer.Write([]byte(text))
+	writer.Close()
+
+	tests := map[string][]byte{
+		"":        []byte(text),
+		"gzip":    compressed,
+		"deflate": deflated.Bytes(),
+	}
+	for encoding, body := range tests {
+		reader, err := decompressBody(bytes.NewReader(body), encoding)
+		if err != nil {
+			t.Fatalf("decompressBody(%q) returned error: %v", encoding, err)
+		}
+		data, err := io.ReadAll(reader)
+		if err != nil || string(data) != text {
+			t.Fatalf("decompressBody(%q) = %q, %v", encoding, data, err)
+		}
+	}
+
+	if _, err := decompressBody(strings.NewReader(text), "br"); err == nil {
+		t.Fatalf("expected an error for an unsupported encoding")
+	}
+}
+
+func TestRequestTimingString(t *testing.T) {
+	timing := requestTiming{Label: "MaskedEmail/get", Duration: 420 * time.Millisecond, Sent: 180, Received: 3174, Decoded: 18842}
+	expected := "MaskedEmail/get: 420ms, sent 180 B, received 3.1 KiB (18.4 KiB uncompressed)"
+	if got := timing.String(); got != expected {
+		t.Fatalf("String() = %q, want %q", got, expected)
+	}
+
+	timing.Decoded = timing.Received
+	if strings.Contains(timing.String(), "uncompressed") {
+		t.Fatalf("expected no uncompressed size for an uncompressed response, got %q", timing.String())
+	}
+}
+
+func TestRequestLabel(t *testing.T) {
+	req := httptest.NewRequest("GET", sessionURL, nil)
+	if got := requestLabel(req, nil); got != "GET /jmap/session" {
+		t.Fatalf("unexpected label for a session request: %q", got)
+	}
+
+	body := []byte(`{"using": [], "methodCalls": [["Email/query", {}, "0"], ["Email/get", {}, "1"]]}`)
+	if got := requestLabel(req, body); got != "Email/query, Email/get" {
+		t.Fatalf("unexpected label for a JMAP request: %q", got)
+	}
+}
+
+func TestDoHTTPCompressedResponse(t *testing.T) {
+	const text = `{"methodResponses": []}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			t.Errorf("expected the request to accept gzip, got %q", r.Header.Get("Accept-Encoding"))
+		}
+		compressed, _ := gzipBytes([]byte(text))
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(compressed)
+	}))
+	defer server.Close()
+
+	client := &FastmailClient{Token: "token", client: server.Client()}
+	req, err := http.NewRequest("GET", server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []byte
+	err = client.doHTTP(req, nil, opDefault, func(body io.Reader) error {
+		got, err = io.ReadAll(body)
+		return err
+	})
+	if err != nil || string(got) != text {
+		t.Fatalf("expected the decompressed body, got %q, %v", got, err)
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Outcomes of the aliases given to restore <alias>
+const (
+	undeletePlanned   = "planned"
+	undeleteRestored  = "restored"
+	undeleteUnchanged = "unchanged"
+	undeleteFailed    = "failed"
+	undeletePending   = "pending"
+)
+
+// undeleteItem is an alias to restore and what happens to it.
+type undeleteItem struct {
+	Alias MaskedEmailInfo
+	// DeletedAt is when the alias was deleted, from the recycle bin; zero if
+	// it wasn't deleted with this tool
+	DeletedAt time.Time
+	Outcome   string
+	Reason    string
+}
+
+// deletedAgo tells how long ago the alias was deleted, as far as the
+// recycle bin knows.
+func (item undeleteItem) deletedAgo(now time.Time) string {
+	if item.DeletedAt.IsZero() {
+		return "deleted at an unknown time, not with this tool"
+	}
+	return "deleted " + formatTimeAt(item.DeletedAt, now)
+}
+
+// planUndelete finds the aliases with the emails, in order, and plans to
+// enable those that are deleted; the others are left unchanged. The recycle
+// bin tells when the aliases were deleted.
+func planUndelete(aliases []MaskedEmailInfo, emails []string, bin recycleBin) ([]undeleteItem, error) {
+	byEmail := make(map[string]MaskedEmailInfo, len(aliases))
+	for _, alias := range aliases {
+		byEmail[alias.Email] = alias
+	}
+
+	items := make([]undeleteItem, 0, len(emails))
+	seen := make(map[string]bool, len(emails))
+	for _, email := range emails {
+		alias, ok := byEmail[email]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrAliasNotFound, email)
+		}
+		if seen[email] {
+			continue
+		}
+		seen[email] = true
+		item := undeleteItem{Alias: alias, Outcome: undeletePlanned}
+		if entry, ok := bin[email]; ok && entry.ID == alias.ID {
+			item.DeletedAt = entry.DeletedAt
+		}
+		if alias.State != AliasDeleted {
+			item.Outcome, item.Reason = undeleteUnchanged, "already "+string(alias.State)
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// handleUndelete enables the deleted aliases among the identifiers, after
+// confirming with how long ago they were deleted.
+func handleUndelete(client *FastmailClient, identifiers []string) error {
+	emails := make([]string, 0, len(identifiers))
+	for _, identifier := range identifiers {
+		email, err := normalizeEmailInput(identifier)
+		if err != nil {
+			return &exitCodeError{code: exitInvalidInput, err: err}
+		}
+		emails = append(emails, email)
+	}
+
+	aliases, err := fetchAllAliasesWithProgress(client)
+	if err != nil {
+		return formatAPIError("failed to get aliases", err)
+	}
+	bin, err := loadRecycleBin()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not read the recycle bin, so deletion times are unknown: %v\n", err)
+		bin = recycleBin{}
+	}
+	items, err := planUndelete(aliases, emails, bin)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	updates := make(map[string]MaskedEmailUpdate)
+	var question string
+	for _, item := range items {
+		if item.Outcome != undeletePlanned {
+			continue
+		}
+		enabled := AliasEnabled
+		updates[item.Alias.ID] = MaskedEmailUpdate{State: &enabled}
+		fmt.Fprintf(humanOut, "%s (%s) was %s\n", item.Alias.Email, item.Alias.ForDomain, item.deletedAgo(now))
+		question = fmt.Sprintf("Restore %s, %s?", item.Alias.Email, item.deletedAgo(now))
+	}
+	if len(updates) > 1 {
+		question = fmt.Sprintf("Restore these %d aliases?", len(updates))
+	}
+	if len(updates) > 0 {
+		if err := confirmBulk("restore", len(updates), question); err != nil {
+			return err
+		}
+	}
+
+	var result *BatchResult
+	if len(updates) > 0 {
+		ctx, stop := withInterrupt()
+		defer stop()
+		result, err = client.UpdateAliases(ctx, updates, nil)
+	}
+	var restored []MaskedEmailInfo
+	for i := range items {
+		item := &items[i]
+		if item.Outcome != undeletePlanned {
+			continue
+		}
+		item.Outcome = undeletePending
+		if result == nil {
+			continue
+		}
+		if setErr, ok := result.Failed[item.Alias.ID]; ok {
+			item.Outcome, item.Reason = undeleteFailed, setErr.String()
+		}
+		for _, id := range result.Updated {
+			if id == item.Alias.ID {
+				item.Outcome = undeleteRestored
+				restored = append(restored, item.Alias)
+			}
+		}
+	}
+	recordStateChanges(restored, AliasEnabled, now)
+	printUndelete(items)
+
+	if errors.Is(err, ErrInterrupted) {
+		return err
+	}
+	if err != nil {
+		return formatAPIError("failed to restore aliases", err)
+	}
+	if result != nil && len(result.Failed) > 0 {
+		return fmt.Errorf("%d of %d aliases could not be restored", len(result.Failed), len(updates))
+	}
+	return nil
+}
+
+// printUndelete prints the outcome of each alias.
+func printUndelete(items []undeleteItem) {
+	for _, item := range items {
+		if porcelain != "" {
+			// email, deletedAt, outcome, reason
+			deletedAt := ""
+			if !item.DeletedAt.IsZero() {
+				deletedAt = item.DeletedAt.UTC().Format(time.RFC3339)
+			}
+			printPorcelain(item.Alias.Email, deletedAt, item.Outcome, item.Reason)
+			continue
+		}
+		switch item.Outcome {
+		case undeleteRestored:
+			fmt.Printf("Restored %s (enabled)\n", item.Alias.Email)
+		case undeleteUnchanged:
+			fmt.Printf("%s is not deleted; it is %s\n", item.Alias.Email, item.Alias.State)
+		case undeleteFailed:
+			fmt.Printf("Failed to restore %s: %s\n", item.Alias.Email, item.Reason)
+		case undeletePending:
+			fmt.Printf("%s was not restored\n", item.Alias.Email)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPlanUndelete(t *testing.T) {
+	deletedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	aliases := []MaskedEmailInfo{
+		{ID: "a1", Email: "old.1@fastmail.com", State: AliasDeleted},
+		{ID: "a2", Email: "shop.2@fastmail.com", State: AliasEnabled},
+		{ID: "a3", Email: "gone.3@fastmail.com", State: AliasDeleted},
+	}
+	bin := recycleBin{"old.1@fastmail.com": {ID: "a1", DeletedAt: deletedAt, PreviousState: AliasEnabled}}
+
+	items, err := planUndelete(aliases, []string{"old.1@fastmail.com", "shop.2@fastmail.com", "gone.3@fastmail.com", "old.1@fastmail.com"}, bin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 3 {
+		t.Fatalf("got %d items, want 3 without the repeated alias", len(items))
+	}
+	if items[0].Outcome != undeletePlanned || !items[0].DeletedAt.Equal(deletedAt) {
+		t.Errorf("deleted alias: %+v; want planned with the time from the recycle bin", items[0])
+	}
+	if got := items[0].deletedAgo(deletedAt.Add(72 * time.Hour)); got != "deleted 3 days ago" {
+		t.Errorf("deletedAgo() = %q", got)
+	}
+	if items[1].Outcome != undeleteUnchanged || items[1].Reason != "already enabled" {
+		t.Errorf("enabled alias: %+v; want it unchanged", items[1])
+	}
+	if items[2].Outcome != undeletePlanned || !strings.Contains(items[2].deletedAgo(deletedAt), "unknown time") {
+		t.Errorf("alias missing from the recycle bin: %+v; want planned at an unknown time", items[2])
+	}
+
+	if _, err := planUndelete(aliases, []string{"missing@fastmail.com"}, bin); !errors.Is(err, ErrAliasNotFound) {
+		t.Errorf("planUndelete() = %v; want ErrAliasNotFound", err)
+	}
+}
+
+func TestHandleUndelete(t *testing.T) {
+	fake, client := newFakeJMAP(t,
+		MaskedEmailInfo{ID: "a1", Email: "old.1@fastmail.com", State: AliasDeleted},
+		MaskedEmailInfo{ID: "a2", Email: "shop.2@fastmail.com", State: AliasDisabled},
+	)
+	deletedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	bin := recycleBin{"old.1@fastmail.com": {ID: "a1", DeletedAt: deletedAt, PreviousState: AliasEnabled}}
+	if err := writeJSONFile(recycleBinFile, bin); err != nil {
+		t.Fatal(err)
+	}
+	porcelain, assumeYes = porcelainV1, true
+	defer func() { porcelain, assumeYes = "", false }()
+
+	out := captureStdout(t, func() {
+		if err := handleUndelete(client, []string{"old.1@fastmail.com", "shop.2@fastmail.com"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if fake.state("a1") != AliasEnabled || fake.state("a2") != AliasDisabled {
+		t.Errorf("states = %s, %s; want only the deleted alias enabled", fake.state("a1"), fake.state("a2"))
+	}
+	want := "old.1@fastmail.com\t2024-05-01T12:00:00Z\trestored\t\nshop.2@fastmail.com\t\tunchanged\talready disabled\n"
+	if out != want {
+		t.Errorf("output = %q, want %q", out, want)
+	}
+	if bin, err := loadRecycleBin(); err != nil || len(bin) != 0 {
+		t.Errorf("recycle bin = %v, %v; want the restored alias taken out", bin, err)
+	}
+}
+
+func TestRestoresAliases(t *testing.T) {
+	export := filepath.Join(t.TempDir(), "backup@home.json")
+	if err := os.WriteFile(export, []byte("{}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	for args, want := range map[string]bool{
+		"user.1234@fastmail.com":                     true,
+		"user.1234@fastmail.com shop.5@fastmail.com": true,
+		"backup.json":                                false,
+		"user.1234@fastmail.com backup.json":         false,
+		export:                                       false,
+	} {
+		if got := restoresAliases(strings.Fields(args)); got != want {
+			t.Errorf("restoresAliases(%q) = %v, want %v", args, got, want)
+		}
+	}
+}
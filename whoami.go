@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+// identity is what whoami reports: the account a command would change and
+// where its requests go.
+type identity struct {
+	AccountID   string     `json:"accountId"`
+	AccountName string     `json:"accountName"`
+	Email       string     `json:"email"`
+	APIURL      string     `json:"apiUrl"`
+	ReadOnly    bool       `json:"readOnly"`
+	Limits      CoreLimits `json:"limits"`
+}
+
+// newWhoamiCmd creates the command that shows which account commands act on.
+func newWhoamiCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "whoami",
+		Short: "Show the account, user and API endpoint commands act on",
+		Long: `Show the account commands act on, taking --account into account, with the
+user the API token belongs to, the API endpoint of the session and the limits
+of the server. Scripts can check it before changing anything, e.g. with
+--output ndjson, which prints a single JSON object.`,
+		Example: `  masked_fastmail whoami
+  masked_fastmail --account family@example.com whoami --output ndjson | jq -r .accountId`,
+		Args:        cobra.NoArgs,
+		Annotations: map[string]string{outputAnnotation: outputNDJSON},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, err := newClientFromCmd(cmd)
+			if err != nil {
+				return err
+			}
+			id, err := resolveIdentity(client)
+			if err != nil {
+				return err
+			}
+			return printIdentity(id)
+		},
+	}
+}
+
+// resolveIdentity reads the identity from the session of the client.
+func resolveIdentity(client *FastmailClient) (identity, error) {
+	session, err := client.GetSession()
+	if err != nil {
+		return identity{}, formatAPIError("failed to get session", err)
+	}
+	account := session.Accounts[client.AccountID]
+	return identity{
+		AccountID:   client.AccountID,
+		AccountName: account.Name,
+		Email:       session.Username,
+		APIURL:      session.APIURL,
+		ReadOnly:    account.IsReadOnly,
+		Limits:      session.CoreLimits(),
+	}, nil
+}
+
+// printIdentity prints the identity in the selected output format.
+func printIdentity(id identity) error {
+	if outputFormat == outputNDJSON {
+		return printNDJSON(id)
+	}
+	if porcelain != "" {
+		// field, value
+		printPorcelain("accountId", id.AccountID)
+		printPorcelain("accountName", id.AccountName)
+		printPorcelain("email", id.Email)
+		printPorcelain("apiUrl", id.APIURL)
+		printPorcelain("access", choose(id.ReadOnly, "read-only", "read-write"))
+		printPorcelain("maxObjectsInSet", strconv.Itoa(id.Limits.MaxObjectsInSet))
+		printPorcelain("maxCallsInRequest", strconv.Itoa(id.Limits.MaxCallsInRequest))
+		printPorcelain("maxSizeRequest", strconv.Itoa(id.Limits.MaxSizeRequest))
+		return nil
+	}
+
+	fmt.Printf("Email:    %s\n", sanitizeText(id.Email))
+	fmt.Printf("Account:  %s (%s), %s\n", id.AccountID, sanitizeText(id.AccountName), choose(id.ReadOnly, "read-only", "read-write"))
+	fmt.Printf("API:      %s\n", sanitizeText(id.APIURL))
+	fmt.Printf("Limits:   %d objects per set, %d calls per request, %s per request\n",
+		id.Limits.MaxObjectsInSet, id.Limits.MaxCallsInRequest, formatBytes(id.Limits.MaxSizeRequest))
+	return nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestResolveIdentity(t *testing.T) {
+	_, client := newFakeJMAP(t)
+
+	id, err := resolveIdentity(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id.AccountID != "u1" || id.Email != "me@example.com" || id.ReadOnly {
+		t.Errorf("identity = %+v, want the read-write account u1 of me@example.com", id)
+	}
+	if id.APIURL == "" {
+		t.Errorf("APIURL = %q, want the apiUrl of the session", id.APIURL)
+	}
+	if id.Limits.MaxCallsInRequest != 16 {
+		t.Errorf("MaxCallsInRequest = %d, want 16", id.Limits.MaxCallsInRequest)
+	}
+}
+
+func TestPrintIdentityNDJSON(t *testing.T) {
+	outputFormat = outputNDJSON
+	defer func() { outputFormat = outputText }()
+
+	out := captureStdout(t, func() {
+		if err := printIdentity(identity{AccountID: "u1", Email: "me@example.com", APIURL: "https://api.example.com/jmap/api/"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	var got map[string]interface{}
+	if err := json.Unmarshal([]byte(out), &got); err != nil {
+		t.Fatalf("output is not a JSON object: %v\n%s", err, out)
+	}
+	for _, field := range []string{"accountId", "email", "apiUrl", "limits"} {
+		if _, ok := got[field]; !ok {
+			t.Errorf("output has no %s: %s", field, out)
+		}
+	}
+}
+
+func TestPrintIdentityText(t *testing.T) {
+	out := captureStdout(t, func() {
+		printIdentity(identity{AccountID: "u1", AccountName: "Family", Email: "me@example.com", ReadOnly: true})
+	})
+	if !strings.Contains(out, "u1 (Family), read-only") || !strings.Contains(out, "me@example.com") {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+}